@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewAffectedCmd() *cobra.Command {
+	var since string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "affected [name|path]",
+		Short: "Rebuild and restart only what changed since a git ref",
+		Long:  "Map the files changed since the merge base with --since, plus uncommitted and untracked files, to the environment's compose builds, services, tmux windows and artifacts.\nAffected compose services are rebuilt and affected services and windows restarted; artifacts are only reported, since their cache key changed.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			plan, err := mono.FindAffected(path, since)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d file(s) changed since %s (merge base %.12s)\n", len(plan.Changed), plan.Since, plan.Base)
+			if len(plan.Targets) == 0 {
+				fmt.Printf("Nothing in %s is affected\n", plan.EnvName())
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tNAME\tACTION\tFILES")
+			for _, t := range plan.Targets {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Kind, t.Name, t.Action(), t.FileSummary())
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+
+			if dryRun {
+				return nil
+			}
+			return plan.Apply(os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "origin/main", "git ref to compare against, using its merge base with HEAD")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list what is affected")
+
+	return cmd
+}
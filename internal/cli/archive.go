@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewArchiveCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "archive [name|path]",
+		Short: "Archive an environment and remove its worktree",
+		Long:  "Sync artifacts to the cache, snapshot the environment (mono.yml, .mono.local.yaml, data directory,\nports, labels and docker volumes) into ~/.mono/archives/<name>, then destroy it and remove its worktree.\nThe branch is kept. Bring it back with mono unarchive <name>.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			absPath, err := resolvePath(args)
+			if err != nil {
+				return err
+			}
+
+			if !yes && isTerminal(os.Stdin) {
+				ok, err := confirm(fmt.Sprintf("Archive environment at %s and remove its worktree?", absPath))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			manifest, err := mono.Archive(absPath)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Archived %s (%s @ %.12s)\n", manifest.Name, manifest.Branch, manifest.Commit)
+			mono.Printf("  Restore: mono unarchive %s\n", manifest.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+
+	return cmd
+}
+
+func NewUnarchiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unarchive [name]",
+		Short: "Restore an archived environment",
+		Long:  "Recreate the worktree of an archived environment at its original path, restore its config, data directory\nand docker volumes, and initialize it again. Without a name, lists the available archives.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return listArchives()
+			}
+
+			path, err := mono.Unarchive(args[0])
+			if err != nil {
+				return err
+			}
+			mono.Printf("Unarchived %s at %s\n", args[0], path)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func listArchives() error {
+	archives, err := mono.ListArchives()
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		fmt.Println("No archived environments.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBRANCH\tARCHIVED\tVOLUMES\tPATH")
+	for _, a := range archives {
+		path := a.Path
+		if home, err := os.UserHomeDir(); err == nil {
+			path = strings.Replace(path, home, "~", 1)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", a.Name, a.Branch, a.ArchivedAt.Format(time.DateOnly), len(a.Volumes), path)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewArtifactsCmd() *cobra.Command {
+	var env string
+
+	cmd := &cobra.Command{
+		Use:   "artifacts",
+		Short: "Use the files that tasks published, without rebuilding them",
+		Long:  "Tasks with publish in mono.yml copy those outputs to ~/.mono/artifacts after every successful run,\nkeyed by the project and the hash of the task's inputs. Any environment of the project, or a script, can list and fetch them.",
+	}
+
+	cmd.PersistentFlags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+
+	cmd.AddCommand(newArtifactsLsCmd(&env))
+	cmd.AddCommand(newArtifactsGetCmd(&env))
+
+	return cmd
+}
+
+func artifactsEnvPath(env string) (string, error) {
+	var envArgs []string
+	if env != "" {
+		envArgs = []string{env}
+	}
+	return resolveEnvPath(envArgs)
+}
+
+func newArtifactsLsCmd(env *string) *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "ls [task]",
+		Short: "List published artifacts, newest first",
+		Long:  "List what the project's tasks have published, newest first. Pass a task to only list its artifacts,\nincluding every combination of a matrix task.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := artifactsEnvPath(*env)
+			if err != nil {
+				return err
+			}
+			var task string
+			if len(args) == 1 {
+				task = args[0]
+			}
+			artifacts, err := mono.ListArtifacts(path, task)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(artifacts)
+			}
+			if len(artifacts) == 0 {
+				fmt.Println("No published artifacts")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "TASK\tKEY\tFILES\tSIZE\tENV\tCOMMIT\tPUBLISHED\n")
+			for _, a := range artifacts {
+				commit := "-"
+				if a.Commit != "" {
+					commit = fmt.Sprintf("%s@%.8s", a.Branch, a.Commit)
+				}
+				fmt.Fprintf(w, "%s\t%.12s\t%d\t%s\t%s\t%s\t%s\n", a.Task, a.Key, len(a.Files), formatSize(a.Size), a.Env, commit, formatTimeAgo(a.PublishedAt))
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
+
+func newArtifactsGetCmd(env *string) *cobra.Command {
+	var key string
+	var out string
+	var printPath bool
+
+	cmd := &cobra.Command{
+		Use:   "get <task> [file...]",
+		Short: "Copy the files a task published",
+		Long:  "Copy the latest files published by a task, or the ones published under --key, into --out.\nName files to copy only those. With --path, print the directory holding the published files instead.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := artifactsEnvPath(*env)
+			if err != nil {
+				return err
+			}
+			artifact, err := mono.GetArtifact(path, args[0], key)
+			if err != nil {
+				return err
+			}
+			if printPath {
+				fmt.Println(artifact.Path)
+				return nil
+			}
+
+			if err := os.MkdirAll(out, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", out, err)
+			}
+			copied, err := artifact.Export(out, args[1:])
+			for _, c := range copied {
+				fmt.Println(c)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&key, "key", "", "input hash (or a prefix of it) to fetch instead of the latest")
+	cmd.Flags().StringVarP(&out, "out", "o", ".", "directory to copy the files into")
+	cmd.Flags().BoolVar(&printPath, "path", false, "print the directory of the published files instead of copying them")
+
+	return cmd
+}
@@ -9,9 +9,17 @@ import (
 
 func NewAttachCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "attach",
+		Use:   "attach [name|path]",
 		Short: "Attach to a tmux session",
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				path, err := resolvePath(args)
+				if err != nil {
+					return err
+				}
+				return mono.Attach(path)
+			}
 			cwd, err := os.Getwd()
 			if err != nil {
 				return err
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewAuditCmd() *cobra.Command {
+	var filter mono.AuditFilter
+	var since string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show who removed caches, environments and sessions",
+		Long:  "Print the append-only log in ~/.mono/audit.ndjson of every destructive operation: directories removed,\ncache entries evicted, environments destroyed and tmux sessions killed, with the user, host, command and\nthe reason given with --why, oldest first.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if since != "" {
+				age, err := mono.ParseAge(since)
+				if err != nil {
+					return err
+				}
+				filter.Since = time.Now().Add(-age)
+			}
+			entries, err := mono.ReadAudit(filter)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Println("No audit entries")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "TIME\tUSER\tHOST\tACTION\tTARGET\tSIZE\tWHY\tCOMMAND\tERROR\n")
+			for _, e := range entries {
+				who := e.User
+				if e.SudoUser != "" {
+					who = e.SudoUser + " (as " + e.User + ")"
+				}
+				size := "-"
+				if e.Size > 0 {
+					size = formatSize(e.Size)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					e.Time.Local().Format("2006-01-02 15:04:05"),
+					who,
+					e.Host,
+					e.Action,
+					e.Target,
+					size,
+					orDash(e.Why),
+					e.Command,
+					strings.ReplaceAll(e.Error, "\n", " "),
+				)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "only show entries from this long ago (e.g. 24h or 7d)")
+	cmd.Flags().StringVar(&filter.Action, "action", "", "only show entries of this action: "+strings.Join(mono.AuditActions, ", "))
+	cmd.Flags().StringVar(&filter.User, "user", "", "only show entries of this user")
+	cmd.Flags().StringVar(&filter.Env, "env", "", "only show entries of this environment")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
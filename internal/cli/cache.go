@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gwuah/mono/internal/mono"
@@ -15,11 +17,12 @@ func NewCacheCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "cache",
 		Short: "Manage build cache",
-		Long:  "View cache statistics and clean cached build artifacts.",
+		Long:  "View cache statistics and hit-rate reports, and clean cached build artifacts.",
 	}
 
 	cmd.AddCommand(newCacheStatsCmd())
 	cmd.AddCommand(newCacheCleanCmd())
+	cmd.AddCommand(newCacheReportCmd())
 
 	return cmd
 }
@@ -40,7 +43,9 @@ func newCacheStatsCmd() *cobra.Command {
 			}
 			defer db.Close()
 
-			sizes, err := cm.GetCacheSizes()
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			sizes, err := cm.GetCacheSizesContext(ctx)
+			stop()
 			if err != nil {
 				return err
 			}
@@ -160,7 +165,9 @@ func newCacheCleanCmd() *cobra.Command {
 				return err
 			}
 
-			sizes, err := cm.GetCacheSizes()
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			sizes, err := cm.GetCacheSizesContext(ctx)
+			stop()
 			if err != nil {
 				return err
 			}
@@ -178,7 +185,7 @@ func newCacheCleanCmd() *cobra.Command {
 				if err := db.DeleteAllCacheEvents(); err != nil {
 					return fmt.Errorf("failed to clear cache events: %w", err)
 				}
-				fmt.Printf("Removed %d entries (%s)\n", count, formatSize(totalSize))
+				mono.Printf("Removed %d entries (%s)\n", count, formatSize(totalSize))
 				return nil
 			}
 
@@ -256,7 +263,7 @@ func newCacheCleanCmd() *cobra.Command {
 				totalRemoved += entry.Size
 			}
 
-			fmt.Printf("Removed %d entries (%s)\n", len(selected), formatSize(totalRemoved))
+			mono.Printf("Removed %d entries (%s)\n", len(selected), formatSize(totalRemoved))
 			return nil
 		},
 	}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+var trendLevels = []rune("▁▂▃▄▅▆▇█")
+
+func newCacheReportCmd() *cobra.Command {
+	var days int
+	var artifact string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Show hit rates and restore times per artifact",
+		Long:  "Summarize the last --days of cache lookups per project and artifact: how many distinct keys were\nlooked up, the hit rate and its daily trend, and the average time and total bytes of restores.\nMany keys with a low hit rate suggest a key that changes too often, a near-perfect hit rate with a\nsingle key suggests one that misses real changes.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := mono.CacheReport(days, artifact)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			if len(report) == 0 {
+				fmt.Printf("No cache activity in the last %d day(s)\n", days)
+				return nil
+			}
+
+			db, err := mono.OpenDB()
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			rootPaths, err := db.GetAllRootPaths()
+			if err != nil {
+				return err
+			}
+			projectNames := buildProjectNameMap(rootPaths)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "PROJECT\tARTIFACT\tKEYS\tHITS\tMISSES\tHIT RATE\tTREND\tAVG RESTORE\tRESTORED\n")
+			for _, r := range report {
+				project := r.ProjectID
+				if name, ok := projectNames[r.ProjectID]; ok {
+					project = name
+				}
+				avg := "-"
+				if r.Restores > 0 {
+					avg = roundDuration(r.AvgRestore).String()
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n",
+					project,
+					r.Artifact,
+					r.Keys,
+					r.Hits,
+					r.Misses,
+					formatHitRate(r.HitRate()),
+					hitRateTrend(r.Days),
+					avg,
+					formatSize(r.BytesRestored),
+				)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", mono.DefaultCacheReportDays, "number of days to report on, including today")
+	cmd.Flags().StringVar(&artifact, "artifact", "", "only report on this artifact")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
+
+func formatHitRate(rate float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", rate*100)
+}
+
+func hitRateTrend(days []mono.CacheReportDay) string {
+	trend := make([]rune, len(days))
+	for i, d := range days {
+		total := d.Hits + d.Misses
+		if total == 0 {
+			trend[i] = '·'
+			continue
+		}
+		trend[i] = trendLevels[d.Hits*(len(trendLevels)-1)/total]
+	}
+	return string(trend)
+}
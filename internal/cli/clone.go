@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewCloneCmd() *cobra.Command {
+	var opts mono.CloneOptions
+
+	cmd := &cobra.Command{
+		Use:   "clone <src-env> <new-branch>",
+		Short: "Clone an environment including its built artifacts",
+		Long:  "Create a worktree for a new branch at the source environment's HEAD, seed its build artifacts\n(target/, node_modules/, ...) directly from the source environment, then run mono init on it.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, err := resolvePath(args[:1])
+			if err != nil {
+				return err
+			}
+			opts.Source = src
+			opts.Branch = args[1]
+
+			_, err = mono.Clone(opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Dir, "dir", "", "worktree path (default <envs_dir>/<branch>)")
+	cmd.Flags().BoolVar(&opts.EphemeralPorts, "ephemeral-ports", false, "use OS-assigned free ports instead of a port slot")
+
+	return cmd
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewConductorCmd() *cobra.Command {
+	var opts mono.ConductorOptions
+
+	cmd := &cobra.Command{
+		Use:   "conductor",
+		Short: "Register Conductor workspaces automatically",
+		Long:  "Discover Conductor workspaces (default ~/conductor/workspaces/<project>/<workspace>) and keep mono environments in step with them.\nWorkspaces with a mono.yml are initialized; environments whose workspace was deleted are torn down, leaving the worktree to Conductor.",
+	}
+
+	cmd.PersistentFlags().StringVar(&opts.WorkspacesDir, "workspaces-dir", "", "Conductor workspaces directory (default ~/conductor/workspaces)")
+
+	cmd.AddCommand(newConductorListCmd(&opts))
+	cmd.AddCommand(newConductorSyncCmd(&opts))
+	cmd.AddCommand(newConductorWatchCmd(&opts))
+
+	return cmd
+}
+
+func newConductorListCmd(opts *mono.ConductorOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List Conductor workspaces and whether they are registered",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			workspaces, err := mono.ListConductorWorkspaces(*opts)
+			if err != nil {
+				return err
+			}
+
+			if len(workspaces) == 0 {
+				fmt.Println("No Conductor workspaces found.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROJECT\tWORKSPACE\tMONO.YML\tREGISTERED\tPATH")
+			for _, ws := range workspaces {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", ws.Project, ws.Name, yesNo(ws.HasConfig), yesNo(ws.Registered), ws.Path)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newConductorSyncCmd(opts *mono.ConductorOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "sync",
+		Short: "Register new workspaces and tear down deleted ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := mono.SyncConductorWorkspaces(*opts)
+			if err != nil {
+				return err
+			}
+			printConductorSync(result)
+			if len(result.Registered) == 0 && len(result.Deregistered) == 0 && len(result.Failed) == 0 {
+				fmt.Println("Environments are in sync with Conductor workspaces.")
+			}
+			if len(result.Failed) > 0 {
+				return fmt.Errorf("failed to sync %d workspace(s)", len(result.Failed))
+			}
+			return nil
+		},
+	}
+}
+
+func newConductorWatchCmd(opts *mono.ConductorOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Continuously sync environments with Conductor workspaces",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return mono.WatchConductorWorkspaces(ctx, *opts, printConductorSync)
+		},
+	}
+
+	cmd.Flags().DurationVar(&opts.Interval, "interval", 0, "how often to scan for workspace changes when file events are unavailable (default 5s)")
+
+	return cmd
+}
+
+func printConductorSync(result *mono.ConductorSyncResult) {
+	for _, path := range result.Registered {
+		mono.Printf("Registered %s\n", path)
+	}
+	for _, path := range result.Deregistered {
+		mono.Printf("Deregistered %s\n", path)
+	}
+	for _, reload := range result.Reloaded {
+		for _, change := range reload.Applied {
+			mono.Printf("Reloaded %s: %s\n", reload.Env, change)
+		}
+		if len(reload.NeedsInit) > 0 {
+			fmt.Printf("Config of %s changed in ways that need a re-init: %s\n", reload.Env, strings.Join(reload.NeedsInit, ", "))
+		}
+	}
+	if len(result.Failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Failed:\n  %s\n", strings.Join(result.Failed, "\n  "))
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect mono.yml and its format",
+	}
+
+	cmd.AddCommand(newConfigSchemaCmd())
+	cmd.AddCommand(newConfigShowCmd())
+	cmd.AddCommand(newConfigMigrateCmd())
+	cmd.AddCommand(newConfigLintCmd())
+
+	return cmd
+}
+
+func newConfigSchemaCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print a JSON Schema for mono.yml",
+		Long:  "Print a JSON Schema describing mono.yml so editors can complete and validate it.\nWith the VS Code YAML extension, save it with --output and add\n# yaml-language-server: $schema=<path> to the top of mono.yml.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema, err := mono.ConfigSchema()
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err := os.Stdout.Write(schema)
+				return err
+			}
+			if err := os.WriteFile(output, schema, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", output, err)
+			}
+			mono.Printf("Wrote %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the schema to this file instead of stdout")
+
+	return cmd
+}
+
+func newConfigShowCmd() *cobra.Command {
+	var opts mono.ShowConfigOptions
+
+	cmd := &cobra.Command{
+		Use:   "show [name|path]",
+		Short: "Print the effective configuration with the source of each value",
+		Long:  "Print mono.yml after extends/include, .mono.local.yaml, interpolation, defaults, the template\nand the profile are applied. Each value is annotated with the file or flag it came from.\nFor an initialized environment the template, profile and ports recorded at init are used.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			out, err := mono.ShowConfig(path, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Template, "template", "", "apply this template from mono.yml")
+	cmd.Flags().StringVar(&opts.Profile, "profile", "", "apply this profile from mono.yml (falls back to "+mono.ProfileEnvVar+")")
+
+	return cmd
+}
+
+func newConfigMigrateCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate [name|path]",
+		Short: "Rewrite mono.yml to the current config version",
+		Long:  "Upgrade mono.yml to the current config version, renaming or restructuring fields that changed\nand setting the version field. Older configs keep loading in the meantime.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			result, err := mono.MigrateConfig(path)
+			if err != nil {
+				return err
+			}
+			if len(result.Changes) == 0 {
+				fmt.Printf("%s is already at config version %d\n", result.File, mono.CurrentConfigVersion)
+				return nil
+			}
+
+			for _, change := range result.Changes {
+				fmt.Printf("  %s\n", change)
+			}
+			if dryRun {
+				fmt.Println()
+				_, err := os.Stdout.Write(result.Output)
+				return err
+			}
+			if err := mono.WriteMigratedConfig(result); err != nil {
+				return err
+			}
+			mono.Printf("Updated %s to config version %d\n", result.File, mono.CurrentConfigVersion)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrated config instead of writing it")
+
+	return cmd
+}
+
+func newConfigLintCmd() *cobra.Command {
+	var strict bool
+
+	cmd := &cobra.Command{
+		Use:   "lint [name|path]",
+		Short: "Check mono.yml for common mistakes",
+		Long:  "Check mono.yml for mistakes the schema can't catch: artifacts whose paths overlap, key files that\ndon't cover the paths they cache, services no template selects and port requests that don't fit\nin an environment's slot. Exits non-zero on errors, or on warnings too with --strict, so it can run in CI.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			issues, err := mono.LintConfig(path)
+			if err != nil {
+				return err
+			}
+			if len(issues) == 0 {
+				fmt.Println("No problems found")
+				return nil
+			}
+
+			var errs, warnings int
+			for _, issue := range issues {
+				fmt.Printf("  %s\n", issue)
+				if issue.Severity == mono.LintError {
+					errs++
+				} else {
+					warnings++
+				}
+			}
+			if errs > 0 || (strict && warnings > 0) {
+				return fmt.Errorf("found %d error(s) and %d warning(s)", errs, warnings)
+			}
+			fmt.Printf("Found %d warning(s)\n", warnings)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail on warnings as well as errors")
+
+	return cmd
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewUpCmd() *cobra.Command {
+	return newContainersCmd(mono.ContainersUp, "Start an environment's containers", "Started")
+}
+
+func NewDownCmd() *cobra.Command {
+	return newContainersCmd(mono.ContainersDown, "Stop and remove an environment's containers, keeping its volumes", "Stopped")
+}
+
+func NewRestartCmd() *cobra.Command {
+	return newContainersCmd(mono.ContainersRestart, "Restart an environment's containers", "Restarted")
+}
+
+func newContainersCmd(action, short, done string) *cobra.Command {
+	var env string
+
+	cmd := &cobra.Command{
+		Use:   action + " [service...]",
+		Short: short,
+		Long:  short + ", or only the given services.\nOnly containers in the environment's own compose project are touched, never another environment's.\nUses the environment from --env, CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var envArgs []string
+			if env != "" {
+				envArgs = []string{env}
+			}
+			path, err := resolveEnvPath(envArgs)
+			if err != nil {
+				return err
+			}
+
+			envName, err := mono.ManageContainers(path, action, args, os.Stderr)
+			if err != nil {
+				return err
+			}
+			target := "all services"
+			if len(args) > 0 {
+				target = strings.Join(args, ", ")
+			}
+			mono.Printf("%s %s in %s\n", done, target, envName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+
+	return cmd
+}
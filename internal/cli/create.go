@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewCreateCmd() *cobra.Command {
+	var opts mono.CreateOptions
+
+	cmd := &cobra.Command{
+		Use:   "create <branch>",
+		Short: "Create a git worktree for a branch and initialize it",
+		Long:  "Create a git worktree for a new or existing branch under the envs directory\n(envs_dir in mono.yml, default ~/.mono/workspaces/<project>), then run mono init on it.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Branch = args[0]
+			if opts.ProjectRoot == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current directory: %w", err)
+				}
+				opts.ProjectRoot = cwd
+			}
+			if opts.Profile == "" {
+				opts.Profile = os.Getenv(mono.ProfileEnvVar)
+			}
+
+			_, err := mono.Create(opts)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Base, "base", "", "start point for a new branch (default HEAD)")
+	cmd.Flags().StringVar(&opts.Dir, "dir", "", "worktree path (default <envs_dir>/<branch>)")
+	cmd.Flags().StringVar(&opts.ProjectRoot, "project", "", "repository to create the worktree from (default current directory)")
+	cmd.Flags().BoolVar(&opts.EphemeralPorts, "ephemeral-ports", false, "use OS-assigned free ports instead of a port slot")
+	cmd.Flags().StringVar(&opts.Template, "template", "", "template from mono.yml selecting which artifacts, services and tmux windows to set up")
+	cmd.Flags().StringVar(&opts.Profile, "profile", "", "profile from mono.yml enabling artifacts, services and sccache (falls back to "+mono.ProfileEnvVar+")")
+
+	return cmd
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewDaemonCmd() *cobra.Command {
+	var opts mono.DaemonOptions
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve the local mono API over a unix socket",
+		Long:  "Serve a JSON API on a unix socket (default ~/.mono/mono.sock) so external tools can query and reserve port allocations,\nand run mono commands as background jobs (see mono jobs).\n\nEndpoints:\n  GET    /v1/envs\n  GET    /v1/envs/{env}/ports\n  GET    /v1/envs/{env}/ports/{service}\n  POST   /v1/envs/{env}/ports   {\"service\", \"container_port\", \"count\", \"protocol\"}\n  DELETE /v1/envs/{env}/ports/{service}\n  GET    /v1/jobs\n  POST   /v1/jobs   {\"args\", \"dir\", \"env\"}\n  GET    /v1/jobs/{id}\n  GET    /v1/jobs/{id}/log?follow=true\n  POST   /v1/jobs/{id}/cancel",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return mono.RunDaemon(ctx, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Socket, "socket", "", "unix socket path (default ~/.mono/mono.sock)")
+	cmd.Flags().DurationVar(&opts.AutoPruneInterval, "auto-prune-interval", 10*time.Minute, "how often to apply the prune.auto policy (0 disables)")
+	cmd.Flags().DurationVar(&opts.ConfigReloadInterval, "config-reload-interval", time.Minute, "how often to look for newly registered environments to watch; edits to mono.yml, its extends/include files, .mono.local.yaml and ~/.mono/config.yaml are picked up from file events (without file events, every config is rescanned at this interval; 0 disables)")
+
+	return cmd
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Snapshot and restore an environment's database",
+		Long:  "Save the environment's postgres database under a name and restore it later, to reset test data\nor copy data from another environment. Uses the environment from --env, CONDUCTOR_WORKSPACE_PATH or the current directory.",
+	}
+
+	cmd.AddCommand(newDBSnapshotCmd())
+	cmd.AddCommand(newDBRestoreCmd())
+	cmd.AddCommand(newDBListCmd())
+	cmd.AddCommand(newDBRemoveCmd())
+
+	return cmd
+}
+
+func dbEnvPath(env string) (string, error) {
+	var envArgs []string
+	if env != "" {
+		envArgs = []string{env}
+	}
+	return resolveEnvPath(envArgs)
+}
+
+func snapshotName(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return mono.DefaultSnapshotName
+}
+
+func newDBSnapshotCmd() *cobra.Command {
+	var env string
+	var opts mono.DBSnapshotOptions
+
+	cmd := &cobra.Command{
+		Use:   "snapshot [name]",
+		Short: "Save the database under a name (default \"default\")",
+		Long:  "Dump the environment's postgres database with pg_dump into ~/.mono/snapshots/<env>/<name>.dump.\nWith --template, copy it into a template database inside the same server instead, which is much faster\nto take and restore but can't be restored into another environment. An existing snapshot with the same name is replaced.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := dbEnvPath(env)
+			if err != nil {
+				return err
+			}
+			opts.Name = snapshotName(args)
+
+			snap, err := mono.SnapshotDatabase(path, opts)
+			if err != nil {
+				return err
+			}
+			if snap.Method == mono.SnapshotTemplate {
+				mono.Printf("Saved %s of %s as template snapshot %s\n", snap.Database, snap.Env, snap.Name)
+				return nil
+			}
+			mono.Printf("Saved %s of %s as snapshot %s (%s)\n", snap.Database, snap.Env, snap.Name, formatSize(snap.Size))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+	cmd.Flags().StringVar(&opts.Service, "service", "", "postgres service to snapshot, needed when there are several")
+	cmd.Flags().BoolVar(&opts.Template, "template", false, "snapshot into a template database inside the server instead of a dump file")
+
+	return cmd
+}
+
+func newDBRestoreCmd() *cobra.Command {
+	var env, from string
+	var opts mono.DBRestoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "restore [name]",
+		Short: "Replace the database with a snapshot (default \"default\")",
+		Long:  "Drop the environment's postgres database and recreate it from a snapshot.\nWith --from, restore a snapshot taken in another environment.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := dbEnvPath(env)
+			if err != nil {
+				return err
+			}
+			if from != "" {
+				opts.From, err = resolveEnvPath([]string{from})
+				if err != nil {
+					return err
+				}
+			}
+			opts.Name = snapshotName(args)
+
+			snap, err := mono.RestoreDatabase(path, opts)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Restored snapshot %s of %s (taken %s)\n", snap.Name, snap.Env, snap.Created.Local().Format(time.DateTime))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+	cmd.Flags().StringVar(&from, "from", "", "environment name or path the snapshot was taken in")
+	cmd.Flags().StringVar(&opts.Service, "service", "", "postgres service to restore into, needed when there are several")
+
+	return cmd
+}
+
+func newDBListCmd() *cobra.Command {
+	var env string
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List an environment's database snapshots",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := dbEnvPath(env)
+			if err != nil {
+				return err
+			}
+
+			snapshots, err := mono.ListDBSnapshots(path)
+			if err != nil {
+				return err
+			}
+			if len(snapshots) == 0 {
+				fmt.Println("No snapshots.")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSERVICE\tDATABASE\tMETHOD\tSIZE\tCREATED")
+			for _, s := range snapshots {
+				size := "-"
+				if s.Method == mono.SnapshotDump {
+					size = formatSize(s.Size)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.Service, s.Database, s.Method, size, s.Created.Local().Format(time.DateTime))
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+
+	return cmd
+}
+
+func newDBRemoveCmd() *cobra.Command {
+	var env string
+
+	cmd := &cobra.Command{
+		Use:     "rm <name>",
+		Aliases: []string{"remove"},
+		Short:   "Delete a database snapshot",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := dbEnvPath(env)
+			if err != nil {
+				return err
+			}
+			if err := mono.RemoveDBSnapshot(path, args[0]); err != nil {
+				return err
+			}
+			mono.Printf("Removed snapshot %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewDebugCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Collect information for bug reports",
+	}
+
+	cmd.AddCommand(newDebugBundleCmd())
+
+	return cmd
+}
+
+func newDebugBundleCmd() *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "bundle [path]",
+		Short: "Write a tarball to attach to an issue",
+		Long:  "Collect versions, mono doctor's checks, the effective config with secrets redacted, the environments and\nport allocations, cache index stats and the tail of ~/.mono/mono.log and the event log into a tar.gz.\nLogs are included as they are, so look through the bundle before sharing it.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+			if out == "" {
+				out = fmt.Sprintf("mono-debug-%s.tar.gz", time.Now().Format("20060102-150405"))
+			}
+
+			manifest, err := mono.DebugBundle(path, out)
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(manifest.Errors))
+			for name := range manifest.Errors {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(os.Stderr, "warning: could not collect %s: %s\n", name, manifest.Errors[name])
+			}
+
+			info, err := os.Stat(out)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Wrote %s (%s, %d file(s))\n", out, formatSize(info.Size()), len(manifest.Files))
+			mono.Println("Logs are included as they are, look through the bundle before attaching it to an issue.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write (default mono-debug-<timestamp>.tar.gz in the current directory)")
+
+	return cmd
+}
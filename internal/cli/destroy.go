@@ -1,25 +1,108 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/gwuah/mono/internal/mono"
 	"github.com/spf13/cobra"
 )
 
 func NewDestroyCmd() *cobra.Command {
+	var opts mono.DestroyOptions
+	var yes bool
+	var filterFlags envFilterFlags
+
 	cmd := &cobra.Command{
-		Use:   "destroy [path]",
+		Use:   "destroy [name|path]",
 		Short: "Destroy an environment",
-		Long:  "Stop containers, kill tmux session, and clean up data.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH.",
+		Long:  "Sync artifacts to the cache, kill the tmux session, remove containers and volumes, release ports,\nderegister the environment, and remove its git worktree (unless --keep-worktree or run under Conductor).\nAccepts an environment name or path. If neither is provided, uses CONDUCTOR_WORKSPACE_PATH.\nWith --label and/or --older-than, destroys every matching environment instead.",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if os.Getenv("CONDUCTOR_WORKSPACE_PATH") != "" {
+				opts.KeepWorktree = true
+			}
+
+			filter, err := filterFlags.filter()
+			if err != nil {
+				return err
+			}
+			if !filter.Empty() {
+				if len(args) > 0 {
+					return fmt.Errorf("cannot combine an environment argument with --label or --older-than")
+				}
+				return destroyMatching(filter, opts, yes)
+			}
+
 			absPath, err := resolvePath(args)
 			if err != nil {
 				return err
 			}
 
-			return mono.Destroy(absPath)
+			if !yes && isTerminal(os.Stdin) {
+				prompt := fmt.Sprintf("Destroy environment at %s", absPath)
+				if !opts.KeepWorktree {
+					prompt += " and remove its worktree"
+				}
+				ok, err := confirm(prompt + "?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			return mono.Destroy(absPath, opts)
 		},
 	}
 
+	cmd.Flags().BoolVar(&opts.KeepWorktree, "keep-worktree", false, "keep the git worktree on disk")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	filterFlags.register(cmd)
+
 	return cmd
 }
+
+func destroyMatching(filter mono.EnvFilter, opts mono.DestroyOptions, yes bool) error {
+	envs, err := mono.FilterEnvironments(filter)
+	if err != nil {
+		return err
+	}
+	if len(envs) == 0 {
+		fmt.Println("No matching environments.")
+		return nil
+	}
+
+	for _, env := range envs {
+		fmt.Printf("  %s (%s)\n", env.EnvName(), env.Path)
+	}
+
+	if !yes {
+		if !isTerminal(os.Stdin) {
+			return fmt.Errorf("refusing to destroy %d environment(s) without --yes", len(envs))
+		}
+		ok, err := confirm(fmt.Sprintf("Destroy these %d environment(s)?", len(envs)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	var failed []string
+	for _, env := range envs {
+		if err := mono.Destroy(env.Path, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to destroy %s: %v\n", env.EnvName(), err)
+			failed = append(failed, env.EnvName())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to destroy %d environment(s): %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewDirenvCmd() *cobra.Command {
+	var print bool
+
+	cmd := &cobra.Command{
+		Use:   "direnv [name|path]",
+		Short: "Write an .envrc exporting the environment's variables",
+		Long:  "Write an .envrc into the environment that exports its ports, database URLs, cache variables and env from mono.yml,\nand adds direnv.path entries to PATH, then runs direnv allow if direnv is installed.\nWith direnv.enabled in mono.yml the file is written on init and kept in sync when ports or the name change.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			if print {
+				content, err := mono.PrintEnvrc(path)
+				if err != nil {
+					return err
+				}
+				fmt.Print(content)
+				return nil
+			}
+
+			written, err := mono.GenerateEnvrc(path)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Wrote %s\n", written)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&print, "print", false, "print the .envrc to stdout instead of writing it")
+
+	return cmd
+}
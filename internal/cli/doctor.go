@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewDoctorCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor [path]",
+		Short: "Check that this machine has what mono needs",
+		Long:  "Check tmux, the container runtime, sccache, free disk space under ~/.mono, whether the cache volume\nsupports hardlinks, clones and flock, and the git worktree setup, with a fix for every problem found.\nExits non-zero when a check fails. If no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			checks, err := mono.Doctor(path)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(checks); err != nil {
+					return err
+				}
+			} else {
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				for _, c := range checks {
+					fmt.Fprintf(w, "%s\t%s\t%s\n", c.Status, c.Name, c.Detail)
+					if c.Fix != "" && c.Status != mono.DoctorOK {
+						fmt.Fprintf(w, "\t\tfix: %s\n", c.Fix)
+					}
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+			}
+
+			var errs int
+			for _, c := range checks {
+				if c.Status == mono.DoctorError {
+					errs++
+				}
+			}
+			if errs > 0 {
+				return fmt.Errorf("%d check(s) failed", errs)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
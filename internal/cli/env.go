@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewEnvCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Manage environments",
+	}
+
+	cmd.AddCommand(newEnvPruneCmd())
+	cmd.AddCommand(newEnvDotenvCmd())
+
+	return cmd
+}
+
+func newEnvPruneCmd() *cobra.Command {
+	var days int
+	var yes bool
+	var dryRun bool
+	var keepWorktree bool
+	var auto bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Destroy stale environments",
+		Long:  "Find environments whose directory is missing, whose branch was merged or deleted upstream,\nor that have not been used for --days, and offer to destroy each one with the full teardown.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if auto {
+				return runAutoPrune()
+			}
+
+			stale, err := mono.FindStaleEnvironments(mono.PruneOptions{
+				OlderThan: time.Duration(days) * 24 * time.Hour,
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(stale) == 0 {
+				fmt.Println("No stale environments found.")
+				return nil
+			}
+
+			interactive := !yes && isTerminal(os.Stdin)
+			if !yes && !interactive && !dryRun {
+				dryRun = true
+			}
+
+			var failed []string
+			for _, s := range stale {
+				fmt.Printf("%s (%s): %s\n", s.Name, s.Path, strings.Join(s.Reasons, ", "))
+				if dryRun {
+					continue
+				}
+
+				if interactive {
+					ok, err := confirm(fmt.Sprintf("Destroy %s?", s.Name))
+					if err != nil {
+						return err
+					}
+					if !ok {
+						continue
+					}
+				}
+
+				if err := mono.Destroy(s.Path, mono.DestroyOptions{KeepWorktree: keepWorktree}); err != nil {
+					fmt.Fprintf(os.Stderr, "failed to destroy %s: %v\n", s.Name, err)
+					failed = append(failed, s.Name)
+				}
+			}
+
+			if dryRun {
+				fmt.Println("Dry run: nothing was destroyed. Run interactively or with --yes to prune.")
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("failed to prune %d environment(s): %s", len(failed), strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&days, "days", 30, "treat environments unused for this many days as stale (0 to disable)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "destroy all stale environments without prompting")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list stale environments")
+	cmd.Flags().BoolVar(&keepWorktree, "keep-worktree", false, "keep git worktrees on disk")
+	cmd.Flags().BoolVar(&auto, "auto", false, "apply the prune.auto policy: destroy environments whose branch was merged or deleted once the grace period passes")
+
+	return cmd
+}
+
+func runAutoPrune() error {
+	logger, err := mono.NewFileLogger("prune")
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	result, err := mono.AutoPrune(logger)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range result.Pending {
+		mono.Printf("Pending %s: %s\n", s.Name, strings.Join(s.Reasons, ", "))
+	}
+	for _, s := range result.Destroyed {
+		mono.Printf("Destroyed %s: %s\n", s.Name, strings.Join(s.Reasons, ", "))
+	}
+	if len(result.Pending) == 0 && len(result.Destroyed) == 0 && len(result.Failed) == 0 {
+		fmt.Println("No environments to auto-prune.")
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("auto-prune failed for:\n  %s", strings.Join(result.Failed, "\n  "))
+	}
+	return nil
+}
+
+func newEnvDotenvCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "dotenv [name|path]",
+		Short: "Regenerate the .env.mono file for an environment",
+		Long:  "Write allocated ports, database URLs derived from the compose services, cache variables,\nand the dotenv.vars templates from mono.yml to the environment's dotenv file (default .env.mono).\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			written, err := mono.GenerateDotenv(path)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Wrote %s\n", written)
+			return nil
+		},
+	}
+}
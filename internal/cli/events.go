@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewEventsCmd() *cobra.Command {
+	var filter mono.EventFilter
+	var since string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show the log of cache and environment operations",
+		Long:  "Print the append-only log in ~/.mono/events.ndjson: cache restores, stores and evictions, inits and destroys,\nwith their keys, sizes and durations, oldest first. Failed restores, inits and destroys carry their error.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if since != "" {
+				age, err := mono.ParseAge(since)
+				if err != nil {
+					return err
+				}
+				filter.Since = time.Now().Add(-age)
+			}
+			events, err := mono.ReadEvents(filter)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(events)
+			}
+			if len(events) == 0 {
+				fmt.Println("No events")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "TIME\tEVENT\tENV\tARTIFACT\tKEY\tSIZE\tDURATION\tERROR\n")
+			for _, e := range events {
+				size := "-"
+				if e.Size > 0 {
+					size = formatSize(e.Size)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.12s\t%s\t%s\t%s\n",
+					e.Time.Local().Format("2006-01-02 15:04:05"),
+					e.Type,
+					orDash(e.Env),
+					orDash(e.Artifact),
+					orDash(e.Key),
+					size,
+					roundDuration(time.Duration(e.DurationMS)*time.Millisecond),
+					strings.ReplaceAll(e.Error, "\n", " "),
+				)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "only show events from this long ago (e.g. 24h or 7d)")
+	cmd.Flags().StringVar(&filter.Artifact, "artifact", "", "only show events of this artifact (task caches are task-<name>)")
+	cmd.Flags().StringVar(&filter.Type, "type", "", "only show events of this type: "+strings.Join(mono.EventTypes, ", "))
+	cmd.Flags().StringVar(&filter.Env, "env", "", "only show events of this environment")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewHostsCmd() *cobra.Command {
+	var write bool
+	var remove bool
+
+	cmd := &cobra.Command{
+		Use:   "hosts [name|path]",
+		Short: "Show or update local hostnames for an environment",
+		Long:  "Show the hostnames mapped to 127.0.0.1 for an environment, e.g. api.<env>.test.\nWith --write or --remove, update the managed block in the hosts file (usually requires root).\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if write && remove {
+				return fmt.Errorf("--write and --remove are mutually exclusive")
+			}
+
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			env, hostsCfg, hostnames, err := mono.GetEnvHosts(path)
+			if err != nil {
+				return err
+			}
+			envName := env.EnvName()
+
+			if remove {
+				if err := mono.RemoveHostsEntries(hostsCfg.File, envName); err != nil {
+					return err
+				}
+				mono.Printf("Removed hosts entries for %s from %s\n", envName, hostsCfg.File)
+				return nil
+			}
+
+			if write {
+				if err := mono.UpdateHostsFile(hostsCfg.File, envName, hostnames); err != nil {
+					return err
+				}
+				mono.Printf("Updated %s\n", hostsCfg.File)
+			}
+
+			for _, h := range hostnames {
+				fmt.Println(h)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&write, "write", false, "write the hostnames to the hosts file")
+	cmd.Flags().BoolVar(&remove, "remove", false, "remove the hostnames from the hosts file")
+
+	return cmd
+}
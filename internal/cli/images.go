@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewImagesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "images",
+		Short: "Manage the container images of an environment",
+	}
+
+	cmd.AddCommand(newImagesPullCmd())
+
+	return cmd
+}
+
+func newImagesPullCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "pull [name|path]",
+		Short: "Pull every image the environment's compose services use",
+		Long:  "Pull the images of an environment's compose services in parallel, skipping ones that are already present.\nWith images.mirror.enabled, Docker Hub images go through a local pull-through registry shared by all environments.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			pulls, err := mono.PullEnvImages(path, force, os.Stderr)
+			var pulled, present, failed int
+			for _, p := range pulls {
+				switch {
+				case p.Err != nil:
+					failed++
+				case p.Present:
+					present++
+				default:
+					pulled++
+				}
+			}
+			if pulls != nil {
+				mono.Printf("Pulled %d image(s), %d already present, %d failed\n", pulled, present, failed)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "pull images even if they are already present")
+
+	return cmd
+}
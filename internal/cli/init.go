@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/gwuah/mono/internal/mono"
 	"github.com/spf13/cobra"
@@ -10,11 +11,15 @@ import (
 
 func NewInitCmd() *cobra.Command {
 	var projectRoot string
+	var ephemeralPorts bool
+	var template string
+	var profile string
+	var wait time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "init [path]",
 		Short: "Initialize a new environment",
-		Long:  "Register an environment, start containers, and create a tmux session.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH.",
+		Long:  "Register an environment, start containers, and create a tmux session.\nPaths under shared are symlinked to their source. Only compose bind mounts of them are read-only; writes through the symlinks on the host change the source for every environment.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH.",
 		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			absPath, err := resolvePath(args)
@@ -26,11 +31,25 @@ func NewInitCmd() *cobra.Command {
 				return fmt.Errorf("path does not exist: %s", absPath)
 			}
 
-			return mono.Init(absPath, projectRoot)
+			if profile == "" {
+				profile = os.Getenv(mono.ProfileEnvVar)
+			}
+
+			return mono.Init(absPath, mono.InitOptions{
+				ProjectRoot:    projectRoot,
+				EphemeralPorts: ephemeralPorts,
+				Template:       template,
+				Profile:        profile,
+				LockWait:       wait,
+			})
 		},
 	}
 
-	cmd.Flags().StringVar(&projectRoot, "project", "", "root path of the project (falls back to CONDUCTOR_ROOT_PATH)")
+	cmd.Flags().StringVar(&projectRoot, "project", "", "root path of the project (falls back to CONDUCTOR_ROOT_PATH, then the main git worktree)")
+	cmd.Flags().BoolVar(&ephemeralPorts, "ephemeral-ports", false, "use OS-assigned free ports instead of a port slot")
+	cmd.Flags().DurationVar(&wait, "wait", 0, "if another init is running for this path, wait up to this long instead of failing")
+	cmd.Flags().StringVar(&template, "template", "", "template from mono.yml selecting which artifacts, services and tmux windows to set up")
+	cmd.Flags().StringVar(&profile, "profile", "", "profile from mono.yml enabling artifacts, services and sccache (falls back to "+mono.ProfileEnvVar+")")
 
 	return cmd
 }
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+const jobProgressWidth = 60
+
+func NewJobsCmd() *cobra.Command {
+	var socket string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Run mono commands in the background through the daemon",
+		Long:  "List the jobs the mono daemon is running or has finished, with the last line of their output as progress.\nJobs keep running when the terminal that started them closes. Requires a running mono daemon.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := mono.NewDaemonClient(socket)
+			if err != nil {
+				return err
+			}
+			jobs, err := client.Jobs()
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(jobs)
+			}
+			if len(jobs) == 0 {
+				fmt.Println("No jobs")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "ID\tSTATUS\tELAPSED\tCOMMAND\tPROGRESS\n")
+			for _, j := range jobs {
+				progress := j.Progress
+				if len(progress) > jobProgressWidth {
+					progress = progress[:jobProgressWidth-3] + "..."
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", j.ID, j.Status, roundDuration(j.Elapsed()), j.Command(), progress)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(&socket, "socket", "", "unix socket path of the daemon (default ~/.mono/mono.sock)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	cmd.AddCommand(newJobsStartCmd(&socket))
+	cmd.AddCommand(newJobsLogsCmd(&socket))
+	cmd.AddCommand(newJobsCancelCmd(&socket))
+
+	return cmd
+}
+
+func newJobsStartCmd(socket *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start -- <command...>",
+		Short: "Start a mono command as a background job",
+		Long:  "Hand a mono command to the daemon, e.g. mono jobs start -- task build --env feature.\nIt runs in the current directory, and its output goes to a log that mono jobs logs can stream.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.ArgsLenAtDash() != 0 || len(args) == 0 {
+				return fmt.Errorf("expected a mono command after --, e.g. mono jobs start -- task build")
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get working directory: %w", err)
+			}
+			req := mono.JobRequest{Args: args, Dir: cwd}
+			if ws := os.Getenv("CONDUCTOR_WORKSPACE_PATH"); ws != "" {
+				req.Env = append(req.Env, "CONDUCTOR_WORKSPACE_PATH="+ws)
+			}
+
+			client, err := mono.NewDaemonClient(*socket)
+			if err != nil {
+				return err
+			}
+			job, err := client.StartJob(req)
+			if err != nil {
+				return err
+			}
+			mono.Printf("Started job %s: %s\n", job.ID, job.Command())
+			mono.Printf("Follow it with mono jobs logs %s -f\n", job.ID)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newJobsLogsCmd(socket *string) *cobra.Command {
+	var follow bool
+
+	cmd := &cobra.Command{
+		Use:   "logs <id>",
+		Short: "Print the output of a job",
+		Long:  "Print what a job has written so far. With -f, keep streaming until the job finishes\nand exit non-zero if it failed or was cancelled.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := mono.NewDaemonClient(*socket)
+			if err != nil {
+				return err
+			}
+			if err := client.StreamJobLog(args[0], follow, os.Stdout); err != nil {
+				return err
+			}
+			if !follow {
+				return nil
+			}
+			job, err := client.Job(args[0])
+			if err != nil {
+				return err
+			}
+			switch job.Status {
+			case mono.JobSucceeded:
+				return nil
+			case mono.JobRunning:
+				return fmt.Errorf("stopped following job %s while it was still running", job.ID)
+			default:
+				return fmt.Errorf("job %s %s after %s (exit code %d)", job.ID, job.Status, roundDuration(job.Elapsed()), job.ExitCode)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "stream the output until the job finishes")
+
+	return cmd
+}
+
+func newJobsCancelCmd(socket *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cancel <id>",
+		Short: "Interrupt a running job",
+		Long:  "Send an interrupt to a job and everything it started, and kill it if it is still running 10s later.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := mono.NewDaemonClient(*socket)
+			if err != nil {
+				return err
+			}
+			job, err := client.CancelJob(args[0])
+			if err != nil {
+				return err
+			}
+			mono.Printf("Cancelling job %s: %s\n", job.ID, job.Command())
+			return nil
+		},
+	}
+
+	return cmd
+}
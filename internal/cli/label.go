@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewLabelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "label",
+		Short: "Manage environment labels",
+		Long:  "Attach key or key=value labels to environments and use them to filter list and destroy,\ne.g. mono label add feature-x team=payments, then mono destroy --label team=payments --older-than 7d.",
+	}
+
+	cmd.AddCommand(newLabelAddCmd())
+	cmd.AddCommand(newLabelRemoveCmd())
+	cmd.AddCommand(newLabelListCmd())
+
+	return cmd
+}
+
+func newLabelAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <name|path> <key[=value]>...",
+		Short: "Add or update labels on an environment",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolvePath(args[:1])
+			if err != nil {
+				return err
+			}
+
+			labels, err := mono.AddLabels(path, args[1:])
+			if err != nil {
+				return err
+			}
+			fmt.Println(mono.FormatLabels(labels))
+			return nil
+		},
+	}
+}
+
+func newLabelRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rm <name|path> <key>...",
+		Aliases: []string{"remove"},
+		Short:   "Remove labels from an environment",
+		Args:    cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolvePath(args[:1])
+			if err != nil {
+				return err
+			}
+
+			labels, err := mono.RemoveLabels(path, args[1:])
+			if err != nil {
+				return err
+			}
+			if len(labels) == 0 {
+				fmt.Println("No labels.")
+				return nil
+			}
+			fmt.Println(mono.FormatLabels(labels))
+			return nil
+		},
+	}
+}
+
+func newLabelListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls [name|path]",
+		Short: "Show the labels of an environment",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			labels, err := mono.GetEnvLabels(path)
+			if err != nil {
+				return err
+			}
+			if len(labels) == 0 {
+				fmt.Println("No labels.")
+				return nil
+			}
+			fmt.Println(mono.FormatLabels(labels))
+			return nil
+		},
+	}
+}
+
+type envFilterFlags struct {
+	labels    []string
+	olderThan string
+}
+
+func (f *envFilterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&f.labels, "label", "l", nil, "only environments with this label (key or key=value, repeatable)")
+	cmd.Flags().StringVar(&f.olderThan, "older-than", "", "only environments created longer ago than this (e.g. 7d, 12h)")
+}
+
+func (f *envFilterFlags) filter() (mono.EnvFilter, error) {
+	var filter mono.EnvFilter
+	sel, err := mono.ParseLabelSelector(f.labels)
+	if err != nil {
+		return filter, err
+	}
+	filter.Labels = sel
+	if strings.TrimSpace(f.olderThan) != "" {
+		age, err := mono.ParseAge(f.olderThan)
+		if err != nil {
+			return filter, err
+		}
+		filter.OlderThan = age
+	}
+	return filter, nil
+}
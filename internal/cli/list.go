@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -11,24 +12,48 @@ import (
 )
 
 func NewListCmd() *cobra.Command {
+	var asJSON bool
+	var filterFlags envFilterFlags
+
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List all environments",
-		Long:  "Show all registered environments with their status.",
+		Long:  "Show all registered environments with their branch, tmux and container state, port slot, and cache freshness.",
 		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			statuses, err := mono.List()
+			filter, err := filterFlags.filter()
+			if err != nil {
+				return err
+			}
+
+			all, err := mono.List()
 			if err != nil {
 				return err
 			}
 
+			var statuses []mono.EnvironmentStatus
+			for _, s := range all {
+				if filter.Matches(s.CreatedAt, s.Labels) {
+					statuses = append(statuses, s)
+				}
+			}
+
+			if asJSON {
+				if statuses == nil {
+					statuses = []mono.EnvironmentStatus{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(statuses)
+			}
+
 			if len(statuses) == 0 {
 				fmt.Println("No environments found.")
 				return nil
 			}
 
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tPATH\tSTATUS")
+			fmt.Fprintln(w, "NAME\tBRANCH\tSTATUS\tSLOT\tCACHE\tLABELS\tPATH")
 
 			for _, s := range statuses {
 				status := getStatus(s.TmuxRunning, s.DockerRunning)
@@ -38,13 +63,26 @@ func NewListCmd() *cobra.Command {
 					path = strings.Replace(path, home, "~", 1)
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, path, status)
+				branch := s.Branch
+				if branch == "" {
+					branch = "-"
+				}
+
+				slot := "-"
+				if s.PortSlot != nil {
+					slot = fmt.Sprintf("%d", *s.PortSlot)
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, branch, status, slot, s.Cache, orDash(mono.FormatLabels(s.Labels)), path)
 			}
 
 			return w.Flush()
 		},
 	}
 
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+	filterFlags.register(cmd)
+
 	return cmd
 }
 
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewLogsCmd() *cobra.Command {
+	var opts mono.LogsOptions
+	var noColor bool
+
+	cmd := &cobra.Command{
+		Use:   "logs [name|path]",
+		Short: "Show logs of an environment's containers",
+		Long:  "Show the logs of an environment's compose services, one line per entry prefixed with the service name.\nUse --service to pick services instead of looking up the per-environment container names.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+			opts.Color = !noColor && isTerminal(os.Stdout)
+			return mono.StreamLogs(path, opts, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&opts.Services, "service", "s", nil, "only show logs of this service (repeatable, default all services)")
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "keep streaming new log lines")
+	cmd.Flags().StringVarP(&opts.Tail, "tail", "n", "", "number of lines to show from the end of each service's log (default all)")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "only show logs since a timestamp (e.g. 2024-01-02T13:23:37Z) or relative duration (e.g. 10m)")
+	cmd.Flags().BoolVar(&noColor, "no-color", false, "don't color the service prefixes")
+
+	return cmd
+}
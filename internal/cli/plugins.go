@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewPluginsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plugins [name|path]",
+		Short: "List mono-plugin-* executables and the plugins mono.yml declares",
+		Long:  "List every mono-plugin-* executable found on PATH, and for the project at the given path which of them\nmono.yml declares under plugins and for which events. Declared plugins missing from PATH are reported too.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+			cfg, err := mono.LoadConfig(path)
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidatePlugins(); err != nil {
+				return err
+			}
+
+			declared := make(map[string]mono.PluginConfig, len(cfg.Plugins))
+			for _, p := range cfg.Plugins {
+				declared[p.Name] = p
+			}
+
+			discovered := mono.DiscoverPlugins()
+			if len(discovered) == 0 && len(cfg.Plugins) == 0 {
+				fmt.Printf("No %s* executables found on PATH\n", mono.PluginPrefix)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tEVENTS\tPATH")
+			found := make(map[string]bool, len(discovered))
+			for _, d := range discovered {
+				found[d.Name] = true
+				events := "-"
+				if p, ok := declared[d.Name]; ok {
+					events = pluginEvents(p)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", d.Name, events, d.Path)
+			}
+			for _, p := range cfg.Plugins {
+				if !found[p.Name] {
+					fmt.Fprintf(w, "%s\t%s\t(%s not found on PATH)\n", p.Name, pluginEvents(p), p.Executable())
+				}
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func pluginEvents(p mono.PluginConfig) string {
+	if len(p.Events) == 0 {
+		return strings.Join(mono.PluginEvents, ",")
+	}
+	return strings.Join(p.Events, ",")
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewPortsCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "ports [name|path]",
+		Short: "Show port allocations",
+		Long:  "Show the host ports allocated to an environment and whether they are in use.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			env, statuses, err := mono.GetPortStatus(path)
+			if err != nil {
+				return err
+			}
+			envName := env.EnvName()
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(struct {
+					Env   string            `json:"env"`
+					Path  string            `json:"path"`
+					Ports []mono.PortStatus `json:"ports"`
+				}{envName, env.Path, statuses})
+			}
+
+			if len(statuses) == 0 {
+				fmt.Printf("No ports allocated for %s.\n", envName)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SERVICE\tCONTAINER\tHOST\tPROTOCOL\tSTATUS")
+			for _, s := range statuses {
+				container := fmt.Sprintf("%d", s.ContainerPort)
+				host := fmt.Sprintf("%d", s.HostPort)
+				if s.Count > 1 {
+					container = fmt.Sprintf("%d-%d", s.ContainerPort, s.ContainerPort+s.Count-1)
+					host = fmt.Sprintf("%d-%d", s.HostPort, s.HostPort+s.Count-1)
+				}
+				status := "free"
+				if s.InUse {
+					status = "in use"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Service, container, host, s.Protocol, status)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	cmd.AddCommand(newPortsCheckCmd())
+
+	return cmd
+}
+
+func newPortsCheckCmd() *cobra.Command {
+	var fix bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Detect port conflicts between environments",
+		Long:  "Load every registered environment's allocations and report overlapping host ports or slots.\nWith --fix, the newer environment of each conflict is moved to a free slot.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			conflicts, err := mono.FindPortConflicts()
+			if err != nil {
+				return err
+			}
+
+			if len(conflicts) == 0 {
+				fmt.Println("No port conflicts found.")
+				return nil
+			}
+
+			for _, c := range conflicts {
+				fmt.Println(c.String())
+			}
+
+			if !fix {
+				return fmt.Errorf("found %d port conflict(s), run with --fix to reassign", len(conflicts))
+			}
+
+			reassignments, err := mono.FixPortConflicts(conflicts)
+			for _, r := range reassignments {
+				if r.Ephemeral {
+					mono.Printf("Reassigned %s: new ephemeral ports\n", r.EnvName)
+				} else {
+					mono.Printf("Reassigned %s: slot %d -> %d\n", r.EnvName, r.OldSlot, r.NewSlot)
+				}
+				for _, a := range r.Allocations {
+					mono.Printf("  %s\n", a.String())
+				}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&fix, "fix", false, "reassign the newer environment of each conflict to a free slot")
+
+	return cmd
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewProxyCmd() *cobra.Command {
+	var opts mono.ProxyOptions
+
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Run a reverse proxy to environment ports",
+		Long:  "Forward stable hostnames like web.<env>.localhost to the host ports allocated for that environment.\nUse --env with --bind to also expose an environment's services on fixed local ports.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return mono.RunProxy(ctx, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Listen, "listen", "127.0.0.1:18080", "address for hostname-based HTTP routing (empty to disable)")
+	cmd.Flags().StringVar(&opts.TLSListen, "tls-listen", "", "address for HTTPS routing with certificates from the local CA, e.g. 127.0.0.1:18443")
+	cmd.Flags().StringVar(&opts.Domain, "domain", "localhost", "domain suffix used in <service>.<env>.<domain>")
+	cmd.Flags().StringVar(&opts.Env, "env", "", "environment used for --bind forwards")
+	cmd.Flags().StringToIntVar(&opts.Binds, "bind", nil, "forward a fixed local port to a service, e.g. web=3000")
+
+	return cmd
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewQueueCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Show builds holding or waiting for a build slot",
+		Long:  "List the wrapped commands and tasks that hold a build_queue slot, followed by the ones\nwaiting for one, across all environments on this machine.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := mono.BuildQueueStatus()
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(entries)
+			}
+			if len(entries) == 0 {
+				fmt.Println("No builds running or queued")
+				return nil
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "STATE\tSLOT\tENV\tCOMMAND\tPID\tFOR\n")
+			for _, e := range entries {
+				state, slot := "running", fmt.Sprint(e.Slot)
+				if e.Waiting {
+					state, slot = "waiting", "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", state, slot, e.Env, e.Command, e.PID, time.Since(e.Since).Round(time.Second))
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
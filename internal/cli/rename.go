@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewRenameCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rename <name|path> <new-name>",
+		Short: "Rename an environment",
+		Long:  "Rename an environment in the registry, its tmux session and data directory.\nPort allocations, containers and cache associations are kept.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolvePath(args[:1])
+			if err != nil {
+				return err
+			}
+			return mono.Rename(path, args[1])
+		},
+	}
+
+	return cmd
+}
@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/gwuah/mono/internal/mono"
 	"github.com/spf13/cobra"
 )
 
@@ -12,6 +15,11 @@ func resolvePath(args []string) (string, error) {
 	var path string
 	if len(args) > 0 && args[0] != "" {
 		path = args[0]
+		if envPath, ok, err := lookupEnvName(path); err != nil {
+			return "", err
+		} else if ok {
+			return envPath, nil
+		}
 	} else if envPath := os.Getenv("CONDUCTOR_WORKSPACE_PATH"); envPath != "" {
 		path = envPath
 	} else {
@@ -26,20 +34,115 @@ func resolvePath(args []string) (string, error) {
 	return absPath, nil
 }
 
+func lookupEnvName(arg string) (string, bool, error) {
+	if strings.ContainsRune(arg, os.PathSeparator) || arg == "." || arg == ".." {
+		return "", false, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return "", false, nil
+	}
+
+	envPath, err := mono.LookupEnvironmentPath(arg)
+	if errors.Is(err, mono.ErrEnvironmentNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return envPath, true, nil
+}
+
+func resolveEnvPath(args []string) (string, error) {
+	if len(args) > 0 && args[0] != "" {
+		return resolvePath(args)
+	}
+	if os.Getenv("CONDUCTOR_WORKSPACE_PATH") != "" {
+		return resolvePath(nil)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return cwd, nil
+}
+
 func NewRootCmd() *cobra.Command {
+	var sets []string
+	var verbose int
+	var quiet bool
+	var why string
+	var progressSocket string
+
 	cmd := &cobra.Command{
 		Use:   "mono",
 		Short: "Runtime backend for Conductor workspaces",
 		Long:  "mono manages execution environments for Conductor workspaces - Docker containers, tmux sessions, and data directories.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := mono.SetVerbosity(verbose, quiet); err != nil {
+				return err
+			}
+			mono.SetAuditReason(why)
+			if err := mono.OpenProgressSocket(progressSocket); err != nil {
+				return err
+			}
+			return mono.SetConfigOverrides(sets)
+		},
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+			return mono.CloseProgressSocket()
+		},
 	}
 
+	cmd.PersistentFlags().StringArrayVar(&sets, "set", nil, "override a config key, e.g. --set cache.max_size=50GB (repeatable)")
+	cmd.PersistentFlags().CountVarP(&verbose, "verbose", "v", "echo the log to stderr, -vv adds per-file detail")
+	cmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only results and errors")
+	cmd.PersistentFlags().StringVar(&progressSocket, "progress-socket", "", "unix socket to send progress events to as JSON lines (default $MONO_PROGRESS_SOCKET)")
+	cmd.PersistentFlags().StringVar(&why, "why", "", "reason recorded in the audit log for anything this command deletes")
+
 	cmd.AddCommand(NewInitCmd())
+	cmd.AddCommand(NewCreateCmd())
+	cmd.AddCommand(NewCloneCmd())
 	cmd.AddCommand(NewDestroyCmd())
+	cmd.AddCommand(NewRenameCmd())
+	cmd.AddCommand(NewArchiveCmd())
+	cmd.AddCommand(NewUnarchiveCmd())
 	cmd.AddCommand(NewRunCmd())
+	cmd.AddCommand(NewTaskCmd())
+	cmd.AddCommand(NewTestCmd())
+	cmd.AddCommand(NewArtifactsCmd())
+	cmd.AddCommand(NewQueueCmd())
+	cmd.AddCommand(NewUpCmd())
+	cmd.AddCommand(NewDownCmd())
+	cmd.AddCommand(NewRestartCmd())
+	cmd.AddCommand(NewLogsCmd())
+	cmd.AddCommand(NewWatchCmd())
+	cmd.AddCommand(NewAffectedCmd())
+	cmd.AddCommand(NewDBCmd())
+	cmd.AddCommand(NewImagesCmd())
 	cmd.AddCommand(NewListCmd())
+	cmd.AddCommand(NewStatusCmd())
+	cmd.AddCommand(NewUICmd())
+	cmd.AddCommand(NewTimingsCmd())
+	cmd.AddCommand(NewDoctorCmd())
+	cmd.AddCommand(NewDebugCmd())
+	cmd.AddCommand(NewEnvCmd())
+	cmd.AddCommand(NewConfigCmd())
+	cmd.AddCommand(NewDirenvCmd())
+	cmd.AddCommand(NewLabelCmd())
 	cmd.AddCommand(NewSyncCmd())
 	cmd.AddCommand(NewCacheCmd())
+	cmd.AddCommand(NewEventsCmd())
+	cmd.AddCommand(NewAuditCmd())
 	cmd.AddCommand(NewAttachCmd())
+	cmd.AddCommand(NewShellCmd())
+	cmd.AddCommand(NewPortsCmd())
+	cmd.AddCommand(NewProxyCmd())
+	cmd.AddCommand(NewHostsCmd())
+	cmd.AddCommand(NewTLSCmd())
+	cmd.AddCommand(NewDaemonCmd())
+	cmd.AddCommand(NewStatusdCmd())
+	cmd.AddCommand(NewJobsCmd())
+	cmd.AddCommand(NewConductorCmd())
+	cmd.AddCommand(NewPluginsCmd())
 
 	return cmd
 }
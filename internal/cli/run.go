@@ -1,17 +1,40 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/gwuah/mono/internal/mono"
 	"github.com/spf13/cobra"
 )
 
 func NewRunCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "run [path]",
-		Short: "Execute run script in tmux",
-		Long:  "Send the run script from mono.yml to the tmux session.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH.",
-		Args:  cobra.MaximumNArgs(1),
+		Use:   "run [name|path] [-- command...]",
+		Short: "Execute run script in tmux, or wrap a command with the build cache",
+		Long:  "Send the run script from mono.yml to the tmux session.\nAccepts an environment name or path. If neither is provided, uses CONDUCTOR_WORKSPACE_PATH.\n\nWith a command after --, run it directly instead, e.g. mono run -- cargo build. Artifacts are restored\nfrom the cache first, the command gets the environment's ports and cache variables, and artifacts are\nsynced back to the cache when it succeeds. Without a name or path, uses the current directory.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				if dash > 1 || len(args) == dash {
+					return fmt.Errorf("expected at most one name or path before -- and a command after it")
+				}
+				path, err := resolveEnvPath(args[:dash])
+				if err != nil {
+					return err
+				}
+				code, err := mono.RunWrapped(path, args[dash:], os.Stderr)
+				if err != nil {
+					return err
+				}
+				if code != 0 {
+					os.Exit(code)
+				}
+				return nil
+			}
+			if len(args) > 1 {
+				return fmt.Errorf("accepts at most 1 arg(s), received %d", len(args))
+			}
+
 			absPath, err := resolvePath(args)
 			if err != nil {
 				return err
@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewShellCmd() *cobra.Command {
+	var opts mono.ShellOptions
+
+	cmd := &cobra.Command{
+		Use:   "shell [name|path]",
+		Short: "Start a shell inside an environment",
+		Long:  "Replace mono with $SHELL running in the environment's directory, with its ports, database URLs,\ncache variables, env from mono.yml and direnv.path entries applied. No tmux required.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+			return mono.Shell(path, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Command, "command", "c", "", "run this command in the environment instead of an interactive shell")
+
+	return cmd
+}
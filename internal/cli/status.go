@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewStatusCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "status [name|path]",
+		Short: "Show whether an environment is fully wired up",
+		Long:  "Report the project root, env name, tmux session, container health, allocated ports,\nservice startup order and per-artifact cache state for an environment.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			report, err := mono.Status(path)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+
+			printStatusReport(report)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
+
+func printStatusReport(r *mono.StatusReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", r.Name)
+	fmt.Fprintf(w, "Path:\t%s\n", r.Path)
+	fmt.Fprintf(w, "Root:\t%s\n", orDash(r.RootPath))
+	fmt.Fprintf(w, "Branch:\t%s\n", orDash(r.Branch))
+	if r.Template != "" {
+		fmt.Fprintf(w, "Template:\t%s\n", r.Template)
+	}
+	if r.Profile != "" {
+		fmt.Fprintf(w, "Profile:\t%s\n", r.Profile)
+	}
+	tmux := "not running"
+	if r.TmuxRunning {
+		tmux = "running"
+	}
+	fmt.Fprintf(w, "Tmux:\t%s (%s)\n", r.Session, tmux)
+	w.Flush()
+
+	fmt.Println()
+	switch {
+	case r.DockerProject == "":
+		fmt.Println("Containers: none (no docker compose project)")
+	case r.ContainersError != "":
+		fmt.Printf("Containers: unavailable (%s)\n", r.ContainersError)
+	case len(r.Containers) == 0:
+		fmt.Printf("Containers: none running for %s\n", r.DockerProject)
+	default:
+		fmt.Printf("Containers (%s):\n", r.DockerProject)
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, c := range r.Containers {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", c.Service, c.State, orDash(c.Health))
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	if len(r.Ports) == 0 {
+		fmt.Println("Ports: none allocated")
+	} else {
+		fmt.Println("Ports:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, p := range r.Ports {
+			state := "free"
+			if p.InUse {
+				state = "in use"
+			}
+			fmt.Fprintf(w, "  %s\t%d/%s\t-> %d\t%s\n", p.Service, p.ContainerPort, p.Protocol, p.HostPort, state)
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	switch {
+	case r.PlanError != "":
+		fmt.Printf("Startup order: unavailable (%s)\n", r.PlanError)
+	case len(r.StartupPlan) == 0:
+		fmt.Println("Startup order: no services")
+	default:
+		fmt.Println("Startup order:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, step := range r.StartupPlan {
+			after := "-"
+			if len(step.DependsOn) > 0 {
+				after = "after " + strings.Join(step.DependsOn, ", ")
+			}
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%s\n", step.Stage, step.Service, step.Kind, after)
+		}
+		w.Flush()
+	}
+
+	fmt.Println()
+	switch {
+	case r.ArtifactsError != "":
+		fmt.Printf("Cache: unavailable (%s)\n", r.ArtifactsError)
+	case len(r.Artifacts) == 0:
+		fmt.Println("Cache: no artifacts configured")
+	default:
+		fmt.Println("Cache:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, a := range r.Artifacts {
+			hit := "miss"
+			if a.Hit {
+				hit = "hit"
+			}
+			lastSync := "never synced"
+			if a.LastSync != nil {
+				lastSync = "synced " + a.LastSync.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", a.Name, a.Key, hit, lastSync)
+		}
+		w.Flush()
+		for _, a := range r.Artifacts {
+			for _, warning := range a.Warnings {
+				fmt.Printf("  warning: %s: %s\n", a.Name, warning)
+			}
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
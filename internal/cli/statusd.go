@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewStatusdCmd() *cobra.Command {
+	var opts mono.StatusdOptions
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:   "statusd",
+		Short: "Publish a compact status for menubar tools",
+		Long:  "Periodically write a compact status (current environment, running builds and tasks, cache syncs in progress)\nto a file (default ~/.mono/status.txt) and optionally serve it on a unix socket.\n\nThe text format follows the SwiftBar/xbar plugin convention: the first line is the menubar title,\nfollowed by --- and one line per item. A plugin can simply cat the file, or call mono statusd --once.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if once {
+				status, err := mono.CollectDesktopStatus()
+				if err != nil {
+					return err
+				}
+				data, err := status.Render(opts.JSON)
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+
+			if !cmd.Flags().Changed("output") {
+				output, err := mono.DefaultStatusdOutput()
+				if err != nil {
+					return err
+				}
+				opts.Output = output
+			}
+			if opts.Output == "" && opts.Socket == "" {
+				return fmt.Errorf("nothing to publish to: set --output or --socket")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return mono.RunStatusd(ctx, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "file to write the status to (default ~/.mono/status.txt, empty to disable)")
+	cmd.Flags().StringVar(&opts.Socket, "socket", "", "unix socket that serves the current status to each connection")
+	cmd.Flags().DurationVar(&opts.Interval, "interval", mono.DefaultStatusdPeriod, "how often to refresh the status")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "publish the status as JSON")
+	cmd.Flags().BoolVar(&once, "once", false, "print the status to stdout and exit")
+
+	return cmd
+}
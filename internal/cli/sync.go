@@ -22,48 +22,60 @@ func NewSyncCmd() *cobra.Command {
 				return fmt.Errorf("invalid path: %w", err)
 			}
 
-			db, err := mono.OpenDB()
-			if err != nil {
-				return fmt.Errorf("failed to open database: %w", err)
+			if err := syncEnvironment(absPath, true); err != nil {
+				return err
 			}
-			defer db.Close()
 
-			env, err := db.GetEnvironmentByPath(absPath)
-			if err != nil {
-				return fmt.Errorf("environment not found: %w", err)
-			}
+			mono.Println("Sync complete")
+			return nil
+		},
+	}
 
-			cfg, err := mono.LoadConfig(absPath)
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
-			cfg.ApplyDefaults(absPath)
+	return cmd
+}
 
-			cm, err := mono.NewCacheManager()
-			if err != nil {
-				return fmt.Errorf("failed to create cache manager: %w", err)
-			}
+func syncEnvironment(absPath string, summary bool) (err error) {
+	db, err := mono.OpenDB()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
 
-			rootPath := ""
-			if env.RootPath.Valid {
-				rootPath = env.RootPath.String
-			}
+	env, err := db.GetEnvironmentByPath(absPath)
+	if err != nil {
+		return fmt.Errorf("environment not found: %w", err)
+	}
 
-			if rootPath == "" {
-				return fmt.Errorf("environment has no root path set")
-			}
+	cfg, err := mono.LoadConfig(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.ApplyDefaults(absPath)
 
-			err = cm.Sync(cfg.Build.Artifacts, rootPath, absPath, mono.SyncOptions{
-				HardlinkBack: true,
-			})
-			if err != nil {
-				return err
-			}
+	cm, err := mono.NewCacheManager()
+	if err != nil {
+		return fmt.Errorf("failed to create cache manager: %w", err)
+	}
 
-			fmt.Println("Sync complete")
-			return nil
-		},
+	rootPath := ""
+	if env.RootPath.Valid {
+		rootPath = env.RootPath.String
 	}
 
-	return cmd
+	if rootPath == "" {
+		return fmt.Errorf("environment has no root path set")
+	}
+
+	timer := mono.NewPhaseTimer(mono.TimingSync, env.EnvName(), rootPath)
+	defer func() {
+		timer.Finish(err)
+		if summary {
+			timer.PrintSummary(cfg.Timings)
+		}
+	}()
+
+	return cm.Sync(cfg.Build.Artifacts, rootPath, absPath, mono.SyncOptions{
+		HardlinkBack: true,
+		Timer:        timer,
+	})
 }
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewTaskCmd() *cobra.Command {
+	var env string
+	var opts mono.TaskOptions
+
+	cmd := &cobra.Command{
+		Use:   "task <task>...",
+		Short: "Run tasks from mono.yml, skipping ones whose inputs haven't changed",
+		Long:  "Run the given tasks in order. A task with inputs is skipped when their hash matches its last successful run,\nand its outputs are restored from the mono cache when another environment already ran it with the same inputs.\nA task with a matrix runs once per combination; name@key=value runs only the matching ones.\nUses the environment from --env, CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var envArgs []string
+			if env != "" {
+				envArgs = []string{env}
+			}
+			path, err := resolveEnvPath(envArgs)
+			if err != nil {
+				return err
+			}
+			results, err := mono.RunTasks(path, args, opts, os.Stderr)
+			printTaskMatrix(results)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "run tasks even if their inputs haven't changed")
+
+	return cmd
+}
+
+func printTaskMatrix(results []mono.TaskResult) {
+	var tasks []string
+	byTask := make(map[string][]mono.TaskResult)
+	for _, r := range results {
+		if len(r.Matrix) == 0 {
+			continue
+		}
+		if _, ok := byTask[r.Task]; !ok {
+			tasks = append(tasks, r.Task)
+		}
+		byTask[r.Task] = append(byTask[r.Task], r)
+	}
+
+	for _, task := range tasks {
+		rows := byTask[task]
+		passed := 0
+		for _, r := range rows {
+			if r.Err == nil {
+				passed++
+			}
+		}
+		fmt.Printf("\n%s: %d of %d combination(s) passed\n", task, passed, len(rows))
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		var header []string
+		for _, c := range rows[0].Matrix {
+			header = append(header, strings.ToUpper(c.Key))
+		}
+		fmt.Fprintf(w, "%s\tRESULT\tTIME\n", strings.Join(header, "\t"))
+		for _, r := range rows {
+			var values []string
+			for _, c := range r.Matrix {
+				values = append(values, c.Value)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", strings.Join(values, "\t"), r.Outcome, roundDuration(r.Duration))
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewTestCmd() *cobra.Command {
+	var env, shard string
+	var opts mono.TestOptions
+
+	cmd := &cobra.Command{
+		Use:   "test [suite...]",
+		Short: "Run the test suites from mono.yml, skipping packages whose inputs haven't changed",
+		Long:  "Run every test suite from mono.yml, or only the given ones, once per package.\nA package that passed before with the same inputs is reported as cached instead of run again; --no-cache runs everything.\nUse --shard to split the packages across CI machines.\nUses the environment from --env, CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var envArgs []string
+			if env != "" {
+				envArgs = []string{env}
+			}
+			path, err := resolveEnvPath(envArgs)
+			if err != nil {
+				return err
+			}
+			opts.Shard, err = mono.ParseTestShard(shard)
+			if err != nil {
+				return err
+			}
+
+			results, err := mono.RunTests(path, args, opts, os.Stdout)
+			var passed, cached, failed int
+			for _, r := range results {
+				switch {
+				case r.Cached:
+					cached++
+				case r.Passed:
+					passed++
+				default:
+					failed++
+				}
+			}
+			if results != nil {
+				fmt.Printf("%d passed, %d cached, %d failed\n", passed, cached, failed)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&env, "env", "", "environment name or path (default CONDUCTOR_WORKSPACE_PATH or the current directory)")
+	cmd.Flags().BoolVar(&opts.NoCache, "no-cache", false, "run every package even if it passed before with the same inputs")
+	cmd.Flags().StringVar(&shard, "shard", "", "only run this share of the packages, e.g. 2/4")
+	cmd.Flags().IntVarP(&opts.Parallel, "parallel", "p", 0, "packages to test at once (default test.parallel)")
+
+	return cmd
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewTimingsCmd() *cobra.Command {
+	var task string
+	var runs int
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "timings [name|path]",
+		Short: "Show where the time of the last init or task run went",
+		Long:  "Print the phase breakdown of the environment's last mono init (or of a task with --task),\nnext to the average, fastest and slowest of recent runs across the project's environments.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if runs < 1 {
+				return fmt.Errorf("--runs must be at least 1")
+			}
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			operation := mono.TimingInit
+			if task != "" {
+				operation = mono.TaskTimingOperation(task)
+			}
+			report, err := mono.Timings(path, operation, runs)
+			if err != nil {
+				return err
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(report)
+			}
+			if report.Last == nil {
+				fmt.Printf("No recorded %s runs for %s\n", operation, report.Env)
+				return nil
+			}
+			printTimingReport(report)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&task, "task", "", "show the runs of this task instead of mono init")
+	cmd.Flags().IntVar(&runs, "runs", mono.DefaultTimingRuns, "number of recent runs to compare against")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "output as JSON")
+
+	return cmd
+}
+
+func printTimingReport(r *mono.TimingReport) {
+	last := r.Last
+	status := "succeeded"
+	if !last.Succeeded {
+		status = "failed"
+	}
+	fmt.Printf("Last %s of %s: %s, %s (%s)\n\n", r.Operation, r.Env, roundDuration(last.Duration), status, last.Started.Local().Format("2006-01-02 15:04"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "PHASE\tLAST\tSHARE\tAVG\tMIN\tMAX\n")
+	for _, t := range r.Trends {
+		share := "-"
+		if last.Duration > 0 {
+			share = fmt.Sprintf("%d%%", t.Last*100/last.Duration)
+		}
+		if t.Phase == "total" {
+			if untracked := last.Untracked(); untracked >= time.Second {
+				fmt.Fprintf(w, "other\t%s\t%d%%\t-\t-\t-\n", roundDuration(untracked), untracked*100/last.Duration)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Phase, roundDuration(t.Last), share, roundDuration(t.Average), roundDuration(t.Min), roundDuration(t.Max))
+	}
+	w.Flush()
+
+	fmt.Printf("\nRecent runs (%d):\n", len(r.History))
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STARTED\tENV\tDURATION\tSTATUS\n")
+	for _, run := range r.History {
+		status := "ok"
+		if !run.Succeeded {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", run.Started.Local().Format("2006-01-02 15:04"), run.Env, roundDuration(run.Duration), status)
+	}
+	w.Flush()
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Minute {
+		return d.Round(time.Second)
+	}
+	return d.Round(100 * time.Millisecond)
+}
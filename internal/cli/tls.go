@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewTLSCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tls",
+		Short: "Manage the local CA and per-environment certificates",
+		Long:  "mono keeps a local certificate authority in ~/.mono/ca and issues each environment a certificate\nfor its hostnames, so HTTPS frontends work on every environment once the CA is trusted.",
+	}
+
+	cmd.AddCommand(newTLSCACmd())
+	cmd.AddCommand(newTLSTrustCmd())
+	cmd.AddCommand(newTLSIssueCmd())
+
+	return cmd
+}
+
+func newTLSCACmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ca",
+		Short: "Print the path of the local CA certificate, creating it if needed",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca, err := mono.EnsureCA()
+			if err != nil {
+				return err
+			}
+			fmt.Println(ca.Path)
+			return nil
+		},
+	}
+}
+
+func newTLSTrustCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "trust",
+		Short: "Add the local CA to the system trust store (asks for sudo)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ca, err := mono.EnsureCA()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				command, err := mono.TrustCACommand(ca)
+				if err != nil {
+					return err
+				}
+				fmt.Println(strings.Join(command, " "))
+				return nil
+			}
+			if err := mono.TrustCA(ca); err != nil {
+				return err
+			}
+			mono.Printf("Trusted %s\n", ca.Path)
+			mono.Println("Browsers with their own trust store (e.g. Firefox) need it imported separately.")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the command instead of running it")
+
+	return cmd
+}
+
+func newTLSIssueCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "issue [name|path]",
+		Short: "Issue or renew an environment's certificate",
+		Long:  "Issue a certificate for the environment's hostnames (<env>.<domain> and *.<env>.<domain>) signed by the local CA.\nAn existing certificate is kept unless it is about to expire, doesn't cover the hostnames, or --force is given.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			env, dir, issued, err := mono.IssueEnvTLS(path, force)
+			if err != nil {
+				return err
+			}
+			if issued {
+				mono.Printf("Issued certificate for %s in %s\n", env.EnvName(), dir)
+			} else {
+				mono.Printf("Certificate for %s in %s is current\n", env.EnvName(), dir)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "issue a new certificate even if the current one is valid")
+
+	return cmd
+}
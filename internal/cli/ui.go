@@ -0,0 +1,520 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+const (
+	uiRefreshInterval = 3 * time.Second
+	uiMaxJobs         = 5
+)
+
+var (
+	uiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	uiHeadingStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	uiSelectedStyle = lipgloss.NewStyle().Reverse(true)
+	uiDimStyle      = lipgloss.NewStyle().Faint(true)
+	uiErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+)
+
+func NewUICmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ui",
+		Short: "Open a dashboard of environments, caches and jobs",
+		Long:  "Show every environment with its tmux session, containers and ports, the cache sizes and hit rates per\nproject and artifact, and the daemon's jobs, refreshed every few seconds. From the dashboard you can\nattach to an environment, restart its containers or a single service, and sync its artifacts to the cache.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the mono executable: %w", err)
+			}
+			_, err = tea.NewProgram(newUIModel(exe), tea.WithAltScreen()).Run()
+			return err
+		},
+	}
+}
+
+type uiPane int
+
+const (
+	uiPaneEnvironments uiPane = iota
+	uiPaneServices
+)
+
+type cacheSummary struct {
+	project  string
+	artifact string
+	entries  int
+	size     int64
+	hits     int
+	misses   int
+}
+
+func (c cacheSummary) hitRate() string {
+	total := c.hits + c.misses
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", 100*float64(c.hits)/float64(total))
+}
+
+type uiEnvsMsg struct {
+	envs []mono.EnvironmentStatus
+	err  error
+}
+
+type uiDetailMsg struct {
+	path   string
+	report *mono.StatusReport
+	err    error
+}
+
+type uiCachesMsg struct {
+	caches []cacheSummary
+	err    error
+}
+
+type uiJobsMsg struct {
+	jobs []mono.Job
+	err  error
+}
+
+type uiActionMsg struct {
+	done string
+	err  error
+}
+
+type uiTickMsg time.Time
+
+type uiModel struct {
+	exe       string
+	envs      []mono.EnvironmentStatus
+	envsErr   error
+	cursor    int
+	pane      uiPane
+	detail    *mono.StatusReport
+	detailErr error
+	service   int
+	caches    []cacheSummary
+	cachesErr error
+	jobs      []mono.Job
+	jobsErr   error
+	status    string
+	statusErr bool
+}
+
+func newUIModel(exe string) uiModel {
+	return uiModel{exe: exe, status: "loading..."}
+}
+
+func (m uiModel) Init() tea.Cmd {
+	return tea.Batch(loadUIEnvs, loadUICaches, loadUIJobs, uiTick())
+}
+
+func uiTick() tea.Cmd {
+	return tea.Tick(uiRefreshInterval, func(t time.Time) tea.Msg { return uiTickMsg(t) })
+}
+
+func loadUIEnvs() tea.Msg {
+	envs, err := mono.List()
+	return uiEnvsMsg{envs: envs, err: err}
+}
+
+func loadUIDetail(path string) tea.Cmd {
+	return func() tea.Msg {
+		report, err := mono.Status(path)
+		return uiDetailMsg{path: path, report: report, err: err}
+	}
+}
+
+func loadUIJobs() tea.Msg {
+	client, err := mono.NewDaemonClient("")
+	if err != nil {
+		return uiJobsMsg{err: err}
+	}
+	jobs, err := client.Jobs()
+	return uiJobsMsg{jobs: jobs, err: err}
+}
+
+func loadUICaches() tea.Msg {
+	caches, err := cacheSummaries()
+	return uiCachesMsg{caches: caches, err: err}
+}
+
+func cacheSummaries() ([]cacheSummary, error) {
+	cm, err := mono.NewCacheManager()
+	if err != nil {
+		return nil, err
+	}
+	db, err := mono.OpenDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sizes, err := cm.GetCacheSizes()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return nil, err
+	}
+	rootPaths, err := db.GetAllRootPaths()
+	if err != nil {
+		return nil, err
+	}
+	projectNames := buildProjectNameMap(rootPaths)
+
+	byArtifact := make(map[string]*cacheSummary)
+	summary := func(projectID, artifact string) *cacheSummary {
+		key := projectID + "/" + artifact
+		if s, ok := byArtifact[key]; ok {
+			return s
+		}
+		project := projectID
+		if name, ok := projectNames[projectID]; ok {
+			project = name
+		}
+		s := &cacheSummary{project: project, artifact: artifact}
+		byArtifact[key] = s
+		return s
+	}
+	for _, e := range sizes {
+		s := summary(e.ProjectID, e.Artifact)
+		s.entries++
+		s.size += e.Size
+	}
+	for _, e := range stats {
+		s := summary(e.ProjectID, e.Artifact)
+		s.hits += e.Hits
+		s.misses += e.Misses
+	}
+
+	summaries := make([]cacheSummary, 0, len(byArtifact))
+	for _, s := range byArtifact {
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].project != summaries[j].project {
+			return summaries[i].project < summaries[j].project
+		}
+		return summaries[i].artifact < summaries[j].artifact
+	})
+	return summaries, nil
+}
+
+func (m uiModel) selected() (mono.EnvironmentStatus, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.envs) {
+		return mono.EnvironmentStatus{}, false
+	}
+	return m.envs[m.cursor], true
+}
+
+func (m uiModel) selectedService() (string, bool) {
+	if m.pane != uiPaneServices || m.detail == nil || m.service >= len(m.detail.Containers) {
+		return "", false
+	}
+	return m.detail.Containers[m.service].Service, true
+}
+
+func (m uiModel) reloadDetail() (uiModel, tea.Cmd) {
+	env, ok := m.selected()
+	if !ok {
+		m.detail, m.detailErr = nil, nil
+		return m, nil
+	}
+	if m.detail != nil && m.detail.Path != env.Path {
+		m.detail, m.detailErr, m.service = nil, nil, 0
+	}
+	return m, loadUIDetail(env.Path)
+}
+
+func (m uiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case uiTickMsg:
+		return m, tea.Batch(loadUIEnvs, loadUIJobs, uiTick())
+	case uiEnvsMsg:
+		m.envs, m.envsErr = msg.envs, msg.err
+		if m.status == "loading..." {
+			m.status = ""
+		}
+		m.cursor = min(m.cursor, max(len(m.envs)-1, 0))
+		return m.reloadDetail()
+	case uiDetailMsg:
+		if env, ok := m.selected(); ok && env.Path == msg.path {
+			m.detail, m.detailErr = msg.report, msg.err
+			if m.detail != nil {
+				m.service = min(m.service, max(len(m.detail.Containers)-1, 0))
+			}
+		}
+		return m, nil
+	case uiCachesMsg:
+		m.caches, m.cachesErr = msg.caches, msg.err
+		return m, nil
+	case uiJobsMsg:
+		m.jobs, m.jobsErr = msg.jobs, msg.err
+		return m, nil
+	case uiActionMsg:
+		m.status, m.statusErr = msg.done, false
+		if msg.err != nil {
+			m.status, m.statusErr = msg.err.Error(), true
+		}
+		return m, tea.Batch(loadUIEnvs, loadUICaches, loadUIJobs)
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m uiModel) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "tab":
+		if m.pane == uiPaneEnvironments && m.detail != nil && len(m.detail.Containers) > 0 {
+			m.pane = uiPaneServices
+		} else {
+			m.pane = uiPaneEnvironments
+		}
+		return m, nil
+	case "up", "k":
+		if m.pane == uiPaneServices {
+			m.service = max(m.service-1, 0)
+			return m, nil
+		}
+		if m.cursor > 0 {
+			m.cursor--
+			return m.reloadDetail()
+		}
+		return m, nil
+	case "down", "j":
+		if m.pane == uiPaneServices {
+			if m.detail != nil && m.service < len(m.detail.Containers)-1 {
+				m.service++
+			}
+			return m, nil
+		}
+		if m.cursor < len(m.envs)-1 {
+			m.cursor++
+			return m.reloadDetail()
+		}
+		return m, nil
+	case "R":
+		m.status, m.statusErr = "refreshing...", false
+		return m, tea.Batch(loadUIEnvs, loadUICaches, loadUIJobs)
+	}
+
+	env, ok := m.selected()
+	if !ok {
+		return m, nil
+	}
+	switch msg.String() {
+	case "enter", "a":
+		attach := exec.Command(m.exe, "attach", env.Path)
+		return m, tea.ExecProcess(attach, func(err error) tea.Msg {
+			return uiActionMsg{done: "detached from " + env.Name, err: err}
+		})
+	case "r":
+		var services []string
+		target := "all containers of " + env.Name
+		if service, ok := m.selectedService(); ok {
+			services, target = []string{service}, service+" in "+env.Name
+		}
+		m.status, m.statusErr = "restarting "+target+"...", false
+		return m, func() tea.Msg {
+			var output bytes.Buffer
+			if _, err := mono.ManageContainers(env.Path, mono.ContainersRestart, services, &output); err != nil {
+				return uiActionMsg{err: fmt.Errorf("failed to restart %s: %w", target, err)}
+			}
+			return uiActionMsg{done: "restarted " + target}
+		}
+	case "s":
+		m.status, m.statusErr = "syncing "+env.Name+" to the cache...", false
+		return m, func() tea.Msg {
+			if err := syncEnvironment(env.Path, false); err != nil {
+				return uiActionMsg{err: fmt.Errorf("failed to sync %s: %w", env.Name, err)}
+			}
+			return uiActionMsg{done: "synced " + env.Name + " to the cache"}
+		}
+	}
+	return m, nil
+}
+
+func formatUITable(header []string, rows [][]string) []string {
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = len(h)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	format := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			padded[i] = fmt.Sprintf("%-*s", widths[i], cell)
+		}
+		return "  " + strings.TrimRight(strings.Join(padded, "  "), " ")
+	}
+	lines := []string{uiDimStyle.Render(format(header))}
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	return lines
+}
+
+func runningMark(running bool) string {
+	if running {
+		return "running"
+	}
+	return "-"
+}
+
+func (m uiModel) View() string {
+	var b strings.Builder
+	b.WriteString(uiTitleStyle.Render(fmt.Sprintf("mono · %d environment(s)", len(m.envs))) + "\n\n")
+
+	b.WriteString(uiHeadingStyle.Render("ENVIRONMENTS") + "\n")
+	switch {
+	case m.envsErr != nil:
+		b.WriteString(uiErrorStyle.Render("  "+m.envsErr.Error()) + "\n")
+	case len(m.envs) == 0:
+		b.WriteString(uiDimStyle.Render("  no environments, create one with mono init or mono create") + "\n")
+	default:
+		var rows [][]string
+		for _, e := range m.envs {
+			lastUsed := "never"
+			if e.LastUsed != nil {
+				lastUsed = formatTimeAgo(*e.LastUsed)
+			}
+			rows = append(rows, []string{e.Name, orDash(e.Branch), runningMark(e.TmuxRunning), runningMark(e.DockerRunning), e.Cache, lastUsed})
+		}
+		lines := formatUITable([]string{"NAME", "BRANCH", "TMUX", "CONTAINERS", "CACHE", "LAST USED"}, rows)
+		for i, line := range lines {
+			if i-1 == m.cursor && m.pane == uiPaneEnvironments {
+				line = uiSelectedStyle.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if env, ok := m.selected(); ok {
+		b.WriteString(m.detailView(env))
+	}
+
+	b.WriteString(uiHeadingStyle.Render("CACHE") + "\n")
+	switch {
+	case m.cachesErr != nil:
+		b.WriteString(uiErrorStyle.Render("  "+m.cachesErr.Error()) + "\n")
+	case len(m.caches) == 0:
+		b.WriteString(uiDimStyle.Render("  empty") + "\n")
+	default:
+		var rows [][]string
+		for _, c := range m.caches {
+			rows = append(rows, []string{c.project, c.artifact, fmt.Sprint(c.entries), formatSize(c.size), fmt.Sprint(c.hits), fmt.Sprint(c.misses), c.hitRate()})
+		}
+		for _, line := range formatUITable([]string{"PROJECT", "ARTIFACT", "ENTRIES", "SIZE", "HITS", "MISSES", "HIT RATE"}, rows) {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	b.WriteString(uiHeadingStyle.Render("JOBS") + "\n")
+	switch {
+	case m.jobsErr != nil:
+		b.WriteString(uiDimStyle.Render("  "+m.jobsErr.Error()) + "\n")
+	case len(m.jobs) == 0:
+		b.WriteString(uiDimStyle.Render("  none") + "\n")
+	default:
+		var rows [][]string
+		for _, j := range m.jobs[:min(len(m.jobs), uiMaxJobs)] {
+			progress := j.Progress
+			if len(progress) > jobProgressWidth {
+				progress = progress[:jobProgressWidth-3] + "..."
+			}
+			rows = append(rows, []string{j.ID, j.Status, roundDuration(j.Elapsed()).String(), j.Command(), progress})
+		}
+		for _, line := range formatUITable([]string{"ID", "STATUS", "ELAPSED", "COMMAND", "PROGRESS"}, rows) {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.status != "" {
+		status := m.status
+		if m.statusErr {
+			status = uiErrorStyle.Render(status)
+		}
+		b.WriteString(status + "\n")
+	}
+	b.WriteString(uiDimStyle.Render("↑/↓ move · tab services · enter attach · r restart · s sync · R refresh · q quit") + "\n")
+	return b.String()
+}
+
+func (m uiModel) detailView(env mono.EnvironmentStatus) string {
+	var b strings.Builder
+	b.WriteString(uiHeadingStyle.Render(strings.ToUpper(env.Name)) + uiDimStyle.Render("  "+env.Path) + "\n")
+	switch {
+	case m.detailErr != nil:
+		b.WriteString(uiErrorStyle.Render("  "+m.detailErr.Error()) + "\n\n")
+		return b.String()
+	case m.detail == nil:
+		b.WriteString(uiDimStyle.Render("  loading...") + "\n\n")
+		return b.String()
+	}
+
+	session := "not running"
+	if m.detail.TmuxRunning {
+		session = "running"
+	}
+	b.WriteString(fmt.Sprintf("  session %s (%s)\n", m.detail.Session, session))
+
+	switch {
+	case m.detail.ContainersError != "":
+		b.WriteString(uiErrorStyle.Render("  containers: "+m.detail.ContainersError) + "\n")
+	case len(m.detail.Containers) == 0:
+		b.WriteString(uiDimStyle.Render("  no containers running") + "\n")
+	default:
+		var rows [][]string
+		for _, c := range m.detail.Containers {
+			rows = append(rows, []string{c.Service, c.State, orDash(c.Health)})
+		}
+		lines := formatUITable([]string{"SERVICE", "STATE", "HEALTH"}, rows)
+		for i, line := range lines {
+			if i-1 == m.service && m.pane == uiPaneServices {
+				line = uiSelectedStyle.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if len(m.detail.Ports) > 0 {
+		var rows [][]string
+		for _, p := range m.detail.Ports {
+			state := "free"
+			if p.InUse {
+				state = "in use"
+			}
+			rows = append(rows, []string{p.Service, fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol), fmt.Sprint(p.HostPort), state})
+		}
+		for _, line := range formatUITable([]string{"PORT", "CONTAINER", "HOST", "STATE"}, rows) {
+			b.WriteString(line + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
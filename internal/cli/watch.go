@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gwuah/mono/internal/mono"
+	"github.com/spf13/cobra"
+)
+
+func NewWatchCmd() *cobra.Command {
+	var targets []string
+
+	cmd := &cobra.Command{
+		Use:   "watch [name|path]",
+		Short: "Restart services and windows when their files change",
+		Long:  "Poll the watch patterns of an environment's services and tmux windows, and re-run them in their tmux window once changes settle.\nUse --target to watch only some of them.\nIf no path is provided, uses CONDUCTOR_WORKSPACE_PATH or the current directory.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := resolveEnvPath(args)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return mono.Watch(ctx, path, targets, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringArrayVarP(&targets, "target", "t", nil, "only watch this service or window (repeatable, default all with watch patterns)")
+
+	return cmd
+}
@@ -0,0 +1,121 @@
+package mono
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	activityDir = "activity"
+
+	ActivitySync = "sync"
+	ActivityInit = "init"
+	ActivityTask = "task"
+)
+
+type Activity struct {
+	PID       int       `json:"pid"`
+	Operation string    `json:"operation"`
+	Detail    string    `json:"detail,omitempty"`
+	Env       string    `json:"env"`
+	Path      string    `json:"path"`
+	Started   time.Time `json:"started"`
+}
+
+type ActivityMarker struct {
+	path string
+}
+
+func activityPath() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, activityDir), nil
+}
+
+func StartActivity(operation, detail, envPath string) (*ActivityMarker, error) {
+	dir, err := activityPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create activity directory: %w", err)
+	}
+	a := Activity{
+		PID:       os.Getpid(),
+		Operation: operation,
+		Detail:    detail,
+		Env:       DeriveEnvName(envPath),
+		Path:      envPath,
+		Started:   time.Now().UTC(),
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode activity: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%d-%d.json", a.PID, a.Started.UnixNano()))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write activity marker: %w", err)
+	}
+	return &ActivityMarker{path: path}, nil
+}
+
+func (m *ActivityMarker) Done() error {
+	if m == nil || m.path == "" {
+		return nil
+	}
+	err := os.Remove(m.path)
+	m.path = ""
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove activity marker: %w", err)
+	}
+	return nil
+}
+
+func RunningActivities() ([]Activity, error) {
+	dir, err := activityPath()
+	if err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity directory: %w", err)
+	}
+
+	var activities []Activity
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read activity marker: %w", err)
+		}
+		var a Activity
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, fmt.Errorf("invalid activity marker %s: %w", path, err)
+		}
+		if !processAlive(a.PID) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to remove stale activity marker: %w", err)
+			}
+			continue
+		}
+		activities = append(activities, a)
+	}
+	sort.Slice(activities, func(i, j int) bool { return activities[i].Started.Before(activities[j].Started) })
+	return activities, nil
+}
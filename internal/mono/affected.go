@@ -0,0 +1,258 @@
+package mono
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const (
+	AffectedArtifact = "artifact"
+	AffectedImage    = "image"
+	AffectedService  = "service"
+	AffectedWindow   = "window"
+)
+
+type AffectedTarget struct {
+	Name  string
+	Kind  string
+	Files []string
+}
+
+func (t AffectedTarget) Action() string {
+	switch t.Kind {
+	case AffectedImage:
+		return "rebuild"
+	case AffectedArtifact:
+		return "new cache key"
+	default:
+		return "restart"
+	}
+}
+
+func (t AffectedTarget) FileSummary() string {
+	return summarizeFiles(t.Files)
+}
+
+type AffectedPlan struct {
+	Since   string
+	Base    string
+	Changed []string
+	Targets []AffectedTarget
+
+	ec *EnvContext
+}
+
+func (p *AffectedPlan) EnvName() string {
+	return p.ec.Env.EnvName()
+}
+
+func FindAffected(path, since string) (*AffectedPlan, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ec.Config.ValidateWatch(); err != nil {
+		return nil, fmt.Errorf("invalid mono.yml: %w", err)
+	}
+
+	base, err := GitMergeBase(ec.Env.Path, since)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := GitChangedFiles(ec.Env.Path, base)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := affectedTargets(ec.Config, ec.Project, ec.Env.Path, ec.Env.ComposeDirPath(), changed)
+	if err != nil {
+		return nil, err
+	}
+	return &AffectedPlan{Since: since, Base: base, Changed: changed, Targets: targets, ec: ec}, nil
+}
+
+func affectedTargets(cfg *Config, project *types.Project, envPath, composeDir string, changed []string) ([]AffectedTarget, error) {
+	var targets []AffectedTarget
+	add := func(name, kind string, match func(string) bool) {
+		var files []string
+		for _, f := range changed {
+			if match(f) {
+				files = append(files, f)
+			}
+		}
+		if len(files) > 0 {
+			targets = append(targets, AffectedTarget{Name: name, Kind: kind, Files: files})
+		}
+	}
+
+	if project != nil {
+		for _, name := range project.ServiceNames() {
+			svc := project.Services[name]
+			if svc.Build == nil {
+				continue
+			}
+			match, err := buildInputMatcher(svc, envPath, composeDir)
+			if err != nil {
+				return nil, err
+			}
+			add(name, AffectedImage, match)
+		}
+	}
+	for _, s := range cfg.SelectedProcessServices() {
+		patterns := cleanPatterns(s.Watch)
+		add(s.Name, AffectedService, func(f string) bool {
+			rel, ok := relativeTo(filepath.Clean(s.WorkingDir), f)
+			return ok && matchesPatterns(patterns, rel)
+		})
+	}
+	for _, w := range cfg.Tmux.Windows {
+		if w.Command == "" {
+			continue
+		}
+		patterns := cleanPatterns(w.Watch)
+		add(w.Name, AffectedWindow, func(f string) bool { return matchesPatterns(patterns, f) })
+	}
+	for _, a := range cfg.Build.Artifacts {
+		patterns := cleanPatterns(a.KeyFiles)
+		add(a.Name, AffectedArtifact, func(f string) bool { return matchesPatterns(patterns, f) })
+	}
+	return targets, nil
+}
+
+func relativeTo(dir, file string) (string, bool) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+func buildInputMatcher(svc types.ServiceConfig, envPath, composeDir string) (func(string) bool, error) {
+	context := svc.Build.Context
+	if context == "" {
+		context = "."
+	}
+	if !filepath.IsAbs(context) {
+		context = filepath.Join(composeDir, context)
+	}
+	dockerfile := ""
+	if svc.Build.DockerfileInline == "" {
+		dockerfile = svc.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(context, dockerfile)
+		}
+	}
+	ignore, err := readDockerignore(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(f string) bool {
+		path := filepath.Join(envPath, f)
+		if path == dockerfile {
+			return true
+		}
+		rel, ok := relativeTo(context, path)
+		if !ok || rel == "." {
+			return false
+		}
+		for _, part := range strings.Split(rel, "/") {
+			if slices.Contains(buildxSkippedNames, part) {
+				return false
+			}
+		}
+		return !ignore.excludes(rel)
+	}, nil
+}
+
+func (p *AffectedPlan) Apply(out io.Writer) error {
+	var images, services, windows []string
+	for _, t := range p.Targets {
+		switch t.Kind {
+		case AffectedImage:
+			images = append(images, t.Name)
+		case AffectedService:
+			services = append(services, t.Name)
+		case AffectedWindow:
+			windows = append(windows, t.Name)
+		}
+	}
+	if len(images)+len(services)+len(windows) == 0 {
+		return nil
+	}
+
+	env, cfg := p.ec.Env, p.ec.Config
+	envName := env.EnvName()
+	lock, err := AcquireEnvLock(env.Path, "affected", 0)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono affected %s --since %s", env.Path, p.Since)
+
+	if len(images) > 0 {
+		containers, err := env.Runtime()
+		if err != nil {
+			return err
+		}
+		if err := EnsureRuntimeAvailable(containers, cfg.ContainerAutostart, out); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Rebuilding %s...\n", strings.Join(images, ", "))
+		stdout := NewLogWriter(logger, "out")
+		stderr := io.MultiWriter(NewLogWriter(logger, "err"), out)
+		args := append([]string{"up", "-d", "--build", "--no-deps"}, images...)
+		if err := containers.Compose(env.DockerProject.String, env.ComposeDirPath(), stdout, stderr, args...); err != nil {
+			return fmt.Errorf("failed to rebuild %s: %w", strings.Join(images, ", "), err)
+		}
+		logger.Log("rebuilt %s", strings.Join(images, ", "))
+	}
+
+	if len(services)+len(windows) == 0 {
+		return nil
+	}
+	sessionName := SessionName(envName)
+	tm := NewTmuxManager(sessionName, env.Path, cfg.Tmux)
+	if !tm.SessionExists() {
+		return fmt.Errorf("tmux session does not exist: %s", sessionName)
+	}
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return fmt.Errorf("failed to get mono home: %w", err)
+	}
+	scriptDir := filepath.Join(monoHome, "data", envName, "services")
+	if err := os.MkdirAll(scriptDir, 0755); err != nil {
+		return fmt.Errorf("failed to create service script directory: %w", err)
+	}
+
+	for _, name := range services {
+		if err := startService(tm, p.ec, *cfg.processService(name), scriptDir); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Restarted %s\n", name)
+		logger.Log("restarted service %s", name)
+	}
+	for _, name := range windows {
+		i := slices.IndexFunc(cfg.Tmux.Windows, func(w TmuxWindow) bool { return w.Name == name })
+		if err := tm.RestartWindow(name, cfg.Tmux.Windows[i].Command); err != nil {
+			return fmt.Errorf("failed to restart window %s: %w", name, err)
+		}
+		fmt.Fprintf(out, "Restarted %s\n", name)
+		logger.Log("restarted window %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package mono
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestAffectedTargets(t *testing.T) {
+	envPath := t.TempDir()
+	writeBuildxFixture(t, envPath, map[string]string{
+		"api/.dockerignore": "*.md\n",
+	})
+	project := &types.Project{Services: types.Services{
+		"api": {Name: "api", Build: &types.BuildConfig{Context: filepath.Join(envPath, "api")}},
+		"web": {Name: "web", Build: &types.BuildConfig{Context: filepath.Join(envPath, "web"), Dockerfile: "../docker/web.Dockerfile"}},
+		"db":  {Name: "db", Image: "postgres:16"},
+	}}
+	cfg := &Config{
+		Build: BuildConfig{Artifacts: []ArtifactConfig{
+			{Name: "cargo", KeyFiles: []string{"worker/Cargo.lock"}, Paths: []string{"worker/target"}},
+			{Name: "npm", KeyFiles: []string{"web/package-lock.json"}, Paths: []string{"web/node_modules"}},
+		}},
+		Services: []ServiceConfig{
+			{Name: "worker", Command: "cargo run", WorkingDir: "worker", Watch: []string{"src/**/*.rs", "Cargo.lock"}},
+			{Name: "mailer", Command: "go run ./mailer", Watch: []string{"mailer"}},
+		},
+		Tmux: TmuxConfig{Windows: []TmuxWindow{
+			{Name: "codegen", Command: "make generate", Watch: []string{"proto/**/*.proto"}},
+			{Name: "shell"},
+		}},
+	}
+	changed := []string{
+		"api/README.md",
+		"api/handlers/users.go",
+		"docker/web.Dockerfile",
+		"proto/users/v1/users.proto",
+		"worker/Cargo.lock",
+		"worker/src/jobs/email.rs",
+	}
+
+	targets, err := affectedTargets(cfg, project, envPath, envPath, changed)
+	if err != nil {
+		t.Fatalf("affectedTargets() error = %v", err)
+	}
+	got := make(map[string][]string)
+	var order []string
+	for _, target := range targets {
+		got[target.Kind+"/"+target.Name] = target.Files
+		order = append(order, target.Kind+"/"+target.Name)
+	}
+	want := map[string][]string{
+		"image/api":      {"api/handlers/users.go"},
+		"image/web":      {"docker/web.Dockerfile"},
+		"service/worker": {"worker/Cargo.lock", "worker/src/jobs/email.rs"},
+		"window/codegen": {"proto/users/v1/users.proto"},
+		"artifact/cargo": {"worker/Cargo.lock"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("affected = %v, want %v", order, want)
+	}
+	for key, files := range want {
+		if !slices.Equal(got[key], files) {
+			t.Errorf("%s files = %v, want %v", key, got[key], files)
+		}
+	}
+	if want := []string{"image/api", "image/web", "service/worker", "window/codegen", "artifact/cargo"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.email=t@t", "-c", "user.name=t"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	writeBuildxFixture(t, repo, map[string]string{"svc/main.go": "package main\n", "svc/go.mod": "module svc\n", "other/a.txt": "a"})
+	git("init", "-q", "-b", "main")
+	git("add", "-A")
+	git("commit", "-q", "-m", "base")
+	git("checkout", "-q", "-b", "feature")
+	writeBuildxFixture(t, repo, map[string]string{"svc/main.go": "package main\n\nfunc main() {}\n", "other/a.txt": "b"})
+	git("commit", "-q", "-am", "change")
+	git("checkout", "-q", "main")
+	writeBuildxFixture(t, repo, map[string]string{"svc/go.mod": "module svc\n\ngo 1.24\n"})
+	git("commit", "-q", "-am", "upstream")
+	git("checkout", "-q", "feature")
+	writeBuildxFixture(t, repo, map[string]string{"svc/new.go": "package main\n", "svc/.gitignore": "*.log\n", "svc/debug.log": "x"})
+
+	dir := filepath.Join(repo, "svc")
+	base, err := GitMergeBase(dir, "main")
+	if err != nil {
+		t.Fatalf("GitMergeBase() error = %v", err)
+	}
+	files, err := GitChangedFiles(dir, base)
+	if err != nil {
+		t.Fatalf("GitChangedFiles() error = %v", err)
+	}
+	if want := []string{".gitignore", "main.go", "new.go"}; !slices.Equal(files, want) {
+		t.Errorf("GitChangedFiles() = %v, want %v", files, want)
+	}
+
+	if _, err := GitMergeBase(dir, "origin/missing"); err == nil || !strings.Contains(err.Error(), "origin/missing") {
+		t.Errorf("GitMergeBase() with an unknown ref error = %v", err)
+	}
+}
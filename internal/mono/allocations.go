@@ -0,0 +1,75 @@
+package mono
+
+import (
+	"fmt"
+)
+
+func (db *DB) SaveAllocations(envID int64, allocations []Allocation) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM port_allocations WHERE env_id = ?`, envID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to clear allocations: %w", err)
+	}
+
+	for _, a := range allocations {
+		_, err := tx.Exec(
+			`INSERT INTO port_allocations (env_id, service, container_port, host_port, protocol, count) VALUES (?, ?, ?, ?, ?, ?)`,
+			envID, a.Service, a.ContainerPort, a.HostPort, a.Protocol, a.Size(),
+		)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to insert allocation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit allocations: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAllocations(envID int64) ([]Allocation, error) {
+	rows, err := db.conn.Query(
+		`SELECT service, container_port, host_port, protocol, count FROM port_allocations WHERE env_id = ? ORDER BY host_port`,
+		envID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allocations: %w", err)
+	}
+	defer rows.Close()
+
+	var allocations []Allocation
+	for rows.Next() {
+		var a Allocation
+		if err := rows.Scan(&a.Service, &a.ContainerPort, &a.HostPort, &a.Protocol, &a.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan allocation: %w", err)
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+func (db *DB) GetAllAllocations() (map[int64][]Allocation, error) {
+	rows, err := db.conn.Query(
+		`SELECT env_id, service, container_port, host_port, protocol, count FROM port_allocations ORDER BY env_id, host_port`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allocations: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64][]Allocation)
+	for rows.Next() {
+		var envID int64
+		var a Allocation
+		if err := rows.Scan(&envID, &a.Service, &a.ContainerPort, &a.HostPort, &a.Protocol, &a.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan allocation: %w", err)
+		}
+		result[envID] = append(result[envID], a)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,537 @@
+package mono
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	archiveManifestFile = "archive.json"
+	archiveDataFile     = "data.tar.gz"
+	archiveConfigDir    = "config"
+	archiveVolumesDir   = "volumes"
+)
+
+var ErrArchiveNotFound = errors.New("archive not found")
+
+type ArchiveManifest struct {
+	Name           string            `json:"name"`
+	Path           string            `json:"path"`
+	Repo           string            `json:"repo"`
+	RootPath       string            `json:"root_path,omitempty"`
+	Branch         string            `json:"branch"`
+	Commit         string            `json:"commit"`
+	Template       string            `json:"template,omitempty"`
+	Profile        string            `json:"profile,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	DockerProject  string            `json:"docker_project,omitempty"`
+	Runtime        string            `json:"container_runtime,omitempty"`
+	EphemeralPorts bool              `json:"ephemeral_ports,omitempty"`
+	Allocations    []Allocation      `json:"allocations,omitempty"`
+	Volumes        []string          `json:"volumes,omitempty"`
+	ConfigFiles    []string          `json:"config_files,omitempty"`
+	HasData        bool              `json:"has_data"`
+	ArchivedAt     time.Time         `json:"archived_at"`
+}
+
+func ArchivesDir() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(monoHome, "archives"), nil
+}
+
+func archiveDir(name string) (string, error) {
+	if err := ValidateEnvName(name); err != nil {
+		return "", err
+	}
+	dir, err := ArchivesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func ReadArchive(name string) (*ArchiveManifest, error) {
+	dir, err := archiveDir(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, archiveManifestFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrArchiveNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", name, err)
+	}
+
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive %s: %w", name, err)
+	}
+	return &manifest, nil
+}
+
+func ListArchives() ([]ArchiveManifest, error) {
+	dir, err := ArchivesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archives: %w", err)
+	}
+
+	var archives []ArchiveManifest
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		manifest, err := ReadArchive(entry.Name())
+		if errors.Is(err, ErrArchiveNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, *manifest)
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].ArchivedAt.After(archives[j].ArchivedAt)
+	})
+	return archives, nil
+}
+
+func Archive(path string) (*ArchiveManifest, error) {
+	manifest, err := buildArchiveManifest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := archiveDir(manifest.Name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("archive %s already exists at %s", manifest.Name, dir)
+	}
+
+	logger, err := NewFileLogger(manifest.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	logger.Log("mono archive %s", path)
+
+	if err := writeArchive(dir, manifest, logger); err != nil {
+		if rmErr := removeAllAudited(dir); rmErr != nil {
+			return nil, fmt.Errorf("%w (cleanup also failed: %v)", err, rmErr)
+		}
+		return nil, err
+	}
+	logger.Log("wrote archive to %s", dir)
+
+	if err := Destroy(path, DestroyOptions{KeepWorktree: true}); err != nil {
+		return nil, fmt.Errorf("archive written to %s but destroy failed: %w", dir, err)
+	}
+
+	err = RemoveWorktree(manifest.Repo, path, true)
+	if auditErr := audit(AuditRemove, path, manifest.Name, 0, err); auditErr != nil {
+		return nil, auditErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("archive written to %s but worktree was kept: %w", dir, err)
+	}
+	logger.Log("removed worktree %s", path)
+
+	return manifest, nil
+}
+
+func buildArchiveManifest(path string) (*ArchiveManifest, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("environment not found: %s", path)
+	}
+
+	repo, linked, err := GitMainWorktree(path)
+	if err != nil {
+		return nil, err
+	}
+	if !linked {
+		return nil, fmt.Errorf("cannot archive %s: it is the main checkout, not a worktree", path)
+	}
+
+	commit, branch, err := GitHead(path)
+	if err != nil {
+		return nil, err
+	}
+	if branch == "HEAD" {
+		return nil, fmt.Errorf("cannot archive %s: HEAD is detached", path)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.ApplyDefaults(path)
+
+	changed, err := GitChangedPaths(path)
+	if err != nil {
+		return nil, err
+	}
+	ignored := map[string]bool{
+		"mono.yml":      true,
+		LocalConfigFile: true,
+		filepath.Join(env.ComposeDir.String, "docker-compose.mono.yml"): true,
+	}
+	for _, sp := range cfg.Shared {
+		ignored[filepath.Clean(sp.Path)] = true
+	}
+	if cfg.Direnv.Enabled {
+		ignored[EnvrcFile] = true
+	}
+	if !filepath.IsAbs(cfg.Dotenv.File) {
+		ignored[filepath.Clean(cfg.Dotenv.File)] = true
+	}
+	var dirty []string
+	for _, p := range changed {
+		if !ignored[filepath.Clean(p)] {
+			dirty = append(dirty, p)
+		}
+	}
+	if len(dirty) > 0 {
+		return nil, fmt.Errorf("cannot archive %s: uncommitted changes in %s (commit or stash them first)", path, strings.Join(dirty, ", "))
+	}
+
+	labels, err := db.GetLabels(env.ID)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchiveManifest{
+		Name:           env.EnvName(),
+		Path:           env.Path,
+		Repo:           repo,
+		RootPath:       env.RootPath.String,
+		Branch:         branch,
+		Commit:         commit,
+		Template:       env.Template.String,
+		Profile:        env.Profile.String,
+		Labels:         labels,
+		DockerProject:  env.DockerProject.String,
+		Runtime:        env.ContainerRuntime.String,
+		EphemeralPorts: !env.PortSlot.Valid && len(allocations) > 0,
+		Allocations:    allocations,
+	}, nil
+}
+
+func writeArchive(dir string, manifest *ArchiveManifest, logger *FileLogger) error {
+	configDir := filepath.Join(dir, archiveConfigDir)
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	for _, name := range []string{"mono.yml", LocalConfigFile} {
+		src := filepath.Join(manifest.Path, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(configDir, name)); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		manifest.ConfigFiles = append(manifest.ConfigFiles, name)
+	}
+
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	dataDir := filepath.Join(monoHome, "data", manifest.Name)
+	if dirExists(dataDir) {
+		if err := writeTarGz(dataDir, filepath.Join(dir, archiveDataFile)); err != nil {
+			return fmt.Errorf("failed to archive data directory: %w", err)
+		}
+		manifest.HasData = true
+		logger.Log("archived data directory %s", dataDir)
+	}
+
+	if manifest.DockerProject != "" {
+		containers, err := recordedContainerRuntime(manifest.Runtime)
+		if err != nil {
+			return err
+		}
+		volumes, err := containers.ProjectVolumes(manifest.DockerProject)
+		if err != nil {
+			return err
+		}
+		if len(volumes) > 0 {
+			if err := containers.StopProject(manifest.DockerProject); err != nil {
+				return err
+			}
+			volumesDir := filepath.Join(dir, archiveVolumesDir)
+			if err := os.MkdirAll(volumesDir, 0755); err != nil {
+				return fmt.Errorf("failed to create archive directory: %w", err)
+			}
+			for _, volume := range volumes {
+				if err := containers.ExportVolume(volume, volumesDir, volume+".tar.gz"); err != nil {
+					return err
+				}
+				logger.Log("exported volume %s", volume)
+			}
+		}
+		manifest.Volumes = volumes
+	}
+
+	manifest.ArchivedAt = time.Now()
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode archive manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, archiveManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	return nil
+}
+
+func Unarchive(name string) (string, error) {
+	manifest, err := ReadArchive(name)
+	if err != nil {
+		return "", err
+	}
+	dir, err := archiveDir(name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(manifest.Path); err == nil {
+		return "", fmt.Errorf("cannot unarchive %s: %s already exists", name, manifest.Path)
+	}
+
+	logger, err := NewFileLogger(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	logger.Log("mono unarchive %s", name)
+
+	if err := AddWorktree(manifest.Repo, manifest.Path, manifest.Branch, manifest.Commit); err != nil {
+		return "", err
+	}
+	Printf("Restored worktree for %s at %s\n", manifest.Branch, manifest.Path)
+
+	if err := restoreArchive(dir, manifest, logger); err != nil {
+		if rmErr := RemoveWorktree(manifest.Repo, manifest.Path, true); rmErr != nil {
+			return "", fmt.Errorf("%w (cleanup also failed: %v)", err, rmErr)
+		}
+		return "", err
+	}
+
+	opts := InitOptions{ProjectRoot: manifest.RootPath, EphemeralPorts: manifest.EphemeralPorts, Template: manifest.Template, Profile: manifest.Profile}
+	if err := Init(manifest.Path, opts); err != nil {
+		return "", fmt.Errorf("failed to initialize %s (archive kept at %s, retry with mono init): %w", manifest.Path, dir, err)
+	}
+
+	if DeriveEnvName(manifest.Path) != name {
+		if err := Rename(manifest.Path, name); err != nil {
+			return "", err
+		}
+	}
+
+	if len(manifest.Labels) > 0 {
+		labels := make([]string, 0, len(manifest.Labels))
+		for k, v := range manifest.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		if _, err := AddLabels(manifest.Path, labels); err != nil {
+			return "", err
+		}
+	}
+
+	if err := removeAllAudited(dir); err != nil {
+		return "", fmt.Errorf("failed to remove archive %s: %w", dir, err)
+	}
+	logger.Log("removed archive %s", dir)
+
+	return manifest.Path, nil
+}
+
+func restoreArchive(dir string, manifest *ArchiveManifest, logger *FileLogger) error {
+	for _, name := range manifest.ConfigFiles {
+		if err := copyFile(filepath.Join(dir, archiveConfigDir, name), filepath.Join(manifest.Path, name)); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+
+	if manifest.HasData {
+		monoHome, err := GetMonoHome()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dataDir := filepath.Join(monoHome, "data", DeriveEnvName(manifest.Path))
+		if err := extractTarGz(filepath.Join(dir, archiveDataFile), dataDir); err != nil {
+			return fmt.Errorf("failed to restore data directory: %w", err)
+		}
+		logger.Log("restored data directory %s", dataDir)
+	}
+
+	if len(manifest.Volumes) == 0 {
+		return nil
+	}
+	containers, err := recordedContainerRuntime(manifest.Runtime)
+	if err != nil {
+		return err
+	}
+	for _, volume := range manifest.Volumes {
+		if err := containers.ImportVolume(manifest.DockerProject, volume, filepath.Join(dir, archiveVolumesDir), volume+".tar.gz"); err != nil {
+			return err
+		}
+		logger.Log("restored volume %s", volume)
+	}
+	return nil
+}
+
+func writeTarGz(src, dst string) error {
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func extractTarGz(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dst)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
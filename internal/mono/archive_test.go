@@ -0,0 +1,49 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarGzRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "nested", "deep"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "top.txt"), []byte("top"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "nested", "deep", "file.txt"), []byte("deep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("top.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "data.tar.gz")
+	if err := writeTarGz(src, archive); err != nil {
+		t.Fatalf("writeTarGz failed: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "restored")
+	if err := extractTarGz(archive, dst); err != nil {
+		t.Fatalf("extractTarGz failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "nested", "deep", "file.txt"))
+	if err != nil || string(data) != "deep" {
+		t.Errorf("nested file = %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dst, "top.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("top.txt mode = %v, want 0600", info.Mode().Perm())
+	}
+	target, err := os.Readlink(filepath.Join(dst, "link"))
+	if err != nil || target != "top.txt" {
+		t.Errorf("link target = %q, %v", target, err)
+	}
+}
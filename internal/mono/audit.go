@@ -0,0 +1,167 @@
+package mono
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	auditFile = "audit.ndjson"
+
+	AuditRemove      = "remove"
+	AuditEvict       = "evict"
+	AuditDestroy     = "destroy"
+	AuditKillSession = "kill-session"
+)
+
+var AuditActions = []string{AuditRemove, AuditEvict, AuditDestroy, AuditKillSession}
+
+var auditReason string
+
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	SudoUser string    `json:"sudo_user,omitempty"`
+	Host     string    `json:"host"`
+	PID      int       `json:"pid"`
+	Command  string    `json:"command"`
+	Action   string    `json:"action"`
+	Target   string    `json:"target"`
+	Env      string    `json:"env,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Why      string    `json:"why,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type AuditFilter struct {
+	Since  time.Time
+	Action string
+	User   string
+	Env    string
+}
+
+func (f AuditFilter) Validate() error {
+	if f.Action != "" && !slices.Contains(AuditActions, f.Action) {
+		return unknownSelection("audit action", f.Action, AuditActions)
+	}
+	return nil
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	return !e.Time.Before(f.Since) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.User == "" || e.User == f.User || e.SudoUser == f.User) &&
+		(f.Env == "" || e.Env == f.Env)
+}
+
+func SetAuditReason(why string) {
+	auditReason = why
+}
+
+func AuditPath() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, auditFile), nil
+}
+
+func newAuditEntry(action, target string, opErr error) (AuditEntry, error) {
+	u, err := user.Current()
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("failed to look up current user: %w", err)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	e := AuditEntry{
+		Time:     time.Now().UTC(),
+		User:     u.Username,
+		SudoUser: os.Getenv("SUDO_USER"),
+		Host:     host,
+		PID:      os.Getpid(),
+		Command:  strings.Join(os.Args, " "),
+		Action:   action,
+		Target:   target,
+		Why:      auditReason,
+	}
+	if opErr != nil {
+		e.Error = opErr.Error()
+	}
+	return e, nil
+}
+
+func RecordAudit(e AuditEntry) error {
+	path, err := AuditPath()
+	if err != nil {
+		return err
+	}
+	if err := appendJSONLine(path, e); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func audit(action, target, env string, size int64, opErr error) error {
+	e, err := newAuditEntry(action, target, opErr)
+	if err != nil {
+		return err
+	}
+	e.Env, e.Size = env, size
+	return RecordAudit(e)
+}
+
+func removeAllAudited(path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	err := os.RemoveAll(path)
+	return errors.Join(err, audit(AuditRemove, path, "", 0, err))
+}
+
+func ReadAudit(filter AuditFilter) ([]AuditEntry, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	path, err := AuditPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d of %s: %w", line, path, err)
+		}
+		if filter.matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
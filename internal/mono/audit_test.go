@@ -0,0 +1,81 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDestructiveOperationsAreAudited(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	t.Setenv("SUDO_USER", "alice")
+	t.Cleanup(func() { SetAuditReason("") })
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootPath := t.TempDir()
+	envPath := filepath.Join(t.TempDir(), "feature")
+	targetDir := filepath.Join(envPath, "target")
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "lib.rlib"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := ArtifactCacheEntry{
+		Name:      "cargo",
+		Key:       "abc123",
+		CachePath: cm.GetArtifactCachePath(rootPath, "cargo", "abc123"),
+		EnvPaths:  []string{targetDir},
+		EnvRoot:   envPath,
+	}
+	if err := cm.StoreToCache(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RestoreFromCache(entry, nil); err != nil {
+		t.Fatal(err)
+	}
+	SetAuditReason("disk full on ci-3")
+	if err := cm.RemoveCacheEntry(ComputeProjectID(rootPath), "cargo", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeAllAudited(filepath.Join(envPath, "missing")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadAudit(AuditFilter{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ReadAudit() = %+v, want the restore's removal and the eviction", entries)
+	}
+	if e := entries[0]; e.Action != AuditRemove || e.Target != targetDir || e.Why != "" {
+		t.Errorf("first entry = %+v, want the removal of %s without a reason", e, targetDir)
+	}
+	e := entries[1]
+	if e.Action != AuditEvict || e.Target != entry.CachePath || e.Size != 10 || e.Why != "disk full on ci-3" {
+		t.Errorf("second entry = %+v, want the eviction of %s with its size and reason", e, entry.CachePath)
+	}
+	if e.User == "" || e.Host == "" || e.PID != os.Getpid() || e.Command == "" {
+		t.Errorf("second entry = %+v, want who, where and what ran", e)
+	}
+
+	entries, err = ReadAudit(AuditFilter{Action: AuditDestroy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("destroy entries = %+v, want none", entries)
+	}
+
+	_, err = ReadAudit(AuditFilter{Action: "delete"})
+	if err == nil || !strings.Contains(err.Error(), `unknown audit action "delete"`) {
+		t.Errorf("ReadAudit(delete) error = %v", err)
+	}
+}
@@ -0,0 +1,321 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	buildSlotEnv      = "MONO_BUILD_SLOT"
+	buildQueueDir     = "queue"
+	buildWaitersDir   = "waiting"
+	buildQueueTimeFmt = time.RFC3339
+	defaultBuildSlots = 1
+)
+
+type BuildQueueConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Slots   int  `yaml:"slots"`
+}
+
+func (bq BuildQueueConfig) Validate() error {
+	if bq.Slots < 0 {
+		return fmt.Errorf("build_queue.slots must not be negative")
+	}
+	return nil
+}
+
+func (bq BuildQueueConfig) slots() int {
+	if bq.Slots == 0 {
+		return defaultBuildSlots
+	}
+	return bq.Slots
+}
+
+type QueueEntry struct {
+	Slot    int       `json:"slot,omitempty"`
+	PID     int       `json:"pid"`
+	Env     string    `json:"env"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+	Waiting bool      `json:"waiting"`
+}
+
+func (e QueueEntry) encode() string {
+	return fmt.Sprintf("%d\t%s\t%s\t%s\n", e.PID, e.Since.UTC().Format(buildQueueTimeFmt), e.Env, e.Command)
+}
+
+func parseQueueEntry(data string) (QueueEntry, error) {
+	fields := strings.SplitN(strings.TrimRight(data, "\n"), "\t", 4)
+	if len(fields) != 4 {
+		return QueueEntry{}, fmt.Errorf("malformed queue entry %q", data)
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return QueueEntry{}, fmt.Errorf("malformed queue entry pid %q: %w", fields[0], err)
+	}
+	since, err := time.Parse(buildQueueTimeFmt, fields[1])
+	if err != nil {
+		return QueueEntry{}, fmt.Errorf("malformed queue entry time %q: %w", fields[1], err)
+	}
+	return QueueEntry{PID: pid, Since: since, Env: fields[2], Command: fields[3]}, nil
+}
+
+func (e QueueEntry) describe() string {
+	return fmt.Sprintf("%s in %s (pid %d, %s)", e.Command, e.Env, e.PID, time.Since(e.Since).Round(time.Second))
+}
+
+func buildQueuePath() (string, error) {
+	home, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, buildQueueDir), nil
+}
+
+func buildSlotPath(dir string, slot int) string {
+	return filepath.Join(dir, fmt.Sprintf("slot-%d.lock", slot))
+}
+
+type BuildSlot struct {
+	file *os.File
+}
+
+func AcquireBuildSlot(cfg BuildQueueConfig, env, command string, out io.Writer) (*BuildSlot, error) {
+	if !cfg.Enabled || os.Getenv(buildSlotEnv) != "" {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	dir, err := buildQueuePath()
+	if err != nil {
+		return nil, err
+	}
+	waiters := filepath.Join(dir, buildWaitersDir)
+	if err := os.MkdirAll(waiters, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create build queue directory: %w", err)
+	}
+
+	entry := QueueEntry{PID: os.Getpid(), Env: env, Command: command, Since: time.Now()}
+	ticket := filepath.Join(waiters, fmt.Sprintf("%020d-%d", entry.Since.UnixNano(), entry.PID))
+	if err := os.WriteFile(ticket, []byte(entry.encode()), 0644); err != nil {
+		return nil, fmt.Errorf("failed to join build queue: %w", err)
+	}
+	defer func() {
+		if err := os.Remove(ticket); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(out, "mono: failed to leave build queue: %v\n", err)
+		}
+	}()
+
+	announced := false
+	for {
+		ahead, err := waitersAhead(waiters, filepath.Base(ticket))
+		if err != nil {
+			return nil, err
+		}
+		if ahead == 0 {
+			f, err := tryBuildSlots(dir, cfg.slots(), entry)
+			if err != nil {
+				return nil, err
+			}
+			if f != nil {
+				if announced {
+					fmt.Fprintf(out, "mono: got a build slot after %s\n", time.Since(entry.Since).Round(time.Second))
+				}
+				return &BuildSlot{file: f}, nil
+			}
+		}
+		if !announced {
+			running, err := runningBuilds(dir)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(out, "mono: waiting for a build slot (%d ahead in queue)\n", ahead)
+			for _, r := range running {
+				fmt.Fprintf(out, "mono:   running: %s\n", r.describe())
+			}
+			announced = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+func tryBuildSlots(dir string, slots int, entry QueueEntry) (*os.File, error) {
+	for i := 0; i < slots; i++ {
+		f, err := os.OpenFile(buildSlotPath(dir, i), os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open build slot: %w", err)
+		}
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			if err := f.Close(); err != nil {
+				return nil, fmt.Errorf("failed to close build slot: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock build slot: %w", err)
+		}
+		entry.Since = time.Now()
+		if err := f.Truncate(0); err != nil {
+			releaseLockFile(f)
+			return nil, fmt.Errorf("failed to write build slot: %w", err)
+		}
+		if _, err := f.WriteAt([]byte(entry.encode()), 0); err != nil {
+			releaseLockFile(f)
+			return nil, fmt.Errorf("failed to write build slot: %w", err)
+		}
+		return f, nil
+	}
+	return nil, nil
+}
+
+func waitersAhead(dir, ticket string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read build queue: %w", err)
+	}
+	ahead := 0
+	for _, e := range entries {
+		if e.Name() >= ticket {
+			continue
+		}
+		if !waiterAlive(e.Name()) {
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+				return 0, fmt.Errorf("failed to remove stale build queue entry: %w", err)
+			}
+			continue
+		}
+		ahead++
+	}
+	return ahead, nil
+}
+
+func waiterAlive(name string) bool {
+	_, pidStr, ok := strings.Cut(name, "-")
+	if !ok {
+		return false
+	}
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return false
+	}
+	return processAlive(pid)
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func runningBuilds(dir string) ([]QueueEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "slot-*.lock"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list build slots: %w", err)
+	}
+	var running []QueueEntry
+	for _, p := range paths {
+		slot, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), "slot-"), ".lock"))
+		if err != nil {
+			continue
+		}
+		held, err := slotHeld(p)
+		if err != nil {
+			return nil, err
+		}
+		if !held {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build slot: %w", err)
+		}
+		entry, err := parseQueueEntry(string(data))
+		if err != nil {
+			entry = QueueEntry{Env: "-", Command: "unknown"}
+		}
+		entry.Slot = slot + 1
+		running = append(running, entry)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Slot < running[j].Slot })
+	return running, nil
+}
+
+func slotHeld(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open build slot: %w", err)
+	}
+	defer f.Close()
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check build slot: %w", err)
+	}
+	return false, syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+func BuildQueueStatus() ([]QueueEntry, error) {
+	dir, err := buildQueuePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	entries, err := runningBuilds(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	waiters := filepath.Join(dir, buildWaitersDir)
+	tickets, err := os.ReadDir(waiters)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read build queue: %w", err)
+	}
+	for _, t := range tickets {
+		if !waiterAlive(t.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(waiters, t.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build queue entry: %w", err)
+		}
+		entry, err := parseQueueEntry(string(data))
+		if err != nil {
+			return nil, err
+		}
+		entry.Waiting = true
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *BuildSlot) Env() []string {
+	if s == nil {
+		return nil
+	}
+	return []string{buildSlotEnv + "=1"}
+}
+
+func (s *BuildSlot) Release() {
+	if s != nil && s.file != nil {
+		releaseLockFile(s.file)
+		s.file = nil
+	}
+}
@@ -0,0 +1,105 @@
+package mono
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireBuildSlotDisabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+
+	slot, err := AcquireBuildSlot(BuildQueueConfig{}, "feature", "cargo build", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot != nil {
+		t.Errorf("AcquireBuildSlot() = %v, want no slot when the queue is disabled", slot)
+	}
+
+	t.Setenv(buildSlotEnv, "1")
+	slot, err = AcquireBuildSlot(BuildQueueConfig{Enabled: true}, "feature", "cargo build", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slot != nil {
+		t.Errorf("AcquireBuildSlot() = %v, want no slot inside a build that holds one", slot)
+	}
+}
+
+func TestAcquireBuildSlotQueues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cfg := BuildQueueConfig{Enabled: true, Slots: 1}
+
+	first, err := AcquireBuildSlot(cfg, "feature", "cargo build", io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	acquired := make(chan *BuildSlot)
+	failed := make(chan error)
+	go func() {
+		slot, err := AcquireBuildSlot(cfg, "bugfix", "task build", &out)
+		if err != nil {
+			failed <- err
+			return
+		}
+		acquired <- slot
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries, err := BuildQueueStatus()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 2 {
+			if entries[0].Waiting || entries[0].Env != "feature" || entries[0].Slot != 1 {
+				t.Errorf("entries[0] = %+v, want feature running in slot 1", entries[0])
+			}
+			if !entries[1].Waiting || entries[1].Env != "bugfix" || entries[1].Command != "task build" {
+				t.Errorf("entries[1] = %+v, want bugfix waiting", entries[1])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queue = %+v, want one running and one waiting build", entries)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	select {
+	case slot := <-acquired:
+		slot.Release()
+		t.Fatal("second build got a slot while the first still held it")
+	case err := <-failed:
+		t.Fatal(err)
+	default:
+	}
+
+	first.Release()
+	select {
+	case slot := <-acquired:
+		slot.Release()
+	case err := <-failed:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second build never got the released slot")
+	}
+	if !strings.Contains(out.String(), "running: cargo build in feature") {
+		t.Errorf("queue output = %q, want the running build", out.String())
+	}
+
+	entries, err := BuildQueueStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("queue after release = %+v, want empty", entries)
+	}
+}
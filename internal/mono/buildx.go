@@ -0,0 +1,247 @@
+package mono
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const (
+	ArtifactDirectory = "directory"
+	ArtifactBuildx    = "buildx"
+
+	buildxCacheIndex = "index.json"
+)
+
+var buildxSkippedNames = []string{".git", "docker-compose.mono.yml", LocalConfigFile}
+
+func (a ArtifactConfig) ArtifactType() string {
+	if a.Type == "" {
+		return ArtifactDirectory
+	}
+	return a.Type
+}
+
+func validateBuildxArtifact(a ArtifactConfig) error {
+	if a.Service == "" {
+		return fmt.Errorf("artifact %s has type %s but no service", a.Name, ArtifactBuildx)
+	}
+	if a.RestoreStrategy() != RestoreCopy {
+		return fmt.Errorf("artifact %s must use strategy %s: buildx rewrites its cache in place", a.Name, RestoreCopy)
+	}
+	return nil
+}
+
+func buildxService(a ArtifactConfig, envPath string) (types.ServiceConfig, string, error) {
+	composeDir := filepath.Join(envPath, a.composeDir)
+	compose, err := ParseComposeConfig(composeDir)
+	if err != nil {
+		return types.ServiceConfig{}, "", err
+	}
+	svc, ok := compose.Project().AllServices()[a.Service]
+	if !ok {
+		return types.ServiceConfig{}, "", fmt.Errorf("artifact %s: compose service %s does not exist", a.Name, a.Service)
+	}
+	if svc.Build == nil {
+		return types.ServiceConfig{}, "", fmt.Errorf("artifact %s: compose service %s has no build section", a.Name, a.Service)
+	}
+	context := svc.Build.Context
+	if context == "" {
+		context = "."
+	}
+	if !filepath.IsAbs(context) {
+		context = filepath.Join(composeDir, context)
+	}
+	return svc, context, nil
+}
+
+func hashBuildContext(h hash.Hash, a ArtifactConfig, envPath string) (int64, error) {
+	svc, context, err := buildxService(a, envPath)
+	if err != nil {
+		return 0, err
+	}
+
+	var hashed int64
+	if svc.Build.DockerfileInline != "" {
+		n, err := io.WriteString(h, svc.Build.DockerfileInline)
+		if err != nil {
+			return 0, err
+		}
+		hashed += int64(n)
+	} else {
+		dockerfile := svc.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		if !filepath.IsAbs(dockerfile) {
+			dockerfile = filepath.Join(context, dockerfile)
+		}
+		n, err := hashFile(h, dockerfile)
+		if err != nil {
+			return 0, fmt.Errorf("failed to hash Dockerfile for %s: %w", a.Service, err)
+		}
+		hashed += n
+	}
+
+	ignore, err := readDockerignore(context)
+	if err != nil {
+		return 0, err
+	}
+	var owned []string
+	for _, p := range a.Paths {
+		owned = append(owned, filepath.Join(envPath, p))
+	}
+
+	err = filepath.WalkDir(context, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(context, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if slices.Contains(buildxSkippedNames, d.Name()) || slices.Contains(owned, path) || ignore.excludes(filepath.ToSlash(rel)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		n, err := hashFile(h, path)
+		if err != nil {
+			return err
+		}
+		hashed += n
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash build context of %s: %w", a.Service, err)
+	}
+	return hashed, nil
+}
+
+func hashFile(h hash.Hash, path string) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return io.Copy(h, f)
+}
+
+type dockerignore []string
+
+func readDockerignore(context string) (dockerignore, error) {
+	f, err := os.Open(filepath.Join(context, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	defer f.Close()
+
+	var patterns dockerignore
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		line = strings.Trim(filepath.ToSlash(filepath.Clean(strings.TrimPrefix(line, "!"))), "/")
+		if negate {
+			line = "!" + line
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	return patterns, nil
+}
+
+func (d dockerignore) excludes(rel string) bool {
+	excluded := false
+	for _, pattern := range d {
+		negate := strings.HasPrefix(pattern, "!")
+		if ignorePatternMatches(strings.TrimPrefix(pattern, "!"), rel) {
+			excluded = !negate
+		}
+	}
+	return excluded
+}
+
+func ignorePatternMatches(pattern, rel string) bool {
+	if rest, ok := strings.CutPrefix(pattern, "**/"); ok {
+		parts := strings.Split(rel, "/")
+		for i := range parts {
+			if ignorePatternMatches(rest, strings.Join(parts[i:], "/")) {
+				return true
+			}
+		}
+		return false
+	}
+	for p := rel; p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+		if ok, _ := filepath.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func ApplyBuildxCache(project *types.Project, artifacts []ArtifactConfig, envPath string) error {
+	for _, a := range artifacts {
+		if a.ArtifactType() != ArtifactBuildx {
+			continue
+		}
+		svc, ok := project.Services[a.Service]
+		if !ok {
+			continue
+		}
+		if svc.Build == nil {
+			return fmt.Errorf("artifact %s: compose service %s has no build section", a.Name, a.Service)
+		}
+		for _, p := range a.Paths {
+			dir := filepath.Join(envPath, p)
+			if fileExists(filepath.Join(dir, buildxCacheIndex)) {
+				svc.Build.CacheFrom = append(svc.Build.CacheFrom, "type=local,src="+dir)
+			}
+			svc.Build.CacheTo = append(svc.Build.CacheTo, "type=local,dest="+dir+",mode=max")
+		}
+		project.Services[a.Service] = svc
+	}
+	return nil
+}
+
+func (cm *CacheManager) StoreBuildxCaches(entries []ArtifactCacheEntry, logger *FileLogger) {
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Type != ArtifactBuildx || entry.Hit {
+			continue
+		}
+		if !slices.ContainsFunc(entry.EnvPaths, func(p string) bool { return fileExists(filepath.Join(p, buildxCacheIndex)) }) {
+			continue
+		}
+		if err := cm.StoreToCache(*entry); err != nil {
+			logger.Log("warning: failed to store %s to cache: %v", entry.Name, err)
+			continue
+		}
+		logger.Log("stored %s to cache (key: %s)", entry.Name, entry.Key)
+		entry.Hit = true
+	}
+}
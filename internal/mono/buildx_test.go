@@ -0,0 +1,198 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func writeBuildxFixture(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildxCacheKey(t *testing.T) {
+	envPath := t.TempDir()
+	writeBuildxFixture(t, envPath, map[string]string{
+		"docker-compose.yml": "services:\n  api:\n    build: .\n  db:\n    image: postgres:16\n",
+		"Dockerfile":         "FROM golang:1.24\nCOPY . .\n",
+		"main.go":            "package main\n",
+		".dockerignore":      "# generated\n*.log\ntmp/\n",
+	})
+
+	cm := &CacheManager{}
+	artifact := ArtifactConfig{Name: "api-image", Type: ArtifactBuildx, Service: "api", Paths: []string{".mono/buildx/api"}}
+	key := func() string {
+		t.Helper()
+		k, err := cm.ComputeCacheKey(artifact, envPath)
+		if err != nil {
+			t.Fatalf("ComputeCacheKey() error = %v", err)
+		}
+		return k
+	}
+
+	base := key()
+
+	writeBuildxFixture(t, envPath, map[string]string{
+		"debug.log":                          "noise",
+		"tmp/scratch":                        "noise",
+		"docker-compose.mono.yml":            "services: {}\n",
+		".mono/buildx/api/index.json":        "{}",
+		".git/HEAD":                          "ref: refs/heads/main\n",
+		".mono/buildx/api/blobs/sha256/abcd": "layer",
+	})
+	if got := key(); got != base {
+		t.Errorf("key changed after writing ignored files: %s -> %s", base, got)
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	source := key()
+	if source == base {
+		t.Error("key did not change after editing a file in the build context")
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"Dockerfile": "FROM golang:1.24\nCOPY . .\nRUN go build\n"})
+	if got := key(); got == source {
+		t.Error("key did not change after editing the Dockerfile")
+	}
+
+	artifact.Service = "db"
+	if _, err := cm.ComputeCacheKey(artifact, envPath); err == nil || !strings.Contains(err.Error(), "no build section") {
+		t.Errorf("ComputeCacheKey() for an image-only service error = %v, want no build section", err)
+	}
+}
+
+func TestDockerignoreExcludes(t *testing.T) {
+	ignore := dockerignore{"node_modules", "**/*.tmp", "docs/*.md", "!docs/README.md"}
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"node_modules", true},
+		{"node_modules/left-pad/index.js", true},
+		{"web/cache/build.tmp", true},
+		{"docs/guide.md", true},
+		{"docs/README.md", false},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := ignore.excludes(tt.rel); got != tt.want {
+			t.Errorf("excludes(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestApplyBuildxCache(t *testing.T) {
+	envPath := t.TempDir()
+	project := &types.Project{Services: types.Services{
+		"api": {Name: "api", Build: &types.BuildConfig{Context: envPath}},
+		"web": {Name: "web", Build: &types.BuildConfig{Context: envPath}},
+	}}
+	artifacts := []ArtifactConfig{
+		{Name: "cargo", Paths: []string{"target"}},
+		{Name: "api-image", Type: ArtifactBuildx, Service: "api", Paths: []string{".mono/buildx/api"}},
+		{Name: "web-image", Type: ArtifactBuildx, Service: "web", Paths: []string{".mono/buildx/web"}},
+		{Name: "worker-image", Type: ArtifactBuildx, Service: "worker", Paths: []string{".mono/buildx/worker"}},
+	}
+	writeBuildxFixture(t, envPath, map[string]string{".mono/buildx/web/index.json": "{}"})
+
+	if err := ApplyBuildxCache(project, artifacts, envPath); err != nil {
+		t.Fatalf("ApplyBuildxCache() error = %v", err)
+	}
+
+	api := project.Services["api"].Build
+	if len(api.CacheFrom) != 0 {
+		t.Errorf("api cache_from = %v, want none before the first export", api.CacheFrom)
+	}
+	if want := "type=local,dest=" + filepath.Join(envPath, ".mono/buildx/api") + ",mode=max"; !slices.Equal(api.CacheTo, types.StringList{want}) {
+		t.Errorf("api cache_to = %v, want [%s]", api.CacheTo, want)
+	}
+
+	web := project.Services["web"].Build
+	if want := "type=local,src=" + filepath.Join(envPath, ".mono/buildx/web"); !slices.Equal(web.CacheFrom, types.StringList{want}) {
+		t.Errorf("web cache_from = %v, want [%s]", web.CacheFrom, want)
+	}
+
+	project.Services["db"] = types.ServiceConfig{Name: "db", Image: "postgres:16"}
+	err := ApplyBuildxCache(project, []ArtifactConfig{{Name: "db-image", Type: ArtifactBuildx, Service: "db", Paths: []string{"x"}}}, envPath)
+	if err == nil {
+		t.Error("ApplyBuildxCache() accepted a service without a build section")
+	}
+}
+
+func TestStoreBuildxCaches(t *testing.T) {
+	envPath := t.TempDir()
+	cm := &CacheManager{LocalCacheDir: t.TempDir()}
+	logger, err := NewFileLogger("buildx-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	entries := []ArtifactCacheEntry{
+		{Name: "api-image", Key: "k1", Type: ArtifactBuildx, Strategy: RestoreCopy, EnvRoot: envPath,
+			CachePath: filepath.Join(cm.LocalCacheDir, "p", "api-image", "k1"), EnvPaths: []string{filepath.Join(envPath, ".mono/buildx/api")}},
+		{Name: "web-image", Key: "k2", Type: ArtifactBuildx, Strategy: RestoreCopy, EnvRoot: envPath,
+			CachePath: filepath.Join(cm.LocalCacheDir, "p", "web-image", "k2"), EnvPaths: []string{filepath.Join(envPath, ".mono/buildx/web")}},
+	}
+	writeBuildxFixture(t, envPath, map[string]string{
+		".mono/buildx/api/index.json":        "{}",
+		".mono/buildx/api/blobs/sha256/abcd": "layer",
+	})
+
+	cm.StoreBuildxCaches(entries, logger)
+
+	if !entries[0].Hit {
+		t.Error("api-image was not stored after buildx exported its cache")
+	}
+	if !fileExists(filepath.Join(entries[0].CachePath, "api", "blobs", "sha256", "abcd")) {
+		t.Error("exported layers were not copied into the cache")
+	}
+	if !fileExists(filepath.Join(envPath, ".mono/buildx/api/index.json")) {
+		t.Error("the environment's cache export was moved instead of copied")
+	}
+	if entries[1].Hit || dirExists(entries[1].CachePath) {
+		t.Error("web-image was stored although buildx exported nothing")
+	}
+}
+
+func TestValidateBuildxArtifacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact ArtifactConfig
+		wantErr  string
+	}{
+		{"valid", ArtifactConfig{Name: "api-image", Type: ArtifactBuildx, Service: "api", Paths: []string{".mono/buildx/api"}}, ""},
+		{"no service", ArtifactConfig{Name: "api-image", Type: ArtifactBuildx, Paths: []string{".mono/buildx/api"}}, "no service"},
+		{"hardlink", ArtifactConfig{Name: "api-image", Type: ArtifactBuildx, Service: "api", Strategy: RestoreHardlink, Paths: []string{"x"}}, "must use strategy copy"},
+		{"unknown type", ArtifactConfig{Name: "api-image", Type: "bazel", KeyFiles: []string{"WORKSPACE"}, Paths: []string{"x"}}, "invalid type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := BuildConfig{Artifacts: []ArtifactConfig{tt.artifact}}
+			err := bc.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
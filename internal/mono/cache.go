@@ -4,18 +4,21 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/shirou/gopsutil/v4/process"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -23,6 +26,18 @@ type CacheManager struct {
 	HomeDir          string
 	LocalCacheDir    string
 	SccacheAvailable bool
+	Workers          int
+	MaxSize          int64
+
+	tracer      *Tracer
+	keys        sync.Map
+	keyCommands sync.Map
+	nixShells   sync.Map
+}
+
+type memoizedCacheKey struct {
+	key      string
+	warnings []string
 }
 
 func NewCacheManager() (*CacheManager, error) {
@@ -31,9 +46,20 @@ func NewCacheManager() (*CacheManager, error) {
 		return nil, err
 	}
 
+	user, err := LoadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := user.Cache.MaxBytes()
+	if err != nil {
+		return nil, err
+	}
+
 	cm := &CacheManager{
 		HomeDir:       homeDir,
 		LocalCacheDir: filepath.Join(homeDir, "cache_local"),
+		Workers:       user.Cache.Workers,
+		MaxSize:       maxSize,
 	}
 
 	cm.SccacheAvailable = cm.detectSccache()
@@ -69,37 +95,148 @@ type ArtifactCacheEntry struct {
 	Key       string
 	CachePath string
 	EnvPaths  []string
+	EnvRoot   string
+	Strategy  string
+	Type      string
 	Hit       bool
+	Warnings  []string
 }
 
 func (cm *CacheManager) ComputeCacheKey(artifact ArtifactConfig, envPath string) (string, error) {
+	key, _, err := cm.computeCacheKey(artifact, envPath)
+	return key, err
+}
+
+func (cm *CacheManager) computeCacheKey(artifact ArtifactConfig, envPath string) (string, []string, error) {
+	memo, err := cm.cacheKeyMemo(artifact, envPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if memo != "" {
+		if m, ok := cm.keys.Load(memo); ok {
+			return m.(memoizedCacheKey).key, m.(memoizedCacheKey).warnings, nil
+		}
+	}
+
+	span := cm.tracer.Start("compute cache key")
+	span.SetAttr("mono.artifact", artifact.Name)
+	key, warnings, err := cm.hashCacheKey(artifact, envPath)
+	span.SetAttr("mono.cache_key", key)
+	span.Fail(err)
+	span.End()
+	if err == nil && memo != "" {
+		cm.keys.Store(memo, memoizedCacheKey{key: key, warnings: warnings})
+	}
+	return key, warnings, err
+}
+
+func (cm *CacheManager) cacheKeyMemo(artifact ArtifactConfig, envPath string) (string, error) {
+	if artifact.ArtifactType() == ArtifactBuildx {
+		return "", nil
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%q\x00%q\x00%+v\x00", artifact.Name, envPath, artifact.KeyFiles, artifact.KeyCommands, artifact.nix)
+	for _, keyFile := range artifact.KeyFiles {
+		if err := writeFileStamp(h, keyFile, filepath.Join(envPath, keyFile)); err != nil {
+			return "", fmt.Errorf("failed to stat key file %s: %w", keyFile, err)
+		}
+	}
+	if artifact.nix.Enabled {
+		shellPath, err := cm.nixShellPath(artifact.nix, envPath)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", shellPath)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeFileStamp(w io.Writer, name, path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(w, "%s missing\x00", name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var inode uint64
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		inode = uint64(st.Ino)
+	}
+	fmt.Fprintf(w, "%s %d %d %d\x00", name, info.Size(), info.ModTime().UnixNano(), inode)
+	return nil
+}
+
+func (cm *CacheManager) runKeyCommand(cmd string) ([]byte, error) {
+	if output, ok := cm.keyCommands.Load(cmd); ok {
+		return output.([]byte), nil
+	}
+	output, err := exec.Command("bash", "-c", cmd).Output()
+	if err != nil {
+		return nil, err
+	}
+	cm.keyCommands.Store(cmd, output)
+	return output, nil
+}
+
+func (cm *CacheManager) hashCacheKey(artifact ArtifactConfig, envPath string) (string, []string, error) {
 	h := sha256.New()
+	var hashed int64
+	var warnings []string
 
 	for _, keyFile := range artifact.KeyFiles {
+		if filepath.Base(keyFile) == LocalConfigFile {
+			continue
+		}
 		fullPath := filepath.Join(envPath, keyFile)
 		f, err := os.Open(fullPath)
 		if err != nil {
 			if os.IsNotExist(err) {
+				warnings = append(warnings, fmt.Sprintf("key file %s does not exist", keyFile))
 				continue
 			}
-			return "", fmt.Errorf("failed to read key file %s: %w", keyFile, err)
+			return "", nil, fmt.Errorf("failed to read key file %s: %w", keyFile, err)
 		}
-		_, err = io.Copy(h, f)
+		n, err := io.Copy(h, f)
 		f.Close()
 		if err != nil {
-			return "", fmt.Errorf("failed to hash key file %s: %w", keyFile, err)
+			return "", nil, fmt.Errorf("failed to hash key file %s: %w", keyFile, err)
 		}
+		hashed += n
 	}
 
 	for _, cmd := range artifact.KeyCommands {
-		output, err := exec.Command("bash", "-c", cmd).Output()
+		output, err := cm.runKeyCommand(cmd)
 		if err != nil {
-			return "", fmt.Errorf("failed to run key command %s: %w", cmd, err)
+			return "", nil, fmt.Errorf("failed to run key command %s: %w", cmd, err)
 		}
 		h.Write(output)
+		hashed += int64(len(output))
+	}
+
+	if artifact.ArtifactType() == ArtifactBuildx {
+		n, err := hashBuildContext(h, artifact, envPath)
+		if err != nil {
+			return "", nil, err
+		}
+		hashed += n
+	}
+
+	if artifact.nix.Enabled {
+		shellPath, err := cm.nixShellPath(artifact.nix, envPath)
+		if err != nil {
+			return "", nil, err
+		}
+		h.Write([]byte(shellPath))
+		hashed += int64(len(shellPath))
+	}
+
+	if hashed == 0 {
+		warnings = append(warnings, "cache key is computed from zero bytes, so every state of the environment shares it")
 	}
 
-	return hex.EncodeToString(h.Sum(nil))[:16], nil
+	return hex.EncodeToString(h.Sum(nil))[:16], warnings, nil
 }
 
 func (cm *CacheManager) GetArtifactCachePath(rootPath, artifactName, key string) string {
@@ -111,7 +248,7 @@ func (cm *CacheManager) PrepareArtifactCache(artifacts []ArtifactConfig, rootPat
 	var entries []ArtifactCacheEntry
 
 	for _, artifact := range artifacts {
-		key, err := cm.ComputeCacheKey(artifact, envPath)
+		key, warnings, err := cm.computeCacheKey(artifact, envPath)
 		if err != nil {
 			return nil, err
 		}
@@ -129,13 +266,36 @@ func (cm *CacheManager) PrepareArtifactCache(artifacts []ArtifactConfig, rootPat
 			Key:       key,
 			CachePath: cachePath,
 			EnvPaths:  envPaths,
+			EnvRoot:   envPath,
+			Strategy:  artifact.RestoreStrategy(),
+			Type:      artifact.ArtifactType(),
 			Hit:       hit,
+			Warnings:  warnings,
 		})
 	}
 
 	return entries, nil
 }
 
+func CheckCacheKeys(entries []ArtifactCacheEntry, strict bool, logger *FileLogger) error {
+	var problems []string
+	for _, entry := range entries {
+		for _, w := range entry.Warnings {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Name, w))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("suspicious cache keys (build.strict_keys is set):\n  %s", strings.Join(problems, "\n  "))
+	}
+	for _, p := range problems {
+		logger.Log("warning: %s", p)
+	}
+	return nil
+}
+
 func dirExists(path string) bool {
 	info, err := os.Stat(path)
 	return err == nil && info.IsDir()
@@ -243,14 +403,17 @@ type SeedOptions struct {
 	NumWorkers      int
 	OperationName   string
 	ProgressTimeout time.Duration // Abort if no progress for this duration (0 = 30s default)
-	FileTimeout     time.Duration // Timeout for individual file operations (0 = 10s default)
+	FileTimeout     time.Duration // Abort if a single file makes no progress for this duration (0 = 10s default)
+	Tracer          *Tracer
 }
 
-func copyDirectory(src, dst, artifactName string, logger *FileLogger, operation string) error {
+func (cm *CacheManager) copyDirectory(src, dst, artifactName string, logger *FileLogger, operation string) error {
 	return SeedDirectory(src, dst, SeedOptions{
 		ArtifactName:  artifactName,
 		Logger:        logger,
+		NumWorkers:    cm.Workers,
 		OperationName: operation,
+		Tracer:        cm.tracer,
 	})
 }
 
@@ -275,179 +438,322 @@ func countFiles(src string, artifactName string) (int64, error) {
 	return count, err
 }
 
+const (
+	defaultSeedWorkers         = 16
+	defaultSeedProgressTimeout = 30 * time.Second
+	defaultSeedFileTimeout     = 10 * time.Second
+	seedQueuePerWorker         = 64
+	minSeedWatchInterval       = 10 * time.Millisecond
+	maxSeedWatchInterval       = 5 * time.Second
+	copyChunkSize              = 4 << 20
+)
+
 type fileEntry struct {
 	srcPath string
 	dstPath string
 	relPath string
 	mode    fs.FileMode
+	size    int64
 }
 
 func SeedDirectory(src, dst string, opts SeedOptions) error {
+	operation := opts.OperationName
+	if operation == "" {
+		operation = "seeding"
+	}
+	span := opts.Tracer.Start("seed directory")
+	span.SetAttr("mono.artifact", opts.ArtifactName)
+	span.SetAttr("mono.operation", operation)
+	span.SetAttr("mono.source", src)
+	err := seedDirectory(src, dst, opts)
+	span.Fail(err)
+	span.End()
+	return err
+}
+
+type seedWorker struct {
+	mu      sync.Mutex
+	relPath string
+	started time.Time
+}
+
+func (w *seedWorker) begin(relPath string) {
+	w.mu.Lock()
+	w.relPath, w.started = relPath, time.Now()
+	w.mu.Unlock()
+}
+
+func (w *seedWorker) advance() {
+	w.mu.Lock()
+	w.started = time.Now()
+	w.mu.Unlock()
+}
+
+func (w *seedWorker) end() {
+	w.mu.Lock()
+	w.relPath, w.started = "", time.Time{}
+	w.mu.Unlock()
+}
+
+func (w *seedWorker) busy() (string, time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started.IsZero() {
+		return "", 0, false
+	}
+	return w.relPath, time.Since(w.started), true
+}
+
+type seedPool struct {
+	ctx             context.Context
+	cancel          context.CancelFunc
+	workers         []*seedWorker
+	fileTimeout     time.Duration
+	progressTimeout time.Duration
+	lastProgress    atomic.Int64
+	copyOnly        atomic.Bool
+	stalled         chan error
+}
+
+func newSeedPool(numWorkers int, fileTimeout, progressTimeout time.Duration) *seedPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &seedPool{
+		ctx:             ctx,
+		cancel:          cancel,
+		workers:         make([]*seedWorker, numWorkers),
+		fileTimeout:     fileTimeout,
+		progressTimeout: progressTimeout,
+		stalled:         make(chan error, 1),
+	}
+	for i := range p.workers {
+		p.workers[i] = &seedWorker{}
+	}
+	p.touch()
+	return p
+}
+
+func (p *seedPool) touch() {
+	p.lastProgress.Store(time.Now().UnixNano())
+}
+
+func (p *seedPool) watchInterval() time.Duration {
+	return min(max(min(p.fileTimeout, p.progressTimeout)/4, minSeedWatchInterval), maxSeedWatchInterval)
+}
+
+func (p *seedPool) watch(done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.watchInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := p.check(); err != nil {
+			p.stalled <- err
+			p.cancel()
+			return
+		}
+	}
+}
+
+func (p *seedPool) check() error {
+	for _, w := range p.workers {
+		if relPath, elapsed, ok := w.busy(); ok && elapsed > p.fileTimeout {
+			return fmt.Errorf("failed to link %s: timed out after %v without progress", relPath, p.fileTimeout)
+		}
+	}
+	if idle := time.Since(time.Unix(0, p.lastProgress.Load())); idle > p.progressTimeout {
+		return fmt.Errorf("seeding timed out: no progress for %v", p.progressTimeout)
+	}
+	return nil
+}
+
+func (p *seedPool) place(f fileEntry, onBytes func(int64)) error {
+	if f.mode&os.ModeSymlink != 0 {
+		return linkOrCopyFile(f.srcPath, f.dstPath)
+	}
+	if !p.copyOnly.Load() {
+		err := os.Link(f.srcPath, f.dstPath)
+		if err == nil || os.IsExist(err) {
+			return nil
+		}
+		if !isHardlinkNotSupported(err) {
+			return err
+		}
+		p.copyOnly.Store(true)
+	}
+	return copyFileContext(p.ctx, f.srcPath, f.dstPath, onBytes)
+}
+
+func seedDirectory(src, dst string, opts SeedOptions) error {
 	numWorkers := opts.NumWorkers
 	if numWorkers <= 0 {
-		numWorkers = 16 // Reduced from 16 to avoid APFS contention
+		numWorkers = defaultSeedWorkers
 	}
 
 	progressTimeout := opts.ProgressTimeout
 	if progressTimeout <= 0 {
-		progressTimeout = 30 * time.Second
+		progressTimeout = defaultSeedProgressTimeout
 	}
 
 	fileTimeout := opts.FileTimeout
 	if fileTimeout <= 0 {
-		fileTimeout = 10 * time.Second
+		fileTimeout = defaultSeedFileTimeout
 	}
 
-	var totalFiles int64
 	var progress *ProgressLogger
 	if opts.Logger != nil {
-		var err error
-		totalFiles, err = countFiles(src, opts.ArtifactName)
-		if err != nil {
-			return fmt.Errorf("failed to count files: %w", err)
-		}
 		operation := opts.OperationName
 		if operation == "" {
 			operation = "seeding"
 		}
-		progress = NewProgressLogger(opts.Logger, operation+" "+opts.ArtifactName, totalFiles)
-	}
-
-	var dirs []struct {
-		path string
-		mode fs.FileMode
+		progress = NewProgressLogger(opts.Logger, operation+" "+opts.ArtifactName, 0)
+		defer progress.Close()
 	}
-	var files []fileEntry
 
-	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	pool := newSeedPool(numWorkers, fileTimeout, progressTimeout)
+	watchDone := make(chan struct{})
+	go pool.watch(watchDone)
+	defer func() {
+		pool.cancel()
+		<-watchDone
+	}()
 
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
+	g, gctx := errgroup.WithContext(pool.ctx)
+	queue := make(chan fileEntry, numWorkers*seedQueuePerWorker)
 
-		if d.IsDir() {
-			if shouldSkipPath(relPath+"/", opts.ArtifactName) {
-				return filepath.SkipDir
-			}
-			info, err := d.Info()
+	g.Go(func() error {
+		defer close(queue)
+		err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
-			dirs = append(dirs, struct {
-				path string
-				mode fs.FileMode
-			}{filepath.Join(dst, relPath), info.Mode()})
-			return nil
-		}
-
-		if shouldSkipPath(relPath, opts.ArtifactName) {
-			return nil
-		}
-
-		info, err := d.Info()
-		if err != nil {
-			return err
-		}
-
-		files = append(files, fileEntry{
-			srcPath: path,
-			dstPath: filepath.Join(dst, relPath),
-			relPath: relPath,
-			mode:    info.Mode(),
-		})
-
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("failed to walk source directory: %w", err)
-	}
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			pool.touch()
 
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir.path, dir.mode); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir.path, err)
-		}
-	}
+			relPath, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
 
-	fileChan := make(chan fileEntry, len(files))
-	for _, f := range files {
-		fileChan <- f
-	}
-	close(fileChan)
+			if d.IsDir() {
+				if shouldSkipPath(relPath+"/", opts.ArtifactName) {
+					return filepath.SkipDir
+				}
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+				dirPath := filepath.Join(dst, relPath)
+				if err := os.MkdirAll(dirPath, info.Mode()); err != nil {
+					return fmt.Errorf("failed to create directory %s: %w", dirPath, err)
+				}
+				return nil
+			}
 
-	// Track progress for timeout detection
-	var lastProgress atomic.Int64
-	lastProgress.Store(time.Now().UnixNano())
+			if shouldSkipPath(relPath, opts.ArtifactName) {
+				return nil
+			}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if progress != nil {
+				progress.AddTotal(1, info.Size())
+			}
 
-	// Watchdog goroutine: cancel if no progress within timeout
-	watchdogDone := make(chan struct{})
-	go func() {
-		defer close(watchdogDone)
-		ticker := time.NewTicker(5 * time.Second)
-		defer ticker.Stop()
-		for {
 			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				lastTime := time.Unix(0, lastProgress.Load())
-				if time.Since(lastTime) > progressTimeout {
-					cancel()
-					return
-				}
+			case queue <- fileEntry{
+				srcPath: path,
+				dstPath: filepath.Join(dst, relPath),
+				relPath: relPath,
+				mode:    info.Mode(),
+				size:    info.Size(),
+			}:
+				return nil
+			case <-gctx.Done():
+				return gctx.Err()
 			}
+		})
+		if err != nil && gctx.Err() == nil {
+			return fmt.Errorf("failed to walk source directory: %w", err)
 		}
-	}()
-
-	g, gctx := errgroup.WithContext(ctx)
-
-	var once sync.Once
-	var firstErr error
+		if err == nil && progress != nil {
+			progress.TotalKnown()
+		}
+		return err
+	})
 
-	for i := 0; i < numWorkers; i++ {
+	for _, w := range pool.workers {
 		g.Go(func() error {
 			for {
+				var f fileEntry
 				select {
 				case <-gctx.Done():
 					return gctx.Err()
-				case f, ok := <-fileChan:
+				case next, ok := <-queue:
 					if !ok {
 						return nil
 					}
+					f = next
+				}
 
-					if err := linkOrCopyFileWithTimeout(f.srcPath, f.dstPath, fileTimeout); err != nil {
-						once.Do(func() {
-							firstErr = fmt.Errorf("failed to link %s: %w", f.relPath, err)
-						})
-						return firstErr
+				if progress != nil {
+					progress.SetCurrent(f.relPath)
+				}
+				w.begin(f.relPath)
+				var copied int64
+				err := pool.place(f, func(n int64) {
+					copied += n
+					w.advance()
+					pool.touch()
+					if progress != nil {
+						progress.AddBytes(n)
 					}
+				})
+				w.end()
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				if err != nil {
+					return fmt.Errorf("failed to link %s: %w", f.relPath, err)
+				}
 
-					// Update progress timestamp
-					lastProgress.Store(time.Now().UnixNano())
+				pool.touch()
 
-					if progress != nil {
-						progress.Increment()
-					}
+				if opts.Logger != nil {
+					opts.Logger.Debug("linked %s/%s", opts.ArtifactName, f.relPath)
+				}
+
+				if progress != nil {
+					progress.Increment(f.size - copied)
 				}
 			}
 		})
 	}
 
-	err = g.Wait()
-	cancel() // Stop watchdog
-	<-watchdogDone
+	waited := make(chan error, 1)
+	go func() {
+		waited <- g.Wait()
+	}()
 
-	if err != nil {
-		if err == context.Canceled {
-			return fmt.Errorf("seeding timed out: no progress for %v", progressTimeout)
-		}
+	select {
+	case err := <-pool.stalled:
 		return err
+	case err := <-waited:
+		select {
+		case stallErr := <-pool.stalled:
+			return stallErr
+		default:
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if progress != nil {
@@ -492,24 +798,11 @@ func linkOrCopyFile(src, dst string) error {
 	return nil
 }
 
-// linkOrCopyFileWithTimeout wraps linkOrCopyFile with a timeout.
-// If the operation doesn't complete within the timeout, it returns an error.
-// Note: the underlying goroutine may still be blocked, but we move on.
-func linkOrCopyFileWithTimeout(src, dst string, timeout time.Duration) error {
-	done := make(chan error, 1)
-	go func() {
-		done <- linkOrCopyFile(src, dst)
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(timeout):
-		return fmt.Errorf("operation timed out after %v", timeout)
-	}
+func copyFile(src, dst string) error {
+	return copyFileContext(context.Background(), src, dst, nil)
 }
 
-func copyFile(src, dst string) error {
+func copyFileContext(ctx context.Context, src, dst string, onBytes func(int64)) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -522,8 +815,20 @@ func copyFile(src, dst string) error {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, in); err != nil {
-		return err
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := io.CopyN(out, in, copyChunkSize)
+		if n > 0 && onBytes != nil {
+			onBytes(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	info, err := os.Stat(src)
@@ -534,18 +839,45 @@ func copyFile(src, dst string) error {
 }
 
 func (cm *CacheManager) RestoreFromCache(entry ArtifactCacheEntry, logger *FileLogger) error {
+	start := time.Now()
+	span := cm.tracer.Start("restore cache")
+	span.SetAttr("mono.artifact", entry.Name)
+	span.SetAttr("mono.cache_key", entry.Key)
+	span.SetAttr("mono.strategy", entry.Strategy)
+	err := cm.restoreFromCache(entry, logger)
+	span.Fail(err)
+	span.End()
+	return errors.Join(err, cm.recordCacheEvent(EventRestore, entry.CachePath, entry.EnvRoot, start, err))
+}
+
+func (cm *CacheManager) restoreFromCache(entry ArtifactCacheEntry, logger *FileLogger) error {
 	for _, envPath := range entry.EnvPaths {
 		srcPath := filepath.Join(entry.CachePath, filepath.Base(envPath))
 		if !dirExists(srcPath) {
 			srcPath = filepath.Join(entry.CachePath, entry.Name)
 		}
 
-		if err := os.RemoveAll(envPath); err != nil {
+		if err := removeAllAudited(envPath); err != nil {
 			return fmt.Errorf("failed to remove existing %s: %w", envPath, err)
 		}
 
-		if err := copyDirectory(srcPath, envPath, entry.Name, logger, "restoring"); err != nil {
-			return fmt.Errorf("failed to restore cache for %s: %w", entry.Name, err)
+		switch entry.Strategy {
+		case RestoreShared:
+			if err := os.MkdirAll(filepath.Dir(envPath), 0755); err != nil {
+				return fmt.Errorf("failed to create parent of %s: %w", envPath, err)
+			}
+			if err := linkSharedCache(srcPath, envPath); err != nil {
+				return fmt.Errorf("failed to link cache for %s: %w", entry.Name, err)
+			}
+			continue
+		case RestoreCopy:
+			if err := copyDir(srcPath, envPath); err != nil {
+				return fmt.Errorf("failed to restore cache for %s: %w", entry.Name, err)
+			}
+		default:
+			if err := cm.copyDirectory(srcPath, envPath, entry.Name, logger, "restoring"); err != nil {
+				return fmt.Errorf("failed to restore cache for %s: %w", entry.Name, err)
+			}
 		}
 
 		if err := cm.ApplyPostRestoreFixes(entry.Name, envPath); err != nil {
@@ -694,31 +1026,56 @@ func (cm *CacheManager) cleanNodeModulesBin(nodeModulesDir string) error {
 }
 
 func (cm *CacheManager) StoreToCache(entry ArtifactCacheEntry) error {
+	start := time.Now()
 	if err := os.MkdirAll(entry.CachePath, 0755); err != nil {
 		return fmt.Errorf("failed to create cache dir: %w", err)
 	}
 
 	for _, envPath := range entry.EnvPaths {
-		if !dirExists(envPath) {
+		if !dirExists(envPath) || isSymlink(envPath) {
 			continue
 		}
 
 		cacheDst := filepath.Join(entry.CachePath, filepath.Base(envPath))
 
+		if entry.Strategy == RestoreCopy {
+			if err := copyDir(envPath, cacheDst); err != nil {
+				return fmt.Errorf("failed to copy %s to cache: %w", envPath, err)
+			}
+			continue
+		}
+
 		if err := os.Rename(envPath, cacheDst); err != nil {
 			return fmt.Errorf("failed to move %s to cache: %w", envPath, err)
 		}
 
-		if err := HardlinkTree(cacheDst, envPath); err != nil {
-			return fmt.Errorf("failed to hardlink back from cache: %w", err)
+		if err := linkBackFromCache(entry.Strategy, cacheDst, envPath); err != nil {
+			return err
 		}
 	}
 
+	if err := writeDedupedCacheManifest(entry.CachePath, entry.Name, entry.Key, entry.EnvRoot); err != nil {
+		return err
+	}
+	return cm.recordCacheEvent(EventStore, entry.CachePath, entry.EnvRoot, start, nil)
+}
+
+func linkBackFromCache(strategy, cacheDst, envPath string) error {
+	if strategy == RestoreShared {
+		if err := linkSharedCache(cacheDst, envPath); err != nil {
+			return fmt.Errorf("failed to link %s to cache: %w", envPath, err)
+		}
+		return nil
+	}
+	if err := HardlinkTree(cacheDst, envPath); err != nil {
+		return fmt.Errorf("failed to hardlink back from cache: %w", err)
+	}
 	return nil
 }
 
 type SyncOptions struct {
 	HardlinkBack bool
+	Timer        *PhaseTimer
 }
 
 func (cm *CacheManager) acquireCacheLock(cachePath string) (*os.File, error) {
@@ -748,9 +1105,22 @@ func (cm *CacheManager) releaseCacheLock(f *os.File) {
 	}
 }
 
-func (cm *CacheManager) Sync(artifacts []ArtifactConfig, rootPath, envPath string, opts SyncOptions) error {
+func (cm *CacheManager) Sync(artifacts []ArtifactConfig, rootPath, envPath string, opts SyncOptions) (err error) {
+	marker, err := StartActivity(ActivitySync, "", envPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, marker.Done())
+	}()
 	for _, artifact := range artifacts {
-		if err := cm.syncArtifact(artifact, rootPath, envPath, opts); err != nil {
+		done := func() {}
+		if opts.Timer != nil {
+			done = opts.Timer.Start("sync " + artifact.Name)
+		}
+		err := cm.syncArtifact(artifact, rootPath, envPath, opts)
+		done()
+		if err != nil {
 			return err
 		}
 	}
@@ -774,49 +1144,90 @@ type CargoProcessInfo struct {
 }
 
 // DetectRunningCargoProcesses checks if any cargo/rustc processes are running
-// that reference the given project path. Returns a list of matching processes.
+// whose working directory or arguments are inside the given project path.
 func DetectRunningCargoProcesses(projectPath string) ([]CargoProcessInfo, error) {
-	// Use ps to get all cargo and rustc processes with their full command lines
-	cmd := exec.Command("ps", "-eo", "pid,command")
-	output, err := cmd.Output()
+	roots := []string{projectPath}
+	resolved, err := filepath.EvalSymlinks(projectPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to resolve %s: %w", projectPath, err)
+	}
+	if err == nil && resolved != projectPath {
+		roots = append(roots, resolved)
+	}
+
+	procs, err := process.Processes()
 	if err != nil {
-		return nil, fmt.Errorf("failed to run ps: %w", err)
+		return nil, fmt.Errorf("failed to list processes: %w", err)
 	}
 
 	var processes []CargoProcessInfo
-	lines := strings.Split(string(output), "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, p := range procs {
+		if p.Pid == int32(os.Getpid()) {
 			continue
 		}
-
-		// Check if this is a cargo or rustc process
-		if !strings.Contains(line, "cargo") && !strings.Contains(line, "rustc") {
+		name, err := p.Name()
+		if err != nil {
 			continue
 		}
-
-		// Check if it references our project path
-		if !strings.Contains(line, projectPath) {
+		args, err := p.CmdlineSlice()
+		if err != nil {
 			continue
 		}
-
-		// Parse PID (first field)
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
+		if !isCargoProcess(name, args) {
+			continue
+		}
+		cwd, err := p.Cwd()
+		if err != nil {
+			cwd = ""
+		}
+		if !processInProject(roots, cwd, args) {
 			continue
 		}
-
 		processes = append(processes, CargoProcessInfo{
-			PID:     fields[0],
-			Command: strings.Join(fields[1:], " "),
+			PID:     strconv.Itoa(int(p.Pid)),
+			Command: strings.Join(args, " "),
 		})
 	}
 
 	return processes, nil
 }
 
+func isCargoProcess(name string, args []string) bool {
+	names := []string{name}
+	if len(args) > 0 {
+		names = append(names, filepath.Base(args[0]))
+	}
+	for _, n := range names {
+		if n == "cargo" || n == "rustc" || strings.HasPrefix(n, "cargo-") {
+			return true
+		}
+	}
+	return false
+}
+
+func processInProject(roots []string, cwd string, args []string) bool {
+	paths := []string{cwd}
+	for _, arg := range args {
+		if _, value, ok := strings.Cut(arg, "="); ok {
+			arg = value
+		}
+		if filepath.IsAbs(arg) {
+			paths = append(paths, arg)
+		}
+	}
+	for _, root := range roots {
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if _, inside := relativeTo(root, p); inside {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // CheckCargoBuildConflicts checks for any conditions that would cause cargo build
 // to block: either a .cargo-lock file or running cargo/rustc processes.
 // Returns a descriptive error if a conflict is found, nil otherwise.
@@ -856,28 +1267,52 @@ func (cm *CacheManager) syncArtifact(artifact ArtifactConfig, rootPath, envPath
 		return fmt.Errorf("failed to compute cache key for %s: %w", artifact.Name, err)
 	}
 
+	if artifact.Crates {
+		if _, err := cm.storeCrates(artifact, key, rootPath, envPath); err != nil {
+			return fmt.Errorf("failed to sync crates of %s: %w", artifact.Name, err)
+		}
+	}
+
 	cachePath := cm.GetArtifactCachePath(rootPath, artifact.Name, key)
 
 	if dirExists(cachePath) {
 		return nil
 	}
 
+	start := time.Now()
 	for _, p := range artifact.Paths {
 		localPath := filepath.Join(envPath, p)
 
 		if !dirExists(localPath) {
 			continue
 		}
+		if isSymlink(localPath) {
+			if err := cm.storeSharedLink(localPath, cachePath, opts.HardlinkBack); err != nil {
+				return fmt.Errorf("failed to sync %s: %w", artifact.Name, err)
+			}
+			continue
+		}
 
-		if err := cm.moveToCache(localPath, cachePath, opts.HardlinkBack); err != nil {
+		if err := cm.moveToCache(localPath, cachePath, artifact.RestoreStrategy(), opts.HardlinkBack); err != nil {
 			return fmt.Errorf("failed to sync %s: %w", artifact.Name, err)
 		}
 	}
 
-	return nil
+	if !dirExists(cachePath) {
+		return nil
+	}
+	if err := writeDedupedCacheManifest(cachePath, artifact.Name, key, envPath); err != nil {
+		return err
+	}
+	return cm.recordCacheEvent(EventStore, cachePath, envPath, start, nil)
 }
 
-func (cm *CacheManager) moveToCache(localPath, cachePath string, hardlinkBack bool) error {
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+func (cm *CacheManager) moveToCache(localPath, cachePath, strategy string, hardlinkBack bool) error {
 	lock, err := cm.acquireCacheLock(cachePath)
 	if err != nil {
 		return err
@@ -897,6 +1332,10 @@ func (cm *CacheManager) moveToCache(localPath, cachePath string, hardlinkBack bo
 		return err
 	}
 
+	if strategy == RestoreCopy && hardlinkBack {
+		return copyDir(localPath, targetInCache)
+	}
+
 	if err := os.Rename(localPath, targetInCache); err != nil {
 		if isCrossDevice(err) {
 			return cm.copyToCache(localPath, targetInCache, hardlinkBack)
@@ -905,7 +1344,7 @@ func (cm *CacheManager) moveToCache(localPath, cachePath string, hardlinkBack bo
 	}
 
 	if hardlinkBack {
-		if err := HardlinkTree(targetInCache, localPath); err != nil {
+		if err := linkBackFromCache(strategy, targetInCache, localPath); err != nil {
 			recoverErr := os.Rename(targetInCache, localPath)
 			cleanupErr := os.RemoveAll(cachePath)
 			if recoverErr != nil {
@@ -930,7 +1369,7 @@ func (cm *CacheManager) copyToCache(localPath, targetInCache string, hardlinkBac
 		return nil
 	}
 
-	return os.RemoveAll(localPath)
+	return removeAllAudited(localPath)
 }
 
 func isCrossDevice(err error) bool {
@@ -987,6 +1426,20 @@ func (cm *CacheManager) seedArtifactFromRoot(artifact ArtifactConfig, rootPath,
 		return nil
 	}
 
+	if artifact.Crates && !cm.isBuildInProgress(rootPath, artifact) {
+		rootKey, err := cm.ComputeCacheKey(artifact, rootPath)
+		if err != nil {
+			return fmt.Errorf("failed to compute cache key for root %s: %w", artifact.Name, err)
+		}
+		seeded, err := cm.storeCrates(artifact, rootKey, rootPath, rootPath)
+		if err != nil {
+			return fmt.Errorf("failed to seed crates of %s from root: %w", artifact.Name, err)
+		}
+		if len(seeded) > 0 && logger != nil {
+			logger.Log("seeded %d crate(s) of %s from root: %s", len(seeded), artifact.Name, strings.Join(seeded, ", "))
+		}
+	}
+
 	envKey, err := cm.ComputeCacheKey(artifact, envPath)
 	if err != nil {
 		return fmt.Errorf("failed to compute cache key for env %s: %w", artifact.Name, err)
@@ -1010,6 +1463,7 @@ func (cm *CacheManager) seedArtifactFromRoot(artifact ArtifactConfig, rootPath,
 		return nil
 	}
 
+	start := time.Now()
 	for _, p := range artifact.Paths {
 		rootArtifact := filepath.Join(rootPath, p)
 		if !dirExists(rootArtifact) {
@@ -1021,7 +1475,13 @@ func (cm *CacheManager) seedArtifactFromRoot(artifact ArtifactConfig, rootPath,
 		}
 	}
 
-	return nil
+	if !dirExists(cachePath) {
+		return nil
+	}
+	if err := writeDedupedCacheManifest(cachePath, artifact.Name, envKey, rootPath); err != nil {
+		return err
+	}
+	return cm.recordCacheEvent(EventStore, cachePath, rootPath, start, nil)
 }
 
 func (cm *CacheManager) seedToCache(sourcePath, cachePath, artifactName string, logger *FileLogger) error {
@@ -1038,6 +1498,8 @@ func (cm *CacheManager) seedToCache(sourcePath, cachePath, artifactName string,
 	err := SeedDirectory(sourcePath, targetInCache, SeedOptions{
 		ArtifactName: artifactName,
 		Logger:       logger,
+		NumWorkers:   cm.Workers,
+		Tracer:       cm.tracer,
 	})
 	if err != nil {
 		os.RemoveAll(targetInCache)
@@ -1054,6 +1516,58 @@ type CacheSizeEntry struct {
 }
 
 func (cm *CacheManager) GetCacheSizes() ([]CacheSizeEntry, error) {
+	return cm.GetCacheSizesContext(context.Background())
+}
+
+func (cm *CacheManager) GetCacheSizesContext(ctx context.Context) ([]CacheSizeEntry, error) {
+	entries, err := cm.listCacheEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	workers := cm.Workers
+	if workers <= 0 {
+		workers = defaultSeedWorkers
+	}
+	found := make([]bool, len(entries))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i := range entries {
+		g.Go(func() error {
+			keyPath := filepath.Join(cm.LocalCacheDir, entries[i].ProjectID, entries[i].Artifact, entries[i].CacheKey)
+			size, err := cachedEntrySize(gctx, keyPath)
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if errors.Is(err, errInvalidCacheManifest) {
+				fmt.Fprintf(os.Stderr, "warning: skipping cache entry: %v\n", err)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to measure cache entry %s: %w", keyPath, err)
+			}
+			entries[i].Size = size
+			found[i] = true
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("cache size scan interrupted: %w", ctxErr)
+		}
+		return nil, err
+	}
+
+	sizes := make([]CacheSizeEntry, 0, len(entries))
+	for i, e := range entries {
+		if found[i] {
+			sizes = append(sizes, e)
+		}
+	}
+	return sizes, nil
+}
+
+func (cm *CacheManager) listCacheEntries() ([]CacheSizeEntry, error) {
 	var entries []CacheSizeEntry
 
 	if !dirExists(cm.LocalCacheDir) {
@@ -1082,9 +1596,8 @@ func (cm *CacheManager) GetCacheSizes() ([]CacheSizeEntry, error) {
 				continue
 			}
 			artifact := artifactDir.Name()
-			artifactPath := filepath.Join(projectPath, artifact)
 
-			keyDirs, err := os.ReadDir(artifactPath)
+			keyDirs, err := os.ReadDir(filepath.Join(projectPath, artifact))
 			if err != nil {
 				continue
 			}
@@ -1093,19 +1606,10 @@ func (cm *CacheManager) GetCacheSizes() ([]CacheSizeEntry, error) {
 				if !keyDir.IsDir() {
 					continue
 				}
-				cacheKey := keyDir.Name()
-				keyPath := filepath.Join(artifactPath, cacheKey)
-
-				size, err := cm.calculateDirSize(keyPath)
-				if err != nil {
-					continue
-				}
-
 				entries = append(entries, CacheSizeEntry{
 					ProjectID: projectID,
 					Artifact:  artifact,
-					CacheKey:  cacheKey,
-					Size:      size,
+					CacheKey:  keyDir.Name(),
 				})
 			}
 		}
@@ -1114,12 +1618,19 @@ func (cm *CacheManager) GetCacheSizes() ([]CacheSizeEntry, error) {
 	return entries, nil
 }
 
-func (cm *CacheManager) calculateDirSize(path string) (int64, error) {
+func dirSize(path string) (int64, error) {
+	return dirSizeContext(context.Background(), path)
+}
+
+func dirSizeContext(ctx context.Context, path string) (int64, error) {
 	var size int64
 	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -1134,15 +1645,31 @@ func (cm *CacheManager) calculateDirSize(path string) (int64, error) {
 }
 
 func (cm *CacheManager) RemoveCacheEntry(projectID, artifact, cacheKey string) error {
+	start := time.Now()
 	path := filepath.Join(cm.LocalCacheDir, projectID, artifact, cacheKey)
+	if err := checkCacheEntryUnused(path); err != nil {
+		return err
+	}
+	size, err := cacheEntrySize(path)
+	if err != nil {
+		return err
+	}
 	if err := os.RemoveAll(path); err != nil {
-		return fmt.Errorf("failed to remove cache entry: %w", err)
+		return errors.Join(fmt.Errorf("failed to remove cache entry: %w", err), audit(AuditEvict, path, "", size, err))
+	}
+	if err := audit(AuditEvict, path, "", size, nil); err != nil {
+		return err
+	}
+	if err := os.Remove(sharedRefsPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove shared cache users: %w", err)
 	}
 
 	cm.cleanEmptyParentDirs(filepath.Join(cm.LocalCacheDir, projectID, artifact))
 	cm.cleanEmptyParentDirs(filepath.Join(cm.LocalCacheDir, projectID))
 
-	return nil
+	e := newEvent(EventEvict, start, nil)
+	e.Project, e.Artifact, e.Key, e.Size = projectID, artifact, cacheKey, size
+	return RecordEvent(e)
 }
 
 func (cm *CacheManager) cleanEmptyParentDirs(path string) {
@@ -1163,11 +1690,25 @@ func (cm *CacheManager) RemoveAllCache() (int, int64, error) {
 
 	var totalSize int64
 	for _, entry := range entries {
+		if err := checkCacheEntryUnused(filepath.Join(cm.LocalCacheDir, entry.ProjectID, entry.Artifact, entry.CacheKey)); err != nil {
+			return 0, 0, fmt.Errorf("%w; destroy that environment or remove the link first", err)
+		}
 		totalSize += entry.Size
 	}
 
+	start := time.Now()
 	if err := os.RemoveAll(cm.LocalCacheDir); err != nil {
-		return 0, 0, fmt.Errorf("failed to remove cache directory: %w", err)
+		return 0, 0, errors.Join(fmt.Errorf("failed to remove cache directory: %w", err), audit(AuditEvict, cm.LocalCacheDir, "", totalSize, err))
+	}
+	for _, entry := range entries {
+		if err := audit(AuditEvict, filepath.Join(cm.LocalCacheDir, entry.ProjectID, entry.Artifact, entry.CacheKey), "", entry.Size, nil); err != nil {
+			return 0, 0, err
+		}
+		e := newEvent(EventEvict, start, nil)
+		e.Project, e.Artifact, e.Key, e.Size = entry.ProjectID, entry.Artifact, entry.CacheKey, entry.Size
+		if err := RecordEvent(e); err != nil {
+			return 0, 0, err
+		}
 	}
 
 	return len(entries), totalSize, nil
@@ -0,0 +1,213 @@
+package mono
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const cacheHashesFile = "hashes.json"
+
+type cachedFileHash struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Inode   uint64 `json:"inode"`
+	Hash    string `json:"sha256,omitempty"`
+}
+
+func newCachedFileHash(info fs.FileInfo) cachedFileHash {
+	entry := cachedFileHash{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Inode = uint64(st.Ino)
+	}
+	return entry
+}
+
+func (h cachedFileHash) unchanged(info fs.FileInfo) bool {
+	cur := newCachedFileHash(info)
+	return h.Hash != "" && h.Size == cur.Size && h.ModTime == cur.ModTime && h.Inode == cur.Inode
+}
+
+func readCacheHashes(cachePath string) (map[string]cachedFileHash, error) {
+	data, err := os.ReadFile(filepath.Join(cachePath, cacheHashesFile))
+	if os.IsNotExist(err) {
+		return map[string]cachedFileHash{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file hashes of %s: %w", cachePath, err)
+	}
+	var hashes map[string]cachedFileHash
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("invalid file hashes in %s: %w", cachePath, err)
+	}
+	return hashes, nil
+}
+
+func writeCacheHashes(cachePath string, hashes map[string]cachedFileHash) error {
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return fmt.Errorf("failed to encode file hashes: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cachePath, cacheHashesFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write file hashes: %w", err)
+	}
+	return nil
+}
+
+func previousCacheEntry(cachePath string) (string, error) {
+	artifactDir := filepath.Dir(cachePath)
+	keyDirs, err := os.ReadDir(artifactDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var prev string
+	var latest *CacheManifest
+	for _, keyDir := range keyDirs {
+		path := filepath.Join(artifactDir, keyDir.Name())
+		if !keyDir.IsDir() || path == cachePath {
+			continue
+		}
+		m, err := ReadCacheManifest(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if latest == nil || m.CreatedAt.After(latest.CreatedAt) {
+			prev, latest = path, m
+		}
+	}
+	return prev, nil
+}
+
+func sha256File(path string) (string, error) {
+	h := sha256.New()
+	if _, err := hashFile(h, path); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func replaceWithLink(src, dst string) error {
+	tmp := dst + ".mono-link"
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		if removeErr := os.Remove(tmp); removeErr != nil {
+			return fmt.Errorf("failed to replace %s: %w (cleanup error: %v)", dst, err, removeErr)
+		}
+		return fmt.Errorf("failed to replace %s: %w", dst, err)
+	}
+	return nil
+}
+
+type cacheDeduper struct {
+	prev       string
+	prevHashes map[string]cachedFileHash
+	linking    bool
+}
+
+func (d *cacheDeduper) file(path, rel string, info fs.FileInfo) (cachedFileHash, bool, error) {
+	entry := newCachedFileHash(info)
+	if d.prev == "" {
+		return entry, false, nil
+	}
+	prevPath := filepath.Join(d.prev, filepath.FromSlash(rel))
+	prevInfo, err := os.Lstat(prevPath)
+	if os.IsNotExist(err) {
+		return entry, false, nil
+	}
+	if err != nil {
+		return entry, false, err
+	}
+	if !prevInfo.Mode().IsRegular() || prevInfo.Size() != info.Size() || prevInfo.Mode().Perm() != info.Mode().Perm() || !prevInfo.ModTime().Equal(info.ModTime()) {
+		return entry, false, nil
+	}
+	known := d.prevHashes[rel]
+	if os.SameFile(info, prevInfo) {
+		if known.unchanged(prevInfo) {
+			entry.Hash = known.Hash
+		}
+		return entry, true, nil
+	}
+
+	if entry.Hash, err = sha256File(path); err != nil {
+		return entry, false, err
+	}
+	prevHash := known.Hash
+	if !known.unchanged(prevInfo) {
+		if prevHash, err = sha256File(prevPath); err != nil {
+			return entry, false, err
+		}
+	}
+	if prevHash != entry.Hash || !d.linking {
+		return entry, false, nil
+	}
+	if err := replaceWithLink(prevPath, path); err != nil {
+		if isHardlinkNotSupported(err) {
+			d.linking = false
+			return entry, false, nil
+		}
+		return entry, false, fmt.Errorf("failed to link %s to %s: %w", rel, d.prev, err)
+	}
+	linked := newCachedFileHash(prevInfo)
+	linked.Hash = entry.Hash
+	return linked, true, nil
+}
+
+func dedupeCacheEntry(cachePath string) (map[string]cachedFileHash, int64, error) {
+	prev, err := previousCacheEntry(cachePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	d := &cacheDeduper{prev: prev, prevHashes: map[string]cachedFileHash{}, linking: true}
+	if prev != "" {
+		if d.prevHashes, err = readCacheHashes(prev); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	hashes := make(map[string]cachedFileHash)
+	var shared int64
+	err = filepath.WalkDir(cachePath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(cachePath, path)
+		if err != nil {
+			return err
+		}
+		if rel == cacheManifestFile || rel == cacheHashesFile {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		hash, linked, err := d.file(path, rel, info)
+		if err != nil {
+			return err
+		}
+		hashes[rel] = hash
+		if linked {
+			shared += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to deduplicate cache entry %s: %w", cachePath, err)
+	}
+	return hashes, shared, nil
+}
@@ -0,0 +1,179 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDedupeCacheEntry(t *testing.T) {
+	artifactDir := filepath.Join(t.TempDir(), "project", "cargo")
+	source := t.TempDir()
+	oldKey := filepath.Join(artifactDir, "old")
+	writeBuildxFixture(t, oldKey, map[string]string{
+		"target/debug/deps/libserde.rlib": "serde",
+		"target/debug/app":                "app v1",
+		"target/debug/removed.d":          "gone",
+	})
+	if err := writeDedupedCacheManifest(oldKey, "cargo", "old", source); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := filepath.Join(artifactDir, "new")
+	writeBuildxFixture(t, newKey, map[string]string{
+		"target/debug/deps/libserde.rlib": "serde",
+		"target/debug/app":                "app v2",
+		"target/debug/added.d":            "new",
+	})
+	if err := os.Link(filepath.Join(oldKey, "target/debug/removed.d"), filepath.Join(newKey, "target/debug/removed.d")); err != nil {
+		t.Fatal(err)
+	}
+	syncModTime(t, oldKey, newKey, "target/debug/deps/libserde.rlib")
+	syncModTime(t, oldKey, newKey, "target/debug/app")
+	time.Sleep(10 * time.Millisecond)
+	if err := writeDedupedCacheManifest(newKey, "cargo", "new", source); err != nil {
+		t.Fatal(err)
+	}
+
+	if !sameCacheFile(t, oldKey, newKey, "target/debug/deps/libserde.rlib") {
+		t.Error("unchanged file was not linked to the previous key")
+	}
+	if sameCacheFile(t, oldKey, newKey, "target/debug/app") {
+		t.Error("changed file was linked to the previous key")
+	}
+	data, err := os.ReadFile(filepath.Join(newKey, "target/debug/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "app v2" {
+		t.Errorf("changed file = %q, want the new content", data)
+	}
+
+	m, err := ReadCacheManifest(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len("serde") + len("gone")); m.Shared != want {
+		t.Errorf("manifest shared = %d, want %d", m.Shared, want)
+	}
+	if want := int64(len("serde") + len("app v2") + len("new") + len("gone")); m.Size != want {
+		t.Errorf("manifest size = %d, want %d without the hash file", m.Size, want)
+	}
+
+	hashes, err := readCacheHashes(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 4 {
+		t.Errorf("hashes = %v, want one per file", hashes)
+	}
+	oldHashes, err := readCacheHashes(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, old := hashes["target/debug/deps/libserde.rlib"], oldHashes["target/debug/deps/libserde.rlib"]; h.Hash == "" || h.Size != old.Size || h.ModTime != old.ModTime || h.Inode != old.Inode {
+		t.Errorf("hash of linked file = %+v, want the previous key's file %+v", h, old)
+	}
+	if h := hashes["target/debug/added.d"]; h.Hash != "" {
+		t.Errorf("hash of a file without a previous version = %+v, want it left for later", h)
+	}
+}
+
+func TestDedupeCacheEntryWithoutPreviousKey(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "project", "cargo", "only")
+	writeBuildxFixture(t, cachePath, map[string]string{"target/debug/app": "app"})
+
+	hashes, shared, err := dedupeCacheEntry(cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared != 0 || len(hashes) != 1 {
+		t.Errorf("dedupeCacheEntry() = %v, %d, want one file and nothing shared", hashes, shared)
+	}
+}
+
+func syncModTime(t *testing.T, from, to, rel string) {
+	t.Helper()
+	info, err := os.Stat(filepath.Join(from, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(to, rel), info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sameCacheFile(t *testing.T, oldKey, newKey, rel string) bool {
+	t.Helper()
+	a, err := os.Stat(filepath.Join(oldKey, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(newKey, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(a, b)
+}
+
+func TestDedupeCacheEntryKeepsModTime(t *testing.T) {
+	artifactDir := filepath.Join(t.TempDir(), "project", "go")
+	source := t.TempDir()
+	oldKey := filepath.Join(artifactDir, "old")
+	writeBuildxFixture(t, oldKey, map[string]string{"pkg/lib.a": "lib"})
+	if err := writeDedupedCacheManifest(oldKey, "go", "old", source); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := filepath.Join(artifactDir, "new")
+	writeBuildxFixture(t, newKey, map[string]string{"pkg/lib.a": "lib"})
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filepath.Join(newKey, "pkg/lib.a"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDedupedCacheManifest(newKey, "go", "new", source); err != nil {
+		t.Fatal(err)
+	}
+
+	if sameCacheFile(t, oldKey, newKey, "pkg/lib.a") {
+		t.Error("file with a different mtime was linked to the previous key")
+	}
+	info, err := os.Stat(filepath.Join(newKey, "pkg/lib.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+}
+
+func TestDedupeCacheEntryRehashesEditedPrevious(t *testing.T) {
+	artifactDir := filepath.Join(t.TempDir(), "project", "cargo")
+	source := t.TempDir()
+	oldKey := filepath.Join(artifactDir, "old")
+	writeBuildxFixture(t, oldKey, map[string]string{"target/debug/app": "app v1"})
+	if err := writeDedupedCacheManifest(oldKey, "cargo", "old", source); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	writeBuildxFixture(t, oldKey, map[string]string{"target/debug/app": "app v2"})
+
+	newKey := filepath.Join(artifactDir, "new")
+	writeBuildxFixture(t, newKey, map[string]string{"target/debug/app": "app v1"})
+	syncModTime(t, oldKey, newKey, "target/debug/app")
+	if err := writeDedupedCacheManifest(newKey, "cargo", "new", source); err != nil {
+		t.Fatal(err)
+	}
+
+	if sameCacheFile(t, oldKey, newKey, "target/debug/app") {
+		t.Error("file was linked using the previous key's stale hash")
+	}
+	data, err := os.ReadFile(filepath.Join(newKey, "target/debug/app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "app v1" {
+		t.Errorf("app = %q, want the new key's content", data)
+	}
+}
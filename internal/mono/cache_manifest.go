@@ -0,0 +1,188 @@
+package mono
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const cacheManifestFile = "manifest.json"
+
+var errInvalidCacheManifest = errors.New("invalid cache manifest")
+
+type CacheManifest struct {
+	Artifact  string    `json:"artifact"`
+	Key       string    `json:"key"`
+	Branch    string    `json:"branch,omitempty"`
+	Commit    string    `json:"commit,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	Shared    int64     `json:"shared,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func WriteCacheManifest(cachePath, artifact, key, sourceDir string) error {
+	m, err := newCacheManifest(cachePath, artifact, key, sourceDir)
+	if err != nil {
+		return err
+	}
+	return writeCacheManifest(cachePath, m)
+}
+
+func writeDedupedCacheManifest(cachePath, artifact, key, sourceDir string) error {
+	hashes, shared, err := dedupeCacheEntry(cachePath)
+	if err != nil {
+		return err
+	}
+	m, err := newCacheManifest(cachePath, artifact, key, sourceDir)
+	if err != nil {
+		return err
+	}
+	m.Shared = shared
+	if err := writeCacheHashes(cachePath, hashes); err != nil {
+		return err
+	}
+	return writeCacheManifest(cachePath, m)
+}
+
+func newCacheManifest(cachePath, artifact, key, sourceDir string) (CacheManifest, error) {
+	m := CacheManifest{
+		Artifact:  artifact,
+		Key:       key,
+		CreatedAt: time.Now().UTC(),
+	}
+	size, err := dirSize(cachePath)
+	if err != nil {
+		return m, fmt.Errorf("failed to measure cache entry %s: %w", cachePath, err)
+	}
+	m.Size = size
+	if GitRefExists(sourceDir, "HEAD") {
+		commit, branch, err := GitHead(sourceDir)
+		if err != nil {
+			return m, err
+		}
+		m.Commit = commit
+		m.Branch = branch
+	}
+	return m, nil
+}
+
+func writeCacheManifest(cachePath string, m CacheManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cache manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(cachePath, cacheManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache manifest: %w", err)
+	}
+	return nil
+}
+
+func cachedEntrySize(ctx context.Context, cachePath string) (int64, error) {
+	m, err := ReadCacheManifest(cachePath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if m != nil && m.Size > 0 {
+		return m.Size, nil
+	}
+	return dirSizeContext(ctx, cachePath)
+}
+
+func ReadCacheManifest(cachePath string) (*CacheManifest, error) {
+	data, err := os.ReadFile(filepath.Join(cachePath, cacheManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m CacheManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w in %s: %w", errInvalidCacheManifest, cachePath, err)
+	}
+	return &m, nil
+}
+
+func (cm *CacheManager) FindApproximateEntry(artifact ArtifactConfig, rootPath, envPath string) (*ArtifactCacheEntry, *CacheManifest, error) {
+	artifactDir := filepath.Join(cm.GetProjectCacheDir(rootPath), artifact.Name)
+	keyDirs, err := os.ReadDir(artifactDir)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var manifests []*CacheManifest
+	for _, keyDir := range keyDirs {
+		if !keyDir.IsDir() {
+			continue
+		}
+		m, err := ReadCacheManifest(filepath.Join(artifactDir, keyDir.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if m.Commit == "" {
+			continue
+		}
+		m.Key = keyDir.Name()
+		manifests = append(manifests, m)
+	}
+	if len(manifests) == 0 {
+		return nil, nil, nil
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+
+	var best *CacheManifest
+	bestDistance := -1
+	for _, m := range manifests {
+		if !GitIsAncestor(envPath, m.Commit, "HEAD") {
+			continue
+		}
+		distance, err := GitCommitDistance(envPath, m.Commit, "HEAD")
+		if err != nil {
+			return nil, nil, err
+		}
+		if best == nil || distance < bestDistance {
+			best = m
+			bestDistance = distance
+		}
+	}
+
+	if best == nil {
+		defaultBranch := GitDefaultBranch(envPath)
+		for _, m := range manifests {
+			if m.Branch == defaultBranch {
+				best = m
+				break
+			}
+		}
+	}
+
+	if best == nil {
+		return nil, nil, nil
+	}
+
+	var envPaths []string
+	for _, p := range artifact.Paths {
+		envPaths = append(envPaths, filepath.Join(envPath, p))
+	}
+
+	return &ArtifactCacheEntry{
+		Name:      artifact.Name,
+		Key:       best.Key,
+		CachePath: filepath.Join(artifactDir, best.Key),
+		EnvPaths:  envPaths,
+		EnvRoot:   envPath,
+		Strategy:  artifact.RestoreStrategy(),
+		Type:      artifact.ArtifactType(),
+	}, best, nil
+}
@@ -0,0 +1,167 @@
+package mono
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultCacheReportDays = 7
+	cacheReportDayLayout   = "2006-01-02"
+)
+
+type CacheReportDay struct {
+	Day    string `json:"day"`
+	Hits   int    `json:"hits"`
+	Misses int    `json:"misses"`
+}
+
+type CacheReportRow struct {
+	ProjectID     string           `json:"project_id"`
+	Artifact      string           `json:"artifact"`
+	Keys          int              `json:"keys"`
+	Hits          int              `json:"hits"`
+	Misses        int              `json:"misses"`
+	Restores      int              `json:"restores"`
+	AvgRestore    time.Duration    `json:"avg_restore"`
+	BytesRestored int64            `json:"bytes_restored"`
+	Days          []CacheReportDay `json:"days"`
+}
+
+func (r CacheReportRow) HitRate() (float64, bool) {
+	total := r.Hits + r.Misses
+	if total == 0 {
+		return 0, false
+	}
+	return float64(r.Hits) / float64(total), true
+}
+
+type cacheEventCount struct {
+	ProjectID string
+	Artifact  string
+	CacheKey  string
+	Day       string
+	Hits      int
+	Misses    int
+}
+
+func (db *DB) getCacheEventCounts(since time.Time) ([]cacheEventCount, error) {
+	rows, err := db.conn.Query(`
+		SELECT
+			project_id,
+			artifact,
+			cache_key,
+			date(timestamp) as day,
+			SUM(CASE WHEN event = 'hit' THEN 1 ELSE 0 END) as hits,
+			SUM(CASE WHEN event = 'miss' THEN 1 ELSE 0 END) as misses
+		FROM cache_events
+		WHERE timestamp >= ?
+		GROUP BY project_id, artifact, cache_key, day
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache events: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []cacheEventCount
+	for rows.Next() {
+		var c cacheEventCount
+		if err := rows.Scan(&c.ProjectID, &c.Artifact, &c.CacheKey, &c.Day, &c.Hits, &c.Misses); err != nil {
+			return nil, fmt.Errorf("failed to scan cache events: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cache events: %w", err)
+	}
+	return counts, nil
+}
+
+func CacheReport(days int, artifact string) ([]CacheReportRow, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("invalid number of days %d: must be at least 1", days)
+	}
+	now := time.Now().UTC()
+	since := now.Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	counts, err := db.getCacheEventCounts(since)
+	if err != nil {
+		return nil, err
+	}
+	restores, err := ReadEvents(EventFilter{Since: since, Type: EventRestore, Artifact: artifact})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[[2]string]*CacheReportRow)
+	keys := make(map[[2]string]map[string]bool)
+	daily := make(map[[2]string]map[string]*CacheReportDay)
+	row := func(projectID, name string) *CacheReportRow {
+		id := [2]string{projectID, name}
+		if r, ok := rows[id]; ok {
+			return r
+		}
+		r := &CacheReportRow{ProjectID: projectID, Artifact: name}
+		rows[id] = r
+		keys[id] = make(map[string]bool)
+		daily[id] = make(map[string]*CacheReportDay)
+		for d := since; !d.After(now); d = d.AddDate(0, 0, 1) {
+			day := d.Format(cacheReportDayLayout)
+			daily[id][day] = &CacheReportDay{Day: day}
+		}
+		return r
+	}
+
+	for _, c := range counts {
+		if artifact != "" && c.Artifact != artifact {
+			continue
+		}
+		r := row(c.ProjectID, c.Artifact)
+		id := [2]string{c.ProjectID, c.Artifact}
+		r.Hits += c.Hits
+		r.Misses += c.Misses
+		keys[id][c.CacheKey] = true
+		if d, ok := daily[id][c.Day]; ok {
+			d.Hits += c.Hits
+			d.Misses += c.Misses
+		}
+	}
+
+	restoreTimes := make(map[[2]string]time.Duration)
+	for _, e := range restores {
+		if e.Error != "" {
+			continue
+		}
+		r := row(e.Project, e.Artifact)
+		id := [2]string{e.Project, e.Artifact}
+		r.Restores++
+		r.BytesRestored += e.Size
+		restoreTimes[id] += time.Duration(e.DurationMS) * time.Millisecond
+	}
+
+	report := make([]CacheReportRow, 0, len(rows))
+	for id, r := range rows {
+		r.Keys = len(keys[id])
+		if r.Restores > 0 {
+			r.AvgRestore = restoreTimes[id] / time.Duration(r.Restores)
+		}
+		for _, day := range sortedKeys(daily[id]) {
+			r.Days = append(r.Days, *daily[id][day])
+		}
+		report = append(report, *r)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].ProjectID != report[j].ProjectID {
+			return report[i].ProjectID < report[j].ProjectID
+		}
+		return report[i].Artifact < report[j].Artifact
+	})
+	return report, nil
+}
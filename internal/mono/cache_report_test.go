@@ -0,0 +1,83 @@
+package mono
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheReport(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, e := range []struct{ event, artifact, key string }{
+		{"miss", "cargo", "k1"},
+		{"hit", "cargo", "k1"},
+		{"hit", "cargo", "k1"},
+		{"miss", "cargo", "k2"},
+		{"miss", "npm", "n1"},
+	} {
+		if err := db.RecordCacheEvent(e.event, "proj", e.artifact, e.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().UTC().AddDate(0, 0, -10).Format("2006-01-02 15:04:05")
+	if _, err := db.conn.Exec(`INSERT INTO cache_events (timestamp, event, project_id, artifact, cache_key) VALUES (?, 'hit', 'proj', 'cargo', 'k0')`, old); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []Event{
+		{Time: time.Now(), Type: EventRestore, Project: "proj", Artifact: "cargo", Key: "k1", Size: 1000, DurationMS: 1000},
+		{Time: time.Now(), Type: EventRestore, Project: "proj", Artifact: "cargo", Key: "k1", Size: 3000, DurationMS: 3000},
+		{Time: time.Now(), Type: EventRestore, Project: "proj", Artifact: "cargo", Key: "k1", DurationMS: 50, Error: "disk full"},
+		{Time: time.Now().AddDate(0, 0, -10), Type: EventRestore, Project: "proj", Artifact: "cargo", Key: "k0", Size: 5000, DurationMS: 9000},
+	} {
+		if err := RecordEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := CacheReport(7, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 2 || report[0].Artifact != "cargo" || report[1].Artifact != "npm" {
+		t.Fatalf("CacheReport() = %+v, want cargo and npm", report)
+	}
+	cargo := report[0]
+	if cargo.Keys != 2 || cargo.Hits != 2 || cargo.Misses != 2 {
+		t.Errorf("cargo lookups = %d keys, %d hits, %d misses, want 2, 2, 2", cargo.Keys, cargo.Hits, cargo.Misses)
+	}
+	if rate, ok := cargo.HitRate(); !ok || rate != 0.5 {
+		t.Errorf("cargo hit rate = %v, %v, want 0.5", rate, ok)
+	}
+	if cargo.Restores != 2 || cargo.AvgRestore != 2*time.Second || cargo.BytesRestored != 4000 {
+		t.Errorf("cargo restores = %d, avg %s, %d bytes, want 2, 2s, 4000", cargo.Restores, cargo.AvgRestore, cargo.BytesRestored)
+	}
+	if len(cargo.Days) != 7 {
+		t.Fatalf("cargo days = %+v, want 7", cargo.Days)
+	}
+	today := cargo.Days[6]
+	if today.Day != time.Now().UTC().Format(cacheReportDayLayout) || today.Hits != 2 || today.Misses != 2 {
+		t.Errorf("today = %+v, want 2 hits and 2 misses", today)
+	}
+	if _, ok := report[1].HitRate(); !ok || report[1].Restores != 0 {
+		t.Errorf("npm = %+v, want only a miss", report[1])
+	}
+
+	report, err = CacheReport(30, "npm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 1 || report[0].Artifact != "npm" || len(report[0].Days) != 30 {
+		t.Errorf("CacheReport(30, npm) = %+v", report)
+	}
+
+	if _, err := CacheReport(0, ""); err == nil {
+		t.Error("CacheReport(0) succeeded")
+	}
+}
@@ -0,0 +1,130 @@
+package mono
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var ErrCacheEntryInUse = errors.New("cache entry is still linked from an environment")
+
+func sharedRefsPath(cachePath string) string {
+	return cachePath + ".refs"
+}
+
+func linkSharedCache(src, envPath string) error {
+	if err := os.Symlink(src, envPath); err != nil {
+		return err
+	}
+	return addSharedCacheRef(filepath.Dir(src), envPath)
+}
+
+func addSharedCacheRef(cachePath, envPath string) error {
+	f, err := os.OpenFile(sharedRefsPath(cachePath), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to record shared cache user: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, envPath); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to record shared cache user: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to record shared cache user: %w", err)
+	}
+	return nil
+}
+
+func sharedCacheUsers(cachePath string) ([]string, error) {
+	f, err := os.Open(sharedRefsPath(cachePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shared cache users: %w", err)
+	}
+	defer f.Close()
+
+	var users []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		envPath := strings.TrimSpace(scanner.Text())
+		if envPath == "" || seen[envPath] {
+			continue
+		}
+		seen[envPath] = true
+		target, err := os.Readlink(envPath)
+		if os.IsNotExist(err) || errors.Is(err, syscall.EINVAL) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", envPath, err)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(envPath), target)
+		}
+		if pathWithin(filepath.Clean(target), cachePath) {
+			users = append(users, envPath)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shared cache users: %w", err)
+	}
+	return users, nil
+}
+
+func checkCacheEntryUnused(cachePath string) error {
+	users, err := sharedCacheUsers(cachePath)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("%w: %s is linked from %s", ErrCacheEntryInUse, cachePath, strings.Join(users, ", "))
+	}
+	return nil
+}
+
+func (cm *CacheManager) storeSharedLink(localPath, cachePath string, relink bool) error {
+	lock, err := cm.acquireCacheLock(cachePath)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return nil
+	}
+	defer cm.releaseCacheLock(lock)
+
+	target, err := filepath.EvalSymlinks(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", localPath, err)
+	}
+	targetInCache := filepath.Join(cachePath, filepath.Base(localPath))
+	if dirExists(targetInCache) {
+		return nil
+	}
+	if err := os.MkdirAll(cachePath, 0755); err != nil {
+		return err
+	}
+	if err := copyDir(target, targetInCache); err != nil {
+		return fmt.Errorf("failed to copy %s to cache: %w", target, err)
+	}
+	if !relink {
+		return nil
+	}
+
+	tmp := localPath + ".mono-link"
+	if err := os.Symlink(targetInCache, tmp); err != nil {
+		return fmt.Errorf("failed to link %s to cache: %w", localPath, err)
+	}
+	if err := os.Rename(tmp, localPath); err != nil {
+		if removeErr := os.Remove(tmp); removeErr != nil {
+			return fmt.Errorf("failed to link %s to cache: %w (cleanup error: %v)", localPath, err, removeErr)
+		}
+		return fmt.Errorf("failed to link %s to cache: %w", localPath, err)
+	}
+	return addSharedCacheRef(cachePath, localPath)
+}
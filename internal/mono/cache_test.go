@@ -1,10 +1,15 @@
 package mono
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -79,6 +84,64 @@ func TestComputeCacheKey(t *testing.T) {
 	}
 }
 
+func TestComputeCacheKeyMemoized(t *testing.T) {
+	testDir := t.TempDir()
+	lockfile := filepath.Join(testDir, "Cargo.lock")
+	if err := os.WriteFile(lockfile, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	counter := filepath.Join(t.TempDir(), "runs")
+	keyCommand := fmt.Sprintf("echo run >> %s; echo rustc 1.80", counter)
+	runs := func() int {
+		t.Helper()
+		data, err := os.ReadFile(counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "run")
+	}
+
+	cm := &CacheManager{LocalCacheDir: t.TempDir()}
+	cargo := ArtifactConfig{Name: "cargo", KeyFiles: []string{"Cargo.lock"}, KeyCommands: []string{keyCommand}, Paths: []string{"target"}}
+	sccache := ArtifactConfig{Name: "sccache", KeyFiles: []string{"Cargo.lock"}, KeyCommands: []string{keyCommand}, Paths: []string{".sccache"}}
+
+	first, err := cm.ComputeCacheKey(cargo, testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := cm.PrepareArtifactCache([]ArtifactConfig{cargo, sccache}, testDir, testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Key != first {
+		t.Errorf("PrepareArtifactCache() key = %s, want %s", entries[0].Key, first)
+	}
+	if got := runs(); got != 1 {
+		t.Errorf("key command ran %d times, want once per process", got)
+	}
+
+	if err := os.WriteFile(lockfile, []byte("v2 with more"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := cm.ComputeCacheKey(cargo, testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Error("key did not change after the key file changed")
+	}
+	if got := runs(); got != 1 {
+		t.Errorf("key command ran %d times, want its output reused", got)
+	}
+
+	if _, err := (&CacheManager{}).ComputeCacheKey(cargo, testDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := runs(); got != 2 {
+		t.Errorf("key command ran %d times, want a fresh CacheManager to run it again", got)
+	}
+}
+
 func TestComputeCacheKeyMissingKeyFile(t *testing.T) {
 	cm, err := NewCacheManager()
 	if err != nil {
@@ -104,6 +167,286 @@ func TestComputeCacheKeyMissingKeyFile(t *testing.T) {
 	}
 }
 
+func TestCacheKeyWarnings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+	logger, err := NewFileLogger("cache-key-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	rootDir := t.TempDir()
+	envDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(envDir, "empty.lock"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := []ArtifactConfig{
+		{Name: "npm", KeyFiles: []string{"package-lock.json"}, KeyCommands: []string{"echo v1"}, Paths: []string{"node_modules"}},
+		{Name: "empty", KeyFiles: []string{"empty.lock"}, Paths: []string{"out"}},
+	}
+	entries, err := cm.PrepareArtifactCache(artifacts, rootDir, envDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entries[0].Warnings; len(got) != 1 || !strings.Contains(got[0], "package-lock.json does not exist") {
+		t.Errorf("npm warnings = %v", got)
+	}
+	if got := entries[1].Warnings; len(got) != 1 || !strings.Contains(got[0], "zero bytes") {
+		t.Errorf("empty warnings = %v", got)
+	}
+
+	if err := CheckCacheKeys(entries, false, logger); err != nil {
+		t.Errorf("non-strict check failed: %v", err)
+	}
+	err = CheckCacheKeys(entries, true, logger)
+	if err == nil || !strings.Contains(err.Error(), "npm: key file package-lock.json does not exist") {
+		t.Errorf("strict check err = %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":   512,
+		"10KB":  10 << 10,
+		"1.5G":  3 << 29,
+		"20 GB": 20 << 30,
+		"1tb":   1 << 40,
+		"100MB": 100 << 20,
+	}
+	for input, want := range tests {
+		got, err := ParseSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "GB", "-1GB", "ten"} {
+		if _, err := ParseSize(input); err == nil {
+			t.Errorf("ParseSize(%q) should fail", input)
+		}
+	}
+}
+
+func TestEnforceMaxSize(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("max-size-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	for _, key := range []string{"old", "recent", "current"} {
+		dir := filepath.Join(cm.LocalCacheDir, "proj", "npm", key, "node_modules")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RecordCacheEvent("hit", "proj", "npm", "old"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if err := db.RecordCacheEvent("hit", "proj", "npm", "recent"); err != nil {
+		t.Fatal(err)
+	}
+
+	cm.MaxSize = 2500
+	keep := []ArtifactCacheEntry{{CachePath: filepath.Join(cm.LocalCacheDir, "proj", "npm", "current")}}
+	if err := cm.EnforceMaxSize(db, keep, logger); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := cm.GetCacheSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, s := range sizes {
+		keys = append(keys, s.CacheKey)
+	}
+	if strings.Join(keys, ",") != "current,recent" {
+		t.Errorf("remaining keys = %v, want the protected and most recently used entries kept", keys)
+	}
+}
+
+func TestGetCacheSizesReadsManifest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := filepath.Join(cm.LocalCacheDir, "proj", "npm", "legacy")
+	if err := os.MkdirAll(filepath.Join(legacy, "node_modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "node_modules", "blob"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes, err := cm.GetCacheSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 1 || sizes[0].Size != 1000 {
+		t.Fatalf("GetCacheSizes() = %+v, want the legacy entry measured at 1000 bytes", sizes)
+	}
+	if _, err := ReadCacheManifest(legacy); !os.IsNotExist(err) {
+		t.Errorf("measuring the entry wrote a manifest (read err = %v), want manifests written only on store", err)
+	}
+
+	if err := writeCacheManifest(legacy, CacheManifest{Artifact: "npm", Key: "legacy", Size: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, "node_modules", "untracked"), make([]byte, 500), 0644); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(cm.LocalCacheDir, "proj", "npm", "corrupt")
+	if err := os.MkdirAll(corrupt, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(corrupt, cacheManifestFile), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sizes, err = cm.GetCacheSizes()
+	if err != nil {
+		t.Fatalf("GetCacheSizes() with a corrupt manifest = %v, want the entry skipped", err)
+	}
+	if len(sizes) != 1 || sizes[0].CacheKey != "legacy" || sizes[0].Size != 1000 {
+		t.Errorf("GetCacheSizes() = %+v, want only the legacy entry at its recorded size", sizes)
+	}
+}
+
+func TestGetCacheSizesContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.Workers = 4
+
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(cm.LocalCacheDir, "proj", "npm", fmt.Sprintf("key-%02d", i), "node_modules")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "blob"), make([]byte, 100*(i+1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cm.GetCacheSizesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetCacheSizesContext() with cancelled context = %v, want context.Canceled", err)
+	}
+
+	sizes, err := cm.GetCacheSizesContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 20 {
+		t.Fatalf("got %d entries, want 20", len(sizes))
+	}
+	for i, s := range sizes {
+		if want := fmt.Sprintf("key-%02d", i); s.CacheKey != want || s.Size != int64(100*(i+1)) {
+			t.Errorf("entry %d = %+v, want %s with %d bytes", i, s, want, 100*(i+1))
+		}
+	}
+}
+
+func TestDetectRunningCargoProcesses(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+	data, err := os.ReadFile(sleep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	for _, name := range []string{"cargo", "editor"} {
+		if err := os.WriteFile(filepath.Join(bin, name), data, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project := t.TempDir()
+	elsewhere := t.TempDir()
+	start := func(name, dir string) *exec.Cmd {
+		t.Helper()
+		cmd := exec.Command(filepath.Join(bin, name), "30")
+		cmd.Dir = dir
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		})
+		return cmd
+	}
+	inProject := start("cargo", project)
+	start("cargo", elsewhere)
+	start("editor", project)
+
+	procs, err := DetectRunningCargoProcesses(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 1 || procs[0].PID != strconv.Itoa(inProject.Process.Pid) {
+		t.Errorf("DetectRunningCargoProcesses() = %+v, want only pid %d", procs, inProject.Process.Pid)
+	}
+}
+
+func TestProcessInProject(t *testing.T) {
+	roots := []string{"/work/app"}
+	tests := []struct {
+		name string
+		cwd  string
+		args []string
+		want bool
+	}{
+		{name: "cwd inside", cwd: "/work/app/crates/api", args: []string{"cargo", "build"}, want: true},
+		{name: "manifest path", cwd: "/", args: []string{"cargo", "build", "--manifest-path=/work/app/Cargo.toml"}, want: true},
+		{name: "source argument", args: []string{"rustc", "/work/app/src/lib.rs"}, want: true},
+		{name: "sibling prefix", cwd: "/work/app-old", args: []string{"cargo", "build"}},
+		{name: "unrelated", cwd: "/tmp", args: []string{"cargo", "install", "ripgrep"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processInProject(roots, tt.cwd, tt.args); got != tt.want {
+				t.Errorf("processInProject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if isCargoProcess("nvim", []string{"nvim", "/work/app/cargo/notes.md"}) {
+		t.Error("isCargoProcess() matched an editor with cargo in a file path")
+	}
+	if !isCargoProcess("cargo-watch", []string{"cargo-watch", "-x", "run"}) {
+		t.Error("isCargoProcess() missed a cargo subcommand binary")
+	}
+}
+
 func TestHardlinkTree(t *testing.T) {
 	src := t.TempDir()
 	dst := filepath.Join(t.TempDir(), "dst")
@@ -314,6 +657,69 @@ func TestStoreAndRestoreCache(t *testing.T) {
 	}
 }
 
+func TestRestoreStrategies(t *testing.T) {
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatalf("failed to create cache manager: %v", err)
+	}
+
+	for _, strategy := range []string{RestoreHardlink, RestoreCopy, RestoreShared} {
+		t.Run(strategy, func(t *testing.T) {
+			testDir := t.TempDir()
+			moduleDir := filepath.Join(testDir, "env", "node_modules")
+			if err := os.MkdirAll(moduleDir, 0755); err != nil {
+				t.Fatalf("failed to create node_modules: %v", err)
+			}
+			testFile := filepath.Join(moduleDir, "index.js")
+			if err := os.WriteFile(testFile, []byte("cached"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			entry := ArtifactCacheEntry{
+				Name:      "npm",
+				Key:       "key",
+				CachePath: filepath.Join(testDir, "cache", "npm", "key"),
+				EnvPaths:  []string{moduleDir},
+				Strategy:  strategy,
+			}
+			if err := cm.StoreToCache(entry); err != nil {
+				t.Fatalf("StoreToCache failed: %v", err)
+			}
+			if err := os.RemoveAll(moduleDir); err != nil {
+				t.Fatalf("failed to remove node_modules: %v", err)
+			}
+			if err := cm.RestoreFromCache(entry, nil); err != nil {
+				t.Fatalf("RestoreFromCache failed: %v", err)
+			}
+
+			cachedFile := filepath.Join(entry.CachePath, "node_modules", "index.js")
+			cachedInfo, err := os.Stat(cachedFile)
+			if err != nil {
+				t.Fatalf("cached file missing: %v", err)
+			}
+			envInfo, err := os.Stat(testFile)
+			if err != nil {
+				t.Fatalf("restored file missing: %v", err)
+			}
+
+			switch strategy {
+			case RestoreHardlink:
+				if !os.SameFile(cachedInfo, envInfo) {
+					t.Error("hardlink strategy should share inodes with the cache")
+				}
+			case RestoreCopy:
+				if os.SameFile(cachedInfo, envInfo) {
+					t.Error("copy strategy should not share inodes with the cache")
+				}
+			case RestoreShared:
+				if !isSymlink(moduleDir) {
+					t.Error("shared strategy should link node_modules to the cache")
+				}
+			}
+		})
+	}
+}
+
 func TestDetectArtifacts(t *testing.T) {
 	testDir := t.TempDir()
 
@@ -1622,6 +2028,94 @@ func TestSeedDirectoryPreservesSymlinks(t *testing.T) {
 	}
 }
 
+func TestSeedDirectoryDoesNotLeakGoroutines(t *testing.T) {
+	testDir := t.TempDir()
+	srcDir := filepath.Join(testDir, "src")
+	dstDir := filepath.Join(testDir, "dst")
+
+	for i := 0; i < 2000; i++ {
+		dir := filepath.Join(srcDir, fmt.Sprintf("pkg-%d", i%20))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d", i)), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	before := runtime.NumGoroutine()
+	if err := SeedDirectory(srcDir, dstDir, SeedOptions{ArtifactName: "npm", NumWorkers: 4}); err != nil {
+		t.Fatalf("SeedDirectory failed: %v", err)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines after seeding = %d, want at most %d", after, before)
+	}
+
+	count, err := countFiles(dstDir, "npm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2000 {
+		t.Errorf("seeded %d files, want 2000", count)
+	}
+}
+
+func TestSeedPoolDetectsStalls(t *testing.T) {
+	pool := newSeedPool(2, 50*time.Millisecond, time.Hour)
+	defer pool.cancel()
+	if err := pool.check(); err != nil {
+		t.Fatalf("check() on idle pool = %v", err)
+	}
+
+	pool.workers[1].begin("deps/slow.rlib")
+	pool.workers[1].started = time.Now().Add(-time.Second)
+	err := pool.check()
+	if err == nil || !strings.Contains(err.Error(), "deps/slow.rlib") || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("check() with stuck worker = %v, want timeout naming the file", err)
+	}
+	pool.workers[1].advance()
+	if err := pool.check(); err != nil {
+		t.Errorf("check() after the worker copied more bytes = %v", err)
+	}
+	pool.workers[1].end()
+
+	pool.progressTimeout = 10 * time.Millisecond
+	pool.lastProgress.Store(time.Now().Add(-time.Second).UnixNano())
+	if err := pool.check(); err == nil || !strings.Contains(err.Error(), "no progress") {
+		t.Errorf("check() without progress = %v, want progress timeout", err)
+	}
+}
+
+func TestCopyFileContextStopsWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := copyFileContext(ctx, src, filepath.Join(dir, "cancelled"), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("copyFileContext() with cancelled context = %v, want context.Canceled", err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	var copied int64
+	if err := copyFileContext(context.Background(), src, dst, func(n int64) { copied += n }); err != nil {
+		t.Fatal(err)
+	}
+	if copied != int64(len("content")) {
+		t.Errorf("reported %d bytes copied, want %d", copied, len("content"))
+	}
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Errorf("copied %q, want %q", data, "content")
+	}
+}
+
 func setupMockFingerprints(b *testing.B, numCrates int) string {
 	b.Helper()
 	dir := b.TempDir()
@@ -1692,3 +2186,124 @@ func BenchmarkTouchDepFiles(b *testing.B) {
 		})
 	}
 }
+
+func TestFindApproximateEntry(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.email=t@t", "-c", "user.name=t"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "one")
+	first := git("rev-parse", "HEAD")
+	git("commit", "-q", "--allow-empty", "-m", "two")
+	second := git("rev-parse", "HEAD")
+	git("checkout", "-q", "-b", "feature")
+	git("commit", "-q", "--allow-empty", "-m", "three")
+
+	cm := &CacheManager{LocalCacheDir: t.TempDir()}
+	artifact := ArtifactConfig{Name: "npm", Paths: []string{"node_modules"}}
+	artifactDir := filepath.Join(cm.GetProjectCacheDir(repo), artifact.Name)
+
+	for key, commit := range map[string]string{"aaaa": first, "bbbb": second} {
+		dir := filepath.Join(artifactDir, key)
+		if err := os.MkdirAll(filepath.Join(dir, "node_modules"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		data := fmt.Sprintf(`{"artifact":"npm","key":%q,"branch":"main","commit":%q,"created_at":"2024-01-01T00:00:00Z"}`, key, commit)
+		if err := os.WriteFile(filepath.Join(dir, cacheManifestFile), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, manifest, err := cm.FindApproximateEntry(artifact, repo, repo)
+	if err != nil {
+		t.Fatalf("FindApproximateEntry failed: %v", err)
+	}
+	if entry == nil {
+		t.Fatal("expected an approximate entry")
+	}
+	if entry.Key != "bbbb" || manifest.Commit != second {
+		t.Errorf("expected closest ancestor bbbb, got %s (%s)", entry.Key, manifest.Commit)
+	}
+}
+
+func TestSharedCacheEntryInUse(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootPath := t.TempDir()
+	envPath := filepath.Join(t.TempDir(), "feature")
+	writeBuildxFixture(t, envPath, map[string]string{
+		"package-lock.json":     "v1",
+		"node_modules/index.js": "one",
+	})
+	artifact := ArtifactConfig{Name: "npm", KeyFiles: []string{"package-lock.json"}, Paths: []string{"node_modules"}, Strategy: RestoreShared}
+	sync := func() string {
+		t.Helper()
+		if err := cm.Sync([]ArtifactConfig{artifact}, rootPath, envPath, SyncOptions{HardlinkBack: true}); err != nil {
+			t.Fatal(err)
+		}
+		key, err := cm.ComputeCacheKey(artifact, envPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	first := sync()
+	moduleDir := filepath.Join(envPath, "node_modules")
+	if !isSymlink(moduleDir) {
+		t.Fatal("node_modules was not linked to the cache entry")
+	}
+	projectID := ComputeProjectID(rootPath)
+	if err := cm.RemoveCacheEntry(projectID, "npm", first); !errors.Is(err, ErrCacheEntryInUse) {
+		t.Fatalf("RemoveCacheEntry() = %v, want ErrCacheEntryInUse while the env links it", err)
+	}
+	if _, _, err := cm.RemoveAllCache(); !errors.Is(err, ErrCacheEntryInUse) {
+		t.Fatalf("RemoveAllCache() = %v, want ErrCacheEntryInUse while the env links it", err)
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"package-lock.json": "v2", "node_modules/added.js": "two"})
+	second := sync()
+	if second == first {
+		t.Fatal("changing the key file did not change the key")
+	}
+	secondPath := cm.GetArtifactCachePath(rootPath, "npm", second)
+	data, err := os.ReadFile(filepath.Join(secondPath, "node_modules", "added.js"))
+	if err != nil {
+		t.Fatalf("sync did not store the linked node_modules under the new key: %v", err)
+	}
+	if string(data) != "two" {
+		t.Errorf("stored file = %q, want two", data)
+	}
+	target, err := os.Readlink(moduleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pathWithin(target, secondPath) {
+		t.Errorf("node_modules links to %s, want the new key %s", target, secondPath)
+	}
+
+	if err := cm.RemoveCacheEntry(projectID, "npm", first); err != nil {
+		t.Errorf("RemoveCacheEntry() of the no longer linked key = %v", err)
+	}
+	if err := cm.RemoveCacheEntry(projectID, "npm", second); !errors.Is(err, ErrCacheEntryInUse) {
+		t.Errorf("RemoveCacheEntry() = %v, want ErrCacheEntryInUse for the new key", err)
+	}
+}
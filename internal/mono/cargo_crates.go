@@ -0,0 +1,569 @@
+package mono
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	crateArtifactSeparator = "@"
+	cargoMetadataTimeout   = 2 * time.Minute
+	cargoUnitHashLen       = 16
+)
+
+var cargoUnitDirs = map[string]bool{
+	"deps":         true,
+	"build":        true,
+	".fingerprint": true,
+}
+
+type cargoCrate struct {
+	Name  string
+	Dir   string
+	Deps  []string
+	stems []string
+}
+
+type crateSource struct {
+	Fingerprint string
+	Newest      time.Time
+}
+
+type crateUnit struct {
+	fingerprint string
+	paths       []string
+}
+
+func (u *crateUnit) fresh(newest time.Time) (bool, error) {
+	if u.fingerprint == "" {
+		return false, nil
+	}
+	entries, err := os.ReadDir(u.fingerprint)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return false, err
+		}
+		if !info.ModTime().Before(newest) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type CrateRestore struct {
+	Restored  []string
+	Unchanged []string
+	Rebuild   []string
+}
+
+func (r CrateRestore) Summary() string {
+	return fmt.Sprintf("%d restored, %d unchanged, %d to rebuild", len(r.Restored), len(r.Unchanged), len(r.Rebuild))
+}
+
+func validateCratesArtifact(a ArtifactConfig) error {
+	if a.cargoManifestDir() == "" {
+		return fmt.Errorf("artifact %s sets crates but has no Cargo.lock in key_files", a.Name)
+	}
+	if len(a.Paths) != 1 {
+		return fmt.Errorf("artifact %s sets crates and needs exactly one path, the cargo target directory", a.Name)
+	}
+	if a.RestoreStrategy() == RestoreShared {
+		return fmt.Errorf("artifact %s sets crates, which doesn't work with strategy %s", a.Name, RestoreShared)
+	}
+	return nil
+}
+
+func (a ArtifactConfig) cargoManifestDir() string {
+	for _, f := range a.KeyFiles {
+		if filepath.Base(f) == "Cargo.lock" {
+			return filepath.Dir(f)
+		}
+	}
+	return ""
+}
+
+func (cm *CacheManager) crateCachePath(rootPath string, artifact ArtifactConfig, crate, fingerprint string) string {
+	return cm.GetArtifactCachePath(rootPath, artifact.Name+crateArtifactSeparator+crate, fingerprint)
+}
+
+func normalizeCrateName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func cargoWorkspace(envPath string, artifact ArtifactConfig) ([]cargoCrate, error) {
+	manifest := filepath.Join(envPath, artifact.cargoManifestDir(), "Cargo.toml")
+	output, err := Command("cargo", "metadata", "--no-deps", "--format-version", "1", "--offline", "--manifest-path", manifest).
+		Timeout(cargoMetadataTimeout).
+		Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("failed to read cargo workspace %s: %s", manifest, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to read cargo workspace %s: %w", manifest, err)
+	}
+
+	var metadata struct {
+		Packages []struct {
+			Name         string `json:"name"`
+			ManifestPath string `json:"manifest_path"`
+			Dependencies []struct {
+				Path string `json:"path"`
+			} `json:"dependencies"`
+			Targets []struct {
+				Name string `json:"name"`
+			} `json:"targets"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(output, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse cargo metadata for %s: %w", manifest, err)
+	}
+
+	byDir := make(map[string]string)
+	for _, p := range metadata.Packages {
+		byDir[filepath.Dir(p.ManifestPath)] = p.Name
+	}
+
+	crates := make([]cargoCrate, 0, len(metadata.Packages))
+	for _, p := range metadata.Packages {
+		c := cargoCrate{Name: p.Name, Dir: filepath.Dir(p.ManifestPath), stems: []string{p.Name}}
+		for _, d := range p.Dependencies {
+			if name, ok := byDir[d.Path]; ok && !slices.Contains(c.Deps, name) {
+				c.Deps = append(c.Deps, name)
+			}
+		}
+		slices.Sort(c.Deps)
+		for _, t := range p.Targets {
+			norm := normalizeCrateName(t.Name)
+			c.stems = append(c.stems, norm, "lib"+norm)
+		}
+		crates = append(crates, c)
+	}
+	slices.SortFunc(crates, func(a, b cargoCrate) int { return strings.Compare(a.Name, b.Name) })
+	return crates, nil
+}
+
+func hashCrateSources(dir string, excluded map[string]bool) (string, time.Time, error) {
+	h := sha256.New()
+	var newest time.Time
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (excluded[path] || d.Name() == ".git" || d.Name() == "target") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		fmt.Fprintf(h, "%s\x00", rel)
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s\x00", target)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to hash sources in %s: %w", dir, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), newest, nil
+}
+
+func crateFingerprints(crates []cargoCrate, baseKey, targetDir string) (map[string]crateSource, error) {
+	excluded := map[string]bool{targetDir: true}
+	for _, c := range crates {
+		excluded[c.Dir] = true
+	}
+
+	own := make(map[string]crateSource)
+	byName := make(map[string]cargoCrate)
+	for _, c := range crates {
+		hash, newest, err := hashCrateSources(c.Dir, excluded)
+		if err != nil {
+			return nil, err
+		}
+		own[c.Name] = crateSource{Fingerprint: hash, Newest: newest}
+		byName[c.Name] = c
+	}
+
+	resolved := make(map[string]crateSource)
+	visiting := make(map[string]bool)
+	var resolve func(name string) crateSource
+	resolve = func(name string) crateSource {
+		if s, ok := resolved[name]; ok {
+			return s
+		}
+		visiting[name] = true
+		src := own[name]
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", baseKey, name, src.Fingerprint)
+		for _, dep := range byName[name].Deps {
+			if visiting[dep] {
+				continue
+			}
+			d := resolve(dep)
+			fmt.Fprintf(h, "%s=%s\x00", dep, d.Fingerprint)
+			if d.Newest.After(src.Newest) {
+				src.Newest = d.Newest
+			}
+		}
+		visiting[name] = false
+		src.Fingerprint = hex.EncodeToString(h.Sum(nil))[:16]
+		resolved[name] = src
+		return src
+	}
+	for _, c := range crates {
+		resolve(c.Name)
+	}
+	return resolved, nil
+}
+
+func splitUnitName(name string) (string, string, bool) {
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 || len(name)-i-1 != cargoUnitHashLen {
+		return "", "", false
+	}
+	hash := name[i+1:]
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", "", false
+	}
+	return name[:i], hash, true
+}
+
+func scanCrateUnits(targetDir string, crates []cargoCrate) (map[string]map[string]*crateUnit, error) {
+	owners := make(map[string]string)
+	for _, c := range crates {
+		for _, stem := range c.stems {
+			owners[stem] = c.Name
+		}
+	}
+
+	units := make(map[string]map[string]*crateUnit)
+	if !dirExists(targetDir) {
+		return units, nil
+	}
+	err := filepath.WalkDir(targetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == "incremental" {
+			return filepath.SkipDir
+		}
+		if !cargoUnitDirs[d.Name()] {
+			return nil
+		}
+		profile, err := filepath.Rel(targetDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			stem, hash, ok := splitUnitName(e.Name())
+			if !ok {
+				continue
+			}
+			crate, ok := owners[stem]
+			if !ok {
+				continue
+			}
+			if units[crate] == nil {
+				units[crate] = make(map[string]*crateUnit)
+			}
+			key := filepath.Join(profile, hash)
+			unit := units[crate][key]
+			if unit == nil {
+				unit = &crateUnit{}
+				units[crate][key] = unit
+			}
+			full := filepath.Join(path, e.Name())
+			rel, err := filepath.Rel(targetDir, full)
+			if err != nil {
+				return err
+			}
+			unit.paths = append(unit.paths, rel)
+			if d.Name() == ".fingerprint" && e.IsDir() {
+				unit.fingerprint = full
+			}
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", targetDir, err)
+	}
+	return units, nil
+}
+
+func linkCrateTree(srcRoot, dstRoot, rel string, copyFiles bool) error {
+	src := filepath.Join(srcRoot, rel)
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcRoot, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstRoot, relPath)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		if shouldSkipCargoPath(relPath) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if copyFiles && d.Type().IsRegular() {
+			return copyFile(path, dst)
+		}
+		return linkOrCopyFile(path, dst)
+	})
+}
+
+type crateState struct {
+	crates    []cargoCrate
+	sources   map[string]crateSource
+	units     map[string]map[string]*crateUnit
+	targetDir string
+}
+
+func loadCrateState(artifact ArtifactConfig, key, envPath string) (*crateState, error) {
+	crates, err := cargoWorkspace(envPath, artifact)
+	if err != nil {
+		return nil, err
+	}
+	targetDir := filepath.Join(envPath, artifact.Paths[0])
+	sources, err := crateFingerprints(crates, key, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	units, err := scanCrateUnits(targetDir, crates)
+	if err != nil {
+		return nil, err
+	}
+	return &crateState{crates: crates, sources: sources, units: units, targetDir: targetDir}, nil
+}
+
+func (s *crateState) freshPaths(crate string) ([]string, error) {
+	var paths []string
+	for _, key := range sortedKeys(s.units[crate]) {
+		unit := s.units[crate][key]
+		fresh, err := unit.fresh(s.sources[crate].Newest)
+		if err != nil {
+			return nil, err
+		}
+		if fresh {
+			paths = append(paths, unit.paths...)
+		}
+	}
+	return paths, nil
+}
+
+func (cm *CacheManager) storeCrates(artifact ArtifactConfig, key, rootPath, srcPath string) ([]string, error) {
+	state, err := loadCrateState(artifact, key, srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []string
+	for _, c := range state.crates {
+		fingerprint := state.sources[c.Name].Fingerprint
+		cachePath := cm.crateCachePath(rootPath, artifact, c.Name, fingerprint)
+		if dirExists(cachePath) {
+			continue
+		}
+		paths, err := state.freshPaths(c.Name)
+		if err != nil {
+			return stored, fmt.Errorf("failed to check build state of crate %s: %w", c.Name, err)
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		if err := storeCrateEntry(state.targetDir, paths, cachePath, artifact.RestoreStrategy() == RestoreCopy); err != nil {
+			return stored, fmt.Errorf("failed to store crate %s: %w", c.Name, err)
+		}
+		if err := WriteCacheManifest(cachePath, artifact.Name+crateArtifactSeparator+c.Name, fingerprint, srcPath); err != nil {
+			return stored, err
+		}
+		stored = append(stored, c.Name)
+	}
+	return stored, nil
+}
+
+func storeCrateEntry(targetDir string, paths []string, cachePath string, copyFiles bool) error {
+	tmp := fmt.Sprintf("%s.tmp-%d", cachePath, os.Getpid())
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	for _, rel := range paths {
+		if err := linkCrateTree(targetDir, tmp, rel, copyFiles); err != nil {
+			if rmErr := os.RemoveAll(tmp); rmErr != nil {
+				return fmt.Errorf("%w (and failed to clean up %s: %v)", err, tmp, rmErr)
+			}
+			return err
+		}
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		if rmErr := os.RemoveAll(tmp); rmErr != nil {
+			return fmt.Errorf("failed to clean up %s: %w", tmp, rmErr)
+		}
+		if dirExists(cachePath) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (cm *CacheManager) RestoreCrates(artifact ArtifactConfig, rootPath, envPath string, replaced bool) (CrateRestore, error) {
+	var res CrateRestore
+	if !artifact.Crates {
+		return res, nil
+	}
+	key, err := cm.ComputeCacheKey(artifact, envPath)
+	if err != nil {
+		return res, fmt.Errorf("failed to compute cache key for %s: %w", artifact.Name, err)
+	}
+	state, err := loadCrateState(artifact, key, envPath)
+	if err != nil {
+		return res, err
+	}
+
+	for _, c := range state.crates {
+		if !replaced {
+			paths, err := state.freshPaths(c.Name)
+			if err != nil {
+				return res, fmt.Errorf("failed to check build state of crate %s: %w", c.Name, err)
+			}
+			if len(paths) > 0 {
+				res.Unchanged = append(res.Unchanged, c.Name)
+				continue
+			}
+		}
+
+		cachePath := cm.crateCachePath(rootPath, artifact, c.Name, state.sources[c.Name].Fingerprint)
+		if !dirExists(cachePath) && !replaced {
+			res.Rebuild = append(res.Rebuild, c.Name)
+			continue
+		}
+		for _, key := range sortedKeys(state.units[c.Name]) {
+			for _, rel := range state.units[c.Name][key].paths {
+				if err := removeAllAudited(filepath.Join(state.targetDir, rel)); err != nil {
+					return res, fmt.Errorf("failed to remove stale build of crate %s: %w", c.Name, err)
+				}
+			}
+		}
+		if !dirExists(cachePath) {
+			res.Rebuild = append(res.Rebuild, c.Name)
+			continue
+		}
+		if err := restoreCrateEntry(cachePath, state.targetDir, artifact.RestoreStrategy() == RestoreCopy); err != nil {
+			return res, fmt.Errorf("failed to restore crate %s: %w", c.Name, err)
+		}
+		res.Restored = append(res.Restored, c.Name)
+	}
+	return res, nil
+}
+
+func restoreCrateEntry(cachePath, targetDir string, copyFiles bool) error {
+	entries, err := os.ReadDir(cachePath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == cacheManifestFile {
+			continue
+		}
+		if err := linkCrateTree(cachePath, targetDir, e.Name(), copyFiles); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	return filepath.WalkDir(cachePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || filepath.Base(filepath.Dir(path)) != ".fingerprint" {
+			return nil
+		}
+		rel, err := filepath.Rel(cachePath, path)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(targetDir, rel)
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := os.Chtimes(filepath.Join(dir, f.Name()), now, now); err != nil {
+				return err
+			}
+		}
+		return filepath.SkipDir
+	})
+}
+
+func (cm *CacheManager) restoreArtifactCrates(artifacts []ArtifactConfig, name, rootPath, envPath string, replaced bool, logger *FileLogger) (string, error) {
+	artifact := artifactByName(artifacts, name)
+	if artifact == nil || !artifact.Crates {
+		return "", nil
+	}
+	res, err := cm.RestoreCrates(*artifact, rootPath, envPath, replaced)
+	if err != nil {
+		return "", fmt.Errorf("failed to restore crates of %s: %w", name, err)
+	}
+	logger.Log("crates of %s: %s (restored: %s, rebuild: %s)", name, res.Summary(), strings.Join(res.Restored, ", "), strings.Join(res.Rebuild, ", "))
+	return res.Summary(), nil
+}
@@ -0,0 +1,160 @@
+package mono
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeCargoWorkspace(t *testing.T, dir, apiSource string) {
+	t.Helper()
+	files := map[string]string{
+		"Cargo.toml":      "[workspace]\nmembers = [\"api\", \"core\"]\nresolver = \"2\"\n",
+		"Cargo.lock":      "version = 3\n",
+		"api/Cargo.toml":  "[package]\nname = \"api\"\nversion = \"0.1.0\"\nedition = \"2021\"\n\n[dependencies]\ncore = { path = \"../core\" }\n",
+		"api/src/lib.rs":  apiSource,
+		"core/Cargo.toml": "[package]\nname = \"core\"\nversion = \"0.1.0\"\nedition = \"2021\"\n",
+		"core/src/lib.rs": "pub fn answer() -> u32 { 42 }\n",
+		"target/debug/.fingerprint/core-aaaaaaaaaaaaaaaa/dep-lib-core": "core",
+		"target/debug/deps/libcore-aaaaaaaaaaaaaaaa.rlib":              "core rlib",
+		"target/debug/.fingerprint/api-bbbbbbbbbbbbbbbb/dep-lib-api":   "api",
+		"target/debug/deps/libapi-bbbbbbbbbbbbbbbb.rlib":               "api rlib",
+		"target/debug/deps/libserde-cccccccccccccccc.rlib":             "serde rlib",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	built := time.Now().Add(time.Hour)
+	for _, name := range []string{"core-aaaaaaaaaaaaaaaa/dep-lib-core", "api-bbbbbbbbbbbbbbbb/dep-lib-api"} {
+		if err := os.Chtimes(filepath.Join(dir, "target/debug/.fingerprint", name), built, built); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCargoCratesRestoreOnlyUnchangedCrates(t *testing.T) {
+	if _, err := exec.LookPath("cargo"); err != nil {
+		t.Skip("cargo not installed")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dir := range map[string]string{"CARGO_HOME": ".cargo", "RUSTUP_HOME": ".rustup"} {
+		if os.Getenv(name) == "" {
+			t.Setenv(name, filepath.Join(home, dir))
+		}
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootPath := t.TempDir()
+	envPath := t.TempDir()
+	writeCargoWorkspace(t, rootPath, "pub fn api() -> u32 { core::answer() }\n")
+	artifact := ArtifactConfig{
+		Name:        "cargo",
+		KeyFiles:    []string{"Cargo.lock"},
+		KeyCommands: []string{"echo v1"},
+		Paths:       []string{"target"},
+		Crates:      true,
+	}
+
+	if err := cm.Sync([]ArtifactConfig{artifact}, rootPath, rootPath, SyncOptions{HardlinkBack: true}); err != nil {
+		t.Fatal(err)
+	}
+	key, err := cm.ComputeCacheKey(artifact, rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := cm.storeCrates(artifact, key, rootPath, rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 0 {
+		t.Errorf("second store = %v, want unchanged crates skipped", stored)
+	}
+
+	writeCargoWorkspace(t, envPath, "pub fn api() -> u32 { core::answer() + 1 }\n")
+	entries, err := cm.PrepareArtifactCache([]ArtifactConfig{artifact}, rootPath, envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !entries[0].Hit {
+		t.Fatal("expected a hit for the dependency cache")
+	}
+	if err := cm.RestoreFromCache(entries[0], nil); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := cm.RestoreCrates(artifact, rootPath, envPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Restored, []string{"core"}) || !slices.Equal(res.Rebuild, []string{"api"}) {
+		t.Errorf("RestoreCrates() = %+v, want core restored and api rebuilt", res)
+	}
+
+	target := filepath.Join(envPath, "target", "debug")
+	if fileExists(filepath.Join(target, "deps", "libapi-bbbbbbbbbbbbbbbb.rlib")) {
+		t.Error("stale api build was left in place")
+	}
+	for _, name := range []string{"deps/libcore-aaaaaaaaaaaaaaaa.rlib", "deps/libserde-cccccccccccccccc.rlib"} {
+		if !fileExists(filepath.Join(target, name)) {
+			t.Errorf("%s is missing after restore", name)
+		}
+	}
+
+	res, err = cm.RestoreCrates(artifact, rootPath, envPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Unchanged, []string{"core"}) || !slices.Equal(res.Rebuild, []string{"api"}) {
+		t.Errorf("RestoreCrates() on a built target = %+v, want core unchanged", res)
+	}
+}
+
+func TestSplitUnitName(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantStem string
+		ok       bool
+	}{
+		{name: "libapi_server-0c1b4292006fd51f.rlib", wantStem: "libapi_server", ok: true},
+		{name: "api-server-f90a867dbaa65c98", wantStem: "api-server", ok: true},
+		{name: "api-server", ok: false},
+		{name: "build_script_build-2vead41umsfiv", ok: false},
+	}
+	for _, tt := range tests {
+		stem, _, ok := splitUnitName(tt.name)
+		if ok != tt.ok || stem != tt.wantStem {
+			t.Errorf("splitUnitName(%q) = %q, %v, want %q, %v", tt.name, stem, ok, tt.wantStem, tt.ok)
+		}
+	}
+}
+
+func TestValidateCratesArtifact(t *testing.T) {
+	bc := BuildConfig{Artifacts: []ArtifactConfig{{Name: "cargo", KeyFiles: []string{"package-lock.json"}, Paths: []string{"target"}, Crates: true}}}
+	if err := bc.Validate(); err == nil {
+		t.Error("Validate() accepted crates without a Cargo.lock key file")
+	}
+	bc.Artifacts[0].KeyFiles = []string{"rust/Cargo.lock"}
+	if err := bc.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if dir := bc.Artifacts[0].cargoManifestDir(); dir != "rust" {
+		t.Errorf("cargoManifestDir() = %q, want rust", dir)
+	}
+}
@@ -24,6 +24,7 @@ type Cmd struct {
 	args    []string
 	dir     string
 	timeout time.Duration
+	stdin   io.Reader
 	stdout  io.Writer
 	stderr  io.Writer
 	env     []string
@@ -39,6 +40,11 @@ func (c *Cmd) Timeout(d time.Duration) *Cmd {
 	return c
 }
 
+func (c *Cmd) Stdin(r io.Reader) *Cmd {
+	c.stdin = r
+	return c
+}
+
 func (c *Cmd) Stdout(w io.Writer) *Cmd {
 	c.stdout = w
 	return c
@@ -62,6 +68,9 @@ func (c *Cmd) Run() error {
 	if c.dir != "" {
 		cmd.Dir = c.dir
 	}
+	if c.stdin != nil {
+		cmd.Stdin = c.stdin
+	}
 	if c.stdout != nil {
 		cmd.Stdout = c.stdout
 	}
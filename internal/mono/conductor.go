@@ -0,0 +1,239 @@
+package mono
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ConductorOptions struct {
+	WorkspacesDir string
+	Interval      time.Duration
+}
+
+type ConductorWorkspace struct {
+	Project    string
+	Name       string
+	Path       string
+	HasConfig  bool
+	Registered bool
+}
+
+type ConductorSyncResult struct {
+	Registered   []string
+	Deregistered []string
+	Failed       []string
+	Reloaded     []ConfigReloadResult
+}
+
+func DefaultConductorWorkspacesDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, "conductor", "workspaces"), nil
+}
+
+func (o *ConductorOptions) applyDefaults() error {
+	if o.WorkspacesDir == "" {
+		dir, err := DefaultConductorWorkspacesDir()
+		if err != nil {
+			return err
+		}
+		o.WorkspacesDir = dir
+	}
+	absDir, err := filepath.Abs(o.WorkspacesDir)
+	if err != nil {
+		return fmt.Errorf("invalid workspaces directory: %w", err)
+	}
+	o.WorkspacesDir = absDir
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	return nil
+}
+
+func ListConductorWorkspaces(opts ConductorOptions) ([]ConductorWorkspace, error) {
+	if err := opts.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	return scanConductorWorkspaces(db, opts.WorkspacesDir)
+}
+
+func scanConductorWorkspaces(db *DB, dir string) ([]ConductorWorkspace, error) {
+	projects, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var workspaces []ConductorWorkspace
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		projectDir := filepath.Join(dir, project.Name())
+		entries, err := os.ReadDir(projectDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", projectDir, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(projectDir, entry.Name())
+			if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+				continue
+			}
+			registered, err := db.EnvironmentExists(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check environment: %w", err)
+			}
+			workspaces = append(workspaces, ConductorWorkspace{
+				Project:    project.Name(),
+				Name:       entry.Name(),
+				Path:       path,
+				HasConfig:  fileExists(filepath.Join(path, "mono.yml")),
+				Registered: registered,
+			})
+		}
+	}
+	return workspaces, nil
+}
+
+func SyncConductorWorkspaces(opts ConductorOptions) (*ConductorSyncResult, error) {
+	if err := opts.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	workspaces, err := scanConductorWorkspaces(db, opts.WorkspacesDir)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	envs, err := db.ListEnvironments()
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConductorSyncResult{}
+
+	for _, ws := range workspaces {
+		if ws.Registered || !ws.HasConfig {
+			continue
+		}
+		if err := Init(ws.Path, InitOptions{}); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", ws.Path, err))
+			continue
+		}
+		result.Registered = append(result.Registered, ws.Path)
+	}
+
+	prefix := opts.WorkspacesDir + string(filepath.Separator)
+	for _, env := range envs {
+		if !strings.HasPrefix(env.Path, prefix) {
+			continue
+		}
+		if _, err := os.Stat(env.Path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := Destroy(env.Path, DestroyOptions{KeepWorktree: true}); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", env.Path, err))
+			continue
+		}
+		result.Deregistered = append(result.Deregistered, env.Path)
+	}
+
+	return result, nil
+}
+
+func WatchConductorWorkspaces(ctx context.Context, opts ConductorOptions, onSync func(*ConductorSyncResult)) error {
+	if err := opts.applyDefaults(); err != nil {
+		return err
+	}
+
+	logger, err := NewFileLogger("conductor")
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	fsw, err := NewFSWatcher(logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := fsw.Close(); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}()
+	sub := fsw.Subscribe()
+	defer sub.Close()
+
+	var fallback <-chan time.Time
+	if err := sub.Watch(opts.WorkspacesDir, 2); err != nil {
+		logger.Log("warning: file events unavailable, scanning %s every %s: %v", opts.WorkspacesDir, opts.Interval, err)
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		fallback = ticker.C
+	} else {
+		logger.Log("watching %s", opts.WorkspacesDir)
+	}
+
+	reloader := NewConfigReloader(logger)
+	for {
+		result, err := SyncConductorWorkspaces(opts)
+		if err != nil {
+			return err
+		}
+		envs, err := environmentsUnder(opts.WorkspacesDir)
+		if err != nil {
+			return err
+		}
+		result.Reloaded = reloader.Check(envs)
+		if fallback == nil {
+			for _, env := range envs {
+				watchConfigSources(sub, env, logger)
+			}
+		}
+		for _, path := range result.Registered {
+			logger.Log("registered %s", path)
+		}
+		for _, path := range result.Deregistered {
+			logger.Log("deregistered %s", path)
+		}
+		for _, failure := range result.Failed {
+			logger.Log("warning: %s", failure)
+		}
+		onSync(result)
+
+		select {
+		case <-ctx.Done():
+			logger.Log("watch stopped")
+			return nil
+		case <-fallback:
+		case <-sub.Events():
+			if !sub.Settle(ctx, defaultWatchDebounce) {
+				logger.Log("watch stopped")
+				return nil
+			}
+		}
+	}
+}
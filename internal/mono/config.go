@@ -5,29 +5,223 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	RestoreHardlink = "hardlink"
+	RestoreCopy     = "copy"
+	RestoreShared   = "shared"
+)
+
 type ArtifactConfig struct {
-	Name        string   `yaml:"name"`
+	Name        string   `yaml:"name" mono:"required"`
 	KeyFiles    []string `yaml:"key_files"`
 	KeyCommands []string `yaml:"key_commands"`
-	Paths       []string `yaml:"paths"`
+	Paths       []string `yaml:"paths" mono:"required"`
+	Strategy    string   `yaml:"strategy"`
+	Type        string   `yaml:"type"`
+	Service     string   `yaml:"service"`
+	Crates      bool     `yaml:"crates"`
+
+	nix        NixConfig
+	composeDir string
+}
+
+func (a ArtifactConfig) RestoreStrategy() string {
+	if a.Strategy == "" {
+		if a.ArtifactType() == ArtifactBuildx {
+			return RestoreCopy
+		}
+		return RestoreHardlink
+	}
+	return a.Strategy
 }
 
 type BuildConfig struct {
-	Sccache   *bool            `yaml:"sccache"`
-	Artifacts []ArtifactConfig `yaml:"artifacts"`
+	Sccache    *bool            `yaml:"sccache"`
+	StrictKeys bool             `yaml:"strict_keys"`
+	Artifacts  []ArtifactConfig `yaml:"artifacts"`
+}
+
+func (bc *BuildConfig) Validate() error {
+	seen := make(map[string]bool)
+	for _, a := range bc.Artifacts {
+		if seen[a.Name] {
+			return fmt.Errorf("artifact %s is defined more than once", a.Name)
+		}
+		seen[a.Name] = true
+		switch a.ArtifactType() {
+		case ArtifactDirectory:
+		case ArtifactBuildx:
+			if err := validateBuildxArtifact(a); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("invalid type %q for artifact %s (expected %s or %s)", a.Type, a.Name, ArtifactDirectory, ArtifactBuildx)
+		}
+		if len(a.KeyFiles) == 0 && len(a.KeyCommands) == 0 {
+			return fmt.Errorf("artifact %s needs key_files or key_commands to compute its cache key", a.Name)
+		}
+		switch a.RestoreStrategy() {
+		case RestoreHardlink, RestoreCopy, RestoreShared:
+		default:
+			return fmt.Errorf("invalid strategy %q for artifact %s (expected %s, %s or %s)", a.Strategy, a.Name, RestoreHardlink, RestoreCopy, RestoreShared)
+		}
+		if a.Crates {
+			if err := validateCratesArtifact(a); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 type Config struct {
-	Scripts    Scripts           `yaml:"scripts"`
-	Build      BuildConfig       `yaml:"build"`
-	Env        map[string]string `yaml:"env"`
-	ComposeDir string            `yaml:"compose_dir"`
-	Tmux       TmuxConfig        `yaml:"tmux"`
+	Version            int                          `yaml:"version"`
+	Extends            string                       `yaml:"extends"`
+	Include            []string                     `yaml:"include"`
+	Scripts            Scripts                      `yaml:"scripts"`
+	Build              BuildConfig                  `yaml:"build"`
+	BuildQueue         BuildQueueConfig             `yaml:"build_queue"`
+	Env                map[string]string            `yaml:"env"`
+	ComposeDir         string                       `yaml:"compose_dir"`
+	Runtime            string                       `yaml:"container_runtime"`
+	ContainerAutostart bool                         `yaml:"container_autostart"`
+	ComposeProfiles    []string                     `yaml:"compose_profiles"`
+	EnvsDir            string                       `yaml:"envs_dir"`
+	Tmux               TmuxConfig                   `yaml:"tmux"`
+	Nix                NixConfig                    `yaml:"nix"`
+	Ports              PortsConfig                  `yaml:"ports"`
+	Hosts              HostsConfig                  `yaml:"hosts"`
+	Hooks              HooksConfig                  `yaml:"hooks"`
+	Retry              RetryConfig                  `yaml:"retry"`
+	Dotenv             DotenvConfig                 `yaml:"dotenv"`
+	Direnv             DirenvConfig                 `yaml:"direnv"`
+	Prune              PruneConfig                  `yaml:"prune"`
+	Templates          map[string]TemplateConfig    `yaml:"templates"`
+	Profiles           map[string]ProfileConfig     `yaml:"profiles"`
+	Shared             []SharedPath                 `yaml:"shared"`
+	Platforms          []PlatformConfig             `yaml:"platforms"`
+	Services           []ServiceConfig              `yaml:"services"`
+	Remotes            map[string]RemoteConfig      `yaml:"remotes"`
+	Tasks              []TaskConfig                 `yaml:"tasks"`
+	Test               TestConfig                   `yaml:"test"`
+	HealthChecks       map[string]HealthCheckConfig `yaml:"health_checks"`
+	Resources          ResourcesConfig              `yaml:"resources"`
+	Kubernetes         KubernetesConfig             `yaml:"kubernetes"`
+	Images             ImagesConfig                 `yaml:"images"`
+	TLS                TLSConfig                    `yaml:"tls"`
+	Watch              WatchConfig                  `yaml:"watch"`
+	Notify             NotifyConfig                 `yaml:"notify"`
+	Tracing            TracingConfig                `yaml:"tracing"`
+	Timings            TimingsConfig                `yaml:"timings"`
+	Plugins            []PluginConfig               `yaml:"plugins"`
+
+	disabledArtifacts    []string
+	services             []string
+	localComposeProfiles bool
+}
+
+type TemplateConfig struct {
+	Artifacts       []string `yaml:"artifacts"`
+	Services        []string `yaml:"services"`
+	Windows         []string `yaml:"windows"`
+	ComposeProfiles []string `yaml:"compose_profiles"`
+}
+
+const ProfileEnvVar = "MONO_PROFILE"
+
+type PlatformConfig struct {
+	When     string            `yaml:"when" mono:"required"`
+	Services []string          `yaml:"services"`
+	Scripts  Scripts           `yaml:"scripts"`
+	Build    BuildConfig       `yaml:"build"`
+	Env      map[string]string `yaml:"env"`
+	Tmux     TmuxConfig        `yaml:"tmux"`
+	Shared   []SharedPath      `yaml:"shared"`
+}
+
+type ProfileConfig struct {
+	Artifacts       []string `yaml:"artifacts"`
+	Services        []string `yaml:"services"`
+	Sccache         *bool    `yaml:"sccache"`
+	ComposeProfiles []string `yaml:"compose_profiles"`
+}
+
+type PortsConfig struct {
+	Mode     string   `yaml:"mode"`
+	Reserved []string `yaml:"reserved"`
+}
+
+func (pc *PortsConfig) ApplyDefaults() {
+	if pc.Mode == "" {
+		pc.Mode = PortModeSlot
+	}
+}
+
+func (pc *PortsConfig) Validate() error {
+	switch pc.Mode {
+	case PortModeSlot, PortModeEphemeral:
+	default:
+		return fmt.Errorf("invalid ports.mode %q (expected %s or %s)", pc.Mode, PortModeSlot, PortModeEphemeral)
+	}
+	_, err := pc.ReservedPorts()
+	return err
+}
+
+func (pc *PortsConfig) ReservedPorts() (map[int]bool, error) {
+	reserved := make(map[int]bool)
+	for _, entry := range pc.Reserved {
+		first, last, err := parsePortRange(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ports.reserved entry %q: %w", entry, err)
+		}
+		for p := first; p <= last; p++ {
+			reserved[p] = true
+		}
+	}
+	return reserved, nil
+}
+
+func parsePortRange(s string) (int, int, error) {
+	start, end, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	first, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil {
+		return 0, 0, fmt.Errorf("not a port number")
+	}
+	last := first
+	if isRange {
+		last, err = strconv.Atoi(strings.TrimSpace(end))
+		if err != nil {
+			return 0, 0, fmt.Errorf("not a port number")
+		}
+	}
+	if first < 1 || last > MaxPort || first > last {
+		return 0, 0, fmt.Errorf("port range out of bounds")
+	}
+	return first, last, nil
+}
+
+type HostsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Domain  string `yaml:"domain"`
+	File    string `yaml:"file"`
+}
+
+func (hc *HostsConfig) ApplyDefaults() {
+	if hc.Domain == "" {
+		hc.Domain = DefaultHostsDomain
+	}
+	if hc.File == "" {
+		hc.File = DefaultHostsFile
+	}
 }
 
 type Scripts struct {
@@ -37,12 +231,51 @@ type Scripts struct {
 	Destroy string `yaml:"destroy"`
 }
 
+type PruneConfig struct {
+	Auto        bool   `yaml:"auto"`
+	GracePeriod string `yaml:"grace_period"`
+}
+
+const DefaultPruneGracePeriod = 24 * time.Hour
+
+func (pc *PruneConfig) Grace() (time.Duration, error) {
+	if pc.GracePeriod == "" {
+		return DefaultPruneGracePeriod, nil
+	}
+	d, err := ParseAge(pc.GracePeriod)
+	if err != nil {
+		return 0, fmt.Errorf("invalid prune.grace_period: %w", err)
+	}
+	return d, nil
+}
+
+type HooksConfig struct {
+	PreInit  string `yaml:"pre_init"`
+	PostInit string `yaml:"post_init"`
+}
+
 type TmuxRunConfig struct {
 	OnConflict string `yaml:"on_conflict"`
 }
 
+type TmuxWindow struct {
+	Name    string   `yaml:"name" mono:"required"`
+	Command string   `yaml:"command"`
+	Watch   []string `yaml:"watch"`
+}
+
 type TmuxConfig struct {
-	Run TmuxRunConfig `yaml:"run"`
+	Run     TmuxRunConfig `yaml:"run"`
+	Windows []TmuxWindow  `yaml:"windows"`
+
+	nix NixConfig
+}
+
+func (tc TmuxConfig) shell() string {
+	if !tc.nix.Enabled {
+		return ""
+	}
+	return tc.nix.shellCommand(`"$SHELL"`)
 }
 
 func (tc *TmuxConfig) ApplyDefaults() {
@@ -51,30 +284,292 @@ func (tc *TmuxConfig) ApplyDefaults() {
 	}
 }
 
+const LocalConfigFile = ".mono.local.yaml"
+
+type LocalConfig struct {
+	Scripts           Scripts           `yaml:"scripts"`
+	Env               map[string]string `yaml:"env"`
+	DisabledArtifacts []string          `yaml:"disabled_artifacts"`
+	ComposeProfiles   []string          `yaml:"compose_profiles"`
+}
+
 func LoadConfig(dir string) (*Config, error) {
+	return loadConfig(dir, nil)
+}
+
+func loadConfig(dir string, sources ConfigSources) (*Config, error) {
 	path := filepath.Join(dir, "mono.yml")
 
-	data, err := os.ReadFile(path)
-	if os.IsNotExist(err) {
-		return &Config{}, nil
+	var origins map[*yaml.Node]string
+	if sources != nil {
+		origins = make(map[*yaml.Node]string)
 	}
+
+	node, err := loadUserConfigNode(origins)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read mono.yml: %w", err)
+		return nil, err
 	}
 
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	_, err = os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read mono.yml: %w", err)
+	}
+	if err == nil {
+		project, err := loadConfigTree(path, dir, make(map[string]bool), origins)
+		if err != nil {
+			return nil, err
+		}
+		node = mergeConfigNodes(node, project)
+	}
+	if node != nil {
+		if err := node.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid mono.yml: %w", err)
+		}
+		if err := cfg.applyPlatformServices(); err != nil {
+			return nil, fmt.Errorf("invalid mono.yml: %w", err)
+		}
+		if sources != nil {
+			sources.recordNode(node, "", origins)
+		}
+	}
+
+	local, err := loadLocalConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	if local != nil {
+		cfg.applyLocal(local)
+		if sources != nil {
+			sources.recordLocal(local)
+		}
+	}
+
+	if err := applyConfigOverrides(&cfg, sources); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.interpolate(nil); err != nil {
 		return nil, fmt.Errorf("invalid mono.yml: %w", err)
 	}
 
 	return &cfg, nil
 }
 
+func loadLocalConfig(dir string) (*LocalConfig, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LocalConfigFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LocalConfigFile, err)
+	}
+
+	var local LocalConfig
+	if err := decodeConfigFile(LocalConfigFile, data, &local); err != nil {
+		return nil, err
+	}
+	return &local, nil
+}
+
+func (c *Config) applyLocal(local *LocalConfig) {
+	if local.Scripts.Init != "" {
+		c.Scripts.Init = local.Scripts.Init
+	}
+	if local.Scripts.Setup != "" {
+		c.Scripts.Setup = local.Scripts.Setup
+	}
+	if local.Scripts.Run != "" {
+		c.Scripts.Run = local.Scripts.Run
+	}
+	if local.Scripts.Destroy != "" {
+		c.Scripts.Destroy = local.Scripts.Destroy
+	}
+
+	if len(local.Env) > 0 && c.Env == nil {
+		c.Env = make(map[string]string, len(local.Env))
+	}
+	for k, v := range local.Env {
+		c.Env[k] = v
+	}
+
+	c.disabledArtifacts = append(c.disabledArtifacts, local.DisabledArtifacts...)
+	if local.ComposeProfiles != nil {
+		c.ComposeProfiles = local.ComposeProfiles
+		c.localComposeProfiles = true
+	}
+}
+
+func filterArtifacts(artifacts []ArtifactConfig, disabled []string) []ArtifactConfig {
+	if len(disabled) == 0 {
+		return artifacts
+	}
+	skip := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		skip[name] = true
+	}
+	var kept []ArtifactConfig
+	for _, a := range artifacts {
+		if !skip[a.Name] {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
 func (c *Config) ApplyDefaults(envPath string) {
 	if len(c.Build.Artifacts) == 0 {
 		c.Build.Artifacts = detectArtifacts(envPath)
 	}
+	c.Build.Artifacts = filterArtifacts(c.Build.Artifacts, c.disabledArtifacts)
+	c.Nix.ApplyDefaults()
+	c.Kubernetes.ApplyDefaults()
+	c.Images.ApplyDefaults()
+	c.TLS.ApplyDefaults()
+	c.Test.ApplyDefaults()
+	for i := range c.Build.Artifacts {
+		c.Build.Artifacts[i].composeDir = c.ComposeDir
+	}
+	if c.Nix.Enabled {
+		c.Tmux.nix = c.Nix
+		for i := range c.Build.Artifacts {
+			c.Build.Artifacts[i].nix = c.Nix
+		}
+	}
 	c.Tmux.ApplyDefaults()
+	c.Ports.ApplyDefaults()
+	c.Hosts.ApplyDefaults()
+	c.Dotenv.ApplyDefaults()
+}
+
+func unknownSelection(kind, name string, defined []string) error {
+	sort.Strings(defined)
+	if len(defined) == 0 {
+		return fmt.Errorf("unknown %s %q: mono.yml defines no %ss", kind, name, kind)
+	}
+	return fmt.Errorf("unknown %s %q (available: %s)", kind, name, strings.Join(defined, ", "))
+}
+
+func keepArtifacts(artifacts []ArtifactConfig, names []string) []ArtifactConfig {
+	keep := make(map[string]bool, len(names))
+	for _, n := range names {
+		keep[n] = true
+	}
+	var kept []ArtifactConfig
+	for _, a := range artifacts {
+		if keep[a.Name] {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func (c *Config) selectServices(services []string) error {
+	if len(services) == 0 {
+		return nil
+	}
+	if len(c.services) == 0 {
+		c.services = services
+		return nil
+	}
+	allowed := make(map[string]bool, len(services))
+	for _, s := range services {
+		allowed[s] = true
+	}
+	selected := []string{}
+	for _, s := range c.services {
+		if allowed[s] {
+			selected = append(selected, s)
+		}
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no services left: %s were selected but only %s are allowed", strings.Join(c.services, ", "), strings.Join(services, ", "))
+	}
+	c.services = selected
+	return nil
+}
+
+func (c *Config) ApplyTemplate(name string) error {
+	if name == "" {
+		return nil
+	}
+	tpl, ok := c.Templates[name]
+	if !ok {
+		names := make([]string, 0, len(c.Templates))
+		for n := range c.Templates {
+			names = append(names, n)
+		}
+		return unknownSelection("template", name, names)
+	}
+
+	if tpl.Artifacts != nil {
+		c.Build.Artifacts = keepArtifacts(c.Build.Artifacts, tpl.Artifacts)
+	}
+
+	if tpl.Windows != nil {
+		keep := make(map[string]bool, len(tpl.Windows))
+		for _, w := range tpl.Windows {
+			keep[w] = true
+		}
+		var windows []TmuxWindow
+		for _, w := range c.Tmux.Windows {
+			if keep[w.Name] {
+				windows = append(windows, w)
+			}
+		}
+		c.Tmux.Windows = windows
+	}
+
+	if tpl.ComposeProfiles != nil && !c.localComposeProfiles {
+		c.ComposeProfiles = tpl.ComposeProfiles
+	}
+
+	return c.selectServices(tpl.Services)
+}
+
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		return unknownSelection("profile", name, names)
+	}
+
+	if profile.Artifacts != nil {
+		c.Build.Artifacts = keepArtifacts(c.Build.Artifacts, profile.Artifacts)
+	}
+	if profile.Sccache != nil {
+		c.Build.Sccache = profile.Sccache
+	}
+	if profile.ComposeProfiles != nil && !c.localComposeProfiles {
+		c.ComposeProfiles = profile.ComposeProfiles
+	}
+	return c.selectServices(profile.Services)
+}
+
+func (c *Config) SelectedServices() []string {
+	if len(c.services) == 0 {
+		return c.services
+	}
+	selected := []string{}
+	for _, s := range c.selectedWithDependencies() {
+		if c.processService(s) == nil {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+func (c *Config) SelectCompose(compose *ComposeConfig) error {
+	if err := compose.EnableProfiles(c.ComposeProfiles); err != nil {
+		return err
+	}
+	return compose.SelectServices(c.SelectedServices())
 }
 
 func (c *Config) ResolveComposeDir(basePath string) string {
@@ -84,6 +579,24 @@ func (c *Config) ResolveComposeDir(basePath string) string {
 	return filepath.Join(basePath, c.ComposeDir)
 }
 
+func (c *Config) ResolveEnvsDir(repoRoot string) (string, error) {
+	dir := c.EnvsDir
+	if dir == "" || dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		if dir == "" {
+			return filepath.Join(home, ".mono", "workspaces", filepath.Base(repoRoot)), nil
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoRoot, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
 type lockFileSpec struct {
 	filename    string
 	artifactDir string
@@ -129,8 +642,8 @@ func detectArtifacts(envPath string) []ArtifactConfig {
 }
 
 type foundLockFile struct {
-	relPath  string
-	spec     lockFileSpec
+	relPath string
+	spec    lockFileSpec
 }
 
 func (f foundLockFile) toArtifactConfig() ArtifactConfig {
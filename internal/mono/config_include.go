@@ -0,0 +1,180 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func loadConfigTree(path, displayDir string, visiting map[string]bool, origins map[*yaml.Node]string) (*yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config path %s: %w", path, err)
+	}
+	display := abs
+	if rel, err := filepath.Rel(displayDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		display = rel
+	}
+	if visiting[abs] {
+		return nil, fmt.Errorf("config include cycle: %s includes itself", display)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", display, err)
+	}
+
+	node, err := parseConfigNode(display, data, &Config{})
+	if err != nil {
+		return nil, err
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	if origins != nil {
+		recordOrigin(node, display, origins)
+	}
+
+	var parents []string
+	own := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "extends":
+			if value.Value != "" {
+				parents = append([]string{value.Value}, parents...)
+			}
+		case "include":
+			for _, item := range value.Content {
+				parents = append(parents, item.Value)
+			}
+		default:
+			own.Content = append(own.Content, key, value)
+		}
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, parent := range parents {
+		parentPath, err := resolveConfigPath(filepath.Dir(abs), parent)
+		if err != nil {
+			return nil, err
+		}
+		parentNode, err := loadConfigTree(parentPath, displayDir, visiting, origins)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", display, err)
+		}
+		merged = mergeConfigNodes(merged, parentNode)
+	}
+	own, err = applyPlatformNodes(display, own)
+	if err != nil {
+		return nil, err
+	}
+	return mergeConfigNodes(merged, own), nil
+}
+
+func recordOrigin(node *yaml.Node, file string, origins map[*yaml.Node]string) {
+	origins[node] = file
+	for _, child := range node.Content {
+		recordOrigin(child, file, origins)
+	}
+}
+
+func resolveConfigPath(dir, path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+	}
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	return filepath.Join(dir, path), nil
+}
+
+func mergeConfigNodes(base, overlay *yaml.Node) *yaml.Node {
+	if base == nil || base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: base.Tag, Line: base.Line, Column: base.Column}
+	merged.Content = append(merged.Content, base.Content...)
+
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		if value.Tag == "!!null" {
+			continue
+		}
+		replaced := false
+		for j := 0; j+1 < len(merged.Content); j += 2 {
+			if merged.Content[j].Value == key.Value {
+				merged.Content[j+1] = mergeConfigNodes(merged.Content[j+1], value)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+	return merged
+}
+
+func configSourceFiles(dir string) ([]string, error) {
+	userPath, err := UserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	files := []string{userPath, filepath.Join(dir, LocalConfigFile)}
+	seen := make(map[string]bool)
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("invalid config path %s: %w", path, err)
+		}
+		if seen[abs] {
+			return nil
+		}
+		seen[abs] = true
+		files = append(files, abs)
+
+		data, err := os.ReadFile(abs)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", abs, err)
+		}
+		var refs struct {
+			Extends string   `yaml:"extends"`
+			Include []string `yaml:"include"`
+		}
+		if err := yaml.Unmarshal(data, &refs); err != nil {
+			return fmt.Errorf("invalid %s: %w", abs, err)
+		}
+		parents := refs.Include
+		if refs.Extends != "" {
+			parents = append([]string{refs.Extends}, parents...)
+		}
+		for _, parent := range parents {
+			parentPath, err := resolveConfigPath(filepath.Dir(abs), parent)
+			if err != nil {
+				return err
+			}
+			if err := walk(parentPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err = walk(filepath.Join(dir, "mono.yml"))
+	return files, err
+}
@@ -0,0 +1,145 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type interpolator struct {
+	home  string
+	ports map[string]int
+}
+
+func (c *Config) interpolate(ports map[string]int) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	in := &interpolator{home: home, ports: ports}
+	return in.walk(reflect.ValueOf(c).Elem(), "")
+}
+
+func (c *Config) ResolvePorts(allocations []Allocation) error {
+	ports := make(map[string]int)
+	for _, a := range allocations {
+		if _, ok := ports[a.Service]; !ok {
+			ports[a.Service] = a.HostPort
+		}
+	}
+	return c.interpolate(ports)
+}
+
+func (in *interpolator) walk(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return in.walk(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if err := in.walk(v.Field(i), joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := in.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := in.walk(elem, joinPath(path, key.String())); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		s, err := in.expand(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(s)
+	}
+	return nil
+}
+
+func (in *interpolator) expand(s string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		end += start
+
+		ref := s[start+2 : end]
+		value, ok, err := in.resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s[:start])
+		if ok {
+			b.WriteString(value)
+		} else {
+			b.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+}
+
+func (in *interpolator) resolve(ref string) (string, bool, error) {
+	switch {
+	case ref == "HOME":
+		return in.home, true, nil
+	case strings.HasPrefix(ref, "env."):
+		name, fallback, hasFallback := strings.Cut(strings.TrimPrefix(ref, "env."), ":-")
+		if value, ok := os.LookupEnv(name); ok && (value != "" || !hasFallback) {
+			return value, true, nil
+		}
+		if hasFallback {
+			return fallback, true, nil
+		}
+		return "", false, fmt.Errorf("undefined environment variable %s in ${%s} (use ${env.%s:-default} for a fallback)", name, ref, name)
+	case strings.HasPrefix(ref, "port."):
+		if in.ports == nil {
+			return "", false, nil
+		}
+		service := strings.TrimPrefix(ref, "port.")
+		port, ok := in.ports[service]
+		if !ok {
+			services := make([]string, 0, len(in.ports))
+			for s := range in.ports {
+				services = append(services, s)
+			}
+			sort.Strings(services)
+			if len(services) == 0 {
+				return "", false, fmt.Errorf("no port allocated for service %s in ${%s}: this environment has no compose ports", service, ref)
+			}
+			return "", false, fmt.Errorf("no port allocated for service %s in ${%s} (services with ports: %s)", service, ref, strings.Join(services, ", "))
+		}
+		return strconv.Itoa(port), true, nil
+	default:
+		return "", false, nil
+	}
+}
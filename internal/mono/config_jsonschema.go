@@ -0,0 +1,79 @@
+package mono
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var configEnums = map[string][]string{
+	"ports.mode":               {PortModeSlot, PortModeEphemeral},
+	"build.artifacts.strategy": {RestoreHardlink, RestoreCopy, RestoreShared},
+	"build.artifacts.type":     {ArtifactDirectory, ArtifactBuildx},
+	"tmux.run.on_conflict":     {"interrupt", "respawn"},
+}
+
+func ConfigSchema() ([]byte, error) {
+	schema := jsonSchemaFor(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "mono.yml"
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func jsonSchemaFor(t reflect.Type, path string) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = jsonSchemaFor(f.Type, joinPath(path, name))
+			if f.Tag.Get("mono") == "required" {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": jsonSchemaFor(t.Elem(), joinPath(path, "*")),
+		}
+	case reflect.Slice:
+		return map[string]any{
+			"type":  "array",
+			"items": jsonSchemaFor(t.Elem(), path),
+		}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	default:
+		schema := map[string]any{"type": "string"}
+		if values := configEnums[path]; len(values) > 0 {
+			schema["enum"] = values
+		}
+		return schema
+	}
+}
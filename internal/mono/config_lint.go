@@ -0,0 +1,335 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+type LintIssue struct {
+	Severity string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return i.Severity + ": " + i.Message
+}
+
+type configLinter struct {
+	issues []LintIssue
+}
+
+func (l *configLinter) errorf(format string, args ...any) {
+	l.issues = append(l.issues, LintIssue{Severity: LintError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *configLinter) warnf(format string, args ...any) {
+	l.issues = append(l.issues, LintIssue{Severity: LintWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *configLinter) check(err error) {
+	if err != nil {
+		l.errorf("%v", err)
+	}
+}
+
+func LintConfig(path string) ([]LintIssue, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyDefaults(path)
+
+	l := &configLinter{}
+	l.check(cfg.Build.Validate())
+	l.check(cfg.Ports.Validate())
+	l.check(cfg.ValidateServices())
+	l.check(cfg.ValidatePlugins())
+	l.check(cfg.Nix.Validate())
+	l.check(cfg.Resources.Validate())
+	l.check(cfg.Kubernetes.Validate())
+	l.check(cfg.Images.Validate())
+	l.check(cfg.TLS.Validate())
+	l.check(cfg.ValidateWatch())
+	l.check(cfg.ValidateTasks())
+	l.check(cfg.Test.Validate())
+	l.check(cfg.Notify.Validate())
+	l.check(cfg.Tracing.Validate())
+	l.check(cfg.Retry.Validate())
+	l.check(cfg.ValidateRemotes())
+	l.check(cfg.BuildQueue.Validate())
+	if _, err := NewContainerRuntime(cfg.Runtime); err != nil {
+		l.errorf("%v", err)
+	}
+
+	l.lintArtifactOverlap(cfg.Build.Artifacts)
+	l.lintKeyFiles(cfg.Build.Artifacts, path)
+
+	composePorts := map[string][]PortRequest{}
+	var compose *ComposeConfig
+	composeDir := cfg.ResolveComposeDir(path)
+	if _, err := DetectComposeFile(composeDir); err == nil {
+		compose, err = ParseComposeConfig(composeDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compose config: %w", err)
+		}
+		composePorts = compose.GetServicePorts()
+	}
+	l.lintServices(cfg, compose)
+	l.lintBuildxArtifacts(cfg.Build.Artifacts, compose)
+	l.lintComposeProfiles(cfg, compose)
+
+	monoPorts, err := monoServicePorts(cfg.Services, cfg.Kubernetes)
+	if err != nil {
+		l.errorf("%v", err)
+		return l.issues, nil
+	}
+	ports, err := mergeServicePorts(composePorts, monoPorts)
+	if err != nil {
+		l.errorf("%v", err)
+	} else if cfg.Ports.Mode != PortModeEphemeral {
+		l.lintSlotRange(ports)
+	}
+
+	return l.issues, nil
+}
+
+func (l *configLinter) lintArtifactOverlap(artifacts []ArtifactConfig) {
+	type owned struct {
+		artifact string
+		path     string
+	}
+	var paths []owned
+	for _, a := range artifacts {
+		for _, p := range a.Paths {
+			paths = append(paths, owned{artifact: a.Name, path: filepath.Clean(p)})
+		}
+	}
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			a, b := paths[i], paths[j]
+			if !pathWithin(a.path, b.path) && !pathWithin(b.path, a.path) {
+				continue
+			}
+			if a.artifact == b.artifact {
+				l.errorf("artifact %s lists overlapping paths %s and %s", a.artifact, a.path, b.path)
+				continue
+			}
+			l.errorf("artifacts %s and %s both cache %s", a.artifact, b.artifact, overlapOf(a.path, b.path))
+		}
+	}
+}
+
+func pathWithin(path, dir string) bool {
+	return path == dir || dir == "." || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func overlapOf(a, b string) string {
+	if pathWithin(a, b) {
+		return a
+	}
+	return b
+}
+
+func (l *configLinter) lintKeyFiles(artifacts []ArtifactConfig, envPath string) {
+	for _, a := range artifacts {
+		for _, keyFile := range a.KeyFiles {
+			if filepath.Base(keyFile) == LocalConfigFile {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(envPath, keyFile)); os.IsNotExist(err) {
+				l.warnf("artifact %s: key file %s does not exist", a.Name, keyFile)
+			} else if err != nil {
+				l.errorf("artifact %s: failed to check key file %s: %v", a.Name, keyFile, err)
+			}
+		}
+		if len(a.KeyCommands) > 0 || a.ArtifactType() == ArtifactBuildx {
+			continue
+		}
+		for _, p := range a.Paths {
+			if !keyFilesCover(a.KeyFiles, p) {
+				l.warnf("artifact %s: no key file lives next to or above %s, so changes that affect it won't change the cache key", a.Name, p)
+			}
+		}
+	}
+}
+
+func (l *configLinter) lintBuildxArtifacts(artifacts []ArtifactConfig, compose *ComposeConfig) {
+	for _, a := range artifacts {
+		if a.ArtifactType() != ArtifactBuildx {
+			continue
+		}
+		if compose == nil {
+			l.errorf("artifact %s caches builds of compose service %s, but there is no compose file", a.Name, a.Service)
+			continue
+		}
+		svc, ok := compose.Project().AllServices()[a.Service]
+		if !ok {
+			l.errorf("artifact %s: compose service %s does not exist", a.Name, a.Service)
+		} else if svc.Build == nil {
+			l.errorf("artifact %s: compose service %s has no build section", a.Name, a.Service)
+		}
+	}
+}
+
+func (l *configLinter) lintComposeProfiles(cfg *Config, compose *ComposeConfig) {
+	type selection struct {
+		owner    string
+		profiles []string
+	}
+	selections := []selection{{"compose_profiles", cfg.ComposeProfiles}}
+	for _, name := range sortedKeys(cfg.Templates) {
+		selections = append(selections, selection{"template " + name, cfg.Templates[name].ComposeProfiles})
+	}
+	for _, name := range sortedKeys(cfg.Profiles) {
+		selections = append(selections, selection{"profile " + name, cfg.Profiles[name].ComposeProfiles})
+	}
+
+	known := make(map[string]bool)
+	if compose != nil {
+		for _, svc := range compose.Project().AllServices() {
+			for _, p := range svc.Profiles {
+				known[p] = true
+			}
+		}
+	}
+	for _, sel := range selections {
+		for _, p := range sel.profiles {
+			if compose == nil {
+				l.errorf("%s enables compose profile %s, but there is no compose file", sel.owner, p)
+			} else if p != "*" && !known[p] {
+				l.errorf("%s enables compose profile %s, which no compose service declares", sel.owner, p)
+			}
+		}
+	}
+}
+
+func keyFilesCover(keyFiles []string, path string) bool {
+	parent := filepath.Dir(filepath.Clean(path))
+	for _, keyFile := range keyFiles {
+		if pathWithin(parent, filepath.Dir(filepath.Clean(keyFile))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *configLinter) lintServices(cfg *Config, compose *ComposeConfig) {
+	defined := make(map[string]bool)
+	dependencies := make(map[string][]string)
+	if compose != nil {
+		all := compose.Project().AllServices()
+		for _, name := range sortedKeys(all) {
+			svc := all[name]
+			defined[name] = true
+			dependencies[name] = sortedKeys(svc.DependsOn)
+			if svc.NetworkMode == "host" {
+				l.errorf("compose service %s uses network_mode: host, so its ports would collide across environments", name)
+			}
+		}
+	}
+	for _, name := range sortedKeys(cfg.HealthChecks) {
+		if !defined[name] {
+			l.errorf("health_checks.%s: service %s is not defined in the compose file", name, name)
+		}
+	}
+	for _, name := range sortedKeys(cfg.Resources.Services) {
+		if !defined[name] {
+			l.errorf("resources.services.%s: service %s is not defined in the compose file", name, name)
+		}
+	}
+	for _, s := range cfg.Services {
+		defined[s.Name] = true
+		dependencies[s.Name] = s.DependsOn
+	}
+	graph := make(map[string][]string, len(dependencies))
+	for _, name := range sortedKeys(dependencies) {
+		graph[name] = []string{}
+		for _, dep := range dependencies[name] {
+			if !defined[dep] {
+				l.errorf("service %s depends on %s, which is not defined in mono.yml or the compose file", name, dep)
+				continue
+			}
+			graph[name] = append(graph[name], dep)
+		}
+	}
+	if _, err := serviceStages(graph); err != nil && cfg.ValidateServices() == nil {
+		l.errorf("%v", err)
+	}
+
+	type selection struct {
+		owner    string
+		services []string
+	}
+	var selections []selection
+	for _, name := range sortedKeys(cfg.Templates) {
+		selections = append(selections, selection{"template " + name, cfg.Templates[name].Services})
+	}
+	for _, name := range sortedKeys(cfg.Profiles) {
+		selections = append(selections, selection{"profile " + name, cfg.Profiles[name].Services})
+	}
+	for _, p := range cfg.Platforms {
+		selections = append(selections, selection{"platform " + p.When, p.Services})
+	}
+
+	for _, sel := range selections {
+		for _, name := range sel.services {
+			if !defined[name] {
+				l.errorf("%s selects service %s, which is not defined in mono.yml or the compose file", sel.owner, name)
+			}
+		}
+	}
+	if len(cfg.Templates) == 0 {
+		return
+	}
+
+	used := make(map[string]bool)
+	var markUsed func(name string)
+	markUsed = func(name string) {
+		if used[name] {
+			return
+		}
+		used[name] = true
+		for _, dep := range dependencies[name] {
+			markUsed(dep)
+		}
+	}
+	for _, tpl := range cfg.Templates {
+		if tpl.Services == nil {
+			return
+		}
+		for _, name := range tpl.Services {
+			markUsed(name)
+		}
+	}
+
+	for _, name := range sortedKeys(defined) {
+		if !used[name] {
+			l.warnf("service %s is not selected by any template, so it only runs when no template is given", name)
+		}
+	}
+}
+
+func (l *configLinter) lintSlotRange(servicePorts map[string][]PortRequest) {
+	totals := make(map[string]int)
+	for _, service := range sortedKeys(servicePorts) {
+		for _, r := range servicePorts[service] {
+			r = r.normalized()
+			if r.Count > PortRangePerWorktree {
+				l.errorf("service %s requests %d ports for %d, more than the %d each environment's slot holds", service, r.Count, r.Port, PortRangePerWorktree)
+			}
+			totals[r.Protocol] += r.Count
+		}
+	}
+	for _, protocol := range sortedKeys(totals) {
+		if totals[protocol] > PortRangePerWorktree {
+			l.errorf("services request %d %s ports in total, more than the %d each environment's slot holds; use ports.mode: ephemeral or drop some", totals[protocol], protocol, PortRangePerWorktree)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const CurrentConfigVersion = 1
+
+type configMigration struct {
+	from  int
+	apply func(root *yaml.Node) []string
+}
+
+var configMigrations []configMigration
+
+func configVersion(root *yaml.Node) (int, *yaml.Node, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "version" {
+			continue
+		}
+		value := root.Content[i+1]
+		version, err := strconv.Atoi(value.Value)
+		if err != nil || version < 1 {
+			return 0, value, fmt.Errorf("line %d: version must be a positive number, got %q", value.Line, value.Value)
+		}
+		return version, value, nil
+	}
+	return 1, nil, nil
+}
+
+func migrateConfigNode(file string, root *yaml.Node) ([]string, error) {
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	version, node, err := configVersion(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", file, err)
+	}
+	if version > CurrentConfigVersion {
+		return nil, fmt.Errorf("%s uses config version %d but this mono only understands up to version %d, upgrade mono", file, version, CurrentConfigVersion)
+	}
+
+	var changes []string
+	for _, m := range configMigrations {
+		if m.from < version {
+			continue
+		}
+		for _, change := range m.apply(root) {
+			changes = append(changes, fmt.Sprintf("v%d -> v%d: %s", m.from, m.from+1, change))
+		}
+		version = m.from + 1
+	}
+
+	if node != nil {
+		node.Value = strconv.Itoa(version)
+	}
+	return changes, nil
+}
+
+func moveConfigKey(root *yaml.Node, from, to string) bool {
+	parent, index := findConfigKey(root, strings.Split(from, "."))
+	if parent == nil {
+		return false
+	}
+	key, value := parent.Content[index], parent.Content[index+1]
+	parent.Content = append(parent.Content[:index], parent.Content[index+2:]...)
+
+	target := strings.Split(to, ".")
+	node := root
+	for _, name := range target[:len(target)-1] {
+		next, i := findConfigKey(node, []string{name})
+		if next == nil {
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, child)
+			node = child
+			continue
+		}
+		node = next.Content[i+1]
+	}
+	key.Value = target[len(target)-1]
+	node.Content = append(node.Content, key, value)
+	return true
+}
+
+func findConfigKey(node *yaml.Node, path []string) (*yaml.Node, int) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return node, i
+		}
+		if node.Content[i+1].Kind != yaml.MappingNode {
+			return nil, 0
+		}
+		return findConfigKey(node.Content[i+1], path[1:])
+	}
+	return nil, 0
+}
+
+type MigrateResult struct {
+	File    string
+	Changes []string
+	Output  []byte
+}
+
+func MigrateConfig(dir string) (*MigrateResult, error) {
+	path := filepath.Join(dir, "mono.yml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid %s: the top level must be a mapping", path)
+	}
+
+	_, node, err := configVersion(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	changes, err := migrateConfigNode("mono.yml", root)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		root.Content = append([]*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(CurrentConfigVersion)},
+		}, root.Content...)
+		changes = append(changes, fmt.Sprintf("added version: %d", CurrentConfigVersion))
+	}
+
+	result := &MigrateResult{File: path, Changes: changes}
+	if len(changes) == 0 {
+		return result, nil
+	}
+
+	var out strings.Builder
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	result.Output = []byte(out.String())
+
+	var cfg Config
+	if err := decodeConfigFile("mono.yml", result.Output, &cfg); err != nil {
+		return nil, fmt.Errorf("migration produced an invalid config: %w", err)
+	}
+	return result, nil
+}
+
+func WriteMigratedConfig(result *MigrateResult) error {
+	info, err := os.Stat(result.File)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", result.File, err)
+	}
+	if err := os.WriteFile(result.File, result.Output, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", result.File, err)
+	}
+	return nil
+}
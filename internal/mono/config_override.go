@@ -0,0 +1,169 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configOverrideEnvPrefix = "MONO_"
+
+type configOverride struct {
+	source string
+	path   string
+	value  *yaml.Node
+}
+
+var flagOverrides []configOverride
+
+func SetConfigOverrides(sets []string) error {
+	var overrides []configOverride
+	for _, set := range sets {
+		path, value, ok := strings.Cut(set, "=")
+		if !ok || path == "" {
+			return fmt.Errorf("invalid --set %q: expected key=value", set)
+		}
+		override, err := newConfigOverride("--set "+path, path, value)
+		if err != nil {
+			return err
+		}
+		overrides = append(overrides, override)
+	}
+	flagOverrides = overrides
+	return nil
+}
+
+func newConfigOverride(source, path, value string) (configOverride, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return configOverride{}, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	if len(doc.Content) > 0 {
+		node = doc.Content[0]
+	}
+	if value == "" {
+		node = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+	}
+	clearLines(node)
+	return configOverride{source: source, path: path, value: node}, nil
+}
+
+func clearLines(node *yaml.Node) {
+	node.Line, node.Column = 0, 0
+	for _, child := range node.Content {
+		clearLines(child)
+	}
+}
+
+func envConfigOverrides() ([]configOverride, error) {
+	var overrides []configOverride
+	for _, path := range configLeafPaths(reflect.TypeOf(UserConfig{}), "") {
+		name := ConfigEnvVarName(path)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		override, err := newConfigOverride(name, path, value)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
+
+func ConfigEnvVarName(path string) string {
+	return configOverrideEnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+func configLeafPaths(t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return []string{path}
+	}
+
+	fields := make(map[string]reflect.StructField)
+	var names []string
+	collectYAMLFields(t, fields, &names)
+	sort.Strings(names)
+
+	var paths []string
+	for _, name := range names {
+		switch joinPath(path, name) {
+		case "extends", "include", "version":
+			continue
+		}
+		ft := fields[name].Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Map:
+			continue
+		case reflect.Slice:
+			if ft.Elem().Kind() == reflect.Struct {
+				continue
+			}
+		}
+		paths = append(paths, configLeafPaths(ft, joinPath(path, name))...)
+	}
+	return paths
+}
+
+func configOverrides() ([]configOverride, error) {
+	overrides, err := envConfigOverrides()
+	if err != nil {
+		return nil, err
+	}
+	return append(overrides, flagOverrides...), nil
+}
+
+func applyConfigOverrides(out any, sources ConfigSources) error {
+	overrides, err := configOverrides()
+	if err != nil {
+		return err
+	}
+
+	for _, o := range overrides {
+		switch top, _, _ := strings.Cut(o.path, "."); top {
+		case "extends", "include", "version":
+			return fmt.Errorf("invalid %s: %s cannot be overridden", o.source, top)
+		}
+		root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		node := root
+		keys := strings.Split(o.path, ".")
+		for i, key := range keys {
+			if key == "" {
+				return fmt.Errorf("invalid %s: empty key in %q", o.source, o.path)
+			}
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+			if i == len(keys)-1 {
+				node.Content = append(node.Content, keyNode, o.value)
+				break
+			}
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, keyNode, child)
+			node = child
+		}
+
+		v := &schemaValidator{file: o.source}
+		v.check(root, reflect.TypeOf(UserConfig{}), "")
+		if len(v.problems) > 0 {
+			return &ConfigError{File: o.source, Problems: v.problems}
+		}
+		if err := root.Decode(out); err != nil {
+			return fmt.Errorf("invalid %s: %w", o.source, err)
+		}
+		if sources != nil {
+			sources[o.path] = o.source
+		}
+	}
+	return nil
+}
@@ -0,0 +1,449 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type configState struct {
+	ComposeDir string
+	Scripts    Scripts
+	Env        map[string]string
+	Artifacts  map[string]ArtifactConfig
+	Services   []string
+	Ports      map[string][]PortRequest
+}
+
+func loadConfigState(env *Environment) (*configState, error) {
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyDefaults(env.Path)
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return nil, err
+	}
+
+	state := &configState{
+		ComposeDir: cfg.ComposeDir,
+		Scripts:    cfg.Scripts,
+		Env:        cfg.Env,
+		Artifacts:  make(map[string]ArtifactConfig),
+		Services:   cfg.SelectedServices(),
+		Ports:      make(map[string][]PortRequest),
+	}
+	for _, a := range cfg.Build.Artifacts {
+		state.Artifacts[a.Name] = a
+	}
+
+	composePorts := map[string][]PortRequest{}
+	composeDir := cfg.ResolveComposeDir(env.Path)
+	if _, err := DetectComposeFile(composeDir); err == nil {
+		composeConfig, err := ParseComposeConfig(composeDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compose config: %w", err)
+		}
+		if err := cfg.SelectCompose(composeConfig); err != nil {
+			return nil, err
+		}
+		state.Services = composeConfig.GetServiceNames()
+		composePorts = composeConfig.GetServicePorts()
+	}
+
+	processServices := cfg.SelectedProcessServices()
+	for _, s := range processServices {
+		state.Services = append(state.Services, s.Name)
+	}
+	monoPorts, err := monoServicePorts(processServices, cfg.Kubernetes)
+	if err != nil {
+		return nil, err
+	}
+	servicePorts, err := mergeServicePorts(composePorts, monoPorts)
+	if err != nil {
+		return nil, err
+	}
+	for service, requests := range servicePorts {
+		for _, req := range requests {
+			state.Ports[service] = append(state.Ports[service], req.normalized())
+		}
+	}
+	return state, nil
+}
+
+type configDiff struct {
+	EnvSet           []string
+	EnvRemoved       []string
+	ArtifactsAdded   []string
+	ArtifactsChanged []string
+	ArtifactsRemoved []string
+	ServicesAdded    []string
+	ServicesRemoved  []string
+	PortsAdded       map[string][]PortRequest
+	PortsRemoved     map[string][]PortRequest
+	ScriptsChanged   []string
+	ComposeDirMoved  bool
+}
+
+func (d *configDiff) empty() bool {
+	return len(d.EnvSet) == 0 && len(d.EnvRemoved) == 0 &&
+		len(d.ArtifactsAdded) == 0 && len(d.ArtifactsChanged) == 0 && len(d.ArtifactsRemoved) == 0 &&
+		len(d.ServicesAdded) == 0 && len(d.ServicesRemoved) == 0 &&
+		len(d.PortsAdded) == 0 && len(d.PortsRemoved) == 0 &&
+		len(d.ScriptsChanged) == 0 && !d.ComposeDirMoved
+}
+
+func diffConfigState(old, cur *configState) *configDiff {
+	d := &configDiff{
+		PortsAdded:      make(map[string][]PortRequest),
+		PortsRemoved:    make(map[string][]PortRequest),
+		ComposeDirMoved: old.ComposeDir != cur.ComposeDir,
+	}
+
+	for k, v := range cur.Env {
+		if prev, ok := old.Env[k]; !ok || prev != v {
+			d.EnvSet = append(d.EnvSet, k)
+		}
+	}
+	for k := range old.Env {
+		if _, ok := cur.Env[k]; !ok {
+			d.EnvRemoved = append(d.EnvRemoved, k)
+		}
+	}
+
+	for name, a := range cur.Artifacts {
+		prev, ok := old.Artifacts[name]
+		switch {
+		case !ok:
+			d.ArtifactsAdded = append(d.ArtifactsAdded, name)
+		case !sameArtifact(prev, a):
+			d.ArtifactsChanged = append(d.ArtifactsChanged, name)
+		}
+	}
+	for name := range old.Artifacts {
+		if _, ok := cur.Artifacts[name]; !ok {
+			d.ArtifactsRemoved = append(d.ArtifactsRemoved, name)
+		}
+	}
+
+	for _, s := range cur.Services {
+		if !slices.Contains(old.Services, s) {
+			d.ServicesAdded = append(d.ServicesAdded, s)
+		}
+	}
+	for _, s := range old.Services {
+		if !slices.Contains(cur.Services, s) {
+			d.ServicesRemoved = append(d.ServicesRemoved, s)
+		}
+	}
+
+	for service, requests := range cur.Ports {
+		for _, req := range requests {
+			if !slices.Contains(old.Ports[service], req) {
+				d.PortsAdded[service] = append(d.PortsAdded[service], req)
+			}
+		}
+	}
+	for service, requests := range old.Ports {
+		for _, req := range requests {
+			if !slices.Contains(cur.Ports[service], req) {
+				d.PortsRemoved[service] = append(d.PortsRemoved[service], req)
+			}
+		}
+	}
+
+	scripts := map[string][2]string{
+		"init":    {old.Scripts.Init, cur.Scripts.Init},
+		"setup":   {old.Scripts.Setup, cur.Scripts.Setup},
+		"run":     {old.Scripts.Run, cur.Scripts.Run},
+		"destroy": {old.Scripts.Destroy, cur.Scripts.Destroy},
+	}
+	for name, pair := range scripts {
+		if pair[0] != pair[1] {
+			d.ScriptsChanged = append(d.ScriptsChanged, name)
+		}
+	}
+
+	for _, list := range [][]string{d.EnvSet, d.EnvRemoved, d.ArtifactsAdded, d.ArtifactsChanged, d.ArtifactsRemoved, d.ScriptsChanged} {
+		sort.Strings(list)
+	}
+	return d
+}
+
+func sameArtifact(a, b ArtifactConfig) bool {
+	return slices.Equal(a.KeyFiles, b.KeyFiles) &&
+		slices.Equal(a.KeyCommands, b.KeyCommands) &&
+		slices.Equal(a.Paths, b.Paths) &&
+		a.RestoreStrategy() == b.RestoreStrategy()
+}
+
+func (d *configDiff) needsInit() []string {
+	var reasons []string
+	if d.ComposeDirMoved {
+		reasons = append(reasons, "compose_dir changed")
+	}
+	for _, name := range d.ScriptsChanged {
+		if name == "init" || name == "setup" {
+			reasons = append(reasons, fmt.Sprintf("scripts.%s changed", name))
+		}
+	}
+	for _, name := range d.ArtifactsChanged {
+		reasons = append(reasons, fmt.Sprintf("artifact %s changed", name))
+	}
+	for _, name := range d.ArtifactsRemoved {
+		reasons = append(reasons, fmt.Sprintf("artifact %s removed", name))
+	}
+	for _, name := range d.ServicesAdded {
+		reasons = append(reasons, fmt.Sprintf("service %s added", name))
+	}
+	for _, name := range d.ServicesRemoved {
+		reasons = append(reasons, fmt.Sprintf("service %s removed", name))
+	}
+	for _, service := range sortedKeys(d.PortsRemoved) {
+		for _, req := range d.PortsRemoved[service] {
+			reasons = append(reasons, fmt.Sprintf("port %d/%s of %s removed", req.Port, req.Protocol, service))
+		}
+	}
+	for _, name := range d.EnvRemoved {
+		reasons = append(reasons, fmt.Sprintf("env.%s removed", name))
+	}
+	return reasons
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type ConfigReloadResult struct {
+	Env       string
+	Applied   []string
+	NeedsInit []string
+}
+
+type ConfigReloader struct {
+	logger *FileLogger
+	states map[int64]*configState
+}
+
+func NewConfigReloader(logger *FileLogger) *ConfigReloader {
+	return &ConfigReloader{logger: logger, states: make(map[int64]*configState)}
+}
+
+func (r *ConfigReloader) Check(envs []*Environment) []ConfigReloadResult {
+	var results []ConfigReloadResult
+	seen := make(map[int64]bool)
+	for _, env := range envs {
+		seen[env.ID] = true
+		cur, err := loadConfigState(env)
+		if err != nil {
+			r.logger.Log("warning: failed to reload config for %s: %v", env.EnvName(), err)
+			continue
+		}
+		old, ok := r.states[env.ID]
+		r.states[env.ID] = cur
+		if !ok {
+			continue
+		}
+		diff := diffConfigState(old, cur)
+		if diff.empty() {
+			continue
+		}
+
+		result := ConfigReloadResult{
+			Env:       env.EnvName(),
+			Applied:   r.apply(env, cur, diff),
+			NeedsInit: diff.needsInit(),
+		}
+		for _, change := range result.Applied {
+			r.logger.Log("%s: %s", result.Env, change)
+		}
+		if len(result.NeedsInit) > 0 {
+			r.logger.Log("%s: config changes need a full re-init (mono destroy && mono init): %s", result.Env, strings.Join(result.NeedsInit, ", "))
+		}
+		results = append(results, result)
+	}
+	r.forgetExcept(seen)
+	return results
+}
+
+func (r *ConfigReloader) Discover(envs []*Environment) []*Environment {
+	var added []*Environment
+	seen := make(map[int64]bool)
+	for _, env := range envs {
+		seen[env.ID] = true
+		if _, ok := r.states[env.ID]; ok {
+			continue
+		}
+		cur, err := loadConfigState(env)
+		if err != nil {
+			r.logger.Log("warning: failed to reload config for %s: %v", env.EnvName(), err)
+			continue
+		}
+		r.states[env.ID] = cur
+		added = append(added, env)
+	}
+	r.forgetExcept(seen)
+	return added
+}
+
+func (r *ConfigReloader) forgetExcept(seen map[int64]bool) {
+	for id := range r.states {
+		if !seen[id] {
+			delete(r.states, id)
+		}
+	}
+}
+
+func (r *ConfigReloader) apply(env *Environment, cur *configState, diff *configDiff) []string {
+	var applied []string
+	envName := env.EnvName()
+
+	var changedVars []string
+	for _, service := range sortedKeys(diff.PortsAdded) {
+		if slices.Contains(diff.ServicesAdded, service) {
+			continue
+		}
+		for _, req := range diff.PortsAdded[service] {
+			alloc, err := ReservePort(envName, service, req)
+			if err != nil {
+				r.logger.Log("warning: %s: failed to reserve port %d/%s for %s: %v", envName, req.Port, req.Protocol, service, err)
+				continue
+			}
+			applied = append(applied, fmt.Sprintf("reserved %s", alloc))
+			changedVars = append(changedVars, PortEnvVarName(service), "MONO_PORTS")
+		}
+	}
+
+	if len(diff.ArtifactsAdded) > 0 {
+		applied = append(applied, r.restoreArtifacts(env, cur, diff.ArtifactsAdded)...)
+	}
+
+	changedVars = append(changedVars, diff.EnvSet...)
+	if len(changedVars) == 0 {
+		return applied
+	}
+
+	ec, err := LoadEnvContext(env.Path)
+	if err != nil {
+		r.logger.Log("warning: %s: failed to load environment: %v", envName, err)
+		return applied
+	}
+
+	sessionName := SessionName(envName)
+	if SessionExists(sessionName) {
+		secrets, err := ResolveSecrets(ec.Config.Env)
+		if err != nil {
+			r.logger.Log("warning: %s: %v", envName, err)
+			return applied
+		}
+		var vars []string
+		for _, kv := range withSecrets(ec.Vars, secrets) {
+			if k, _, ok := strings.Cut(kv, "="); ok && slices.Contains(changedVars, k) {
+				vars = append(vars, kv)
+			}
+		}
+		if err := SetSessionEnv(sessionName, vars); err != nil {
+			r.logger.Log("warning: %s: %v", envName, err)
+		} else if len(diff.EnvSet) > 0 {
+			applied = append(applied, fmt.Sprintf("updated %s in tmux session %s", strings.Join(diff.EnvSet, ", "), sessionName))
+		}
+	}
+
+	refreshEnvFiles(env.Path, r.logger)
+	return applied
+}
+
+func (r *ConfigReloader) restoreArtifacts(env *Environment, cur *configState, names []string) []string {
+	envName := env.EnvName()
+	if env.RootPath.String == "" {
+		return nil
+	}
+
+	var artifacts []ArtifactConfig
+	for _, name := range names {
+		artifacts = append(artifacts, cur.Artifacts[name])
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		r.logger.Log("warning: %s: failed to initialize cache: %v", envName, err)
+		return nil
+	}
+	entries, err := cm.PrepareArtifactCache(artifacts, env.RootPath.String, env.Path)
+	if err != nil {
+		r.logger.Log("warning: %s: failed to prepare artifact cache: %v", envName, err)
+		return nil
+	}
+
+	var applied []string
+	for _, entry := range entries {
+		if !entry.Hit {
+			r.logger.Log("%s: no cached copy of %s (key: %s), it will be built by the next init", envName, entry.Name, entry.Key)
+			continue
+		}
+		if artifactPresent(entry) {
+			continue
+		}
+		if err := cm.RestoreFromCache(entry, r.logger); err != nil {
+			r.logger.Log("warning: %s: %v", envName, err)
+			continue
+		}
+		applied = append(applied, fmt.Sprintf("restored %s from cache (key: %s)", entry.Name, entry.Key))
+		if _, err := cm.restoreArtifactCrates(artifacts, entry.Name, env.RootPath.String, env.Path, true, r.logger); err != nil {
+			r.logger.Log("warning: %s: %v", envName, err)
+		}
+	}
+	return applied
+}
+
+func artifactPresent(entry ArtifactCacheEntry) bool {
+	for _, p := range entry.EnvPaths {
+		if _, err := os.Lstat(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func reloadEnvironmentsUnder(r *ConfigReloader, dir string) ([]ConfigReloadResult, error) {
+	envs, err := environmentsUnder(dir)
+	if err != nil {
+		return nil, err
+	}
+	return r.Check(envs), nil
+}
+
+func environmentsUnder(dir string) ([]*Environment, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	envs, err := db.ListEnvironments()
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		prefix := dir + string(filepath.Separator)
+		var filtered []*Environment
+		for _, env := range envs {
+			if strings.HasPrefix(env.Path, prefix) {
+				filtered = append(filtered, env)
+			}
+		}
+		envs = filtered
+	}
+	return envs, nil
+}
@@ -0,0 +1,219 @@
+package mono
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("invalid %s: %s", e.File, e.Problems[0])
+	}
+	return fmt.Sprintf("invalid %s:\n  %s", e.File, strings.Join(e.Problems, "\n  "))
+}
+
+func decodeConfigFile(file string, data []byte, out any) error {
+	node, err := parseConfigNode(file, data, out)
+	if err != nil || node == nil {
+		return err
+	}
+	if err := node.Decode(out); err != nil {
+		return fmt.Errorf("invalid %s: %w", file, err)
+	}
+	return nil
+}
+
+func parseConfigNode(file string, data []byte, out any) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", file, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	node := root.Content[0]
+	if _, ok := out.(*Config); ok {
+		if _, err := migrateConfigNode(file, node); err != nil {
+			return nil, err
+		}
+	}
+	v := &schemaValidator{file: file}
+	v.check(node, reflect.TypeOf(out).Elem(), "")
+	if len(v.problems) > 0 {
+		return nil, &ConfigError{File: file, Problems: v.problems}
+	}
+	return node, nil
+}
+
+type schemaValidator struct {
+	file     string
+	problems []string
+}
+
+func (v *schemaValidator) addf(node *yaml.Node, format string, args ...any) {
+	if node.Line == 0 {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+		return
+	}
+	v.problems = append(v.problems, fmt.Sprintf("%s:%d: %s", v.file, node.Line, fmt.Sprintf(format, args...)))
+}
+
+func (v *schemaValidator) check(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Tag == "!!null" {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			v.addf(node, "%s must be a mapping, got %s", describePath(path), describeNode(node))
+			return
+		}
+		v.checkStruct(node, t, path)
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			v.addf(node, "%s must be a mapping, got %s", describePath(path), describeNode(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.check(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			v.addf(node, "%s must be a list, got %s", describePath(path), describeNode(node))
+			return
+		}
+		for i, item := range node.Content {
+			v.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			v.addf(node, "%s must be true or false, got %s", describePath(path), describeNode(node))
+		}
+	case reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			v.addf(node, "%s must be a number, got %s", describePath(path), describeNode(node))
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode {
+			v.addf(node, "%s must be a string, got %s", describePath(path), describeNode(node))
+		}
+	}
+}
+
+func (v *schemaValidator) checkStruct(node *yaml.Node, t reflect.Type, path string) {
+	fields := make(map[string]reflect.StructField)
+	var names []string
+	collectYAMLFields(t, fields, &names)
+
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "<<" {
+			continue
+		}
+		f, ok := fields[key.Value]
+		if !ok {
+			msg := fmt.Sprintf("unknown field %q in %s", key.Value, describePath(path))
+			if s := suggestField(key.Value, names); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			v.addf(key, "%s", msg)
+			continue
+		}
+		seen[key.Value] = true
+		v.check(value, f.Type, joinPath(path, key.Value))
+	}
+
+	for _, name := range names {
+		if fields[name].Tag.Get("mono") == "required" && !seen[name] {
+			v.addf(node, "%s is missing required field %q", describePath(path), name)
+		}
+	}
+}
+
+func collectYAMLFields(t reflect.Type, fields map[string]reflect.StructField, names *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if opts == "inline" && f.Type.Kind() == reflect.Struct {
+			collectYAMLFields(f.Type, fields, names)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f
+		*names = append(*names, name)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "the top level"
+	}
+	return path
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func suggestField(name string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,296 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigSources map[string]string
+
+const redactedValue = "<redacted>"
+
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_?key|private_?key|credential|auth|webhook|dsn)`)
+
+func (s ConfigSources) recordNode(node *yaml.Node, path string, origins map[*yaml.Node]string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		p := joinPath(path, key.Value)
+		if value.Kind == yaml.MappingNode {
+			s.recordNode(value, p, origins)
+			continue
+		}
+		if file, ok := origins[value]; ok {
+			s[p] = file
+		}
+	}
+}
+
+func (s ConfigSources) recordLocal(local *LocalConfig) {
+	scripts := map[string]string{
+		"init":    local.Scripts.Init,
+		"setup":   local.Scripts.Setup,
+		"run":     local.Scripts.Run,
+		"destroy": local.Scripts.Destroy,
+	}
+	for name, script := range scripts {
+		if script != "" {
+			s["scripts."+name] = LocalConfigFile
+		}
+	}
+	for k := range local.Env {
+		s["env."+k] = LocalConfigFile
+	}
+	if len(local.DisabledArtifacts) > 0 {
+		s.note("build.artifacts", "filtered by "+LocalConfigFile)
+	}
+	if local.ComposeProfiles != nil {
+		s["compose_profiles"] = LocalConfigFile
+	}
+}
+
+func (s ConfigSources) note(path, note string) {
+	if s[path] == "" {
+		s[path] = note
+		return
+	}
+	s[path] += ", " + note
+}
+
+type ShowConfigOptions struct {
+	Template string
+	Profile  string
+	Redact   bool
+}
+
+func ShowConfig(path string, opts ShowConfigOptions) (string, error) {
+	dir := path
+	var header []string
+	var allocations []Allocation
+
+	db, err := OpenDB()
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByPath(path)
+	switch {
+	case errors.Is(err, ErrEnvironmentNotFound):
+		env = nil
+	case err != nil:
+		return "", err
+	default:
+		dir = env.Path
+		header = append(header, "environment: "+env.EnvName())
+		allocations, err = db.GetAllocations(env.ID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	templateOrigin := "--template"
+	if opts.Template == "" && env != nil && env.Template.String != "" {
+		opts.Template, templateOrigin = env.Template.String, "recorded at init"
+	}
+	profileOrigin := "--profile"
+	switch {
+	case opts.Profile != "":
+	case env != nil && env.Profile.String != "":
+		opts.Profile, profileOrigin = env.Profile.String, "recorded at init"
+	case env == nil && os.Getenv(ProfileEnvVar) != "":
+		opts.Profile, profileOrigin = os.Getenv(ProfileEnvVar), ProfileEnvVar
+	}
+
+	sources := make(ConfigSources)
+	cfg, err := loadConfig(dir, sources)
+	if err != nil {
+		return "", err
+	}
+
+	detected := len(cfg.Build.Artifacts) == 0
+	cfg.ApplyDefaults(dir)
+	if detected && len(cfg.Build.Artifacts) > 0 {
+		sources["build.artifacts"] = "detected from lockfiles"
+	}
+
+	if err := cfg.ApplyTemplate(opts.Template); err != nil {
+		return "", err
+	}
+	if opts.Template != "" {
+		header = append(header, fmt.Sprintf("template: %s (%s)", opts.Template, templateOrigin))
+		tpl := cfg.Templates[opts.Template]
+		if tpl.Artifacts != nil {
+			sources.note("build.artifacts", "filtered by template "+opts.Template)
+		}
+		if tpl.Windows != nil {
+			sources.note("tmux.windows", "filtered by template "+opts.Template)
+		}
+		if tpl.ComposeProfiles != nil && !cfg.localComposeProfiles {
+			sources["compose_profiles"] = "template " + opts.Template
+		}
+	}
+
+	if err := cfg.ApplyProfile(opts.Profile); err != nil {
+		return "", err
+	}
+	if opts.Profile != "" {
+		header = append(header, fmt.Sprintf("profile: %s (%s)", opts.Profile, profileOrigin))
+		profile := cfg.Profiles[opts.Profile]
+		if profile.Artifacts != nil {
+			sources.note("build.artifacts", "filtered by profile "+opts.Profile)
+		}
+		if profile.Sccache != nil {
+			sources["build.sccache"] = "profile " + opts.Profile
+		}
+		if profile.ComposeProfiles != nil && !cfg.localComposeProfiles {
+			sources["compose_profiles"] = "profile " + opts.Profile
+		}
+	}
+
+	if services := cfg.SelectedServices(); len(services) > 0 {
+		header = append(header, "services: "+strings.Join(services, ", "))
+	}
+
+	if env != nil {
+		if err := cfg.ResolvePorts(allocations); err != nil {
+			return "", fmt.Errorf("invalid mono.yml: %w", err)
+		}
+	}
+
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	annotateConfigNode(&root, "", sources)
+	if opts.Redact {
+		redactConfigNode(&root, false)
+	}
+
+	header = append([]string{"effective config for " + filepath.Clean(dir)}, header...)
+	root.HeadComment = strings.Join(header, "\n")
+
+	var out strings.Builder
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	return out.String(), nil
+}
+
+func annotateConfigNode(node *yaml.Node, path string, sources ConfigSources) {
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		p := joinPath(path, key.Value)
+		source, ok := sources[p]
+
+		if value.Kind == yaml.MappingNode && !ok {
+			annotateConfigNode(value, p, sources)
+			if len(value.Content) == 0 {
+				continue
+			}
+			kept = append(kept, key, value)
+			continue
+		}
+
+		if !ok {
+			if isEmptyNode(value) {
+				continue
+			}
+			source = "default"
+		}
+		compactNode(value)
+		if value.Kind == yaml.ScalarNode || value.Style == yaml.FlowStyle {
+			value.LineComment = source
+		} else {
+			key.LineComment = source
+		}
+		kept = append(kept, key, value)
+	}
+	node.Content = kept
+}
+
+func redactConfigNode(node *yaml.Node, sensitive bool) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			redactConfigNode(node.Content[i+1], sensitive || key == "headers" || sensitiveKeyPattern.MatchString(key))
+		}
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			redactConfigNode(n, sensitive)
+		}
+	case yaml.ScalarNode:
+		node.Value = redactValue(node.Value, sensitive)
+	}
+}
+
+func redactValue(value string, sensitive bool) string {
+	if value == "" || IsSecretRef(value) {
+		return value
+	}
+	if sensitive {
+		return redactedValue
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	u.User = url.UserPassword(u.User.Username(), "redacted")
+	return u.String()
+}
+
+func compactNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		if isScalarList(node) {
+			node.Style = yaml.FlowStyle
+		}
+		for _, item := range node.Content {
+			compactNode(item)
+		}
+	case yaml.MappingNode:
+		var kept []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if isEmptyNode(node.Content[i+1]) {
+				continue
+			}
+			compactNode(node.Content[i+1])
+			kept = append(kept, node.Content[i], node.Content[i+1])
+		}
+		node.Content = kept
+	}
+}
+
+func isEmptyNode(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Tag == "!!null" || node.Value == "" || node.Value == "false" || node.Value == "0"
+	default:
+		return len(node.Content) == 0
+	}
+}
+
+func isScalarList(node *yaml.Node) bool {
+	for _, item := range node.Content {
+		if item.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+	return true
+}
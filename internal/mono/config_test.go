@@ -0,0 +1,1019 @@
+package mono
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadConfigLocalOverlay(t *testing.T) {
+	dir := t.TempDir()
+	monoYml := `scripts:
+  init: npm ci
+  run: npm start
+env:
+  A: project
+  B: project
+build:
+  artifacts:
+    - name: npm
+      key_files: [package-lock.json, .mono.local.yaml]
+      paths: [node_modules]
+    - name: cargo
+      key_files: [Cargo.lock]
+      paths: [target]
+`
+	local := `scripts:
+  run: npm run dev
+env:
+  B: local
+  C: local
+disabled_artifacts: [cargo]
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(monoYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigFile), []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	cfg.ApplyDefaults(dir)
+
+	if cfg.Scripts.Init != "npm ci" || cfg.Scripts.Run != "npm run dev" {
+		t.Errorf("scripts = %+v, want init from mono.yml and run from local", cfg.Scripts)
+	}
+	if cfg.Env["A"] != "project" || cfg.Env["B"] != "local" || cfg.Env["C"] != "local" {
+		t.Errorf("env = %v, want local values to win", cfg.Env)
+	}
+	if len(cfg.Build.Artifacts) != 1 || cfg.Build.Artifacts[0].Name != "npm" {
+		t.Fatalf("artifacts = %+v, want only npm", cfg.Build.Artifacts)
+	}
+
+	cm := &CacheManager{}
+	before, err := cm.ComputeCacheKey(cfg.Build.Artifacts[0], dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigFile), []byte("env: {D: x}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after, err := cm.ComputeCacheKey(cfg.Build.Artifacts[0], dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Errorf("cache key changed after editing %s", LocalConfigFile)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	cfg := &Config{
+		Build: BuildConfig{Artifacts: []ArtifactConfig{{Name: "cargo"}, {Name: "npm"}}},
+		Tmux:  TmuxConfig{Windows: []TmuxWindow{{Name: "api"}, {Name: "web"}}},
+		Templates: map[string]TemplateConfig{
+			"backend-only": {Artifacts: []string{"cargo"}, Services: []string{"api"}, Windows: []string{"api"}},
+			"full-stack":   {},
+		},
+	}
+
+	full := *cfg
+	if err := full.ApplyTemplate("full-stack"); err != nil {
+		t.Fatal(err)
+	}
+	if len(full.Build.Artifacts) != 2 || len(full.Tmux.Windows) != 2 || full.SelectedServices() != nil {
+		t.Errorf("empty template should keep everything, got %+v", full)
+	}
+
+	if err := cfg.ApplyTemplate("backend-only"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Build.Artifacts) != 1 || cfg.Build.Artifacts[0].Name != "cargo" {
+		t.Errorf("artifacts = %+v, want only cargo", cfg.Build.Artifacts)
+	}
+	if len(cfg.Tmux.Windows) != 1 || cfg.Tmux.Windows[0].Name != "api" {
+		t.Errorf("windows = %+v, want only api", cfg.Tmux.Windows)
+	}
+	if got := cfg.SelectedServices(); len(got) != 1 || got[0] != "api" {
+		t.Errorf("services = %v, want [api]", got)
+	}
+
+	if err := cfg.ApplyTemplate("frontend"); err == nil {
+		t.Error("expected error for unknown template")
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	enabled, disabled := true, false
+	base := Config{
+		Build: BuildConfig{Sccache: &enabled, Artifacts: []ArtifactConfig{{Name: "cargo"}, {Name: "npm"}}},
+		Templates: map[string]TemplateConfig{
+			"backend": {Services: []string{"api", "db"}},
+		},
+		Profiles: map[string]ProfileConfig{
+			"ci":      {Artifacts: []string{"cargo"}, Services: []string{"db", "redis"}, Sccache: &disabled},
+			"minimal": {Artifacts: []string{}},
+			"dev":     {},
+		},
+	}
+
+	dev := base
+	if err := dev.ApplyProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dev.Build.Artifacts) != 2 || !*dev.Build.Sccache || dev.SelectedServices() != nil {
+		t.Errorf("empty profile should keep everything, got %+v", dev)
+	}
+
+	minimal := base
+	if err := minimal.ApplyProfile("minimal"); err != nil {
+		t.Fatal(err)
+	}
+	if len(minimal.Build.Artifacts) != 0 {
+		t.Errorf("artifacts = %+v, want none", minimal.Build.Artifacts)
+	}
+
+	ci := base
+	if err := ci.ApplyTemplate("backend"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ci.ApplyProfile("ci"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ci.Build.Artifacts) != 1 || ci.Build.Artifacts[0].Name != "cargo" || *ci.Build.Sccache {
+		t.Errorf("build = %+v, want only cargo without sccache", ci.Build)
+	}
+	if got := ci.SelectedServices(); len(got) != 1 || got[0] != "db" {
+		t.Errorf("services = %v, want the template and profile overlap [db]", got)
+	}
+	if !*base.Build.Sccache {
+		t.Error("applying a profile must not modify the shared sccache setting")
+	}
+
+	if err := base.ApplyProfile("staging"); err == nil || !strings.Contains(err.Error(), "ci, dev, minimal") {
+		t.Errorf("expected unknown profile error listing profiles, got %v", err)
+	}
+}
+
+func TestComposeProfiles(t *testing.T) {
+	dir := t.TempDir()
+	monoYml := `compose_profiles: [observability]
+templates:
+  full:
+    compose_profiles: [observability, tools]
+`
+	compose := `services:
+  db:
+    image: postgres:16
+  jaeger:
+    image: jaegertracing/all-in-one
+    profiles: [observability]
+  seed:
+    image: alpine
+    profiles: [tools]
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(monoYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	selected := func(template string) []string {
+		t.Helper()
+		cfg, err := LoadConfig(dir)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if err := cfg.ApplyTemplate(template); err != nil {
+			t.Fatal(err)
+		}
+		composeConfig, err := ParseComposeConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.SelectCompose(composeConfig); err != nil {
+			t.Fatalf("SelectCompose() error = %v", err)
+		}
+		for name, svc := range composeConfig.Project().Services {
+			if len(svc.Profiles) > 0 {
+				t.Errorf("service %s keeps profiles %v, so compose would skip it without --profile", name, svc.Profiles)
+			}
+		}
+		names := composeConfig.GetServiceNames()
+		slices.Sort(names)
+		return names
+	}
+
+	if got := selected(""); !slices.Equal(got, []string{"db", "jaeger"}) {
+		t.Errorf("services = %v, want [db jaeger]", got)
+	}
+	if got := selected("full"); !slices.Equal(got, []string{"db", "jaeger", "seed"}) {
+		t.Errorf("services with template full = %v, want [db jaeger seed]", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LocalConfigFile), []byte("compose_profiles: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := selected("full"); !slices.Equal(got, []string{"db"}) {
+		t.Errorf("services with a local override = %v, want [db]", got)
+	}
+
+	composeConfig, err := ParseComposeConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := composeConfig.EnableProfiles([]string{"debug"}); err == nil || !strings.Contains(err.Error(), "observability, tools") {
+		t.Errorf("EnableProfiles() error = %v, want unknown profile listing the available ones", err)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	dir := t.TempDir()
+	monoYml := `scripts:
+  init: npm ci
+build:
+  artifacts:
+    - name: npm
+      key_comands: [npm --version]
+      paths: node_modules
+    - key_files: [Cargo.lock]
+hosts:
+  enabled: "yes"
+tmux:
+  windows:
+    - command: npm run dev
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(monoYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadConfig(dir)
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("LoadConfig error = %v, want *ConfigError", err)
+	}
+
+	want := []string{
+		`mono.yml:6: unknown field "key_comands" in build.artifacts[0] (did you mean "key_commands"?)`,
+		`mono.yml:7: build.artifacts[0].paths must be a list, got "node_modules"`,
+		`mono.yml:8: build.artifacts[1] is missing required field "name"`,
+		`mono.yml:8: build.artifacts[1] is missing required field "paths"`,
+		`mono.yml:10: hosts.enabled must be true or false, got "yes"`,
+		`mono.yml:13: tmux.windows[0] is missing required field "name"`,
+	}
+	if strings.Join(cfgErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems =\n%s\nwant\n%s", strings.Join(cfgErr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadConfigValidationAcceptsReadme(t *testing.T) {
+	dir := t.TempDir()
+	monoYml := `env:
+  API_PORT: "$((5678 + MONO_ENV_ID))"
+ports:
+  mode: ephemeral
+  reserved: [19443, 19500-19510]
+build:
+  sccache: true
+  artifacts:
+    - name: npm
+      key_files: [package-lock.json]
+      paths: [node_modules]
+scripts:
+dotenv:
+  enabled: true
+  vars:
+    DATABASE_URL: "${MONO_DB_URL}?sslmode=disable"
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(monoYml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Ports.Reserved) != 2 || cfg.Build.Sccache == nil || !*cfg.Build.Sccache {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadConfigExtendsAndInclude(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		filepath.Join(root, "base.mono.yml"): `env:
+  A: base
+  B: base
+scripts:
+  init: make deps
+  run: make run
+build:
+  artifacts:
+    - name: cargo
+      key_files: [Cargo.lock]
+      paths: [target]
+`,
+		filepath.Join(root, "docker.mono.yml"): `compose_dir: docker
+env:
+  C: docker
+`,
+		filepath.Join(dir, "mono.yml"): `extends: ../../base.mono.yml
+include: [../../docker.mono.yml]
+env:
+  B: api
+scripts:
+  run: cargo run --bin api
+`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Env["A"] != "base" || cfg.Env["B"] != "api" || cfg.Env["C"] != "docker" {
+		t.Errorf("env = %v, want merged values with the overlay winning", cfg.Env)
+	}
+	if cfg.Scripts.Init != "make deps" || cfg.Scripts.Run != "cargo run --bin api" {
+		t.Errorf("scripts = %+v", cfg.Scripts)
+	}
+	if cfg.ComposeDir != "docker" || len(cfg.Build.Artifacts) != 1 || cfg.Build.Artifacts[0].Name != "cargo" {
+		t.Errorf("unexpected merged config: %+v", cfg)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "base.mono.yml"), []byte("extends: services/api/mono.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+}
+
+func TestLoadConfigInterpolation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_TEST_PROFILE", "release")
+
+	dir := t.TempDir()
+	content := `env:
+  CACHE: ${HOME}/.cache/app
+  PROFILE: ${env.MONO_TEST_PROFILE}
+  REGION: ${env.MONO_TEST_UNSET:-eu-west-1}
+  API_URL: http://localhost:${port.web}
+scripts:
+  run: cargo run --profile ${env.MONO_TEST_PROFILE} -- --port ${port.web} --name ${MONO_ENV_NAME}
+build:
+  artifacts:
+    - name: cargo
+      key_commands:
+        - cat ${HOME}/toolchain
+      paths: [target]
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Env["CACHE"] != home+"/.cache/app" || cfg.Env["PROFILE"] != "release" || cfg.Env["REGION"] != "eu-west-1" {
+		t.Errorf("env = %v", cfg.Env)
+	}
+	if cfg.Build.Artifacts[0].KeyCommands[0] != "cat "+home+"/toolchain" {
+		t.Errorf("key command = %q", cfg.Build.Artifacts[0].KeyCommands[0])
+	}
+	if cfg.Env["API_URL"] != "http://localhost:${port.web}" {
+		t.Errorf("port references should wait for allocations, got %q", cfg.Env["API_URL"])
+	}
+
+	if err := cfg.ResolvePorts([]Allocation{{Service: "web", ContainerPort: 80, HostPort: 4100}}); err != nil {
+		t.Fatalf("ResolvePorts failed: %v", err)
+	}
+	if cfg.Env["API_URL"] != "http://localhost:4100" {
+		t.Errorf("API_URL = %q", cfg.Env["API_URL"])
+	}
+	if want := "cargo run --profile release -- --port 4100 --name ${MONO_ENV_NAME}"; cfg.Scripts.Run != want {
+		t.Errorf("run = %q, want %q", cfg.Scripts.Run, want)
+	}
+
+	cfg = &Config{Scripts: Scripts{Run: "serve --port ${port.api}"}}
+	err = cfg.ResolvePorts([]Allocation{{Service: "web", HostPort: 4100}})
+	if err == nil || !strings.Contains(err.Error(), "scripts.run") || !strings.Contains(err.Error(), "api") {
+		t.Errorf("expected unknown service error, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte("env:\n  TOKEN: ${env.MONO_TEST_UNSET}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "env.TOKEN") || !strings.Contains(err.Error(), "MONO_TEST_UNSET") {
+		t.Errorf("expected undefined variable error, got %v", err)
+	}
+}
+
+func TestConfigSchema(t *testing.T) {
+	data, err := ConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Properties map[string]struct {
+			Properties map[string]struct {
+				Enum  []string `json:"enum"`
+				Items struct {
+					Required []string `json:"required"`
+				} `json:"items"`
+			} `json:"properties"`
+			AdditionalProperties json.RawMessage `json:"additionalProperties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+
+	for _, key := range []string{"scripts", "build", "env", "profiles", "templates", "shared"} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("schema is missing %q", key)
+		}
+	}
+	if got := schema.Properties["ports"].Properties["mode"].Enum; len(got) != 2 {
+		t.Errorf("ports.mode enum = %v", got)
+	}
+	if got := schema.Properties["build"].Properties["artifacts"].Items.Required; len(got) != 2 {
+		t.Errorf("artifact required fields = %v, want name and paths", got)
+	}
+	if !strings.Contains(string(schema.Properties["env"].AdditionalProperties), `"string"`) {
+		t.Errorf("env values should be strings, got %s", schema.Properties["env"].AdditionalProperties)
+	}
+}
+
+func TestShowConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	t.Setenv(ProfileEnvVar, "")
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.mono.yml": "env:\n  A: base\nscripts:\n  run: make run\n",
+		"mono.yml": `extends: base.mono.yml
+scripts:
+  init: make deps
+build:
+  artifacts:
+    - name: npm
+      key_files: [package-lock.json]
+      paths: [node_modules]
+profiles:
+  ci:
+    sccache: false
+    artifacts: []
+`,
+		LocalConfigFile: "env:\n  B: local\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := ShowConfig(dir, ShowConfigOptions{Profile: "ci"})
+	if err != nil {
+		t.Fatalf("ShowConfig failed: %v", err)
+	}
+	for _, want := range []string{
+		"# profile: ci (--profile)",
+		"run: make run # base.mono.yml",
+		"init: make deps # mono.yml",
+		"A: base # base.mono.yml",
+		"B: local # " + LocalConfigFile,
+		"sccache: false # profile ci",
+		"artifacts: [] # mono.yml, filtered by profile ci",
+		"mode: slot # default",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "extends") || strings.Contains(out, "destroy") {
+		t.Errorf("resolved and unset fields should be omitted:\n%s", out)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	saved := configMigrations
+	defer func() { configMigrations = saved }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mono.yml")
+	if err := os.WriteFile(path, []byte("scripts:\n  run: make run\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MigrateConfig(dir)
+	if err != nil {
+		t.Fatalf("MigrateConfig failed: %v", err)
+	}
+	if len(result.Changes) != 1 || !strings.HasPrefix(string(result.Output), "version: 1\n") {
+		t.Fatalf("unversioned config should get a version field, got %v:\n%s", result.Changes, result.Output)
+	}
+	if err := WriteMigratedConfig(result); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := MigrateConfig(dir); err != nil || len(result.Changes) != 0 {
+		t.Errorf("current config should need no changes, got %v, %v", result, err)
+	}
+
+	configMigrations = []configMigration{{
+		from: 1,
+		apply: func(root *yaml.Node) []string {
+			if moveConfigKey(root, "scripts.run", "commands.run") {
+				return []string{"moved scripts.run to commands.run"}
+			}
+			return nil
+		},
+	}}
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte("version: 1\nscripts:\n  run: make run\n"), root); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := migrateConfigNode("mono.yml", root.Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var migrated struct {
+		Version  int               `yaml:"version"`
+		Scripts  map[string]string `yaml:"scripts"`
+		Commands map[string]string `yaml:"commands"`
+	}
+	if err := root.Decode(&migrated); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || migrated.Version != 2 || migrated.Commands["run"] != "make run" || len(migrated.Scripts) != 0 {
+		t.Errorf("migration result = %+v, changes %v", migrated, changes)
+	}
+
+	if err := os.WriteFile(path, []byte("version: 99\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "upgrade mono") {
+		t.Errorf("expected error for a newer config version, got %v", err)
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	tests := []struct {
+		when string
+		want bool
+	}{
+		{"darwin", true},
+		{"darwin/arm64", true},
+		{"darwin/amd64", false},
+		{"*/arm64", true},
+		{"linux", false},
+		{"linux, darwin/arm64", true},
+	}
+	for _, tt := range tests {
+		got, err := platformMatches(tt.when, "darwin", "arm64")
+		if err != nil || got != tt.want {
+			t.Errorf("platformMatches(%q) = %v, %v, want %v", tt.when, got, err, tt.want)
+		}
+	}
+	for _, when := range []string{"", "/arm64", "darwin/", "darwin/arm64/v8"} {
+		if _, err := platformMatches(when, "darwin", "arm64"); err == nil {
+			t.Errorf("expected error for %q", when)
+		}
+	}
+}
+
+func TestLoadConfigPlatforms(t *testing.T) {
+	dir := t.TempDir()
+	content := fmt.Sprintf(`env:
+  A: base
+  B: base
+build:
+  artifacts:
+    - name: cargo
+      key_commands: [rustc -V]
+      paths: [target]
+platforms:
+  - when: %s
+    services: [api, db]
+    env:
+      B: native
+    build:
+      artifacts:
+        - name: cargo
+          key_commands: [rustc -vV]
+          paths: [target]
+  - when: plan9
+    services: [web]
+    env:
+      A: plan9
+`, runtime.GOOS+"/"+runtime.GOARCH)
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Env["A"] != "base" || cfg.Env["B"] != "native" {
+		t.Errorf("env = %v, want only the matching block applied", cfg.Env)
+	}
+	if got := cfg.Build.Artifacts[0].KeyCommands; len(got) != 1 || got[0] != "rustc -vV" {
+		t.Errorf("key commands = %v", got)
+	}
+	if got := cfg.SelectedServices(); len(got) != 2 || got[0] != "api" {
+		t.Errorf("services = %v, want [api db]", got)
+	}
+
+	cfg.Templates = map[string]TemplateConfig{"backend": {Services: []string{"db", "worker"}}}
+	if err := cfg.ApplyTemplate("backend"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SelectedServices(); len(got) != 1 || got[0] != "db" {
+		t.Errorf("services = %v, want the platform and template overlap [db]", got)
+	}
+}
+
+func TestConfigReloaderCheck(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	envPath := filepath.Join(home, "workspaces", "proj", "feature")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(envPath, "mono.yml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`env:
+  A: one
+  B: two
+build:
+  artifacts:
+    - name: npm
+      key_files: [package-lock.json]
+      paths: [node_modules]
+`)
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertEnvironment(envPath, "", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	logger, err := NewFileLogger("reload-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	reloader := NewConfigReloader(logger)
+	check := func() []ConfigReloadResult {
+		results, err := reloadEnvironmentsUnder(reloader, filepath.Join(home, "workspaces"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	if results := check(); len(results) != 0 {
+		t.Fatalf("first check = %+v, want a baseline only", results)
+	}
+	if results := check(); len(results) != 0 {
+		t.Fatalf("unchanged config reported %+v", results)
+	}
+
+	write(`env:
+  A: changed
+  C: three
+build:
+  artifacts:
+    - name: cargo
+      key_files: [Cargo.lock]
+      paths: [target]
+scripts:
+  init: make
+`)
+	results := check()
+	if len(results) != 1 || results[0].Env != "proj-feature" {
+		t.Fatalf("results = %+v, want one for proj-feature", results)
+	}
+	want := []string{"scripts.init changed", "artifact npm removed", "env.B removed"}
+	if got := results[0].NeedsInit; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("needs init = %v, want %v", got, want)
+	}
+
+	if results := check(); len(results) != 0 {
+		t.Errorf("changes reported twice: %+v", results)
+	}
+}
+
+func TestConfigReloaderDiscover(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	logger, err := NewFileLogger("reload-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"mono.yml": "env:\n  A: one\n"})
+	env := &Environment{ID: 1, Path: dir}
+	reloader := NewConfigReloader(logger)
+	if added := reloader.Discover([]*Environment{env}); len(added) != 1 {
+		t.Fatalf("Discover() = %v, want the new environment", added)
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"mono.yml": "env:\n  A: two\n"})
+	if added := reloader.Discover([]*Environment{env}); len(added) != 0 {
+		t.Errorf("Discover() = %v, want nothing for a known environment", added)
+	}
+	if results := reloader.Check([]*Environment{env}); len(results) != 1 {
+		t.Errorf("Check() = %+v, want the edit made before the second Discover", results)
+	}
+}
+
+func TestConfigSourceFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	root := t.TempDir()
+	dir := filepath.Join(root, "services", "api")
+	writeBuildxFixture(t, root, map[string]string{
+		"services/api/mono.yml": "extends: ../../base.mono.yml\ninclude: [../../docker.mono.yml, ../../missing.mono.yml]\n",
+		"base.mono.yml":         "include: [shared/env.mono.yml]\n",
+		"shared/env.mono.yml":   "extends: ../docker.mono.yml\n",
+		"docker.mono.yml":       "compose_dir: .\n",
+	})
+
+	files, err := configSourceFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(home, ".mono", UserConfigFile),
+		filepath.Join(dir, LocalConfigFile),
+		filepath.Join(dir, "mono.yml"),
+		filepath.Join(root, "base.mono.yml"),
+		filepath.Join(root, "shared", "env.mono.yml"),
+		filepath.Join(root, "docker.mono.yml"),
+		filepath.Join(root, "missing.mono.yml"),
+	}
+	if strings.Join(files, "\n") != strings.Join(want, "\n") {
+		t.Errorf("configSourceFiles() =\n%s\nwant\n%s", strings.Join(files, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiffConfigStatePorts(t *testing.T) {
+	old := &configState{
+		Services: []string{"api", "db"},
+		Ports: map[string][]PortRequest{
+			"api": {{Port: 8080, Count: 1, Protocol: ProtocolTCP}},
+			"db":  {{Port: 5432, Count: 1, Protocol: ProtocolTCP}},
+		},
+	}
+	cur := &configState{
+		Services: []string{"api", "cache"},
+		Ports: map[string][]PortRequest{
+			"api":   {{Port: 8080, Count: 1, Protocol: ProtocolTCP}, {Port: 9229, Count: 1, Protocol: ProtocolTCP}},
+			"cache": {{Port: 6379, Count: 1, Protocol: ProtocolTCP}},
+		},
+	}
+
+	diff := diffConfigState(old, cur)
+	if got := diff.PortsAdded["api"]; len(got) != 1 || got[0].Port != 9229 {
+		t.Errorf("ports added for api = %v, want [9229]", got)
+	}
+	want := []string{"service cache added", "service db removed", "port 5432/tcp of db removed"}
+	if got := diff.needsInit(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("needs init = %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfigUserConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".mono"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	user := `env:
+  EDITOR: nvim
+  LOG_LEVEL: info
+tmux:
+  run:
+    on_conflict: respawn
+cache:
+  workers: 4
+  max_size: 20GB
+`
+	if err := os.WriteFile(filepath.Join(home, ".mono", UserConfigFile), []byte(user), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte("env:\n  LOG_LEVEL: debug\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Env["EDITOR"] != "nvim" || cfg.Env["LOG_LEVEL"] != "debug" {
+		t.Errorf("env = %v, want user defaults under project values", cfg.Env)
+	}
+	if cfg.Tmux.Run.OnConflict != "respawn" {
+		t.Errorf("on_conflict = %q, want respawn from the user config", cfg.Tmux.Run.OnConflict)
+	}
+
+	sources := make(ConfigSources)
+	if _, err := loadConfig(dir, sources); err != nil {
+		t.Fatal(err)
+	}
+	if sources["env.EDITOR"] != "~/.mono/config.yaml" || sources["env.LOG_LEVEL"] != "mono.yml" {
+		t.Errorf("sources = %v", sources)
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Workers != 4 || cm.MaxSize != 20<<30 {
+		t.Errorf("cache manager workers = %d, max size = %d", cm.Workers, cm.MaxSize)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".mono", UserConfigFile), []byte("extends: base.yml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(dir); err == nil || !strings.Contains(err.Error(), "only supported in mono.yml") {
+		t.Errorf("err = %v, want extends to be rejected", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(home, ".mono", UserConfigFile), []byte("cache:\n  max_size: lots\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCacheManager(); err == nil || !strings.Contains(err.Error(), "cache.max_size") {
+		t.Errorf("err = %v, want an invalid max_size error", err)
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_CACHE_MAX_SIZE", "50GB")
+	t.Setenv("MONO_TMUX_RUN_ON_CONFLICT", "attach")
+	t.Cleanup(func() { flagOverrides = nil })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte("env:\n  FOO: from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetConfigOverrides([]string{"env.FOO=bar", "tmux.run.on_conflict=respawn", "hosts.enabled=true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := make(ConfigSources)
+	cfg, err := loadConfig(dir, sources)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if cfg.Env["FOO"] != "bar" {
+		t.Errorf("env.FOO = %q, want bar", cfg.Env["FOO"])
+	}
+	if cfg.Tmux.Run.OnConflict != "respawn" {
+		t.Errorf("on_conflict = %q, want the flag to win over the env var", cfg.Tmux.Run.OnConflict)
+	}
+	if !cfg.Hosts.Enabled {
+		t.Error("hosts.enabled = false, want true")
+	}
+	if sources["env.FOO"] != "--set env.FOO" || sources["tmux.run.on_conflict"] != "--set tmux.run.on_conflict" {
+		t.Errorf("sources = %v", sources)
+	}
+
+	user, err := LoadUserConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Cache.MaxSize != "50GB" {
+		t.Errorf("cache.max_size = %q, want 50GB from MONO_CACHE_MAX_SIZE", user.Cache.MaxSize)
+	}
+
+	for _, set := range []string{"hosts.enabled=maybe", "tmux.nope=1", "extends=base.yml", "novalue"} {
+		err := SetConfigOverrides([]string{set})
+		if err == nil {
+			_, err = loadConfig(dir, nil)
+		}
+		if err == nil {
+			t.Errorf("--set %s: expected an error", set)
+		}
+	}
+
+	flagOverrides = nil
+	t.Setenv("MONO_CACHE_MAX_SIZE", "lots")
+	if _, err := LoadUserConfig(); err == nil || !strings.Contains(err.Error(), "cache.max_size") {
+		t.Errorf("err = %v, want an invalid max_size error", err)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	config := `build:
+  artifacts:
+    - name: npm
+      key_files: [package-lock.json]
+      paths: [node_modules]
+    - name: web
+      key_files: [api/package-lock.json]
+      paths: [node_modules/.cache, web/node_modules]
+services:
+  - name: api
+    command: npm start
+    ports: [8080]
+    depends_on: [db, queue]
+templates:
+  backend:
+    services: [api, db]
+    compose_profiles: [metrics]
+  broken:
+    services: [worker]
+`
+	compose := `services:
+  db:
+    image: postgres
+    depends_on: [cache]
+    ports:
+      - "5432"
+  cache:
+    image: redis
+  search:
+    image: elasticsearch
+    ports:
+      - "9200-9208"
+`
+	files := map[string]string{
+		"mono.yml":           config,
+		"docker-compose.yml": compose,
+		"package-lock.json":  "{}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	issues, err := LintConfig(dir)
+	if err != nil {
+		t.Fatalf("LintConfig failed: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"error: artifacts npm and web both cache node_modules/.cache",
+		"warning: artifact web: key file api/package-lock.json does not exist",
+		"warning: artifact web: no key file lives next to or above node_modules/.cache, so changes that affect it won't change the cache key",
+		"warning: artifact web: no key file lives next to or above web/node_modules, so changes that affect it won't change the cache key",
+		"error: service api depends on queue, which is not defined in mono.yml or the compose file",
+		"error: template broken selects service worker, which is not defined in mono.yml or the compose file",
+		"warning: service search is not selected by any template, so it only runs when no template is given",
+		"error: template backend enables compose profile metrics, which no compose service declares",
+		"error: services request 11 tcp ports in total, more than the 10 each environment's slot holds; use ports.mode: ephemeral or drop some",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,336 @@
+package mono
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RuntimeAuto   = "auto"
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+
+	podmanHelperImage = "docker.io/library/alpine:3"
+)
+
+type ContainerRuntime interface {
+	Name() string
+	CheckAvailable() error
+	StartContainers(projectName, workDir string, stdout, stderr io.Writer) error
+	StopContainers(projectName, workDir string, removeVolumes bool, stdout, stderr io.Writer) error
+	StopProject(projectName string) error
+	ContainersRunning(projectName string) bool
+	ContainerStatuses(projectName string) ([]ContainerStatus, error)
+	ProjectVolumes(projectName string) ([]string, error)
+	ProjectResources(projectName string) ([]ContainerResource, error)
+	RemoveResources(resources []ContainerResource) error
+	ExportVolume(volume, dir, file string) error
+	ImportVolume(projectName, volume, dir, file string) error
+	Compose(projectName, workDir string, stdout, stderr io.Writer, args ...string) error
+	ComposeCommand(projectName, workDir string, args ...string) (*exec.Cmd, error)
+	ImageExists(image string) (bool, error)
+	PullImage(image string) error
+	TagImage(source, target string) error
+	RemoveImage(image string) error
+	EnsureRegistryMirror(mirror RegistryMirrorConfig) error
+}
+
+func DetectContainerRuntime() string {
+	if _, err := exec.LookPath("docker"); err == nil {
+		return RuntimeDocker
+	}
+	if _, err := exec.LookPath("podman"); err == nil {
+		return RuntimePodman
+	}
+	return RuntimeDocker
+}
+
+func NewContainerRuntime(name string) (ContainerRuntime, error) {
+	if name == "" || name == RuntimeAuto {
+		name = DetectContainerRuntime()
+	}
+	switch name {
+	case RuntimeDocker:
+		return dockerRuntime{}, nil
+	case RuntimePodman:
+		return &podmanRuntime{}, nil
+	default:
+		return nil, fmt.Errorf("invalid container_runtime %q (expected %s, %s or %s)", name, RuntimeAuto, RuntimeDocker, RuntimePodman)
+	}
+}
+
+func recordedContainerRuntime(name string) (ContainerRuntime, error) {
+	if name == "" {
+		return dockerRuntime{}, nil
+	}
+	return NewContainerRuntime(name)
+}
+
+func (e *Environment) Runtime() (ContainerRuntime, error) {
+	return recordedContainerRuntime(e.ContainerRuntime.String)
+}
+
+type podmanRuntime struct {
+	once    sync.Once
+	compose []string
+	err     error
+}
+
+func (r *podmanRuntime) Name() string {
+	return RuntimePodman
+}
+
+func (r *podmanRuntime) composeCommand() ([]string, error) {
+	r.once.Do(func() {
+		if err := Command("podman", "compose", "version").Timeout(10 * time.Second).Run(); err == nil {
+			r.compose = []string{"podman", "compose"}
+			return
+		}
+		if _, err := exec.LookPath("podman-compose"); err == nil {
+			r.compose = []string{"podman-compose"}
+			return
+		}
+		r.err = fmt.Errorf("podman has no compose provider: install podman-compose or docker-compose")
+	})
+	return r.compose, r.err
+}
+
+func (r *podmanRuntime) CheckAvailable() error {
+	output, err := Command("podman", "info").Timeout(30 * time.Second).CombinedOutput()
+	if err != nil {
+		outputStr := strings.ToLower(string(output))
+		if strings.Contains(outputStr, "cannot connect") || strings.Contains(outputStr, "connection refused") {
+			return fmt.Errorf("podman machine %w", ErrRuntimeNotRunning)
+		}
+		return fmt.Errorf("podman unavailable: %s", strings.TrimSpace(string(output)))
+	}
+	_, err = r.composeCommand()
+	return err
+}
+
+func (r *podmanRuntime) StartContainers(projectName, workDir string, stdout, stderr io.Writer) error {
+	compose, err := r.composeCommand()
+	if err != nil {
+		return err
+	}
+	return composeUp(compose, projectName, workDir, stdout, stderr)
+}
+
+func (r *podmanRuntime) StopContainers(projectName, workDir string, removeVolumes bool, stdout, stderr io.Writer) error {
+	compose, err := r.composeCommand()
+	if err != nil {
+		return err
+	}
+	return composeDown(compose, projectName, workDir, removeVolumes, stdout, stderr)
+}
+
+func (r *podmanRuntime) StopProject(projectName string) error {
+	compose, err := r.composeCommand()
+	if err != nil {
+		return err
+	}
+	return composeStop(compose, projectName)
+}
+
+func (r *podmanRuntime) Compose(projectName, workDir string, stdout, stderr io.Writer, args ...string) error {
+	compose, err := r.composeCommand()
+	if err != nil {
+		return err
+	}
+	return composeRun(compose, projectName, workDir, stdout, stderr, args...)
+}
+
+func (r *podmanRuntime) ComposeCommand(projectName, workDir string, args ...string) (*exec.Cmd, error) {
+	compose, err := r.composeCommand()
+	if err != nil {
+		return nil, err
+	}
+	return composeCommand(compose, projectName, workDir, args...), nil
+}
+
+func (r *podmanRuntime) ContainersRunning(projectName string) bool {
+	output, err := Command("podman", "ps", "-q", "--filter", "label=com.docker.compose.project="+projectName).Output()
+	if err != nil {
+		return false
+	}
+	return len(strings.TrimSpace(string(output))) > 0
+}
+
+func (r *podmanRuntime) ContainerStatuses(projectName string) ([]ContainerStatus, error) {
+	output, err := Command("podman", "ps", "-a", "--filter", "label=com.docker.compose.project="+projectName, "--format", "json").
+		Timeout(30 * time.Second).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return parsePodmanPS(output)
+}
+
+func (r *podmanRuntime) ProjectVolumes(projectName string) ([]string, error) {
+	return projectVolumes("podman", projectName)
+}
+
+func (r *podmanRuntime) ProjectResources(projectName string) ([]ContainerResource, error) {
+	return projectResources("podman", projectName)
+}
+
+func (r *podmanRuntime) RemoveResources(resources []ContainerResource) error {
+	return removeResources("podman", resources)
+}
+
+func (r *podmanRuntime) ExportVolume(volume, dir, file string) error {
+	return exportVolume("podman", podmanHelperImage, volume, dir, file)
+}
+
+func (r *podmanRuntime) ImportVolume(projectName, volume, dir, file string) error {
+	return importVolume("podman", podmanHelperImage, projectName, volume, dir, file)
+}
+
+func (r *podmanRuntime) ImageExists(image string) (bool, error) {
+	return imageExists("podman", image)
+}
+
+func (r *podmanRuntime) PullImage(image string) error {
+	if strings.HasPrefix(image, "127.0.0.1:") || strings.HasPrefix(image, "localhost:") {
+		return pullImageWith("podman", image, "--tls-verify=false")
+	}
+	return pullImageWith("podman", image)
+}
+
+func (r *podmanRuntime) TagImage(source, target string) error {
+	return tagImage("podman", source, target)
+}
+
+func (r *podmanRuntime) RemoveImage(image string) error {
+	return removeImage("podman", image)
+}
+
+func (r *podmanRuntime) EnsureRegistryMirror(mirror RegistryMirrorConfig) error {
+	return ensureRegistryMirror("podman", mirror)
+}
+
+func parsePodmanPS(output []byte) ([]ContainerStatus, error) {
+	var entries []struct {
+		Names  []string
+		State  string
+		Status string
+		Labels map[string]string
+	}
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(trimmed), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse podman ps output: %w", err)
+	}
+
+	statuses := make([]ContainerStatus, 0, len(entries))
+	for _, e := range entries {
+		status := ContainerStatus{
+			Service: e.Labels["com.docker.compose.service"],
+			State:   e.State,
+		}
+		if len(e.Names) > 0 {
+			status.Name = e.Names[0]
+		}
+		for _, health := range []string{"unhealthy", "healthy", "starting"} {
+			if strings.Contains(e.Status, "("+health+")") {
+				status.Health = health
+				break
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+const (
+	ContainersUp      = "up"
+	ContainersDown    = "down"
+	ContainersRestart = "restart"
+)
+
+func ManageContainers(path, action string, services []string, stderr io.Writer) (string, error) {
+	lock, err := AcquireEnvLock(path, action, 0)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Release()
+
+	db, err := OpenDB()
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByPath(path)
+	if err != nil {
+		return "", fmt.Errorf("environment not found: %s", path)
+	}
+	envName := env.EnvName()
+	if !env.DockerProject.Valid || env.DockerProject.String == "" {
+		return "", fmt.Errorf("environment %s has no compose services", envName)
+	}
+	project := env.DockerProject.String
+
+	containers, err := env.Runtime()
+	if err != nil {
+		return "", err
+	}
+
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return "", fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	logger.Log("mono %s %s", action, strings.Join(append([]string{path}, services...), " "))
+
+	stdout := NewLogWriter(logger, "out")
+	errOut := io.MultiWriter(NewLogWriter(logger, "err"), stderr)
+	composeDir := env.ComposeDirPath()
+	run := func(args ...string) error {
+		return containers.Compose(project, composeDir, stdout, errOut, append(args, services...)...)
+	}
+
+	switch action {
+	case ContainersUp:
+		cfg, err := LoadConfig(env.Path)
+		if err != nil {
+			return "", fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := EnsureRuntimeAvailable(containers, cfg.ContainerAutostart, stderr); err != nil {
+			return "", err
+		}
+		err = withRetry(RetryContainers, cfg.Retry[RetryContainers], logger, func() error {
+			return run("up", "-d")
+		})
+	case ContainersDown:
+		if len(services) == 0 {
+			err = run("down")
+		} else if err = run("stop"); err == nil {
+			err = run("rm", "-f")
+		}
+	case ContainersRestart:
+		err = run("restart")
+	default:
+		return "", fmt.Errorf("unknown container action %q", action)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed for %s: %w", containers.Name(), action, envName, err)
+	}
+	if action == ContainersUp {
+		if err := trackContainerResources(db, env.ID, containers, project); err != nil {
+			logger.Log("warning: failed to record container resources: %v", err)
+		}
+	}
+	logger.Log("%s completed for %s", action, project)
+	return envName, nil
+}
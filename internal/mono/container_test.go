@@ -0,0 +1,225 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewContainerRuntime(t *testing.T) {
+	for name, want := range map[string]string{RuntimeDocker: RuntimeDocker, RuntimePodman: RuntimePodman} {
+		rt, err := NewContainerRuntime(name)
+		if err != nil {
+			t.Fatalf("NewContainerRuntime(%q) failed: %v", name, err)
+		}
+		if rt.Name() != want {
+			t.Errorf("NewContainerRuntime(%q) = %s, want %s", name, rt.Name(), want)
+		}
+	}
+
+	if _, err := NewContainerRuntime("containerd"); err == nil {
+		t.Error("expected an error for an unknown runtime")
+	}
+
+	rt, err := recordedContainerRuntime("")
+	if err != nil || rt.Name() != RuntimeDocker {
+		t.Errorf("environments recorded before runtime support should use docker, got %v (%v)", rt, err)
+	}
+}
+
+func TestParsePodmanPS(t *testing.T) {
+	output := `[
+  {"Names": ["mono-proj-feature_web_1"], "State": "running", "Status": "Up 2 minutes (healthy)", "Labels": {"com.docker.compose.service": "web"}},
+  {"Names": ["mono-proj-feature_db_1"], "State": "exited", "Status": "Exited (1) 5 seconds ago", "Labels": {"com.docker.compose.service": "db"}}
+]`
+	statuses, err := parsePodmanPS([]byte(output))
+	if err != nil {
+		t.Fatalf("parsePodmanPS failed: %v", err)
+	}
+	want := []ContainerStatus{
+		{Service: "db", Name: "mono-proj-feature_db_1", State: "exited"},
+		{Service: "web", Name: "mono-proj-feature_web_1", State: "running", Health: "healthy"},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("statuses[%d] = %+v, want %+v", i, statuses[i], want[i])
+		}
+	}
+
+	empty, err := parsePodmanPS([]byte("\n"))
+	if err != nil || empty != nil {
+		t.Errorf("empty output = %v, %v", empty, err)
+	}
+}
+
+func TestManageContainers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	envPath := filepath.Join(home, "workspaces", "proj", "feature")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(envPath, "mono-proj-feature", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetEnvironmentRuntime(envID, RuntimeDocker); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if _, err := ManageContainers(envPath, ContainersUp, nil, io.Discard); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if _, err := ManageContainers(envPath, ContainersDown, []string{"db"}, io.Discard); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	name, err := ManageContainers(envPath, ContainersRestart, []string{"web", "db"}, io.Discard)
+	if err != nil {
+		t.Fatalf("restart failed: %v", err)
+	}
+	if name != "proj-feature" {
+		t.Errorf("env name = %q", name)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"info",
+		"compose -p mono-proj-feature -f docker-compose.mono.yml up -d",
+		"ps -a --filter label=com.docker.compose.project=mono-proj-feature --format {{.Names}}",
+		"network ls --filter label=com.docker.compose.project=mono-proj-feature --format {{.Name}}",
+		"volume ls --filter label=com.docker.compose.project=mono-proj-feature --format {{.Name}}",
+		"compose -p mono-proj-feature -f docker-compose.mono.yml stop db",
+		"compose -p mono-proj-feature -f docker-compose.mono.yml rm -f db",
+		"compose -p mono-proj-feature -f docker-compose.mono.yml restart web db",
+	}, "\n") + "\n"
+	if string(data) != want {
+		t.Errorf("docker calls:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	script := "#!/bin/sh\necho \"$@\" >> " + calls + "\nfor last; do :; done\necho \"ready from $last\"\necho \"listening\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	envPath := filepath.Join(home, "workspaces", "proj", "feature")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	compose := "services:\n  db:\n    image: postgres\n  cache:\n    image: redis\n"
+	if err := os.WriteFile(filepath.Join(envPath, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertEnvironment(envPath, "mono-proj-feature", "", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	var out strings.Builder
+	if err := StreamLogs(envPath, LogsOptions{Services: []string{"db"}, Tail: "20", Since: "10m"}, &out); err != nil {
+		t.Fatalf("StreamLogs failed: %v", err)
+	}
+	if got := out.String(); got != "db | ready from db\ndb | listening\n" {
+		t.Errorf("output = %q", got)
+	}
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "compose -p mono-proj-feature -f docker-compose.mono.yml logs --no-color --no-log-prefix --tail 20 --since 10m db\n"; string(data) != want {
+		t.Errorf("docker calls = %q, want %q", data, want)
+	}
+
+	out.Reset()
+	if err := StreamLogs(envPath, LogsOptions{Follow: true, Color: true}, &out); err != nil {
+		t.Fatalf("StreamLogs failed: %v", err)
+	}
+	for _, want := range []string{"\033[36mcache | \033[0mready from cache\n", "\033[33mdb    | \033[0mready from db\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := StreamLogs(envPath, LogsOptions{Services: []string{"web"}}, io.Discard); err == nil || !strings.Contains(err.Error(), "services: cache, db") {
+		t.Errorf("err = %v, want the available services listed", err)
+	}
+	if err := StreamLogs(envPath, LogsOptions{Tail: "lots"}, io.Discard); err == nil {
+		t.Error("expected an error for an invalid --tail")
+	}
+}
+
+type fakeStoppedRuntime struct {
+	ContainerRuntime
+	ready string
+}
+
+func (r fakeStoppedRuntime) Name() string {
+	return RuntimeDocker
+}
+
+func (r fakeStoppedRuntime) CheckAvailable() error {
+	if _, err := os.Stat(r.ready); err != nil {
+		return fmt.Errorf("docker daemon %w", ErrRuntimeNotRunning)
+	}
+	return nil
+}
+
+func TestEnsureRuntimeAvailable(t *testing.T) {
+	bin := t.TempDir()
+	ready := filepath.Join(bin, "ready")
+	if err := os.WriteFile(filepath.Join(bin, "colima"), []byte("#!/bin/sh\n[ \"$1\" = start ] && : > "+ready+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	containers := fakeStoppedRuntime{ready: ready}
+
+	err := EnsureRuntimeAvailable(containers, false, io.Discard)
+	if err == nil || !errors.Is(err, ErrRuntimeNotRunning) || !strings.Contains(err.Error(), "start it with `colima start`") {
+		t.Fatalf("err = %v, want a hint to run colima start", err)
+	}
+
+	var progress strings.Builder
+	if err := EnsureRuntimeAvailable(containers, true, &progress); err != nil {
+		t.Fatalf("EnsureRuntimeAvailable failed: %v", err)
+	}
+	if !strings.Contains(progress.String(), "Starting Colima (colima start)...") || !strings.Contains(progress.String(), "Colima is ready") {
+		t.Errorf("progress = %q", progress.String())
+	}
+}
@@ -0,0 +1,419 @@
+package mono
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type DaemonOptions struct {
+	Socket               string
+	AutoPruneInterval    time.Duration
+	ConfigReloadInterval time.Duration
+}
+
+type daemonEnv struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	PortSlot *int64 `json:"port_slot,omitempty"`
+}
+
+type reservePortRequest struct {
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	Count         int    `json:"count"`
+	Protocol      string `json:"protocol"`
+}
+
+func DefaultDaemonSocket() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".mono", "mono.sock"), nil
+}
+
+func RunDaemon(ctx context.Context, opts DaemonOptions) error {
+	if opts.Socket == "" {
+		socket, err := DefaultDaemonSocket()
+		if err != nil {
+			return err
+		}
+		opts.Socket = socket
+	}
+
+	logger, err := NewFileLogger("daemon")
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	if err := os.MkdirAll(filepath.Dir(opts.Socket), 0755); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := removeStaleSocket(opts.Socket); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", opts.Socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", opts.Socket, err)
+	}
+	defer os.Remove(opts.Socket)
+
+	if err := os.Chmod(opts.Socket, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to find the mono executable: %w", err)
+	}
+	jobs, err := newJobManager(exe, logger)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	defer jobs.shutdown()
+
+	server := &http.Server{
+		Handler:     newDaemonHandler(logger, jobs),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Log("daemon listening on %s", opts.Socket)
+		errCh <- server.Serve(listener)
+	}()
+
+	if opts.AutoPruneInterval > 0 {
+		go runAutoPruneLoop(ctx, opts.AutoPruneInterval, logger)
+	}
+	if opts.ConfigReloadInterval > 0 {
+		go runConfigReloadLoop(ctx, opts.ConfigReloadInterval, logger)
+	}
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("daemon server failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("failed to shut down daemon: %w", err)
+		}
+		logger.Log("daemon stopped")
+		return nil
+	}
+}
+
+func runAutoPruneLoop(ctx context.Context, interval time.Duration, logger *FileLogger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result, err := AutoPrune(logger)
+		if err != nil {
+			logger.Log("warning: auto-prune failed: %v", err)
+			continue
+		}
+		for _, s := range result.Destroyed {
+			logger.Log("auto-pruned %s (%s)", s.Name, strings.Join(s.Reasons, ", "))
+		}
+		for _, failure := range result.Failed {
+			logger.Log("warning: auto-prune: %s", failure)
+		}
+	}
+}
+
+func runConfigReloadLoop(ctx context.Context, interval time.Duration, logger *FileLogger) {
+	reloader := NewConfigReloader(logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var events <-chan struct{}
+	var sub *FSSubscription
+	fsw, err := NewFSWatcher(logger)
+	if err != nil {
+		logger.Log("warning: file events unavailable, checking mono.yml every %s: %v", interval, err)
+	} else {
+		defer func() {
+			if err := fsw.Close(); err != nil {
+				logger.Log("warning: %v", err)
+			}
+		}()
+		sub = fsw.Subscribe()
+		defer sub.Close()
+		events = sub.Events()
+	}
+
+	changed := true
+	for {
+		envs, err := environmentsUnder("")
+		if err != nil {
+			logger.Log("warning: config reload failed: %v", err)
+		} else if sub == nil {
+			reloader.Check(envs)
+		} else {
+			watch := reloader.Discover(envs)
+			if changed {
+				reloader.Check(envs)
+				watch = envs
+			}
+			for _, env := range watch {
+				watchConfigSources(sub, env, logger)
+			}
+		}
+
+		changed = false
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-events:
+			if !sub.Settle(ctx, defaultWatchDebounce) {
+				return
+			}
+			changed = true
+		}
+	}
+}
+
+func watchConfigSources(sub *FSSubscription, env *Environment, logger *FileLogger) {
+	files, err := configSourceFiles(env.Path)
+	if err != nil {
+		logger.Log("warning: config reload: %s: %v", env.EnvName(), err)
+	}
+	for _, file := range files {
+		if err := sub.Watch(file, 0); err != nil {
+			logger.Log("warning: config reload: %v", err)
+		}
+	}
+}
+
+func removeStaleSocket(socket string) error {
+	if _, err := os.Stat(socket); os.IsNotExist(err) {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon already running on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
+
+func newDaemonHandler(logger *FileLogger, jobs *jobManager) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/envs", func(w http.ResponseWriter, r *http.Request) {
+		db, err := OpenDB()
+		if err != nil {
+			writeDaemonError(w, logger, fmt.Errorf("failed to open database: %w", err))
+			return
+		}
+		defer db.Close()
+
+		envs, err := db.ListEnvironments()
+		if err != nil {
+			writeDaemonError(w, logger, err)
+			return
+		}
+
+		result := make([]daemonEnv, 0, len(envs))
+		for _, env := range envs {
+			de := daemonEnv{Name: env.EnvName(), Path: env.Path}
+			if env.PortSlot.Valid {
+				slot := env.PortSlot.Int64
+				de.PortSlot = &slot
+			}
+			result = append(result, de)
+		}
+		writeDaemonJSON(w, logger, http.StatusOK, result)
+	})
+
+	mux.HandleFunc("GET /v1/envs/{env}/ports", func(w http.ResponseWriter, r *http.Request) {
+		statuses, err := envPortStatuses(r.PathValue("env"), "")
+		if err != nil {
+			writeDaemonError(w, logger, err)
+			return
+		}
+		writeDaemonJSON(w, logger, http.StatusOK, statuses)
+	})
+
+	mux.HandleFunc("GET /v1/envs/{env}/ports/{service}", func(w http.ResponseWriter, r *http.Request) {
+		statuses, err := envPortStatuses(r.PathValue("env"), r.PathValue("service"))
+		if err != nil {
+			writeDaemonError(w, logger, err)
+			return
+		}
+		if len(statuses) == 0 {
+			http.Error(w, fmt.Sprintf("no ports allocated for service %s", r.PathValue("service")), http.StatusNotFound)
+			return
+		}
+		writeDaemonJSON(w, logger, http.StatusOK, statuses)
+	})
+
+	mux.HandleFunc("POST /v1/envs/{env}/ports", func(w http.ResponseWriter, r *http.Request) {
+		var req reservePortRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if req.Service == "" || req.ContainerPort <= 0 {
+			http.Error(w, "service and container_port are required", http.StatusBadRequest)
+			return
+		}
+		if req.Protocol != "" && req.Protocol != ProtocolTCP && req.Protocol != ProtocolUDP {
+			http.Error(w, fmt.Sprintf("invalid protocol %q", req.Protocol), http.StatusBadRequest)
+			return
+		}
+
+		alloc, err := ReservePort(r.PathValue("env"), req.Service, PortRequest{
+			Port:     req.ContainerPort,
+			Count:    req.Count,
+			Protocol: req.Protocol,
+		})
+		if err != nil {
+			writeDaemonError(w, logger, err)
+			return
+		}
+		logger.Log("reserved %s for %s", alloc.String(), r.PathValue("env"))
+		writeDaemonJSON(w, logger, http.StatusCreated, newPortStatus(*alloc))
+	})
+
+	mux.HandleFunc("DELETE /v1/envs/{env}/ports/{service}", func(w http.ResponseWriter, r *http.Request) {
+		released, err := ReleasePort(r.PathValue("env"), r.PathValue("service"))
+		if err != nil {
+			writeDaemonError(w, logger, err)
+			return
+		}
+		if released == 0 {
+			http.Error(w, fmt.Sprintf("no ports allocated for service %s", r.PathValue("service")), http.StatusNotFound)
+			return
+		}
+		logger.Log("released %d port(s) of %s for %s", released, r.PathValue("service"), r.PathValue("env"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeDaemonJSON(w, logger, http.StatusOK, jobs.list())
+	})
+
+	mux.HandleFunc("POST /v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req JobRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job, err := jobs.start(req)
+		if err != nil {
+			writeJobError(w, logger, err)
+			return
+		}
+		writeDaemonJSON(w, logger, http.StatusCreated, job)
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		rj, err := jobs.find(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, logger, err)
+			return
+		}
+		writeDaemonJSON(w, logger, http.StatusOK, jobs.snapshot(rj))
+	})
+
+	mux.HandleFunc("GET /v1/jobs/{id}/log", func(w http.ResponseWriter, r *http.Request) {
+		rj, f, err := jobs.openLog(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, logger, err)
+			return
+		}
+		defer f.Close()
+		if err := followLog(w, r, rj, f, r.URL.Query().Get("follow") == "true"); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	})
+
+	mux.HandleFunc("POST /v1/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		job, err := jobs.cancel(r.PathValue("id"))
+		if err != nil {
+			writeJobError(w, logger, err)
+			return
+		}
+		writeDaemonJSON(w, logger, http.StatusOK, job)
+	})
+
+	return mux
+}
+
+func envPortStatuses(envName, service string) ([]PortStatus, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByName(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]PortStatus, 0, len(allocations))
+	for _, a := range allocations {
+		if service != "" && a.Service != service {
+			continue
+		}
+		statuses = append(statuses, newPortStatus(a))
+	}
+	return statuses, nil
+}
+
+func writeDaemonJSON(w http.ResponseWriter, logger *FileLogger, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Log("failed to write response: %v", err)
+	}
+}
+
+func writeDaemonError(w http.ResponseWriter, logger *FileLogger, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrEnvironmentNotFound) {
+		status = http.StatusNotFound
+	} else {
+		logger.Log("request failed: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,130 @@
+package mono
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type DaemonClient struct {
+	socket string
+	http   *http.Client
+}
+
+func NewDaemonClient(socket string) (*DaemonClient, error) {
+	if socket == "" {
+		s, err := DefaultDaemonSocket()
+		if err != nil {
+			return nil, err
+		}
+		socket = s
+	}
+	if _, err := os.Stat(socket); os.IsNotExist(err) {
+		return nil, fmt.Errorf("mono daemon is not running (no socket at %s, start it with mono daemon)", socket)
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &DaemonClient{socket: socket, http: &http.Client{Transport: transport}}, nil
+}
+
+func (c *DaemonClient) do(method, path string, body any, want int) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, "http://mono"+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach mono daemon on %s: %w", c.socket, err)
+	}
+	if resp.StatusCode != want {
+		defer resp.Body.Close()
+		msg, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if err != nil {
+			return nil, fmt.Errorf("mono daemon returned %s", resp.Status)
+		}
+		return nil, fmt.Errorf("mono daemon: %s", strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (c *DaemonClient) doJSON(method, path string, body any, want int, v any) error {
+	resp, err := c.do(method, path, body, want)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode daemon response: %w", err)
+	}
+	return nil
+}
+
+func (c *DaemonClient) StartJob(req JobRequest) (*Job, error) {
+	var job Job
+	if err := c.doJSON(http.MethodPost, "/v1/jobs", req, http.StatusCreated, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *DaemonClient) Jobs() ([]Job, error) {
+	var jobs []Job
+	if err := c.doJSON(http.MethodGet, "/v1/jobs", nil, http.StatusOK, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (c *DaemonClient) Job(id string) (*Job, error) {
+	var job Job
+	if err := c.doJSON(http.MethodGet, "/v1/jobs/"+url.PathEscape(id), nil, http.StatusOK, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *DaemonClient) CancelJob(id string) (*Job, error) {
+	var job Job
+	if err := c.doJSON(http.MethodPost, "/v1/jobs/"+url.PathEscape(id)+"/cancel", nil, http.StatusOK, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (c *DaemonClient) StreamJobLog(id string, follow bool, w io.Writer) error {
+	path := "/v1/jobs/" + url.PathEscape(id) + "/log"
+	if follow {
+		path += "?follow=true"
+	}
+	resp, err := c.do(http.MethodGet, path, nil, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read log of job %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package mono
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDaemonPortAPI(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	envPath := filepath.Join(home, "workspaces", "proj", "feature")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(envPath, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPortSlot(envID, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAllocations(envID, []Allocation{
+		{Service: "web", ContainerPort: 3000, HostPort: SlotBasePort(100), Protocol: ProtocolTCP, Count: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	logger, err := NewFileLogger("daemon-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	jobs, err := newJobManager("sh", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newDaemonHandler(logger, jobs))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/envs/proj-feature/ports/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []PortStatus
+	if err := json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(statuses) != 1 || statuses[0].HostPort != SlotBasePort(100) {
+		t.Fatalf("unexpected web ports: %+v", statuses)
+	}
+
+	resp, err = http.Post(server.URL+"/v1/envs/proj-feature/ports", "application/json",
+		strings.NewReader(`{"service":"playwright","container_port":9323}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reserved PortStatus
+	if err := json.NewDecoder(resp.Body).Decode(&reserved); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if reserved.HostPort == SlotBasePort(100) {
+		t.Errorf("reserved port %d overlaps existing allocation", reserved.HostPort)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+"/v1/envs/proj-feature/ports/playwright", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/v1/envs/missing/ports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown env, got %d", resp.StatusCode)
+	}
+}
@@ -32,6 +32,17 @@ CREATE TABLE IF NOT EXISTS cache_events (
 CREATE INDEX IF NOT EXISTS idx_cache_events_key ON cache_events(project_id, artifact, cache_key);
 `
 
+const portAllocationsSchema = `
+CREATE TABLE IF NOT EXISTS port_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    env_id INTEGER NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    service TEXT NOT NULL,
+    container_port INTEGER NOT NULL,
+    host_port INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_port_allocations_env ON port_allocations(env_id);
+`
+
 type DB struct {
 	conn *sql.DB
 	path string
@@ -98,14 +109,99 @@ func (db *DB) Initialize() error {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
-	db.conn.Exec(`ALTER TABLE environments ADD COLUMN root_path TEXT`)
-	db.conn.Exec(`ALTER TABLE environments ADD COLUMN compose_dir TEXT`)
+	columns := []struct {
+		name       string
+		definition string
+	}{
+		{"root_path", "TEXT"},
+		{"compose_dir", "TEXT"},
+		{"port_slot", "INTEGER"},
+		{"name", "TEXT"},
+		{"branch", "TEXT"},
+		{"last_used", "TIMESTAMP"},
+		{"stale_since", "TIMESTAMP"},
+		{"template", "TEXT"},
+		{"profile", "TEXT"},
+		{"container_runtime", "TEXT"},
+		{"base_commit", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := db.addColumnIfMissing("environments", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	if err := db.backfillEnvironmentNames(); err != nil {
+		return err
+	}
+	if err := db.renameDuplicateEnvironmentNames(); err != nil {
+		return err
+	}
+	if _, err := db.conn.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_environments_name ON environments(name)`); err != nil {
+		return fmt.Errorf("failed to create environment name index: %w", err)
+	}
 
 	_, err = db.conn.Exec(cacheEventsSchema)
 	if err != nil {
 		return fmt.Errorf("failed to create cache_events schema: %w", err)
 	}
 
+	_, err = db.conn.Exec(portAllocationsSchema)
+	if err != nil {
+		return fmt.Errorf("failed to create port_allocations schema: %w", err)
+	}
+	if err := db.addColumnIfMissing("port_allocations", "protocol", "TEXT NOT NULL DEFAULT 'tcp'"); err != nil {
+		return err
+	}
+	if err := db.addColumnIfMissing("port_allocations", "count", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+
+	_, err = db.conn.Exec(labelsSchema)
+	if err != nil {
+		return fmt.Errorf("failed to create environment_labels schema: %w", err)
+	}
+
+	_, err = db.conn.Exec(containerResourcesSchema)
+	if err != nil {
+		return fmt.Errorf("failed to create container_resources schema: %w", err)
+	}
+
+	_, err = db.conn.Exec(timingsSchema)
+	if err != nil {
+		return fmt.Errorf("failed to create timings schema: %w", err)
+	}
+
+	return nil
+}
+
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.conn.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("failed to close %s columns: %w", table, err)
+	}
+
+	if _, err := db.conn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
@@ -0,0 +1,491 @@
+package mono
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SnapshotDump     = "dump"
+	SnapshotTemplate = "template"
+
+	DefaultSnapshotName    = "default"
+	snapshotTemplatePrefix = "mono_snapshot_"
+)
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	validSnapshotName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,39}$`)
+)
+
+type DBSnapshot struct {
+	Name     string    `json:"name"`
+	Env      string    `json:"env"`
+	Service  string    `json:"service"`
+	Database string    `json:"database"`
+	Method   string    `json:"method"`
+	Size     int64     `json:"size,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (s *DBSnapshot) templateDatabase() string {
+	return snapshotTemplatePrefix + s.Name
+}
+
+type DBSnapshotOptions struct {
+	Name     string
+	Service  string
+	Template bool
+}
+
+type DBRestoreOptions struct {
+	Name    string
+	Service string
+	From    string
+}
+
+func ValidateSnapshotName(name string) error {
+	if !validSnapshotName.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q: use up to 40 letters, digits, '_' and '-'", name)
+	}
+	return nil
+}
+
+func SnapshotsDir(envName string) (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(monoHome, "snapshots", envName), nil
+}
+
+func snapshotPaths(envName, name string) (string, string, error) {
+	dir, err := SnapshotsDir(envName)
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(dir, name+".json"), filepath.Join(dir, name+".dump"), nil
+}
+
+func readSnapshot(envName, name string) (*DBSnapshot, error) {
+	metaPath, _, err := snapshotPaths(envName, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s has no snapshot %s", ErrSnapshotNotFound, envName, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	var snap DBSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+func writeSnapshot(snap *DBSnapshot) error {
+	metaPath, _, err := snapshotPaths(snap.Env, snap.Name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot %s: %w", snap.Name, err)
+	}
+	if err := os.WriteFile(metaPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %w", snap.Name, err)
+	}
+	return nil
+}
+
+type postgresTarget struct {
+	containers ContainerRuntime
+	project    string
+	dir        string
+	service    string
+	user       string
+	database   string
+}
+
+func resolvePostgres(ec *EnvContext, service string) (*postgresTarget, error) {
+	envName := ec.Env.EnvName()
+	if ec.Project == nil {
+		return nil, fmt.Errorf("environment %s has no compose services", envName)
+	}
+
+	var candidates []string
+	for _, name := range ec.Project.ServiceNames() {
+		if kind := matchDatabaseKind(ec.Project.Services[name].Image); kind != nil && kind.name == "postgres" {
+			candidates = append(candidates, name)
+		}
+	}
+	switch {
+	case service != "":
+		found := false
+		for _, name := range candidates {
+			found = found || name == service
+		}
+		if !found {
+			return nil, fmt.Errorf("service %s of %s is not a postgres service (postgres services: %s)", service, envName, strings.Join(candidates, ", "))
+		}
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("environment %s has no postgres service", envName)
+	case len(candidates) > 1:
+		return nil, fmt.Errorf("environment %s has several postgres services (%s), pick one with --service", envName, strings.Join(candidates, ", "))
+	default:
+		service = candidates[0]
+	}
+
+	containers, err := ec.Env.Runtime()
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string)
+	for k, v := range ec.Project.Services[service].Environment {
+		if v != nil {
+			env[k] = *v
+		}
+	}
+	user := envOr(env, "POSTGRES_USER", "postgres")
+	return &postgresTarget{
+		containers: containers,
+		project:    ec.Env.DockerProject.String,
+		dir:        ec.Env.ComposeDirPath(),
+		service:    service,
+		user:       user,
+		database:   envOr(env, "POSTGRES_DB", user),
+	}, nil
+}
+
+func (t *postgresTarget) exec(stdin io.Reader, stdout io.Writer, args ...string) error {
+	cmd, err := t.containers.ComposeCommand(t.project, t.dir, append([]string{"exec", "-T", t.service}, args...)...)
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s in %s failed: %w: %s", args[0], t.service, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (t *postgresTarget) maintenanceDB() string {
+	if t.database == "postgres" {
+		return "template1"
+	}
+	return "postgres"
+}
+
+func (t *postgresTarget) sql(statements ...string) error {
+	args := []string{"psql", "-U", t.user, "-d", t.maintenanceDB(), "-v", "ON_ERROR_STOP=1", "-q"}
+	for _, s := range statements {
+		args = append(args, "-c", s)
+	}
+	return t.exec(nil, io.Discard, args...)
+}
+
+func (t *postgresTarget) recreate(database, template string) error {
+	return t.sql(
+		fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname IN (%s, %s) AND pid <> pg_backend_pid()", quoteSQLLiteral(database), quoteSQLLiteral(template)),
+		"DROP DATABASE IF EXISTS "+quoteSQLIdent(database),
+		"CREATE DATABASE "+quoteSQLIdent(database)+" TEMPLATE "+quoteSQLIdent(template),
+	)
+}
+
+func quoteSQLIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteSQLLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func SnapshotDatabase(path string, opts DBSnapshotOptions) (*DBSnapshot, error) {
+	if err := ValidateSnapshotName(opts.Name); err != nil {
+		return nil, err
+	}
+	lock, err := AcquireEnvLock(path, "db snapshot", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	target, err := resolvePostgres(ec, opts.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	envName := ec.Env.EnvName()
+	dir, err := SnapshotsDir(envName)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+	previous, err := readSnapshot(envName, opts.Name)
+	if err != nil && !errors.Is(err, ErrSnapshotNotFound) {
+		return nil, err
+	}
+
+	snap := &DBSnapshot{
+		Name:     opts.Name,
+		Env:      envName,
+		Service:  target.service,
+		Database: target.database,
+		Method:   SnapshotDump,
+		Created:  time.Now().UTC(),
+	}
+	_, dumpPath, err := snapshotPaths(envName, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Template {
+		snap.Method = SnapshotTemplate
+		if err := target.recreate(snap.templateDatabase(), target.database); err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", target.database, err)
+		}
+	} else {
+		size, err := dumpPostgres(target, dumpPath)
+		if err != nil {
+			return nil, err
+		}
+		snap.Size = size
+	}
+
+	if err := writeSnapshot(snap); err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Method != snap.Method {
+		if err := removeSnapshotData(target, previous, dumpPath); err != nil {
+			return nil, err
+		}
+	}
+	return snap, nil
+}
+
+func dumpPostgres(target *postgresTarget, dumpPath string) (int64, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dumpPath), ".dump-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	dumpErr := target.exec(nil, tmp, "pg_dump", "-U", target.user, "-Fc", "--no-owner", "--no-privileges", target.database)
+	if err := tmp.Close(); err != nil && dumpErr == nil {
+		dumpErr = fmt.Errorf("failed to write snapshot file: %w", err)
+	}
+	if dumpErr != nil {
+		if err := os.Remove(tmp.Name()); err != nil {
+			return 0, errors.Join(dumpErr, err)
+		}
+		return 0, dumpErr
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat snapshot file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dumpPath); err != nil {
+		return 0, fmt.Errorf("failed to save snapshot file: %w", err)
+	}
+	return info.Size(), nil
+}
+
+func RestoreDatabase(path string, opts DBRestoreOptions) (snap *DBSnapshot, err error) {
+	if err := ValidateSnapshotName(opts.Name); err != nil {
+		return nil, err
+	}
+	lock, err := AcquireEnvLock(path, "db restore", 0)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	envName := ec.Env.EnvName()
+
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono db restore %s %s", path, opts.Name)
+	timer := NewPhaseTimer(TimingDBRestore, envName, path)
+	defer func() {
+		timer.Finish(err)
+		timer.PrintSummary(ec.Config.Timings)
+		ec.Config.Notify.Send(Notification{Operation: "db restore", Env: envName, Duration: time.Since(timer.start), Err: err}, logger)
+	}()
+
+	source := envName
+	if opts.From != "" {
+		db, err := OpenDB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		from, err := db.FindEnvironment(opts.From)
+		db.Close()
+		if err != nil {
+			return nil, err
+		}
+		source = from.EnvName()
+	}
+
+	snap, err = readSnapshot(source, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	service := opts.Service
+	if service == "" && source == envName {
+		service = snap.Service
+	}
+	target, err := resolvePostgres(ec, service)
+	if err != nil {
+		return nil, err
+	}
+
+	if snap.Method == SnapshotTemplate {
+		if source != envName {
+			return nil, fmt.Errorf("snapshot %s of %s is a template database inside its own server; take a dump snapshot (without --template) to copy it to %s", snap.Name, source, envName)
+		}
+		doneRecreate := timer.Start("recreate from template")
+		err := target.recreate(target.database, snap.templateDatabase())
+		doneRecreate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", target.database, err)
+		}
+		return snap, nil
+	}
+
+	_, dumpPath, err := snapshotPaths(source, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	doneReset := timer.Start("reset database")
+	err = target.recreate(target.database, "template0")
+	doneReset()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reset %s: %w", target.database, err)
+	}
+	doneLoad := timer.Start("load dump")
+	err = target.exec(f, io.Discard, "pg_restore", "-U", target.user, "-d", target.database, "--no-owner", "--no-privileges", "--exit-on-error")
+	doneLoad()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore %s: %w", target.database, err)
+	}
+	return snap, nil
+}
+
+func ListDBSnapshots(path string) ([]DBSnapshot, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	env, err := db.FindEnvironment(path)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := SnapshotsDir(env.EnvName())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	var snapshots []DBSnapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		snap, err := readSnapshot(env.EnvName(), name)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snap)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created.After(snapshots[j].Created) })
+	return snapshots, nil
+}
+
+func RemoveDBSnapshot(path, name string) error {
+	if err := ValidateSnapshotName(name); err != nil {
+		return err
+	}
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return err
+	}
+	envName := ec.Env.EnvName()
+	snap, err := readSnapshot(envName, name)
+	if err != nil {
+		return err
+	}
+	metaPath, dumpPath, err := snapshotPaths(envName, name)
+	if err != nil {
+		return err
+	}
+
+	var target *postgresTarget
+	if snap.Method == SnapshotTemplate {
+		target, err = resolvePostgres(ec, snap.Service)
+		if err != nil {
+			return err
+		}
+	}
+	if err := removeSnapshotData(target, snap, dumpPath); err != nil {
+		return err
+	}
+	if err := os.Remove(metaPath); err != nil {
+		return fmt.Errorf("failed to remove snapshot %s: %w", name, err)
+	}
+	return nil
+}
+
+func removeSnapshotData(target *postgresTarget, snap *DBSnapshot, dumpPath string) error {
+	if snap.Method == SnapshotTemplate {
+		if err := target.sql("DROP DATABASE IF EXISTS " + quoteSQLIdent(snap.templateDatabase())); err != nil {
+			return fmt.Errorf("failed to drop snapshot database: %w", err)
+		}
+		return nil
+	}
+	if err := os.Remove(dumpPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove snapshot file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDatabaseSnapshots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	restored := filepath.Join(bin, "restored")
+	script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$*" in
+*pg_dump*) printf 'PGDMP-data' ;;
+*pg_restore*) cat > ` + restored + ` ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	compose := "services:\n  db:\n    image: postgres:16\n    environment:\n      POSTGRES_USER: app\n      POSTGRES_DB: app_dev\n  cache:\n    image: redis\n"
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, name := range []string{"feature", "other"} {
+		envPath := filepath.Join(home, "workspaces", "proj", name)
+		if err := os.MkdirAll(envPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(envPath, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.InsertEnvironment(envPath, "mono-proj-"+name, "", "", ""); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, envPath)
+	}
+	db.Close()
+	feature, other := paths[0], paths[1]
+
+	snap, err := SnapshotDatabase(feature, DBSnapshotOptions{Name: DefaultSnapshotName})
+	if err != nil {
+		t.Fatalf("SnapshotDatabase failed: %v", err)
+	}
+	if snap.Service != "db" || snap.Database != "app_dev" || snap.Method != SnapshotDump || snap.Size != int64(len("PGDMP-data")) {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	if _, err := RestoreDatabase(other, DBRestoreOptions{Name: DefaultSnapshotName, From: feature}); err != nil {
+		t.Fatalf("RestoreDatabase failed: %v", err)
+	}
+	data, err := os.ReadFile(restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "PGDMP-data" {
+		t.Errorf("pg_restore got %q", data)
+	}
+
+	if _, err := SnapshotDatabase(feature, DBSnapshotOptions{Name: "seeded", Template: true}); err != nil {
+		t.Fatalf("template snapshot failed: %v", err)
+	}
+	if _, err := RestoreDatabase(feature, DBRestoreOptions{Name: "seeded"}); err != nil {
+		t.Fatalf("template restore failed: %v", err)
+	}
+	if _, err := RestoreDatabase(other, DBRestoreOptions{Name: "seeded", From: feature}); err == nil || !strings.Contains(err.Error(), "template database") {
+		t.Errorf("err = %v, want template snapshots to stay in their environment", err)
+	}
+
+	snapshots, err := ListDBSnapshots(feature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != "seeded" || snapshots[1].Name != DefaultSnapshotName {
+		t.Errorf("snapshots = %+v", snapshots)
+	}
+	if err := RemoveDBSnapshot(feature, "seeded"); err != nil {
+		t.Fatalf("RemoveDBSnapshot failed: %v", err)
+	}
+
+	data, err = os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "compose -p mono-proj-feature -f docker-compose.mono.yml exec -T db "
+	otherPrefix := "compose -p mono-proj-other -f docker-compose.mono.yml exec -T db "
+	terminate := "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname IN "
+	want := []string{
+		prefix + "pg_dump -U app -Fc --no-owner --no-privileges app_dev",
+		otherPrefix + "psql -U app -d postgres -v ON_ERROR_STOP=1 -q -c " + terminate + "('app_dev', 'template0') AND pid <> pg_backend_pid() -c DROP DATABASE IF EXISTS \"app_dev\" -c CREATE DATABASE \"app_dev\" TEMPLATE \"template0\"",
+		otherPrefix + "pg_restore -U app -d app_dev --no-owner --no-privileges --exit-on-error",
+		prefix + "psql -U app -d postgres -v ON_ERROR_STOP=1 -q -c " + terminate + "('mono_snapshot_seeded', 'app_dev') AND pid <> pg_backend_pid() -c DROP DATABASE IF EXISTS \"mono_snapshot_seeded\" -c CREATE DATABASE \"mono_snapshot_seeded\" TEMPLATE \"app_dev\"",
+		prefix + "psql -U app -d postgres -v ON_ERROR_STOP=1 -q -c " + terminate + "('app_dev', 'mono_snapshot_seeded') AND pid <> pg_backend_pid() -c DROP DATABASE IF EXISTS \"app_dev\" -c CREATE DATABASE \"app_dev\" TEMPLATE \"mono_snapshot_seeded\"",
+		prefix + "psql -U app -d postgres -v ON_ERROR_STOP=1 -q -c DROP DATABASE IF EXISTS \"mono_snapshot_seeded\"",
+	}
+	if got := strings.TrimSpace(string(data)); got != strings.Join(want, "\n") {
+		t.Errorf("docker calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	if _, err := SnapshotDatabase(feature, DBSnapshotOptions{Name: "../escape"}); err == nil {
+		t.Error("expected an error for an invalid snapshot name")
+	}
+	if _, err := SnapshotDatabase(feature, DBSnapshotOptions{Name: "x", Service: "cache"}); err == nil || !strings.Contains(err.Error(), "not a postgres service") {
+		t.Errorf("err = %v, want cache to be rejected", err)
+	}
+}
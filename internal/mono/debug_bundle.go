@@ -0,0 +1,235 @@
+package mono
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	bundleManifestFile = "bundle.json"
+	bundleLogTail      = 2 << 20
+	bundleToolTimeout  = 10 * time.Second
+)
+
+var bundleTools = [][]string{
+	{"tmux", "-V"},
+	{"git", "version"},
+	{"docker", "--version"},
+	{"podman", "--version"},
+	{"sccache", "--version"},
+	{"cargo", "--version"},
+	{"node", "--version"},
+}
+
+type BundleManifest struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Path      string            `json:"path"`
+	Files     []string          `json:"files"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+type BundleVersions struct {
+	Mono   string            `json:"mono"`
+	Commit string            `json:"commit,omitempty"`
+	Go     string            `json:"go"`
+	OS     string            `json:"os"`
+	Arch   string            `json:"arch"`
+	Tools  map[string]string `json:"tools"`
+}
+
+type bundleCache struct {
+	Entries []CacheSizeEntry `json:"entries"`
+	Stats   []CacheEntry     `json:"stats"`
+}
+
+type bundleRegistry struct {
+	Environments []EnvironmentStatus     `json:"environments"`
+	Ports        map[string][]Allocation `json:"ports"`
+}
+
+type bundleWriter struct {
+	dir      string
+	manifest BundleManifest
+}
+
+func (b *bundleWriter) add(name string, collect func() ([]byte, error)) error {
+	data, err := collect()
+	if err != nil {
+		b.manifest.Errors[name] = err.Error()
+		return nil
+	}
+	dst := filepath.Join(b.dir, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	b.manifest.Files = append(b.manifest.Files, name)
+	return nil
+}
+
+func bundleJSON(v any, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+func DebugBundle(path, out string) (*BundleManifest, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mono home: %w", err)
+	}
+	tmp, err := os.MkdirTemp("", "mono-debug-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	b := &bundleWriter{dir: tmp, manifest: BundleManifest{CreatedAt: time.Now().UTC(), Path: path, Errors: make(map[string]string)}}
+	sections := []struct {
+		name    string
+		collect func() ([]byte, error)
+	}{
+		{"versions.json", func() ([]byte, error) { return bundleJSON(bundleVersions(), nil) }},
+		{"doctor.json", func() ([]byte, error) { return bundleJSON(Doctor(path)) }},
+		{"config.yml", func() ([]byte, error) {
+			cfg, err := ShowConfig(path, ShowConfigOptions{Redact: true})
+			return []byte(cfg), err
+		}},
+		{"registry.json", func() ([]byte, error) { return bundleJSON(bundleRegistryContents()) }},
+		{"cache.json", func() ([]byte, error) { return bundleJSON(bundleCacheIndex()) }},
+		{"logs/mono.log", func() ([]byte, error) { return tailFile(filepath.Join(monoHome, "mono.log"), bundleLogTail) }},
+		{"logs/" + eventsFile, func() ([]byte, error) { return tailFile(filepath.Join(monoHome, eventsFile), bundleLogTail) }},
+	}
+	for _, s := range sections {
+		if err := b.add(s.name, s.collect); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.MarshalIndent(b.manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, bundleManifestFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	if err := writeTarGz(tmp, out); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", out, err)
+	}
+	return &b.manifest, nil
+}
+
+func bundleVersions() BundleVersions {
+	v := BundleVersions{Mono: "unknown", Go: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH, Tools: make(map[string]string)}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v.Mono = info.Main.Version
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				v.Commit = s.Value
+			}
+		}
+	}
+	for _, tool := range bundleTools {
+		v.Tools[tool[0]] = toolVersion(tool[0], tool[1:]...)
+	}
+	return v
+}
+
+func toolVersion(name string, args ...string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return "not installed"
+	}
+	output, err := Command(name, args...).Timeout(bundleToolTimeout).CombinedOutput()
+	if err != nil {
+		return fmt.Sprintf("error: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func bundleRegistryContents() (*bundleRegistry, error) {
+	environments, err := List()
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+	r := &bundleRegistry{Environments: environments, Ports: make(map[string][]Allocation)}
+	for _, env := range envs {
+		if a := allocations[env.ID]; len(a) > 0 {
+			r.Ports[env.EnvName()] = a
+		}
+	}
+	return r, nil
+}
+
+func bundleCacheIndex() (*bundleCache, error) {
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := cm.GetCacheSizes()
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return nil, err
+	}
+	return &bundleCache{Entries: entries, Stats: stats}, nil
+}
+
+func tailFile(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() <= max {
+		return io.ReadAll(f)
+	}
+	if _, err := f.Seek(info.Size()-max, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		data = data[i+1:]
+	}
+	return data, nil
+}
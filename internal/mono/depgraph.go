@@ -0,0 +1,218 @@
+package mono
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const (
+	ServiceKindCompose = "compose"
+	ServiceKindProcess = "process"
+)
+
+type ServiceStep struct {
+	Stage     int      `json:"stage"`
+	Service   string   `json:"service"`
+	Kind      string   `json:"kind"`
+	DependsOn []string `json:"depends_on,omitempty"`
+}
+
+func (c *Config) selectedWithDependencies() []string {
+	if len(c.services) == 0 {
+		return c.services
+	}
+	seen := make(map[string]bool)
+	var result []string
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		result = append(result, name)
+		if s := c.processService(name); s != nil {
+			for _, dep := range s.DependsOn {
+				visit(dep)
+			}
+		}
+	}
+	for _, name := range c.services {
+		visit(name)
+	}
+	return result
+}
+
+func (c *Config) serviceGraph(project *types.Project) (map[string][]string, map[string]string) {
+	deps := make(map[string][]string)
+	kinds := make(map[string]string)
+	if project != nil {
+		for _, name := range project.ServiceNames() {
+			kinds[name] = ServiceKindCompose
+		}
+		for _, name := range project.ServiceNames() {
+			deps[name] = []string{}
+			for _, dep := range sortedKeys(project.Services[name].DependsOn) {
+				if _, ok := project.Services[dep]; ok {
+					deps[name] = append(deps[name], dep)
+				}
+			}
+		}
+	}
+	for _, s := range c.SelectedProcessServices() {
+		kinds[s.Name] = ServiceKindProcess
+		deps[s.Name] = slices.Clone(s.DependsOn)
+		slices.Sort(deps[s.Name])
+	}
+	return deps, kinds
+}
+
+func (c *Config) StartupPlan(project *types.Project) ([]ServiceStep, error) {
+	deps, kinds := c.serviceGraph(project)
+	stages, err := serviceStages(deps)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []ServiceStep
+	for i, stage := range stages {
+		for _, name := range stage {
+			plan = append(plan, ServiceStep{Stage: i + 1, Service: name, Kind: kinds[name], DependsOn: deps[name]})
+		}
+	}
+	return plan, nil
+}
+
+func environmentPlan(cfg *Config, env *Environment) ([]ServiceStep, error) {
+	var project *types.Project
+	if env.DockerProject.Valid && env.DockerProject.String != "" {
+		composeConfig, err := ParseComposeConfig(env.ComposeDirPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compose config: %w", err)
+		}
+		if err := cfg.SelectCompose(composeConfig); err != nil {
+			return nil, err
+		}
+		project = composeConfig.Project()
+	}
+	return cfg.StartupPlan(project)
+}
+
+func planStages(plan []ServiceStep, kind string) [][]string {
+	var stages [][]string
+	for _, step := range plan {
+		if step.Kind != kind {
+			continue
+		}
+		for len(stages) < step.Stage {
+			stages = append(stages, nil)
+		}
+		stages[step.Stage-1] = append(stages[step.Stage-1], step.Service)
+	}
+	return slices.DeleteFunc(stages, func(stage []string) bool { return len(stage) == 0 })
+}
+
+func serviceStages(deps map[string][]string) ([][]string, error) {
+	for _, name := range sortedKeys(deps) {
+		for _, dep := range deps[name] {
+			if dep == name {
+				return nil, fmt.Errorf("service %s depends on itself", name)
+			}
+			if _, ok := deps[dep]; !ok {
+				return nil, fmt.Errorf("service %s depends on %s, which is not part of the environment", name, dep)
+			}
+		}
+	}
+
+	placed := make(map[string]bool, len(deps))
+	var stages [][]string
+	for len(placed) < len(deps) {
+		var stage []string
+		for _, name := range sortedKeys(deps) {
+			if placed[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[name] {
+				if !placed[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				stage = append(stage, name)
+			}
+		}
+		if len(stage) == 0 {
+			return nil, fmt.Errorf("dependency cycle: %s", strings.Join(dependencyCycle(deps, placed), " -> "))
+		}
+		for _, name := range stage {
+			placed[name] = true
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+func dependencyCycle(deps map[string][]string, placed map[string]bool) []string {
+	var start string
+	for _, name := range sortedKeys(deps) {
+		if !placed[name] {
+			start = name
+			break
+		}
+	}
+
+	index := make(map[string]int)
+	var path []string
+	for current := start; ; {
+		if i, ok := index[current]; ok {
+			return append(path[i:], current)
+		}
+		index[current] = len(path)
+		path = append(path, current)
+		for _, dep := range deps[current] {
+			if !placed[dep] {
+				current = dep
+				break
+			}
+		}
+	}
+}
+
+func stopProcessServices(tm *TmuxManager, plan []ServiceStep, logger *FileLogger) error {
+	stages := planStages(plan, ServiceKindProcess)
+	for i := len(stages) - 1; i >= 0; i-- {
+		for _, name := range stages[i] {
+			exists, err := WindowExists(tm.sessionName, name)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			if err := tm.interrupt(tm.sessionName + ":" + name); err != nil {
+				return fmt.Errorf("failed to stop service %s: %w", name, err)
+			}
+			logger.Log("stopped service %s", name)
+		}
+	}
+	return nil
+}
+
+func stopComposeServices(containers ContainerRuntime, dockerProject, composeDir string, plan []ServiceStep, stdout, stderr io.Writer) error {
+	stages := planStages(plan, ServiceKindCompose)
+	if len(stages) < 2 {
+		return nil
+	}
+	for i := len(stages) - 1; i >= 0; i-- {
+		args := append([]string{"stop"}, stages[i]...)
+		if err := containers.Compose(dockerProject, composeDir, stdout, stderr, args...); err != nil {
+			return fmt.Errorf("failed to stop %s: %w", strings.Join(stages[i], ", "), err)
+		}
+	}
+	return nil
+}
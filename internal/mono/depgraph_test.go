@@ -0,0 +1,61 @@
+package mono
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestStartupPlan(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			"cache": {Name: "cache"},
+			"db":    {Name: "db"},
+			"web":   {Name: "web", DependsOn: types.DependsOnConfig{"db": {Condition: types.ServiceConditionStarted}}},
+		},
+	}
+	cfg := &Config{Services: []ServiceConfig{
+		{Name: "worker", Command: "w", DependsOn: []string{"api", "cache"}},
+		{Name: "api", Command: "a", DependsOn: []string{"db"}},
+	}}
+
+	plan, err := cfg.StartupPlan(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ServiceStep{
+		{Stage: 1, Service: "cache", Kind: ServiceKindCompose, DependsOn: []string{}},
+		{Stage: 1, Service: "db", Kind: ServiceKindCompose, DependsOn: []string{}},
+		{Stage: 2, Service: "api", Kind: ServiceKindProcess, DependsOn: []string{"db"}},
+		{Stage: 2, Service: "web", Kind: ServiceKindCompose, DependsOn: []string{"db"}},
+		{Stage: 3, Service: "worker", Kind: ServiceKindProcess, DependsOn: []string{"api", "cache"}},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("plan = %+v\nwant %+v", plan, want)
+	}
+	if got := planStages(plan, ServiceKindProcess); !reflect.DeepEqual(got, [][]string{{"api"}, {"worker"}}) {
+		t.Errorf("process stages = %v", got)
+	}
+	if got := planStages(plan, ServiceKindCompose); !reflect.DeepEqual(got, [][]string{{"cache", "db"}, {"web"}}) {
+		t.Errorf("compose stages = %v", got)
+	}
+
+	cfg.services = []string{"worker"}
+	if got := cfg.SelectedServices(); !reflect.DeepEqual(got, []string{"db", "cache"}) {
+		t.Errorf("SelectedServices = %v, want the compose dependencies of worker and api", got)
+	}
+	if got := cfg.SelectedProcessServices(); len(got) != 2 || got[0].Name != "worker" || got[1].Name != "api" {
+		t.Errorf("SelectedProcessServices = %+v, want worker and api", got)
+	}
+
+	_, err = serviceStages(map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}})
+	if err == nil || !strings.Contains(err.Error(), "b -> c -> b") {
+		t.Errorf("err = %v, want the cycle named", err)
+	}
+	_, err = serviceStages(map[string][]string{"a": {"missing"}})
+	if err == nil || !strings.Contains(err.Error(), "not part of the environment") {
+		t.Errorf("err = %v, want the unknown dependency rejected", err)
+	}
+}
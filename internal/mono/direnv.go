@@ -0,0 +1,163 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const (
+	EnvrcFile   = ".envrc"
+	envrcHeader = "# generated by mono, do not edit"
+)
+
+type DirenvConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Path    []string `yaml:"path"`
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func RenderEnvrc(scriptEnv []string, urls map[string]string, pathAdds []string) string {
+	values := make(map[string]string)
+	for _, kv := range scriptEnv {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			values[k] = v
+		}
+	}
+	for k, v := range urls {
+		values[k] = v
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(envrcHeader + "\n")
+	for _, k := range keys {
+		if IsSecretRef(values[k]) {
+			fmt.Fprintf(&b, "export %s=%s\n", k, secretShellExpr(values[k]))
+			continue
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(values[k]))
+	}
+	for _, p := range pathAdds {
+		fmt.Fprintf(&b, "PATH_add %s\n", shellQuote(p))
+	}
+	return b.String()
+}
+
+func isGeneratedEnvrc(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return strings.HasPrefix(string(data), envrcHeader), nil
+}
+
+func WriteEnvrc(envPath string, cfg DirenvConfig, scriptEnv []string, project *types.Project, allocations []Allocation) (string, error) {
+	path := filepath.Join(envPath, EnvrcFile)
+	generated, err := isGeneratedEnvrc(path)
+	if err != nil {
+		return "", err
+	}
+	if !generated {
+		return "", fmt.Errorf("%s exists and was not generated by mono, remove it or disable direnv in mono.yml", path)
+	}
+
+	content := RenderEnvrc(scriptEnv, ServiceURLs(project, allocations), cfg.Path)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func AllowEnvrc(path string) error {
+	if _, err := exec.LookPath("direnv"); err != nil {
+		return nil
+	}
+	output, err := Command("direnv", "allow", path).
+		Timeout(30 * time.Second).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("direnv allow failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func RemoveEnvrc(envPath string) error {
+	path := filepath.Join(envPath, EnvrcFile)
+	generated, err := isGeneratedEnvrc(path)
+	if err != nil {
+		return err
+	}
+	if !generated {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+func GenerateEnvrc(path string) (string, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return "", err
+	}
+	written, err := WriteEnvrc(ec.Env.Path, ec.Config.Direnv, ec.Vars, ec.Project, ec.Allocations)
+	if err != nil {
+		return "", err
+	}
+	return written, AllowEnvrc(written)
+}
+
+func PrintEnvrc(path string) (string, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return "", err
+	}
+	return RenderEnvrc(ec.Vars, ServiceURLs(ec.Project, ec.Allocations), ec.Config.Direnv.Path), nil
+}
+
+func refreshEnvFiles(path string, logger *FileLogger) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		logger.Log("warning: failed to refresh env files: %v", err)
+		return
+	}
+
+	if ec.Config.Dotenv.Enabled {
+		if written, err := WriteDotenv(ec.Env.Path, ec.Config.Dotenv, ec.Vars, ec.Project, ec.Allocations); err != nil {
+			logger.Log("warning: %v", err)
+		} else {
+			logger.Log("regenerated %s", written)
+		}
+	}
+
+	if ec.Config.Direnv.Enabled {
+		written, err := WriteEnvrc(ec.Env.Path, ec.Config.Direnv, ec.Vars, ec.Project, ec.Allocations)
+		if err != nil {
+			logger.Log("warning: %v", err)
+			return
+		}
+		logger.Log("regenerated %s", written)
+		if err := AllowEnvrc(written); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}
+}
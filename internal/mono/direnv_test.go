@@ -0,0 +1,59 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderEnvrc(t *testing.T) {
+	content := RenderEnvrc(
+		[]string{"MONO_ENV_NAME=feature", "GREETING=it's here"},
+		map[string]string{"MONO_DB_URL": "postgres://postgres@127.0.0.1:19000/postgres"},
+		[]string{"node_modules/.bin", "bin"},
+	)
+
+	for _, line := range []string{
+		`export GREETING='it'\''s here'`,
+		`export MONO_DB_URL='postgres://postgres@127.0.0.1:19000/postgres'`,
+		`export MONO_ENV_NAME='feature'`,
+		`PATH_add 'node_modules/.bin'`,
+		`PATH_add 'bin'`,
+	} {
+		if !strings.Contains(content, line+"\n") {
+			t.Errorf("envrc missing %s:\n%s", line, content)
+		}
+	}
+}
+
+func TestWriteEnvrcKeepsUserFile(t *testing.T) {
+	dir := t.TempDir()
+	userEnvrc := filepath.Join(dir, EnvrcFile)
+	if err := os.WriteFile(userEnvrc, []byte("use nix\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := WriteEnvrc(dir, DirenvConfig{}, nil, nil, nil); err == nil {
+		t.Error("expected an error when .envrc was not generated by mono")
+	}
+	if err := RemoveEnvrc(dir); err != nil {
+		t.Fatalf("RemoveEnvrc failed: %v", err)
+	}
+	if data, err := os.ReadFile(userEnvrc); err != nil || string(data) != "use nix\n" {
+		t.Errorf("user .envrc should be untouched, got %q, %v", data, err)
+	}
+
+	if err := os.Remove(userEnvrc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteEnvrc(dir, DirenvConfig{}, []string{"A=1"}, nil, nil); err != nil {
+		t.Fatalf("WriteEnvrc failed: %v", err)
+	}
+	if err := RemoveEnvrc(dir); err != nil {
+		t.Fatalf("RemoveEnvrc failed: %v", err)
+	}
+	if _, err := os.Stat(userEnvrc); !os.IsNotExist(err) {
+		t.Errorf("generated .envrc should be removed, got %v", err)
+	}
+}
@@ -2,11 +2,14 @@ package mono
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -14,21 +17,6 @@ import (
 	"github.com/compose-spec/compose-go/v2/types"
 )
 
-func CheckDockerAvailable() error {
-	cmd := exec.Command("docker", "info")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		outputStr := strings.ToLower(string(output))
-		if strings.Contains(outputStr, "cannot connect") ||
-			strings.Contains(outputStr, "is the docker daemon running") ||
-			strings.Contains(outputStr, "connection refused") {
-			return fmt.Errorf("docker daemon isn't running, please (re)start it")
-		}
-		return fmt.Errorf("docker unavailable: %s", strings.TrimSpace(string(output)))
-	}
-	return nil
-}
-
 var composeFilenames = []string{
 	"docker-compose.yml",
 	"docker-compose.yaml",
@@ -86,22 +74,99 @@ func ParseComposeConfig(workDir string) (*ComposeConfig, error) {
 	return &ComposeConfig{project: project}, nil
 }
 
-func (c *ComposeConfig) GetServicePorts() map[string][]int {
-	result := make(map[string][]int)
+func (c *ComposeConfig) SelectServices(names []string) error {
+	if names == nil {
+		return nil
+	}
+	if len(names) == 0 {
+		c.project.Services = types.Services{}
+		return nil
+	}
+	project, err := c.project.WithSelectedServices(names, types.IncludeDependencies)
+	if err != nil {
+		return fmt.Errorf("failed to select services: %w", err)
+	}
+	c.project = project
+	return nil
+}
+
+func (c *ComposeConfig) EnableProfiles(profiles []string) error {
+	known := make(map[string]bool)
+	for _, svc := range c.project.AllServices() {
+		for _, p := range svc.Profiles {
+			known[p] = true
+		}
+	}
+	for _, p := range profiles {
+		if p == "*" || known[p] {
+			continue
+		}
+		if len(known) == 0 {
+			return fmt.Errorf("unknown compose profile %q: no compose service declares profiles", p)
+		}
+		return fmt.Errorf("unknown compose profile %q (available: %s)", p, strings.Join(sortedKeys(known), ", "))
+	}
+
+	project, err := c.project.WithProfiles(profiles)
+	if err != nil {
+		return fmt.Errorf("failed to enable compose profiles: %w", err)
+	}
+	for name, svc := range project.Services {
+		svc.Profiles = nil
+		project.Services[name] = svc
+	}
+	c.project = project
+	return nil
+}
+
+func (c *ComposeConfig) GetServicePorts() map[string][]PortRequest {
+	result := make(map[string][]PortRequest)
 	for _, svc := range c.project.Services {
-		var ports []int
+		byProtocol := make(map[string][]int)
 		for _, p := range svc.Ports {
-			if p.Target > 0 {
-				ports = append(ports, int(p.Target))
+			if p.Target == 0 {
+				continue
+			}
+			protocol := strings.ToLower(p.Protocol)
+			if protocol == "" {
+				protocol = ProtocolTCP
 			}
+			byProtocol[protocol] = append(byProtocol[protocol], int(p.Target))
 		}
-		if len(ports) > 0 {
-			result[svc.Name] = ports
+
+		var requests []PortRequest
+		for protocol, ports := range byProtocol {
+			requests = append(requests, groupPortRanges(ports, protocol)...)
+		}
+		if len(requests) > 0 {
+			result[svc.Name] = requests
 		}
 	}
 	return result
 }
 
+func groupPortRanges(ports []int, protocol string) []PortRequest {
+	sorted := make([]int, len(ports))
+	copy(sorted, ports)
+	sort.Ints(sorted)
+
+	var requests []PortRequest
+	for _, port := range sorted {
+		if n := len(requests); n > 0 {
+			last := &requests[n-1]
+			if port < last.Port+last.Count {
+				continue
+			}
+			if port == last.Port+last.Count {
+				last.Count++
+				continue
+			}
+		}
+		requests = append(requests, PortRequest{Port: port, Count: 1, Protocol: protocol})
+	}
+	return requests
+}
+
 func (c *ComposeConfig) GetServiceNames() []string {
 	names := make([]string, 0, len(c.project.Services))
 	for _, svc := range c.project.Services {
@@ -114,36 +179,65 @@ func (c *ComposeConfig) Project() *types.Project {
 	return c.project
 }
 
-func ApplyOverrides(project *types.Project, envName string, allocations []Allocation) {
-	monoPrefix := fmt.Sprintf("mono-%s", envName)
+func ApplyOverrides(project *types.Project, dockerProject string, allocations []Allocation) error {
+	monoPrefix := dockerProject
 
 	portsByService := make(map[string][]types.ServicePortConfig)
 	for _, alloc := range allocations {
-		portsByService[alloc.Service] = append(portsByService[alloc.Service], types.ServicePortConfig{
-			Target:    uint32(alloc.ContainerPort),
-			Published: fmt.Sprintf("%d", alloc.HostPort),
-		})
+		for i := 0; i < alloc.Size(); i++ {
+			portsByService[alloc.Service] = append(portsByService[alloc.Service], types.ServicePortConfig{
+				Target:    uint32(alloc.ContainerPort + i),
+				Published: fmt.Sprintf("%d", alloc.HostPort+i),
+				Protocol:  alloc.Protocol,
+			})
+		}
 	}
 
-	for name, svc := range project.Services {
-		if newPorts, ok := portsByService[name]; ok {
-			svc.Ports = newPorts
-			project.Services[name] = svc
+	for _, name := range project.ServiceNames() {
+		svc := project.Services[name]
+		if svc.NetworkMode == "host" {
+			return fmt.Errorf("service %s uses network_mode: host, which shares ports with every other environment; remove it so the service joins the environment's own network", name)
+		}
+		svc.Ports = portsByService[name]
+		if svc.Networks != nil && svc.NetworkMode == "" {
+			if _, ok := svc.Networks["default"]; !ok {
+				svc.Networks["default"] = nil
+			}
+		}
+		if svc.ContainerName != "" {
+			svc.ContainerName = fmt.Sprintf("%s-%s", monoPrefix, svc.ContainerName)
 		}
+		project.Services[name] = svc
 	}
 
-	project.Networks = types.Networks{
+	newNetworks := types.Networks{
 		"default": types.NetworkConfig{
 			Name: monoPrefix,
 		},
 	}
+	for netName, netConfig := range project.Networks {
+		if bool(netConfig.External) {
+			newNetworks[netName] = netConfig
+			continue
+		}
+		if netName == "default" {
+			netConfig.Name = monoPrefix
+		} else {
+			netConfig.Name = fmt.Sprintf("%s_%s", monoPrefix, netName)
+		}
+		newNetworks[netName] = netConfig
+	}
+	project.Networks = newNetworks
 
 	newVolumes := types.Volumes{}
 	for volName, volConfig := range project.Volumes {
-		volConfig.Name = fmt.Sprintf("%s_%s", monoPrefix, volName)
+		if !bool(volConfig.External) {
+			volConfig.Name = fmt.Sprintf("%s_%s", monoPrefix, volName)
+		}
 		newVolumes[volName] = volConfig
 	}
 	project.Volumes = newVolumes
+	return nil
 }
 
 func WriteComposeOverride(path string, project *types.Project) error {
@@ -157,55 +251,270 @@ func WriteComposeOverride(path string, project *types.Project) error {
 	return nil
 }
 
-func StartContainers(projectName, workDir string, stdout, stderr io.Writer) error {
+type dockerRuntime struct{}
+
+func (dockerRuntime) Name() string {
+	return RuntimeDocker
+}
+
+func (dockerRuntime) CheckAvailable() error {
+	cmd := exec.Command("docker", "info")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		outputStr := strings.ToLower(string(output))
+		if strings.Contains(outputStr, "cannot connect") ||
+			strings.Contains(outputStr, "is the docker daemon running") ||
+			strings.Contains(outputStr, "connection refused") {
+			return fmt.Errorf("docker daemon %w", ErrRuntimeNotRunning)
+		}
+		return fmt.Errorf("docker unavailable: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (dockerRuntime) StartContainers(projectName, workDir string, stdout, stderr io.Writer) error {
+	return composeUp([]string{"docker", "compose"}, projectName, workDir, stdout, stderr)
+}
+
+func (dockerRuntime) StopContainers(projectName, workDir string, removeVolumes bool, stdout, stderr io.Writer) error {
+	return composeDown([]string{"docker", "compose"}, projectName, workDir, removeVolumes, stdout, stderr)
+}
+
+func (dockerRuntime) StopProject(projectName string) error {
+	return composeStop([]string{"docker", "compose"}, projectName)
+}
+
+func (dockerRuntime) ContainersRunning(projectName string) bool {
+	cmd := exec.Command("docker", "compose", "-p", projectName, "ps", "-q")
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	return len(strings.TrimSpace(string(output))) > 0
+}
+
+func (dockerRuntime) ContainerStatuses(projectName string) ([]ContainerStatus, error) {
+	output, err := Command("docker", "compose", "-p", projectName, "ps", "-a", "--format", "json").
+		Timeout(30 * time.Second).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return parseComposePS(output)
+}
+
+func (dockerRuntime) ProjectVolumes(projectName string) ([]string, error) {
+	return projectVolumes("docker", projectName)
+}
+
+func (dockerRuntime) ProjectResources(projectName string) ([]ContainerResource, error) {
+	return projectResources("docker", projectName)
+}
+
+func (dockerRuntime) RemoveResources(resources []ContainerResource) error {
+	return removeResources("docker", resources)
+}
+
+func (dockerRuntime) ExportVolume(volume, dir, file string) error {
+	return exportVolume("docker", volumeHelperImage, volume, dir, file)
+}
+
+func (dockerRuntime) ImportVolume(projectName, volume, dir, file string) error {
+	return importVolume("docker", volumeHelperImage, projectName, volume, dir, file)
+}
+
+func (dockerRuntime) ImageExists(image string) (bool, error) {
+	return imageExists("docker", image)
+}
+
+func (dockerRuntime) PullImage(image string) error {
+	return pullImageWith("docker", image)
+}
+
+func (dockerRuntime) TagImage(source, target string) error {
+	return tagImage("docker", source, target)
+}
+
+func (dockerRuntime) RemoveImage(image string) error {
+	return removeImage("docker", image)
+}
+
+func (dockerRuntime) EnsureRegistryMirror(mirror RegistryMirrorConfig) error {
+	return ensureRegistryMirror("docker", mirror)
+}
+
+func (dockerRuntime) Compose(projectName, workDir string, stdout, stderr io.Writer, args ...string) error {
+	return composeRun([]string{"docker", "compose"}, projectName, workDir, stdout, stderr, args...)
+}
+
+func (dockerRuntime) ComposeCommand(projectName, workDir string, args ...string) (*exec.Cmd, error) {
+	return composeCommand([]string{"docker", "compose"}, projectName, workDir, args...), nil
+}
+
+func composeCommand(compose []string, projectName, workDir string, args ...string) *exec.Cmd {
+	cmd := exec.Command(compose[0], slices.Concat(compose[1:], []string{"-p", projectName, "-f", "docker-compose.mono.yml"}, args)...)
+	cmd.Dir = workDir
+	return cmd
+}
+
+func composeRun(compose []string, projectName, workDir string, stdout, stderr io.Writer, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, compose[0], slices.Concat(compose[1:], []string{"-p", projectName, "-f", "docker-compose.mono.yml"}, args)...)
+	cmd.Dir = workDir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%s %s timed out", strings.Join(compose, " "), strings.Join(args, " "))
+		}
+		return err
+	}
+	return nil
+}
+
+func composeUp(compose []string, projectName, workDir string, stdout, stderr io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "docker", "compose",
-		"-p", projectName,
-		"-f", "docker-compose.mono.yml",
-		"up", "-d")
+	args := slices.Concat(compose[1:], []string{"-p", projectName, "-f", "docker-compose.mono.yml", "up", "-d"})
+	cmd := exec.CommandContext(ctx, compose[0], args...)
 	cmd.Dir = workDir
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 
 	if err := cmd.Run(); err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("docker compose up timed out")
+			return fmt.Errorf("%s up timed out", strings.Join(compose, " "))
 		}
 		return fmt.Errorf("failed to start containers: %w", err)
 	}
 	return nil
 }
 
-func StopContainers(projectName, workDir string, removeVolumes bool, stdout, stderr io.Writer) error {
+func composeDown(compose []string, projectName, workDir string, removeVolumes bool, stdout, stderr io.Writer) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	args := []string{"compose", "-p", projectName, "down"}
+	args := slices.Concat(compose[1:], []string{"-p", projectName, "down"})
 	if removeVolumes {
 		args = append(args, "-v")
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd := exec.CommandContext(ctx, compose[0], args...)
 	cmd.Dir = workDir
 	cmd.Stdout = stdout
 	cmd.Stderr = stderr
 
 	if err := cmd.Run(); err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("docker compose down timed out")
+			return fmt.Errorf("%s down timed out", strings.Join(compose, " "))
 		}
 		return fmt.Errorf("failed to stop containers: %w", err)
 	}
 	return nil
 }
 
-func ContainersRunning(projectName string) bool {
-	cmd := exec.Command("docker", "compose", "-p", projectName, "ps", "-q")
-	output, err := cmd.Output()
+func composeStop(compose []string, projectName string) error {
+	args := slices.Concat(compose[1:], []string{"-p", projectName, "stop"})
+	output, err := Command(compose[0], args...).
+		Timeout(2 * time.Minute).
+		CombinedOutput()
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to stop containers: %w: %s", err, strings.TrimSpace(string(output)))
 	}
-	return len(strings.TrimSpace(string(output))) > 0
+	return nil
+}
+
+type ContainerStatus struct {
+	Service string `json:"service"`
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Health  string `json:"health,omitempty"`
+}
+
+func parseComposePS(output []byte) ([]ContainerStatus, error) {
+	type psEntry struct {
+		Service string
+		Name    string
+		State   string
+		Health  string
+	}
+
+	trimmed := strings.TrimSpace(string(output))
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	var entries []psEntry
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal([]byte(trimmed), &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+		}
+	} else {
+		for _, line := range strings.Split(trimmed, "\n") {
+			var e psEntry
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	statuses := make([]ContainerStatus, 0, len(entries))
+	for _, e := range entries {
+		statuses = append(statuses, ContainerStatus(e))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+const volumeHelperImage = "alpine:3"
+
+func projectVolumes(binary, projectName string) ([]string, error) {
+	output, err := Command(binary, "volume", "ls", "-q", "--filter", "label=com.docker.compose.project="+projectName).
+		Timeout(30 * time.Second).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes of %s: %w", projectName, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+func exportVolume(binary, image, volume, dir, file string) error {
+	output, err := Command(binary, "run", "--rm",
+		"-v", volume+":/volume:ro",
+		"-v", dir+":/backup",
+		image, "tar", "czf", "/backup/"+file, "-C", "/volume", ".").
+		Timeout(30 * time.Minute).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to export volume %s: %w: %s", volume, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func importVolume(binary, image, projectName, volume, dir, file string) error {
+	output, err := Command(binary, "volume", "create",
+		"--label", "com.docker.compose.project="+projectName,
+		"--label", "com.docker.compose.volume="+strings.TrimPrefix(volume, projectName+"_"),
+		volume).
+		Timeout(30 * time.Second).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create volume %s: %w: %s", volume, err, strings.TrimSpace(string(output)))
+	}
+
+	output, err = Command(binary, "run", "--rm",
+		"-v", volume+":/volume",
+		"-v", dir+":/backup:ro",
+		image, "tar", "xzf", "/backup/"+file, "-C", "/volume").
+		Timeout(30 * time.Minute).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to import volume %s: %w: %s", volume, err, strings.TrimSpace(string(output)))
+	}
+	return nil
 }
@@ -0,0 +1,338 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	DoctorOK      = "ok"
+	DoctorWarning = "warning"
+	DoctorError   = "error"
+
+	doctorTimeout    = 10 * time.Second
+	lowDiskSpace     = 10 << 30
+	criticalDiskFree = 1 << 30
+)
+
+var (
+	minTmuxVersion = version{3, 2}
+	minGitVersion  = version{2, 31}
+	versionPattern = regexp.MustCompile(`(\d+)\.(\d+)`)
+)
+
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+type version struct {
+	major, minor int
+}
+
+func (v version) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+func (v version) atLeast(min version) bool {
+	return v.major > min.major || (v.major == min.major && v.minor >= min.minor)
+}
+
+func parseVersion(s string) (version, bool) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return version{}, false
+	}
+	major, err := strconv.Atoi(m[1])
+	if err != nil {
+		return version{}, false
+	}
+	minor, err := strconv.Atoi(m[2])
+	if err != nil {
+		return version{}, false
+	}
+	return version{major, minor}, true
+}
+
+func Doctor(path string) ([]DoctorCheck, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mono home: %w", err)
+	}
+	if err := os.MkdirAll(monoHome, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", monoHome, err)
+	}
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, err
+	}
+	if err := cm.EnsureDirectories(); err != nil {
+		return nil, fmt.Errorf("failed to create cache directories: %w", err)
+	}
+
+	var checks []DoctorCheck
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		checks = append(checks, DoctorCheck{Name: "config", Status: DoctorError, Detail: err.Error(), Fix: "fix mono.yml, `mono config lint` explains most mistakes"})
+		cfg = &Config{}
+	}
+
+	checks = append(checks,
+		tmuxCheck(),
+		runtimeCheck(cfg),
+		sccacheCheck(),
+		diskCheck(monoHome),
+		hardlinkCheck(cm.LocalCacheDir, path),
+		cloneCheck(cm.LocalCacheDir),
+		flockCheck(filepath.Join(monoHome, "locks")),
+		gitCheck(path),
+	)
+	return checks, nil
+}
+
+func tmuxCheck() DoctorCheck {
+	c := DoctorCheck{Name: "tmux"}
+	if _, err := exec.LookPath("tmux"); err != nil {
+		c.Status, c.Detail = DoctorError, "not installed"
+		c.Fix = "install tmux " + minTmuxVersion.String() + " or newer (brew install tmux, apt install tmux)"
+		return c
+	}
+	output, err := Command("tmux", "-V").Timeout(doctorTimeout).Output()
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("tmux -V failed: %v", err)
+		c.Fix = "reinstall tmux"
+		return c
+	}
+	return tmuxVersionCheck(strings.TrimSpace(string(output)))
+}
+
+func tmuxVersionCheck(output string) DoctorCheck {
+	c := DoctorCheck{Name: "tmux", Status: DoctorOK, Detail: output}
+	v, ok := parseVersion(output)
+	if !ok {
+		c.Status, c.Detail = DoctorWarning, fmt.Sprintf("unrecognised version %q", output)
+		return c
+	}
+	if !v.atLeast(minTmuxVersion) {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("%s is too old, mono passes the environment to new sessions with -e", output)
+		c.Fix = "upgrade tmux to " + minTmuxVersion.String() + " or newer"
+	}
+	return c
+}
+
+func runtimeCheck(cfg *Config) DoctorCheck {
+	c := DoctorCheck{Name: "containers"}
+	containers, err := NewContainerRuntime(cfg.Runtime)
+	if err != nil {
+		c.Status, c.Detail = DoctorError, err.Error()
+		c.Fix = "set container_runtime to auto, docker or podman"
+		return c
+	}
+	c.Name = containers.Name()
+	if _, err := exec.LookPath(containers.Name()); err != nil {
+		c.Status, c.Detail = DoctorWarning, "not installed, environments with compose services can't start"
+		c.Fix = "install Docker Desktop, OrbStack, Colima or podman"
+		return c
+	}
+	err = containers.CheckAvailable()
+	switch {
+	case err == nil:
+		c.Status, c.Detail = DoctorOK, "running"
+	case errors.Is(err, ErrRuntimeNotRunning):
+		c.Status, c.Detail = DoctorWarning, err.Error()
+		if starters := runtimeStarters(containers.Name()); len(starters) > 0 {
+			c.Fix = fmt.Sprintf("start it with `%s`, or set container_autostart: true to let mono start it", starters[0])
+		}
+	default:
+		c.Status, c.Detail = DoctorError, err.Error()
+		c.Fix = fmt.Sprintf("check the output of `%s info`", containers.Name())
+	}
+	return c
+}
+
+func sccacheCheck() DoctorCheck {
+	if _, err := exec.LookPath("sccache"); err != nil {
+		return DoctorCheck{Name: "sccache", Status: DoctorWarning, Detail: "not installed, cargo builds don't share a compilation cache", Fix: "cargo install sccache"}
+	}
+	return DoctorCheck{Name: "sccache", Status: DoctorOK, Detail: "installed"}
+}
+
+func diskCheck(monoHome string) DoctorCheck {
+	c := DoctorCheck{Name: "disk"}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(monoHome, &st); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to read free space of %s: %v", monoHome, err)
+		return c
+	}
+	return diskSpaceCheck(monoHome, uint64(st.Bavail)*uint64(st.Bsize))
+}
+
+func diskSpaceCheck(monoHome string, free uint64) DoctorCheck {
+	c := DoctorCheck{Name: "disk", Status: DoctorOK, Detail: fmt.Sprintf("%.1f GB free under %s", float64(free)/(1<<30), monoHome)}
+	switch {
+	case free < criticalDiskFree:
+		c.Status = DoctorError
+	case free < lowDiskSpace:
+		c.Status = DoctorWarning
+	default:
+		return c
+	}
+	c.Fix = "free space with `mono cache clean`, or cap the cache with cache.max_size in ~/.mono/config.yaml"
+	return c
+}
+
+func hardlinkCheck(cacheDir, path string) DoctorCheck {
+	c := DoctorCheck{Name: "hardlinks"}
+	src, err := os.CreateTemp(cacheDir, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to write to %s: %v", cacheDir, err)
+		c.Fix = "make sure you own ~/.mono and it is writable"
+		return c
+	}
+	defer os.Remove(src.Name())
+	if err := src.Close(); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to write to %s: %v", cacheDir, err)
+		return c
+	}
+
+	target, into := cacheDir, "the cache"
+	if dirExists(path) {
+		target, into = path, path
+	}
+	dst := filepath.Join(target, filepath.Base(src.Name())+".link")
+	if err := os.Link(src.Name(), dst); err != nil {
+		c.Status, c.Detail = DoctorWarning, fmt.Sprintf("can't hardlink from %s into %s (%v), restores copy every file", cacheDir, into, err)
+		c.Fix = "keep ~/.mono and your worktrees on the same volume, envs_dir in mono.yml picks where worktrees go"
+		return c
+	}
+	if err := os.Remove(dst); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to remove %s: %v", dst, err)
+		return c
+	}
+	c.Status, c.Detail = DoctorOK, "cache restores hardlink into "+into
+	return c
+}
+
+func cloneCheck(cacheDir string) DoctorCheck {
+	c := DoctorCheck{Name: "clonefile", Status: DoctorOK}
+	var flag string
+	switch runtime.GOOS {
+	case "darwin":
+		flag = "-c"
+	case "linux":
+		flag = "--reflink=always"
+	default:
+		c.Detail = "not checked on " + runtime.GOOS
+		return c
+	}
+	src, err := os.CreateTemp(cacheDir, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to write to %s: %v", cacheDir, err)
+		return c
+	}
+	defer os.Remove(src.Name())
+	if err := src.Close(); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to write to %s: %v", cacheDir, err)
+		return c
+	}
+	dst := src.Name() + ".clone"
+	defer os.Remove(dst)
+	if err := Command("cp", flag, src.Name(), dst).Timeout(doctorTimeout).Run(); err != nil {
+		c.Detail = "not supported by the cache volume"
+		return c
+	}
+	c.Detail = "supported by the cache volume"
+	return c
+}
+
+func flockCheck(locksDir string) DoctorCheck {
+	c := DoctorCheck{Name: "flock", Fix: "keep ~/.mono on a local disk, network filesystems like NFS often don't support flock"}
+	if err := os.MkdirAll(locksDir, 0755); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to create %s: %v", locksDir, err)
+		return c
+	}
+	f, err := os.CreateTemp(locksDir, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to create a lock file in %s: %v", locksDir, err)
+		return c
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("flock failed in %s: %v, concurrent mono commands can corrupt environments", locksDir, err)
+		return c
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("failed to release a lock in %s: %v", locksDir, err)
+		return c
+	}
+	return DoctorCheck{Name: "flock", Status: DoctorOK, Detail: "supported in " + locksDir}
+}
+
+func gitCheck(path string) DoctorCheck {
+	c := DoctorCheck{Name: "git"}
+	if _, err := exec.LookPath("git"); err != nil {
+		c.Status, c.Detail = DoctorError, "not installed"
+		c.Fix = "install git " + minGitVersion.String() + " or newer"
+		return c
+	}
+	output, err := Command("git", "version").Timeout(doctorTimeout).Output()
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("git version failed: %v", err)
+		c.Fix = "reinstall git"
+		return c
+	}
+	v, ok := parseVersion(string(output))
+	if ok && !v.atLeast(minGitVersion) {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("%s is too old for mono's worktree handling", strings.TrimSpace(string(output)))
+		c.Fix = "upgrade git to " + minGitVersion.String() + " or newer"
+		return c
+	}
+
+	mainRepo, linked, err := GitMainWorktree(path)
+	if err != nil {
+		c.Status, c.Detail = DoctorWarning, fmt.Sprintf("%s is not in a git repository, mono create and worktree environments need one", path)
+		c.Fix = "run mono doctor from inside your project"
+		return c
+	}
+	list, err := Command("git", "worktree", "list", "--porcelain").Dir(path).Timeout(doctorTimeout).Output()
+	if err != nil {
+		c.Status, c.Detail = DoctorError, fmt.Sprintf("git worktree list failed: %v", err)
+		return c
+	}
+	return worktreeCheck(mainRepo, linked, string(list))
+}
+
+func worktreeCheck(mainRepo string, linked bool, porcelain string) DoctorCheck {
+	var worktrees, prunable int
+	for _, line := range strings.Split(porcelain, "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			worktrees++
+		case line == "prunable" || strings.HasPrefix(line, "prunable "):
+			prunable++
+		}
+	}
+	where := "main checkout"
+	if linked {
+		where = "worktree of " + mainRepo
+	}
+	c := DoctorCheck{Name: "git", Status: DoctorOK, Detail: fmt.Sprintf("%s, %d worktree(s)", where, worktrees)}
+	if prunable > 0 {
+		c.Status = DoctorWarning
+		c.Detail += fmt.Sprintf(", %d of them missing on disk", prunable)
+		c.Fix = "git -C " + mainRepo + " worktree prune"
+	}
+	return c
+}
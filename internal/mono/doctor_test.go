@@ -0,0 +1,87 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTmuxVersionCheck(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{output: "tmux 3.4", want: DoctorOK},
+		{output: "tmux 3.2a", want: DoctorOK},
+		{output: "tmux next-3.5", want: DoctorOK},
+		{output: "tmux 2.9a", want: DoctorError},
+		{output: "tmux master", want: DoctorWarning},
+	}
+	for _, tt := range tests {
+		c := tmuxVersionCheck(tt.output)
+		if c.Status != tt.want {
+			t.Errorf("tmuxVersionCheck(%q) = %s, want %s", tt.output, c.Status, tt.want)
+		}
+		if c.Status == DoctorError && c.Fix == "" {
+			t.Errorf("tmuxVersionCheck(%q) has no fix", tt.output)
+		}
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	tests := []struct {
+		free uint64
+		want string
+	}{
+		{free: 50 << 30, want: DoctorOK},
+		{free: 4 << 30, want: DoctorWarning},
+		{free: 200 << 20, want: DoctorError},
+	}
+	for _, tt := range tests {
+		if c := diskSpaceCheck("/home/me/.mono", tt.free); c.Status != tt.want {
+			t.Errorf("diskSpaceCheck(%d) = %s, want %s", tt.free, c.Status, tt.want)
+		}
+	}
+}
+
+func TestWorktreeCheck(t *testing.T) {
+	porcelain := "worktree /src/app\nHEAD abc\nbranch refs/heads/main\n\nworktree /envs/feature\nHEAD def\nbranch refs/heads/feature\n\nworktree /envs/gone\nHEAD 123\nbranch refs/heads/gone\nprunable gitdir file points to non-existent location\n"
+	c := worktreeCheck("/src/app", true, porcelain)
+	if c.Status != DoctorWarning || c.Fix != "git -C /src/app worktree prune" {
+		t.Errorf("worktreeCheck() = %+v, want a warning to prune", c)
+	}
+	if c.Detail != "worktree of /src/app, 3 worktree(s), 1 of them missing on disk" {
+		t.Errorf("detail = %q", c.Detail)
+	}
+
+	c = worktreeCheck("/src/app", false, "worktree /src/app\nHEAD abc\nbranch refs/heads/main\n")
+	if c.Status != DoctorOK || c.Detail != "main checkout, 1 worktree(s)" {
+		t.Errorf("worktreeCheck() = %+v", c)
+	}
+}
+
+func TestFilesystemChecks(t *testing.T) {
+	cacheDir := t.TempDir()
+	envPath := t.TempDir()
+
+	if c := hardlinkCheck(cacheDir, envPath); c.Status != DoctorOK {
+		t.Errorf("hardlinkCheck() = %+v", c)
+	}
+	if c := flockCheck(filepath.Join(cacheDir, "locks")); c.Status != DoctorOK {
+		t.Errorf("flockCheck() = %+v", c)
+	}
+	if c := cloneCheck(cacheDir); c.Status != DoctorOK {
+		t.Errorf("cloneCheck() = %+v", c)
+	}
+	for _, dir := range []string{cacheDir, envPath} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.Name() != "locks" {
+				t.Errorf("check left %s behind in %s", e.Name(), dir)
+			}
+		}
+	}
+}
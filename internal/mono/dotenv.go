@@ -0,0 +1,217 @@
+package mono
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const DefaultDotenvFile = ".env.mono"
+
+type DotenvConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	File    string            `yaml:"file"`
+	Vars    map[string]string `yaml:"vars"`
+}
+
+func (dc *DotenvConfig) ApplyDefaults() {
+	if dc.File == "" {
+		dc.File = DefaultDotenvFile
+	}
+}
+
+type databaseKind struct {
+	name   string
+	images []string
+	port   int
+	url    func(env map[string]string, port int) string
+}
+
+var databaseKinds = []databaseKind{
+	{
+		name:   "postgres",
+		images: []string{"postgres", "postgis", "timescaledb"},
+		port:   5432,
+		url: func(env map[string]string, port int) string {
+			user := envOr(env, "POSTGRES_USER", "postgres")
+			db := envOr(env, "POSTGRES_DB", user)
+			return fmt.Sprintf("postgres://%s@127.0.0.1:%d/%s", userInfo(user, env["POSTGRES_PASSWORD"]), port, db)
+		},
+	},
+	{
+		name:   "mysql",
+		images: []string{"mysql", "mariadb"},
+		port:   3306,
+		url: func(env map[string]string, port int) string {
+			user, password := "root", envOr(env, "MYSQL_ROOT_PASSWORD", env["MARIADB_ROOT_PASSWORD"])
+			if u := envOr(env, "MYSQL_USER", env["MARIADB_USER"]); u != "" {
+				user, password = u, envOr(env, "MYSQL_PASSWORD", env["MARIADB_PASSWORD"])
+			}
+			db := envOr(env, "MYSQL_DATABASE", env["MARIADB_DATABASE"])
+			return fmt.Sprintf("mysql://%s@127.0.0.1:%d/%s", userInfo(user, password), port, db)
+		},
+	},
+	{
+		name:   "redis",
+		images: []string{"redis", "valkey", "keydb"},
+		port:   6379,
+		url: func(env map[string]string, port int) string {
+			return fmt.Sprintf("redis://127.0.0.1:%d", port)
+		},
+	},
+	{
+		name:   "mongo",
+		images: []string{"mongo"},
+		port:   27017,
+		url: func(env map[string]string, port int) string {
+			if user := env["MONGO_INITDB_ROOT_USERNAME"]; user != "" {
+				return fmt.Sprintf("mongodb://%s@127.0.0.1:%d", userInfo(user, env["MONGO_INITDB_ROOT_PASSWORD"]), port)
+			}
+			return fmt.Sprintf("mongodb://127.0.0.1:%d", port)
+		},
+	},
+}
+
+func envOr(env map[string]string, key, fallback string) string {
+	if v := env[key]; v != "" {
+		return v
+	}
+	return fallback
+}
+
+func userInfo(user, password string) string {
+	if password == "" {
+		return url.User(user).String()
+	}
+	return url.UserPassword(user, password).String()
+}
+
+func ServiceURLs(project *types.Project, allocations []Allocation) map[string]string {
+	urls := make(map[string]string)
+	if project == nil {
+		return urls
+	}
+
+	for name, svc := range project.Services {
+		kind := matchDatabaseKind(svc.Image)
+		if kind == nil {
+			continue
+		}
+		hostPort := 0
+		for _, a := range allocations {
+			if a.Service == name && kind.port >= a.ContainerPort && kind.port < a.ContainerPort+a.Size() {
+				hostPort = a.HostPort + kind.port - a.ContainerPort
+				break
+			}
+		}
+		if hostPort == 0 {
+			continue
+		}
+
+		env := make(map[string]string)
+		for k, v := range svc.Environment {
+			if v != nil {
+				env[k] = *v
+			}
+		}
+		urls[strings.TrimSuffix(PortEnvVarName(name), "_PORT")+"_URL"] = kind.url(env, hostPort)
+	}
+	return urls
+}
+
+func matchDatabaseKind(image string) *databaseKind {
+	name := image
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexAny(name, ":@"); i >= 0 {
+		name = name[:i]
+	}
+	for i := range databaseKinds {
+		for _, candidate := range databaseKinds[i].images {
+			if name == candidate {
+				return &databaseKinds[i]
+			}
+		}
+	}
+	return nil
+}
+
+func RenderDotenv(scriptEnv []string, urls map[string]string, vars map[string]string) string {
+	values := make(map[string]string)
+	for _, kv := range scriptEnv {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			values[k] = v
+		}
+	}
+	for k, v := range urls {
+		values[k] = v
+	}
+
+	templated := make(map[string]string, len(vars))
+	for k, v := range vars {
+		templated[k] = os.Expand(v, func(name string) string {
+			if val, ok := values[name]; ok {
+				return val
+			}
+			return os.Getenv(name)
+		})
+	}
+	for k, v := range templated {
+		values[k] = v
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# generated by mono, do not edit\n")
+	for _, k := range keys {
+		v := values[k]
+		if IsSecretRef(v) {
+			v = secretDotenvValue(v)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, strconv.Quote(v))
+	}
+	return b.String()
+}
+
+func WriteDotenv(envPath string, cfg DotenvConfig, scriptEnv []string, project *types.Project, allocations []Allocation) (string, error) {
+	path := cfg.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(envPath, path)
+	}
+	content := RenderDotenv(scriptEnv, ServiceURLs(project, allocations), cfg.Vars)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func RemoveDotenv(envPath string, cfg DotenvConfig) error {
+	path := cfg.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(envPath, path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+func GenerateDotenv(path string) (string, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return "", err
+	}
+	return WriteDotenv(ec.Env.Path, ec.Config.Dotenv, ec.Vars, ec.Project, ec.Allocations)
+}
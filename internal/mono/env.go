@@ -1,10 +1,21 @@
 package mono
 
 import (
+	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+var validEnvName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func ValidateEnvName(name string) error {
+	if !validEnvName.MatchString(name) {
+		return fmt.Errorf("invalid environment name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
 func DeriveNames(path string) (project, workspace string) {
 	parts := strings.Split(path, string(filepath.Separator))
 	for i, part := range parts {
@@ -17,3 +28,10 @@ func DeriveNames(path string) (project, workspace string) {
 	return project, workspace
 }
 
+func DeriveEnvName(path string) string {
+	project, workspace := DeriveNames(path)
+	if project == "" || workspace == "" {
+		return filepath.Base(path)
+	}
+	return fmt.Sprintf("%s-%s", project, workspace)
+}
@@ -0,0 +1,84 @@
+package mono
+
+import (
+	"fmt"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+type EnvContext struct {
+	Env         *Environment
+	Config      *Config
+	Allocations []Allocation
+	Project     *types.Project
+	Vars        []string
+}
+
+func LoadEnvContext(path string) (*EnvContext, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.ApplyDefaults(env.Path)
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return nil, err
+	}
+
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ResolvePorts(allocations); err != nil {
+		return nil, fmt.Errorf("invalid mono.yml: %w", err)
+	}
+
+	var project *types.Project
+	if env.DockerProject.Valid && env.DockerProject.String != "" {
+		composeConfig, err := ParseComposeConfig(env.ComposeDirPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse compose config: %w", err)
+		}
+		if err := cfg.SelectCompose(composeConfig); err != nil {
+			return nil, err
+		}
+		project = composeConfig.Project()
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	cacheEnvVars := cm.EnvVars(cfg.Build)
+	cacheEnvVars = append(cacheEnvVars, "MONO_CACHE_DIR="+cm.LocalCacheDir)
+	cacheEnvVars = append(cacheEnvVars, PortEnvVars(allocations)...)
+	cacheEnvVars = append(cacheEnvVars, cfg.Kubernetes.EnvVars(env.EnvName())...)
+	if cfg.TLS.Enabled {
+		tlsVars, err := TLSEnvVars(env.EnvName())
+		if err != nil {
+			return nil, err
+		}
+		cacheEnvVars = append(cacheEnvVars, tlsVars...)
+	}
+
+	return &EnvContext{
+		Env:         env,
+		Config:      cfg,
+		Allocations: allocations,
+		Project:     project,
+		Vars:        buildScriptEnv(env.EnvName(), env.ID, env.Path, env.RootPath.String, allocations, cfg.Env, cacheEnvVars),
+	}, nil
+}
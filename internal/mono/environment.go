@@ -4,19 +4,63 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 type Environment struct {
-	ID            int64
-	Path          string
-	DockerProject sql.NullString
-	RootPath      sql.NullString
-	ComposeDir    sql.NullString
-	CreatedAt     time.Time
+	ID               int64
+	Path             string
+	DockerProject    sql.NullString
+	RootPath         sql.NullString
+	ComposeDir       sql.NullString
+	PortSlot         sql.NullInt64
+	Name             sql.NullString
+	Branch           sql.NullString
+	CreatedAt        time.Time
+	LastUsed         sql.NullTime
+	StaleSince       sql.NullTime
+	Template         sql.NullString
+	Profile          sql.NullString
+	ContainerRuntime sql.NullString
+	BaseCommit       sql.NullString
 }
 
-func (db *DB) InsertEnvironment(path, dockerProject, rootPath, composeDir string) (int64, error) {
+var ErrEnvironmentNotFound = errors.New("environment not found")
+
+var ErrEnvironmentNameInUse = errors.New("name already in use")
+
+const environmentColumns = `id, path, docker_project, root_path, compose_dir, port_slot, name, branch, created_at, last_used, stale_since, template, profile, container_runtime, base_commit`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEnvironment(row rowScanner) (*Environment, error) {
+	var e Environment
+	err := row.Scan(&e.ID, &e.Path, &e.DockerProject, &e.RootPath, &e.ComposeDir, &e.PortSlot, &e.Name, &e.Branch, &e.CreatedAt, &e.LastUsed, &e.StaleSince, &e.Template, &e.Profile, &e.ContainerRuntime, &e.BaseCommit)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *Environment) EnvName() string {
+	if e.Name.Valid && e.Name.String != "" {
+		return e.Name.String
+	}
+	return DeriveEnvName(e.Path)
+}
+
+func (e *Environment) ComposeDirPath() string {
+	if e.ComposeDir.Valid && e.ComposeDir.String != "" {
+		return filepath.Join(e.Path, e.ComposeDir.String)
+	}
+	return e.Path
+}
+
+func (db *DB) InsertEnvironment(path, dockerProject, rootPath, composeDir, branch string) (int64, error) {
 	var dp sql.NullString
 	if dockerProject != "" {
 		dp = sql.NullString{String: dockerProject, Valid: true}
@@ -32,10 +76,18 @@ func (db *DB) InsertEnvironment(path, dockerProject, rootPath, composeDir string
 		cd = sql.NullString{String: composeDir, Valid: true}
 	}
 
+	var br sql.NullString
+	if branch != "" {
+		br = sql.NullString{String: branch, Valid: true}
+	}
+
 	result, err := db.conn.Exec(
-		`INSERT INTO environments (path, docker_project, root_path, compose_dir) VALUES (?, ?, ?, ?)`,
-		path, dp, rp, cd,
+		`INSERT INTO environments (path, docker_project, root_path, compose_dir, name, branch, last_used) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		path, dp, rp, cd, DeriveEnvName(path), br,
 	)
+	if isEnvironmentNameConflict(err) {
+		return 0, fmt.Errorf("failed to insert environment: %w: %s", ErrEnvironmentNameInUse, DeriveEnvName(path))
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert environment: %w", err)
 	}
@@ -50,25 +102,184 @@ func (db *DB) InsertEnvironment(path, dockerProject, rootPath, composeDir string
 
 func (db *DB) GetEnvironmentByPath(path string) (*Environment, error) {
 	row := db.conn.QueryRow(
-		`SELECT id, path, docker_project, root_path, compose_dir, created_at FROM environments WHERE path = ?`,
+		`SELECT `+environmentColumns+` FROM environments WHERE path = ?`,
 		path,
 	)
 
-	var e Environment
-	err := row.Scan(&e.ID, &e.Path, &e.DockerProject, &e.RootPath, &e.ComposeDir, &e.CreatedAt)
+	e, err := scanEnvironment(row)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("environment not found")
+		return nil, ErrEnvironmentNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get environment: %w", err)
 	}
 
-	return &e, nil
+	return e, nil
+}
+
+func (db *DB) FindEnvironment(path string) (*Environment, error) {
+	dir := filepath.Clean(path)
+	for {
+		exists, err := db.EnvironmentExists(dir)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return db.GetEnvironmentByPath(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no environment found for %s", path)
+		}
+		dir = parent
+	}
+}
+
+func (db *DB) GetEnvironmentByName(name string) (*Environment, error) {
+	row := db.conn.QueryRow(
+		`SELECT `+environmentColumns+` FROM environments WHERE name = ?`,
+		name,
+	)
+
+	e, err := scanEnvironment(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrEnvironmentNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment: %w", err)
+	}
+
+	return e, nil
+}
+
+func (db *DB) RenameEnvironment(envID int64, name, branch string) error {
+	var br sql.NullString
+	if branch != "" {
+		br = sql.NullString{String: branch, Valid: true}
+	}
+	_, err := db.conn.Exec(
+		`UPDATE environments SET name = ?, branch = ? WHERE id = ?`,
+		name, br, envID,
+	)
+	if isEnvironmentNameConflict(err) {
+		return fmt.Errorf("failed to rename environment: %w: %s", ErrEnvironmentNameInUse, name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename environment: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) TouchEnvironment(envID int64) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET last_used = CURRENT_TIMESTAMP WHERE id = ?`,
+		envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update last used: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) SetEnvironmentTemplate(envID int64, template string) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET template = ? WHERE id = ?`,
+		template, envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set template: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) SetEnvironmentProfile(envID int64, profile string) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET profile = ? WHERE id = ?`,
+		profile, envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set profile: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) SetEnvironmentRuntime(envID int64, runtime string) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET container_runtime = ? WHERE id = ?`,
+		runtime, envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set container runtime: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) SetEnvironmentBaseCommit(envID int64, commit string) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET base_commit = ? WHERE id = ?`,
+		commit, envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set base commit: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) MarkEnvironmentStale(envID int64) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET stale_since = CURRENT_TIMESTAMP WHERE id = ? AND stale_since IS NULL`,
+		envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark environment stale: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) ClearEnvironmentStale(envID int64) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET stale_since = NULL WHERE id = ?`,
+		envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear stale marker: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) backfillEnvironmentNames() error {
+	rows, err := db.conn.Query(`SELECT id, path FROM environments WHERE name IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to query unnamed environments: %w", err)
+	}
+
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan environment: %w", err)
+		}
+		names[id] = DeriveEnvName(path)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("failed to query unnamed environments: %w", err)
+	}
+	rows.Close()
+
+	for id, name := range names {
+		if _, err := db.conn.Exec(`UPDATE environments SET name = ? WHERE id = ?`, name, id); err != nil {
+			return fmt.Errorf("failed to backfill environment name: %w", err)
+		}
+	}
+	return nil
 }
 
 func (db *DB) ListEnvironments() ([]*Environment, error) {
 	rows, err := db.conn.Query(
-		`SELECT id, path, docker_project, root_path, compose_dir, created_at FROM environments ORDER BY created_at DESC`,
+		`SELECT ` + environmentColumns + ` FROM environments ORDER BY created_at DESC`,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list environments: %w", err)
@@ -77,12 +288,11 @@ func (db *DB) ListEnvironments() ([]*Environment, error) {
 
 	var environments []*Environment
 	for rows.Next() {
-		var e Environment
-		err := rows.Scan(&e.ID, &e.Path, &e.DockerProject, &e.RootPath, &e.ComposeDir, &e.CreatedAt)
+		e, err := scanEnvironment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan environment: %w", err)
 		}
-		environments = append(environments, &e)
+		environments = append(environments, e)
 	}
 
 	return environments, rows.Err()
@@ -100,6 +310,17 @@ func (db *DB) EnvironmentExists(path string) (bool, error) {
 	return count > 0, nil
 }
 
+func (db *DB) SetPortSlot(envID int64, slot int) error {
+	_, err := db.conn.Exec(
+		`UPDATE environments SET port_slot = ? WHERE id = ?`,
+		slot, envID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set port slot: %w", err)
+	}
+	return nil
+}
+
 func (db *DB) DeleteEnvironment(path string) error {
 	result, err := db.conn.Exec(
 		`DELETE FROM environments WHERE path = ?`,
@@ -119,3 +340,51 @@ func (db *DB) DeleteEnvironment(path string) error {
 
 	return nil
 }
+
+func LookupEnvironmentPath(name string) (string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByName(name)
+	if err != nil {
+		return "", err
+	}
+	return env.Path, nil
+}
+
+func isEnvironmentNameConflict(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: environments.name")
+}
+
+func (db *DB) renameDuplicateEnvironmentNames() error {
+	rows, err := db.conn.Query(`SELECT e.id, e.name FROM environments e WHERE e.name IS NOT NULL AND EXISTS (SELECT 1 FROM environments o WHERE o.name = e.name AND o.id < e.id)`)
+	if err != nil {
+		return fmt.Errorf("failed to query duplicate environment names: %w", err)
+	}
+
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan environment: %w", err)
+		}
+		names[id] = fmt.Sprintf("%s-%d", name, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("failed to query duplicate environment names: %w", err)
+	}
+	rows.Close()
+
+	for id, name := range names {
+		if _, err := db.conn.Exec(`UPDATE environments SET name = ? WHERE id = ?`, name, id); err != nil {
+			return fmt.Errorf("failed to rename duplicate environment: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvironmentNameUnique(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	root := t.TempDir()
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.InsertEnvironment(filepath.Join(root, "a", "feature"), "", root, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertEnvironment(filepath.Join(root, "b", "feature"), "", root, "", ""); !errors.Is(err, ErrEnvironmentNameInUse) {
+		t.Errorf("InsertEnvironment() with a taken name = %v, want ErrEnvironmentNameInUse", err)
+	}
+
+	otherID, err := db.InsertEnvironment(filepath.Join(root, "other"), "", root, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RenameEnvironment(otherID, "feature", ""); !errors.Is(err, ErrEnvironmentNameInUse) {
+		t.Errorf("RenameEnvironment() to a taken name = %v, want ErrEnvironmentNameInUse", err)
+	}
+}
+
+func TestInitializeRenamesDuplicateEnvironmentNames(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	root := t.TempDir()
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	firstPath := filepath.Join(root, "a", "feature")
+	if _, err := db.InsertEnvironment(firstPath, "", root, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`DROP INDEX idx_environments_name`); err != nil {
+		t.Fatal(err)
+	}
+	secondPath := filepath.Join(root, "b", "feature")
+	secondID, err := db.InsertEnvironment(secondPath, "", root, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Initialize(); err != nil {
+		t.Fatalf("Initialize() with duplicate names = %v", err)
+	}
+	first, err := db.GetEnvironmentByName("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Path != firstPath {
+		t.Errorf("feature resolves to %s, want the oldest environment %s", first.Path, firstPath)
+	}
+	second, err := db.GetEnvironmentByPath(secondPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("feature-%d", secondID); second.Name.String != want {
+		t.Errorf("duplicate renamed to %q, want %q", second.Name.String, want)
+	}
+}
@@ -0,0 +1,169 @@
+package mono
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+const (
+	eventsFile = "events.ndjson"
+
+	EventRestore = "restore"
+	EventStore   = "store"
+	EventEvict   = "evict"
+	EventInit    = "init"
+	EventDestroy = "destroy"
+)
+
+var EventTypes = []string{EventRestore, EventStore, EventEvict, EventInit, EventDestroy}
+
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"type"`
+	Env        string    `json:"env,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Project    string    `json:"project,omitempty"`
+	Artifact   string    `json:"artifact,omitempty"`
+	Key        string    `json:"key,omitempty"`
+	Size       int64     `json:"size,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type EventFilter struct {
+	Since    time.Time
+	Type     string
+	Artifact string
+	Env      string
+}
+
+func (f EventFilter) Validate() error {
+	if f.Type != "" && !slices.Contains(EventTypes, f.Type) {
+		return unknownSelection("event type", f.Type, EventTypes)
+	}
+	return nil
+}
+
+func (f EventFilter) matches(e Event) bool {
+	return !e.Time.Before(f.Since) &&
+		(f.Type == "" || e.Type == f.Type) &&
+		(f.Artifact == "" || e.Artifact == f.Artifact) &&
+		(f.Env == "" || e.Env == f.Env)
+}
+
+func EventsPath() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, eventsFile), nil
+}
+
+func newEvent(kind string, start time.Time, err error) Event {
+	e := Event{Time: start.UTC(), Type: kind, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+func RecordEvent(e Event) error {
+	path, err := EventsPath()
+	if err != nil {
+		return err
+	}
+	if err := appendJSONLine(path, e); err != nil {
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	return nil
+}
+
+func appendJSONLine(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ReadEvents(filter EventFilter) ([]Event, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	path, err := EventsPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid event on line %d of %s: %w", line, path, err)
+		}
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event log: %w", err)
+	}
+	return events, nil
+}
+
+func (cm *CacheManager) recordCacheEvent(kind, cachePath, envPath string, start time.Time, opErr error) error {
+	e := newEvent(kind, start, opErr)
+	e.Key = filepath.Base(cachePath)
+	e.Artifact = filepath.Base(filepath.Dir(cachePath))
+	e.Project = filepath.Base(filepath.Dir(filepath.Dir(cachePath)))
+	if envPath != "" {
+		e.Env = DeriveEnvName(envPath)
+		e.Path = envPath
+	}
+	size, err := cacheEntrySize(cachePath)
+	if err != nil {
+		return err
+	}
+	e.Size = size
+	return RecordEvent(e)
+}
+
+func cacheEntrySize(cachePath string) (int64, error) {
+	m, err := ReadCacheManifest(cachePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return m.Size, nil
+}
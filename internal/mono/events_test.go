@@ -0,0 +1,87 @@
+package mono
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheOperationsAreLogged(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootPath := t.TempDir()
+	envPath := filepath.Join(t.TempDir(), "feature")
+	targetDir := filepath.Join(envPath, "target")
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "lib.rlib"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := ArtifactCacheEntry{
+		Name:      "cargo",
+		Key:       "abc123",
+		CachePath: cm.GetArtifactCachePath(rootPath, "cargo", "abc123"),
+		EnvPaths:  []string{targetDir},
+		EnvRoot:   envPath,
+	}
+	if err := cm.StoreToCache(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RestoreFromCache(entry, nil); err != nil {
+		t.Fatal(err)
+	}
+	projectID := ComputeProjectID(rootPath)
+	if err := cm.RemoveCacheEntry(projectID, "cargo", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	failed := newEvent(EventInit, time.Now().Add(-time.Second), errors.New("compose up failed"))
+	failed.Env = "feature"
+	if err := RecordEvent(failed); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadEvents(EventFilter{Artifact: "cargo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{EventStore, EventRestore, EventEvict}
+	if len(events) != len(want) {
+		t.Fatalf("ReadEvents() = %+v, want %v", events, want)
+	}
+	for i, e := range events {
+		if e.Type != want[i] || e.Key != "abc123" || e.Project != projectID || e.Size != 10 {
+			t.Errorf("event %d = %+v, want a %s of abc123 with its size", i, e, want[i])
+		}
+	}
+	if events[0].Env != "feature" || events[2].Env != "" {
+		t.Errorf("envs = %q, %q, want the store tied to feature and the eviction to none", events[0].Env, events[2].Env)
+	}
+
+	events, err = ReadEvents(EventFilter{Type: EventInit, Since: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Error != "compose up failed" || events[0].DurationMS < 1000 {
+		t.Errorf("init events = %+v", events)
+	}
+
+	events, err = ReadEvents(EventFilter{Since: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("ReadEvents() in the future = %+v, want none", events)
+	}
+	if _, err := ReadEvents(EventFilter{Type: "build"}); err == nil {
+		t.Error("ReadEvents() accepted an unknown event type")
+	}
+}
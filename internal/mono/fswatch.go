@@ -0,0 +1,331 @@
+package mono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const WatchRecursive = -1
+
+type FSWatcher struct {
+	watcher *fsnotify.Watcher
+	logger  *FileLogger
+	done    chan struct{}
+
+	mu    sync.Mutex
+	dirs  map[string]int
+	roots map[string]int
+	refs  map[string]int
+	subs  map[*FSSubscription]struct{}
+}
+
+type FSSubscription struct {
+	w      *FSWatcher
+	roots  map[string]int
+	events chan struct{}
+}
+
+func NewFSWatcher(logger *FileLogger) (*FSWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w := &FSWatcher{
+		watcher: watcher,
+		logger:  logger,
+		done:    make(chan struct{}),
+		dirs:    make(map[string]int),
+		roots:   make(map[string]int),
+		refs:    make(map[string]int),
+		subs:    make(map[*FSSubscription]struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *FSWatcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	if err != nil {
+		return fmt.Errorf("failed to close file watcher: %w", err)
+	}
+	return nil
+}
+
+func (w *FSWatcher) Subscribe() *FSSubscription {
+	s := &FSSubscription{w: w, roots: make(map[string]int), events: make(chan struct{}, 1)}
+	w.mu.Lock()
+	w.subs[s] = struct{}{}
+	w.mu.Unlock()
+	return s
+}
+
+func (s *FSSubscription) Watch(path string, depth int) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	if cur, ok := s.roots[abs]; !ok {
+		s.roots[abs] = depth
+		s.w.refs[abs]++
+	} else if !coversDepth(cur, depth) {
+		s.roots[abs] = depth
+	}
+	if cur, ok := s.w.roots[abs]; !ok || !coversDepth(cur, depth) {
+		s.w.roots[abs] = depth
+	}
+	if err := s.w.resolve(abs); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *FSSubscription) Events() <-chan struct{} {
+	return s.events
+}
+
+func (s *FSSubscription) Settle(ctx context.Context, quiet time.Duration) bool {
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.events:
+			timer.Reset(quiet)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+func (s *FSSubscription) Close() {
+	s.w.mu.Lock()
+	defer s.w.mu.Unlock()
+	if _, ok := s.w.subs[s]; !ok {
+		return
+	}
+	delete(s.w.subs, s)
+
+	released := false
+	for root := range s.roots {
+		s.w.refs[root]--
+		if s.w.refs[root] > 0 {
+			continue
+		}
+		delete(s.w.refs, root)
+		delete(s.w.roots, root)
+		released = true
+	}
+	if released {
+		s.w.prune()
+	}
+}
+
+func (s *FSSubscription) matches(path string) bool {
+	for root := range s.roots {
+		if pathWithin(path, root) || pathWithin(root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *FSSubscription) notify() {
+	select {
+	case s.events <- struct{}{}:
+	default:
+	}
+}
+
+func coversDepth(have, want int) bool {
+	return have == WatchRecursive || (want != WatchRecursive && have >= want)
+}
+
+func childDepth(depth int) int {
+	if depth == WatchRecursive {
+		return WatchRecursive
+	}
+	return depth - 1
+}
+
+func (w *FSWatcher) resolve(root string) error {
+	info, err := os.Stat(root)
+	if err == nil {
+		if !info.IsDir() {
+			return w.addTree(filepath.Dir(root), 0)
+		}
+		return w.addTree(root, w.roots[root])
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err == nil && info.IsDir() {
+			return w.addTree(dir, 0)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+}
+
+func (w *FSWatcher) addTree(dir string, depth int) error {
+	cur, watched := w.dirs[dir]
+	if watched && coversDepth(cur, depth) {
+		return nil
+	}
+	if !watched {
+		if err := w.watcher.Add(dir); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	w.dirs[dir] = depth
+	if depth == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || skipDirs[e.Name()] {
+			continue
+		}
+		if err := w.addTree(filepath.Join(dir, e.Name()), childDepth(depth)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *FSWatcher) forget(path string) {
+	for dir := range w.dirs {
+		if !pathWithin(dir, path) {
+			continue
+		}
+		delete(w.dirs, dir)
+		if err := w.watcher.Remove(dir); err != nil && !errors.Is(err, fsnotify.ErrNonExistentWatch) {
+			w.logger.Log("warning: failed to stop watching %s: %v", dir, err)
+		}
+	}
+}
+
+func (w *FSWatcher) prune() {
+	for root := range w.roots {
+		depth, found := 0, false
+		for s := range w.subs {
+			if cur, ok := s.roots[root]; ok && (!found || !coversDepth(depth, cur)) {
+				depth, found = cur, true
+			}
+		}
+		w.roots[root] = depth
+	}
+
+	previous := w.dirs
+	w.dirs = make(map[string]int)
+	for root := range w.roots {
+		if err := w.resolve(root); err != nil {
+			w.logger.Log("warning: failed to watch %s: %v", root, err)
+		}
+	}
+	for dir := range previous {
+		if _, ok := w.dirs[dir]; ok {
+			continue
+		}
+		if err := w.watcher.Remove(dir); err != nil && !errors.Is(err, fsnotify.ErrNonExistentWatch) {
+			w.logger.Log("warning: failed to stop watching %s: %v", dir, err)
+		}
+	}
+}
+
+func (w *FSWatcher) handle(event fsnotify.Event) {
+	path := filepath.Clean(event.Name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	switch {
+	case event.Has(fsnotify.Create):
+		if depth, ok := w.dirs[filepath.Dir(path)]; ok && depth != 0 && !skipDirs[filepath.Base(path)] {
+			info, err := os.Lstat(path)
+			if err != nil && !os.IsNotExist(err) {
+				w.logger.Log("warning: failed to inspect %s: %v", path, err)
+			}
+			if err == nil && info.IsDir() {
+				if err := w.addTree(path, childDepth(depth)); err != nil {
+					w.logger.Log("warning: failed to watch %s: %v", path, err)
+				}
+			}
+		}
+		for root := range w.roots {
+			if pathWithin(root, path) {
+				if err := w.resolve(root); err != nil {
+					w.logger.Log("warning: failed to watch %s: %v", root, err)
+				}
+			}
+		}
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		if _, ok := w.dirs[path]; ok {
+			w.forget(path)
+			for root := range w.roots {
+				if pathWithin(root, path) {
+					if err := w.resolve(root); err != nil {
+						w.logger.Log("warning: failed to watch %s: %v", root, err)
+					}
+				}
+			}
+		}
+	}
+
+	for s := range w.subs {
+		if s.matches(path) {
+			s.notify()
+		}
+	}
+}
+
+func (w *FSWatcher) run() {
+	defer close(w.done)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Log("warning: file watcher: %v", err)
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.mu.Lock()
+				for s := range w.subs {
+					s.notify()
+				}
+				w.mu.Unlock()
+			}
+		}
+	}
+}
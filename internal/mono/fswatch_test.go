@@ -0,0 +1,174 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestFSWatcher(t *testing.T) *FSWatcher {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	logger, err := NewFileLogger("fswatch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	fsw, err := NewFSWatcher(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := fsw.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return fsw
+}
+
+func waitForEvent(t *testing.T, sub *FSSubscription, what string) {
+	t.Helper()
+	select {
+	case <-sub.Events():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event after %s", what)
+	}
+}
+
+func drainEvents(sub *FSSubscription) {
+	for {
+		select {
+		case <-sub.Events():
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func TestFSWatcherRecursive(t *testing.T) {
+	fsw := newTestFSWatcher(t)
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"src/main.go": "package main\n"})
+
+	sub := fsw.Subscribe()
+	defer sub.Close()
+	if err := sub.Watch(filepath.Join(dir, "src"), WatchRecursive); err != nil {
+		t.Fatal(err)
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"src/main.go": "package main\n\nfunc main() {}\n"})
+	waitForEvent(t, sub, "editing a watched file")
+
+	if err := os.MkdirAll(filepath.Join(dir, "src", "api", "v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	drainEvents(sub)
+	writeBuildxFixture(t, dir, map[string]string{"src/api/v1/server.go": "package v1\n"})
+	waitForEvent(t, sub, "writing into a new subdirectory")
+
+	drainEvents(sub)
+	writeBuildxFixture(t, dir, map[string]string{"README.md": "docs", "src/node_modules/dep/index.js": ""})
+	drainEvents(sub)
+	writeBuildxFixture(t, dir, map[string]string{"src/node_modules/dep/index.js": "changed"})
+	select {
+	case <-sub.Events():
+		t.Error("got an event for a file in a skipped directory")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestFSWatcherMissingRoot(t *testing.T) {
+	fsw := newTestFSWatcher(t)
+	dir := t.TempDir()
+
+	sub := fsw.Subscribe()
+	defer sub.Close()
+	other := fsw.Subscribe()
+	defer other.Close()
+	if err := sub.Watch(filepath.Join(dir, "web", "src"), WatchRecursive); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Watch(filepath.Join(dir, "mono.yml"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"web/src/app.ts": "export {}\n"})
+	waitForEvent(t, sub, "creating the missing root")
+	drainEvents(sub)
+	writeBuildxFixture(t, dir, map[string]string{"web/src/app.ts": "export const x = 1\n"})
+	waitForEvent(t, sub, "editing a file under the created root")
+
+	select {
+	case <-other.Events():
+		t.Error("mono.yml subscriber got an event for unrelated files")
+	default:
+	}
+	writeBuildxFixture(t, dir, map[string]string{"mono.yml": "scripts: {}\n"})
+	waitForEvent(t, other, "writing a watched file")
+}
+
+func TestFSWatcherDepth(t *testing.T) {
+	fsw := newTestFSWatcher(t)
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"app/feature/mono.yml": "", "app/feature/src/main.go": ""})
+
+	sub := fsw.Subscribe()
+	defer sub.Close()
+	if err := sub.Watch(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"app/feature/src/main.go": "package main\n"})
+	select {
+	case <-sub.Events():
+		t.Error("got an event below the watched depth")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"app/other/mono.yml": ""})
+	waitForEvent(t, sub, "adding a workspace")
+	drainEvents(sub)
+	writeBuildxFixture(t, dir, map[string]string{"app/other/mono.yml": "scripts: {}\n"})
+	waitForEvent(t, sub, "editing a new workspace's mono.yml")
+}
+
+func TestFSWatcherCloseReleasesRoots(t *testing.T) {
+	fsw := newTestFSWatcher(t)
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"app/src/main.go": "", "config/mono.yml": ""})
+	app := filepath.Join(dir, "app")
+	configFile := filepath.Join(dir, "config", "mono.yml")
+
+	first := fsw.Subscribe()
+	if err := first.Watch(app, WatchRecursive); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Watch(configFile, 0); err != nil {
+		t.Fatal(err)
+	}
+	second := fsw.Subscribe()
+	defer second.Close()
+	if err := second.Watch(app, WatchRecursive); err != nil {
+		t.Fatal(err)
+	}
+
+	first.Close()
+	watched := fsw.watcher.WatchList()
+	if !slices.Contains(watched, filepath.Join(app, "src")) {
+		t.Errorf("watch list %v lost the root still used by another subscriber", watched)
+	}
+	if slices.Contains(watched, filepath.Dir(configFile)) {
+		t.Errorf("watch list %v still holds the closed subscriber's config directory", watched)
+	}
+
+	second.Close()
+	if watched := fsw.watcher.WatchList(); len(watched) != 0 {
+		t.Errorf("watch list = %v after every subscriber closed, want empty", watched)
+	}
+	if len(fsw.roots) != 0 || len(fsw.dirs) != 0 {
+		t.Errorf("roots = %v, dirs = %v after every subscriber closed", fsw.roots, fsw.dirs)
+	}
+}
@@ -0,0 +1,249 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gitTimeout = 2 * time.Minute
+
+func GitRepoRoot(dir string) (string, error) {
+	output, err := Command("git", "rev-parse", "--show-toplevel").
+		Dir(dir).
+		Output()
+	if err != nil {
+		return "", fmt.Errorf("not a git repository: %s", dir)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func GitRefExists(repo, ref string) bool {
+	return Command("git", "rev-parse", "--verify", "--quiet", ref).
+		Dir(repo).
+		Run() == nil
+}
+
+func GitCurrentBranch(dir string) (string, error) {
+	output, err := Command("git", "rev-parse", "--abbrev-ref", "HEAD").
+		Dir(dir).
+		Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func AddWorktree(repo, dir, branch, base string) error {
+	args := []string{"worktree", "add"}
+	switch {
+	case GitRefExists(repo, "refs/heads/"+branch):
+		args = append(args, dir, branch)
+	case GitRefExists(repo, "refs/remotes/origin/"+branch):
+		args = append(args, "--track", "-b", branch, dir, "origin/"+branch)
+	default:
+		args = append(args, "-b", branch, dir)
+		if base != "" {
+			args = append(args, base)
+		}
+	}
+
+	output, err := Command("git", args...).
+		Dir(repo).
+		Timeout(gitTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree add failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func GitMainWorktree(dir string) (string, bool, error) {
+	output, err := Command("git", "rev-parse", "--path-format=absolute", "--git-dir", "--git-common-dir").
+		Dir(dir).
+		Output()
+	if err != nil {
+		return "", false, fmt.Errorf("not a git repository: %s", dir)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 {
+		return "", false, fmt.Errorf("unexpected git rev-parse output: %s", output)
+	}
+	gitDir, commonDir := lines[0], lines[1]
+	return filepath.Dir(commonDir), gitDir != commonDir, nil
+}
+
+func DetectProjectRoot(path string) (string, error) {
+	mainRepo, linked, err := GitMainWorktree(path)
+	if err != nil {
+		return "", err
+	}
+	if !linked {
+		return "", nil
+	}
+	return mainRepo, nil
+}
+
+func RemoveWorktree(repo, dir string, force bool) error {
+	args := []string{"worktree", "remove"}
+	if force {
+		args = append(args, "--force")
+	}
+	args = append(args, dir)
+
+	output, err := Command("git", args...).
+		Dir(repo).
+		Timeout(gitTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree remove failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func GitRevParse(dir, ref string) (string, error) {
+	output, err := Command("git", "rev-parse", "--verify", ref+"^{commit}").
+		Dir(dir).
+		Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s in %s: %w", ref, dir, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func GitHead(dir string) (commit, branch string, err error) {
+	commit, err = GitRevParse(dir, "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+
+	branch, err = GitCurrentBranch(dir)
+	if err != nil {
+		return "", "", err
+	}
+	return commit, branch, nil
+}
+
+func GitIsAncestor(dir, ancestor, ref string) bool {
+	return Command("git", "merge-base", "--is-ancestor", ancestor, ref).
+		Dir(dir).
+		Run() == nil
+}
+
+func GitCommitDistance(dir, from, to string) (int, error) {
+	output, err := Command("git", "rev-list", "--count", from+".."+to).
+		Dir(dir).
+		Output()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count commits %s..%s: %w", from, to, err)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected rev-list output: %s", output)
+	}
+	return count, nil
+}
+
+func GitDefaultBranch(dir string) string {
+	output, err := Command("git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD").
+		Dir(dir).
+		Output()
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(output)), "origin/")
+	}
+	for _, candidate := range []string{"main", "master"} {
+		if GitRefExists(dir, "refs/heads/"+candidate) {
+			return candidate
+		}
+	}
+	return "main"
+}
+
+func GitUpstream(dir, branch string) (upstream string, gone bool, err error) {
+	output, err := Command("git", "for-each-ref", "--format=%(upstream:short)|%(upstream:track)", "refs/heads/"+branch).
+		Dir(dir).
+		Output()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read upstream of %s: %w", branch, err)
+	}
+	upstream, track, _ := strings.Cut(strings.TrimSpace(string(output)), "|")
+	return upstream, track == "[gone]", nil
+}
+
+func GitFetchPrune(dir string) error {
+	output, err := Command("git", "fetch", "--all", "--prune", "--quiet").
+		Dir(dir).
+		Env(append(os.Environ(), "GIT_TERMINAL_PROMPT=0")).
+		Timeout(gitTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git fetch failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func GitChangedPaths(dir string) ([]string, error) {
+	output, err := Command("git", "status", "--porcelain", "-z", "--untracked-files=all").
+		Dir(dir).
+		Timeout(gitTimeout).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status of %s: %w", dir, err)
+	}
+
+	var paths []string
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		paths = append(paths, entry[3:])
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+	return paths, nil
+}
+
+func GitMergeBase(dir, ref string) (string, error) {
+	output, err := Command("git", "merge-base", ref, "HEAD").
+		Dir(dir).
+		Timeout(gitTimeout).
+		CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base of %s and HEAD: %w: %s", ref, err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func GitChangedFiles(dir, base string) ([]string, error) {
+	changed, err := Command("git", "diff", "--name-only", "--relative", base).
+		Dir(dir).
+		Timeout(gitTimeout).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff against %s: %w", base, err)
+	}
+	untracked, err := Command("git", "ls-files", "--others", "--exclude-standard").
+		Dir(dir).
+		Timeout(gitTimeout).
+		Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(changed)+string(untracked), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !slices.Contains(files, line) {
+			files = append(files, line)
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
@@ -0,0 +1,178 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultHostsFile   = "/etc/hosts"
+	DefaultHostsDomain = "test"
+	hostsLoopback      = "127.0.0.1"
+)
+
+var invalidHostnameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func hostnameLabel(s string) string {
+	label := invalidHostnameChars.ReplaceAllString(strings.ToLower(s), "-")
+	return strings.Trim(label, "-")
+}
+
+func EnvHostnames(envName, domain string, allocations []Allocation) []string {
+	env := hostnameLabel(envName)
+	seen := map[string]bool{}
+	hostnames := []string{fmt.Sprintf("%s.%s", env, domain)}
+	for _, a := range allocations {
+		service := hostnameLabel(a.Service)
+		if seen[service] {
+			continue
+		}
+		seen[service] = true
+		hostnames = append(hostnames, fmt.Sprintf("%s.%s.%s", service, env, domain))
+	}
+	sort.Strings(hostnames[1:])
+	return hostnames
+}
+
+func hostsMarkers(envName string) (string, string) {
+	return "# mono:begin " + envName, "# mono:end " + envName
+}
+
+func stripHostsBlock(content, envName string) string {
+	begin, end := hostsMarkers(envName)
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == begin {
+			inBlock = true
+			continue
+		}
+		if inBlock {
+			if trimmed == end {
+				inBlock = false
+			}
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+func renderHostsBlock(envName string, hostnames []string) string {
+	begin, end := hostsMarkers(envName)
+	var b strings.Builder
+	b.WriteString(begin + "\n")
+	for _, h := range hostnames {
+		fmt.Fprintf(&b, "%s %s\n", hostsLoopback, h)
+	}
+	b.WriteString(end + "\n")
+	return b.String()
+}
+
+func UpdateHostsFile(file, envName string, hostnames []string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fmt.Errorf("failed to stat hosts file: %w", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	content := strings.TrimRight(stripHostsBlock(string(data), envName), "\n")
+	if len(hostnames) > 0 {
+		if content != "" {
+			content += "\n"
+		}
+		content += renderHostsBlock(envName, hostnames)
+	} else if content != "" {
+		content += "\n"
+	}
+
+	if content == string(data) {
+		return nil
+	}
+
+	if err := replaceHostsFile(file, []byte(content), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write hosts file: %w", err)
+	}
+	return nil
+}
+
+func replaceHostsFile(file string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".mono-*")
+	if err != nil {
+		return err
+	}
+	err = writeHostsTemp(tmp, data, perm)
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		if removeErr := os.Remove(tmp.Name()); removeErr != nil && !os.IsNotExist(removeErr) {
+			return fmt.Errorf("%w (cleanup error: %v)", err, removeErr)
+		}
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
+
+func writeHostsTemp(tmp *os.File, data []byte, perm os.FileMode) error {
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	return tmp.Close()
+}
+
+func RemoveHostsEntries(file, envName string) error {
+	return UpdateHostsFile(file, envName, nil)
+}
+
+func GetEnvHosts(path string) (*Environment, *HostsConfig, []string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.Hosts.ApplyDefaults()
+
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return env, &cfg.Hosts, EnvHostnames(env.EnvName(), cfg.Hosts.Domain, allocations), nil
+}
@@ -0,0 +1,84 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvHostnames(t *testing.T) {
+	allocations := []Allocation{
+		{Service: "web", ContainerPort: 3000, HostPort: 19000},
+		{Service: "api", ContainerPort: 8080, HostPort: 19001},
+		{Service: "api", ContainerPort: 9090, HostPort: 19002},
+	}
+
+	got := EnvHostnames("Proj-Feature_X", "test", allocations)
+	want := []string{"proj-feature-x.test", "api.proj-feature-x.test", "web.proj-feature-x.test"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvHostnames = %v, want %v", got, want)
+	}
+}
+
+func TestUpdateHostsFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hosts")
+	original := "127.0.0.1 localhost\n::1 localhost\n"
+	if err := os.WriteFile(file, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UpdateHostsFile(file, "a", []string{"a.test", "web.a.test"}); err != nil {
+		t.Fatalf("UpdateHostsFile failed: %v", err)
+	}
+	if err := UpdateHostsFile(file, "b", []string{"b.test"}); err != nil {
+		t.Fatalf("UpdateHostsFile failed: %v", err)
+	}
+	if err := UpdateHostsFile(file, "a", []string{"a.test"}); err != nil {
+		t.Fatalf("UpdateHostsFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := original +
+		"# mono:begin b\n127.0.0.1 b.test\n# mono:end b\n" +
+		"# mono:begin a\n127.0.0.1 a.test\n# mono:end a\n"
+	if string(data) != want {
+		t.Errorf("hosts file =\n%s\nwant\n%s", data, want)
+	}
+
+	if err := RemoveHostsEntries(file, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveHostsEntries(file, "b"); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != original {
+		t.Errorf("hosts file after removal =\n%s\nwant\n%s", data, original)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("hosts file mode = %v, want 0640", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the hosts file", len(entries))
+	}
+}
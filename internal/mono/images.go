@@ -0,0 +1,290 @@
+package mono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultImagePullParallel = 4
+	defaultMirrorPort        = 5005
+	defaultMirrorUpstream    = "https://registry-1.docker.io"
+	registryMirrorName       = "mono-registry-mirror"
+	registryMirrorImage      = "registry:2"
+	imagePullTimeout         = 30 * time.Minute
+	registryReadyTimeout     = 30 * time.Second
+)
+
+type RegistryMirrorConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Port     int    `yaml:"port"`
+	Upstream string `yaml:"upstream"`
+}
+
+func (m RegistryMirrorConfig) Host() string {
+	return fmt.Sprintf("127.0.0.1:%d", m.Port)
+}
+
+type ImagesConfig struct {
+	Prefetch *bool                `yaml:"prefetch"`
+	Parallel int                  `yaml:"parallel"`
+	Mirror   RegistryMirrorConfig `yaml:"mirror"`
+}
+
+func (ic *ImagesConfig) ApplyDefaults() {
+	if ic.Parallel == 0 {
+		ic.Parallel = defaultImagePullParallel
+	}
+	if ic.Mirror.Port == 0 {
+		ic.Mirror.Port = defaultMirrorPort
+	}
+	if ic.Mirror.Upstream == "" {
+		ic.Mirror.Upstream = defaultMirrorUpstream
+	}
+}
+
+func (ic ImagesConfig) Validate() error {
+	if ic.Parallel < 0 {
+		return fmt.Errorf("invalid images.parallel %d: must be positive", ic.Parallel)
+	}
+	if ic.Mirror.Port < 0 || ic.Mirror.Port > MaxPort {
+		return fmt.Errorf("invalid images.mirror.port %d", ic.Mirror.Port)
+	}
+	if ic.Mirror.Upstream != "" && !strings.HasPrefix(ic.Mirror.Upstream, "https://") && !strings.HasPrefix(ic.Mirror.Upstream, "http://") {
+		return fmt.Errorf("invalid images.mirror.upstream %q: expected a registry URL", ic.Mirror.Upstream)
+	}
+	return nil
+}
+
+func (ic ImagesConfig) PrefetchEnabled() bool {
+	return ic.Prefetch == nil || *ic.Prefetch
+}
+
+func ProjectImages(project *types.Project) []string {
+	var images []string
+	for _, name := range project.ServiceNames() {
+		svc := project.Services[name]
+		if svc.Image == "" || svc.Build != nil || slices.Contains(images, svc.Image) {
+			continue
+		}
+		images = append(images, svc.Image)
+	}
+	slices.Sort(images)
+	return images
+}
+
+func dockerHubPath(image string) (string, bool) {
+	first, rest, found := strings.Cut(image, "/")
+	if !found {
+		return "library/" + image, true
+	}
+	switch {
+	case first == "docker.io" || first == "index.docker.io":
+		if !strings.Contains(rest, "/") {
+			return "library/" + rest, true
+		}
+		return rest, true
+	case strings.ContainsAny(first, ".:") || first == "localhost":
+		return "", false
+	}
+	return image, true
+}
+
+type ImagePull struct {
+	Image   string
+	Present bool
+	Mirror  bool
+	Elapsed time.Duration
+	Err     error
+}
+
+func PrefetchImages(containers ContainerRuntime, images []string, cfg ImagesConfig, force bool, progress io.Writer) ([]ImagePull, error) {
+	if cfg.Mirror.Enabled {
+		if err := containers.EnsureRegistryMirror(cfg.Mirror); err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), registryReadyTimeout)
+		defer cancel()
+		if err := waitForRegistry(ctx, cfg.Mirror); err != nil {
+			return nil, err
+		}
+	}
+
+	var mu sync.Mutex
+	report := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(progress, format+"\n", args...)
+	}
+
+	pulls := make([]ImagePull, len(images))
+	var g errgroup.Group
+	g.SetLimit(max(cfg.Parallel, 1))
+	for i, image := range images {
+		g.Go(func() error {
+			pulls[i].Image = image
+			if !force {
+				exists, err := containers.ImageExists(image)
+				if err != nil {
+					pulls[i].Err = err
+					return nil
+				}
+				if exists {
+					pulls[i].Present = true
+					report("  %s: already present", image)
+					return nil
+				}
+			}
+
+			start := time.Now()
+			mirrored, err := pullImage(containers, image, cfg.Mirror, report)
+			if err != nil {
+				pulls[i].Err = err
+				report("  %s: failed", image)
+				return nil
+			}
+			pulls[i].Mirror = mirrored
+			pulls[i].Elapsed = time.Since(start).Round(100 * time.Millisecond)
+			via := ""
+			if mirrored {
+				via = " via " + cfg.Mirror.Host()
+			}
+			report("  %s: pulled in %s%s", image, pulls[i].Elapsed, via)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, p := range pulls {
+		errs = append(errs, p.Err)
+	}
+	return pulls, errors.Join(errs...)
+}
+
+func pullImage(containers ContainerRuntime, image string, mirror RegistryMirrorConfig, report func(string, ...any)) (bool, error) {
+	if path, ok := dockerHubPath(image); ok && mirror.Enabled {
+		mirrored := mirror.Host() + "/" + path
+		err := containers.PullImage(mirrored)
+		if err == nil {
+			if err := containers.TagImage(mirrored, image); err != nil {
+				return false, err
+			}
+			return true, containers.RemoveImage(mirrored)
+		}
+		report("  %s: mirror pull failed, pulling directly: %v", image, err)
+	}
+	return false, containers.PullImage(image)
+}
+
+func waitForRegistry(ctx context.Context, mirror RegistryMirrorConfig) error {
+	url := "http://" + mirror.Host() + "/v2/"
+	for {
+		err := checkHTTP(ctx, url)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("registry mirror on %s is not answering: %w", mirror.Host(), err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func imageExists(binary, image string) (bool, error) {
+	result, err := Command(binary, "image", "inspect", "--format", "{{.Id}}", image).RunCapture()
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+	return result.ExitCode == 0, nil
+}
+
+func pullImageWith(binary, image string, extra ...string) error {
+	args := append([]string{"pull", "--quiet"}, extra...)
+	output, err := Command(binary, append(args, image)...).
+		Timeout(imagePullTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func tagImage(binary, source, target string) error {
+	output, err := Command(binary, "tag", source, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to tag %s as %s: %w: %s", source, target, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func removeImage(binary, image string) error {
+	output, err := Command(binary, "rmi", image).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to remove image tag %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func ensureRegistryMirror(binary string, mirror RegistryMirrorConfig) error {
+	result, err := Command(binary, "inspect", "--format", "{{.State.Running}}", registryMirrorName).RunCapture()
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", registryMirrorName, err)
+	}
+	if result.ExitCode == 0 {
+		if strings.TrimSpace(string(result.Stdout)) == "true" {
+			return nil
+		}
+		output, err := Command(binary, "start", registryMirrorName).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to start %s: %w: %s", registryMirrorName, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	output, err := Command(binary, "run", "-d",
+		"--name", registryMirrorName,
+		"--restart", "unless-stopped",
+		"-p", mirror.Host()+":5000",
+		"-v", registryMirrorName+":/var/lib/registry",
+		"-e", "REGISTRY_PROXY_REMOTEURL="+mirror.Upstream,
+		registryMirrorImage).
+		Timeout(imagePullTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to run registry mirror: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func PullEnvImages(path string, force bool, progress io.Writer) ([]ImagePull, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	if ec.Project == nil {
+		return nil, fmt.Errorf("environment %s has no compose services", ec.Env.EnvName())
+	}
+	containers, err := ec.Env.Runtime()
+	if err != nil {
+		return nil, err
+	}
+	if err := EnsureRuntimeAvailable(containers, ec.Config.ContainerAutostart, progress); err != nil {
+		return nil, err
+	}
+
+	images := ProjectImages(ec.Project)
+	fmt.Fprintf(progress, "Pulling %d image(s) for %s...\n", len(images), ec.Env.EnvName())
+	return PrefetchImages(containers, images, ec.Config.Images, force, progress)
+}
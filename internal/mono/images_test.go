@@ -0,0 +1,108 @@
+package mono
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestDockerHubPath(t *testing.T) {
+	tests := []struct {
+		image string
+		path  string
+		hub   bool
+	}{
+		{"postgres:16", "library/postgres:16", true},
+		{"bitnami/redis", "bitnami/redis", true},
+		{"docker.io/nginx", "library/nginx", true},
+		{"docker.io/grafana/grafana:11", "grafana/grafana:11", true},
+		{"ghcr.io/org/app:1", "", false},
+		{"localhost:5000/app", "", false},
+	}
+	for _, tt := range tests {
+		path, hub := dockerHubPath(tt.image)
+		if path != tt.path || hub != tt.hub {
+			t.Errorf("dockerHubPath(%q) = %q, %v, want %q, %v", tt.image, path, hub, tt.path, tt.hub)
+		}
+	}
+}
+
+func TestPrefetchImages(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer registry.Close()
+	u, err := url.Parse(registry.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$*" in
+"image inspect --format {{.Id}} redis:7") echo sha256:abc ;;
+"image inspect"*) exit 1 ;;
+"inspect --format {{.State.Running}} mono-registry-mirror") echo true ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	project := &types.Project{Services: types.Services{
+		"db":    {Name: "db", Image: "postgres:16"},
+		"cache": {Name: "cache", Image: "redis:7"},
+		"app":   {Name: "app", Image: "ghcr.io/org/app:1"},
+		"api":   {Name: "api", Image: "api:dev", Build: &types.BuildConfig{Context: "."}},
+		"queue": {Name: "queue", Image: "redis:7"},
+	}}
+	images := ProjectImages(project)
+	if strings.Join(images, " ") != "ghcr.io/org/app:1 postgres:16 redis:7" {
+		t.Fatalf("ProjectImages = %v", images)
+	}
+
+	cfg := ImagesConfig{Parallel: 1, Mirror: RegistryMirrorConfig{Enabled: true, Port: port}}
+	cfg.ApplyDefaults()
+	var progress strings.Builder
+	pulls, err := PrefetchImages(dockerRuntime{}, images, cfg, false, &progress)
+	if err != nil {
+		t.Fatalf("PrefetchImages failed: %v", err)
+	}
+	if len(pulls) != 3 || pulls[0].Mirror || !pulls[1].Mirror || !pulls[2].Present {
+		t.Errorf("pulls = %+v", pulls)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirror := "127.0.0.1:" + u.Port() + "/library/postgres:16"
+	want := []string{
+		"inspect --format {{.State.Running}} mono-registry-mirror",
+		"image inspect --format {{.Id}} ghcr.io/org/app:1",
+		"pull --quiet ghcr.io/org/app:1",
+		"image inspect --format {{.Id}} postgres:16",
+		"pull --quiet " + mirror,
+		"tag " + mirror + " postgres:16",
+		"rmi " + mirror,
+		"image inspect --format {{.Id}} redis:7",
+	}
+	if got := strings.TrimSpace(string(data)); got != strings.Join(want, "\n") {
+		t.Errorf("docker calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+	if !strings.Contains(progress.String(), "redis:7: already present") {
+		t.Errorf("progress = %q", progress.String())
+	}
+}
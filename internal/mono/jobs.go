@@ -0,0 +1,379 @@
+package mono
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+
+	jobsDir         = "jobs"
+	jobCancelGrace  = 10 * time.Second
+	maxFinishedJobs = 50
+)
+
+var (
+	ErrJobNotFound   = errors.New("job not found")
+	ErrJobNotRunning = errors.New("job is not running")
+)
+
+type JobRequest struct {
+	Args []string `json:"args"`
+	Dir  string   `json:"dir"`
+	Env  []string `json:"env,omitempty"`
+}
+
+type Job struct {
+	ID       string    `json:"id"`
+	Args     []string  `json:"args"`
+	Dir      string    `json:"dir"`
+	Status   string    `json:"status"`
+	ExitCode int       `json:"exit_code"`
+	Error    string    `json:"error,omitempty"`
+	Progress string    `json:"progress"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitzero"`
+	Log      string    `json:"log"`
+}
+
+func (j Job) Command() string {
+	return "mono " + strings.Join(j.Args, " ")
+}
+
+func (j Job) Elapsed() time.Duration {
+	if j.Finished.IsZero() {
+		return time.Since(j.Started)
+	}
+	return j.Finished.Sub(j.Started)
+}
+
+func (req JobRequest) Validate() error {
+	if len(req.Args) == 0 {
+		return fmt.Errorf("a job needs a mono command to run")
+	}
+	switch req.Args[0] {
+	case "jobs", "daemon":
+		return fmt.Errorf("mono %s can't run as a job", req.Args[0])
+	}
+	if !filepath.IsAbs(req.Dir) {
+		return fmt.Errorf("job directory must be absolute, got %q", req.Dir)
+	}
+	for _, kv := range req.Env {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("invalid job environment variable %q", kv)
+		}
+	}
+	return nil
+}
+
+type progressWriter struct {
+	mu   sync.Mutex
+	out  io.Writer
+	last string
+	line []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexAny(w.line, "\r\n")
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(w.line[:i])); line != "" {
+			w.last = line
+		}
+		w.line = w.line[i+1:]
+	}
+	return w.out.Write(p)
+}
+
+func (w *progressWriter) Progress() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if line := strings.TrimSpace(string(w.line)); line != "" {
+		return line
+	}
+	return w.last
+}
+
+type runningJob struct {
+	job       Job
+	cmd       *exec.Cmd
+	output    *progressWriter
+	done      chan struct{}
+	cancelled bool
+}
+
+type jobManager struct {
+	mu     sync.Mutex
+	exe    string
+	dir    string
+	jobs   []*runningJob
+	logger *FileLogger
+}
+
+func newJobManager(exe string, logger *FileLogger) (*jobManager, error) {
+	home, err := GetMonoHome()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return &jobManager{exe: exe, dir: filepath.Join(home, jobsDir), logger: logger}, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (m *jobManager) start(req JobRequest) (Job, error) {
+	if err := req.Validate(); err != nil {
+		return Job{}, err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return Job{}, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	logPath := filepath.Join(m.dir, id+".log")
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return Job{}, fmt.Errorf("failed to create job log: %w", err)
+	}
+
+	output := &progressWriter{out: logFile}
+	cmd := exec.Command(m.exe, req.Args...)
+	cmd.Dir = req.Dir
+	cmd.Env = append(os.Environ(), req.Env...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return Job{}, fmt.Errorf("failed to start mono %s: %w", strings.Join(req.Args, " "), err)
+	}
+
+	rj := &runningJob{
+		job:    Job{ID: id, Args: req.Args, Dir: req.Dir, Status: JobRunning, Started: time.Now(), Log: logPath},
+		cmd:    cmd,
+		output: output,
+		done:   make(chan struct{}),
+	}
+	m.mu.Lock()
+	m.jobs = append(m.jobs, rj)
+	m.mu.Unlock()
+	m.logger.Log("job %s started: %s", id, rj.job.Command())
+
+	go m.wait(rj, logFile)
+	return m.snapshot(rj), nil
+}
+
+func (m *jobManager) wait(rj *runningJob, logFile *os.File) {
+	err := rj.cmd.Wait()
+	closeErr := logFile.Close()
+
+	m.mu.Lock()
+	rj.job.Finished = time.Now()
+	rj.job.ExitCode = rj.cmd.ProcessState.ExitCode()
+	var exitErr *exec.ExitError
+	switch {
+	case rj.cancelled:
+		rj.job.Status = JobCancelled
+	case err == nil:
+		rj.job.Status = JobSucceeded
+	default:
+		rj.job.Status = JobFailed
+		if !errors.As(err, &exitErr) {
+			rj.job.Error = err.Error()
+		}
+	}
+	job := rj.job
+	m.mu.Unlock()
+	close(rj.done)
+
+	if closeErr != nil {
+		m.logger.Log("warning: failed to close log of job %s: %v", job.ID, closeErr)
+	}
+	m.logger.Log("job %s %s after %s (exit code %d)", job.ID, job.Status, job.Elapsed().Round(time.Second), job.ExitCode)
+	if err := m.pruneFinished(); err != nil {
+		m.logger.Log("warning: %v", err)
+	}
+}
+
+func (m *jobManager) snapshot(rj *runningJob) Job {
+	m.mu.Lock()
+	job := rj.job
+	m.mu.Unlock()
+	job.Progress = rj.output.Progress()
+	return job
+}
+
+func (m *jobManager) find(id string) (*runningJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rj := range m.jobs {
+		if rj.job.ID == id {
+			return rj, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+func (m *jobManager) list() []Job {
+	m.mu.Lock()
+	jobs := slices.Clone(m.jobs)
+	m.mu.Unlock()
+	result := make([]Job, 0, len(jobs))
+	for _, rj := range jobs {
+		result = append(result, m.snapshot(rj))
+	}
+	return result
+}
+
+func (m *jobManager) cancel(id string) (Job, error) {
+	rj, err := m.find(id)
+	if err != nil {
+		return Job{}, err
+	}
+	m.mu.Lock()
+	if rj.job.Status != JobRunning {
+		m.mu.Unlock()
+		return Job{}, fmt.Errorf("%w: %s is %s", ErrJobNotRunning, id, rj.job.Status)
+	}
+	rj.cancelled = true
+	m.mu.Unlock()
+
+	pgid := -rj.cmd.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGINT); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return Job{}, fmt.Errorf("failed to interrupt job %s: %w", id, err)
+	}
+	m.logger.Log("job %s cancelled", id)
+	go func() {
+		select {
+		case <-rj.done:
+		case <-time.After(jobCancelGrace):
+			if err := syscall.Kill(pgid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+				m.logger.Log("warning: failed to kill job %s: %v", id, err)
+			}
+		}
+	}()
+	return m.snapshot(rj), nil
+}
+
+func (m *jobManager) pruneFinished() error {
+	m.mu.Lock()
+	var finished []*runningJob
+	for _, rj := range m.jobs {
+		if rj.job.Status != JobRunning {
+			finished = append(finished, rj)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		m.mu.Unlock()
+		return nil
+	}
+	stale := finished[:len(finished)-maxFinishedJobs]
+	m.jobs = slices.DeleteFunc(m.jobs, func(rj *runningJob) bool { return slices.Contains(stale, rj) })
+	m.mu.Unlock()
+
+	for _, rj := range stale {
+		if err := os.Remove(rj.job.Log); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log of job %s: %w", rj.job.ID, err)
+		}
+	}
+	return nil
+}
+
+func (m *jobManager) shutdown() {
+	for _, job := range m.list() {
+		if job.Status != JobRunning {
+			continue
+		}
+		if _, err := m.cancel(job.ID); err != nil {
+			m.logger.Log("warning: %v", err)
+		}
+	}
+	m.mu.Lock()
+	jobs := slices.Clone(m.jobs)
+	m.mu.Unlock()
+	for _, rj := range jobs {
+		select {
+		case <-rj.done:
+		case <-time.After(jobCancelGrace):
+			m.logger.Log("warning: job %s did not stop within %s", rj.job.ID, jobCancelGrace)
+		}
+	}
+}
+
+func (m *jobManager) openLog(id string) (*runningJob, *os.File, error) {
+	rj, err := m.find(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(rj.job.Log)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log of job %s: %w", id, err)
+	}
+	return rj, f, nil
+}
+
+func followLog(w http.ResponseWriter, r *http.Request, rj *runningJob, f *os.File, follow bool) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	for {
+		if _, err := io.Copy(w, f); err != nil {
+			return fmt.Errorf("failed to stream log of job %s: %w", rj.job.ID, err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if !follow {
+			return nil
+		}
+		select {
+		case <-rj.done:
+			if _, err := io.Copy(w, f); err != nil {
+				return fmt.Errorf("failed to stream log of job %s: %w", rj.job.ID, err)
+			}
+			return nil
+		case <-r.Context().Done():
+			return nil
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func writeJobError(w http.ResponseWriter, logger *FileLogger, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrJobNotRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeDaemonError(w, logger, err)
+	}
+}
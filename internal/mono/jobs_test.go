@@ -0,0 +1,173 @@
+package mono
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startJobServer(t *testing.T) *DaemonClient {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+
+	logger, err := NewFileLogger("jobs-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Close() })
+	jobs, err := newJobManager("sh", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(jobs.shutdown)
+
+	dir, err := os.MkdirTemp("", "mono-jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "mono.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: newDaemonHandler(logger, jobs)}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := NewDaemonClient(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestJobsRunDetached(t *testing.T) {
+	client := startJobServer(t)
+	dir := t.TempDir()
+
+	job, err := client.StartJob(JobRequest{Args: []string{"-c", `echo "building $TARGET in $(pwd)"; echo done`}, Dir: dir, Env: []string{"TARGET=api"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobRunning {
+		t.Errorf("status = %s, want %s", job.Status, JobRunning)
+	}
+
+	var log strings.Builder
+	if err := client.StreamJobLog(job.ID, true, &log); err != nil {
+		t.Fatal(err)
+	}
+	if want := "building api in " + dir + "\ndone\n"; log.String() != want {
+		t.Errorf("log = %q, want %q", log.String(), want)
+	}
+
+	job, err = client.Job(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobSucceeded || job.ExitCode != 0 || job.Progress != "done" {
+		t.Errorf("job = %+v, want succeeded with progress done", job)
+	}
+
+	jobs, err := client.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("jobs = %+v, want the finished job", jobs)
+	}
+}
+
+func TestJobsCancel(t *testing.T) {
+	client := startJobServer(t)
+
+	job, err := client.StartJob(JobRequest{Args: []string{"-c", "echo started; sleep 30"}, Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CancelJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var log strings.Builder
+	done := make(chan error, 1)
+	go func() { done <- client.StreamJobLog(job.ID, true, &log) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled job is still running")
+	}
+
+	job, err = client.Job(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != JobCancelled {
+		t.Errorf("status = %s, want %s", job.Status, JobCancelled)
+	}
+	if _, err := client.CancelJob(job.ID); err == nil || !strings.Contains(err.Error(), "not running") {
+		t.Errorf("CancelJob() on a finished job error = %v, want not running", err)
+	}
+	if _, err := client.Job("missing"); err == nil || !strings.Contains(err.Error(), "job not found") {
+		t.Errorf("Job() on an unknown id error = %v, want job not found", err)
+	}
+}
+
+func TestJobRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     JobRequest
+		wantErr string
+	}{
+		{name: "valid", req: JobRequest{Args: []string{"task", "build"}, Dir: "/repo"}},
+		{name: "no command", req: JobRequest{Dir: "/repo"}, wantErr: "needs a mono command"},
+		{name: "nested jobs", req: JobRequest{Args: []string{"jobs", "start"}, Dir: "/repo"}, wantErr: "can't run as a job"},
+		{name: "relative dir", req: JobRequest{Args: []string{"sync"}, Dir: "repo"}, wantErr: "must be absolute"},
+		{name: "bad env", req: JobRequest{Args: []string{"sync"}, Dir: "/repo", Env: []string{"TARGET"}}, wantErr: "invalid job environment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProgressWriterTracksLastLine(t *testing.T) {
+	var out strings.Builder
+	w := &progressWriter{out: &out}
+	for _, chunk := range []string{"Compiling api\n", "Building [==>  ] 3/10\r", "Building [====>] 7/10\r", "Buil"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := w.Progress(); got != "Buil" {
+		t.Errorf("Progress() = %q, want the unterminated line", got)
+	}
+	if _, err := w.Write([]byte("d finished\n\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Progress(); got != "Build finished" {
+		t.Errorf("Progress() = %q, want the last non-empty line", got)
+	}
+	if !strings.HasPrefix(out.String(), "Compiling api\n") {
+		t.Errorf("output = %q, want everything written through", out.String())
+	}
+}
@@ -0,0 +1,333 @@
+package mono
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KubernetesCluster   = "cluster"
+	KubernetesNamespace = "namespace"
+
+	defaultKindCluster = "mono"
+	minNodePort        = 30000
+	maxNodePort        = 32767
+	kindTimeout        = 10 * time.Minute
+	kubectlTimeout     = 5 * time.Minute
+)
+
+type KubernetesConfig struct {
+	Enabled   bool           `yaml:"enabled"`
+	Mode      string         `yaml:"mode"`
+	Cluster   string         `yaml:"cluster"`
+	Manifests []string       `yaml:"manifests"`
+	Ports     map[string]int `yaml:"ports"`
+}
+
+func (kc *KubernetesConfig) ApplyDefaults() {
+	if kc.Mode == "" {
+		kc.Mode = KubernetesCluster
+	}
+	if kc.Cluster == "" {
+		kc.Cluster = defaultKindCluster
+	}
+}
+
+func (kc KubernetesConfig) Validate() error {
+	if !kc.Enabled {
+		return nil
+	}
+	switch kc.Mode {
+	case "", KubernetesCluster, KubernetesNamespace:
+	default:
+		return fmt.Errorf("invalid kubernetes.mode %q (expected %s or %s)", kc.Mode, KubernetesCluster, KubernetesNamespace)
+	}
+	if len(kc.Manifests) == 0 {
+		return fmt.Errorf("kubernetes.enabled is set but kubernetes.manifests is empty")
+	}
+	for _, m := range kc.Manifests {
+		clean := filepath.Clean(m)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid kubernetes manifest %q: must be inside the environment", m)
+		}
+	}
+	if len(kc.Ports) > 0 && kc.Mode == KubernetesNamespace {
+		return fmt.Errorf("kubernetes.ports needs mode: %s, a shared cluster can't publish per-environment ports", KubernetesCluster)
+	}
+	for _, name := range sortedKeys(kc.Ports) {
+		if p := kc.Ports[name]; p < minNodePort || p > maxNodePort {
+			return fmt.Errorf("invalid kubernetes.ports.%s %d: must be a node port between %d and %d", name, p, minNodePort, maxNodePort)
+		}
+	}
+	return nil
+}
+
+func (kc KubernetesConfig) CheckInstalled() error {
+	if !kc.Enabled {
+		return nil
+	}
+	for _, b := range []string{"kind", "kubectl"} {
+		if _, err := exec.LookPath(b); err != nil {
+			return fmt.Errorf("kubernetes.enabled is set but %s is not installed", b)
+		}
+	}
+	return nil
+}
+
+func (kc KubernetesConfig) servicePorts() map[string][]PortRequest {
+	result := make(map[string][]PortRequest)
+	if !kc.Enabled {
+		return result
+	}
+	for name, port := range kc.Ports {
+		result[name] = []PortRequest{{Port: port, Count: 1, Protocol: ProtocolTCP}}
+	}
+	return result
+}
+
+func monoServicePorts(services []ServiceConfig, kc KubernetesConfig) (map[string][]PortRequest, error) {
+	ports := processServicePorts(services)
+	for name, requests := range kc.servicePorts() {
+		if _, ok := ports[name]; ok {
+			return nil, fmt.Errorf("kubernetes.ports.%s clashes with the service of the same name", name)
+		}
+		ports[name] = requests
+	}
+	return ports, nil
+}
+
+type kubeTarget struct {
+	Cluster   string
+	Namespace string
+	Owned     bool
+}
+
+func (t kubeTarget) Context() string {
+	return "kind-" + t.Cluster
+}
+
+var invalidKubeName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func kubeName(s string, limit int) string {
+	name := invalidKubeName.ReplaceAllString(strings.ToLower(s), "-")
+	if len(name) > limit {
+		name = name[:limit]
+	}
+	return strings.Trim(name, "-")
+}
+
+func (kc KubernetesConfig) target(envName string) kubeTarget {
+	namespace := kubeName("mono-"+envName, 63)
+	if kc.Mode == KubernetesNamespace {
+		return kubeTarget{Cluster: kc.Cluster, Namespace: namespace}
+	}
+	return kubeTarget{Cluster: kubeName("mono-"+envName, 50), Namespace: namespace, Owned: true}
+}
+
+func (kc KubernetesConfig) EnvVars(envName string) []string {
+	if !kc.Enabled {
+		return nil
+	}
+	t := kc.target(envName)
+	return []string{
+		"MONO_K8S_CONTEXT=" + t.Context(),
+		"MONO_K8S_NAMESPACE=" + t.Namespace,
+	}
+}
+
+var manifestVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+func renderManifests(kc KubernetesConfig, envPath string, vars []string) ([]byte, error) {
+	lookup := make(map[string]string, len(vars))
+	for _, kv := range vars {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			lookup[k] = v
+		}
+	}
+
+	var files []string
+	for _, m := range kc.Manifests {
+		path := filepath.Join(envPath, m)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes manifest: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes manifests in %s: %w", m, err)
+		}
+		for _, e := range entries {
+			if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes manifest: %w", err)
+		}
+		rendered := manifestVar.ReplaceAllFunc(data, func(match []byte) []byte {
+			if v, ok := lookup[string(match[2:len(match)-1])]; ok {
+				return []byte(v)
+			}
+			return match
+		})
+		b.WriteString("---\n")
+		b.Write(rendered)
+		if !bytes.HasSuffix(rendered, []byte("\n")) {
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes(), nil
+}
+
+type kindPortMapping struct {
+	ContainerPort int    `yaml:"containerPort"`
+	HostPort      int    `yaml:"hostPort"`
+	ListenAddress string `yaml:"listenAddress"`
+	Protocol      string `yaml:"protocol"`
+}
+
+type kindNode struct {
+	Role              string            `yaml:"role"`
+	ExtraPortMappings []kindPortMapping `yaml:"extraPortMappings,omitempty"`
+}
+
+type kindClusterConfig struct {
+	Kind       string     `yaml:"kind"`
+	APIVersion string     `yaml:"apiVersion"`
+	Nodes      []kindNode `yaml:"nodes"`
+}
+
+func kindConfig(kc KubernetesConfig, allocations []Allocation) ([]byte, error) {
+	node := kindNode{Role: "control-plane"}
+	for _, name := range sortedKeys(kc.Ports) {
+		hostPort := allocatedPort(name, kc.Ports[name], allocations)
+		if hostPort == 0 {
+			return nil, fmt.Errorf("no port allocated for kubernetes service %s", name)
+		}
+		node.ExtraPortMappings = append(node.ExtraPortMappings, kindPortMapping{
+			ContainerPort: kc.Ports[name],
+			HostPort:      hostPort,
+			ListenAddress: "127.0.0.1",
+			Protocol:      "TCP",
+		})
+	}
+	return yaml.Marshal(kindClusterConfig{Kind: "Cluster", APIVersion: "kind.x-k8s.io/v1alpha4", Nodes: []kindNode{node}})
+}
+
+func kindClusterExists(name string) (bool, error) {
+	output, err := Command("kind", "get", "clusters").Timeout(kubectlTimeout).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list kind clusters: %w", err)
+	}
+	return slices.Contains(strings.Fields(string(output)), name), nil
+}
+
+func kubectl(t kubeTarget, stdin []byte, args ...string) error {
+	var out bytes.Buffer
+	cmd := Command("kubectl", append([]string{"--context", t.Context()}, args...)...).
+		Stdout(&out).
+		Stderr(&out).
+		Timeout(kubectlTimeout)
+	if stdin != nil {
+		cmd = cmd.Stdin(bytes.NewReader(stdin))
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kubectl %s: %w: %s", args[0], err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+func ProvisionKubernetes(kc KubernetesConfig, envName, envPath, dataDir string, allocations []Allocation, vars []string, logger *FileLogger) error {
+	t := kc.target(envName)
+	manifests, err := renderManifests(kc, envPath, vars)
+	if err != nil {
+		return err
+	}
+
+	exists, err := kindClusterExists(t.Cluster)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		args := []string{"create", "cluster", "--name", t.Cluster, "--wait", "2m"}
+		if t.Owned {
+			config, err := kindConfig(kc, allocations)
+			if err != nil {
+				return err
+			}
+			configPath := filepath.Join(dataDir, "kind.yaml")
+			if err := os.WriteFile(configPath, config, 0644); err != nil {
+				return fmt.Errorf("failed to write kind config: %w", err)
+			}
+			args = append(args, "--config", configPath)
+		}
+		logger.Log("creating kind cluster %s", t.Cluster)
+		output, err := Command("kind", args...).Timeout(kindTimeout).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to create kind cluster %s: %w: %s", t.Cluster, err, strings.TrimSpace(string(output)))
+		}
+	} else if t.Owned {
+		logger.Log("reusing kind cluster %s", t.Cluster)
+	}
+
+	namespace, err := yaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]string{"name": t.Namespace},
+	})
+	if err != nil {
+		return err
+	}
+	if err := kubectl(t, namespace, "apply", "-f", "-"); err != nil {
+		return fmt.Errorf("failed to create namespace %s: %w", t.Namespace, err)
+	}
+	if err := kubectl(t, manifests, "apply", "--namespace", t.Namespace, "-f", "-"); err != nil {
+		return fmt.Errorf("failed to apply kubernetes manifests: %w", err)
+	}
+	logger.Log("applied kubernetes manifests to %s/%s", t.Context(), t.Namespace)
+	return nil
+}
+
+func TeardownKubernetes(kc KubernetesConfig, envName string, logger *FileLogger) error {
+	t := kc.target(envName)
+	exists, err := kindClusterExists(t.Cluster)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	if t.Owned {
+		output, err := Command("kind", "delete", "cluster", "--name", t.Cluster).Timeout(kindTimeout).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to delete kind cluster %s: %w: %s", t.Cluster, err, strings.TrimSpace(string(output)))
+		}
+		logger.Log("deleted kind cluster %s", t.Cluster)
+		return nil
+	}
+
+	if err := kubectl(t, nil, "delete", "namespace", t.Namespace, "--ignore-not-found", "--wait=true"); err != nil {
+		return fmt.Errorf("failed to delete namespace %s: %w", t.Namespace, err)
+	}
+	logger.Log("deleted namespace %s from kind cluster %s", t.Namespace, t.Cluster)
+	return nil
+}
@@ -0,0 +1,105 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKubernetesLifecycle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	applied := filepath.Join(bin, "applied")
+	clusters := filepath.Join(bin, "clusters")
+	kind := `#!/bin/sh
+echo "kind $*" >> ` + calls + `
+case "$1" in
+get) cat ` + clusters + ` 2>/dev/null || true ;;
+create) echo "$4" >> ` + clusters + ` ;;
+esac
+`
+	kubectl := `#!/bin/sh
+echo "kubectl $*" >> ` + calls + `
+case "$*" in
+*"apply --namespace"*) cat > ` + applied + ` ;;
+*apply*) cat > /dev/null ;;
+esac
+`
+	for name, script := range map[string]string{"kind": kind, "kubectl": kubectl} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	envPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(envPath, "k8s"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "kind: Service\nmetadata:\n  name: api-${MONO_ENV_NAME}\nspec:\n  ports:\n    - port: ${PORT_API}\n  command: echo ${UNSET}\n"
+	if err := os.WriteFile(filepath.Join(envPath, "k8s", "api.yaml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(envPath, "k8s", "README.md"), []byte("not a manifest"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := NewFileLogger("kubernetes-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	kc := KubernetesConfig{Enabled: true, Manifests: []string{"k8s"}, Ports: map[string]int{"api": 30080}}
+	kc.ApplyDefaults()
+	if err := kc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	allocations := []Allocation{{Service: "api", ContainerPort: 30080, HostPort: 19080, Protocol: ProtocolTCP}}
+	vars := append([]string{"MONO_ENV_NAME=proj-Feature_1"}, PortEnvVars(allocations)...)
+	dataDir := t.TempDir()
+	if err := ProvisionKubernetes(kc, "proj-Feature_1", envPath, dataDir, allocations, vars, logger); err != nil {
+		t.Fatalf("ProvisionKubernetes failed: %v", err)
+	}
+	if err := TeardownKubernetes(kc, "proj-Feature_1", logger); err != nil {
+		t.Fatalf("TeardownKubernetes failed: %v", err)
+	}
+
+	data, err := os.ReadFile(applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "---\n" + strings.NewReplacer("${MONO_ENV_NAME}", "proj-Feature_1", "${PORT_API}", "19080").Replace(manifest); string(data) != want {
+		t.Errorf("applied manifests:\n%s\nwant:\n%s", data, want)
+	}
+	config, err := os.ReadFile(filepath.Join(dataDir, "kind.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(config), "containerPort: 30080") || !strings.Contains(string(config), "hostPort: 19080") {
+		t.Errorf("kind config does not map the allocated port:\n%s", config)
+	}
+
+	data, err = os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"kind get clusters",
+		"kind create cluster --name mono-proj-feature-1 --wait 2m --config " + filepath.Join(dataDir, "kind.yaml"),
+		"kubectl --context kind-mono-proj-feature-1 apply -f -",
+		"kubectl --context kind-mono-proj-feature-1 apply --namespace mono-proj-feature-1 -f -",
+		"kind get clusters",
+		"kind delete cluster --name mono-proj-feature-1",
+	}
+	if got := strings.TrimSpace(string(data)); got != strings.Join(want, "\n") {
+		t.Errorf("calls:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	shared := KubernetesConfig{Enabled: true, Mode: KubernetesNamespace, Manifests: []string{"k8s"}, Ports: map[string]int{"api": 30080}}
+	if err := shared.Validate(); err == nil {
+		t.Error("expected ports to be rejected in namespace mode")
+	}
+}
@@ -0,0 +1,261 @@
+package mono
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const labelsSchema = `
+CREATE TABLE IF NOT EXISTS environment_labels (
+    env_id INTEGER NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (env_id, key)
+);
+`
+
+var validLabelKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+func ParseLabel(s string) (string, string, error) {
+	key, value, _ := strings.Cut(strings.TrimSpace(s), "=")
+	if !validLabelKey.MatchString(key) {
+		return "", "", fmt.Errorf("invalid label %q: keys use letters, digits, '.', '_', '/' and '-'", s)
+	}
+	return key, value, nil
+}
+
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if labels[k] == "" {
+			parts = append(parts, k)
+		} else {
+			parts = append(parts, k+"="+labels[k])
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+type labelRequirement struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+type LabelSelector []labelRequirement
+
+func ParseLabelSelector(selectors []string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, s := range selectors {
+		for _, part := range strings.Split(s, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			key, value, err := ParseLabel(part)
+			if err != nil {
+				return nil, err
+			}
+			sel = append(sel, labelRequirement{key: key, value: value, hasValue: strings.Contains(part, "=")})
+		}
+	}
+	return sel, nil
+}
+
+func (sel LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range sel {
+		value, ok := labels[req.key]
+		if !ok || (req.hasValue && value != req.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q: expected e.g. 7d or 12h", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q: expected e.g. 7d or 12h", s)
+	}
+	return d, nil
+}
+
+func (db *DB) SetLabel(envID int64, key, value string) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO environment_labels (env_id, key, value) VALUES (?, ?, ?)
+		 ON CONFLICT(env_id, key) DO UPDATE SET value = excluded.value`,
+		envID, key, value,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set label %s: %w", key, err)
+	}
+	return nil
+}
+
+func (db *DB) RemoveLabel(envID int64, key string) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM environment_labels WHERE env_id = ? AND key = ?`, envID, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove label %s: %w", key, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (db *DB) GetLabels(envID int64) (map[string]string, error) {
+	all, err := db.queryLabels(`SELECT env_id, key, value FROM environment_labels WHERE env_id = ?`, envID)
+	if err != nil {
+		return nil, err
+	}
+	if labels, ok := all[envID]; ok {
+		return labels, nil
+	}
+	return map[string]string{}, nil
+}
+
+func (db *DB) GetAllLabels() (map[int64]map[string]string, error) {
+	return db.queryLabels(`SELECT env_id, key, value FROM environment_labels`)
+}
+
+func (db *DB) queryLabels(query string, args ...any) (map[int64]map[string]string, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get labels: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]map[string]string)
+	for rows.Next() {
+		var envID int64
+		var key, value string
+		if err := rows.Scan(&envID, &key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan label: %w", err)
+		}
+		if result[envID] == nil {
+			result[envID] = make(map[string]string)
+		}
+		result[envID][key] = value
+	}
+	return result, rows.Err()
+}
+
+func GetEnvLabels(path string) (map[string]string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetLabels(env.ID)
+}
+
+func AddLabels(path string, labels []string) (map[string]string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range labels {
+		key, value, err := ParseLabel(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.SetLabel(env.ID, key, value); err != nil {
+			return nil, err
+		}
+	}
+	return db.GetLabels(env.ID)
+}
+
+func RemoveLabels(path string, keys []string) (map[string]string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		removed, err := db.RemoveLabel(env.ID, key)
+		if err != nil {
+			return nil, err
+		}
+		if !removed {
+			return nil, fmt.Errorf("%s has no label %q", env.EnvName(), key)
+		}
+	}
+	return db.GetLabels(env.ID)
+}
+
+type EnvFilter struct {
+	Labels    LabelSelector
+	OlderThan time.Duration
+}
+
+func (f EnvFilter) Empty() bool {
+	return len(f.Labels) == 0 && f.OlderThan == 0
+}
+
+func FilterEnvironments(filter EnvFilter) ([]*Environment, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := db.GetAllLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*Environment
+	for _, env := range envs {
+		if filter.Matches(env.CreatedAt, labels[env.ID]) {
+			matched = append(matched, env)
+		}
+	}
+	return matched, nil
+}
+
+func (f EnvFilter) Matches(createdAt time.Time, labels map[string]string) bool {
+	if f.OlderThan > 0 && time.Since(createdAt) < f.OlderThan {
+		return false
+	}
+	return f.Labels.Matches(labels)
+}
@@ -0,0 +1,62 @@
+package mono
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := map[string]string{"team": "payments", "wip": ""}
+
+	tests := []struct {
+		selectors []string
+		want      bool
+	}{
+		{nil, true},
+		{[]string{"team=payments"}, true},
+		{[]string{"team"}, true},
+		{[]string{"wip"}, true},
+		{[]string{"wip="}, true},
+		{[]string{"team=search"}, false},
+		{[]string{"owner"}, false},
+		{[]string{"team=payments,wip"}, true},
+		{[]string{"team=payments", "owner=alice"}, false},
+	}
+	for _, tt := range tests {
+		sel, err := ParseLabelSelector(tt.selectors)
+		if err != nil {
+			t.Fatalf("ParseLabelSelector(%v) failed: %v", tt.selectors, err)
+		}
+		if got := sel.Matches(labels); got != tt.want {
+			t.Errorf("selector %v matches = %v, want %v", tt.selectors, got, tt.want)
+		}
+	}
+
+	if _, err := ParseLabelSelector([]string{"=payments"}); err == nil {
+		t.Error("expected error for empty label key")
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":  7 * 24 * time.Hour,
+		"0d":  0,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseAge(in)
+		if err != nil {
+			t.Fatalf("ParseAge(%q) failed: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ParseAge(%q) = %v, want %v", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "d", "-1d", "seven"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q) expected error", in)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mono
+
+import (
+	"fmt"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/go-units"
+)
+
+type ResourceLimits struct {
+	CPUs   float64 `yaml:"cpus"`
+	Memory string  `yaml:"memory"`
+}
+
+func (rl ResourceLimits) memoryBytes() (int64, error) {
+	if rl.Memory == "" {
+		return 0, nil
+	}
+	n, err := units.RAMInBytes(rl.Memory)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid memory %q: expected e.g. 512m or 2g", rl.Memory)
+	}
+	return n, nil
+}
+
+func (rl ResourceLimits) Validate() error {
+	if rl.CPUs < 0 {
+		return fmt.Errorf("invalid cpus %v: must be positive", rl.CPUs)
+	}
+	_, err := rl.memoryBytes()
+	return err
+}
+
+func (rl ResourceLimits) merge(override ResourceLimits) ResourceLimits {
+	if override.CPUs != 0 {
+		rl.CPUs = override.CPUs
+	}
+	if override.Memory != "" {
+		rl.Memory = override.Memory
+	}
+	return rl
+}
+
+type ResourcesConfig struct {
+	Default  ResourceLimits            `yaml:"default"`
+	Services map[string]ResourceLimits `yaml:"services"`
+}
+
+func (rc ResourcesConfig) Validate() error {
+	if err := rc.Default.Validate(); err != nil {
+		return fmt.Errorf("resources.default: %w", err)
+	}
+	for _, name := range sortedKeys(rc.Services) {
+		if err := rc.Services[name].Validate(); err != nil {
+			return fmt.Errorf("resources.services.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (rc ResourcesConfig) For(service string) ResourceLimits {
+	return rc.Default.merge(rc.Services[service])
+}
+
+func ApplyResourceLimits(project *types.Project, resources ResourcesConfig) error {
+	for _, name := range project.ServiceNames() {
+		limits := resources.For(name)
+		memory, err := limits.memoryBytes()
+		if err != nil {
+			return fmt.Errorf("resources for %s: %w", name, err)
+		}
+		if limits.CPUs == 0 && memory == 0 {
+			continue
+		}
+
+		svc := project.Services[name]
+		var deployLimits *types.Resource
+		if svc.Deploy != nil && svc.Deploy.Resources.Limits != nil {
+			deployLimits = svc.Deploy.Resources.Limits
+		}
+		if limits.CPUs != 0 {
+			svc.CPUS = float32(limits.CPUs)
+			if deployLimits != nil {
+				deployLimits.NanoCPUs = types.NanoCPUs(limits.CPUs)
+			}
+		}
+		if memory != 0 {
+			svc.MemLimit = types.UnitBytes(memory)
+			if deployLimits != nil {
+				deployLimits.MemoryBytes = types.UnitBytes(memory)
+			}
+		}
+		project.Services[name] = svc
+	}
+	return nil
+}
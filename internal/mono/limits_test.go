@@ -0,0 +1,44 @@
+package mono
+
+import (
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestApplyResourceLimits(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			"api": {Name: "api"},
+			"db": {
+				Name:   "db",
+				Deploy: &types.DeployConfig{Resources: types.Resources{Limits: &types.Resource{MemoryBytes: 8 << 30}}},
+			},
+		},
+	}
+	resources := ResourcesConfig{
+		Default:  ResourceLimits{CPUs: 2, Memory: "1g"},
+		Services: map[string]ResourceLimits{"db": {Memory: "512m"}},
+	}
+	if err := ApplyResourceLimits(project, resources); err != nil {
+		t.Fatal(err)
+	}
+
+	api := project.Services["api"]
+	if api.CPUS != 2 || api.MemLimit != 1<<30 {
+		t.Errorf("api limits = cpus %v memory %d, want the default", api.CPUS, api.MemLimit)
+	}
+	db := project.Services["db"]
+	if db.CPUS != 2 || db.MemLimit != 512<<20 {
+		t.Errorf("db limits = cpus %v memory %d, want 2 cpus and 512m", db.CPUS, db.MemLimit)
+	}
+	if db.Deploy.Resources.Limits.MemoryBytes != 512<<20 {
+		t.Errorf("db deploy memory = %d, want the configured limit to replace it", db.Deploy.Resources.Limits.MemoryBytes)
+	}
+
+	for _, invalid := range []ResourceLimits{{CPUs: -1}, {Memory: "lots"}} {
+		if err := (ResourcesConfig{Default: invalid}).Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", invalid)
+		}
+	}
+}
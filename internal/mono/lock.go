@@ -0,0 +1,140 @@
+package mono
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const lockPollInterval = 200 * time.Millisecond
+
+var ErrEnvLocked = errors.New("environment is locked")
+
+type EnvLock struct {
+	file *os.File
+}
+
+func EnvLockPath(envPath string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(envPath))
+	return filepath.Join(home, ".mono", "locks", hex.EncodeToString(sum[:])[:16]+".lock"), nil
+}
+
+func AcquireEnvLock(envPath, operation string, wait time.Duration) (*EnvLock, error) {
+	lockPath, err := EnvLockPath(envPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create locks directory: %w", err)
+	}
+
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", envPath, err)
+		}
+		if time.Now().After(deadline) {
+			holder := readLockHolder(lockPath)
+			f.Close()
+			if wait > 0 {
+				return nil, fmt.Errorf("%w: %s is still running for %s after waiting %s", ErrEnvLocked, holder, envPath, wait)
+			}
+			return nil, fmt.Errorf("%w: %s is already running for %s (use --wait to wait for it)", ErrEnvLocked, holder, envPath)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		releaseLockFile(f)
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d %s\n", os.Getpid(), operation)), 0); err != nil {
+		releaseLockFile(f)
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return &EnvLock{file: f}, nil
+}
+
+var portsMu sync.Mutex
+
+type PortsLock struct {
+	file *os.File
+}
+
+func AcquirePortsLock() (*PortsLock, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	lockPath := filepath.Join(home, ".mono", "locks", "ports.lock")
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create locks directory: %w", err)
+	}
+
+	portsMu.Lock()
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		portsMu.Unlock()
+		return nil, fmt.Errorf("failed to open ports lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		portsMu.Unlock()
+		return nil, fmt.Errorf("failed to lock port allocations: %w", err)
+	}
+	return &PortsLock{file: f}, nil
+}
+
+func (l *PortsLock) Release() {
+	if l != nil && l.file != nil {
+		releaseLockFile(l.file)
+		l.file = nil
+		portsMu.Unlock()
+	}
+}
+
+func readLockHolder(lockPath string) string {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return "another mono process"
+	}
+	pid, operation, ok := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if !ok {
+		return "another mono process"
+	}
+	return fmt.Sprintf("mono %s (pid %s)", operation, pid)
+}
+
+func (l *EnvLock) Release() {
+	if l != nil && l.file != nil {
+		releaseLockFile(l.file)
+		l.file = nil
+	}
+}
+
+func releaseLockFile(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
@@ -0,0 +1,51 @@
+package mono
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireEnvLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	envPath := "/tmp/workspaces/proj/feature"
+
+	first, err := AcquireEnvLock(envPath, "init", 0)
+	if err != nil {
+		t.Fatalf("first lock failed: %v", err)
+	}
+
+	_, err = AcquireEnvLock(envPath, "init", 0)
+	if !errors.Is(err, ErrEnvLocked) {
+		t.Fatalf("second lock error = %v, want ErrEnvLocked", err)
+	}
+	if !strings.Contains(err.Error(), "mono init (pid") {
+		t.Errorf("error should name the holder, got %q", err)
+	}
+
+	start := time.Now()
+	_, err = AcquireEnvLock(envPath, "init", 300*time.Millisecond)
+	if !errors.Is(err, ErrEnvLocked) {
+		t.Fatalf("waiting lock error = %v, want ErrEnvLocked", err)
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Error("expected lock to wait before failing")
+	}
+
+	other, err := AcquireEnvLock("/tmp/workspaces/proj/other", "init", 0)
+	if err != nil {
+		t.Fatalf("lock on a different env failed: %v", err)
+	}
+	other.Release()
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		first.Release()
+	}()
+	second, err := AcquireEnvLock(envPath, "destroy", 2*time.Second)
+	if err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+	second.Release()
+}
@@ -8,6 +8,9 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/docker/go-units"
+	"github.com/mattn/go-isatty"
 )
 
 type FileLogger struct {
@@ -41,16 +44,31 @@ func NewFileLogger(envName string) (*FileLogger, error) {
 }
 
 func (l *FileLogger) Log(format string, args ...any) {
+	l.write(VerbosityVerbose, format, args...)
+}
+
+func (l *FileLogger) Debug(format string, args ...any) {
+	if verbosity < VerbosityDebug {
+		return
+	}
+	l.write(VerbosityDebug, format, args...)
+}
+
+func (l *FileLogger) write(level Verbosity, format string, args ...any) {
 	if l.file == nil {
 		return
 	}
 	elapsed := time.Since(l.start)
 	msg := fmt.Sprintf(format, args...)
-	fmt.Fprintf(l.file, "[%s] [+%v] [%s] %s\n",
+	line := fmt.Sprintf("[%s] [+%v] [%s] %s\n",
 		time.Now().Format("15:04:05.000"),
 		elapsed.Round(time.Millisecond),
 		l.envName,
 		msg)
+	fmt.Fprint(l.file, line)
+	if verbosity >= level {
+		fmt.Fprint(os.Stderr, line)
+	}
 }
 
 func (l *FileLogger) Close() {
@@ -82,54 +100,177 @@ func (w *LogWriter) Write(p []byte) (n int, err error) {
 }
 
 type ProgressLogger struct {
-	logger      *FileLogger
-	operation   string
-	total       int64
-	completed   atomic.Int64
-	lastLogTime time.Time
-	interval    time.Duration
-	mu          sync.Mutex
+	logger       *FileLogger
+	operation    string
+	total        atomic.Int64
+	totalBytes   atomic.Int64
+	counting     atomic.Bool
+	completed    atomic.Int64
+	bytes        atomic.Int64
+	current      atomic.Value
+	start        time.Time
+	lastEmitTime time.Time
+	lastCount    int64
+	lastBytes    int64
+	lastChange   time.Time
+	interval     time.Duration
+	heartbeat    bool
+	mu           sync.Mutex
+	stop         chan struct{}
+	stopOnce     sync.Once
 }
 
 func NewProgressLogger(logger *FileLogger, operation string, total int64) *ProgressLogger {
-	return &ProgressLogger{
-		logger:      logger,
-		operation:   operation,
-		total:       total,
-		lastLogTime: time.Now(),
-		interval:    5 * time.Second,
+	now := time.Now()
+	p := &ProgressLogger{
+		logger:     logger,
+		operation:  operation,
+		start:      now,
+		lastChange: now,
+		interval:   5 * time.Second,
+		heartbeat:  verbosity == VerbosityNormal && !isatty.IsTerminal(os.Stderr.Fd()) && !isatty.IsCygwinTerminal(os.Stderr.Fd()),
+		stop:       make(chan struct{}),
 	}
+	p.total.Store(total)
+	p.emit(ProgressStart)
+	go p.beat()
+	return p
 }
 
-func (p *ProgressLogger) Increment() {
+func (p *ProgressLogger) AddTotal(files, bytes int64) {
+	p.counting.Store(true)
+	p.total.Add(files)
+	p.totalBytes.Add(bytes)
+}
+
+func (p *ProgressLogger) TotalKnown() {
+	p.counting.Store(false)
+}
+
+func (p *ProgressLogger) SetCurrent(file string) {
+	p.current.Store(file)
+}
+
+func (p *ProgressLogger) Increment(bytes int64) {
 	p.completed.Add(1)
-	p.maybeLog()
+	p.bytes.Add(bytes)
+	p.maybeEmit()
+}
+
+func (p *ProgressLogger) AddBytes(bytes int64) {
+	p.bytes.Add(bytes)
+	p.maybeEmit()
 }
 
-func (p *ProgressLogger) maybeLog() {
+func (p *ProgressLogger) maybeEmit() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if time.Since(p.lastLogTime) < p.interval {
-		return
+	if time.Since(p.lastEmitTime) >= progressInterval {
+		p.emit(ProgressUpdate)
+		p.lastEmitTime = time.Now()
 	}
+}
 
-	p.logProgress()
-	p.lastLogTime = time.Now()
+func (p *ProgressLogger) beat() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.logProgress()
+			p.mu.Unlock()
+		}
+	}
 }
 
-func (p *ProgressLogger) logProgress() {
+func (p *ProgressLogger) emit(state string) {
+	e := ProgressEvent{
+		Env:       p.logger.envName,
+		Operation: p.operation,
+		State:     state,
+		Current:   p.completed.Load(),
+		Bytes:     p.bytes.Load(),
+	}
+	if !p.counting.Load() {
+		e.Total, e.TotalBytes = p.total.Load(), p.totalBytes.Load()
+	}
+	EmitProgress(e)
+}
+
+func (p *ProgressLogger) status() string {
 	completed := p.completed.Load()
-	if p.total > 0 {
-		pct := float64(completed) / float64(p.total) * 100
-		p.logger.Log("%s: %d/%d files (%.0f%%)", p.operation, completed, p.total, pct)
-	} else {
-		p.logger.Log("%s: %d files", p.operation, completed)
+	total := p.total.Load()
+	bytes := p.bytes.Load()
+	totalBytes := p.totalBytes.Load()
+	counting := p.counting.Load()
+	elapsed := time.Since(p.start).Seconds()
+
+	var b strings.Builder
+	switch {
+	case counting:
+		fmt.Fprintf(&b, "%s: %d/%d+ files", p.operation, completed, total)
+	case total > 0:
+		fmt.Fprintf(&b, "%s: %d/%d files", p.operation, completed, total)
+	default:
+		fmt.Fprintf(&b, "%s: %d files", p.operation, completed)
+	}
+
+	switch {
+	case !counting && totalBytes > 0:
+		pct := float64(bytes) / float64(totalBytes) * 100
+		fmt.Fprintf(&b, ", %s/%s (%.0f%%)", units.HumanSize(float64(bytes)), units.HumanSize(float64(totalBytes)), pct)
+	case !counting && total > 0:
+		pct := float64(completed) / float64(total) * 100
+		fmt.Fprintf(&b, " (%.0f%%)", pct)
+		if bytes > 0 {
+			fmt.Fprintf(&b, ", %s", units.HumanSize(float64(bytes)))
+		}
+	case bytes > 0:
+		fmt.Fprintf(&b, ", %s", units.HumanSize(float64(bytes)))
+	}
+
+	if bytes > 0 && elapsed > 0 {
+		rate := float64(bytes) / elapsed
+		fmt.Fprintf(&b, " at %s/s", units.HumanSize(rate))
+		if !counting && totalBytes > bytes {
+			eta := time.Duration(float64(totalBytes-bytes) / rate * float64(time.Second))
+			fmt.Fprintf(&b, ", eta %s", eta.Round(time.Second))
+		}
 	}
+
+	if completed != p.lastCount || bytes != p.lastBytes {
+		p.lastCount, p.lastBytes = completed, bytes
+		p.lastChange = time.Now()
+	} else if stalled := time.Since(p.lastChange); stalled >= p.interval {
+		fmt.Fprintf(&b, ", no progress for %s", stalled.Round(time.Second))
+	}
+
+	if current, ok := p.current.Load().(string); ok && current != "" && (total == 0 || completed < total) {
+		fmt.Fprintf(&b, ", current %s", current)
+	}
+	return b.String()
+}
+
+func (p *ProgressLogger) logProgress() {
+	msg := p.status()
+	p.logger.Log("%s", msg)
+	if p.heartbeat {
+		fmt.Fprintf(os.Stderr, "mono: %s\n", msg)
+	}
+}
+
+func (p *ProgressLogger) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
 }
 
 func (p *ProgressLogger) Done() {
+	p.Close()
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.logProgress()
+	p.logger.Log("%s", p.status())
+	p.emit(ProgressDone)
 }
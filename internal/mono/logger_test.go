@@ -0,0 +1,108 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressLoggerHeartbeat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, err := NewFileLogger("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	p := NewProgressLogger(logger, "restoring cargo", 4)
+	defer p.Close()
+	p.start = time.Now().Add(-2 * time.Second)
+	p.SetCurrent("target/debug/deps/libfoo.rlib")
+	p.Increment(4_000_000)
+
+	status := p.status()
+	for _, want := range []string{"restoring cargo: 1/4 files (25%)", "4MB at ", "current target/debug/deps/libfoo.rlib"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status() = %q, want %q", status, want)
+		}
+	}
+	if strings.Contains(status, "no progress") {
+		t.Errorf("status() = %q, want no stall right after progress", status)
+	}
+
+	p.lastChange = time.Now().Add(-30 * time.Second)
+	if status := p.status(); !strings.Contains(status, "no progress for 30s") {
+		t.Errorf("status() = %q, want the stall", status)
+	}
+
+	p.Increment(0)
+	p.Increment(0)
+	p.Increment(0)
+	p.Done()
+	p.Close()
+
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".mono", "mono.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "restoring cargo: 4/4 files (100%)") || strings.Contains(string(data), "current") {
+		t.Errorf("mono.log = %q, want the final count without a current file", data)
+	}
+}
+
+func TestProgressLoggerGrowingTotal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, err := NewFileLogger("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	p := NewProgressLogger(logger, "seeding npm", 0)
+	defer p.Close()
+	p.AddTotal(1, 10)
+	p.AddTotal(1, 20)
+	p.Increment(10)
+
+	if status := p.status(); !strings.HasPrefix(status, "seeding npm: 1/2+ files,") {
+		t.Errorf("status() while counting = %q, want a lower bound without a percentage", status)
+	}
+
+	p.TotalKnown()
+	if status := p.status(); !strings.HasPrefix(status, "seeding npm: 1/2 files, 10B/30B (33%)") {
+		t.Errorf("status() after counting = %q, want a byte percentage", status)
+	}
+}
+
+func TestProgressLoggerCountsBytesWithinFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, err := NewFileLogger("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	p := NewProgressLogger(logger, "restoring models", 0)
+	defer p.Close()
+	p.AddTotal(2, 100_000_000)
+	p.TotalKnown()
+	p.start = time.Now().Add(-10 * time.Second)
+	p.SetCurrent("weights.bin")
+	p.AddBytes(60_000_000)
+
+	status := p.status()
+	if want := "restoring models: 0/2 files, 60MB/100MB (60%) at 6MB/s, eta 7s, current weights.bin"; status != want {
+		t.Errorf("status() = %q, want %q", status, want)
+	}
+
+	p.lastChange = time.Now().Add(-30 * time.Second)
+	if status := p.status(); !strings.Contains(status, "no progress for 30s") {
+		t.Errorf("status() = %q, want the stall", status)
+	}
+	p.AddBytes(4_000_000)
+	if status := p.status(); strings.Contains(status, "no progress") {
+		t.Errorf("status() = %q, want bytes to count as progress", status)
+	}
+}
@@ -0,0 +1,131 @@
+package mono
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var logColors = []string{"36", "33", "32", "35", "34", "96", "93", "92", "95", "94"}
+
+type LogsOptions struct {
+	Services []string
+	Follow   bool
+	Tail     string
+	Since    string
+	Color    bool
+}
+
+func (o LogsOptions) composeArgs() ([]string, error) {
+	args := []string{"logs", "--no-color", "--no-log-prefix"}
+	if o.Follow {
+		args = append(args, "--follow")
+	}
+	if o.Tail != "" {
+		if n, err := strconv.Atoi(o.Tail); o.Tail != "all" && (err != nil || n < 0) {
+			return nil, fmt.Errorf("invalid --tail %q: expected a number of lines or all", o.Tail)
+		}
+		args = append(args, "--tail", o.Tail)
+	}
+	if o.Since != "" {
+		args = append(args, "--since", o.Since)
+	}
+	return args, nil
+}
+
+func StreamLogs(path string, opts LogsOptions, out io.Writer) error {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return err
+	}
+	envName := ec.Env.EnvName()
+	if ec.Project == nil {
+		return fmt.Errorf("environment %s has no compose services", envName)
+	}
+
+	available := ec.Project.ServiceNames()
+	services := opts.Services
+	if len(services) == 0 {
+		services = available
+	}
+	for _, name := range services {
+		if _, ok := ec.Project.Services[name]; !ok {
+			return fmt.Errorf("service %s is not part of environment %s (services: %s)", name, envName, strings.Join(available, ", "))
+		}
+	}
+
+	args, err := opts.composeArgs()
+	if err != nil {
+		return err
+	}
+	containers, err := ec.Env.Runtime()
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, name := range services {
+		width = max(width, len(name))
+	}
+
+	var mu sync.Mutex
+	var g errgroup.Group
+	var started []*exec.Cmd
+	stopStarted := func() {
+		for _, cmd := range started {
+			if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				fmt.Fprintf(out, "warning: failed to stop log stream: %v\n", err)
+			}
+		}
+	}
+	for i, name := range services {
+		prefix := fmt.Sprintf("%-*s | ", width, name)
+		if opts.Color {
+			prefix = "\033[" + logColors[i%len(logColors)] + "m" + prefix + "\033[0m"
+		}
+		cmd, err := containers.ComposeCommand(ec.Env.DockerProject.String, ec.Env.ComposeDirPath(), append(slices.Clone(args), name)...)
+		if err != nil {
+			stopStarted()
+			return err
+		}
+
+		pr, pw := io.Pipe()
+		cmd.Stdout = pw
+		cmd.Stderr = pw
+		if err := cmd.Start(); err != nil {
+			stopStarted()
+			return fmt.Errorf("failed to stream logs of %s: %w", name, err)
+		}
+		started = append(started, cmd)
+		go func() {
+			pw.CloseWithError(cmd.Wait())
+		}()
+
+		g.Go(func() error {
+			scanner := bufio.NewScanner(pr)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				mu.Lock()
+				_, err := fmt.Fprintf(out, "%s%s\n", prefix, scanner.Text())
+				mu.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("logs of %s: %w", name, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
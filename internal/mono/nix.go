@@ -0,0 +1,142 @@
+package mono
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	NixDevelop = "develop"
+	NixDirenv  = "direnv"
+
+	nixEvalTimeout = 5 * time.Minute
+)
+
+type NixConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mode    string `yaml:"mode"`
+	Flake   string `yaml:"flake"`
+	Shell   string `yaml:"shell"`
+}
+
+func (nc *NixConfig) ApplyDefaults() {
+	if nc.Mode == "" {
+		nc.Mode = NixDevelop
+	}
+	if nc.Flake == "" {
+		nc.Flake = "."
+	}
+	if nc.Shell == "" {
+		nc.Shell = "default"
+	}
+}
+
+func (nc NixConfig) Validate() error {
+	if !nc.Enabled {
+		return nil
+	}
+	switch nc.Mode {
+	case "", NixDevelop, NixDirenv:
+	default:
+		return fmt.Errorf("invalid nix.mode %q (expected %s or %s)", nc.Mode, NixDevelop, NixDirenv)
+	}
+	if strings.Contains(nc.Flake, "#") {
+		return fmt.Errorf("invalid nix.flake %q: name the dev shell with nix.shell instead of #", nc.Flake)
+	}
+	return nil
+}
+
+func (nc NixConfig) CheckInstalled() error {
+	if !nc.Enabled {
+		return nil
+	}
+	binaries := []string{"nix"}
+	if nc.Mode == NixDirenv {
+		binaries = append(binaries, "direnv")
+	}
+	for _, b := range binaries {
+		if _, err := exec.LookPath(b); err != nil {
+			return fmt.Errorf("nix.enabled is set but %s is not installed", b)
+		}
+	}
+	return nil
+}
+
+func (nc NixConfig) installable() string {
+	return nc.Flake + "#" + nc.Shell
+}
+
+func (nc NixConfig) Command(args ...string) []string {
+	if nc.Mode == NixDirenv {
+		return append([]string{"direnv", "exec", "."}, args...)
+	}
+	return append([]string{"nix", "develop", nc.installable(), "--command"}, args...)
+}
+
+func (nc NixConfig) shellCommand(command string) string {
+	prefix := nc.Command()
+	quoted := make([]string, len(prefix))
+	for i, p := range prefix {
+		quoted[i] = shellQuote(p)
+	}
+	return strings.Join(quoted, " ") + " " + command
+}
+
+func (nc NixConfig) flakeDir(envPath string) (string, bool) {
+	ref := strings.TrimPrefix(nc.Flake, "path:")
+	if strings.Contains(ref, ":") {
+		return "", false
+	}
+	if filepath.IsAbs(ref) {
+		return ref, true
+	}
+	return filepath.Join(envPath, ref), true
+}
+
+func (nc NixConfig) shellMemo(envPath string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00", envPath, nc.installable())
+	dir, ok := nc.flakeDir(envPath)
+	if !ok {
+		return b.String(), nil
+	}
+	for _, name := range []string{"flake.nix", "flake.lock"} {
+		if err := writeFileStamp(&b, name, filepath.Join(dir, name)); err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", name, err)
+		}
+	}
+	return b.String(), nil
+}
+
+func (cm *CacheManager) nixShellPath(nc NixConfig, envPath string) (string, error) {
+	memo, err := nc.shellMemo(envPath)
+	if err != nil {
+		return "", err
+	}
+	if path, ok := cm.nixShells.Load(memo); ok {
+		return path.(string), nil
+	}
+
+	system, err := Command("nix", "eval", "--impure", "--raw", "--expr", "builtins.currentSystem").
+		Dir(envPath).
+		Timeout(nixEvalTimeout).
+		Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect the nix system: %w", err)
+	}
+	attr := fmt.Sprintf("%s#devShells.%s.%s.outPath", nc.Flake, strings.TrimSpace(string(system)), nc.Shell)
+	output, err := Command("nix", "eval", "--raw", attr).
+		Dir(envPath).
+		Timeout(nixEvalTimeout).
+		Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate nix dev shell %s: %w", nc.installable(), err)
+	}
+
+	path := strings.TrimSpace(string(output))
+	cm.nixShells.Store(memo, path)
+	return path, nil
+}
@@ -0,0 +1,88 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNixShellCommands(t *testing.T) {
+	cfg := Config{Nix: NixConfig{Enabled: true}}
+	cfg.ApplyDefaults(t.TempDir())
+	if err := cfg.Nix.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(cfg.Nix.Command("/bin/zsh", "-c", "make"), " "); got != "nix develop .#default --command /bin/zsh -c make" {
+		t.Errorf("develop command = %q", got)
+	}
+	if got := cfg.Tmux.shell(); got != `'nix' 'develop' '.#default' '--command' "$SHELL"` {
+		t.Errorf("tmux shell = %q", got)
+	}
+
+	direnv := NixConfig{Enabled: true, Mode: NixDirenv}
+	if got := strings.Join(direnv.Command("/bin/bash"), " "); got != "direnv exec . /bin/bash" {
+		t.Errorf("direnv command = %q", got)
+	}
+
+	if (TmuxConfig{}).shell() != "" {
+		t.Error("tmux should use the default shell without nix")
+	}
+	for _, bad := range []NixConfig{{Enabled: true, Mode: "shell"}, {Enabled: true, Flake: ".#dev"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestNixShellPathInCacheKey(t *testing.T) {
+	bin := t.TempDir()
+	shellPath := filepath.Join(bin, "shell-path")
+	script := `#!/bin/sh
+case "$*" in
+*currentSystem*) printf x86_64-linux ;;
+*"devShells.x86_64-linux.default.outPath"*) cat ` + shellPath + ` ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "nix"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	envPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(envPath, "Cargo.lock"), []byte("lock"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cm := &CacheManager{}
+	keyFor := func(storePath string, nix bool) string {
+		t.Helper()
+		if err := os.WriteFile(shellPath, []byte(storePath), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(envPath, "flake.lock"), []byte(storePath), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg := Config{
+			Nix:   NixConfig{Enabled: nix},
+			Build: BuildConfig{Artifacts: []ArtifactConfig{{Name: "cargo", KeyFiles: []string{"Cargo.lock"}, Paths: []string{"target"}}}},
+		}
+		cfg.ApplyDefaults(envPath)
+		key, err := cm.ComputeCacheKey(cfg.Build.Artifacts[0], envPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	plain := keyFor("/nix/store/aaa-nix-shell", false)
+	first := keyFor("/nix/store/aaa-nix-shell", true)
+	second := keyFor("/nix/store/bbb-nix-shell", true)
+	if first == plain || first == second {
+		t.Errorf("keys plain=%s first=%s second=%s, want the dev shell to change the key", plain, first, second)
+	}
+	if again := keyFor("/nix/store/aaa-nix-shell", true); again != first {
+		t.Errorf("key = %s, want %s for the same dev shell", again, first)
+	}
+}
@@ -0,0 +1,161 @@
+package mono
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNotifyMinDuration = 30 * time.Second
+	notifyWebhookTimeout     = 5 * time.Second
+)
+
+type NotifyConfig struct {
+	Desktop     bool   `yaml:"desktop"`
+	Webhook     string `yaml:"webhook"`
+	MinDuration string `yaml:"min_duration"`
+}
+
+func (nc NotifyConfig) Enabled() bool {
+	return nc.Desktop || nc.Webhook != ""
+}
+
+func (nc NotifyConfig) Validate() error {
+	if nc.Webhook != "" {
+		u, err := url.Parse(nc.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid notify.webhook %q: expected an http or https URL", nc.Webhook)
+		}
+	}
+	_, err := nc.minDuration()
+	return err
+}
+
+func (nc NotifyConfig) minDuration() (time.Duration, error) {
+	if nc.MinDuration == "" {
+		return defaultNotifyMinDuration, nil
+	}
+	d, err := time.ParseDuration(nc.MinDuration)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid notify.min_duration %q: expected e.g. 30s or 2m", nc.MinDuration)
+	}
+	return d, nil
+}
+
+type Notification struct {
+	Operation string        `json:"operation"`
+	Env       string        `json:"environment"`
+	Duration  time.Duration `json:"-"`
+	Err       error         `json:"-"`
+}
+
+func (n Notification) Status() string {
+	if n.Err != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+func (n Notification) Message() string {
+	msg := fmt.Sprintf("%s %s in %s", n.Operation, n.Status(), n.Duration.Round(time.Second))
+	if n.Err != nil {
+		msg += ": " + n.Err.Error()
+	}
+	return msg
+}
+
+func (nc NotifyConfig) due(n Notification) (bool, error) {
+	if !nc.Enabled() {
+		return false, nil
+	}
+	if n.Err != nil {
+		return true, nil
+	}
+	threshold, err := nc.minDuration()
+	if err != nil {
+		return false, err
+	}
+	return n.Duration >= threshold, nil
+}
+
+func (nc NotifyConfig) Send(n Notification, logger *FileLogger) {
+	due, err := nc.due(n)
+	if err != nil {
+		logger.Log("warning: %v", err)
+		return
+	}
+	if !due {
+		return
+	}
+	if nc.Desktop {
+		if err := notifyDesktop("mono: "+n.Env, n.Message()); err != nil {
+			logger.Log("warning: failed to send desktop notification: %v", err)
+		}
+	}
+	if nc.Webhook != "" {
+		if err := notifyWebhook(nc.Webhook, n); err != nil {
+			logger.Log("warning: failed to send webhook notification: %v", err)
+		}
+	}
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func notifyDesktop(title, message string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		return Command("osascript", "-e", script).Timeout(notifyWebhookTimeout).Run()
+	case "linux":
+		if _, err := exec.LookPath("notify-send"); err != nil {
+			return fmt.Errorf("notify-send is not installed")
+		}
+		return Command("notify-send", title, message).Timeout(notifyWebhookTimeout).Run()
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+}
+
+type webhookPayload struct {
+	Notification
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	Message    string `json:"message"`
+}
+
+func notifyWebhook(target string, n Notification) error {
+	payload := webhookPayload{
+		Notification: n,
+		Status:       n.Status(),
+		DurationMS:   n.Duration.Milliseconds(),
+		Message:      n.Message(),
+	}
+	if n.Err != nil {
+		payload.Error = n.Err.Error()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	client := &http.Client{Timeout: notifyWebhookTimeout}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
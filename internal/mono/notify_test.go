@@ -0,0 +1,114 @@
+package mono
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  NotifyConfig
+		wantErr string
+	}{
+		{name: "empty", config: NotifyConfig{}},
+		{name: "valid", config: NotifyConfig{Desktop: true, Webhook: "https://hooks.example.com/mono", MinDuration: "1m"}},
+		{name: "webhook without scheme", config: NotifyConfig{Webhook: "hooks.example.com"}, wantErr: "invalid notify.webhook"},
+		{name: "webhook with other scheme", config: NotifyConfig{Webhook: "ftp://example.com"}, wantErr: "invalid notify.webhook"},
+		{name: "bad duration", config: NotifyConfig{MinDuration: "soon"}, wantErr: "invalid notify.min_duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifyConfigDue(t *testing.T) {
+	webhook := NotifyConfig{Webhook: "https://hooks.example.com", MinDuration: "1m"}
+	tests := []struct {
+		name   string
+		config NotifyConfig
+		n      Notification
+		want   bool
+	}{
+		{"disabled", NotifyConfig{MinDuration: "1s"}, Notification{Duration: time.Hour}, false},
+		{"below threshold", webhook, Notification{Duration: 30 * time.Second}, false},
+		{"above threshold", webhook, Notification{Duration: 2 * time.Minute}, true},
+		{"failure below threshold", webhook, Notification{Duration: time.Second, Err: errors.New("boom")}, true},
+		{"default threshold", NotifyConfig{Desktop: true}, Notification{Duration: 10 * time.Second}, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.config.due(tt.n)
+		if err != nil {
+			t.Fatalf("%s: due() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: due() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	logger, err := NewFileLogger("notify-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode webhook body: %v", err)
+		}
+		received <- body
+	}))
+	defer server.Close()
+
+	nc := NotifyConfig{Webhook: server.URL, MinDuration: "0s"}
+	nc.Send(Notification{Operation: "init", Env: "feature-x", Duration: 90 * time.Second, Err: errors.New("compose up failed")}, logger)
+
+	select {
+	case body := <-received:
+		want := map[string]any{
+			"operation":   "init",
+			"environment": "feature-x",
+			"status":      "failed",
+			"duration_ms": float64(90000),
+			"error":       "compose up failed",
+			"message":     "init failed in 1m30s: compose up failed",
+		}
+		for k, v := range want {
+			if body[k] != v {
+				t.Errorf("body[%q] = %v, want %v", k, body[k], v)
+			}
+		}
+	default:
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestAppleScriptString(t *testing.T) {
+	got := appleScriptString(`init failed: "db" at C:\tmp`)
+	want := `"init failed: \"db\" at C:\\tmp"`
+	if got != want {
+		t.Errorf("appleScriptString() = %s, want %s", got, want)
+	}
+}
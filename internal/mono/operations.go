@@ -1,24 +1,47 @@
 package mono
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
 )
 
-func Init(path string, projectRoot string) error {
+type InitOptions struct {
+	ProjectRoot      string
+	EphemeralPorts   bool
+	SkipCacheRestore bool
+	Template         string
+	Profile          string
+	LockWait         time.Duration
+}
+
+func Init(path string, opts InitOptions) (err error) {
 	if _, err := os.Stat(path); err != nil {
 		return fmt.Errorf("path does not exist: %s", path)
 	}
 
-	project, workspace := DeriveNames(path)
-	envName := fmt.Sprintf("%s-%s", project, workspace)
-	if project == "" || workspace == "" {
-		envName = filepath.Base(path)
+	lock, err := AcquireEnvLock(path, "init", opts.LockWait)
+	if err != nil {
+		return err
 	}
+	defer lock.Release()
+
+	marker, err := StartActivity(ActivityInit, "", path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, marker.Done())
+	}()
+
+	envName := DeriveEnvName(path)
 
 	logger, err := NewFileLogger(envName)
 	if err != nil {
@@ -28,6 +51,26 @@ func Init(path string, projectRoot string) error {
 
 	logger.Log("mono init %s", path)
 
+	timer := NewPhaseTimer(TimingInit, envName, path)
+	var notify NotifyConfig
+	var timings *TimingsConfig
+	defer func() {
+		if timings != nil {
+			timer.PrintSummary(*timings)
+		}
+	}()
+	defer func() {
+		notify.Send(Notification{Operation: "init", Env: envName, Duration: time.Since(timer.start), Err: err}, logger)
+	}()
+	defer func() {
+		timer.Finish(err)
+		e := newEvent(EventInit, timer.start, err)
+		e.Env, e.Path, e.Project = envName, path, ComputeProjectID(timer.project)
+		if err := RecordEvent(e); err != nil {
+			logger.Log("warning: failed to record init event: %v", err)
+		}
+	}()
+
 	db, err := OpenDB()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
@@ -42,6 +85,14 @@ func Init(path string, projectRoot string) error {
 		return fmt.Errorf("environment already exists: %s", path)
 	}
 
+	other, err := db.GetEnvironmentByName(envName)
+	if err == nil {
+		return fmt.Errorf("environment name %s is already used by %s", envName, other.Path)
+	}
+	if !errors.Is(err, ErrEnvironmentNotFound) {
+		return err
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %w", err)
@@ -53,21 +104,121 @@ func Init(path string, projectRoot string) error {
 	logger.Log("created data directory")
 
 	cleanup := func() {
-		os.RemoveAll(dataDir)
+		if err := removeAllAudited(dataDir); err != nil {
+			logger.Log("warning: failed to remove data directory: %v", err)
+		}
 	}
 
+	doneConfig := timer.Start("load config")
 	cfg, err := LoadConfig(path)
 	if err != nil {
 		cleanup()
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	cfg.ApplyDefaults(path)
+	notify = cfg.Notify
+	timings = &cfg.Timings
+	tracer := NewTracer(cfg.Tracing, "mono init", timer.start)
+	tracer.Root().SetAttr("mono.env", envName)
+	tracer.Root().SetAttr("mono.path", path)
+	timer.Trace(tracer)
+	defer func() {
+		if err := tracer.Finish(err); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}()
+	defer func() {
+		succeeded := err == nil
+		if err := timer.Save(db, succeeded); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}()
+	if err := cfg.ApplyTemplate(opts.Template); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.ApplyProfile(opts.Profile); err != nil {
+		cleanup()
+		return err
+	}
+	if opts.EphemeralPorts {
+		cfg.Ports.Mode = PortModeEphemeral
+	}
+	if err := cfg.Ports.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Build.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.ValidateServices(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.ValidatePlugins(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.CheckPluginsInstalled(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Nix.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Nix.CheckInstalled(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Kubernetes.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Kubernetes.CheckInstalled(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Resources.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Images.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.Retry.Validate(); err != nil {
+		cleanup()
+		return err
+	}
+	if err := cfg.ValidateRemotes(); err != nil {
+		cleanup()
+		return err
+	}
+	containers, err := NewContainerRuntime(cfg.Runtime)
+	if err != nil {
+		cleanup()
+		return err
+	}
+	reservedPorts, err := cfg.Ports.ReservedPorts()
+	if err != nil {
+		cleanup()
+		return err
+	}
+
+	doneConfig()
 
 	cm, err := NewCacheManager()
 	if err != nil {
 		cleanup()
 		return fmt.Errorf("failed to initialize cache: %w", err)
 	}
+	cm.tracer = tracer
 
 	if err := cm.EnsureDirectories(); err != nil {
 		cleanup()
@@ -81,13 +232,25 @@ func Init(path string, projectRoot string) error {
 		logger.Log("hint: install sccache for faster builds: cargo install sccache")
 	}
 
-	rootPath := projectRoot
+	rootPath := opts.ProjectRoot
 	if rootPath == "" {
 		rootPath = os.Getenv("CONDUCTOR_ROOT_PATH")
 	}
+	if rootPath == "" {
+		detected, err := DetectProjectRoot(path)
+		if err != nil {
+			logger.Log("could not detect project root: %v", err)
+		} else if detected != "" {
+			rootPath = detected
+			logger.Log("detected project root %s", rootPath)
+		}
+	}
 
 	// Check for cargo build conflicts early, before any seeding/caching
 	if rootPath != "" {
+		timer.SetProject(rootPath)
+		tracer.Root().SetAttr("mono.project", rootPath)
+		tracer.Root().SetAttr("mono.project_id", ComputeProjectID(rootPath))
 		if err := CheckCargoBuildConflicts(rootPath); err != nil {
 			logger.Log("warning: %v", err)
 			logger.Log("hint: seeding/caching may be slow or fail due to lock contention")
@@ -95,14 +258,25 @@ func Init(path string, projectRoot string) error {
 	}
 
 	var cacheEntries []ArtifactCacheEntry
-	if len(cfg.Build.Artifacts) > 0 && rootPath != "" {
+	var approximate, approximateNames []string
+	useCache := len(cfg.Build.Artifacts) > 0 && rootPath != ""
+	if useCache {
+		doneKeys := timer.Start("compute keys")
 		entries, err := cm.PrepareArtifactCache(cfg.Build.Artifacts, rootPath, path)
+		doneKeys()
 		if err != nil {
 			logger.Log("warning: failed to prepare artifact cache: %v", err)
 		} else {
 			cacheEntries = entries
 		}
-
+		if err := CheckCacheKeys(cacheEntries, cfg.Build.StrictKeys, logger); err != nil {
+			cleanup()
+			return err
+		}
+	}
+	if useCache && opts.SkipCacheRestore {
+		logger.Log("skipping cache restore, artifacts were cloned")
+	} else if useCache {
 		initialHits := make(map[string]bool)
 		for _, entry := range cacheEntries {
 			initialHits[entry.Name] = entry.Hit
@@ -117,11 +291,15 @@ func Init(path string, projectRoot string) error {
 		}
 
 		if hasMiss {
+			doneSeed := timer.Start("seed from root")
 			if err := cm.SeedFromRoot(cfg.Build.Artifacts, rootPath, path, logger); err != nil {
 				logger.Log("warning: failed to seed cache from root: %v", err)
 			}
+			doneSeed()
 
+			doneKeys := timer.Start("compute keys")
 			entries, err := cm.PrepareArtifactCache(cfg.Build.Artifacts, rootPath, path)
+			doneKeys()
 			if err != nil {
 				logger.Log("warning: failed to re-prepare artifact cache: %v", err)
 			} else {
@@ -139,19 +317,57 @@ func Init(path string, projectRoot string) error {
 				} else {
 					logger.Log("cache hit for %s (key: %s)", entry.Name, entry.Key)
 				}
-				if err := cm.RestoreFromCache(*entry, logger); err != nil {
+				doneRestore := timer.Start("restore " + entry.Name)
+				err := cm.RestoreFromCache(*entry, logger)
+				doneRestore()
+				if err != nil {
 					logger.Log("warning: failed to restore cache: %v", err)
 					entry.Hit = false
 				} else {
 					if err := db.RecordCacheEvent("hit", projectID, entry.Name, entry.Key); err != nil {
 						logger.Log("warning: failed to record cache hit: %v", err)
 					}
+					if _, err := cm.restoreArtifactCrates(cfg.Build.Artifacts, entry.Name, rootPath, path, true, logger); err != nil {
+						logger.Log("warning: %v", err)
+					}
 				}
 			} else {
 				logger.Log("cache miss for %s (key: %s)", entry.Name, entry.Key)
 				if err := db.RecordCacheEvent("miss", projectID, entry.Name, entry.Key); err != nil {
 					logger.Log("warning: failed to record cache miss: %v", err)
 				}
+
+				artifact := artifactByName(cfg.Build.Artifacts, entry.Name)
+				if artifact == nil {
+					continue
+				}
+				doneRestore := timer.Start("restore " + entry.Name)
+				approx, manifest, err := cm.FindApproximateEntry(*artifact, rootPath, path)
+				if err != nil {
+					doneRestore()
+					logger.Log("warning: failed to find approximate cache for %s: %v", entry.Name, err)
+					continue
+				}
+				if approx == nil {
+					_, err := cm.restoreArtifactCrates(cfg.Build.Artifacts, entry.Name, rootPath, path, false, logger)
+					doneRestore()
+					if err != nil {
+						logger.Log("warning: %v", err)
+					}
+					continue
+				}
+				err = cm.RestoreFromCache(*approx, logger)
+				if err == nil {
+					_, err = cm.restoreArtifactCrates(cfg.Build.Artifacts, entry.Name, rootPath, path, true, logger)
+				}
+				doneRestore()
+				if err != nil {
+					logger.Log("warning: failed to restore approximate cache for %s: %v", entry.Name, err)
+					continue
+				}
+				logger.Log("restored approximate %s cache from %s@%.8s (key: %s)", entry.Name, manifest.Branch, manifest.Commit, approx.Key)
+				approximate = append(approximate, fmt.Sprintf("%s (from %s@%.8s)", entry.Name, manifest.Branch, manifest.Commit))
+				approximateNames = append(approximateNames, entry.Name)
 			}
 		}
 	}
@@ -167,6 +383,9 @@ func Init(path string, projectRoot string) error {
 	cacheEnvVars := cm.EnvVars(cfg.Build)
 	cacheEnvVars = append(cacheEnvVars, fmt.Sprintf("MONO_CACHE_HIT=%t", allHit))
 	cacheEnvVars = append(cacheEnvVars, "MONO_CACHE_DIR="+cm.LocalCacheDir)
+	if len(approximateNames) > 0 {
+		cacheEnvVars = append(cacheEnvVars, "MONO_CACHE_APPROXIMATE="+strings.Join(approximateNames, ","))
+	}
 
 	composeDir := cfg.ResolveComposeDir(path)
 	_, composeErr := DetectComposeFile(composeDir)
@@ -177,7 +396,12 @@ func Init(path string, projectRoot string) error {
 		dockerProject = fmt.Sprintf("mono-%s", envName)
 	}
 
-	envID, err := db.InsertEnvironment(path, dockerProject, rootPath, cfg.ComposeDir)
+	branch, err := GitCurrentBranch(path)
+	if err != nil {
+		logger.Log("warning: %v", err)
+	}
+
+	envID, err := db.InsertEnvironment(path, dockerProject, rootPath, cfg.ComposeDir, branch)
 	if err != nil {
 		cleanup()
 		return fmt.Errorf("failed to save environment: %w", err)
@@ -185,11 +409,134 @@ func Init(path string, projectRoot string) error {
 	logger.Log("registered environment (id=%d)", envID)
 
 	cleanupWithDB := func() {
-		db.DeleteEnvironment(path)
+		if err := db.DeleteEnvironment(path); err != nil {
+			logger.Log("warning: %v", err)
+		}
 		cleanup()
 	}
 
+	if branch != "" {
+		base, err := GitRevParse(path, "HEAD")
+		if err != nil {
+			logger.Log("warning: %v", err)
+		} else if err := db.SetEnvironmentBaseCommit(envID, base); err != nil {
+			cleanupWithDB()
+			return err
+		}
+	}
+
+	if opts.Template != "" {
+		if err := db.SetEnvironmentTemplate(envID, opts.Template); err != nil {
+			cleanupWithDB()
+			return err
+		}
+		logger.Log("using template %s", opts.Template)
+	}
+
+	if opts.Profile != "" {
+		if err := db.SetEnvironmentProfile(envID, opts.Profile); err != nil {
+			cleanupWithDB()
+			return err
+		}
+		logger.Log("using profile %s", opts.Profile)
+	}
+
+	if !isSimpleMode {
+		if err := db.SetEnvironmentRuntime(envID, containers.Name()); err != nil {
+			cleanupWithDB()
+			return err
+		}
+	}
+
+	if len(cacheEntries) > 0 {
+		payload := PluginPayload{Event: PluginPostRestore, Env: envName, Path: path, RootPath: rootPath, DataDir: dataDir, Artifacts: pluginArtifacts(cacheEntries, approximateNames)}
+		if err := runPlugins(cfg.Plugins, payload, buildScriptEnv(envName, envID, path, rootPath, nil, cfg.Env, cacheEnvVars), logger); err != nil {
+			cleanupWithDB()
+			return err
+		}
+	}
+
 	var allocations []Allocation
+	var composeConfig *ComposeConfig
+	composePorts := map[string][]PortRequest{}
+	if !isSimpleMode {
+		composeConfig, err = ParseComposeConfig(composeDir)
+		if err != nil {
+			cleanupWithDB()
+			return fmt.Errorf("failed to parse compose config: %w", err)
+		}
+		if err := cfg.SelectCompose(composeConfig); err != nil {
+			cleanupWithDB()
+			return err
+		}
+		if len(cfg.ComposeProfiles) > 0 {
+			logger.Log("enabled compose profiles: %s", strings.Join(cfg.ComposeProfiles, ", "))
+		}
+		composePorts = composeConfig.GetServicePorts()
+	}
+
+	var prefetched chan error
+	if !isSimpleMode && cfg.Images.PrefetchEnabled() {
+		if err := containers.CheckAvailable(); err != nil {
+			logger.Log("skipping image prefetch: %v", err)
+		} else {
+			prefetched = make(chan error, 1)
+			images := ProjectImages(composeConfig.Project())
+			logger.Log("prefetching %d image(s)", len(images))
+			go func() {
+				_, err := PrefetchImages(containers, images, cfg.Images, false, NewLogWriter(logger, "images"))
+				prefetched <- err
+			}()
+		}
+	}
+	monoPorts, err := monoServicePorts(cfg.SelectedProcessServices(), cfg.Kubernetes)
+	if err != nil {
+		cleanupWithDB()
+		return err
+	}
+	servicePorts, err := mergeServicePorts(composePorts, monoPorts)
+	if err != nil {
+		cleanupWithDB()
+		return err
+	}
+	if len(servicePorts) > 0 {
+		allocations, err = db.allocateInitPorts(envID, envName, cfg.Ports.Mode, servicePorts, reservedPorts, logger)
+		if err != nil {
+			cleanupWithDB()
+			return err
+		}
+	}
+	cacheEnvVars = append(cacheEnvVars, PortEnvVars(allocations)...)
+	cacheEnvVars = append(cacheEnvVars, cfg.Kubernetes.EnvVars(envName)...)
+
+	tlsDir := ""
+	if cfg.TLS.Enabled {
+		tlsDir, _, err = IssueEnvCertificate(envName, cfg.TLSDomains(), false)
+		if err != nil {
+			cleanupWithDB()
+			return err
+		}
+		logger.Log("issued tls certificate in %s", tlsDir)
+		tlsVars, err := TLSEnvVars(envName)
+		if err != nil {
+			cleanupWithDB()
+			return err
+		}
+		cacheEnvVars = append(cacheEnvVars, tlsVars...)
+	}
+
+	if err := cfg.ResolvePorts(allocations); err != nil {
+		cleanupWithDB()
+		return fmt.Errorf("invalid mono.yml: %w", err)
+	}
+
+	if len(allocations) > 0 {
+		payload := PluginPayload{Event: PluginPortAllocated, Env: envName, Path: path, RootPath: rootPath, DataDir: dataDir, Ports: pluginPorts(allocations)}
+		if err := runPlugins(cfg.Plugins, payload, buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), logger); err != nil {
+			cleanupWithDB()
+			return err
+		}
+	}
 
 	// Re-check for cargo build conflicts before init script (may have started during seeding)
 	if rootPath != "" {
@@ -198,114 +545,646 @@ func Init(path string, projectRoot string) error {
 			logger.Log("hint: init script may block waiting for file lock")
 		}
 	}
-
-	if cfg.Scripts.Init != "" {
-		scriptEnv := buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars)
-		logger.Log("running init script: %s", cfg.Scripts.Init)
-		if err := runScript(path, cfg.Scripts.Init, scriptEnv, logger); err != nil {
-			cleanupWithDB()
-			return fmt.Errorf("init script failed: %w", err)
-		}
-		logger.Log("init script completed")
+
+	if err := LinkSharedPaths(cfg.Shared, path, rootPath, logger); err != nil {
+		cleanupWithDB()
+		return err
+	}
+
+	secrets, err := ResolveSecrets(cfg.Env)
+	if err != nil {
+		cleanupWithDB()
+		return err
+	}
+	if len(secrets) > 0 {
+		logger.Log("resolved %d secret(s)", len(secrets))
+	}
+
+	if cfg.Dotenv.Enabled {
+		var composeProject *types.Project
+		if composeConfig != nil {
+			composeProject = composeConfig.Project()
+		}
+		dotenvPath, err := WriteDotenv(path, cfg.Dotenv, buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), composeProject, allocations)
+		if err != nil {
+			cleanupWithDB()
+			return err
+		}
+		logger.Log("generated %s", dotenvPath)
+	}
+
+	if cfg.Direnv.Enabled {
+		var composeProject *types.Project
+		if composeConfig != nil {
+			composeProject = composeConfig.Project()
+		}
+		envrcPath, err := WriteEnvrc(path, cfg.Direnv, buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), composeProject, allocations)
+		if err != nil {
+			cleanupWithDB()
+			return err
+		}
+		logger.Log("generated %s", envrcPath)
+		if err := AllowEnvrc(envrcPath); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}
+
+	donePreInit := timer.Start("pre_init hook")
+	err = runHook(RetryPreInit, cfg.Hooks.PreInit, path, withSecrets(buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets), cfg.Retry[RetryPreInit], logger)
+	donePreInit()
+	if err != nil {
+		cleanupWithDB()
+		return err
+	}
+
+	if cfg.Scripts.Init != "" {
+		scriptEnv := withSecrets(buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets)
+		logger.Log("running init script: %s", cfg.Scripts.Init)
+		doneInit := timer.Start("init script")
+		err := withRetry(RetryInit, cfg.Retry[RetryInit], logger, func() error {
+			return runScript(path, cfg.Scripts.Init, scriptEnv, logger)
+		})
+		doneInit()
+		if err != nil {
+			cleanupWithDB()
+			return fmt.Errorf("init script failed: %w", err)
+		}
+		logger.Log("init script completed")
+	}
+
+	for i := range cacheEntries {
+		entry := &cacheEntries[i]
+		if !entry.Hit && entry.Type != ArtifactBuildx {
+			if err := cm.StoreToCache(*entry); err != nil {
+				logger.Log("warning: failed to store %s to cache: %v", entry.Name, err)
+			} else {
+				logger.Log("stored %s to cache (key: %s)", entry.Name, entry.Key)
+				entry.Hit = true
+			}
+		}
+	}
+	if err := cm.EnforceMaxSize(db, cacheEntries, logger); err != nil {
+		logger.Log("warning: failed to enforce cache.max_size: %v", err)
+	}
+
+	if !isSimpleMode {
+		if err := EnsureRuntimeAvailable(containers, cfg.ContainerAutostart, os.Stderr); err != nil {
+			cleanupWithDB()
+			return err
+		}
+
+		composeProject := composeConfig.Project()
+		if err := ApplyOverrides(composeProject, dockerProject, allocations); err != nil {
+			cleanupWithDB()
+			return err
+		}
+		MountSharedReadOnly(composeProject, cfg.Shared, path)
+		if tlsDir != "" {
+			MountTLS(composeProject, tlsDir, cfg.TLS.Mount)
+		}
+		if err := ApplyResourceLimits(composeProject, cfg.Resources); err != nil {
+			cleanupWithDB()
+			return err
+		}
+		if err := ApplyBuildxCache(composeProject, cfg.Build.Artifacts, path); err != nil {
+			cleanupWithDB()
+			return err
+		}
+
+		monoComposePath := filepath.Join(composeDir, "docker-compose.mono.yml")
+		if err := WriteComposeOverride(monoComposePath, composeProject); err != nil {
+			cleanupWithDB()
+			return fmt.Errorf("failed to write compose override: %w", err)
+		}
+		logger.Log("generated docker-compose.mono.yml")
+
+		if prefetched != nil {
+			donePrefetch := timer.Start("image prefetch")
+			err := <-prefetched
+			donePrefetch()
+			if err != nil {
+				logger.Log("warning: failed to prefetch images, compose will pull them: %v", err)
+			}
+		}
+
+		logger.Log("running: %s compose -p %s up -d", containers.Name(), dockerProject)
+		stdout := NewLogWriter(logger, "out")
+		stderr := NewLogWriter(logger, "err")
+		tracer.Root().SetAttr("mono.container_runtime", containers.Name())
+		doneContainers := timer.Start("containers")
+		err := withRetry(RetryContainers, cfg.Retry[RetryContainers], logger, func() error {
+			return containers.StartContainers(dockerProject, composeDir, stdout, stderr)
+		})
+		doneContainers()
+		if err != nil {
+			cleanupWithDB()
+			return fmt.Errorf("failed to start containers: %w", err)
+		}
+		logger.Log("%s compose completed", containers.Name())
+		cm.StoreBuildxCaches(cacheEntries, logger)
+		if err := trackContainerResources(db, envID, containers, dockerProject); err != nil {
+			logger.Log("warning: failed to record container resources: %v", err)
+		}
+
+		doneHealth := timer.Start("health checks")
+		err = waitForDependencies(cfg, composeProject, containers, dockerProject, allocations, withSecrets(buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets), os.Stderr, logger)
+		doneHealth()
+		if err != nil {
+			containers.StopContainers(dockerProject, composeDir, true, nil, nil)
+			cleanupWithDB()
+			return err
+		}
+	}
+
+	if cfg.Kubernetes.Enabled {
+		doneKubernetes := timer.Start("kubernetes")
+		err := ProvisionKubernetes(cfg.Kubernetes, envName, path, dataDir, allocations, buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), logger)
+		doneKubernetes()
+		if err != nil {
+			if err := TeardownKubernetes(cfg.Kubernetes, envName, logger); err != nil {
+				logger.Log("warning: %v", err)
+			}
+			if !isSimpleMode {
+				containers.StopContainers(dockerProject, composeDir, true, nil, nil)
+			}
+			cleanupWithDB()
+			return err
+		}
+	}
+
+	if cfg.Scripts.Setup != "" {
+		scriptEnv := withSecrets(buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets)
+		logger.Log("running setup script: %s", cfg.Scripts.Setup)
+		doneSetup := timer.Start("setup script")
+		err := withRetry(RetrySetup, cfg.Retry[RetrySetup], logger, func() error {
+			return runScript(path, cfg.Scripts.Setup, scriptEnv, logger)
+		})
+		doneSetup()
+		if err != nil {
+			if cfg.Kubernetes.Enabled {
+				if err := TeardownKubernetes(cfg.Kubernetes, envName, logger); err != nil {
+					logger.Log("warning: %v", err)
+				}
+			}
+			if !isSimpleMode {
+				containers.StopContainers(dockerProject, composeDir, true, nil, nil)
+			}
+			cleanupWithDB()
+			return fmt.Errorf("setup script failed: %w", err)
+		}
+		logger.Log("setup script completed")
+	}
+
+	if cfg.Hosts.Enabled {
+		hostnames := EnvHostnames(envName, cfg.Hosts.Domain, allocations)
+		if err := UpdateHostsFile(cfg.Hosts.File, envName, hostnames); err != nil {
+			logger.Log("warning: failed to update hosts entries: %v", err)
+		} else {
+			logger.Log("mapped %d hostname(s) in %s", len(hostnames), cfg.Hosts.File)
+		}
+	}
+
+	doneSession := timer.Start("tmux session")
+	sessionName := SessionName(envName)
+	sessionEnv := withSecrets(buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets)
+	tm := NewTmuxManager(sessionName, path, cfg.Tmux)
+	if err := tm.CreateSession(sessionEnv); err != nil {
+		logger.Log("warning: failed to create tmux session: %v", err)
+	} else {
+		logger.Log("created tmux session %s", sessionName)
+		for _, w := range cfg.Tmux.Windows {
+			if err := CreateWindow(sessionName, w.Name, path, cfg.Tmux.shell(), w.Command); err != nil {
+				logger.Log("warning: %v", err)
+			}
+		}
+		for _, svc := range cfg.SelectedProcessServices() {
+			if err := CreateWindow(sessionName, svc.Name, svc.Dir(path), cfg.Tmux.shell(), ""); err != nil {
+				logger.Log("warning: %v", err)
+			}
+		}
+	}
+	doneSession()
+
+	Printf("Environment initialized: %s\n", envName)
+	Printf("  Path: %s\n", path)
+	Printf("  Data: %s\n", dataDir)
+	if opts.Template != "" {
+		Printf("  Template: %s\n", opts.Template)
+	}
+	if opts.Profile != "" {
+		Printf("  Profile: %s\n", opts.Profile)
+	}
+	if !isSimpleMode {
+		Printf("  Docker: %s\n", dockerProject)
+	}
+	if cfg.Kubernetes.Enabled {
+		t := cfg.Kubernetes.target(envName)
+		Printf("  Kubernetes: %s (namespace %s)\n", t.Context(), t.Namespace)
+	}
+	for _, alloc := range allocations {
+		Printf("  %s\n", alloc.String())
+	}
+	if cfg.Hosts.Enabled {
+		Printf("  Hosts: %s\n", strings.Join(EnvHostnames(envName, cfg.Hosts.Domain, allocations), ", "))
+	}
+	if len(approximate) > 0 {
+		Printf("  Cache: approximate %s\n", strings.Join(approximate, ", "))
+	}
+	Printf("  Tmux: %s\n", sessionName)
+
+	donePostInit := timer.Start("post_init hook")
+	err = runHook(RetryPostInit, cfg.Hooks.PostInit, path, sessionEnv, cfg.Retry[RetryPostInit], logger)
+	donePostInit()
+	if err != nil {
+		logger.Log("warning: %v; environment %s is up", err, envName)
+		Printf("Warning: %v; the environment is up\n", err)
+	}
+
+	return nil
+}
+
+func artifactByName(artifacts []ArtifactConfig, name string) *ArtifactConfig {
+	for i := range artifacts {
+		if artifacts[i].Name == name {
+			return &artifacts[i]
+		}
+	}
+	return nil
+}
+
+type CreateOptions struct {
+	Branch         string
+	Base           string
+	Dir            string
+	ProjectRoot    string
+	EphemeralPorts bool
+	Template       string
+	Profile        string
+}
+
+func Create(opts CreateOptions) (string, error) {
+	if opts.Branch == "" {
+		return "", fmt.Errorf("branch is required")
+	}
+
+	repo, err := GitRepoRoot(opts.ProjectRoot)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := resolveWorktreeDir(repo, opts.Branch, opts.Dir)
+	if err != nil {
+		return "", err
+	}
+
+	if err := AddWorktree(repo, dir, opts.Branch, opts.Base); err != nil {
+		return "", err
+	}
+	Printf("Created worktree for %s at %s\n", opts.Branch, dir)
+
+	if err := Init(dir, InitOptions{ProjectRoot: repo, EphemeralPorts: opts.EphemeralPorts, Template: opts.Template, Profile: opts.Profile}); err != nil {
+		if rmErr := RemoveWorktree(repo, dir, true); rmErr != nil {
+			return "", fmt.Errorf("%w (cleanup also failed: %v)", err, rmErr)
+		}
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func Rename(path, newName string) error {
+	if err := ValidateEnvName(newName); err != nil {
+		return err
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByPath(path)
+	if err != nil {
+		return fmt.Errorf("environment not found: %s", path)
+	}
+	oldName := env.EnvName()
+	if oldName == newName {
+		return fmt.Errorf("environment is already named %s", newName)
+	}
+
+	other, err := db.GetEnvironmentByName(newName)
+	if err == nil {
+		return fmt.Errorf("environment name %s is already used by %s", newName, other.Path)
+	}
+	if !errors.Is(err, ErrEnvironmentNotFound) {
+		return err
+	}
+
+	logger, err := NewFileLogger(newName)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	logger.Log("mono rename %s -> %s", oldName, newName)
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	oldDataDir := filepath.Join(home, ".mono", "data", oldName)
+	newDataDir := filepath.Join(home, ".mono", "data", newName)
+	if _, err := os.Stat(newDataDir); err == nil {
+		return fmt.Errorf("data directory already exists: %s", newDataDir)
+	}
+
+	branch, err := GitCurrentBranch(path)
+	if err != nil {
+		logger.Log("warning: %v", err)
+		branch = env.Branch.String
+	}
+
+	if err := db.RenameEnvironment(env.ID, newName, branch); err != nil {
+		return err
+	}
+	logger.Log("updated registry")
+
+	movedData := false
+	if dirExists(oldDataDir) {
+		if err := os.Rename(oldDataDir, newDataDir); err != nil {
+			if revertErr := db.RenameEnvironment(env.ID, oldName, env.Branch.String); revertErr != nil {
+				return fmt.Errorf("failed to move data directory: %w (reverting registry also failed: %v)", err, revertErr)
+			}
+			return fmt.Errorf("failed to move data directory: %w", err)
+		}
+		movedData = true
+		logger.Log("moved data directory to %s", newDataDir)
+	}
+
+	oldSession := SessionName(oldName)
+	newSession := SessionName(newName)
+	if SessionExists(oldSession) {
+		if err := RenameSession(oldSession, newSession); err != nil {
+			return revertRename(db, env, oldName, oldDataDir, newDataDir, movedData, err, logger)
+		}
+		vars := []string{"MONO_ENV_NAME=" + newName, "MONO_DATA_DIR=" + newDataDir}
+		if err := SetSessionEnv(newSession, vars); err != nil {
+			if revertErr := RenameSession(newSession, oldSession); revertErr != nil {
+				return fmt.Errorf("%w (reverting tmux session also failed: %v)", err, revertErr)
+			}
+			return revertRename(db, env, oldName, oldDataDir, newDataDir, movedData, err, logger)
+		}
+		logger.Log("renamed tmux session %s to %s", oldSession, newSession)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.Hosts.ApplyDefaults()
+	if cfg.Hosts.Enabled {
+		allocations, err := db.GetAllocations(env.ID)
+		if err != nil {
+			return err
+		}
+		if err := RemoveHostsEntries(cfg.Hosts.File, oldName); err != nil {
+			logger.Log("warning: failed to remove old hosts entries: %v", err)
+		}
+		if err := UpdateHostsFile(cfg.Hosts.File, newName, EnvHostnames(newName, cfg.Hosts.Domain, allocations)); err != nil {
+			logger.Log("warning: failed to update hosts entries: %v", err)
+		}
+	}
+
+	if cfg.TLS.Enabled {
+		if err := renameTLS(db, path, newName, newSession, cfg, logger); err != nil {
+			return err
+		}
+	}
+
+	refreshEnvFiles(path, logger)
+
+	Printf("Environment renamed: %s -> %s\n", oldName, newName)
+	Printf("  Tmux: %s\n", newSession)
+	if env.PortSlot.Valid {
+		Printf("  Ports: unchanged (slot %d)\n", env.PortSlot.Int64)
+	}
+	return nil
+}
+
+func revertRename(db *DB, env *Environment, oldName, oldDataDir, newDataDir string, movedData bool, cause error, logger *FileLogger) error {
+	if movedData {
+		if err := os.Rename(newDataDir, oldDataDir); err != nil {
+			return fmt.Errorf("%w (moving data directory back also failed: %v)", cause, err)
+		}
+		logger.Log("moved data directory back to %s", oldDataDir)
+	}
+	if err := db.RenameEnvironment(env.ID, oldName, env.Branch.String); err != nil {
+		return fmt.Errorf("%w (reverting registry also failed: %v)", cause, err)
+	}
+	logger.Log("reverted rename to %s", oldName)
+	return cause
+}
+
+func renameTLS(db *DB, path, newName, session string, cfg *Config, logger *FileLogger) error {
+	tlsDir, _, err := IssueEnvCertificate(newName, cfg.TLSDomains(), true)
+	if err != nil {
+		return err
+	}
+	logger.Log("reissued tls certificate in %s", tlsDir)
+
+	if SessionExists(session) {
+		tlsVars, err := TLSEnvVars(newName)
+		if err != nil {
+			return err
+		}
+		if err := SetSessionEnv(session, tlsVars); err != nil {
+			return err
+		}
+	}
+
+	env, err := db.GetEnvironmentByPath(path)
+	if err != nil {
+		return err
+	}
+	if !env.DockerProject.Valid || env.DockerProject.String == "" {
+		return nil
+	}
+	containers, err := env.Runtime()
+	if err != nil {
+		return err
+	}
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return err
+	}
+	return regenerateComposeOverride(env, allocations, containers.ContainersRunning(env.DockerProject.String), logger)
+}
+
+func resolveWorktreeDir(repo, branch, dir string) (string, error) {
+	if dir == "" {
+		cfg, err := LoadConfig(repo)
+		if err != nil {
+			return "", fmt.Errorf("failed to load config: %w", err)
+		}
+		envsDir, err := cfg.ResolveEnvsDir(repo)
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(envsDir, strings.ReplaceAll(branch, "/", "-"))
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve worktree path: %w", err)
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("path already exists: %s", dir)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create envs directory: %w", err)
+	}
+	return dir, nil
+}
+
+type CloneOptions struct {
+	Source         string
+	Branch         string
+	Dir            string
+	EphemeralPorts bool
+}
+
+func Clone(opts CloneOptions) (string, error) {
+	if opts.Branch == "" {
+		return "", fmt.Errorf("branch is required")
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	src, err := db.GetEnvironmentByPath(opts.Source)
+	db.Close()
+	if err != nil {
+		return "", fmt.Errorf("environment not found: %s", opts.Source)
 	}
 
-	for i := range cacheEntries {
-		entry := &cacheEntries[i]
-		if !entry.Hit {
-			if err := cm.StoreToCache(*entry); err != nil {
-				logger.Log("warning: failed to store %s to cache: %v", entry.Name, err)
-			} else {
-				logger.Log("stored %s to cache (key: %s)", entry.Name, entry.Key)
-				entry.Hit = true
-			}
-		}
+	repo, _, err := GitMainWorktree(src.Path)
+	if err != nil {
+		return "", err
+	}
+	commit, _, err := GitHead(src.Path)
+	if err != nil {
+		return "", err
 	}
 
-	if !isSimpleMode {
-		if err := CheckDockerAvailable(); err != nil {
-			cleanupWithDB()
-			return err
-		}
+	dir, err := resolveWorktreeDir(repo, opts.Branch, opts.Dir)
+	if err != nil {
+		return "", err
+	}
 
-		composeConfig, err := ParseComposeConfig(composeDir)
-		if err != nil {
-			cleanupWithDB()
-			return fmt.Errorf("failed to parse compose config: %w", err)
-		}
+	logger, err := NewFileLogger(DeriveEnvName(dir))
+	if err != nil {
+		return "", fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
 
-		servicePorts := composeConfig.GetServicePorts()
-		allocations = Allocate(envName, servicePorts)
+	logger.Log("mono clone %s -> %s", src.EnvName(), dir)
 
-		composeProject := composeConfig.Project()
-		ApplyOverrides(composeProject, envName, allocations)
+	if GitRefExists(repo, "refs/heads/"+opts.Branch) {
+		return "", fmt.Errorf("branch already exists: %s", opts.Branch)
+	}
+	if err := AddWorktree(repo, dir, opts.Branch, commit); err != nil {
+		return "", err
+	}
+	Printf("Created worktree for %s at %s (from %s@%.8s)\n", opts.Branch, dir, src.EnvName(), commit)
 
-		monoComposePath := filepath.Join(composeDir, "docker-compose.mono.yml")
-		if err := WriteComposeOverride(monoComposePath, composeProject); err != nil {
-			cleanupWithDB()
-			return fmt.Errorf("failed to write compose override: %w", err)
+	cleanupWorktree := func(err error) error {
+		if rmErr := RemoveWorktree(repo, dir, true); rmErr != nil {
+			return fmt.Errorf("%w (cleanup also failed: %v)", err, rmErr)
 		}
-		logger.Log("generated docker-compose.mono.yml")
+		return err
+	}
 
-		logger.Log("running: docker compose -p %s up -d", dockerProject)
-		stdout := NewLogWriter(logger, "out")
-		stderr := NewLogWriter(logger, "err")
-		if err := StartContainers(dockerProject, composeDir, stdout, stderr); err != nil {
-			cleanupWithDB()
-			return fmt.Errorf("failed to start containers: %w", err)
-		}
-		logger.Log("docker compose completed")
+	cfg, err := LoadConfig(src.Path)
+	if err != nil {
+		return "", cleanupWorktree(fmt.Errorf("failed to load config: %w", err))
+	}
+	cfg.ApplyDefaults(src.Path)
+	if err := cfg.ApplyTemplate(src.Template.String); err != nil {
+		return "", cleanupWorktree(err)
+	}
+	if err := cfg.ApplyProfile(src.Profile.String); err != nil {
+		return "", cleanupWorktree(err)
 	}
 
-	if cfg.Scripts.Setup != "" {
-		scriptEnv := buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars)
-		logger.Log("running setup script: %s", cfg.Scripts.Setup)
-		if err := runScript(path, cfg.Scripts.Setup, scriptEnv, logger); err != nil {
-			if !isSimpleMode {
-				StopContainers(dockerProject, composeDir, true, nil, nil)
+	for _, artifact := range cfg.Build.Artifacts {
+		for _, p := range artifact.Paths {
+			srcArtifact := filepath.Join(src.Path, p)
+			if !dirExists(srcArtifact) {
+				continue
 			}
-			cleanupWithDB()
-			return fmt.Errorf("setup script failed: %w", err)
+			if err := cloneArtifact(artifact, srcArtifact, filepath.Join(dir, p), logger); err != nil {
+				return "", cleanupWorktree(fmt.Errorf("failed to clone %s: %w", artifact.Name, err))
+			}
+			logger.Log("cloned %s from %s", p, src.EnvName())
 		}
-		logger.Log("setup script completed")
 	}
 
-	sessionName := SessionName(envName)
-	sessionEnv := buildScriptEnv(envName, envID, path, rootPath, allocations, cfg.Env, cacheEnvVars)
-	tm := NewTmuxManager(sessionName, path, cfg.Tmux)
-	if err := tm.CreateSession(sessionEnv); err != nil {
-		logger.Log("warning: failed to create tmux session: %v", err)
-	} else {
-		logger.Log("created tmux session %s", sessionName)
+	rootPath := repo
+	if src.RootPath.Valid && src.RootPath.String != "" {
+		rootPath = src.RootPath.String
 	}
 
-	fmt.Printf("Environment initialized: %s\n", envName)
-	fmt.Printf("  Path: %s\n", path)
-	fmt.Printf("  Data: %s\n", dataDir)
-	if !isSimpleMode {
-		fmt.Printf("  Docker: %s\n", dockerProject)
-		for _, alloc := range allocations {
-			fmt.Printf("  %s: %d -> %d\n", alloc.Service, alloc.ContainerPort, alloc.HostPort)
-		}
+	err = Init(dir, InitOptions{
+		ProjectRoot:      rootPath,
+		EphemeralPorts:   opts.EphemeralPorts,
+		SkipCacheRestore: true,
+		Template:         src.Template.String,
+		Profile:          src.Profile.String,
+	})
+	if err != nil {
+		return "", cleanupWorktree(err)
 	}
-	fmt.Printf("  Tmux: %s\n", sessionName)
 
-	return nil
+	return dir, nil
 }
 
-func Destroy(path string) error {
-	project, workspace := DeriveNames(path)
-	envName := fmt.Sprintf("%s-%s", project, workspace)
-	if project == "" || workspace == "" {
-		envName = filepath.Base(path)
+func cloneArtifact(artifact ArtifactConfig, src, dst string, logger *FileLogger) error {
+	switch {
+	case artifact.RestoreStrategy() == RestoreShared && isSymlink(src):
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if !filepath.IsAbs(target) {
+			return os.Symlink(target, dst)
+		}
+		return linkSharedCache(target, dst)
+	case artifact.RestoreStrategy() == RestoreCopy:
+		return copyDir(src, dst)
+	default:
+		return SeedDirectory(src, dst, SeedOptions{
+			ArtifactName:  artifact.Name,
+			Logger:        logger,
+			OperationName: "cloning",
+		})
 	}
+}
 
-	logger, err := NewFileLogger(envName)
+type DestroyOptions struct {
+	KeepWorktree bool
+}
+
+func Destroy(path string, opts DestroyOptions) (err error) {
+	start := time.Now()
+	lock, err := AcquireEnvLock(path, "destroy", 0)
 	if err != nil {
-		return fmt.Errorf("failed to create logger: %w", err)
+		return err
 	}
-	defer logger.Close()
-
-	logger.Log("mono destroy %s", path)
+	defer lock.Release()
 
 	db, err := OpenDB()
 	if err != nil {
@@ -317,11 +1196,18 @@ func Destroy(path string) error {
 	if err != nil {
 		return fmt.Errorf("environment not found: %s", path)
 	}
+	envName := env.EnvName()
 
-	composeDir := path
-	if env.ComposeDir.Valid && env.ComposeDir.String != "" {
-		composeDir = filepath.Join(path, env.ComposeDir.String)
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
 	}
+	defer logger.Close()
+
+	logger.Log("mono destroy %s", path)
+	EmitProgress(ProgressEvent{Env: envName, Operation: "destroy", State: ProgressStart})
+
+	composeDir := env.ComposeDirPath()
 
 	cfg, _ := LoadConfig(path)
 
@@ -330,6 +1216,22 @@ func Destroy(path string) error {
 		rootPath = env.RootPath.String
 	}
 
+	defer func() {
+		state, msg := progressState(err)
+		EmitProgress(ProgressEvent{Env: envName, Operation: "destroy", State: state, Error: msg})
+		e := newEvent(EventDestroy, start, err)
+		e.Env, e.Path, e.Project = envName, path, ComputeProjectID(path)
+		if rootPath != "" {
+			e.Project = ComputeProjectID(rootPath)
+		}
+		if err := RecordEvent(e); err != nil {
+			logger.Log("warning: failed to record destroy event: %v", err)
+		}
+		if auditErr := audit(AuditDestroy, path, envName, 0, err); auditErr != nil {
+			err = errors.Join(err, auditErr)
+		}
+	}()
+
 	cm, err := NewCacheManager()
 	if err != nil {
 		return fmt.Errorf("failed to initialize cache: %w", err)
@@ -337,6 +1239,12 @@ func Destroy(path string) error {
 
 	if cfg != nil {
 		cfg.ApplyDefaults(path)
+		if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+			logger.Log("warning: %v", err)
+		}
+		if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+			logger.Log("warning: %v", err)
+		}
 	}
 
 	if cfg != nil && rootPath != "" {
@@ -353,8 +1261,37 @@ func Destroy(path string) error {
 	}
 	cacheEnvVars = append(cacheEnvVars, "MONO_CACHE_DIR="+cm.LocalCacheDir)
 
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		logger.Log("warning: failed to load port allocations: %v", err)
+	}
+	cacheEnvVars = append(cacheEnvVars, PortEnvVars(allocations)...)
+	if cfg != nil {
+		cacheEnvVars = append(cacheEnvVars, cfg.Kubernetes.EnvVars(envName)...)
+	}
+
+	if cfg != nil {
+		if err := cfg.ResolvePorts(allocations); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	dataDir := filepath.Join(home, ".mono", "data", envName)
+
+	if cfg != nil {
+		payload := PluginPayload{Event: PluginPreDestroy, Env: envName, Path: path, RootPath: rootPath, DataDir: dataDir, Ports: pluginPorts(allocations)}
+		if err := runPlugins(cfg.Plugins, payload, buildScriptEnv(envName, env.ID, path, rootPath, allocations, cfg.Env, cacheEnvVars), logger); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}
+
 	if cfg != nil && cfg.Scripts.Destroy != "" {
-		scriptEnv := buildScriptEnv(envName, env.ID, path, rootPath, nil, cfg.Env, cacheEnvVars)
+		secrets, err := ResolveSecrets(cfg.Env)
+		if err != nil {
+			logger.Log("warning: %v", err)
+		}
+		scriptEnv := withSecrets(buildScriptEnv(envName, env.ID, path, rootPath, allocations, cfg.Env, cacheEnvVars), secrets)
 		logger.Log("running destroy script: %s", cfg.Scripts.Destroy)
 		if err := runScript(path, cfg.Scripts.Destroy, scriptEnv, logger); err != nil {
 			logger.Log("warning: destroy script failed: %v", err)
@@ -363,6 +1300,14 @@ func Destroy(path string) error {
 		}
 	}
 
+	var plan []ServiceStep
+	if cfg != nil {
+		plan, err = environmentPlan(cfg, env)
+		if err != nil {
+			logger.Log("warning: failed to work out the shutdown order: %v", err)
+		}
+	}
+
 	sessionName := SessionName(envName)
 	var tmuxCfg TmuxConfig
 	if cfg != nil {
@@ -370,6 +1315,9 @@ func Destroy(path string) error {
 	}
 	tm := NewTmuxManager(sessionName, path, tmuxCfg)
 	if tm.SessionExists() {
+		if err := stopProcessServices(tm, plan, logger); err != nil {
+			logger.Log("warning: %v", err)
+		}
 		if err := tm.KillSession(); err != nil {
 			logger.Log("warning: failed to kill tmux session: %v", err)
 		} else {
@@ -381,16 +1329,74 @@ func Destroy(path string) error {
 		logger.Log("stopping containers: %s", env.DockerProject.String)
 		stdout := NewLogWriter(logger, "out")
 		stderr := NewLogWriter(logger, "err")
-		if err := StopContainers(env.DockerProject.String, composeDir, true, stdout, stderr); err != nil {
+		containers, err := env.Runtime()
+		if err == nil {
+			if err := stopComposeServices(containers, env.DockerProject.String, composeDir, plan, stdout, stderr); err != nil {
+				logger.Log("warning: %v", err)
+			}
+			err = containers.StopContainers(env.DockerProject.String, composeDir, true, stdout, stderr)
+		}
+		if err != nil {
 			logger.Log("warning: failed to stop containers: %v", err)
 		} else {
 			logger.Log("stopped containers")
 		}
+		if containers != nil {
+			if err := removeTrackedResources(db, env.ID, containers, env.DockerProject.String); err != nil {
+				logger.Log("warning: failed to remove leftover container resources: %v", err)
+			} else {
+				logger.Log("removed leftover container resources")
+			}
+		}
 	}
 
-	home, _ := os.UserHomeDir()
-	dataDir := filepath.Join(home, ".mono", "data", envName)
-	if err := os.RemoveAll(dataDir); err != nil {
+	if cfg != nil && cfg.Kubernetes.Enabled {
+		if err := TeardownKubernetes(cfg.Kubernetes, envName, logger); err != nil {
+			logger.Log("warning: %v", err)
+		}
+	}
+
+	if cfg != nil && cfg.Hosts.Enabled {
+		if err := RemoveHostsEntries(cfg.Hosts.File, envName); err != nil {
+			logger.Log("warning: failed to remove hosts entries: %v", err)
+		} else {
+			logger.Log("removed hosts entries from %s", cfg.Hosts.File)
+		}
+	}
+
+	if cfg != nil && cfg.Dotenv.Enabled {
+		if err := RemoveDotenv(path, cfg.Dotenv); err != nil {
+			logger.Log("warning: %v", err)
+		} else {
+			logger.Log("removed %s", cfg.Dotenv.File)
+		}
+	}
+
+	if cfg != nil && cfg.Direnv.Enabled {
+		if err := RemoveEnvrc(path); err != nil {
+			logger.Log("warning: %v", err)
+		} else {
+			logger.Log("removed %s", EnvrcFile)
+		}
+	}
+
+	if cfg != nil && len(cfg.Shared) > 0 {
+		if err := UnlinkSharedPaths(cfg.Shared, path, rootPath); err != nil {
+			logger.Log("warning: %v", err)
+		} else {
+			logger.Log("removed shared links")
+		}
+	}
+
+	if env.DockerProject.Valid && env.DockerProject.String != "" {
+		if err := os.Remove(filepath.Join(composeDir, "docker-compose.mono.yml")); err != nil && !os.IsNotExist(err) {
+			logger.Log("warning: failed to remove docker-compose.mono.yml: %v", err)
+		} else {
+			logger.Log("removed docker-compose.mono.yml")
+		}
+	}
+
+	if err := removeAllAudited(dataDir); err != nil {
 		logger.Log("warning: failed to remove data directory: %v", err)
 	} else {
 		logger.Log("removed data directory")
@@ -399,46 +1405,75 @@ func Destroy(path string) error {
 	if err := db.DeleteEnvironment(path); err != nil {
 		return fmt.Errorf("failed to delete environment: %w", err)
 	}
-	logger.Log("removed from database")
+	logger.Log("removed from database and released %d port allocation(s)", len(allocations))
 
-	fmt.Printf("Environment destroyed: %s\n", envName)
+	Printf("Environment destroyed: %s\n", envName)
+
+	if !opts.KeepWorktree {
+		if err := removeEnvWorktree(path, envName, logger); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func Run(path string) error {
-	project, workspace := DeriveNames(path)
-	envName := fmt.Sprintf("%s-%s", project, workspace)
-	if project == "" || workspace == "" {
-		envName = filepath.Base(path)
+func removeEnvWorktree(path, envName string, logger *FileLogger) error {
+	mainRepo, linked, err := GitMainWorktree(path)
+	if err != nil {
+		logger.Log("skipping worktree removal: %v", err)
+		return nil
+	}
+	if !linked {
+		logger.Log("skipping worktree removal: %s is the main checkout", path)
+		return nil
 	}
 
-	logger, err := NewFileLogger(envName)
+	err = RemoveWorktree(mainRepo, path, false)
+	if auditErr := audit(AuditRemove, path, envName, 0, err); auditErr != nil {
+		return auditErr
+	}
 	if err != nil {
-		return fmt.Errorf("failed to create logger: %w", err)
+		logger.Log("warning: %v", err)
+		return fmt.Errorf("environment destroyed but worktree was kept: %w", err)
 	}
-	defer logger.Close()
-
-	logger.Log("mono run %s", path)
+	logger.Log("removed worktree %s", path)
+	Printf("Removed worktree: %s\n", path)
+	return nil
+}
 
+func Run(path string) error {
 	db, err := OpenDB()
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	defer db.Close()
 
-	_, err = db.GetEnvironmentByPath(path)
+	env, err := db.GetEnvironmentByPath(path)
 	if err != nil {
 		return fmt.Errorf("environment not found: %s", path)
 	}
+	envName := env.EnvName()
 
-	cfg, err := LoadConfig(path)
+	logger, err := NewFileLogger(envName)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	logger.Log("mono run %s", path)
+	if err := db.TouchEnvironment(env.ID); err != nil {
+		logger.Log("warning: %v", err)
+	}
+
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return err
 	}
-	cfg.Tmux.ApplyDefaults()
+	cfg := ec.Config
+	services := cfg.SelectedProcessServices()
 
-	if cfg.Scripts.Run == "" {
-		return fmt.Errorf("no run script defined in mono.yml")
+	if cfg.Scripts.Run == "" && len(services) == 0 {
+		return fmt.Errorf("no run script or services defined in mono.yml")
 	}
 
 	sessionName := SessionName(envName)
@@ -452,26 +1487,72 @@ func Run(path string) error {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 	dataDir := filepath.Join(home, ".mono", "data", envName)
-	scriptPath := filepath.Join(dataDir, "run.sh")
 
-	if err := os.WriteFile(scriptPath, []byte(cfg.Scripts.Run), 0755); err != nil {
-		return fmt.Errorf("failed to write run script: %w", err)
+	if ec.Project != nil {
+		containers, err := env.Runtime()
+		if err != nil {
+			return err
+		}
+		secrets, err := ResolveSecrets(cfg.Env)
+		if err != nil {
+			return err
+		}
+		if err := waitForDependencies(cfg, ec.Project, containers, env.DockerProject.String, ec.Allocations, withSecrets(ec.Vars, secrets), os.Stderr, logger); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Scripts.Run != "" {
+		scriptPath := filepath.Join(dataDir, "run.sh")
+		if err := os.WriteFile(scriptPath, []byte(cfg.Scripts.Run), 0755); err != nil {
+			return fmt.Errorf("failed to write run script: %w", err)
+		}
+
+		logger.Log("running script via tmux (on_conflict: %s)", cfg.Tmux.Run.OnConflict)
+		if err := tm.Run(scriptPath); err != nil {
+			return fmt.Errorf("failed to run script: %w", err)
+		}
 	}
 
-	logger.Log("running script via tmux (on_conflict: %s)", cfg.Tmux.Run.OnConflict)
-	if err := tm.Run(scriptPath); err != nil {
-		return fmt.Errorf("failed to run script: %w", err)
+	if len(services) > 0 {
+		if err := runServices(tm, ec, dataDir, logger); err != nil {
+			return err
+		}
 	}
 
-	fmt.Printf("Session: %s\n", sessionName)
+	Printf("Session: %s\n", sessionName)
+	for _, svc := range services {
+		status := "started"
+		if svc.HealthCheck.enabled() {
+			status = "healthy"
+		}
+		if port := servicePort(svc, ec.Allocations); port != 0 {
+			Printf("  %s: %s on port %d\n", svc.Name, status, port)
+			continue
+		}
+		Printf("  %s: %s\n", svc.Name, status)
+	}
 	return nil
 }
 
+const (
+	CacheFresh   = "fresh"
+	CacheStale   = "stale"
+	CacheNone    = "none"
+	CacheUnknown = "unknown"
+)
+
 type EnvironmentStatus struct {
-	Name          string
-	Path          string
-	TmuxRunning   bool
-	DockerRunning bool
+	Name          string            `json:"name"`
+	Path          string            `json:"path"`
+	Branch        string            `json:"branch,omitempty"`
+	TmuxRunning   bool              `json:"tmux_running"`
+	DockerRunning bool              `json:"docker_running"`
+	PortSlot      *int              `json:"port_slot,omitempty"`
+	Cache         string            `json:"cache"`
+	CreatedAt     time.Time         `json:"created_at"`
+	LastUsed      *time.Time        `json:"last_used,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
 }
 
 func List() ([]EnvironmentStatus, error) {
@@ -486,33 +1567,88 @@ func List() ([]EnvironmentStatus, error) {
 		return nil, fmt.Errorf("failed to list environments: %w", err)
 	}
 
+	labels, err := db.GetAllLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
 	var statuses []EnvironmentStatus
 	for _, env := range environments {
-		project, workspace := DeriveNames(env.Path)
-		envName := fmt.Sprintf("%s-%s", project, workspace)
-		if project == "" || workspace == "" {
-			envName = filepath.Base(env.Path)
-		}
+		envName := env.EnvName()
 
 		sessionName := SessionName(envName)
 		tmuxRunning := SessionExists(sessionName)
 
 		dockerRunning := false
 		if env.DockerProject.Valid && env.DockerProject.String != "" {
-			dockerRunning = ContainersRunning(env.DockerProject.String)
+			containers, err := env.Runtime()
+			if err != nil {
+				return nil, err
+			}
+			dockerRunning = containers.ContainersRunning(env.DockerProject.String)
 		}
 
-		statuses = append(statuses, EnvironmentStatus{
+		status := EnvironmentStatus{
 			Name:          envName,
 			Path:          env.Path,
+			Branch:        env.Branch.String,
 			TmuxRunning:   tmuxRunning,
 			DockerRunning: dockerRunning,
-		})
+			Cache:         cacheFreshness(cm, env),
+			CreatedAt:     env.CreatedAt,
+			Labels:        labels[env.ID],
+		}
+		if env.PortSlot.Valid {
+			slot := int(env.PortSlot.Int64)
+			status.PortSlot = &slot
+		}
+		if env.LastUsed.Valid {
+			lastUsed := env.LastUsed.Time
+			status.LastUsed = &lastUsed
+		}
+		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
 }
 
+func cacheFreshness(cm *CacheManager, env *Environment) string {
+	if !env.RootPath.Valid || env.RootPath.String == "" {
+		return CacheNone
+	}
+
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return CacheUnknown
+	}
+	cfg.ApplyDefaults(env.Path)
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return CacheUnknown
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return CacheUnknown
+	}
+	if len(cfg.Build.Artifacts) == 0 {
+		return CacheNone
+	}
+
+	entries, err := cm.PrepareArtifactCache(cfg.Build.Artifacts, env.RootPath.String, env.Path)
+	if err != nil {
+		return CacheUnknown
+	}
+	for _, entry := range entries {
+		if !entry.Hit {
+			return CacheStale
+		}
+	}
+	return CacheFresh
+}
+
 func Attach(path string) error {
 	db, err := OpenDB()
 	if err != nil {
@@ -524,12 +1660,10 @@ func Attach(path string) error {
 
 	env, err := db.GetEnvironmentByPath(path)
 	if err == nil {
-		project, workspace := DeriveNames(env.Path)
-		envName := fmt.Sprintf("%s-%s", project, workspace)
-		if project == "" || workspace == "" {
-			envName = filepath.Base(env.Path)
+		sessionName = SessionName(env.EnvName())
+		if err := db.TouchEnvironment(env.ID); err != nil {
+			return err
 		}
-		sessionName = SessionName(envName)
 	} else {
 		sessions, err := ListMonoSessions()
 		if err != nil {
@@ -609,9 +1743,9 @@ func buildScriptEnv(envName string, envID int64, envPath, rootPath string, alloc
 	}
 
 	for _, alloc := range allocations {
-		varName := "MONO_" + strings.ToUpper(strings.ReplaceAll(alloc.Service, "-", "_")) + "_PORT"
-		monoEnvMap[varName] = fmt.Sprintf("%d", alloc.HostPort)
+		monoEnvMap[PortEnvVarName(alloc.Service)] = fmt.Sprintf("%d", alloc.HostPort)
 	}
+	monoEnvMap["MONO_PORTS"] = formatPortMap(allocations)
 
 	var result []string
 	for key, value := range monoEnvMap {
@@ -634,6 +1768,36 @@ func buildScriptEnv(envName string, envID int64, envPath, rootPath string, alloc
 	return result
 }
 
+func formatPortMap(allocations []Allocation) string {
+	ports := AllocationsToMap(allocations)
+	services := make([]string, 0, len(ports))
+	for service := range ports {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	pairs := make([]string, 0, len(services))
+	for _, service := range services {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", service, ports[service]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func runHook(name, script, workDir string, envVars []string, retry RetryPolicy, logger *FileLogger) error {
+	if script == "" {
+		return nil
+	}
+	logger.Log("running %s hook: %s", name, script)
+	err := withRetry(name, retry, logger, func() error {
+		return runScript(workDir, script, envVars, logger)
+	})
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	logger.Log("%s hook completed", name)
+	return nil
+}
+
 func runScript(workDir, script string, envVars []string, logger *FileLogger) error {
 	stdout := NewLogWriter(logger, "out")
 	stderr := NewLogWriter(logger, "err")
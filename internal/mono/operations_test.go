@@ -0,0 +1,182 @@
+package mono
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitPostInitHookFailureKeepsEnvironment(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	root := t.TempDir()
+	path := filepath.Join(root, "post-init-hook-failure")
+	writeBuildxFixture(t, path, map[string]string{
+		"mono.yml": "hooks:\n  post_init: echo post-init-ran > hook.out; exit 3\n",
+	})
+	t.Cleanup(func() {
+		session := SessionName(DeriveEnvName(path))
+		if SessionExists(session) {
+			if err := KillSession(session); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	if err := Init(path, InitOptions{ProjectRoot: root, SkipCacheRestore: true}); err != nil {
+		t.Fatalf("Init() = %v, want the post_init failure reported as a warning", err)
+	}
+	data, err := os.ReadFile(filepath.Join(path, "hook.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "post-init-ran" {
+		t.Errorf("hook output = %q", data)
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exists, err := db.EnvironmentExists(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Error("environment was unregistered after the post_init hook failed")
+	}
+
+	logs, err := os.ReadFile(filepath.Join(home, ".mono", "mono.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(logs), "warning: post_init hook failed") || !strings.Contains(string(logs), "is up") {
+		t.Errorf("log does not warn that the environment is up:\n%s", logs)
+	}
+}
+
+func TestDestroyRemovesComposeWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.email=t@t", "-c", "user.name=t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	writeBuildxFixture(t, repo, map[string]string{"docker-compose.yml": "services: {}\n"})
+	git(repo, "init", "-q", "-b", "main")
+	git(repo, "add", "-A")
+	git(repo, "commit", "-q", "-m", "base")
+	path := filepath.Join(filepath.Dir(repo), "compose-destroy")
+	git(repo, "worktree", "add", "-q", "-b", "feature", path)
+	writeBuildxFixture(t, path, map[string]string{"docker-compose.mono.yml": "services: {}\n"})
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(path, "mono-compose-destroy", repo, "", "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RenameEnvironment(envID, "renamed-env", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	if err := Destroy(path, DestroyOptions{}); err != nil {
+		t.Fatalf("Destroy() = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("worktree %s still exists after destroy (stat err = %v)", path, err)
+	}
+
+	entries, err := ReadAudit(AuditFilter{Action: AuditRemove})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, e := range entries {
+		if e.Target == path {
+			found = true
+			if e.Env != "renamed-env" {
+				t.Errorf("worktree removal audited for env %q, want the stored name renamed-env", e.Env)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no audit entry for the worktree removal in %+v", entries)
+	}
+}
+
+func TestRenameRevertsWhenSessionRenameFails(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux not installed")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	path := filepath.Join(t.TempDir(), "rename-revert-old")
+	writeBuildxFixture(t, path, map[string]string{"mono.yml": "{}\n"})
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(path, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	oldDataDir := filepath.Join(home, ".mono", "data", "rename-revert-old")
+	if err := os.MkdirAll(oldDataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, session := range []string{SessionName("rename-revert-old"), SessionName("rename-revert-new")} {
+		if err := CreateSession(session, path, nil, "sh"); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := KillSession(session); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if err := Rename(path, "rename-revert-new"); err == nil {
+		t.Fatal("Rename() = nil, want the tmux session conflict reported")
+	}
+
+	if !dirExists(oldDataDir) {
+		t.Errorf("data directory %s was not moved back", oldDataDir)
+	}
+	if dirExists(filepath.Join(home, ".mono", "data", "rename-revert-new")) {
+		t.Error("new data directory left behind after a failed rename")
+	}
+	db, err = OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	env, err := db.GetEnvironmentByPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != envID || env.EnvName() != "rename-revert-old" {
+		t.Errorf("registry name = %q after a failed rename, want rename-revert-old", env.EnvName())
+	}
+}
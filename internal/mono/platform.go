@@ -0,0 +1,78 @@
+package mono
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func platformMatches(when, goos, goarch string) (bool, error) {
+	for _, alt := range strings.Split(when, ",") {
+		alt = strings.TrimSpace(alt)
+		osName, arch, hasArch := strings.Cut(alt, "/")
+		if osName == "" || (hasArch && (arch == "" || strings.Contains(arch, "/"))) {
+			return false, fmt.Errorf("invalid platform %q (expected os, os/arch or */arch)", alt)
+		}
+		if osName != "*" && osName != goos {
+			continue
+		}
+		if hasArch && arch != "*" && arch != goarch {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func applyPlatformNodes(file string, own *yaml.Node) (*yaml.Node, error) {
+	var platforms *yaml.Node
+	for i := 0; i+1 < len(own.Content); i += 2 {
+		if own.Content[i].Value == "platforms" {
+			platforms = own.Content[i+1]
+		}
+	}
+	if platforms == nil {
+		return own, nil
+	}
+
+	merged := own
+	for _, item := range platforms.Content {
+		block := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		when := ""
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			switch key, value := item.Content[i], item.Content[i+1]; key.Value {
+			case "when":
+				when = value.Value
+			case "services":
+			default:
+				block.Content = append(block.Content, key, value)
+			}
+		}
+		ok, err := platformMatches(when, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s:%d: %w", file, item.Line, err)
+		}
+		if ok {
+			merged = mergeConfigNodes(merged, block)
+		}
+	}
+	return merged, nil
+}
+
+func (c *Config) applyPlatformServices() error {
+	for _, p := range c.Platforms {
+		ok, err := platformMatches(p.When, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := c.selectServices(p.Services); err != nil {
+			return fmt.Errorf("platform %s: %w", p.When, err)
+		}
+	}
+	return nil
+}
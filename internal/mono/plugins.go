@@ -0,0 +1,217 @@
+package mono
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PluginPrefix  = "mono-plugin-"
+	pluginTimeout = 5 * time.Minute
+
+	PluginPostRestore   = "post-restore"
+	PluginPreDestroy    = "pre-destroy"
+	PluginPortAllocated = "port-allocated"
+)
+
+var PluginEvents = []string{PluginPostRestore, PluginPreDestroy, PluginPortAllocated}
+
+type PluginConfig struct {
+	Name   string   `yaml:"name" mono:"required"`
+	Events []string `yaml:"events"`
+}
+
+func (p PluginConfig) Executable() string {
+	return PluginPrefix + p.Name
+}
+
+func (p PluginConfig) handles(event string) bool {
+	return len(p.Events) == 0 || slices.Contains(p.Events, event)
+}
+
+func (c *Config) ValidatePlugins() error {
+	seen := make(map[string]bool)
+	for _, p := range c.Plugins {
+		if p.Name == "" || strings.ContainsRune(p.Name, os.PathSeparator) {
+			return fmt.Errorf("invalid plugin name %q", p.Name)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("plugin %s is declared more than once", p.Name)
+		}
+		seen[p.Name] = true
+		for _, event := range p.Events {
+			if !slices.Contains(PluginEvents, event) {
+				return fmt.Errorf("invalid event %q for plugin %s (expected one of %s)", event, p.Name, strings.Join(PluginEvents, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) CheckPluginsInstalled() error {
+	for _, p := range c.Plugins {
+		if _, err := exec.LookPath(p.Executable()); err != nil {
+			return fmt.Errorf("plugin %s: %s not found on PATH", p.Name, p.Executable())
+		}
+	}
+	return nil
+}
+
+type PluginArtifact struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	Hit         bool   `json:"hit"`
+	Approximate bool   `json:"approximate,omitempty"`
+}
+
+type PluginPort struct {
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	Protocol      string `json:"protocol"`
+	Count         int    `json:"count"`
+}
+
+type PluginPayload struct {
+	Event     string           `json:"event"`
+	Env       string           `json:"env"`
+	Path      string           `json:"path"`
+	RootPath  string           `json:"root_path,omitempty"`
+	DataDir   string           `json:"data_dir"`
+	Artifacts []PluginArtifact `json:"artifacts,omitempty"`
+	Ports     []PluginPort     `json:"ports,omitempty"`
+}
+
+func pluginArtifacts(entries []ArtifactCacheEntry, approximate []string) []PluginArtifact {
+	result := make([]PluginArtifact, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, PluginArtifact{
+			Name:        e.Name,
+			Key:         e.Key,
+			Hit:         e.Hit,
+			Approximate: slices.Contains(approximate, e.Name),
+		})
+	}
+	return result
+}
+
+func pluginPorts(allocations []Allocation) []PluginPort {
+	result := make([]PluginPort, 0, len(allocations))
+	for _, a := range allocations {
+		result = append(result, PluginPort{
+			Service:       a.Service,
+			ContainerPort: a.ContainerPort,
+			HostPort:      a.HostPort,
+			Protocol:      a.Protocol,
+			Count:         a.Size(),
+		})
+	}
+	return result
+}
+
+func runPlugins(plugins []PluginConfig, payload PluginPayload, envVars []string, logger *FileLogger) error {
+	var selected []PluginConfig
+	for _, p := range plugins {
+		if p.handles(payload.Event) {
+			selected = append(selected, p)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", payload.Event, err)
+	}
+
+	for _, p := range selected {
+		logger.Log("running plugin %s for %s", p.Name, payload.Event)
+		err := Command(p.Executable(), payload.Event).
+			Dir(payload.Path).
+			Env(append(os.Environ(), envVars...)).
+			Stdin(bytes.NewReader(data)).
+			Stdout(NewLogWriter(logger, "out")).
+			Stderr(NewLogWriter(logger, "err")).
+			Timeout(pluginTimeout).
+			Run()
+		if err != nil {
+			return fmt.Errorf("plugin %s failed on %s: %w", p.Name, payload.Event, err)
+		}
+		logger.Log("plugin %s completed", p.Name)
+	}
+	return nil
+}
+
+type DiscoveredPlugin struct {
+	Name string
+	Path string
+}
+
+func DiscoverPlugins() []DiscoveredPlugin {
+	seen := make(map[string]bool)
+	var plugins []DiscoveredPlugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), PluginPrefix)
+			if !ok || name == "" || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, DiscoveredPlugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+func notifyPortsAllocated(path string, logger *FileLogger) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		logger.Log("warning: failed to run port-allocated plugins: %v", err)
+		return
+	}
+	if len(ec.Config.Plugins) == 0 {
+		return
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		logger.Log("warning: failed to run port-allocated plugins: %v", err)
+		return
+	}
+	envName := ec.Env.EnvName()
+	payload := PluginPayload{
+		Event:   PluginPortAllocated,
+		Env:     envName,
+		Path:    ec.Env.Path,
+		DataDir: filepath.Join(home, ".mono", "data", envName),
+		Ports:   pluginPorts(ec.Allocations),
+	}
+	if ec.Env.RootPath.Valid {
+		payload.RootPath = ec.Env.RootPath.String
+	}
+	if err := runPlugins(ec.Config.Plugins, payload, ec.Vars, logger); err != nil {
+		logger.Log("warning: %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package mono
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPlugins(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	bin := t.TempDir()
+	out := t.TempDir()
+	script := "#!/bin/sh\necho \"$1 $MONO_ENV_NAME\" > " + filepath.Join(out, "args") + "\ncat > " + filepath.Join(out, "payload") + "\n"
+	if err := os.WriteFile(filepath.Join(bin, "mono-plugin-record"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "mono-plugin-fail"), []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "mono-plugin-notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	logger, err := NewFileLogger("plugin-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	cfg := Config{Plugins: []PluginConfig{
+		{Name: "record"},
+		{Name: "fail", Events: []string{PluginPreDestroy}},
+	}}
+	if err := cfg.ValidatePlugins(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.CheckPluginsInstalled(); err != nil {
+		t.Fatal(err)
+	}
+
+	envPath := t.TempDir()
+	payload := PluginPayload{
+		Event: PluginPortAllocated,
+		Env:   "proj-feature",
+		Path:  envPath,
+		Ports: pluginPorts([]Allocation{{Service: "api", ContainerPort: 8080, HostPort: 19080, Protocol: ProtocolTCP}}),
+	}
+	if err := runPlugins(cfg.Plugins, payload, []string{"MONO_ENV_NAME=proj-feature"}, logger); err != nil {
+		t.Fatalf("runPlugins failed: %v", err)
+	}
+
+	args, err := os.ReadFile(filepath.Join(out, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(args)); got != "port-allocated proj-feature" {
+		t.Errorf("args = %q", got)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got PluginPayload
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid payload %s: %v", data, err)
+	}
+	if got.Event != PluginPortAllocated || got.Env != "proj-feature" || len(got.Ports) != 1 || got.Ports[0].HostPort != 19080 || got.Ports[0].Count != 1 {
+		t.Errorf("payload = %+v", got)
+	}
+
+	payload.Event = PluginPreDestroy
+	if err := runPlugins(cfg.Plugins, payload, nil, logger); err == nil || !strings.Contains(err.Error(), "plugin fail failed on pre-destroy") {
+		t.Errorf("err = %v, want the failing plugin to be reported", err)
+	}
+
+	discovered := DiscoverPlugins()
+	var names []string
+	for _, d := range discovered {
+		if filepath.Dir(d.Path) == bin {
+			names = append(names, d.Name)
+		}
+	}
+	if strings.Join(names, ",") != "fail,record" {
+		t.Errorf("discovered = %v, want [fail record]", names)
+	}
+
+	missing := Config{Plugins: []PluginConfig{{Name: "absent"}}}
+	if err := missing.CheckPluginsInstalled(); err == nil {
+		t.Error("expected an error for a plugin missing from PATH")
+	}
+}
+
+func TestValidatePlugins(t *testing.T) {
+	tests := []struct {
+		name    string
+		plugins []PluginConfig
+	}{
+		{"duplicate", []PluginConfig{{Name: "slack"}, {Name: "slack"}}},
+		{"unknown event", []PluginConfig{{Name: "slack", Events: []string{"post-init"}}}},
+		{"path in name", []PluginConfig{{Name: "../slack"}}},
+	}
+	for _, tt := range tests {
+		cfg := Config{Plugins: tt.plugins}
+		if err := cfg.ValidatePlugins(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package mono
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ConflictHostPort = "port"
+	ConflictSlot     = "slot"
+)
+
+type PortOwner struct {
+	EnvID     int64
+	EnvName   string
+	Path      string
+	Service   string
+	CreatedAt time.Time
+}
+
+type PortConflict struct {
+	Kind   string
+	Value  int
+	Owners []PortOwner
+}
+
+func (c PortConflict) String() string {
+	var owners []string
+	for _, o := range c.Owners {
+		if o.Service != "" {
+			owners = append(owners, fmt.Sprintf("%s (%s)", o.EnvName, o.Service))
+		} else {
+			owners = append(owners, o.EnvName)
+		}
+	}
+	return fmt.Sprintf("%s %d: %s", c.Kind, c.Value, strings.Join(owners, ", "))
+}
+
+type PortReassignment struct {
+	EnvName     string
+	Ephemeral   bool
+	OldSlot     int
+	NewSlot     int
+	Allocations []Allocation
+}
+
+func FindPortConflicts() ([]PortConflict, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	allocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	return detectPortConflicts(envs, allocations), nil
+}
+
+func detectPortConflicts(envs []*Environment, allocations map[int64][]Allocation) []PortConflict {
+	byPort := make(map[int][]PortOwner)
+	bySlot := make(map[int][]PortOwner)
+
+	for _, env := range envs {
+		owner := PortOwner{
+			EnvID:     env.ID,
+			EnvName:   env.EnvName(),
+			Path:      env.Path,
+			CreatedAt: env.CreatedAt,
+		}
+
+		if env.PortSlot.Valid {
+			slot := int(env.PortSlot.Int64)
+			bySlot[slot] = append(bySlot[slot], owner)
+		}
+
+		for _, a := range allocations[env.ID] {
+			o := owner
+			o.Service = a.Service
+			for p := a.HostPort; p <= a.LastHostPort(); p++ {
+				byPort[p] = append(byPort[p], o)
+			}
+		}
+	}
+
+	var conflicts []PortConflict
+	for slot, owners := range bySlot {
+		if len(owners) > 1 {
+			conflicts = append(conflicts, PortConflict{Kind: ConflictSlot, Value: slot, Owners: sortOwners(owners)})
+		}
+	}
+	for port, owners := range byPort {
+		if len(owners) > 1 {
+			conflicts = append(conflicts, PortConflict{Kind: ConflictHostPort, Value: port, Owners: sortOwners(owners)})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind == ConflictSlot
+		}
+		return conflicts[i].Value < conflicts[j].Value
+	})
+
+	return conflicts
+}
+
+func sortOwners(owners []PortOwner) []PortOwner {
+	sort.Slice(owners, func(i, j int) bool {
+		if !owners[i].CreatedAt.Equal(owners[j].CreatedAt) {
+			return owners[i].CreatedAt.Before(owners[j].CreatedAt)
+		}
+		if owners[i].EnvID != owners[j].EnvID {
+			return owners[i].EnvID < owners[j].EnvID
+		}
+		return owners[i].Service < owners[j].Service
+	})
+	return owners
+}
+
+func newerOwners(conflicts []PortConflict) []int64 {
+	seen := make(map[int64]bool)
+	var result []int64
+	for _, c := range conflicts {
+		if len(c.Owners) == 0 {
+			continue
+		}
+		oldest := c.Owners[0].EnvID
+		for _, o := range c.Owners[1:] {
+			if o.EnvID == oldest || seen[o.EnvID] {
+				continue
+			}
+			seen[o.EnvID] = true
+			result = append(result, o.EnvID)
+		}
+	}
+	return result
+}
+
+func portsUsedByOthers(envs []*Environment, allocations map[int64][]Allocation, envID int64) (map[int]bool, map[int]bool) {
+	usedSlots := make(map[int]bool)
+	usedPorts := make(map[int]bool)
+	for _, other := range envs {
+		if other.ID == envID {
+			continue
+		}
+		if other.PortSlot.Valid {
+			usedSlots[int(other.PortSlot.Int64)] = true
+		}
+		for _, a := range allocations[other.ID] {
+			for p := a.HostPort; p <= a.LastHostPort(); p++ {
+				usedPorts[p] = true
+			}
+		}
+	}
+	return usedSlots, usedPorts
+}
+
+func ephemeralReserved(reserved, usedSlots, usedPorts map[int]bool) map[int]bool {
+	excluded := make(map[int]bool, len(reserved)+len(usedPorts)+len(usedSlots)*PortRangePerWorktree)
+	for port := range reserved {
+		excluded[port] = true
+	}
+	for port := range usedPorts {
+		excluded[port] = true
+	}
+	for slot := range usedSlots {
+		base := SlotBasePort(slot)
+		for offset := 0; offset < PortRangePerWorktree; offset++ {
+			excluded[base+offset] = true
+		}
+	}
+	return excluded
+}
+
+func (db *DB) ephemeralReserved(envID int64, reserved map[int]bool) (map[int]bool, error) {
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	allAllocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+	usedSlots, usedPorts := portsUsedByOthers(envs, allAllocations, envID)
+	return ephemeralReserved(reserved, usedSlots, usedPorts), nil
+}
+
+func (db *DB) allocateFreeSlot(envID int64, envName string, servicePorts map[string][]PortRequest, reserved map[int]bool) (int, []Allocation, error) {
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return 0, nil, err
+	}
+	allAllocations, err := db.GetAllAllocations()
+	if err != nil {
+		return 0, nil, err
+	}
+	usedSlots, usedPorts := portsUsedByOthers(envs, allAllocations, envID)
+	return findFreeSlot(PortSlot(envName), servicePorts, usedSlots, usedPorts, reserved)
+}
+
+func (db *DB) allocateInitPorts(envID int64, envName, mode string, servicePorts map[string][]PortRequest, reserved map[int]bool, logger *FileLogger) ([]Allocation, error) {
+	lock, err := AcquirePortsLock()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	var allocations []Allocation
+	if mode == PortModeEphemeral {
+		excluded, err := db.ephemeralReserved(envID, reserved)
+		if err != nil {
+			return nil, err
+		}
+		allocations, err = AllocateEphemeral(servicePorts, excluded)
+		if err != nil {
+			return nil, err
+		}
+		logger.Log("allocated %d ephemeral port(s)", len(allocations))
+	} else {
+		var slot int
+		slot, allocations, err = db.allocateFreeSlot(envID, envName, servicePorts, reserved)
+		if err != nil {
+			return nil, err
+		}
+		if want := PortSlot(envName); slot != want {
+			logger.Log("port slot %d is used by another environment, using slot %d", want, slot)
+		}
+		if err := db.SetPortSlot(envID, slot); err != nil {
+			return nil, err
+		}
+		for _, alloc := range allocations {
+			if alloc.InUse() {
+				logger.Log("warning: host port for %s is already in use", alloc.String())
+			}
+		}
+	}
+
+	if err := db.SaveAllocations(envID, allocations); err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+func findFreeSlot(start int, servicePorts map[string][]PortRequest, usedSlots map[int]bool, usedPorts map[int]bool, reserved map[int]bool) (int, []Allocation, error) {
+	var lastErr error
+	for i := 0; i < MaxPortSlots; i++ {
+		slot := (start + i) % MaxPortSlots
+		if usedSlots[slot] {
+			continue
+		}
+		allocations, err := AllocateSlot(slot, servicePorts, reserved)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		free := true
+		for _, a := range allocations {
+			for p := a.HostPort; p <= a.LastHostPort(); p++ {
+				if usedPorts[p] {
+					free = false
+					break
+				}
+			}
+		}
+		if free {
+			return slot, allocations, nil
+		}
+	}
+	if lastErr != nil {
+		return 0, nil, fmt.Errorf("no free port slot available: %w", lastErr)
+	}
+	return 0, nil, fmt.Errorf("no free port slot available")
+}
+
+func FixPortConflicts(conflicts []PortConflict) ([]PortReassignment, error) {
+	lock, err := AcquirePortsLock()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	allAllocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	envByID := make(map[int64]*Environment)
+	for _, env := range envs {
+		envByID[env.ID] = env
+	}
+
+	var reassignments []PortReassignment
+	for _, envID := range newerOwners(conflicts) {
+		env, ok := envByID[envID]
+		if !ok {
+			continue
+		}
+
+		usedSlots, usedPorts := portsUsedByOthers(envs, allAllocations, envID)
+
+		envName := env.EnvName()
+		servicePorts := AllocationsToServicePorts(allAllocations[envID])
+
+		cfg, err := LoadConfig(env.Path)
+		if err != nil {
+			return reassignments, fmt.Errorf("failed to load config for %s: %w", envName, err)
+		}
+		reserved, err := cfg.Ports.ReservedPorts()
+		if err != nil {
+			return reassignments, err
+		}
+
+		if !env.PortSlot.Valid {
+			allocations, err := AllocateEphemeral(servicePorts, ephemeralReserved(reserved, usedSlots, usedPorts))
+			if err != nil {
+				return reassignments, fmt.Errorf("failed to reassign %s: %w", envName, err)
+			}
+			if err := db.SaveAllocations(envID, allocations); err != nil {
+				return reassignments, err
+			}
+			allAllocations[envID] = allocations
+
+			if err := applyAllocations(env, envName, allocations); err != nil {
+				return reassignments, fmt.Errorf("failed to apply new ports to %s: %w", envName, err)
+			}
+
+			reassignments = append(reassignments, PortReassignment{
+				EnvName:     envName,
+				Ephemeral:   true,
+				Allocations: allocations,
+			})
+			continue
+		}
+
+		oldSlot := int(env.PortSlot.Int64)
+		usedSlots[oldSlot] = true
+
+		newSlot, allocations, err := findFreeSlot(oldSlot, servicePorts, usedSlots, usedPorts, reserved)
+		if err != nil {
+			return reassignments, fmt.Errorf("failed to reassign %s: %w", envName, err)
+		}
+
+		if err := db.SetPortSlot(envID, newSlot); err != nil {
+			return reassignments, err
+		}
+		if err := db.SaveAllocations(envID, allocations); err != nil {
+			return reassignments, err
+		}
+
+		env.PortSlot.Int64 = int64(newSlot)
+		env.PortSlot.Valid = true
+		allAllocations[envID] = allocations
+
+		if err := applyAllocations(env, envName, allocations); err != nil {
+			return reassignments, fmt.Errorf("failed to apply new ports to %s: %w", envName, err)
+		}
+
+		reassignments = append(reassignments, PortReassignment{
+			EnvName:     envName,
+			OldSlot:     oldSlot,
+			NewSlot:     newSlot,
+			Allocations: allocations,
+		})
+	}
+
+	return reassignments, nil
+}
+
+func applyAllocations(env *Environment, envName string, allocations []Allocation) error {
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	if err := regenerateComposeOverride(env, allocations, true, logger); err != nil {
+		return err
+	}
+
+	sessionName := SessionName(envName)
+	if SessionExists(sessionName) {
+		var vars []string
+		for _, a := range allocations {
+			vars = append(vars, fmt.Sprintf("%s=%d", PortEnvVarName(a.Service), a.HostPort))
+		}
+		vars = append(vars, PortEnvVars(allocations)...)
+		if err := SetSessionEnv(sessionName, vars); err != nil {
+			return err
+		}
+		logger.Log("updated port variables in tmux session %s", sessionName)
+	}
+
+	refreshEnvFiles(env.Path, logger)
+	notifyPortsAllocated(env.Path, logger)
+
+	return nil
+}
+
+func regenerateComposeOverride(env *Environment, allocations []Allocation, restart bool, logger *FileLogger) error {
+	if !env.DockerProject.Valid || env.DockerProject.String == "" {
+		return nil
+	}
+	composeDir := env.ComposeDirPath()
+	composeConfig, err := ParseComposeConfig(composeDir)
+	if err != nil {
+		return fmt.Errorf("failed to parse compose config: %w", err)
+	}
+
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return err
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return err
+	}
+	if err := cfg.SelectCompose(composeConfig); err != nil {
+		return err
+	}
+
+	composeProject := composeConfig.Project()
+	if err := ApplyOverrides(composeProject, env.DockerProject.String, allocations); err != nil {
+		return err
+	}
+	MountSharedReadOnly(composeProject, cfg.Shared, env.Path)
+	if cfg.TLS.Enabled {
+		cfg.TLS.ApplyDefaults()
+		tlsDir, err := EnvTLSDir(env.EnvName())
+		if err != nil {
+			return err
+		}
+		MountTLS(composeProject, tlsDir, cfg.TLS.Mount)
+	}
+	if err := ApplyResourceLimits(composeProject, cfg.Resources); err != nil {
+		return err
+	}
+	if err := ApplyBuildxCache(composeProject, cfg.Build.Artifacts, env.Path); err != nil {
+		return err
+	}
+
+	if err := WriteComposeOverride(filepath.Join(composeDir, "docker-compose.mono.yml"), composeProject); err != nil {
+		return fmt.Errorf("failed to write compose override: %w", err)
+	}
+	logger.Log("regenerated docker-compose.mono.yml")
+	if !restart {
+		return nil
+	}
+
+	stdout := NewLogWriter(logger, "out")
+	stderr := NewLogWriter(logger, "err")
+	containers, err := env.Runtime()
+	if err != nil {
+		return err
+	}
+	if err := containers.StartContainers(env.DockerProject.String, composeDir, stdout, stderr); err != nil {
+		return err
+	}
+	logger.Log("restarted containers")
+	return nil
+}
@@ -3,6 +3,10 @@ package mono
 import (
 	"fmt"
 	"hash/fnv"
+	"io"
+	"net"
+	"sort"
+	"strings"
 )
 
 const (
@@ -12,45 +16,522 @@ const (
 	MaxPortSlots         = (MaxPort - BasePort) / PortRangePerWorktree
 )
 
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+const (
+	PortModeSlot      = "slot"
+	PortModeEphemeral = "ephemeral"
+
+	ephemeralRangeAttempts = 50
+)
+
+type PortRequest struct {
+	Port     int
+	Count    int
+	Protocol string
+}
+
+func (r PortRequest) normalized() PortRequest {
+	if r.Count < 1 {
+		r.Count = 1
+	}
+	if r.Protocol == "" {
+		r.Protocol = ProtocolTCP
+	}
+	return r
+}
+
 type Allocation struct {
 	Service       string
 	ContainerPort int
 	HostPort      int
+	Protocol      string
+	Count         int
+}
+
+func (a Allocation) Size() int {
+	if a.Count < 1 {
+		return 1
+	}
+	return a.Count
 }
 
-func Allocate(envName string, servicePorts map[string][]int) []Allocation {
+func (a Allocation) LastHostPort() int {
+	return a.HostPort + a.Size() - 1
+}
+
+func PortSlot(envName string) int {
 	h := fnv.New32a()
 	h.Write([]byte(envName))
-	slot := int(h.Sum32()) % MaxPortSlots
-	basePort := BasePort + (slot * PortRangePerWorktree)
+	return int(h.Sum32()) % MaxPortSlots
+}
+
+func SlotBasePort(slot int) int {
+	return BasePort + (slot * PortRangePerWorktree)
+}
+
+func Allocate(envName string, servicePorts map[string][]PortRequest, reserved map[int]bool) ([]Allocation, error) {
+	return AllocateSlot(PortSlot(envName), servicePorts, reserved)
+}
+
+func AllocateSlot(slot int, servicePorts map[string][]PortRequest, reserved map[int]bool) ([]Allocation, error) {
+	basePort := SlotBasePort(slot)
+
+	services := make([]string, 0, len(servicePorts))
+	for service := range servicePorts {
+		services = append(services, service)
+	}
+	sort.Strings(services)
 
 	var allocations []Allocation
-	usedPorts := make(map[int]bool)
-	portIndex := 0
+	for _, service := range services {
+		requests := make([]PortRequest, 0, len(servicePorts[service]))
+		for _, r := range servicePorts[service] {
+			requests = append(requests, r.normalized())
+		}
+		sort.Slice(requests, func(i, j int) bool {
+			if requests[i].Protocol != requests[j].Protocol {
+				return requests[i].Protocol < requests[j].Protocol
+			}
+			return requests[i].Port < requests[j].Port
+		})
+		for _, r := range requests {
+			allocations = append(allocations, Allocation{
+				Service:       service,
+				ContainerPort: r.Port,
+				Protocol:      r.Protocol,
+				Count:         r.Count,
+			})
+		}
+	}
 
-	for service, ports := range servicePorts {
-		for _, containerPort := range ports {
-			hostPort := basePort + (containerPort % PortRangePerWorktree)
-			for usedPorts[hostPort] {
-				hostPort = basePort + portIndex
-				portIndex++
+	usedOffsets := map[string]map[int]bool{ProtocolTCP: {}, ProtocolUDP: {}}
+	takenBy := func(protocol string) func(int) bool {
+		return func(offset int) bool {
+			return usedOffsets[protocol][offset] || reserved[basePort+offset]
+		}
+	}
+	place := func(i, offset int) {
+		for j := 0; j < allocations[i].Size(); j++ {
+			usedOffsets[allocations[i].Protocol][offset+j] = true
+		}
+		allocations[i].HostPort = basePort + offset
+	}
+
+	assigned := make([]bool, len(allocations))
+	for i := range allocations {
+		if allocations[i].Count > 1 {
+			continue
+		}
+		offset := allocations[i].ContainerPort % PortRangePerWorktree
+		if takenBy(allocations[i].Protocol)(offset) {
+			continue
+		}
+		place(i, offset)
+		assigned[i] = true
+	}
+
+	for _, ranges := range []bool{true, false} {
+		for i := range allocations {
+			if assigned[i] || (allocations[i].Count > 1) != ranges {
+				continue
+			}
+			offset, ok := firstFreeRun(takenBy(allocations[i].Protocol), allocations[i].Size(), PortRangePerWorktree)
+			if !ok {
+				a := allocations[i]
+				return nil, fmt.Errorf("no room for %d %s port(s) of %s (%d) in port slot %d: each environment's slot holds %d ports per protocol; use ports.mode: ephemeral or drop some", a.Size(), a.Protocol, a.Service, a.ContainerPort, slot, PortRangePerWorktree)
 			}
-			usedPorts[hostPort] = true
+			place(i, offset)
+			assigned[i] = true
+		}
+	}
+
+	return allocations, nil
+}
+
+func firstFreeRun(taken func(int) bool, length, limit int) (int, bool) {
+	for start := 0; start+length <= limit; start++ {
+		free := true
+		for j := 0; j < length; j++ {
+			if taken(start + j) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return start, true
+		}
+	}
+	return 0, false
+}
+
+func AllocateEphemeral(servicePorts map[string][]PortRequest, reserved map[int]bool) ([]Allocation, error) {
+	services := make([]string, 0, len(servicePorts))
+	for service := range servicePorts {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var held []io.Closer
+	defer func() {
+		for _, c := range held {
+			c.Close()
+		}
+	}()
+
+	var allocations []Allocation
+	for _, service := range services {
+		requests := make([]PortRequest, 0, len(servicePorts[service]))
+		for _, r := range servicePorts[service] {
+			requests = append(requests, r.normalized())
+		}
+		sort.Slice(requests, func(i, j int) bool {
+			if requests[i].Protocol != requests[j].Protocol {
+				return requests[i].Protocol < requests[j].Protocol
+			}
+			return requests[i].Port < requests[j].Port
+		})
+
+		for _, r := range requests {
+			hostPort, closers, err := reserveEphemeralPorts(r.Protocol, r.Count, reserved)
+			if err != nil {
+				return nil, fmt.Errorf("failed to allocate ephemeral port for %s:%d: %w", service, r.Port, err)
+			}
+			held = append(held, closers...)
 			allocations = append(allocations, Allocation{
 				Service:       service,
-				ContainerPort: containerPort,
+				ContainerPort: r.Port,
 				HostPort:      hostPort,
+				Protocol:      r.Protocol,
+				Count:         r.Count,
 			})
 		}
 	}
 
-	return allocations
+	return allocations, nil
+}
+
+func reserveEphemeralPorts(protocol string, count int, reserved map[int]bool) (int, []io.Closer, error) {
+	var skipped []io.Closer
+	defer func() {
+		for _, c := range skipped {
+			c.Close()
+		}
+	}()
+
+	for attempt := 0; attempt < ephemeralRangeAttempts; attempt++ {
+		first, port, err := listenPort(protocol, 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		if reserved[port] {
+			skipped = append(skipped, first)
+			continue
+		}
+
+		closers := []io.Closer{first}
+		ok := true
+		for i := 1; i < count; i++ {
+			if port+i > MaxPort || reserved[port+i] {
+				ok = false
+				break
+			}
+			c, _, err := listenPort(protocol, port+i)
+			if err != nil {
+				ok = false
+				break
+			}
+			closers = append(closers, c)
+		}
+		if ok {
+			return port, closers, nil
+		}
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	return 0, nil, fmt.Errorf("no contiguous block of %d %s ports found", count, protocol)
+}
+
+func listenPort(protocol string, port int) (io.Closer, int, error) {
+	addr := fmt.Sprintf(":%d", port)
+	if protocol == ProtocolUDP {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return nil, 0, err
+		}
+		return conn, conn.LocalAddr().(*net.UDPAddr).Port, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	return listener, listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+func PortAvailable(port int, protocol string) bool {
+	addr := fmt.Sprintf(":%d", port)
+	if protocol == ProtocolUDP {
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return false
+		}
+		return conn.Close() == nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return false
+	}
+	return listener.Close() == nil
+}
+
+func (a Allocation) InUse() bool {
+	for p := a.HostPort; p <= a.LastHostPort(); p++ {
+		if !PortAvailable(p, a.Protocol) {
+			return true
+		}
+	}
+	return false
 }
 
 func (a Allocation) String() string {
+	protocol := a.Protocol
+	if protocol == "" {
+		protocol = ProtocolTCP
+	}
+	if a.Size() > 1 {
+		return fmt.Sprintf("%s:%d-%d/%s -> %d-%d", a.Service, a.ContainerPort, a.ContainerPort+a.Size()-1, protocol, a.HostPort, a.LastHostPort())
+	}
+	if protocol != ProtocolTCP {
+		return fmt.Sprintf("%s:%d/%s -> %d", a.Service, a.ContainerPort, protocol, a.HostPort)
+	}
 	return fmt.Sprintf("%s:%d -> %d", a.Service, a.ContainerPort, a.HostPort)
 }
 
+func AllocationsToServicePorts(allocations []Allocation) map[string][]PortRequest {
+	result := make(map[string][]PortRequest)
+	for _, a := range allocations {
+		result[a.Service] = append(result[a.Service], PortRequest{
+			Port:     a.ContainerPort,
+			Count:    a.Size(),
+			Protocol: a.Protocol,
+		}.normalized())
+	}
+	return result
+}
+
+func PortEnvVarName(service string) string {
+	return "MONO_" + strings.ToUpper(strings.ReplaceAll(service, "-", "_")) + "_PORT"
+}
+
+func PortEnvVars(allocations []Allocation) []string {
+	sorted := make([]Allocation, len(allocations))
+	copy(sorted, allocations)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Service != sorted[j].Service {
+			return sorted[i].Service < sorted[j].Service
+		}
+		return sorted[i].ContainerPort < sorted[j].ContainerPort
+	})
+
+	var vars []string
+	seen := make(map[string]bool)
+	for _, a := range sorted {
+		name := "PORT_" + strings.ToUpper(strings.ReplaceAll(a.Service, "-", "_"))
+		if !seen[name] {
+			seen[name] = true
+			vars = append(vars, fmt.Sprintf("%s=%d", name, a.HostPort))
+		}
+		portName := fmt.Sprintf("%s_%d", name, a.ContainerPort)
+		if a.Protocol == ProtocolUDP {
+			portName += "_UDP"
+		}
+		vars = append(vars, fmt.Sprintf("%s=%d", portName, a.HostPort))
+		if a.Size() > 1 {
+			vars = append(vars, fmt.Sprintf("%s_RANGE=%d-%d", portName, a.HostPort, a.LastHostPort()))
+		}
+	}
+	return vars
+}
+
+type PortStatus struct {
+	Service       string `json:"service"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	Protocol      string `json:"protocol"`
+	Count         int    `json:"count"`
+	InUse         bool   `json:"in_use"`
+}
+
+func GetPortStatus(path string) (*Environment, []PortStatus, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statuses := make([]PortStatus, 0, len(allocations))
+	for _, a := range allocations {
+		statuses = append(statuses, newPortStatus(a))
+	}
+	return env, statuses, nil
+}
+
+func newPortStatus(a Allocation) PortStatus {
+	return PortStatus{
+		Service:       a.Service,
+		ContainerPort: a.ContainerPort,
+		HostPort:      a.HostPort,
+		Protocol:      a.Protocol,
+		Count:         a.Size(),
+		InUse:         a.InUse(),
+	}
+}
+
+func ReservePort(envName, service string, req PortRequest) (*Allocation, error) {
+	req = req.normalized()
+
+	lock, err := AcquirePortsLock()
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByName(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	allAllocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := allAllocations[env.ID]
+	for _, a := range existing {
+		if a.Service == service && a.ContainerPort == req.Port && a.Protocol == req.Protocol {
+			return &a, nil
+		}
+	}
+
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	reserved, err := cfg.Ports.ReservedPorts()
+	if err != nil {
+		return nil, err
+	}
+	for _, allocations := range allAllocations {
+		for _, a := range allocations {
+			for p := a.HostPort; p <= a.LastHostPort(); p++ {
+				reserved[p] = true
+			}
+		}
+	}
+
+	var alloc Allocation
+	if env.PortSlot.Valid {
+		basePort := SlotBasePort(int(env.PortSlot.Int64))
+		offset, ok := firstFreeRun(func(offset int) bool {
+			port := basePort + offset
+			return reserved[port] || !PortAvailable(port, req.Protocol)
+		}, req.Count, PortRangePerWorktree)
+		if !ok {
+			return nil, fmt.Errorf("no free port left for %s in slot %d", service, env.PortSlot.Int64)
+		}
+		alloc = Allocation{
+			Service:       service,
+			ContainerPort: req.Port,
+			HostPort:      basePort + offset,
+			Protocol:      req.Protocol,
+			Count:         req.Count,
+		}
+	} else {
+		excluded, err := db.ephemeralReserved(env.ID, reserved)
+		if err != nil {
+			return nil, err
+		}
+		allocations, err := AllocateEphemeral(map[string][]PortRequest{service: {req}}, excluded)
+		if err != nil {
+			return nil, err
+		}
+		alloc = allocations[0]
+	}
+
+	if err := db.SaveAllocations(env.ID, append(existing, alloc)); err != nil {
+		return nil, err
+	}
+
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	notifyPortsAllocated(env.Path, logger)
+
+	return &alloc, nil
+}
+
+func ReleasePort(envName, service string) (int, error) {
+	lock, err := AcquirePortsLock()
+	if err != nil {
+		return 0, err
+	}
+	defer lock.Release()
+
+	db, err := OpenDB()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.GetEnvironmentByName(envName)
+	if err != nil {
+		return 0, err
+	}
+
+	allocations, err := db.GetAllocations(env.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []Allocation
+	for _, a := range allocations {
+		if a.Service != service {
+			kept = append(kept, a)
+		}
+	}
+	released := len(allocations) - len(kept)
+	if released == 0 {
+		return 0, nil
+	}
+
+	if err := db.SaveAllocations(env.ID, kept); err != nil {
+		return 0, err
+	}
+	return released, nil
+}
+
 func AllocationsToMap(allocations []Allocation) map[string]int {
 	result := make(map[string]int)
 	for _, a := range allocations {
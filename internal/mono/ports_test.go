@@ -0,0 +1,464 @@
+package mono
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDetectPortConflicts(t *testing.T) {
+	now := time.Now()
+	envs := []*Environment{
+		{ID: 1, Path: "/w/workspaces/app/one", PortSlot: sql.NullInt64{Int64: 5, Valid: true}, CreatedAt: now},
+		{ID: 2, Path: "/w/workspaces/app/two", PortSlot: sql.NullInt64{Int64: 5, Valid: true}, CreatedAt: now.Add(time.Minute)},
+		{ID: 3, Path: "/w/workspaces/app/three", PortSlot: sql.NullInt64{Int64: 9, Valid: true}, CreatedAt: now},
+	}
+	allocations := map[int64][]Allocation{
+		1: {{Service: "web", ContainerPort: 3000, HostPort: 19050}},
+		2: {{Service: "api", ContainerPort: 8080, HostPort: 19050}},
+		3: {{Service: "db", ContainerPort: 5432, HostPort: 19092}},
+	}
+
+	conflicts := detectPortConflicts(envs, allocations)
+	if len(conflicts) != 2 {
+		t.Fatalf("expected 2 conflicts, got %d: %v", len(conflicts), conflicts)
+	}
+
+	if conflicts[0].Kind != ConflictSlot || conflicts[0].Value != 5 {
+		t.Errorf("expected slot conflict on 5 first, got %s", conflicts[0].String())
+	}
+	if conflicts[1].Kind != ConflictHostPort || conflicts[1].Value != 19050 {
+		t.Errorf("expected port conflict on 19050, got %s", conflicts[1].String())
+	}
+
+	if conflicts[1].Owners[0].EnvName != "app-one" || conflicts[1].Owners[0].Service != "web" {
+		t.Errorf("expected oldest owner app-one (web) first, got %+v", conflicts[1].Owners[0])
+	}
+
+	newer := newerOwners(conflicts)
+	if len(newer) != 1 || newer[0] != 2 {
+		t.Errorf("expected only env 2 to be reassigned, got %v", newer)
+	}
+}
+
+func TestFindFreeSlotSkipsUsedPorts(t *testing.T) {
+	servicePorts := map[string][]PortRequest{"web": {{Port: 3000}}}
+	usedSlots := map[int]bool{0: true, 1: true}
+	usedPorts := map[int]bool{SlotBasePort(2): true}
+
+	slot, allocations, err := findFreeSlot(0, servicePorts, usedSlots, usedPorts, nil)
+	if err != nil {
+		t.Fatalf("findFreeSlot failed: %v", err)
+	}
+	if slot != 3 {
+		t.Errorf("expected slot 3, got %d", slot)
+	}
+	if len(allocations) != 1 || allocations[0].HostPort != SlotBasePort(3) {
+		t.Errorf("unexpected allocations: %v", allocations)
+	}
+}
+
+func TestAllocateFreeSlotAvoidsOtherEnvironments(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	slot := PortSlot("proj-feature")
+	other, err := db.InsertEnvironment(filepath.Join(home, "proj", "other"), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPortSlot(other, slot); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAllocations(other, []Allocation{
+		{Service: "web", ContainerPort: 3000, HostPort: SlotBasePort(slot + 1), Protocol: ProtocolTCP, Count: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(filepath.Join(home, "proj", "feature"), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servicePorts := map[string][]PortRequest{"web": {{Port: 3000}}}
+	got, allocations, err := db.allocateFreeSlot(envID, "proj-feature", servicePorts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != slot+2 {
+		t.Errorf("slot = %d, want %d: the hashed slot and the next one's ports are taken", got, slot+2)
+	}
+	if len(allocations) != 1 || allocations[0].HostPort != SlotBasePort(slot+2) {
+		t.Errorf("allocations = %v", allocations)
+	}
+}
+
+func TestEphemeralReservedExcludesOtherSlots(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	other, err := db.InsertEnvironment(filepath.Join(home, "proj", "other"), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPortSlot(other, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveAllocations(other, []Allocation{
+		{Service: "web", ContainerPort: 3000, HostPort: 40000, Protocol: ProtocolTCP, Count: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(filepath.Join(home, "proj", "feature"), "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	excluded, err := db.ephemeralReserved(envID, map[int]bool{5000: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range []int{5000, 40000, 40001, SlotBasePort(7), SlotBasePort(7) + PortRangePerWorktree - 1} {
+		if !excluded[port] {
+			t.Errorf("port %d is not excluded from ephemeral allocation", port)
+		}
+	}
+	for _, port := range []int{SlotBasePort(7) - 1, SlotBasePort(8), 40002} {
+		if excluded[port] {
+			t.Errorf("port %d is excluded but belongs to no other environment", port)
+		}
+	}
+
+	allocations, err := AllocateEphemeral(map[string][]PortRequest{"web": {{Port: 3000}}}, excluded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if excluded[allocations[0].HostPort] {
+		t.Errorf("ephemeral port %d falls inside another environment's ports", allocations[0].HostPort)
+	}
+}
+
+func TestParseProxyHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		service string
+		env     string
+		ok      bool
+	}{
+		{"web.feature-x.localhost", "web", "feature-x", true},
+		{"web.feature-x.localhost:18080", "web", "feature-x", true},
+		{"feature-x.localhost", "", "", false},
+		{"web.a.b.localhost", "", "", false},
+		{"web.feature-x.example.com", "", "", false},
+	}
+
+	for _, tt := range tests {
+		service, env, ok := ParseProxyHost(tt.host, "localhost")
+		if ok != tt.ok || service != tt.service || env != tt.env {
+			t.Errorf("ParseProxyHost(%q) = %q, %q, %v; want %q, %q, %v", tt.host, service, env, ok, tt.service, tt.env, tt.ok)
+		}
+	}
+}
+
+func TestPortEnvVars(t *testing.T) {
+	allocations := []Allocation{
+		{Service: "web-app", ContainerPort: 3001, HostPort: 19044},
+		{Service: "web-app", ContainerPort: 3000, HostPort: 19043},
+		{Service: "db", ContainerPort: 5432, HostPort: 19042},
+	}
+
+	got := PortEnvVars(allocations)
+	want := []string{
+		"PORT_DB=19042",
+		"PORT_DB_5432=19042",
+		"PORT_WEB_APP=19043",
+		"PORT_WEB_APP_3000=19043",
+		"PORT_WEB_APP_3001=19044",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("var %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestAllocateDeterministic(t *testing.T) {
+	servicePorts := map[string][]PortRequest{
+		"web":    {{Port: 3000}},
+		"api":    {{Port: 8080}, {Port: 8000}},
+		"db":     {{Port: 5432}},
+		"worker": {{Port: 9000}},
+	}
+
+	first, err := Allocate("feature-x", servicePorts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		next, err := Allocate("feature-x", servicePorts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(next) != len(first) {
+			t.Fatalf("allocation count changed: %d vs %d", len(first), len(next))
+		}
+		for j := range first {
+			if first[j] != next[j] {
+				t.Fatalf("allocation %d changed between runs: %v vs %v", j, first[j], next[j])
+			}
+		}
+	}
+
+	base := SlotBasePort(PortSlot("feature-x"))
+	expected := map[string]int{
+		"api:8000":    base + 0,
+		"api:8080":    base + 1,
+		"db:5432":     base + 2,
+		"web:3000":    base + 3,
+		"worker:9000": base + 4,
+	}
+	for _, a := range first {
+		key := fmt.Sprintf("%s:%d", a.Service, a.ContainerPort)
+		if expected[key] != a.HostPort {
+			t.Errorf("%s: expected host port %d, got %d", key, expected[key], a.HostPort)
+		}
+	}
+}
+
+func TestAllocateUDPRange(t *testing.T) {
+	servicePorts := map[string][]PortRequest{
+		"web":    {{Port: 3000}},
+		"webrtc": {{Port: 10000, Count: 6, Protocol: ProtocolUDP}},
+	}
+
+	allocations, err := AllocateSlot(0, servicePorts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocations) != 2 {
+		t.Fatalf("expected 2 allocations, got %v", allocations)
+	}
+
+	web, rtc := allocations[0], allocations[1]
+	if web.HostPort != BasePort || web.Protocol != ProtocolTCP {
+		t.Errorf("unexpected web allocation: %v", web)
+	}
+	if rtc.Protocol != ProtocolUDP || rtc.Size() != 6 {
+		t.Errorf("unexpected webrtc allocation: %v", rtc)
+	}
+	if rtc.HostPort != BasePort || rtc.LastHostPort() != BasePort+5 {
+		t.Errorf("expected contiguous range %d-%d, got %s", BasePort, BasePort+5, rtc.String())
+	}
+}
+
+func TestAllocateProtocolsShareOffsets(t *testing.T) {
+	servicePorts := map[string][]PortRequest{
+		"dns": {{Port: 53}, {Port: 53, Protocol: ProtocolUDP}},
+	}
+
+	allocations, err := AllocateSlot(0, servicePorts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range allocations {
+		if a.HostPort != BasePort+3 {
+			t.Errorf("%s: expected host port %d for both protocols", a.String(), BasePort+3)
+		}
+	}
+}
+
+func TestAllocateSlotOverflow(t *testing.T) {
+	tests := []struct {
+		name         string
+		servicePorts map[string][]PortRequest
+		reserved     map[int]bool
+	}{
+		{"wide range", map[string][]PortRequest{"webrtc": {{Port: 10000, Count: 20, Protocol: ProtocolUDP}}}, nil},
+		{"range after singles", map[string][]PortRequest{
+			"web":    {{Port: 3000}, {Port: 3005}},
+			"webrtc": {{Port: 10000, Count: 8}},
+		}, nil},
+		{"pushed past the slot", map[string][]PortRequest{"web": {{Port: 3000}, {Port: 3001}}}, map[int]bool{
+			19000: true, 19002: true, 19003: true, 19004: true, 19005: true, 19006: true, 19007: true, 19008: true, 19009: true,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocations, err := AllocateSlot(0, tt.servicePorts, tt.reserved)
+			if err == nil {
+				t.Fatalf("AllocateSlot() = %v, want an error instead of ports outside the slot", allocations)
+			}
+			if !strings.Contains(err.Error(), "ports.mode: ephemeral") {
+				t.Errorf("error = %v, want a hint to use ephemeral ports", err)
+			}
+		})
+	}
+}
+
+func TestGroupPortRanges(t *testing.T) {
+	got := groupPortRanges([]int{10002, 10000, 10001, 5000, 10001}, ProtocolUDP)
+	want := []PortRequest{
+		{Port: 5000, Count: 1, Protocol: ProtocolUDP},
+		{Port: 10000, Count: 3, Protocol: ProtocolUDP},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestAllocateEphemeral(t *testing.T) {
+	servicePorts := map[string][]PortRequest{
+		"db":  {{Port: 5432}},
+		"app": {{Port: 8080}, {Port: 9000, Count: 3, Protocol: ProtocolUDP}},
+	}
+
+	allocations, err := AllocateEphemeral(servicePorts, nil)
+	if err != nil {
+		t.Fatalf("AllocateEphemeral failed: %v", err)
+	}
+	if len(allocations) != 3 {
+		t.Fatalf("expected 3 allocations, got %d", len(allocations))
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range allocations {
+		if a.HostPort <= 0 || a.LastHostPort() > MaxPort {
+			t.Errorf("invalid host port for %s: %d", a.String(), a.HostPort)
+		}
+		for p := a.HostPort; p <= a.LastHostPort(); p++ {
+			key := fmt.Sprintf("%d/%s", p, a.Protocol)
+			if seen[key] {
+				t.Errorf("host port %s allocated twice", key)
+			}
+			seen[key] = true
+		}
+	}
+
+	if allocations[1].Protocol != ProtocolUDP || allocations[1].Size() != 3 {
+		t.Errorf("expected udp range allocation, got %s", allocations[1].String())
+	}
+}
+
+func TestAllocateSkipsReservedPorts(t *testing.T) {
+	servicePorts := map[string][]PortRequest{
+		"web": {{Port: 3000}},
+		"api": {{Port: 8080}, {Port: 9000, Count: 3}},
+	}
+
+	cfg := PortsConfig{Reserved: []string{"19000", "19001-19004"}}
+	reserved, err := cfg.ReservedPorts()
+	if err != nil {
+		t.Fatalf("ReservedPorts failed: %v", err)
+	}
+
+	allocations, err := AllocateSlot(0, servicePorts, reserved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range allocations {
+		for p := a.HostPort; p <= a.LastHostPort(); p++ {
+			if reserved[p] {
+				t.Errorf("%s uses reserved port %d", a.String(), p)
+			}
+		}
+	}
+}
+
+func TestReservedPortsInvalid(t *testing.T) {
+	for _, entry := range []string{"abc", "0", "70000", "19010-19000", "1-x"} {
+		cfg := PortsConfig{Mode: PortModeSlot, Reserved: []string{entry}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for reserved entry %q", entry)
+		}
+	}
+}
+
+func TestReservePortConcurrent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	envPath := filepath.Join(home, "workspaces", "proj", "feature")
+	if err := os.MkdirAll(envPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	envID, err := db.InsertEnvironment(envPath, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetPortSlot(envID, 120); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	const workers = 8
+	ports := make([]int, workers)
+	var g errgroup.Group
+	for i := range workers {
+		g.Go(func() error {
+			alloc, err := ReservePort("proj-feature", fmt.Sprintf("svc%d", i), PortRequest{Port: 8080})
+			if err != nil {
+				return err
+			}
+			ports[i] = alloc.HostPort
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	for _, port := range ports {
+		if seen[port] {
+			t.Errorf("port %d was reserved twice: %v", port, ports)
+		}
+		seen[port] = true
+	}
+
+	db, err = OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	saved, err := db.GetAllocations(envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != workers {
+		t.Errorf("saved %d allocations, want %d", len(saved), workers)
+	}
+}
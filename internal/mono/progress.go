@@ -0,0 +1,114 @@
+package mono
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	progressSocketEnvVar = "MONO_PROGRESS_SOCKET"
+	progressDialTimeout  = time.Second
+	progressWriteTimeout = time.Second
+	progressInterval     = 100 * time.Millisecond
+
+	ProgressStart  = "start"
+	ProgressUpdate = "progress"
+	ProgressDone   = "done"
+	ProgressFailed = "failed"
+)
+
+type ProgressEvent struct {
+	Time       time.Time `json:"time"`
+	PID        int       `json:"pid"`
+	Env        string    `json:"env,omitempty"`
+	Operation  string    `json:"operation"`
+	Phase      string    `json:"phase,omitempty"`
+	State      string    `json:"state"`
+	Current    int64     `json:"current,omitempty"`
+	Total      int64     `json:"total,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	TotalBytes int64     `json:"total_bytes,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type progressSink struct {
+	mu   sync.Mutex
+	path string
+	conn net.Conn
+}
+
+var progress *progressSink
+
+func OpenProgressSocket(path string) error {
+	if path == "" {
+		path = os.Getenv(progressSocketEnvVar)
+	}
+	if path == "" {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, progressDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to progress socket %s: %w", path, err)
+	}
+	progress = &progressSink{path: path, conn: conn}
+	return nil
+}
+
+func CloseProgressSocket() error {
+	if progress == nil {
+		return nil
+	}
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.conn == nil {
+		return nil
+	}
+	err := progress.conn.Close()
+	progress.conn = nil
+	return err
+}
+
+func EmitProgress(e ProgressEvent) {
+	if progress == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	e.PID = os.Getpid()
+	data, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to encode progress event: %v\n", err)
+		return
+	}
+
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	if progress.conn == nil {
+		return
+	}
+	if err := progress.conn.SetWriteDeadline(time.Now().Add(progressWriteTimeout)); err != nil {
+		progress.fail(err)
+		return
+	}
+	if _, err := progress.conn.Write(append(data, '\n')); err != nil {
+		progress.fail(err)
+	}
+}
+
+func (s *progressSink) fail(err error) {
+	fmt.Fprintf(os.Stderr, "warning: stopped sending progress to %s: %v\n", s.path, err)
+	if closeErr := s.conn.Close(); closeErr != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to close progress socket: %v\n", closeErr)
+	}
+	s.conn = nil
+}
+
+func progressState(err error) (string, string) {
+	if err != nil {
+		return ProgressFailed, err.Error()
+	}
+	return ProgressDone, ""
+}
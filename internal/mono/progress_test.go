@@ -0,0 +1,114 @@
+package mono
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgressEventsOverSocket(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sockDir, err := os.MkdirTemp("", "mono-progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(sockDir) })
+	sock := filepath.Join(sockDir, "p.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan []ProgressEvent, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		var events []ProgressEvent
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var e ProgressEvent
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				break
+			}
+			events = append(events, e)
+		}
+		received <- events
+	}()
+
+	t.Setenv(progressSocketEnvVar, sock)
+	if err := OpenProgressSocket(""); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { progress = nil })
+
+	src := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("0123"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger, err := NewFileLogger("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	timer := NewPhaseTimer(TimingInit, "feature", src)
+	done := timer.Start("restore cache")
+	if err := SeedDirectory(src, filepath.Join(t.TempDir(), "dst"), SeedOptions{ArtifactName: "npm", Logger: logger, OperationName: "restoring"}); err != nil {
+		t.Fatal(err)
+	}
+	done()
+	timer.Finish(errors.New("compose up failed"))
+	if err := CloseProgressSocket(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := <-received
+	type step struct{ operation, phase, state string }
+	want := []step{
+		{TimingInit, "", ProgressStart},
+		{TimingInit, "restore cache", ProgressStart},
+		{"restoring npm", "", ProgressStart},
+	}
+	if len(events) < len(want)+3 {
+		t.Fatalf("got %d events, want at least %d: %+v", len(events), len(want)+3, events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if (step{e.Operation, e.Phase, e.State}) != w || e.Env != "feature" || e.PID != os.Getpid() {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+	}
+	n := len(events)
+	files := events[n-3]
+	if files.Operation != "restoring npm" || files.State != ProgressDone || files.Current != 3 || files.Total != 3 || files.Bytes != 12 || files.TotalBytes != 12 {
+		t.Errorf("file progress = %+v, want 3/3 files and 12/12 bytes done", files)
+	}
+	if e := events[n-2]; e.Phase != "restore cache" || e.State != ProgressDone {
+		t.Errorf("phase end = %+v", e)
+	}
+	if e := events[n-1]; e.Operation != TimingInit || e.State != ProgressFailed || e.Error != "compose up failed" {
+		t.Errorf("operation end = %+v", e)
+	}
+}
+
+func TestOpenProgressSocketFailsWithoutListener(t *testing.T) {
+	t.Setenv(progressSocketEnvVar, "")
+	if err := OpenProgressSocket(""); err != nil {
+		t.Fatalf("OpenProgressSocket() without a socket = %v", err)
+	}
+	if err := OpenProgressSocket(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Fatal("OpenProgressSocket() on a missing socket succeeded")
+	}
+	EmitProgress(ProgressEvent{Operation: TimingInit, State: ProgressStart})
+}
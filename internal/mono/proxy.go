@@ -0,0 +1,265 @@
+package mono
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const proxyRouteTTL = 2 * time.Second
+
+type ProxyOptions struct {
+	Listen    string
+	TLSListen string
+	Domain    string
+	Env       string
+	Binds     map[string]int
+}
+
+type proxyRoutes struct {
+	mu       sync.Mutex
+	loadedAt time.Time
+	routes   map[string]map[string]int
+}
+
+func (r *proxyRoutes) lookup(envName, service string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.routes == nil || time.Since(r.loadedAt) > proxyRouteTTL {
+		routes, err := loadProxyRoutes()
+		if err != nil {
+			return 0, err
+		}
+		r.routes = routes
+		r.loadedAt = time.Now()
+	}
+
+	services, ok := r.routes[envName]
+	if !ok {
+		return 0, fmt.Errorf("unknown environment: %s", envName)
+	}
+	port, ok := services[service]
+	if !ok {
+		return 0, fmt.Errorf("environment %s has no port for service %s", envName, service)
+	}
+	return port, nil
+}
+
+func loadProxyRoutes() (map[string]map[string]int, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	allocations, err := db.GetAllAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]map[string]int)
+	for _, env := range envs {
+		services := make(map[string]int)
+		for _, a := range allocations[env.ID] {
+			if a.Protocol == ProtocolUDP {
+				continue
+			}
+			if existing, ok := services[a.Service]; !ok || a.HostPort < existing {
+				services[a.Service] = a.HostPort
+			}
+		}
+		routes[env.EnvName()] = services
+	}
+	return routes, nil
+}
+
+func ParseProxyHost(host, domain string) (service, envName string, ok bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	suffix := "." + domain
+	if !strings.HasSuffix(host, suffix) {
+		return "", "", false
+	}
+	service, envName, ok = strings.Cut(strings.TrimSuffix(host, suffix), ".")
+	if !ok || service == "" || envName == "" || strings.Contains(envName, ".") {
+		return "", "", false
+	}
+	return service, envName, true
+}
+
+func RunProxy(ctx context.Context, opts ProxyOptions) error {
+	if opts.Domain == "" {
+		opts.Domain = "localhost"
+	}
+	if len(opts.Binds) > 0 && opts.Env == "" {
+		return fmt.Errorf("port binds require an environment")
+	}
+
+	logger, err := NewFileLogger("proxy")
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	var ca *LocalCA
+	if opts.TLSListen != "" {
+		ca, err = EnsureCA()
+		if err != nil {
+			return err
+		}
+	}
+
+	listeners := make(map[string]net.Listener, len(opts.Binds))
+	for _, service := range sortedKeys(opts.Binds) {
+		port := opts.Binds[service]
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("failed to listen on %d for %s: %w", port, service, err)
+		}
+		listeners[service] = listener
+	}
+
+	routes := &proxyRoutes{}
+	g, gctx := errgroup.WithContext(ctx)
+
+	if opts.Listen != "" {
+		server := &http.Server{
+			Addr:    opts.Listen,
+			Handler: newProxyHandler(routes, opts.Domain, logger),
+		}
+		g.Go(func() error {
+			logger.Log("proxy listening on %s for *.%s", opts.Listen, opts.Domain)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("proxy server failed: %w", err)
+			}
+			return nil
+		})
+		g.Go(func() error {
+			<-gctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		})
+	}
+
+	if opts.TLSListen != "" {
+		certs := &proxyCertificates{ca: ca, domain: opts.Domain, certs: make(map[string]*tls.Certificate)}
+		server := &http.Server{
+			Addr:      opts.TLSListen,
+			Handler:   newProxyHandler(routes, opts.Domain, logger),
+			TLSConfig: &tls.Config{GetCertificate: certs.get, MinVersion: tls.VersionTLS12},
+		}
+		g.Go(func() error {
+			logger.Log("proxy listening on %s for https://*.%s", opts.TLSListen, opts.Domain)
+			if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("tls proxy server failed: %w", err)
+			}
+			return nil
+		})
+		g.Go(func() error {
+			<-gctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		})
+	}
+
+	for service, listener := range listeners {
+		logger.Log("forwarding 127.0.0.1:%d to %s/%s", opts.Binds[service], opts.Env, service)
+		g.Go(func() error {
+			return serveTCPForward(gctx, listener, routes, opts.Env, service, logger)
+		})
+	}
+
+	return g.Wait()
+}
+
+func newProxyHandler(routes *proxyRoutes, domain string, logger *FileLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service, envName, ok := ParseProxyHost(r.Host, domain)
+		if !ok {
+			http.Error(w, fmt.Sprintf("expected host of the form <service>.<env>.%s", domain), http.StatusBadGateway)
+			return
+		}
+
+		port, err := routes.lookup(envName, service)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		target := &url.URL{Scheme: "http", Host: fmt.Sprintf("127.0.0.1:%d", port)}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Log("proxy %s -> %d failed: %v", r.Host, port, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+func serveTCPForward(ctx context.Context, listener net.Listener, routes *proxyRoutes, envName, service string, logger *FileLogger) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		go func() {
+			defer conn.Close()
+
+			port, err := routes.lookup(envName, service)
+			if err != nil {
+				logger.Log("forward %s/%s: %v", envName, service, err)
+				return
+			}
+
+			upstream, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+			if err != nil {
+				logger.Log("forward %s/%s -> %d failed: %v", envName, service, port, err)
+				return
+			}
+			defer upstream.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(upstream, conn)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
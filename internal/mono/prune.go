@@ -0,0 +1,232 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type PruneOptions struct {
+	OlderThan time.Duration
+}
+
+type StaleEnvironment struct {
+	Name    string
+	Path    string
+	Reasons []string
+}
+
+func FindStaleEnvironments(opts PruneOptions) ([]StaleEnvironment, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []StaleEnvironment
+	for _, env := range envs {
+		reasons, err := staleReasons(env, opts, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect %s: %w", env.EnvName(), err)
+		}
+		if len(reasons) > 0 {
+			stale = append(stale, StaleEnvironment{
+				Name:    env.EnvName(),
+				Path:    env.Path,
+				Reasons: reasons,
+			})
+		}
+	}
+	return stale, nil
+}
+
+func staleReasons(env *Environment, opts PruneOptions, now time.Time) ([]string, error) {
+	info, err := os.Stat(env.Path)
+	if os.IsNotExist(err) {
+		return []string{"directory is missing"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reasons []string
+
+	if opts.OlderThan > 0 {
+		lastActive := env.CreatedAt
+		if env.LastUsed.Valid && env.LastUsed.Time.After(lastActive) {
+			lastActive = env.LastUsed.Time
+		}
+		if info.ModTime().After(lastActive) {
+			lastActive = info.ModTime()
+		}
+		if idle := now.Sub(lastActive); idle > opts.OlderThan {
+			reasons = append(reasons, fmt.Sprintf("unused for %d days", int(idle.Hours()/24)))
+		}
+	}
+
+	reason, err := branchStaleReason(env)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		reasons = append(reasons, reason)
+	}
+
+	return reasons, nil
+}
+
+func branchStaleReason(env *Environment) (string, error) {
+	path := env.Path
+	if !GitRefExists(path, "HEAD") {
+		return "", nil
+	}
+	branch, err := GitCurrentBranch(path)
+	if err != nil {
+		return "", err
+	}
+	if branch == "HEAD" {
+		return "", nil
+	}
+
+	upstream, gone, err := GitUpstream(path, branch)
+	if err != nil {
+		return "", err
+	}
+	if gone {
+		return fmt.Sprintf("upstream %s was deleted", upstream), nil
+	}
+	if upstream == "" {
+		return "", nil
+	}
+
+	defaultBranch := GitDefaultBranch(path)
+	if branch == defaultBranch {
+		return "", nil
+	}
+	if !env.BaseCommit.Valid {
+		return "", nil
+	}
+	tip, err := GitRevParse(path, "refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	if tip == env.BaseCommit.String {
+		return "", nil
+	}
+	targets := []string{"refs/heads/" + defaultBranch}
+	if remote, _, ok := strings.Cut(upstream, "/"); ok {
+		targets = append(targets, "refs/remotes/"+remote+"/"+defaultBranch)
+	}
+	for _, target := range targets {
+		if GitRefExists(path, target) && GitIsAncestor(path, "refs/heads/"+branch, target) {
+			return fmt.Sprintf("merged into %s", defaultBranch), nil
+		}
+	}
+	return "", nil
+}
+
+type AutoPruneResult struct {
+	Pending   []StaleEnvironment
+	Destroyed []StaleEnvironment
+	Failed    []string
+}
+
+func AutoPrune(logger *FileLogger) (*AutoPruneResult, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	envs, err := db.ListEnvironments()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	type candidate struct {
+		stale StaleEnvironment
+		due   bool
+	}
+
+	result := &AutoPruneResult{}
+	var candidates []candidate
+	fetched := make(map[string]bool)
+
+	for _, env := range envs {
+		envName := env.EnvName()
+		if _, err := os.Stat(env.Path); err != nil {
+			continue
+		}
+		cfg, err := LoadConfig(env.Path)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", envName, err))
+			continue
+		}
+		if !cfg.Prune.Auto {
+			continue
+		}
+		grace, err := cfg.Prune.Grace()
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", envName, err))
+			continue
+		}
+
+		repo := env.RootPath.String
+		if repo == "" {
+			repo = env.Path
+		}
+		if !fetched[repo] {
+			fetched[repo] = true
+			if err := GitFetchPrune(repo); err != nil {
+				logger.Log("warning: %v", err)
+			}
+		}
+
+		reason, err := branchStaleReason(env)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", envName, err))
+			continue
+		}
+		if reason == "" {
+			if env.StaleSince.Valid {
+				if err := db.ClearEnvironmentStale(env.ID); err != nil {
+					result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", envName, err))
+				}
+			}
+			continue
+		}
+
+		if !env.StaleSince.Valid {
+			if err := db.MarkEnvironmentStale(env.ID); err != nil {
+				result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", envName, err))
+				continue
+			}
+			logger.Log("%s is stale (%s), destroying after %s", envName, reason, grace)
+		}
+
+		stale := StaleEnvironment{Name: envName, Path: env.Path, Reasons: []string{reason}}
+		due := env.StaleSince.Valid && time.Since(env.StaleSince.Time) >= grace
+		candidates = append(candidates, candidate{stale: stale, due: due})
+	}
+	db.Close()
+
+	for _, c := range candidates {
+		if !c.due {
+			result.Pending = append(result.Pending, c.stale)
+			continue
+		}
+		logger.Log("auto-destroying %s (%s)", c.stale.Name, c.stale.Reasons[0])
+		if err := Destroy(c.stale.Path, DestroyOptions{}); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", c.stale.Name, err))
+			continue
+		}
+		result.Destroyed = append(result.Destroyed, c.stale)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package mono
+
+import (
+	"database/sql"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBranchStaleReasonMerged(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin.git")
+	repo := filepath.Join(root, "repo")
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.email=t@t", "-c", "user.name=t"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git(root, "init", "-q", "--bare", "-b", "main", origin)
+	writeBuildxFixture(t, repo, map[string]string{"README.md": "base\n"})
+	git(repo, "init", "-q", "-b", "main")
+	git(repo, "add", "-A")
+	git(repo, "commit", "-q", "-m", "base")
+	git(repo, "remote", "add", "origin", origin)
+	git(repo, "push", "-q", "-u", "origin", "main")
+	git(repo, "checkout", "-q", "-b", "feature")
+	git(repo, "push", "-q", "-u", "origin", "feature")
+	base := git(repo, "rev-parse", "HEAD")
+
+	env := &Environment{Path: repo, BaseCommit: sql.NullString{String: base, Valid: true}}
+	if reason, err := branchStaleReason(env); err != nil || reason != "" {
+		t.Fatalf("fresh branch: branchStaleReason() = %q, %v, want no reason", reason, err)
+	}
+	legacy := &Environment{Path: repo}
+	if reason, err := branchStaleReason(legacy); err != nil || reason != "" {
+		t.Fatalf("branch without a recorded base: branchStaleReason() = %q, %v, want no reason", reason, err)
+	}
+
+	writeBuildxFixture(t, repo, map[string]string{"feature.txt": "work\n"})
+	git(repo, "add", "-A")
+	git(repo, "commit", "-q", "-m", "feature")
+	git(repo, "push", "-q")
+	if reason, err := branchStaleReason(env); err != nil || reason != "" {
+		t.Fatalf("unmerged branch: branchStaleReason() = %q, %v, want no reason", reason, err)
+	}
+
+	git(repo, "checkout", "-q", "main")
+	git(repo, "merge", "-q", "--ff-only", "feature")
+	git(repo, "checkout", "-q", "feature")
+	if reason, err := branchStaleReason(env); err != nil || reason != "merged into main" {
+		t.Errorf("merged branch: branchStaleReason() = %q, %v, want merged into main", reason, err)
+	}
+}
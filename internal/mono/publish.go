@@ -0,0 +1,246 @@
+package mono
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const publishManifestFile = "artifact.json"
+
+type PublishedArtifact struct {
+	Task        string    `json:"task"`
+	Key         string    `json:"key"`
+	Files       []string  `json:"files"`
+	Size        int64     `json:"size"`
+	Env         string    `json:"env"`
+	Branch      string    `json:"branch,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+	Path        string    `json:"path"`
+}
+
+func (t TaskConfig) validatePublish() error {
+	if len(t.Publish) == 0 {
+		return nil
+	}
+	if len(t.Outputs) == 0 {
+		return fmt.Errorf("task %s publishes files but has no outputs", t.Name)
+	}
+	if err := validatePatterns("task "+t.Name, "publish path", t.Publish); err != nil {
+		return err
+	}
+	for _, p := range t.Publish {
+		if strings.ContainsAny(p, "*?[") {
+			return fmt.Errorf("task %s: invalid publish path %q: publish paths are paths, not globs", t.Name, p)
+		}
+		clean := filepath.Clean(p)
+		inOutput := slices.ContainsFunc(t.Outputs, func(o string) bool {
+			o = filepath.Clean(o)
+			return clean == o || strings.HasPrefix(clean, o+string(filepath.Separator))
+		})
+		if !inOutput {
+			return fmt.Errorf("task %s: publish path %s is not one of its outputs", t.Name, p)
+		}
+	}
+	return nil
+}
+
+func ArtifactsDir(rootPath string) (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, "artifacts", ComputeProjectID(rootPath)), nil
+}
+
+func (r *taskRunner) publish(t TaskConfig, dir, key string) (*PublishedArtifact, bool, error) {
+	env := r.ec.Env
+	artifactsDir, err := ArtifactsDir(r.rootPath)
+	if err != nil {
+		return nil, false, err
+	}
+	dest := filepath.Join(artifactsDir, t.Name, key)
+	if dirExists(dest) {
+		a, err := readPublishedArtifact(dest)
+		return a, false, err
+	}
+
+	tmp := fmt.Sprintf("%s.tmp-%d", dest, os.Getpid())
+	if err := os.RemoveAll(tmp); err != nil {
+		return nil, false, fmt.Errorf("failed to clear %s: %w", tmp, err)
+	}
+	fail := func(err error) (*PublishedArtifact, bool, error) {
+		if rmErr := os.RemoveAll(tmp); rmErr != nil {
+			return nil, false, fmt.Errorf("%w (and failed to clean up %s: %v)", err, tmp, rmErr)
+		}
+		return nil, false, err
+	}
+
+	a := &PublishedArtifact{Task: t.Name, Key: key, Env: env.EnvName(), PublishedAt: time.Now().UTC()}
+	for _, p := range t.Publish {
+		src := filepath.Join(dir, p)
+		if _, err := os.Lstat(src); err != nil {
+			return fail(fmt.Errorf("task %s did not produce %s to publish: %w", t.Name, p, err))
+		}
+		if err := copyPath(src, filepath.Join(tmp, p)); err != nil {
+			return fail(fmt.Errorf("failed to publish %s of task %s: %w", p, t.Name, err))
+		}
+		a.Files = append(a.Files, filepath.Clean(p))
+	}
+	size, err := dirSize(tmp)
+	if err != nil {
+		return fail(fmt.Errorf("failed to measure published files of task %s: %w", t.Name, err))
+	}
+	a.Size = size
+	if GitRefExists(env.Path, "HEAD") {
+		a.Commit, a.Branch, err = GitHead(env.Path)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return fail(fmt.Errorf("failed to encode artifact manifest: %w", err))
+	}
+	if err := os.WriteFile(filepath.Join(tmp, publishManifestFile), data, 0644); err != nil {
+		return fail(fmt.Errorf("failed to write artifact manifest: %w", err))
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		if dirExists(dest) {
+			if rmErr := os.RemoveAll(tmp); rmErr != nil {
+				return nil, false, fmt.Errorf("failed to clean up %s: %w", tmp, rmErr)
+			}
+			a, err := readPublishedArtifact(dest)
+			return a, false, err
+		}
+		return fail(fmt.Errorf("failed to publish task %s: %w", t.Name, err))
+	}
+	a.Path = dest
+	return a, true, nil
+}
+
+func (r *taskRunner) publishOutputs(t TaskConfig, dir, key string) error {
+	if len(t.Publish) == 0 {
+		return nil
+	}
+	a, created, err := r.publish(t, dir, key)
+	if err != nil {
+		return err
+	}
+	if created {
+		r.report("mono: published %d file(s) of %s (%.12s)\n", len(a.Files), t.Name, key)
+		r.logger.Log("published %s of task %s to %s", strings.Join(a.Files, ", "), t.Name, a.Path)
+	}
+	return nil
+}
+
+func readPublishedArtifact(dir string) (*PublishedArtifact, error) {
+	data, err := os.ReadFile(filepath.Join(dir, publishManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read published artifact: %w", err)
+	}
+	var a PublishedArtifact
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid artifact manifest in %s: %w", dir, err)
+	}
+	a.Path = dir
+	return &a, nil
+}
+
+func publishedArtifacts(rootPath string, match func(task string) bool) ([]PublishedArtifact, error) {
+	artifactsDir, err := ArtifactsDir(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := filepath.Glob(filepath.Join(artifactsDir, "*", "*", publishManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list published artifacts: %w", err)
+	}
+
+	var artifacts []PublishedArtifact
+	for _, m := range manifests {
+		a, err := readPublishedArtifact(filepath.Dir(m))
+		if err != nil {
+			return nil, err
+		}
+		if match(a.Task) {
+			artifacts = append(artifacts, *a)
+		}
+	}
+	slices.SortFunc(artifacts, func(a, b PublishedArtifact) int {
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	return artifacts, nil
+}
+
+func artifactsProject(path string) (string, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return "", err
+	}
+	if env.RootPath.String == "" {
+		return env.Path, nil
+	}
+	return env.RootPath.String, nil
+}
+
+func ListArtifacts(path, task string) ([]PublishedArtifact, error) {
+	rootPath, err := artifactsProject(path)
+	if err != nil {
+		return nil, err
+	}
+	return publishedArtifacts(rootPath, func(name string) bool {
+		return task == "" || name == task || strings.HasPrefix(name, task+"@")
+	})
+}
+
+func GetArtifact(path, task, key string) (*PublishedArtifact, error) {
+	rootPath, err := artifactsProject(path)
+	if err != nil {
+		return nil, err
+	}
+	artifacts, err := publishedArtifacts(rootPath, func(name string) bool { return name == task })
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range artifacts {
+		if strings.HasPrefix(a.Key, key) {
+			return &a, nil
+		}
+	}
+	if key != "" {
+		return nil, fmt.Errorf("task %s has no published artifact with key %s", task, key)
+	}
+	return nil, fmt.Errorf("task %s has no published artifacts, add publish to it in mono.yml and run it", task)
+}
+
+func (a PublishedArtifact) Export(dst string, files []string) ([]string, error) {
+	if len(files) == 0 {
+		files = a.Files
+	}
+	var exported []string
+	for _, f := range files {
+		f = filepath.Clean(f)
+		if !slices.Contains(a.Files, f) {
+			return exported, fmt.Errorf("%s: %w", a.Task, unknownSelection("published file", f, a.Files))
+		}
+		target := filepath.Join(dst, filepath.Base(f))
+		if err := copyPath(filepath.Join(a.Path, f), target); err != nil {
+			return exported, fmt.Errorf("failed to copy %s: %w", f, err)
+		}
+		exported = append(exported, target)
+	}
+	return exported, nil
+}
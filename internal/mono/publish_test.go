@@ -0,0 +1,83 @@
+package mono
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTaskPublishesOutputs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("publish-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	envPath := t.TempDir()
+	writeBuildxFixture(t, envPath, map[string]string{"src/main.c": "int main() { return 0; }"})
+	task := TaskConfig{
+		Name:    "build",
+		Command: "mkdir -p bin && echo app > bin/app && echo debug > bin/app.debug",
+		Inputs:  []string{"src"},
+		Outputs: []string{"bin"},
+		Publish: []string{"bin/app"},
+	}
+
+	var out bytes.Buffer
+	r := &taskRunner{
+		ec:        &EnvContext{Env: &Environment{Path: envPath}, Config: &Config{}},
+		cm:        cm,
+		db:        db,
+		logger:    logger,
+		rootPath:  envPath,
+		markerDir: t.TempDir(),
+		env:       os.Environ(),
+		out:       &out,
+	}
+	if _, err := r.run(task, TaskOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "published 1 file(s) of build") {
+		t.Errorf("output = %q, want the publish reported", out.String())
+	}
+
+	if _, err := r.run(task, TaskOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "published") != 1 {
+		t.Errorf("output = %q, want an up-to-date run to keep the existing artifact", out.String())
+	}
+
+	artifacts, err := publishedArtifacts(envPath, func(name string) bool { return name == "build" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 1 || len(artifacts[0].Files) != 1 || artifacts[0].Files[0] != filepath.Join("bin", "app") {
+		t.Fatalf("artifacts = %+v, want bin/app published once", artifacts)
+	}
+
+	dst := t.TempDir()
+	copied, err := artifacts[0].Export(dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "app")); err != nil || strings.TrimSpace(string(data)) != "app" || len(copied) != 1 {
+		t.Errorf("exported app = %q, %v (copied %v)", data, err, copied)
+	}
+	if _, err := artifacts[0].Export(dst, []string{"bin/app.debug"}); err == nil || !strings.Contains(err.Error(), "unknown published file") {
+		t.Errorf("Export() of an unpublished file error = %v", err)
+	}
+}
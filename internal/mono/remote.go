@@ -0,0 +1,270 @@
+package mono
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RemoteSyncRsync   = "rsync"
+	RemoteSyncMutagen = "mutagen"
+
+	defaultRemoteDir  = "~/mono"
+	remoteSyncTimeout = 30 * time.Minute
+)
+
+type RemoteConfig struct {
+	Host       string   `yaml:"host" mono:"required"`
+	Dir        string   `yaml:"dir"`
+	Sync       string   `yaml:"sync"`
+	SSHOptions []string `yaml:"ssh_options"`
+	Exclude    []string `yaml:"exclude"`
+}
+
+func (r RemoteConfig) SyncMode() string {
+	if r.Sync == "" {
+		return RemoteSyncRsync
+	}
+	return r.Sync
+}
+
+func (r RemoteConfig) Validate(name string) error {
+	if r.Host == "" {
+		return fmt.Errorf("remote %s has no host", name)
+	}
+	if strings.HasPrefix(r.Host, "-") || strings.ContainsAny(r.Host, " \t\n") {
+		return fmt.Errorf("remote %s: invalid host %q", name, r.Host)
+	}
+	switch r.SyncMode() {
+	case RemoteSyncRsync, RemoteSyncMutagen:
+	default:
+		return fmt.Errorf("remote %s: invalid sync %q (expected %s or %s)", name, r.Sync, RemoteSyncRsync, RemoteSyncMutagen)
+	}
+	return nil
+}
+
+func (r RemoteConfig) CheckInstalled() error {
+	binaries := []string{"ssh", r.SyncMode()}
+	for _, b := range binaries {
+		if _, err := exec.LookPath(b); err != nil {
+			return fmt.Errorf("%s is not installed (needed for remote execution on %s)", b, r.Host)
+		}
+	}
+	return nil
+}
+
+func (c *Config) ValidateRemotes() error {
+	for _, name := range sortedKeys(c.Remotes) {
+		if err := c.Remotes[name].Validate(name); err != nil {
+			return err
+		}
+	}
+	defined := sortedKeys(c.Remotes)
+	for _, s := range c.Services {
+		if s.Remote == "" {
+			continue
+		}
+		if _, ok := c.Remotes[s.Remote]; !ok {
+			return fmt.Errorf("service %s: %w", s.Name, unknownSelection("remote", s.Remote, defined))
+		}
+		for _, k := range sortedKeys(s.Env) {
+			if IsSecretRef(s.Env[k]) {
+				return fmt.Errorf("service %s runs on remote %s and can't use the secret reference in env.%s", s.Name, s.Remote, k)
+			}
+		}
+	}
+	for _, t := range c.Tasks {
+		if t.Remote == "" {
+			continue
+		}
+		if _, ok := c.Remotes[t.Remote]; !ok {
+			return fmt.Errorf("task %s: %w", t.Name, unknownSelection("remote", t.Remote, defined))
+		}
+	}
+	return nil
+}
+
+func (c *Config) remote(name string) (RemoteConfig, error) {
+	r, ok := c.Remotes[name]
+	if !ok {
+		return RemoteConfig{}, unknownSelection("remote", name, sortedKeys(c.Remotes))
+	}
+	return r, nil
+}
+
+func (r RemoteConfig) envDir(envName string) string {
+	dir := r.Dir
+	if dir == "" {
+		dir = defaultRemoteDir
+	}
+	return path.Join(dir, envName)
+}
+
+func remoteShellPath(p string) string {
+	if p == "~" {
+		return `"$HOME"`
+	}
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return `"$HOME"/` + shellQuote(rest)
+	}
+	return shellQuote(p)
+}
+
+func (r RemoteConfig) rsyncPath(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		return r.Host + ":" + rest
+	}
+	return r.Host + ":" + p
+}
+
+func (r RemoteConfig) sshArgs(extra ...string) []string {
+	return slices.Concat(r.SSHOptions, extra, []string{r.Host})
+}
+
+func (r RemoteConfig) excludes() []string {
+	excludes := sortedKeys(skipDirs)
+	for _, e := range r.Exclude {
+		if !slices.Contains(excludes, e) {
+			excludes = append(excludes, e)
+		}
+	}
+	return excludes
+}
+
+func mutagenSessionName(envName, remote string) string {
+	return "mono-" + envName + "-" + remote
+}
+
+func (r RemoteConfig) Push(name, envName, envPath string, out io.Writer) error {
+	dir := r.envDir(envName)
+	if output, err := Command("ssh", append(r.sshArgs(), "mkdir -p "+remoteShellPath(dir))...).Timeout(time.Minute).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create %s on %s: %s: %w", dir, r.Host, strings.TrimSpace(string(output)), err)
+	}
+
+	switch r.SyncMode() {
+	case RemoteSyncMutagen:
+		session := mutagenSessionName(envName, name)
+		if err := Command("mutagen", "sync", "list", session).Run(); err != nil {
+			args := []string{"sync", "create", "--name", session, "--ignore-vcs"}
+			for _, e := range r.excludes() {
+				args = append(args, "--ignore", e)
+			}
+			args = append(args, envPath, r.Host+":"+dir)
+			if output, err := Command("mutagen", args...).Timeout(time.Minute).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to create mutagen session %s: %s: %w", session, strings.TrimSpace(string(output)), err)
+			}
+		}
+		if output, err := Command("mutagen", "sync", "flush", session).Timeout(remoteSyncTimeout).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to sync %s to %s: %s: %w", envName, r.Host, strings.TrimSpace(string(output)), err)
+		}
+	default:
+		args := []string{"-az", "--delete", "-e", shellJoin(append([]string{"ssh"}, r.SSHOptions...))}
+		for _, e := range r.excludes() {
+			args = append(args, "--exclude", e)
+		}
+		args = append(args, filepath.Clean(envPath)+"/", r.rsyncPath(dir)+"/")
+		if err := Command("rsync", args...).Timeout(remoteSyncTimeout).Stdout(out).Stderr(out).Run(); err != nil {
+			return fmt.Errorf("failed to sync %s to %s: %w", envName, r.Host, err)
+		}
+	}
+	return nil
+}
+
+func (r RemoteConfig) Pull(name, envName, envPath string, paths []string, out io.Writer) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	if r.SyncMode() == RemoteSyncMutagen {
+		session := mutagenSessionName(envName, name)
+		if output, err := Command("mutagen", "sync", "flush", session).Timeout(remoteSyncTimeout).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to sync %s back from %s: %s: %w", envName, r.Host, strings.TrimSpace(string(output)), err)
+		}
+		if !slices.ContainsFunc(paths, r.excluded) {
+			return nil
+		}
+	}
+
+	dir := r.envDir(envName)
+	for _, p := range paths {
+		clean := filepath.ToSlash(filepath.Clean(p))
+		args := []string{"-az", "--delete", "-e", shellJoin(append([]string{"ssh"}, r.SSHOptions...))}
+		dest := filepath.Join(envPath, filepath.Dir(p))
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dest, err)
+		}
+		args = append(args, r.rsyncPath(path.Join(dir, clean)), dest+"/")
+		if err := Command("rsync", args...).Timeout(remoteSyncTimeout).Stdout(out).Stderr(out).Run(); err != nil {
+			return fmt.Errorf("failed to copy %s back from %s: %w", p, r.Host, err)
+		}
+	}
+	return nil
+}
+
+func (r RemoteConfig) excluded(p string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Clean(p)), "/") {
+		if slices.Contains(r.excludes(), part) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteScript(dir, workingDir string, env []string, command string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cd %s", remoteShellPath(path.Join(dir, filepath.ToSlash(workingDir))))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&b, " && export %s=%s", k, shellQuote(v))
+	}
+	fmt.Fprintf(&b, " && %s", command)
+	return b.String()
+}
+
+func remoteEnv(vars []string) []string {
+	var env []string
+	for _, kv := range vars {
+		if _, v, ok := strings.Cut(kv, "="); ok && !IsSecretRef(v) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+func tunnelArgs(service string, allocations []Allocation) []string {
+	var args []string
+	for _, a := range allocations {
+		if a.Protocol != "" && a.Protocol != "tcp" {
+			continue
+		}
+		flag := "-R"
+		if a.Service == service {
+			flag = "-L"
+		}
+		for port := a.HostPort; port < a.HostPort+a.Size(); port++ {
+			p := strconv.Itoa(port)
+			args = append(args, flag, p+":127.0.0.1:"+p)
+		}
+	}
+	return args
+}
+
+func (r RemoteConfig) commandArgs(envName, workingDir string, env []string, command string, extra ...string) []string {
+	script := remoteScript(r.envDir(envName), workingDir, env, command)
+	return append([]string{"ssh"}, append(r.sshArgs(extra...), script)...)
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,129 @@
+package mono
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidateRemotes(t *testing.T) {
+	remotes := map[string]RemoteConfig{"devbox1": {Host: "me@devbox1"}}
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name: "valid",
+			config: Config{
+				Remotes:  remotes,
+				Services: []ServiceConfig{{Name: "api", Command: "cargo run", Remote: "devbox1"}},
+				Tasks:    []TaskConfig{{Name: "build", Command: "cargo build", Remote: "devbox1"}},
+			},
+		},
+		{
+			name:    "missing host",
+			config:  Config{Remotes: map[string]RemoteConfig{"devbox1": {}}},
+			wantErr: "remote devbox1 has no host",
+		},
+		{
+			name:    "unknown sync",
+			config:  Config{Remotes: map[string]RemoteConfig{"devbox1": {Host: "devbox1", Sync: "scp"}}},
+			wantErr: `invalid sync "scp"`,
+		},
+		{
+			name:    "host looks like a flag",
+			config:  Config{Remotes: map[string]RemoteConfig{"devbox1": {Host: "-oProxyCommand=x"}}},
+			wantErr: "invalid host",
+		},
+		{
+			name:    "unknown remote on service",
+			config:  Config{Remotes: remotes, Services: []ServiceConfig{{Name: "api", Command: "cargo run", Remote: "devbox2"}}},
+			wantErr: "service api",
+		},
+		{
+			name:    "unknown remote on task",
+			config:  Config{Tasks: []TaskConfig{{Name: "build", Command: "cargo build", Remote: "devbox1"}}},
+			wantErr: "task build",
+		},
+		{
+			name: "secret on remote service",
+			config: Config{
+				Remotes:  remotes,
+				Services: []ServiceConfig{{Name: "api", Command: "cargo run", Remote: "devbox1", Env: map[string]string{"TOKEN": "op://dev/api/token"}}},
+			},
+			wantErr: "secret reference in env.TOKEN",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ValidateRemotes()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateRemotes() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ValidateRemotes() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRemoteShellPath(t *testing.T) {
+	tests := map[string]string{
+		"~":                `"$HOME"`,
+		"~/mono/feature":   `"$HOME"/'mono/feature'`,
+		"/srv/mono/it's":   `'/srv/mono/it'\''s'`,
+		"relative/feature": `'relative/feature'`,
+	}
+	for in, want := range tests {
+		if got := remoteShellPath(in); got != want {
+			t.Errorf("remoteShellPath(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestTunnelArgs(t *testing.T) {
+	allocations := []Allocation{
+		{Service: "api", ContainerPort: 8080, HostPort: 19100, Count: 2},
+		{Service: "db", ContainerPort: 5432, HostPort: 19000},
+		{Service: "dns", ContainerPort: 53, HostPort: 19200, Protocol: "udp"},
+	}
+	got := tunnelArgs("api", allocations)
+	want := []string{
+		"-L", "19100:127.0.0.1:19100",
+		"-L", "19101:127.0.0.1:19101",
+		"-R", "19000:127.0.0.1:19000",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("tunnelArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestRenderRemoteServiceScript(t *testing.T) {
+	r := RemoteConfig{Host: "me@devbox1", SSHOptions: []string{"-p", "2222"}}
+	s := ServiceConfig{Name: "api", Command: "cargo run --bin api", WorkingDir: "services/api", Ports: []int{8080}, Env: map[string]string{"DATABASE_URL": "postgres://127.0.0.1:${DB_PORT}/app"}}
+	allocations := []Allocation{{Service: "api", ContainerPort: 8080, HostPort: 19100}}
+	vars := []string{"DB_PORT=19000", "API_TOKEN=op://dev/api/token"}
+
+	script := renderRemoteServiceScript(r, "feature", s, allocations, vars)
+	for _, want := range []string{
+		"exec 'ssh' '-p' '2222' '-tt' '-o' 'ExitOnForwardFailure=yes' '-L' '19100:127.0.0.1:19100' 'me@devbox1'",
+		`cd "$HOME"/`,
+		"mono/feature/services/api",
+		"export DB_PORT=",
+		"export PORT=",
+		"19100",
+		"postgres://127.0.0.1:19000/app",
+		"cargo run --bin api",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script is missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Contains(script, "op://") {
+		t.Errorf("script passes a secret reference to the remote:\n%s", script)
+	}
+}
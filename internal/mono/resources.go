@@ -0,0 +1,160 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const containerResourcesSchema = `
+CREATE TABLE IF NOT EXISTS container_resources (
+    env_id INTEGER NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    PRIMARY KEY (env_id, kind, name)
+);
+`
+
+const (
+	ResourceContainer = "container"
+	ResourceNetwork   = "network"
+	ResourceVolume    = "volume"
+)
+
+type ContainerResource struct {
+	Kind string
+	Name string
+}
+
+func (r ContainerResource) String() string {
+	return r.Kind + " " + r.Name
+}
+
+func (db *DB) RecordContainerResources(envID int64, resources []ContainerResource) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, r := range resources {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO container_resources (env_id, kind, name) VALUES (?, ?, ?)`, envID, r.Kind, r.Name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record %s: %w", r, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit container resources: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetContainerResources(envID int64) ([]ContainerResource, error) {
+	rows, err := db.conn.Query(`SELECT kind, name FROM container_resources WHERE env_id = ? ORDER BY kind, name`, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container resources: %w", err)
+	}
+	defer rows.Close()
+
+	var resources []ContainerResource
+	for rows.Next() {
+		var r ContainerResource
+		if err := rows.Scan(&r.Kind, &r.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan container resource: %w", err)
+		}
+		resources = append(resources, r)
+	}
+	return resources, rows.Err()
+}
+
+func (db *DB) ClearContainerResources(envID int64) error {
+	if _, err := db.conn.Exec(`DELETE FROM container_resources WHERE env_id = ?`, envID); err != nil {
+		return fmt.Errorf("failed to clear container resources: %w", err)
+	}
+	return nil
+}
+
+func projectResources(binary, projectName string) ([]ContainerResource, error) {
+	filter := "label=com.docker.compose.project=" + projectName
+	lists := []struct {
+		kind string
+		args []string
+	}{
+		{ResourceContainer, []string{"ps", "-a", "--filter", filter, "--format", "{{.Names}}"}},
+		{ResourceNetwork, []string{"network", "ls", "--filter", filter, "--format", "{{.Name}}"}},
+		{ResourceVolume, []string{"volume", "ls", "--filter", filter, "--format", "{{.Name}}"}},
+	}
+
+	var resources []ContainerResource
+	for _, l := range lists {
+		output, err := Command(binary, l.args...).Timeout(30 * time.Second).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %ss of %s: %w", l.kind, projectName, err)
+		}
+		for _, name := range strings.Fields(string(output)) {
+			resources = append(resources, ContainerResource{Kind: l.kind, Name: name})
+		}
+	}
+	return resources, nil
+}
+
+func removeResources(binary string, resources []ContainerResource) error {
+	order := map[string]int{ResourceContainer: 0, ResourceNetwork: 1, ResourceVolume: 2}
+	sorted := append([]ContainerResource(nil), resources...)
+	sort.SliceStable(sorted, func(i, j int) bool { return order[sorted[i].Kind] < order[sorted[j].Kind] })
+
+	var errs []error
+	for _, r := range sorted {
+		var args []string
+		switch r.Kind {
+		case ResourceContainer:
+			args = []string{"rm", "-f", r.Name}
+		case ResourceNetwork:
+			args = []string{"network", "rm", r.Name}
+		case ResourceVolume:
+			args = []string{"volume", "rm", "-f", r.Name}
+		default:
+			errs = append(errs, fmt.Errorf("unknown resource kind %q for %s", r.Kind, r.Name))
+			continue
+		}
+		output, err := Command(binary, args...).Timeout(time.Minute).CombinedOutput()
+		message := strings.ToLower(string(output))
+		if err != nil && !strings.Contains(message, "no such") && !strings.Contains(message, "not found") {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w: %s", r, err, strings.TrimSpace(string(output))))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func trackContainerResources(db *DB, envID int64, containers ContainerRuntime, projectName string) error {
+	resources, err := containers.ProjectResources(projectName)
+	if err != nil {
+		return err
+	}
+	return db.RecordContainerResources(envID, resources)
+}
+
+func removeTrackedResources(db *DB, envID int64, containers ContainerRuntime, projectName string) error {
+	tracked, err := db.GetContainerResources(envID)
+	if err != nil {
+		return err
+	}
+	current, err := containers.ProjectResources(projectName)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[ContainerResource]bool)
+	var leftover []ContainerResource
+	for _, r := range append(tracked, current...) {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		leftover = append(leftover, r)
+	}
+	if err := containers.RemoveResources(leftover); err != nil {
+		return err
+	}
+	return db.ClearContainerResources(envID)
+}
@@ -0,0 +1,166 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestApplyOverridesNamespacesResources(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			"db":  {Name: "db", ContainerName: "postgres"},
+			"web": {Name: "web"},
+		},
+		Networks: types.Networks{
+			"backend": {},
+			"shared":  {Name: "infra", External: true},
+		},
+		Volumes: types.Volumes{
+			"pgdata": {},
+			"models": {Name: "models", External: true},
+		},
+	}
+	if err := ApplyOverrides(project, "mono-proj-feature", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := project.Services["db"].ContainerName; got != "mono-proj-feature-postgres" {
+		t.Errorf("container_name = %q", got)
+	}
+	if got := project.Services["web"].ContainerName; got != "" {
+		t.Errorf("web got container_name %q, want compose's default naming", got)
+	}
+	for name, want := range map[string]string{"default": "mono-proj-feature", "backend": "mono-proj-feature_backend", "shared": "infra"} {
+		if got := project.Networks[name].Name; got != want {
+			t.Errorf("network %s = %q, want %q", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{"pgdata": "mono-proj-feature_pgdata", "models": "models"} {
+		if got := project.Volumes[name].Name; got != want {
+			t.Errorf("volume %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRemoveTrackedResources(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	bin := t.TempDir()
+	calls := filepath.Join(bin, "calls")
+	script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$1 $2" in
+"ps -a") echo mono-proj-feature-web-1 ;;
+"volume ls") echo mono-proj-feature_pgdata ;;
+"rm -f") [ "$3" = mono-proj-feature-old-1 ] && { echo "Error: No such container: $3" >&2; exit 1; } ;;
+esac
+exit 0
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	envID, err := db.InsertEnvironment(filepath.Join(home, "feature"), "mono-proj-feature", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := dockerRuntime{}
+	if err := trackContainerResources(db, envID, containers, "mono-proj-feature"); err != nil {
+		t.Fatalf("trackContainerResources failed: %v", err)
+	}
+	if err := db.RecordContainerResources(envID, []ContainerResource{
+		{Kind: ResourceContainer, Name: "mono-proj-feature-old-1"},
+		{Kind: ResourceNetwork, Name: "mono-proj-feature_legacy"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tracked, err := db.GetContainerResources(envID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracked) != 4 {
+		t.Fatalf("tracked = %v", tracked)
+	}
+
+	if err := os.Remove(calls); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeTrackedResources(db, envID, containers, "mono-proj-feature"); err != nil {
+		t.Fatalf("removeTrackedResources failed: %v", err)
+	}
+
+	data, err := os.ReadFile(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removals []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.Contains(" "+line, " rm ") {
+			removals = append(removals, line)
+		}
+	}
+	want := []string{
+		"rm -f mono-proj-feature-old-1",
+		"rm -f mono-proj-feature-web-1",
+		"network rm mono-proj-feature_legacy",
+		"volume rm -f mono-proj-feature_pgdata",
+	}
+	if strings.Join(removals, "\n") != strings.Join(want, "\n") {
+		t.Errorf("removals:\n%s\nwant:\n%s", strings.Join(removals, "\n"), strings.Join(want, "\n"))
+	}
+
+	left, err := db.GetContainerResources(envID)
+	if err != nil || len(left) != 0 {
+		t.Errorf("resources still tracked after teardown: %v (%v)", left, err)
+	}
+}
+
+func TestApplyOverridesIsolatesNetwork(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			"api": {
+				Name:     "api",
+				Ports:    []types.ServicePortConfig{{Target: 8080, Published: "8080"}, {Target: 9090, Published: "9090"}},
+				Networks: map[string]*types.ServiceNetworkConfig{"backend": nil},
+			},
+			"worker": {Name: "worker", Ports: []types.ServicePortConfig{{Target: 6000, Published: "6000"}}},
+		},
+		Networks: types.Networks{"backend": {}},
+	}
+	allocations := []Allocation{{Service: "api", ContainerPort: 8080, HostPort: 19080, Protocol: ProtocolTCP}}
+	if err := ApplyOverrides(project, "mono-proj-feature", allocations); err != nil {
+		t.Fatal(err)
+	}
+
+	api := project.Services["api"]
+	if len(api.Ports) != 1 || api.Ports[0].Target != 8080 || api.Ports[0].Published != "19080" {
+		t.Errorf("api ports = %+v, want only the allocated 8080 -> 19080", api.Ports)
+	}
+	if _, ok := api.Networks["default"]; !ok {
+		t.Errorf("api networks = %v, want it on the environment network too", api.Networks)
+	}
+	if _, ok := api.Networks["backend"]; !ok {
+		t.Errorf("api networks = %v, want backend kept", api.Networks)
+	}
+	if ports := project.Services["worker"].Ports; len(ports) != 0 {
+		t.Errorf("worker ports = %+v, want nothing published without an allocation", ports)
+	}
+
+	project.Services["api"] = types.ServiceConfig{Name: "api", NetworkMode: "host"}
+	if err := ApplyOverrides(project, "mono-proj-feature", nil); err == nil || !strings.Contains(err.Error(), "network_mode: host") {
+		t.Errorf("err = %v, want host networking rejected", err)
+	}
+}
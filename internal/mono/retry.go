@@ -0,0 +1,113 @@
+package mono
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	RetryPreInit    = "pre_init"
+	RetryInit       = "init"
+	RetryContainers = "containers"
+	RetrySetup      = "setup"
+	RetryPostInit   = "post_init"
+
+	defaultRetryBackoff    = 2 * time.Second
+	defaultRetryMaxBackoff = time.Minute
+)
+
+var retrySteps = []string{RetryPreInit, RetryInit, RetryContainers, RetrySetup, RetryPostInit}
+
+type RetryPolicy struct {
+	Attempts   int    `yaml:"attempts"`
+	Backoff    string `yaml:"backoff"`
+	MaxBackoff string `yaml:"max_backoff"`
+}
+
+type RetryConfig map[string]RetryPolicy
+
+func (rc RetryConfig) Validate() error {
+	for _, step := range sortedKeys(rc) {
+		if !slices.Contains(retrySteps, step) {
+			return fmt.Errorf("unknown retry step %q (expected one of %s)", step, strings.Join(retrySteps, ", "))
+		}
+		if err := rc[step].validate(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p RetryPolicy) validate(step string) error {
+	if p.Attempts < 0 {
+		return fmt.Errorf("retry.%s.attempts must not be negative", step)
+	}
+	_, _, err := p.delays(step)
+	return err
+}
+
+func (p RetryPolicy) attempts() int {
+	return max(p.Attempts, 1)
+}
+
+func (p RetryPolicy) delays(step string) (time.Duration, time.Duration, error) {
+	backoff, maxBackoff := defaultRetryBackoff, defaultRetryMaxBackoff
+	if p.Backoff != "" {
+		d, err := time.ParseDuration(p.Backoff)
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("invalid retry.%s.backoff %q: expected e.g. 2s or 500ms", step, p.Backoff)
+		}
+		backoff = d
+	}
+	if p.MaxBackoff != "" {
+		d, err := time.ParseDuration(p.MaxBackoff)
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("invalid retry.%s.max_backoff %q: expected e.g. 1m or 30s", step, p.MaxBackoff)
+		}
+		maxBackoff = d
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	return backoff, maxBackoff, nil
+}
+
+func withRetry(step string, p RetryPolicy, logger *FileLogger, fn func() error) error {
+	backoff, maxBackoff, err := p.delays(step)
+	if err != nil {
+		return err
+	}
+	attempts := p.attempts()
+	if attempts == 1 {
+		return fn()
+	}
+
+	var failures []string
+	delay := backoff
+	for attempt := 1; attempt <= attempts; attempt++ {
+		start := time.Now()
+		err = fn()
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err == nil {
+			if attempt > 1 {
+				logger.Log("%s succeeded on attempt %d of %d after %d failure(s)", step, attempt, attempts, len(failures))
+			}
+			return nil
+		}
+		failures = append(failures, fmt.Sprintf("attempt %d (%s): %v", attempt, elapsed, err))
+		if attempt == attempts {
+			break
+		}
+		logger.Log("%s failed on attempt %d of %d: %v; retrying in %s", step, attempt, attempts, err, delay)
+		time.Sleep(delay)
+		delay = min(delay*2, maxBackoff)
+	}
+
+	logger.Log("%s failed after %d attempts:", step, attempts)
+	for _, f := range failures {
+		logger.Log("  %s", f)
+	}
+	return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+}
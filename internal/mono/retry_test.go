@@ -0,0 +1,103 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetryConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RetryConfig
+		wantErr string
+	}{
+		{name: "empty", config: nil},
+		{name: "valid", config: RetryConfig{RetryContainers: {Attempts: 3, Backoff: "5s", MaxBackoff: "1m"}}},
+		{name: "unknown step", config: RetryConfig{"migrate": {Attempts: 2}}, wantErr: "unknown retry step"},
+		{name: "negative attempts", config: RetryConfig{RetrySetup: {Attempts: -1}}, wantErr: "retry.setup.attempts"},
+		{name: "bad backoff", config: RetryConfig{RetryPreInit: {Attempts: 2, Backoff: "later"}}, wantErr: "invalid retry.pre_init.backoff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+	logger, err := NewFileLogger("retry-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	policy := RetryPolicy{Attempts: 3, Backoff: "1ms"}
+
+	calls := 0
+	err = withRetry(RetryContainers, policy, logger, func() error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("pull failed %d", calls)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry() error = %v, want success on the third attempt", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+
+	calls = 0
+	flaky := errors.New("migration lock held")
+	err = withRetry(RetrySetup, policy, logger, func() error {
+		calls++
+		return flaky
+	})
+	if !errors.Is(err, flaky) || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Fatalf("withRetry() error = %v, want it to wrap the last failure after 3 attempts", err)
+	}
+	if calls != 3 {
+		t.Errorf("fn called %d times, want 3", calls)
+	}
+
+	calls = 0
+	err = withRetry(RetryInit, RetryPolicy{}, logger, func() error {
+		calls++
+		return flaky
+	})
+	if err != flaky || calls != 1 {
+		t.Errorf("withRetry() without a policy = %v after %d call(s), want the error unchanged after 1", err, calls)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".mono", "mono.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, want := range []string{
+		"containers succeeded on attempt 3 of 3 after 2 failure(s)",
+		"setup failed on attempt 1 of 3: migration lock held; retrying in 1ms",
+		"setup failed after 3 attempts:",
+		"attempt 3 (",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log is missing %q:\n%s", want, log)
+		}
+	}
+}
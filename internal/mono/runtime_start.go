@@ -0,0 +1,101 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	runtimeStartTimeout = 5 * time.Minute
+	runtimeReadyTimeout = 3 * time.Minute
+	runtimeReadyPoll    = 2 * time.Second
+)
+
+var ErrRuntimeNotRunning = errors.New("isn't running")
+
+type runtimeStarter struct {
+	Name    string
+	Command []string
+}
+
+func (s runtimeStarter) String() string {
+	return strings.Join(s.Command, " ")
+}
+
+var dockerDesktopApp = "/Applications/Docker.app"
+
+func runtimeStarters(name string) []runtimeStarter {
+	var starters []runtimeStarter
+	onPath := func(binary string) bool {
+		_, err := exec.LookPath(binary)
+		return err == nil
+	}
+
+	switch name {
+	case RuntimeDocker:
+		if onPath("colima") {
+			starters = append(starters, runtimeStarter{Name: "Colima", Command: []string{"colima", "start"}})
+		}
+		if onPath("orb") {
+			starters = append(starters, runtimeStarter{Name: "OrbStack", Command: []string{"orb", "start"}})
+		}
+		if runtime.GOOS == "darwin" {
+			if _, err := os.Stat(dockerDesktopApp); err == nil {
+				starters = append(starters, runtimeStarter{Name: "Docker Desktop", Command: []string{"open", "-a", "Docker"}})
+			}
+		}
+		if runtime.GOOS == "linux" && onPath("systemctl") {
+			if err := Command("systemctl", "--user", "cat", "docker-desktop").Timeout(10 * time.Second).Run(); err == nil {
+				starters = append(starters, runtimeStarter{Name: "Docker Desktop", Command: []string{"systemctl", "--user", "start", "docker-desktop"}})
+			}
+		}
+	case RuntimePodman:
+		if runtime.GOOS != "linux" && onPath("podman") {
+			starters = append(starters, runtimeStarter{Name: "podman machine", Command: []string{"podman", "machine", "start"}})
+		}
+	}
+	return starters
+}
+
+func EnsureRuntimeAvailable(containers ContainerRuntime, autostart bool, progress io.Writer) error {
+	err := containers.CheckAvailable()
+	if err == nil || !errors.Is(err, ErrRuntimeNotRunning) {
+		return err
+	}
+
+	starters := runtimeStarters(containers.Name())
+	if len(starters) == 0 {
+		return fmt.Errorf("%w, please start it", err)
+	}
+	starter := starters[0]
+	if !autostart {
+		return fmt.Errorf("%w: start it with `%s`, or set container_autostart: true to let mono start it", err, starter)
+	}
+
+	fmt.Fprintf(progress, "Starting %s (%s)...\n", starter.Name, starter)
+	output, startErr := Command(starter.Command[0], starter.Command[1:]...).
+		Timeout(runtimeStartTimeout).
+		CombinedOutput()
+	if startErr != nil {
+		return fmt.Errorf("failed to start %s with %s: %w: %s", starter.Name, starter, startErr, strings.TrimSpace(string(output)))
+	}
+
+	start := time.Now()
+	for {
+		err = containers.CheckAvailable()
+		if err == nil {
+			fmt.Fprintf(progress, "%s is ready after %s\n", starter.Name, time.Since(start).Round(time.Second))
+			return nil
+		}
+		if time.Since(start) > runtimeReadyTimeout {
+			return fmt.Errorf("%s did not become ready within %s after %s: %w", containers.Name(), runtimeReadyTimeout, starter, err)
+		}
+		time.Sleep(runtimeReadyPoll)
+	}
+}
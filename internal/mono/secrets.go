@@ -0,0 +1,102 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	secretOnePasswordPrefix = "op://"
+	secretEnvPrefix         = "env:"
+)
+
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretOnePasswordPrefix) || strings.HasPrefix(value, secretEnvPrefix)
+}
+
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretEnvPrefix):
+		name := strings.TrimPrefix(ref, secretEnvPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret %s is not set in the environment", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretOnePasswordPrefix):
+		if _, err := exec.LookPath("op"); err != nil {
+			return "", fmt.Errorf("resolving %s requires the 1Password CLI (op)", ref)
+		}
+		output, err := Command("op", "read", "--no-newline", ref).
+			Timeout(2 * time.Minute).
+			Output()
+		if err != nil {
+			return "", fmt.Errorf("op read %s failed: %w", ref, err)
+		}
+		return string(output), nil
+	default:
+		return "", fmt.Errorf("not a secret reference: %s", ref)
+	}
+}
+
+func secretShellExpr(ref string) string {
+	if name, ok := strings.CutPrefix(ref, secretEnvPrefix); ok {
+		return `"${` + name + `}"`
+	}
+	return `"$(op read ` + shellQuote(ref) + `)"`
+}
+
+func secretDotenvValue(ref string) string {
+	if name, ok := strings.CutPrefix(ref, secretEnvPrefix); ok {
+		return "${" + name + "}"
+	}
+	return ref
+}
+
+func SecretKeys(env map[string]string) map[string]bool {
+	keys := make(map[string]bool)
+	for k, v := range env {
+		if IsSecretRef(v) {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+func ResolveSecrets(env map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(env))
+	for k := range SecretKeys(env) {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	secrets := make(map[string]string, len(keys))
+	for _, k := range keys {
+		value, err := ResolveSecret(env[k])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve env.%s: %w", k, err)
+		}
+		secrets[k] = value
+	}
+	return secrets, nil
+}
+
+func withSecrets(vars []string, secrets map[string]string) []string {
+	if len(secrets) == 0 {
+		return vars
+	}
+	result := make([]string, 0, len(vars))
+	for _, kv := range vars {
+		if k, _, ok := strings.Cut(kv, "="); ok {
+			if secret, isSecret := secrets[k]; isSecret {
+				kv = k + "=" + secret
+			}
+		}
+		result = append(result, kv)
+	}
+	return result
+}
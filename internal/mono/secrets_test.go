@@ -0,0 +1,65 @@
+package mono
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("MONO_TEST_TOKEN", "s3cr$t")
+
+	env := map[string]string{
+		"TOKEN":    "env:MONO_TEST_TOKEN",
+		"LOG":      "debug",
+		"HOME_DIR": "${MONO_DATA_DIR}",
+	}
+	secrets, err := ResolveSecrets(env)
+	if err != nil {
+		t.Fatalf("ResolveSecrets failed: %v", err)
+	}
+	if len(secrets) != 1 || secrets["TOKEN"] != "s3cr$t" {
+		t.Errorf("secrets = %v", secrets)
+	}
+
+	vars := withSecrets(buildScriptEnv("app", 1, "/env", "/root", nil, env, nil), secrets)
+	found := false
+	for _, kv := range vars {
+		if kv == "TOKEN=s3cr$t" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("resolved secret should reach scripts unexpanded, got %v", vars)
+	}
+
+	if _, err := ResolveSecrets(map[string]string{"X": "env:MONO_TEST_MISSING"}); err == nil || !strings.Contains(err.Error(), "env.X") {
+		t.Errorf("expected error for a missing secret, got %v", err)
+	}
+}
+
+func TestSecretsNeverRendered(t *testing.T) {
+	t.Setenv("MONO_TEST_TOKEN", "s3cr3t")
+	scriptEnv := []string{"TOKEN=env:MONO_TEST_TOKEN", "API_KEY=op://dev/api/key", "LOG=debug"}
+
+	envrc := RenderEnvrc(scriptEnv, nil, nil)
+	for _, want := range []string{
+		`export TOKEN="${MONO_TEST_TOKEN}"`,
+		`export API_KEY="$(op read 'op://dev/api/key')"`,
+		"export LOG='debug'",
+	} {
+		if !strings.Contains(envrc, want) {
+			t.Errorf("envrc is missing %q:\n%s", want, envrc)
+		}
+	}
+
+	dotenv := RenderDotenv(scriptEnv, nil, nil)
+	for _, want := range []string{`TOKEN="${MONO_TEST_TOKEN}"`, `API_KEY="op://dev/api/key"`} {
+		if !strings.Contains(dotenv, want) {
+			t.Errorf("dotenv is missing %q:\n%s", want, dotenv)
+		}
+	}
+
+	if strings.Contains(envrc+dotenv, "s3cr3t") {
+		t.Error("secret values must never be written to generated files")
+	}
+}
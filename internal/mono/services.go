@@ -0,0 +1,561 @@
+package mono
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultHealthInterval = time.Second
+	defaultHealthTimeout  = time.Minute
+)
+
+type HealthCheckConfig struct {
+	Command  string `yaml:"command"`
+	HTTP     string `yaml:"http"`
+	TCP      int    `yaml:"tcp"`
+	Interval string `yaml:"interval"`
+	Timeout  string `yaml:"timeout"`
+}
+
+func (hc HealthCheckConfig) enabled() bool {
+	return hc.Command != "" || hc.HTTP != "" || hc.TCP != 0
+}
+
+func (hc HealthCheckConfig) Validate() error {
+	kinds := 0
+	for _, set := range []bool{hc.Command != "", hc.HTTP != "", hc.TCP != 0} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("health_check takes one of command, http or tcp")
+	}
+	if hc.TCP < 0 || hc.TCP > MaxPort {
+		return fmt.Errorf("invalid health_check.tcp port %d", hc.TCP)
+	}
+	_, _, err := hc.durations()
+	return err
+}
+
+func (hc HealthCheckConfig) String() string {
+	switch {
+	case hc.HTTP != "":
+		return "GET " + hc.HTTP
+	case hc.TCP != 0:
+		return fmt.Sprintf("tcp %d", hc.TCP)
+	default:
+		return hc.Command
+	}
+}
+
+func (hc HealthCheckConfig) durations() (time.Duration, time.Duration, error) {
+	interval, timeout := defaultHealthInterval, defaultHealthTimeout
+	if hc.Interval != "" {
+		d, err := time.ParseDuration(hc.Interval)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid health_check.interval %q: expected e.g. 500ms or 2s", hc.Interval)
+		}
+		interval = d
+	}
+	if hc.Timeout != "" {
+		d, err := time.ParseDuration(hc.Timeout)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid health_check.timeout %q: expected e.g. 30s or 2m", hc.Timeout)
+		}
+		timeout = d
+	}
+	return interval, timeout, nil
+}
+
+type ServiceConfig struct {
+	Name        string            `yaml:"name" mono:"required"`
+	Command     string            `yaml:"command" mono:"required"`
+	Ports       []int             `yaml:"ports"`
+	Env         map[string]string `yaml:"env"`
+	WorkingDir  string            `yaml:"working_dir"`
+	DependsOn   []string          `yaml:"depends_on"`
+	HealthCheck HealthCheckConfig `yaml:"health_check"`
+	Watch       []string          `yaml:"watch"`
+	Remote      string            `yaml:"remote"`
+}
+
+func (s ServiceConfig) Validate() error {
+	if s.Command == "" {
+		return fmt.Errorf("service %s has no command", s.Name)
+	}
+	for _, p := range s.Ports {
+		if p < 1 || p > MaxPort {
+			return fmt.Errorf("service %s has invalid port %d", s.Name, p)
+		}
+	}
+	if s.WorkingDir != "" {
+		clean := filepath.Clean(s.WorkingDir)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid working_dir %q for service %s: must be inside the environment", s.WorkingDir, s.Name)
+		}
+	}
+	if err := s.HealthCheck.Validate(); err != nil {
+		return fmt.Errorf("service %s: %w", s.Name, err)
+	}
+	if s.HealthCheck.HTTP != "" && strings.HasPrefix(s.HealthCheck.HTTP, "/") && len(s.Ports) == 0 {
+		return fmt.Errorf("service %s: health_check.http %s needs a port to connect to", s.Name, s.HealthCheck.HTTP)
+	}
+	if s.HealthCheck.TCP != 0 && !slices.Contains(s.Ports, s.HealthCheck.TCP) {
+		return fmt.Errorf("service %s: health_check.tcp %d is not one of its ports", s.Name, s.HealthCheck.TCP)
+	}
+	for i, dep := range s.DependsOn {
+		if dep == s.Name {
+			return fmt.Errorf("service %s depends on itself", s.Name)
+		}
+		if slices.Contains(s.DependsOn[:i], dep) {
+			return fmt.Errorf("service %s lists %s in depends_on more than once", s.Name, dep)
+		}
+	}
+	return nil
+}
+
+func (s ServiceConfig) Dir(envPath string) string {
+	return filepath.Join(envPath, s.WorkingDir)
+}
+
+func (c *Config) ValidateServices() error {
+	windows := make(map[string]bool, len(c.Tmux.Windows))
+	for _, w := range c.Tmux.Windows {
+		windows[w.Name] = true
+	}
+	seen := make(map[string]bool)
+	for _, s := range c.Services {
+		if seen[s.Name] {
+			return fmt.Errorf("service %s is defined more than once", s.Name)
+		}
+		seen[s.Name] = true
+		if windows[s.Name] {
+			return fmt.Errorf("service %s clashes with the tmux window of the same name", s.Name)
+		}
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+	processDeps := make(map[string][]string, len(c.Services))
+	for _, s := range c.Services {
+		processDeps[s.Name] = slices.DeleteFunc(slices.Clone(s.DependsOn), func(dep string) bool { return !seen[dep] })
+	}
+	if _, err := serviceStages(processDeps); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(c.HealthChecks) {
+		if seen[name] {
+			return fmt.Errorf("health_checks.%s: service %s is defined in mono.yml, use its own health_check", name, name)
+		}
+		if err := c.HealthChecks[name].Validate(); err != nil {
+			return fmt.Errorf("health_checks.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) processService(name string) *ServiceConfig {
+	for i := range c.Services {
+		if c.Services[i].Name == name {
+			return &c.Services[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) SelectedProcessServices() []ServiceConfig {
+	if len(c.services) == 0 {
+		return c.Services
+	}
+	var selected []ServiceConfig
+	for _, name := range c.selectedWithDependencies() {
+		if s := c.processService(name); s != nil {
+			selected = append(selected, *s)
+		}
+	}
+	return selected
+}
+
+func processServicePorts(services []ServiceConfig) map[string][]PortRequest {
+	result := make(map[string][]PortRequest)
+	for _, s := range services {
+		for _, p := range s.Ports {
+			result[s.Name] = append(result[s.Name], PortRequest{Port: p, Count: 1, Protocol: ProtocolTCP})
+		}
+	}
+	return result
+}
+
+func mergeServicePorts(composePorts, processPorts map[string][]PortRequest) (map[string][]PortRequest, error) {
+	merged := make(map[string][]PortRequest, len(composePorts)+len(processPorts))
+	for name, requests := range composePorts {
+		merged[name] = requests
+	}
+	for name, requests := range processPorts {
+		if _, ok := merged[name]; ok {
+			return nil, fmt.Errorf("service %s is defined both in mono.yml and in the compose file", name)
+		}
+		merged[name] = requests
+	}
+	return merged, nil
+}
+
+func servicePort(s ServiceConfig, allocations []Allocation) int {
+	if len(s.Ports) == 0 {
+		return 0
+	}
+	return allocatedPort(s.Name, s.Ports[0], allocations)
+}
+
+func allocatedPort(service string, containerPort int, allocations []Allocation) int {
+	for _, a := range allocations {
+		if a.Service != service {
+			continue
+		}
+		if containerPort == 0 {
+			return a.HostPort
+		}
+		if offset := containerPort - a.ContainerPort; offset >= 0 && offset < a.Size() {
+			return a.HostPort + offset
+		}
+	}
+	return 0
+}
+
+func serviceEnv(s ServiceConfig, allocations []Allocation, vars []string) []string {
+	lookup := make(map[string]string, len(vars))
+	for _, kv := range vars {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			lookup[k] = v
+		}
+	}
+
+	var result []string
+	if port := servicePort(s, allocations); port != 0 {
+		result = append(result, "PORT="+strconv.Itoa(port))
+	}
+	keys := make([]string, 0, len(s.Env))
+	for k := range s.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := s.Env[k]
+		if !IsSecretRef(v) {
+			v = os.Expand(v, func(name string) string {
+				if val, ok := lookup[name]; ok {
+					return val
+				}
+				return os.Getenv(name)
+			})
+		}
+		result = append(result, k+"="+v)
+	}
+	return result
+}
+
+func renderServiceScript(s ServiceConfig, envPath string, env []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cd %s\n", shellQuote(s.Dir(envPath)))
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if IsSecretRef(v) {
+			fmt.Fprintf(&b, "export %s=%s\n", k, secretShellExpr(v))
+			continue
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(v))
+	}
+	b.WriteString(s.Command)
+	b.WriteString("\n")
+	return b.String()
+}
+
+func renderRemoteServiceScript(r RemoteConfig, envName string, s ServiceConfig, allocations []Allocation, vars []string) string {
+	env := append(remoteEnv(vars), serviceEnv(s, allocations, vars)...)
+	extra := append([]string{"-tt", "-o", "ExitOnForwardFailure=yes"}, tunnelArgs(s.Name, allocations)...)
+	return "exec " + shellJoin(r.commandArgs(envName, s.WorkingDir, env, s.Command, extra...)) + "\n"
+}
+
+func startService(tm *TmuxManager, ec *EnvContext, s ServiceConfig, scriptDir string) error {
+	script := renderServiceScript(s, ec.Env.Path, serviceEnv(s, ec.Allocations, ec.Vars))
+	if s.Remote != "" {
+		r, err := ec.Config.remote(s.Remote)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", s.Name, err)
+		}
+		if err := r.CheckInstalled(); err != nil {
+			return err
+		}
+		envName := ec.Env.EnvName()
+		if err := r.Push(s.Remote, envName, ec.Env.Path, io.Discard); err != nil {
+			return fmt.Errorf("failed to start service %s: %w", s.Name, err)
+		}
+		script = renderRemoteServiceScript(r, envName, s, ec.Allocations, ec.Vars)
+	}
+	scriptPath := filepath.Join(scriptDir, s.Name+".sh")
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write script for service %s: %w", s.Name, err)
+	}
+	if err := tm.RunInWindow(s.Name, scriptPath); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+func runServices(tm *TmuxManager, ec *EnvContext, dataDir string, logger *FileLogger) error {
+	plan, err := ec.Config.StartupPlan(ec.Project)
+	if err != nil {
+		return err
+	}
+	scriptDir := filepath.Join(dataDir, "services")
+	if err := os.MkdirAll(scriptDir, 0755); err != nil {
+		return fmt.Errorf("failed to create service script directory: %w", err)
+	}
+
+	secrets, err := ResolveSecrets(ec.Config.Env)
+	if err != nil {
+		return err
+	}
+	vars := withSecrets(ec.Vars, secrets)
+
+	for _, stage := range planStages(plan, ServiceKindProcess) {
+		var services []ServiceConfig
+		for _, name := range stage {
+			services = append(services, *ec.Config.processService(name))
+		}
+
+		for _, s := range services {
+			if err := startService(tm, ec, s, scriptDir); err != nil {
+				return err
+			}
+			logger.Log("started service %s in window %s", s.Name, s.Name)
+		}
+
+		var g errgroup.Group
+		for _, s := range services {
+			if !s.HealthCheck.enabled() {
+				continue
+			}
+			g.Go(func() error {
+				if err := waitForService(s, ec.Env.Path, ec.Allocations, vars); err != nil {
+					return err
+				}
+				logger.Log("service %s is healthy", s.Name)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitForService(s ServiceConfig, envPath string, allocations []Allocation, vars []string) error {
+	env := append(os.Environ(), vars...)
+	for _, kv := range serviceEnv(s, allocations, vars) {
+		k, v, _ := strings.Cut(kv, "=")
+		if IsSecretRef(v) {
+			resolved, err := ResolveSecret(v)
+			if err != nil {
+				return fmt.Errorf("service %s: %w", s.Name, err)
+			}
+			v = resolved
+		}
+		env = append(env, k+"="+v)
+	}
+
+	return waitForHealthy(healthTarget{
+		Name:  s.Name,
+		Check: s.HealthCheck,
+		Dir:   s.Dir(envPath),
+		Port: func(containerPort int) int {
+			if containerPort == 0 {
+				return servicePort(s, allocations)
+			}
+			return allocatedPort(s.Name, containerPort, allocations)
+		},
+	}, env)
+}
+
+type healthTarget struct {
+	Name  string
+	Check HealthCheckConfig
+	Dir   string
+	Port  func(containerPort int) int
+}
+
+func waitForHealthy(t healthTarget, env []string) error {
+	interval, timeout, err := t.Check.durations()
+	if err != nil {
+		return fmt.Errorf("service %s: %w", t.Name, err)
+	}
+
+	var check func(ctx context.Context) error
+	switch {
+	case t.Check.HTTP != "":
+		url := t.Check.HTTP
+		if strings.HasPrefix(url, "/") {
+			port := t.Port(0)
+			if port == 0 {
+				return fmt.Errorf("service %s: no host port allocated for health_check.http %s", t.Name, url)
+			}
+			url = fmt.Sprintf("http://127.0.0.1:%d%s", port, url)
+		}
+		check = func(ctx context.Context) error { return checkHTTP(ctx, url) }
+	case t.Check.TCP != 0:
+		port := t.Port(t.Check.TCP)
+		if port == 0 {
+			return fmt.Errorf("service %s: no host port allocated for health_check.tcp %d", t.Name, t.Check.TCP)
+		}
+		check = func(ctx context.Context) error { return checkTCP(ctx, port, interval) }
+	default:
+		check = func(ctx context.Context) error {
+			return Command("sh", "-c", t.Check.Command).
+				Dir(t.Dir).
+				Env(env).
+				Timeout(interval + 5*time.Second).
+				Run()
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		if lastErr = check(ctx); lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service %s did not become healthy within %s: %w", t.Name, timeout, lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func checkTCP(ctx context.Context, port int, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("invalid health check url %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+func waitForDependencies(cfg *Config, project *types.Project, containers ContainerRuntime, dockerProject string, allocations []Allocation, vars []string, progress io.Writer, logger *FileLogger) error {
+	if project == nil {
+		return nil
+	}
+
+	env := append(os.Environ(), vars...)
+	var mu sync.Mutex
+	report := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(progress, format+"\n", args...)
+	}
+
+	var g errgroup.Group
+	for _, name := range project.ServiceNames() {
+		check, configured := cfg.HealthChecks[name]
+		native := project.Services[name].HealthCheck
+		if !configured && (native == nil || native.Disable) {
+			continue
+		}
+
+		g.Go(func() error {
+			start := time.Now()
+			var err error
+			if configured {
+				report("Waiting for %s (%s)...", name, check)
+				err = waitForHealthy(healthTarget{
+					Name:  name,
+					Check: check,
+					Dir:   project.WorkingDir,
+					Port: func(containerPort int) int {
+						return allocatedPort(name, containerPort, allocations)
+					},
+				}, env)
+			} else {
+				report("Waiting for %s (compose healthcheck)...", name)
+				err = waitForContainerHealth(containers, dockerProject, name)
+			}
+			if err != nil {
+				return err
+			}
+			elapsed := time.Since(start).Round(100 * time.Millisecond)
+			report("  %s: healthy after %s", name, elapsed)
+			logger.Log("service %s is healthy after %s", name, elapsed)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func waitForContainerHealth(containers ContainerRuntime, dockerProject, service string) error {
+	interval, timeout := defaultHealthInterval, defaultHealthTimeout
+	deadline := time.Now().Add(timeout)
+
+	last := "not created"
+	for {
+		statuses, err := containers.ContainerStatuses(dockerProject)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Service != service {
+				continue
+			}
+			if s.State == "exited" || s.State == "dead" {
+				return fmt.Errorf("service %s %s before becoming healthy", service, s.State)
+			}
+			if s.Health == "healthy" {
+				return nil
+			}
+			last = s.State
+			if s.Health != "" {
+				last = s.Health
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service %s did not become healthy within %s: %s", service, timeout, last)
+		}
+		time.Sleep(interval)
+	}
+}
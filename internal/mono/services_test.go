@@ -0,0 +1,223 @@
+package mono
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestLoadConfigServices(t *testing.T) {
+	dir := t.TempDir()
+	content := `services:
+  - name: api
+    command: cargo run
+    ports: [8080]
+    env:
+      DATABASE_URL: postgres://localhost:${port.db}/app
+    health_check:
+      http: /health
+  - name: web
+    command: npm run dev
+    working_dir: web
+templates:
+  backend:
+    services: [api, db]
+`
+	if err := os.WriteFile(filepath.Join(dir, "mono.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.ValidateServices(); err != nil {
+		t.Fatalf("ValidateServices failed: %v", err)
+	}
+	if len(cfg.Services) != 2 || cfg.Services[1].Dir(dir) != filepath.Join(dir, "web") {
+		t.Fatalf("services = %+v", cfg.Services)
+	}
+
+	if err := cfg.ApplyTemplate("backend"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.SelectedServices(); len(got) != 1 || got[0] != "db" {
+		t.Errorf("compose services = %v, want [db]", got)
+	}
+	if got := cfg.SelectedProcessServices(); len(got) != 1 || got[0].Name != "api" {
+		t.Errorf("process services = %+v, want [api]", got)
+	}
+
+	ports, err := mergeServicePorts(map[string][]PortRequest{"db": {{Port: 5432}}}, processServicePorts(cfg.SelectedProcessServices()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ports) != 2 || ports["api"][0].Port != 8080 {
+		t.Errorf("ports = %v", ports)
+	}
+	if _, err := mergeServicePorts(map[string][]PortRequest{"api": {{Port: 80}}}, ports); err == nil {
+		t.Error("expected an error for a service defined in both mono.yml and compose")
+	}
+}
+
+func TestValidateServices(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"duplicate", Config{Services: []ServiceConfig{{Name: "api", Command: "a"}, {Name: "api", Command: "b"}}}},
+		{"window clash", Config{Services: []ServiceConfig{{Name: "api", Command: "a"}}, Tmux: TmuxConfig{Windows: []TmuxWindow{{Name: "api"}}}}},
+		{"bad port", Config{Services: []ServiceConfig{{Name: "api", Command: "a", Ports: []int{70000}}}}},
+		{"escaping working dir", Config{Services: []ServiceConfig{{Name: "api", Command: "a", WorkingDir: "../other"}}}},
+		{"two health checks", Config{Services: []ServiceConfig{{Name: "api", Command: "a", HealthCheck: HealthCheckConfig{Command: "true", HTTP: "/"}}}}},
+		{"http without port", Config{Services: []ServiceConfig{{Name: "api", Command: "a", HealthCheck: HealthCheckConfig{HTTP: "/health"}}}}},
+		{"bad timeout", Config{Services: []ServiceConfig{{Name: "api", Command: "a", HealthCheck: HealthCheckConfig{Command: "true", Timeout: "soon"}}}}},
+		{"tcp on another port", Config{Services: []ServiceConfig{{Name: "api", Command: "a", Ports: []int{8080}, HealthCheck: HealthCheckConfig{TCP: 9090}}}}},
+		{"compose check on a process service", Config{Services: []ServiceConfig{{Name: "api", Command: "a"}}, HealthChecks: map[string]HealthCheckConfig{"api": {TCP: 8080}}}},
+		{"self dependency", Config{Services: []ServiceConfig{{Name: "api", Command: "a", DependsOn: []string{"api"}}}}},
+		{"dependency cycle", Config{Services: []ServiceConfig{{Name: "api", Command: "a", DependsOn: []string{"worker"}}, {Name: "worker", Command: "b", DependsOn: []string{"api"}}}}},
+		{"compose check with two kinds", Config{HealthChecks: map[string]HealthCheckConfig{"db": {TCP: 5432, Command: "pg_isready"}}}},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.ValidateServices(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestRenderServiceScript(t *testing.T) {
+	svc := ServiceConfig{
+		Name:       "api",
+		Command:    "cargo run",
+		Ports:      []int{8080},
+		WorkingDir: "api",
+		Env: map[string]string{
+			"LOG":    "$MONO_ENV_NAME-debug",
+			"SECRET": "env:API_TOKEN",
+		},
+	}
+	allocations := []Allocation{{Service: "api", ContainerPort: 8080, HostPort: 19080, Protocol: ProtocolTCP}}
+	env := serviceEnv(svc, allocations, []string{"MONO_ENV_NAME=proj-feature"})
+
+	script := renderServiceScript(svc, "/envs/feature", env)
+	for _, want := range []string{
+		"cd '/envs/feature/api'\n",
+		"export PORT='19080'\n",
+		"export LOG='proj-feature-debug'\n",
+		`export SECRET="${API_TOKEN}"` + "\n",
+		"cargo run\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestWaitForService(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	port, err := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := ServiceConfig{
+		Name:        "api",
+		Ports:       []int{8080},
+		HealthCheck: HealthCheckConfig{HTTP: "/health", Interval: "10ms", Timeout: "5s"},
+	}
+	allocations := []Allocation{{Service: "api", ContainerPort: 8080, HostPort: port, Protocol: ProtocolTCP}}
+	if err := waitForService(svc, t.TempDir(), allocations, nil); err != nil {
+		t.Fatalf("waitForService failed: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("health check called %d times, want 3", calls)
+	}
+
+	failing := ServiceConfig{
+		Name:        "worker",
+		HealthCheck: HealthCheckConfig{Command: "test -f ready", Interval: "10ms", Timeout: "50ms"},
+	}
+	if err := waitForService(failing, t.TempDir(), nil, nil); err == nil || !strings.Contains(err.Error(), "did not become healthy") {
+		t.Errorf("err = %v, want a health timeout", err)
+	}
+}
+
+type fakeHealthRuntime struct {
+	ContainerRuntime
+	polls  int
+	health []string
+}
+
+func (r *fakeHealthRuntime) ContainerStatuses(projectName string) ([]ContainerStatus, error) {
+	health := r.health[min(r.polls, len(r.health)-1)]
+	r.polls++
+	return []ContainerStatus{{Service: "cache", Name: projectName + "-cache-1", State: "running", Health: health}}, nil
+}
+
+func TestWaitForDependencies(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, err := NewFileLogger("health-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	project := &types.Project{
+		WorkingDir: t.TempDir(),
+		Services: types.Services{
+			"db":    {Name: "db"},
+			"cache": {Name: "cache", HealthCheck: &types.HealthCheckConfig{Test: types.HealthCheckTest{"CMD", "redis-cli", "ping"}}},
+			"web":   {Name: "web"},
+		},
+	}
+	cfg := &Config{HealthChecks: map[string]HealthCheckConfig{"db": {TCP: 5432, Interval: "10ms", Timeout: "5s"}}}
+	allocations := []Allocation{{Service: "db", ContainerPort: 5432, HostPort: port, Protocol: ProtocolTCP}}
+	containers := &fakeHealthRuntime{health: []string{"starting", "healthy"}}
+
+	var progress strings.Builder
+	if err := waitForDependencies(cfg, project, containers, "mono-proj-feature", allocations, nil, &progress, logger); err != nil {
+		t.Fatalf("waitForDependencies failed: %v", err)
+	}
+	if containers.polls != 2 {
+		t.Errorf("compose health polled %d times, want 2", containers.polls)
+	}
+	for _, want := range []string{"Waiting for db (tcp 5432)...", "db: healthy after", "Waiting for cache (compose healthcheck)...", "cache: healthy after"} {
+		if !strings.Contains(progress.String(), want) {
+			t.Errorf("progress missing %q:\n%s", want, progress.String())
+		}
+	}
+	if strings.Contains(progress.String(), "web") {
+		t.Errorf("web has no health check but was waited for:\n%s", progress.String())
+	}
+
+	listener.Close()
+	cfg.HealthChecks["db"] = HealthCheckConfig{TCP: 5432, Interval: "10ms", Timeout: "50ms"}
+	project.Services = types.Services{"db": {Name: "db"}}
+	if err := waitForDependencies(cfg, project, containers, "mono-proj-feature", allocations, nil, io.Discard, logger); err == nil || !strings.Contains(err.Error(), "did not become healthy") {
+		t.Errorf("err = %v, want a health timeout", err)
+	}
+}
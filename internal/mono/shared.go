@@ -0,0 +1,145 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+type SharedPath struct {
+	Path   string `yaml:"path" mono:"required"`
+	Source string `yaml:"source"`
+}
+
+func (sp SharedPath) Validate() error {
+	if sp.Path == "" {
+		return fmt.Errorf("shared entry is missing path")
+	}
+	clean := filepath.Clean(sp.Path)
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid shared path %q: must be relative to the environment", sp.Path)
+	}
+	return nil
+}
+
+func (sp SharedPath) ResolveSource(rootPath string) (string, error) {
+	source := sp.Source
+	if source == "" {
+		if rootPath == "" {
+			return "", fmt.Errorf("shared path %s has no source and no project root is known", sp.Path)
+		}
+		return filepath.Join(rootPath, sp.Path), nil
+	}
+	if source == "~" || strings.HasPrefix(source, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		source = filepath.Join(home, strings.TrimPrefix(source, "~"))
+	}
+	if !filepath.IsAbs(source) {
+		if rootPath == "" {
+			return "", fmt.Errorf("shared source %s is relative and no project root is known", sp.Source)
+		}
+		source = filepath.Join(rootPath, source)
+	}
+	return filepath.Clean(source), nil
+}
+
+func LinkSharedPaths(shared []SharedPath, envPath, rootPath string, logger *FileLogger) error {
+	for _, sp := range shared {
+		if err := sp.Validate(); err != nil {
+			return err
+		}
+		source, err := sp.ResolveSource(rootPath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(envPath, sp.Path)
+		if source == target {
+			continue
+		}
+
+		if _, err := os.Stat(source); err != nil {
+			logger.Log("warning: skipping shared path %s: %v", sp.Path, err)
+			continue
+		}
+
+		if link, err := os.Readlink(target); err == nil {
+			if link == source {
+				continue
+			}
+			if err := os.Remove(target); err != nil {
+				return fmt.Errorf("failed to replace shared link %s: %w", target, err)
+			}
+		} else if _, err := os.Lstat(target); err == nil {
+			logger.Log("warning: %s already exists in the environment, not replacing it with a shared link", sp.Path)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create parent of %s: %w", target, err)
+		}
+		if err := os.Symlink(source, target); err != nil {
+			return fmt.Errorf("failed to link shared path %s: %w", sp.Path, err)
+		}
+		logger.Log("linked shared path %s -> %s", sp.Path, source)
+	}
+	return nil
+}
+
+func UnlinkSharedPaths(shared []SharedPath, envPath, rootPath string) error {
+	for _, sp := range shared {
+		if err := sp.Validate(); err != nil {
+			return err
+		}
+		source, err := sp.ResolveSource(rootPath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(envPath, sp.Path)
+		link, err := os.Readlink(target)
+		if err != nil || link != source {
+			continue
+		}
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("failed to remove shared link %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func MountSharedReadOnly(project *types.Project, shared []SharedPath, envPath string) {
+	if len(shared) == 0 {
+		return
+	}
+
+	var targets []string
+	for _, sp := range shared {
+		if sp.Validate() == nil {
+			targets = append(targets, filepath.Join(envPath, sp.Path))
+		}
+	}
+
+	for name, svc := range project.Services {
+		changed := false
+		for i, vol := range svc.Volumes {
+			if vol.Type != types.VolumeTypeBind {
+				continue
+			}
+			for _, target := range targets {
+				if vol.Source == target || strings.HasPrefix(vol.Source, target+string(filepath.Separator)) {
+					svc.Volumes[i].ReadOnly = true
+					changed = true
+					break
+				}
+			}
+		}
+		if changed {
+			project.Services[name] = svc
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestLinkSharedPaths(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger, err := NewFileLogger("shared-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	root := t.TempDir()
+	env := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "data", "fixtures"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(env, "local"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	shared := []SharedPath{
+		{Path: "data/fixtures"},
+		{Path: "missing"},
+		{Path: "local"},
+	}
+	if err := LinkSharedPaths(shared, env, root, logger); err != nil {
+		t.Fatalf("LinkSharedPaths failed: %v", err)
+	}
+
+	link, err := os.Readlink(filepath.Join(env, "data", "fixtures"))
+	if err != nil || link != filepath.Join(root, "data", "fixtures") {
+		t.Errorf("fixtures link = %q, %v", link, err)
+	}
+	if _, err := os.Lstat(filepath.Join(env, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing source should not be linked, got %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(env, "local")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("existing directory should be left alone, got %v", err)
+	}
+
+	if err := LinkSharedPaths(shared, env, root, logger); err != nil {
+		t.Fatalf("relinking failed: %v", err)
+	}
+
+	if err := UnlinkSharedPaths(shared, env, root); err != nil {
+		t.Fatalf("UnlinkSharedPaths failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(env, "data", "fixtures")); !os.IsNotExist(err) {
+		t.Errorf("link should be removed, got %v", err)
+	}
+	if !dirExists(filepath.Join(root, "data", "fixtures")) || !dirExists(filepath.Join(env, "local")) {
+		t.Error("unlinking must not touch real directories")
+	}
+
+	if err := LinkSharedPaths([]SharedPath{{Path: "../escape"}}, env, root, logger); err == nil {
+		t.Error("expected error for a path outside the environment")
+	}
+}
+
+func TestMountSharedReadOnly(t *testing.T) {
+	project := &types.Project{
+		Services: types.Services{
+			"api": {
+				Name: "api",
+				Volumes: []types.ServiceVolumeConfig{
+					{Type: types.VolumeTypeBind, Source: "/env/data/fixtures/users", Target: "/fixtures"},
+					{Type: types.VolumeTypeBind, Source: "/env/src", Target: "/src"},
+					{Type: types.VolumeTypeVolume, Source: "pgdata", Target: "/var/lib/postgresql/data"},
+				},
+			},
+		},
+	}
+
+	MountSharedReadOnly(project, []SharedPath{{Path: "data/fixtures"}}, "/env")
+
+	volumes := project.Services["api"].Volumes
+	if !volumes[0].ReadOnly {
+		t.Error("bind mount inside a shared path should be read-only")
+	}
+	if volumes[1].ReadOnly || volumes[2].ReadOnly {
+		t.Error("other mounts should be left writable")
+	}
+}
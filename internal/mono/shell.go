@@ -0,0 +1,125 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type ShellOptions struct {
+	Command string
+}
+
+func ShellEnv(ec *EnvContext, base []string) []string {
+	values := make(map[string]string)
+	var order []string
+	set := func(kv string) {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return
+		}
+		if _, seen := values[k]; !seen {
+			order = append(order, k)
+		}
+		values[k] = v
+	}
+
+	for _, kv := range base {
+		set(kv)
+	}
+	for _, kv := range ec.Vars {
+		set(kv)
+	}
+
+	urls := ServiceURLs(ec.Project, ec.Allocations)
+	urlKeys := make([]string, 0, len(urls))
+	for k := range urls {
+		urlKeys = append(urlKeys, k)
+	}
+	sort.Strings(urlKeys)
+	for _, k := range urlKeys {
+		set(k + "=" + urls[k])
+	}
+
+	if len(ec.Config.Direnv.Path) > 0 {
+		var dirs []string
+		for _, p := range ec.Config.Direnv.Path {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(ec.Env.Path, p)
+			}
+			dirs = append(dirs, p)
+		}
+		if current := values["PATH"]; current != "" {
+			dirs = append(dirs, current)
+		}
+		set("PATH=" + strings.Join(dirs, string(os.PathListSeparator)))
+	}
+	set("MONO_SHELL=" + ec.Env.EnvName())
+
+	result := make([]string, 0, len(order))
+	for _, k := range order {
+		result = append(result, k+"="+values[k])
+	}
+	return result
+}
+
+func Shell(path string, opts ShellOptions) error {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return err
+	}
+
+	if current := os.Getenv("MONO_SHELL"); current != "" && opts.Command == "" {
+		return fmt.Errorf("already inside the mono shell for %s, exit it first", current)
+	}
+
+	db, err := OpenDB()
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.TouchEnvironment(ec.Env.ID); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	secrets, err := ResolveSecrets(ec.Config.Env)
+	if err != nil {
+		return err
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	shellPath, err := exec.LookPath(shell)
+	if err != nil {
+		return fmt.Errorf("failed to find shell %s: %w", shell, err)
+	}
+
+	args := []string{shellPath}
+	if opts.Command != "" {
+		args = append(args, "-c", opts.Command)
+	}
+	if ec.Config.Nix.Enabled {
+		args = ec.Config.Nix.Command(args...)
+		shellPath, err = exec.LookPath(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to find %s: %w", args[0], err)
+		}
+	}
+
+	if err := os.Chdir(ec.Env.Path); err != nil {
+		return fmt.Errorf("failed to enter %s: %w", ec.Env.Path, err)
+	}
+	if err := syscall.Exec(shellPath, args, withSecrets(ShellEnv(ec, os.Environ()), secrets)); err != nil {
+		return fmt.Errorf("failed to start %s: %w", shellPath, err)
+	}
+	return nil
+}
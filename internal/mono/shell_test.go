@@ -0,0 +1,40 @@
+package mono
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShellEnv(t *testing.T) {
+	ec := &EnvContext{
+		Env:    &Environment{Path: "/envs/feature"},
+		Config: &Config{Direnv: DirenvConfig{Path: []string{"node_modules/.bin", "/opt/tools"}}},
+		Vars:   []string{"MONO_ENV_NAME=feature", "PORT_WEB=19000", "HOME=/tmp/data"},
+	}
+
+	env := ShellEnv(ec, []string{"HOME=/home/me", "PATH=/usr/bin", "TERM=xterm"})
+
+	values := make(map[string]string)
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if _, dup := values[k]; dup {
+			t.Errorf("duplicate variable %s", k)
+		}
+		values[k] = v
+	}
+
+	if values["HOME"] != "/tmp/data" {
+		t.Errorf("HOME = %q, want env override", values["HOME"])
+	}
+	if values["TERM"] != "xterm" || values["PORT_WEB"] != "19000" {
+		t.Errorf("expected base and env variables, got %v", values)
+	}
+	wantPath := strings.Join([]string{"/envs/feature/node_modules/.bin", "/opt/tools", "/usr/bin"}, string(os.PathListSeparator))
+	if values["PATH"] != wantPath {
+		t.Errorf("PATH = %q, want %q", values["PATH"], wantPath)
+	}
+	if values["MONO_SHELL"] != "feature" {
+		t.Errorf("MONO_SHELL = %q, want feature", values["MONO_SHELL"])
+	}
+}
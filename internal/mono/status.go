@@ -0,0 +1,178 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type ArtifactStatus struct {
+	Name     string     `json:"name"`
+	Key      string     `json:"key"`
+	Hit      bool       `json:"hit"`
+	LastSync *time.Time `json:"last_sync,omitempty"`
+	Warnings []string   `json:"warnings,omitempty"`
+}
+
+type StatusReport struct {
+	Name            string            `json:"name"`
+	Path            string            `json:"path"`
+	RootPath        string            `json:"root_path"`
+	Branch          string            `json:"branch,omitempty"`
+	Template        string            `json:"template,omitempty"`
+	Profile         string            `json:"profile,omitempty"`
+	Session         string            `json:"session"`
+	TmuxRunning     bool              `json:"tmux_running"`
+	DockerProject   string            `json:"docker_project,omitempty"`
+	Containers      []ContainerStatus `json:"containers"`
+	ContainersError string            `json:"containers_error,omitempty"`
+	Ports           []PortStatus      `json:"ports"`
+	Artifacts       []ArtifactStatus  `json:"artifacts"`
+	ArtifactsError  string            `json:"artifacts_error,omitempty"`
+	StartupPlan     []ServiceStep     `json:"startup_plan"`
+	PlanError       string            `json:"startup_plan_error,omitempty"`
+}
+
+func Status(path string) (*StatusReport, error) {
+	env, ports, err := GetPortStatus(path)
+	if err != nil {
+		return nil, err
+	}
+
+	envName := env.EnvName()
+	report := &StatusReport{
+		Name:        envName,
+		Path:        env.Path,
+		RootPath:    env.RootPath.String,
+		Branch:      env.Branch.String,
+		Template:    env.Template.String,
+		Profile:     env.Profile.String,
+		Session:     SessionName(envName),
+		TmuxRunning: SessionExists(SessionName(envName)),
+		Containers:  []ContainerStatus{},
+		Ports:       ports,
+		Artifacts:   []ArtifactStatus{},
+		StartupPlan: []ServiceStep{},
+	}
+
+	if env.DockerProject.Valid && env.DockerProject.String != "" {
+		report.DockerProject = env.DockerProject.String
+		containers, err := containerStatuses(env)
+		if err != nil {
+			report.ContainersError = err.Error()
+		} else if containers != nil {
+			report.Containers = containers
+		}
+	}
+
+	if report.RootPath != "" {
+		artifacts, err := artifactStatuses(env)
+		if err != nil {
+			report.ArtifactsError = err.Error()
+		} else {
+			report.Artifacts = artifacts
+		}
+	}
+
+	plan, err := startupPlan(env)
+	if err != nil {
+		report.PlanError = err.Error()
+	} else if plan != nil {
+		report.StartupPlan = plan
+	}
+
+	return report, nil
+}
+
+func startupPlan(env *Environment) ([]ServiceStep, error) {
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyDefaults(env.Path)
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return nil, err
+	}
+	return environmentPlan(cfg, env)
+}
+
+func artifactStatuses(env *Environment) ([]ArtifactStatus, error) {
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ApplyDefaults(env.Path)
+	if err := cfg.ApplyTemplate(env.Template.String); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyProfile(env.Profile.String); err != nil {
+		return nil, err
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+
+	entries, err := cm.PrepareArtifactCache(cfg.Build.Artifacts, env.RootPath.String, env.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute cache keys: %w", err)
+	}
+
+	statuses := make([]ArtifactStatus, 0, len(entries))
+	for _, entry := range entries {
+		lastSync, err := lastArtifactSync(filepath.Join(cm.GetProjectCacheDir(env.RootPath.String), entry.Name))
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, ArtifactStatus{
+			Name:     entry.Name,
+			Key:      entry.Key,
+			Hit:      entry.Hit,
+			LastSync: lastSync,
+			Warnings: entry.Warnings,
+		})
+	}
+	return statuses, nil
+}
+
+func lastArtifactSync(artifactDir string) (*time.Time, error) {
+	keys, err := os.ReadDir(artifactDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", artifactDir, err)
+	}
+
+	var latest *time.Time
+	for _, key := range keys {
+		if !key.IsDir() {
+			continue
+		}
+		m, err := ReadCacheManifest(filepath.Join(artifactDir, key.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || m.CreatedAt.After(*latest) {
+			t := m.CreatedAt
+			latest = &t
+		}
+	}
+	return latest, nil
+}
+
+func containerStatuses(env *Environment) ([]ContainerStatus, error) {
+	containers, err := env.Runtime()
+	if err != nil {
+		return nil, err
+	}
+	return containers.ContainerStatuses(env.DockerProject.String)
+}
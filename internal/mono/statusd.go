@@ -0,0 +1,368 @@
+package mono
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statusdFile          = "status.txt"
+	statusdWriteTimeout  = time.Second
+	DefaultStatusdPeriod = 2 * time.Second
+)
+
+type StatusdOptions struct {
+	Output   string
+	Socket   string
+	Interval time.Duration
+	JSON     bool
+}
+
+type DesktopTask struct {
+	Env     string    `json:"env"`
+	Command string    `json:"command"`
+	PID     int       `json:"pid,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+func (t DesktopTask) describe(now time.Time) string {
+	s := fmt.Sprintf("%s in %s", t.Command, t.Env)
+	if t.PID > 0 {
+		s += fmt.Sprintf(" (pid %d, %s)", t.PID, now.Sub(t.Since).Round(time.Second))
+	} else {
+		s += fmt.Sprintf(" (%s)", now.Sub(t.Since).Round(time.Second))
+	}
+	return s
+}
+
+type DesktopStatus struct {
+	Time        time.Time     `json:"time"`
+	Current     string        `json:"current,omitempty"`
+	CurrentPath string        `json:"current_path,omitempty"`
+	Syncing     []DesktopTask `json:"syncing"`
+	Building    []DesktopTask `json:"building"`
+	Warnings    []string      `json:"warnings,omitempty"`
+}
+
+func (s DesktopStatus) Idle() bool {
+	return len(s.Syncing) == 0 && len(s.Building) == 0
+}
+
+func (s DesktopStatus) Title() string {
+	parts := []string{"mono"}
+	if s.Current != "" {
+		parts = append(parts, s.Current)
+	}
+	if len(s.Syncing) > 0 {
+		parts = append(parts, "· syncing")
+	}
+	if len(s.Building) > 0 {
+		parts = append(parts, fmt.Sprintf("· building %d", len(s.Building)))
+	}
+	if s.Idle() {
+		parts = append(parts, "· idle")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (s DesktopStatus) Text() string {
+	var b strings.Builder
+	fmt.Fprintln(&b, s.Title())
+	fmt.Fprintln(&b, "---")
+	if s.Current != "" {
+		fmt.Fprintf(&b, "env: %s\n", s.Current)
+	} else {
+		fmt.Fprintln(&b, "env: -")
+	}
+	for _, t := range s.Syncing {
+		fmt.Fprintf(&b, "syncing: %s\n", t.describe(s.Time))
+	}
+	for _, t := range s.Building {
+		fmt.Fprintf(&b, "building: %s\n", t.describe(s.Time))
+	}
+	if s.Idle() {
+		fmt.Fprintln(&b, "nothing running, safe to close")
+	}
+	for _, w := range s.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	fmt.Fprintf(&b, "updated: %s\n", s.Time.Local().Format("15:04:05"))
+	return b.String()
+}
+
+func (s DesktopStatus) Render(asJSON bool) ([]byte, error) {
+	if !asJSON {
+		return []byte(s.Text()), nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode status: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func CollectDesktopStatus() (*DesktopStatus, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	environments, err := db.ListEnvironments()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	names := make(map[string]string, len(environments))
+	for _, env := range environments {
+		names[env.Path] = env.EnvName()
+	}
+
+	status := &DesktopStatus{Time: time.Now(), Syncing: []DesktopTask{}, Building: []DesktopTask{}}
+	if env := currentEnvironment(environments, activeMonoSession()); env != nil {
+		status.Current, status.CurrentPath = env.EnvName(), env.Path
+	}
+
+	activities, err := RunningActivities()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range activities {
+		t := DesktopTask{Env: a.Env, Command: "mono " + a.Operation, PID: a.PID, Since: a.Started}
+		if name, ok := names[a.Path]; ok {
+			t.Env = name
+		}
+		if a.Detail != "" {
+			t.Command += " " + a.Detail
+		}
+		if a.Operation == ActivitySync {
+			status.Syncing = append(status.Syncing, t)
+		} else {
+			status.Building = append(status.Building, t)
+		}
+	}
+
+	builds, err := BuildQueueStatus()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range builds {
+		if !e.Waiting {
+			status.Building = append(status.Building, DesktopTask{Env: e.Env, Command: e.Command, PID: e.PID, Since: e.Since})
+		}
+	}
+
+	jobs, err := runningDaemonJobs()
+	if err != nil {
+		status.Warnings = append(status.Warnings, err.Error())
+	}
+	for _, j := range jobs {
+		env := DeriveEnvName(j.Dir)
+		if name, ok := names[j.Dir]; ok {
+			env = name
+		}
+		status.Building = append(status.Building, DesktopTask{Env: env, Command: j.Command(), Since: j.Started})
+	}
+	sort.SliceStable(status.Building, func(i, j int) bool { return status.Building[i].Since.Before(status.Building[j].Since) })
+
+	return status, nil
+}
+
+func currentEnvironment(environments []*Environment, session string) *Environment {
+	var recent *Environment
+	for _, env := range environments {
+		if session != "" && SessionName(env.EnvName()) == session {
+			return env
+		}
+		if env.LastUsed.Valid && (recent == nil || env.LastUsed.Time.After(recent.LastUsed.Time)) {
+			recent = env
+		}
+	}
+	return recent
+}
+
+func activeMonoSession() string {
+	output, err := Command("tmux", "list-clients", "-F", "#{client_activity}\t#{session_name}").
+		Timeout(tmuxTimeout).
+		Output()
+	if err != nil {
+		return ""
+	}
+
+	var session string
+	var latest int64
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		activity, name, ok := strings.Cut(line, "\t")
+		if !ok || !strings.HasPrefix(name, "mono-") {
+			continue
+		}
+		at, err := strconv.ParseInt(activity, 10, 64)
+		if err != nil {
+			continue
+		}
+		if session == "" || at > latest {
+			session, latest = name, at
+		}
+	}
+	return session
+}
+
+func runningDaemonJobs() ([]Job, error) {
+	socket, err := DefaultDaemonSocket()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(socket); os.IsNotExist(err) {
+		return nil, nil
+	}
+	client, err := NewDaemonClient(socket)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := client.Jobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daemon jobs: %w", err)
+	}
+	var running []Job
+	for _, j := range jobs {
+		if j.Status == JobRunning {
+			running = append(running, j)
+		}
+	}
+	return running, nil
+}
+
+func DefaultStatusdOutput() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, statusdFile), nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+type statusdServer struct {
+	mu      sync.Mutex
+	current []byte
+}
+
+func (s *statusdServer) set(data []byte) {
+	s.mu.Lock()
+	s.current = data
+	s.mu.Unlock()
+}
+
+func (s *statusdServer) serve(listener net.Listener, logger *FileLogger) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		data := bytes.Clone(s.current)
+		s.mu.Unlock()
+		if err := conn.SetWriteDeadline(time.Now().Add(statusdWriteTimeout)); err != nil {
+			logger.Log("warning: statusd: %v", err)
+		} else if _, err := conn.Write(data); err != nil {
+			logger.Log("warning: statusd: failed to write status: %v", err)
+		}
+		if err := conn.Close(); err != nil {
+			logger.Log("warning: statusd: failed to close connection: %v", err)
+		}
+	}
+}
+
+func RunStatusd(ctx context.Context, opts StatusdOptions) error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("invalid interval %s: must be positive", opts.Interval)
+	}
+
+	logger, err := NewFileLogger("statusd")
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+
+	server := &statusdServer{}
+	if opts.Socket != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.Socket), 0755); err != nil {
+			return fmt.Errorf("failed to create socket directory: %w", err)
+		}
+		if err := removeStaleSocket(opts.Socket); err != nil {
+			return err
+		}
+		listener, err := net.Listen("unix", opts.Socket)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", opts.Socket, err)
+		}
+		defer os.Remove(opts.Socket)
+		defer listener.Close()
+		go server.serve(listener, logger)
+	}
+
+	logger.Log("statusd writing to %s every %s", opts.Output, opts.Interval)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		status, err := CollectDesktopStatus()
+		if err != nil {
+			logger.Log("warning: statusd: %v", err)
+			status = &DesktopStatus{Time: time.Now(), Syncing: []DesktopTask{}, Building: []DesktopTask{}, Warnings: []string{err.Error()}}
+		}
+		data, err := status.Render(opts.JSON)
+		if err != nil {
+			return err
+		}
+		server.set(data)
+		if opts.Output != "" {
+			if err := writeFileAtomic(opts.Output, data); err != nil {
+				logger.Log("warning: statusd: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Log("statusd stopped")
+			if opts.Output == "" {
+				return nil
+			}
+			if err := os.Remove(opts.Output); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove %s: %w", opts.Output, err)
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
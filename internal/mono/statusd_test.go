@@ -0,0 +1,140 @@
+package mono
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunningActivitiesSkipsDeadProcesses(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+
+	envPath := filepath.Join(t.TempDir(), "workspaces", "app", "feature")
+	marker, err := StartActivity(ActivitySync, "", envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := activityPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "stale.json")
+	data, err := json.Marshal(Activity{PID: cmd.Process.Pid, Operation: ActivityInit, Path: envPath, Started: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	activities, err := RunningActivities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 1 || activities[0].Operation != ActivitySync || activities[0].Env != "app-feature" {
+		t.Fatalf("RunningActivities() = %+v, want one sync in app-feature", activities)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale marker still present: %v", err)
+	}
+
+	if err := marker.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if err := marker.Done(); err != nil {
+		t.Fatalf("second Done() error = %v", err)
+	}
+	activities, err = RunningActivities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activities) != 0 {
+		t.Errorf("RunningActivities() after Done = %+v, want none", activities)
+	}
+}
+
+func TestCollectDesktopStatus(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+
+	envPath := filepath.Join(t.TempDir(), "feature")
+	syncing, err := StartActivity(ActivitySync, "", envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := StartActivity(ActivityTask, "build", envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := CollectDesktopStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Idle() {
+		t.Fatal("status is idle, want sync and build running")
+	}
+	if got := status.Title(); got != "mono · syncing · building 1" {
+		t.Errorf("Title() = %q", got)
+	}
+	text := status.Text()
+	for _, want := range []string{"---\n", "syncing: mono sync in feature (pid ", "building: mono task build in feature (pid "} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() = %q, want it to contain %q", text, want)
+		}
+	}
+
+	if err := syncing.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Done(); err != nil {
+		t.Fatal(err)
+	}
+	status, err = CollectDesktopStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Idle() || !strings.Contains(status.Text(), "safe to close") {
+		t.Errorf("Text() = %q, want idle status", status.Text())
+	}
+
+	data, err := status.Render(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded DesktopStatus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Syncing == nil || decoded.Building == nil {
+		t.Errorf("Render(true) = %s, want empty lists rather than null", data)
+	}
+}
+
+func TestCurrentEnvironment(t *testing.T) {
+	older := &Environment{Path: "/w/app/older"}
+	older.LastUsed.Valid, older.LastUsed.Time = true, time.Now().Add(-time.Hour)
+	newer := &Environment{Path: "/w/app/newer"}
+	newer.LastUsed.Valid, newer.LastUsed.Time = true, time.Now()
+	envs := []*Environment{older, newer}
+
+	if got := currentEnvironment(envs, ""); got != newer {
+		t.Errorf("currentEnvironment() without session = %v, want most recently used", got.Path)
+	}
+	if got := currentEnvironment(envs, SessionName("older")); got != older {
+		t.Errorf("currentEnvironment() with session = %v, want attached env", got.Path)
+	}
+	if got := currentEnvironment(nil, ""); got != nil {
+		t.Errorf("currentEnvironment(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,541 @@
+package mono
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const taskCachePrefix = "task-"
+
+type TaskConfig struct {
+	Name       string              `yaml:"name" mono:"required"`
+	Command    string              `yaml:"command" mono:"required"`
+	WorkingDir string              `yaml:"working_dir"`
+	Inputs     []string            `yaml:"inputs"`
+	Outputs    []string            `yaml:"outputs"`
+	Remote     string              `yaml:"remote"`
+	Matrix     map[string][]string `yaml:"matrix"`
+	Parallel   int                 `yaml:"parallel"`
+	Publish    []string            `yaml:"publish"`
+
+	base  string
+	cells []MatrixCell
+}
+
+func (t TaskConfig) Dir(envPath string) string {
+	return filepath.Join(envPath, t.WorkingDir)
+}
+
+func (t TaskConfig) Validate() error {
+	if t.Command == "" {
+		return fmt.Errorf("task %s has no command", t.Name)
+	}
+	if strings.Contains(t.Name, "@") {
+		return fmt.Errorf("task %s: names can't contain @, it selects matrix combinations", t.Name)
+	}
+	if err := t.validateMatrix(); err != nil {
+		return err
+	}
+	for _, inst := range t.Expand() {
+		if err := inst.validatePaths(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t TaskConfig) validatePaths() error {
+	if t.WorkingDir != "" {
+		if err := validatePatterns("task "+t.Name, "working_dir", []string{t.WorkingDir}); err != nil {
+			return err
+		}
+	}
+	if err := validatePatterns("task "+t.Name, "input", t.Inputs); err != nil {
+		return err
+	}
+	for _, o := range t.Outputs {
+		if strings.ContainsAny(o, "*?[") {
+			return fmt.Errorf("task %s: invalid output %q: outputs are paths, not globs", t.Name, o)
+		}
+	}
+	if err := validatePatterns("task "+t.Name, "output", t.Outputs); err != nil {
+		return err
+	}
+	if len(t.Outputs) > 0 && len(t.Inputs) == 0 {
+		return fmt.Errorf("task %s has outputs but no inputs to key them on", t.Name)
+	}
+	return t.validatePublish()
+}
+
+func (c *Config) ValidateTasks() error {
+	seen := make(map[string]bool)
+	for _, t := range c.Tasks {
+		if seen[t.Name] {
+			return fmt.Errorf("task %s is defined more than once", t.Name)
+		}
+		seen[t.Name] = true
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashTaskInputs(t TaskConfig, dir string) (string, error) {
+	files, err := globFiles(dir, cleanPatterns(t.Inputs))
+	if err != nil {
+		return "", fmt.Errorf("failed to collect inputs of task %s: %w", t.Name, err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", t.Command, t.WorkingDir, strings.Join(t.Outputs, "\x00"))
+	for _, c := range t.cells {
+		fmt.Fprintf(h, "%s\x00", c)
+	}
+	for _, rel := range sortedKeys(files) {
+		fmt.Fprintf(h, "%s\x00", rel)
+		if _, err := hashFile(h, filepath.Join(dir, rel)); err != nil {
+			return "", fmt.Errorf("failed to hash input %s of task %s: %w", rel, t.Name, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func taskOutputsPresent(t TaskConfig, dir string) bool {
+	for _, o := range t.Outputs {
+		if _, err := os.Lstat(filepath.Join(dir, o)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := removeAllAudited(dst); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyDir(src, dst)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Chmod(dst, info.Mode())
+}
+
+func storeTaskResult(t TaskConfig, dir, cachePath, envPath string) error {
+	tmp := cachePath + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", tmp, err)
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return fmt.Errorf("failed to create task cache entry: %w", err)
+	}
+	for _, o := range t.Outputs {
+		src := filepath.Join(dir, o)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			return fmt.Errorf("task %s did not produce its output %s", t.Name, o)
+		}
+		if err := copyPath(src, filepath.Join(tmp, o)); err != nil {
+			return fmt.Errorf("failed to cache output %s of task %s: %w", o, t.Name, err)
+		}
+	}
+	if err := WriteCacheManifest(tmp, taskCachePrefix+t.Name, filepath.Base(cachePath), envPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		if dirExists(cachePath) {
+			return os.RemoveAll(tmp)
+		}
+		return fmt.Errorf("failed to store result of task %s: %w", t.Name, err)
+	}
+	return nil
+}
+
+func restoreTaskResult(t TaskConfig, dir, cachePath string) error {
+	for _, o := range t.Outputs {
+		if err := copyPath(filepath.Join(cachePath, o), filepath.Join(dir, o)); err != nil {
+			return fmt.Errorf("failed to restore output %s of task %s: %w", o, t.Name, err)
+		}
+	}
+	return nil
+}
+
+type TaskOptions struct {
+	Force bool
+}
+
+const (
+	TaskUpToDate = "up to date"
+	TaskRestored = "restored"
+	TaskRan      = "ran"
+	TaskFailed   = "failed"
+)
+
+type TaskResult struct {
+	Task     string
+	Name     string
+	Matrix   []MatrixCell
+	Outcome  string
+	Duration time.Duration
+	Err      error
+}
+
+type taskRunner struct {
+	ec        *EnvContext
+	cm        *CacheManager
+	db        *DB
+	logger    *FileLogger
+	rootPath  string
+	markerDir string
+	env       []string
+	out       io.Writer
+	mu        sync.Mutex
+}
+
+func (r *taskRunner) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.out.Write(p)
+}
+
+func (r *taskRunner) report(format string, args ...any) {
+	fmt.Fprintf(r, format, args...)
+}
+
+func (r *taskRunner) markerPath(name string) string {
+	return filepath.Join(r.markerDir, name+".hash")
+}
+
+func (r *taskRunner) lastHash(name string) (string, error) {
+	data, err := os.ReadFile(r.markerPath(name))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read last run of task %s: %w", name, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (r *taskRunner) recordHash(name, hash string) error {
+	if err := os.WriteFile(r.markerPath(name), []byte(hash+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record run of task %s: %w", name, err)
+	}
+	return nil
+}
+
+func (r *taskRunner) exec(t TaskConfig, dir string) error {
+	if t.Remote != "" {
+		return r.execRemote(t, dir)
+	}
+	args := []string{"sh", "-c", t.Command}
+	if r.ec.Config.Nix.Enabled {
+		args = r.ec.Config.Nix.Command(args...)
+	}
+	slot, err := AcquireBuildSlot(r.ec.Config.BuildQueue, r.ec.Env.EnvName(), "task "+t.Name, r)
+	if err != nil {
+		return err
+	}
+	code, err := runWrappedCommand(args, slices.Concat(r.env, t.matrixEnv(), slot.Env()), dir)
+	slot.Release()
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("task %s failed with exit code %d", t.Name, code)
+	}
+	return nil
+}
+
+func (r *taskRunner) execRemote(t TaskConfig, dir string) error {
+	remote, err := r.ec.Config.remote(t.Remote)
+	if err != nil {
+		return fmt.Errorf("task %s: %w", t.Name, err)
+	}
+	if err := remote.CheckInstalled(); err != nil {
+		return err
+	}
+	envName := r.ec.Env.EnvName()
+	r.report("mono: syncing %s to %s\n", envName, remote.Host)
+	if err := remote.Push(t.Remote, envName, r.ec.Env.Path, r); err != nil {
+		return err
+	}
+
+	args := remote.commandArgs(envName, t.WorkingDir, remoteEnv(append(ShellEnv(r.ec, nil), t.matrixEnv()...)), t.Command, tunnelArgs("", r.ec.Allocations)...)
+	code, err := runWrappedCommand(args, slices.Concat(r.env, t.matrixEnv()), dir)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("task %s failed on %s with exit code %d", t.Name, remote.Host, code)
+	}
+
+	var outputs []string
+	for _, o := range t.Outputs {
+		outputs = append(outputs, filepath.Join(t.WorkingDir, o))
+	}
+	return remote.Pull(t.Remote, envName, r.ec.Env.Path, outputs, r)
+}
+
+func (r *taskRunner) run(t TaskConfig, opts TaskOptions) (outcome string, err error) {
+	timer := NewPhaseTimer(TaskTimingOperation(t.Name), r.ec.Env.EnvName(), r.rootPath)
+	defer func() {
+		timer.Finish(err)
+		succeeded := err == nil
+		if err := timer.Save(r.db, succeeded); err != nil {
+			r.logger.Log("warning: %v", err)
+		}
+	}()
+	marker, err := StartActivity(ActivityTask, t.Name, r.ec.Env.Path)
+	if err != nil {
+		return TaskFailed, err
+	}
+	defer func() {
+		err = errors.Join(err, marker.Done())
+	}()
+	dir := t.Dir(r.ec.Env.Path)
+	elapsed := func() time.Duration { return time.Since(timer.start).Round(time.Millisecond) }
+
+	if len(t.Inputs) == 0 {
+		r.report("mono: %s has no inputs, running\n", t.Name)
+		doneRun := timer.Start("run")
+		err := r.exec(t, dir)
+		doneRun()
+		if err != nil {
+			return TaskFailed, err
+		}
+		r.report("mono: %s done in %s\n", t.Name, elapsed())
+		return TaskRan, nil
+	}
+
+	doneHash := timer.Start("hash inputs")
+	hash, err := hashTaskInputs(t, dir)
+	doneHash()
+	if err != nil {
+		return TaskFailed, err
+	}
+	cacheName := taskCachePrefix + t.Name
+	cachePath := r.cm.GetArtifactCachePath(r.rootPath, cacheName, hash)
+	projectID := ComputeProjectID(r.rootPath)
+
+	if !opts.Force {
+		last, err := r.lastHash(t.Name)
+		if err != nil {
+			return TaskFailed, err
+		}
+		if last == hash && taskOutputsPresent(t, dir) {
+			r.report("mono: %s is up to date (%.12s), nothing to do\n", t.Name, hash)
+			r.logger.Log("task %s up to date (key: %s)", t.Name, hash)
+			if err := r.publishOutputs(t, dir, hash); err != nil {
+				return TaskFailed, err
+			}
+			return TaskUpToDate, nil
+		}
+		if dirExists(cachePath) {
+			restoreStart := time.Now()
+			doneRestore := timer.Start("restore outputs")
+			err := restoreTaskResult(t, dir, cachePath)
+			doneRestore()
+			if err != nil {
+				return TaskFailed, err
+			}
+			if err := r.cm.recordCacheEvent(EventRestore, cachePath, r.ec.Env.Path, restoreStart, nil); err != nil {
+				r.logger.Log("warning: failed to record restore event: %v", err)
+			}
+			if err := r.recordHash(t.Name, hash); err != nil {
+				return TaskFailed, err
+			}
+			if err := r.db.RecordCacheEvent("hit", projectID, cacheName, hash); err != nil {
+				r.logger.Log("warning: failed to record cache hit: %v", err)
+			}
+			r.report("mono: %s restored from cache (%.12s) in %s\n", t.Name, hash, elapsed())
+			r.logger.Log("task %s restored from cache (key: %s)", t.Name, hash)
+			if err := r.publishOutputs(t, dir, hash); err != nil {
+				return TaskFailed, err
+			}
+			return TaskRestored, nil
+		}
+	}
+
+	if err := r.db.RecordCacheEvent("miss", projectID, cacheName, hash); err != nil {
+		r.logger.Log("warning: failed to record cache miss: %v", err)
+	}
+	r.report("mono: running %s (%.12s)\n", t.Name, hash)
+	doneRun := timer.Start("run")
+	err = r.exec(t, dir)
+	doneRun()
+	if err != nil {
+		return TaskFailed, err
+	}
+	storeStart := time.Now()
+	doneStore := timer.Start("store outputs")
+	err = storeTaskResult(t, dir, cachePath, r.ec.Env.Path)
+	doneStore()
+	if err != nil {
+		return TaskFailed, err
+	}
+	if err := r.cm.recordCacheEvent(EventStore, cachePath, r.ec.Env.Path, storeStart, nil); err != nil {
+		r.logger.Log("warning: failed to record store event: %v", err)
+	}
+	if err := r.recordHash(t.Name, hash); err != nil {
+		return TaskFailed, err
+	}
+	r.report("mono: %s done in %s, cached as %.12s\n", t.Name, elapsed(), hash)
+	r.logger.Log("task %s ran and was cached (key: %s)", t.Name, hash)
+	if err := r.publishOutputs(t, dir, hash); err != nil {
+		return TaskFailed, err
+	}
+	return TaskRan, nil
+}
+
+func RunTasks(path string, names []string, opts TaskOptions, out io.Writer) ([]TaskResult, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := ec.Config
+	if err := cfg.ValidateTasks(); err != nil {
+		return nil, fmt.Errorf("invalid mono.yml: %w", err)
+	}
+	if err := cfg.ValidateRemotes(); err != nil {
+		return nil, fmt.Errorf("invalid mono.yml: %w", err)
+	}
+
+	var defined []string
+	for _, t := range cfg.Tasks {
+		defined = append(defined, t.Name)
+	}
+	type selection struct {
+		task      TaskConfig
+		instances []TaskConfig
+	}
+	var selected []selection
+	for _, name := range names {
+		base, matrix, _ := strings.Cut(name, "@")
+		i := slices.IndexFunc(cfg.Tasks, func(t TaskConfig) bool { return t.Name == base })
+		if i < 0 {
+			return nil, unknownSelection("task", base, defined)
+		}
+		instances, err := selectMatrixInstances(cfg.Tasks[i], matrix)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, selection{task: cfg.Tasks[i], instances: instances})
+	}
+
+	envName := ec.Env.EnvName()
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono task %s %s", path, strings.Join(names, " "))
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mono home: %w", err)
+	}
+	markerDir := filepath.Join(monoHome, "data", envName, "tasks")
+	if err := os.MkdirAll(markerDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task directory: %w", err)
+	}
+	secrets, err := ResolveSecrets(cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	rootPath := ec.Env.RootPath.String
+	if rootPath == "" {
+		rootPath = ec.Env.Path
+	}
+	r := &taskRunner{
+		ec:        ec,
+		cm:        cm,
+		db:        db,
+		logger:    logger,
+		rootPath:  rootPath,
+		markerDir: markerDir,
+		env:       withSecrets(ShellEnv(ec, os.Environ()), secrets),
+		out:       out,
+	}
+	var results []TaskResult
+	for _, s := range selected {
+		taskResults, err := r.runInstances(s.task, s.instances, opts)
+		results = append(results, taskResults...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func (r *taskRunner) runInstances(t TaskConfig, instances []TaskConfig, opts TaskOptions) ([]TaskResult, error) {
+	results := make([]TaskResult, len(instances))
+	var g errgroup.Group
+	g.SetLimit(t.matrixParallel())
+	for i, inst := range instances {
+		g.Go(func() error {
+			start := time.Now()
+			outcome, err := r.run(inst, opts)
+			results[i] = TaskResult{Task: t.Name, Name: inst.Name, Matrix: inst.cells, Outcome: outcome, Duration: time.Since(start), Err: err}
+			if err != nil && len(t.Matrix) > 0 {
+				r.report("mono: %s failed: %v\n", inst.Name, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return results, err
+	}
+	if len(t.Matrix) == 0 {
+		return results, results[0].Err
+	}
+
+	var failed []string
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res.Name)
+		}
+	}
+	if len(failed) > 0 {
+		r.logger.Log("task %s failed for %s", t.Name, strings.Join(failed, ", "))
+		return results, fmt.Errorf("task %s failed for %d of %d combination(s): %s", t.Name, len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return results, nil
+}
@@ -0,0 +1,204 @@
+package mono
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	matrixRefPrefix       = "${matrix."
+	matrixEnvPrefix       = "MONO_MATRIX_"
+	defaultMatrixParallel = 1
+)
+
+var (
+	matrixKeyPattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	matrixValuePattern = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+)
+
+type MatrixCell struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (c MatrixCell) String() string {
+	return c.Key + "=" + c.Value
+}
+
+func (t TaskConfig) matrixParallel() int {
+	if t.Parallel == 0 {
+		return defaultMatrixParallel
+	}
+	return t.Parallel
+}
+
+func matrixRefs(s string) []string {
+	var refs []string
+	for {
+		start := strings.Index(s, matrixRefPrefix)
+		if start < 0 {
+			return refs
+		}
+		s = s[start+len(matrixRefPrefix):]
+		end := strings.Index(s, "}")
+		if end < 0 {
+			return refs
+		}
+		refs = append(refs, s[:end])
+		s = s[end+1:]
+	}
+}
+
+func (t TaskConfig) matrixFields() []string {
+	return slices.Concat([]string{t.Command, t.WorkingDir}, t.Inputs, t.Outputs, t.Publish)
+}
+
+func (t TaskConfig) validateMatrix() error {
+	if len(t.Matrix) == 0 {
+		if t.Parallel != 0 {
+			return fmt.Errorf("task %s sets parallel but has no matrix", t.Name)
+		}
+		for _, f := range t.matrixFields() {
+			if refs := matrixRefs(f); len(refs) > 0 {
+				return fmt.Errorf("task %s uses ${matrix.%s} but has no matrix", t.Name, refs[0])
+			}
+		}
+		return nil
+	}
+	if t.Parallel < 0 {
+		return fmt.Errorf("task %s: invalid parallel %d: must be positive", t.Name, t.Parallel)
+	}
+	if t.Remote != "" && t.matrixParallel() > 1 {
+		return fmt.Errorf("task %s runs on remote %s and can't run its matrix in parallel", t.Name, t.Remote)
+	}
+	for _, key := range sortedKeys(t.Matrix) {
+		if !matrixKeyPattern.MatchString(key) {
+			return fmt.Errorf("task %s: invalid matrix key %q: use letters, digits and underscores", t.Name, key)
+		}
+		values := t.Matrix[key]
+		if len(values) == 0 {
+			return fmt.Errorf("task %s: matrix.%s has no values", t.Name, key)
+		}
+		seen := make(map[string]bool)
+		for _, v := range values {
+			if !matrixValuePattern.MatchString(v) {
+				return fmt.Errorf("task %s: invalid matrix.%s value %q: use letters, digits, '.', '_', '+' and '-'", t.Name, key, v)
+			}
+			if seen[v] {
+				return fmt.Errorf("task %s: matrix.%s lists %s more than once", t.Name, key, v)
+			}
+			seen[v] = true
+		}
+	}
+	for _, f := range t.matrixFields() {
+		for _, ref := range matrixRefs(f) {
+			if _, ok := t.Matrix[ref]; !ok {
+				return fmt.Errorf("task %s: ${matrix.%s}: %w", t.Name, ref, unknownSelection("matrix key", ref, sortedKeys(t.Matrix)))
+			}
+		}
+	}
+	return nil
+}
+
+func (t TaskConfig) Expand() []TaskConfig {
+	if len(t.Matrix) == 0 {
+		return []TaskConfig{t}
+	}
+	combos := [][]MatrixCell{nil}
+	for _, key := range sortedKeys(t.Matrix) {
+		var next [][]MatrixCell
+		for _, combo := range combos {
+			for _, v := range t.Matrix[key] {
+				next = append(next, append(slices.Clone(combo), MatrixCell{Key: key, Value: v}))
+			}
+		}
+		combos = next
+	}
+
+	instances := make([]TaskConfig, 0, len(combos))
+	for _, combo := range combos {
+		instances = append(instances, t.instance(combo))
+	}
+	return instances
+}
+
+func (t TaskConfig) instance(combo []MatrixCell) TaskConfig {
+	pairs := make([]string, 0, 2*len(combo))
+	labels := make([]string, 0, len(combo))
+	for _, c := range combo {
+		pairs = append(pairs, matrixRefPrefix+c.Key+"}", c.Value)
+		labels = append(labels, c.String())
+	}
+	r := strings.NewReplacer(pairs...)
+	replaceAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		out := make([]string, len(values))
+		for i, v := range values {
+			out[i] = r.Replace(v)
+		}
+		return out
+	}
+
+	inst := t
+	inst.Name = t.Name + "@" + strings.Join(labels, ",")
+	inst.Command = r.Replace(t.Command)
+	inst.WorkingDir = r.Replace(t.WorkingDir)
+	inst.Inputs = replaceAll(t.Inputs)
+	inst.Outputs = replaceAll(t.Outputs)
+	inst.Publish = replaceAll(t.Publish)
+	inst.Matrix = nil
+	inst.Parallel = 0
+	inst.base = t.Name
+	inst.cells = combo
+	return inst
+}
+
+func (t TaskConfig) BaseName() string {
+	if t.base != "" {
+		return t.base
+	}
+	return t.Name
+}
+
+func (t TaskConfig) matrixEnv() []string {
+	env := make([]string, 0, len(t.cells))
+	for _, c := range t.cells {
+		env = append(env, matrixEnvPrefix+strings.ToUpper(c.Key)+"="+c.Value)
+	}
+	return env
+}
+
+func selectMatrixInstances(t TaskConfig, selector string) ([]TaskConfig, error) {
+	instances := t.Expand()
+	if selector == "" {
+		return instances, nil
+	}
+	if len(t.Matrix) == 0 {
+		return nil, fmt.Errorf("task %s has no matrix to select %s from", t.Name, selector)
+	}
+	var want []MatrixCell
+	for _, part := range strings.Split(selector, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid matrix selection %q: expected key=value", part)
+		}
+		if _, defined := t.Matrix[key]; !defined {
+			return nil, fmt.Errorf("task %s: %w", t.Name, unknownSelection("matrix key", key, sortedKeys(t.Matrix)))
+		}
+		if !slices.Contains(t.Matrix[key], value) {
+			return nil, fmt.Errorf("task %s: %w", t.Name, unknownSelection("matrix."+key+" value", value, t.Matrix[key]))
+		}
+		want = append(want, MatrixCell{Key: key, Value: value})
+	}
+	var selected []TaskConfig
+	for _, inst := range instances {
+		if !slices.ContainsFunc(want, func(c MatrixCell) bool { return !slices.Contains(inst.cells, c) }) {
+			selected = append(selected, inst)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,142 @@
+package mono
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTaskMatrixExpand(t *testing.T) {
+	task := TaskConfig{
+		Name:    "build",
+		Command: "cargo build --profile ${matrix.profile} --target ${matrix.target}",
+		Inputs:  []string{"src"},
+		Outputs: []string{"target/${matrix.target}/${matrix.profile}"},
+		Matrix:  map[string][]string{"profile": {"dev", "release"}, "target": {"x86_64", "aarch64"}},
+	}
+
+	instances := task.Expand()
+	var names []string
+	for _, inst := range instances {
+		names = append(names, inst.Name)
+	}
+	want := []string{
+		"build@profile=dev,target=x86_64",
+		"build@profile=dev,target=aarch64",
+		"build@profile=release,target=x86_64",
+		"build@profile=release,target=aarch64",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("instances = %v, want %v", names, want)
+	}
+
+	last := instances[3]
+	if last.Command != "cargo build --profile release --target aarch64" {
+		t.Errorf("command = %q", last.Command)
+	}
+	if !slices.Equal(last.Outputs, []string{"target/aarch64/release"}) {
+		t.Errorf("outputs = %v", last.Outputs)
+	}
+	if !slices.Equal(last.matrixEnv(), []string{"MONO_MATRIX_PROFILE=release", "MONO_MATRIX_TARGET=aarch64"}) {
+		t.Errorf("env = %v", last.matrixEnv())
+	}
+	if last.BaseName() != "build" || task.Outputs[0] != "target/${matrix.target}/${matrix.profile}" {
+		t.Errorf("expanding changed the task: base %q, outputs %v", last.BaseName(), task.Outputs)
+	}
+
+	selected, err := selectMatrixInstances(task, "target=aarch64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 || selected[0].Name != want[1] || selected[1].Name != want[3] {
+		t.Errorf("selected = %v, want the aarch64 combinations", selected)
+	}
+	if _, err := selectMatrixInstances(task, "profile=bench"); err == nil || !strings.Contains(err.Error(), "available: dev, release") {
+		t.Errorf("selecting an unknown value error = %v", err)
+	}
+}
+
+func TestTaskMatrixRunsEveryCombination(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("task-matrix-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	envPath := t.TempDir()
+	writeBuildxFixture(t, envPath, map[string]string{"src/main.rs": "fn main() {}"})
+	task := TaskConfig{
+		Name:     "build",
+		Command:  `test "$MONO_MATRIX_PROFILE" != bench && mkdir -p out/${matrix.profile} && echo $MONO_MATRIX_PROFILE > out/${matrix.profile}/bin`,
+		Inputs:   []string{"src"},
+		Outputs:  []string{"out/${matrix.profile}"},
+		Matrix:   map[string][]string{"profile": {"dev", "release", "bench"}},
+		Parallel: 2,
+	}
+
+	var out bytes.Buffer
+	r := &taskRunner{
+		ec:        &EnvContext{Env: &Environment{Path: envPath}, Config: &Config{}},
+		cm:        cm,
+		db:        db,
+		logger:    logger,
+		rootPath:  envPath,
+		markerDir: t.TempDir(),
+		env:       os.Environ(),
+		out:       &out,
+	}
+
+	results, err := r.runInstances(task, task.Expand(), TaskOptions{})
+	if err == nil || !strings.Contains(err.Error(), "failed for 1 of 3 combination(s): build@profile=bench") {
+		t.Fatalf("runInstances() error = %v", err)
+	}
+	outcomes := make(map[string]string)
+	for _, res := range results {
+		outcomes[res.Matrix[0].Value] = res.Outcome
+	}
+	if outcomes["dev"] != TaskRan || outcomes["release"] != TaskRan || outcomes["bench"] != TaskFailed {
+		t.Errorf("outcomes = %v", outcomes)
+	}
+	for _, profile := range []string{"dev", "release"} {
+		data, err := os.ReadFile(filepath.Join(envPath, "out", profile, "bin"))
+		if err != nil || strings.TrimSpace(string(data)) != profile {
+			t.Errorf("output of %s = %q, %v", profile, data, err)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(envPath, "out")); err != nil {
+		t.Fatal(err)
+	}
+	results, err = selectAndRun(r, task, "profile=release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Outcome != TaskRestored {
+		t.Errorf("results = %+v, want release restored from its own cache entry", results)
+	}
+	if data, err := os.ReadFile(filepath.Join(envPath, "out", "release", "bin")); err != nil || strings.TrimSpace(string(data)) != "release" {
+		t.Errorf("restored output = %q, %v", data, err)
+	}
+}
+
+func selectAndRun(r *taskRunner, task TaskConfig, selector string) ([]TaskResult, error) {
+	instances, err := selectMatrixInstances(task, selector)
+	if err != nil {
+		return nil, err
+	}
+	return r.runInstances(task, instances, TaskOptions{})
+}
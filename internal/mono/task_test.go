@@ -0,0 +1,142 @@
+package mono
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTaskResultCaching(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("task-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	envPath := t.TempDir()
+	runs := filepath.Join(t.TempDir(), "runs")
+	writeBuildxFixture(t, envPath, map[string]string{
+		"proto/users.proto": "message User {}",
+		"proto/README.md":   "docs",
+	})
+	task := TaskConfig{
+		Name:    "codegen",
+		Command: "mkdir -p gen && cat proto/*.proto > gen/users.pb && echo run >> " + runs,
+		Inputs:  []string{"proto/**/*.proto"},
+		Outputs: []string{"gen"},
+	}
+
+	var out bytes.Buffer
+	r := &taskRunner{
+		ec:        &EnvContext{Env: &Environment{Path: envPath}, Config: &Config{}},
+		cm:        cm,
+		db:        db,
+		logger:    logger,
+		rootPath:  envPath,
+		markerDir: t.TempDir(),
+		env:       os.Environ(),
+		out:       &out,
+	}
+	run := func(opts TaskOptions, wantRuns int, wantOutput string) {
+		t.Helper()
+		out.Reset()
+		if _, err := r.run(task, opts); err != nil {
+			t.Fatalf("run() error = %v", err)
+		}
+		data, err := os.ReadFile(runs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "run"); got != wantRuns {
+			t.Errorf("command ran %d times, want %d", got, wantRuns)
+		}
+		if !strings.Contains(out.String(), wantOutput) {
+			t.Errorf("output = %q, want %q", out.String(), wantOutput)
+		}
+	}
+
+	run(TaskOptions{}, 1, "running codegen")
+	run(TaskOptions{}, 1, "nothing to do")
+
+	writeBuildxFixture(t, envPath, map[string]string{"proto/README.md": "more docs"})
+	run(TaskOptions{}, 1, "nothing to do")
+
+	if err := os.RemoveAll(filepath.Join(envPath, "gen")); err != nil {
+		t.Fatal(err)
+	}
+	run(TaskOptions{}, 1, "restored from cache")
+	if data, err := os.ReadFile(filepath.Join(envPath, "gen", "users.pb")); err != nil || string(data) != "message User {}" {
+		t.Errorf("restored output = %q, %v", data, err)
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"proto/users.proto": "message User { string name = 1; }"})
+	run(TaskOptions{}, 2, "running codegen")
+
+	writeBuildxFixture(t, envPath, map[string]string{"proto/users.proto": "message User {}"})
+	run(TaskOptions{}, 2, "restored from cache")
+	if data, err := os.ReadFile(filepath.Join(envPath, "gen", "users.pb")); err != nil || string(data) != "message User {}" {
+		t.Errorf("output after switching inputs back = %q, %v", data, err)
+	}
+
+	run(TaskOptions{Force: true}, 3, "running codegen")
+
+	task.Command = "exit 2"
+	task.Inputs = []string{"proto"}
+	if _, err := r.run(task, TaskOptions{}); err == nil || !strings.Contains(err.Error(), "exit code 2") {
+		t.Errorf("run() of a failing task error = %v", err)
+	}
+}
+
+func TestValidateTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   []TaskConfig
+		wantErr string
+	}{
+		{"valid", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src/**/*.c"}, Outputs: []string{"bin/app"}}}, ""},
+		{"duplicate", []TaskConfig{{Name: "build", Command: "make"}, {Name: "build", Command: "make"}}, "more than once"},
+		{"no command", []TaskConfig{{Name: "build"}}, "no command"},
+		{"glob output", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src"}, Outputs: []string{"bin/*"}}}, "not globs"},
+		{"output outside", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src"}, Outputs: []string{"../bin"}}}, "must be inside the environment"},
+		{"outputs without inputs", []TaskConfig{{Name: "build", Command: "make", Outputs: []string{"bin"}}}, "no inputs"},
+		{"matrix", []TaskConfig{{Name: "build", Command: "cargo build --profile ${matrix.profile}", Inputs: []string{"src"}, Outputs: []string{"target/${matrix.profile}"}, Matrix: map[string][]string{"profile": {"dev", "release"}}, Parallel: 2}}, ""},
+		{"unknown matrix key", []TaskConfig{{Name: "build", Command: "cargo build --target ${matrix.target}", Matrix: map[string][]string{"profile": {"dev"}}}}, `unknown matrix key "target"`},
+		{"matrix ref without matrix", []TaskConfig{{Name: "build", Command: "cargo build --profile ${matrix.profile}"}}, "has no matrix"},
+		{"parallel without matrix", []TaskConfig{{Name: "build", Command: "make", Parallel: 2}}, "parallel but has no matrix"},
+		{"empty matrix values", []TaskConfig{{Name: "build", Command: "make", Matrix: map[string][]string{"profile": nil}}}, "has no values"},
+		{"unsafe matrix value", []TaskConfig{{Name: "build", Command: "make", Matrix: map[string][]string{"node": {"20 LTS"}}}}, "invalid matrix.node value"},
+		{"matrix output outside", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src"}, Outputs: []string{"${matrix.dir}/bin"}, Matrix: map[string][]string{"dir": {".."}}}}, "must be inside the environment"},
+		{"at in name", []TaskConfig{{Name: "build@dev", Command: "make"}}, "can't contain @"},
+		{"publish", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src"}, Outputs: []string{"bin"}, Publish: []string{"bin/app"}}}, ""},
+		{"publish outside outputs", []TaskConfig{{Name: "build", Command: "make", Inputs: []string{"src"}, Outputs: []string{"bin"}, Publish: []string{"binaries/app"}}}, "not one of its outputs"},
+		{"publish without outputs", []TaskConfig{{Name: "build", Command: "make", Publish: []string{"bin/app"}}}, "has no outputs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Tasks: tt.tasks}
+			err := cfg.ValidateTasks()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTasks() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateTasks() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
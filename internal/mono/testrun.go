@@ -0,0 +1,455 @@
+package mono
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	testCachePrefix     = "test-"
+	testPackageVar      = "{package}"
+	testOutputFile      = "output.log"
+	defaultTestParallel = 1
+)
+
+type TestSuiteConfig struct {
+	Name         string   `yaml:"name" mono:"required"`
+	Command      string   `yaml:"command" mono:"required"`
+	WorkingDir   string   `yaml:"working_dir"`
+	Packages     []string `yaml:"packages"`
+	Inputs       []string `yaml:"inputs"`
+	SharedInputs []string `yaml:"shared_inputs"`
+}
+
+func (s TestSuiteConfig) Dir(envPath string) string {
+	return filepath.Join(envPath, s.WorkingDir)
+}
+
+func (s TestSuiteConfig) inputs() []string {
+	if len(s.Inputs) == 0 {
+		return []string{"**"}
+	}
+	return cleanPatterns(s.Inputs)
+}
+
+type TestConfig struct {
+	Parallel int               `yaml:"parallel"`
+	Suites   []TestSuiteConfig `yaml:"suites"`
+}
+
+func (tc *TestConfig) ApplyDefaults() {
+	if tc.Parallel == 0 {
+		tc.Parallel = defaultTestParallel
+	}
+}
+
+func (tc TestConfig) Validate() error {
+	if tc.Parallel < 0 {
+		return fmt.Errorf("invalid test.parallel %d: must be positive", tc.Parallel)
+	}
+	seen := make(map[string]bool)
+	for _, s := range tc.Suites {
+		if seen[s.Name] {
+			return fmt.Errorf("test suite %s is defined more than once", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Command == "" {
+			return fmt.Errorf("test suite %s has no command", s.Name)
+		}
+		if len(s.Packages) > 0 && !strings.Contains(s.Command, testPackageVar) {
+			return fmt.Errorf("test suite %s lists packages but its command has no %s", s.Name, testPackageVar)
+		}
+		owner := "test suite " + s.Name
+		if s.WorkingDir != "" {
+			if err := validatePatterns(owner, "working_dir", []string{s.WorkingDir}); err != nil {
+				return err
+			}
+		}
+		if err := validatePatterns(owner, "package", s.Packages); err != nil {
+			return err
+		}
+		if err := validatePatterns(owner, "input", s.Inputs); err != nil {
+			return err
+		}
+		if err := validatePatterns(owner, "shared input", s.SharedInputs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type TestShard struct {
+	Index int
+	Total int
+}
+
+func ParseTestShard(s string) (TestShard, error) {
+	if s == "" {
+		return TestShard{Index: 1, Total: 1}, nil
+	}
+	index, total, ok := strings.Cut(s, "/")
+	i, errIndex := strconv.Atoi(index)
+	n, errTotal := strconv.Atoi(total)
+	if !ok || errIndex != nil || errTotal != nil || n < 1 || i < 1 || i > n {
+		return TestShard{}, fmt.Errorf("invalid shard %q: expected <index>/<total>, e.g. 2/4", s)
+	}
+	return TestShard{Index: i, Total: n}, nil
+}
+
+func (sh TestShard) owns(i int) bool {
+	return i%sh.Total == sh.Index-1
+}
+
+func globDirs(dir string, patterns []string) ([]string, error) {
+	var roots []string
+	for _, p := range patterns {
+		if root := globRoot(p); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+
+	var dirs []string
+	for _, root := range roots {
+		start := filepath.Join(dir, root)
+		err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != start && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if slices.Contains(dirs, rel) {
+				return nil
+			}
+			for _, p := range patterns {
+				if matchGlob(p, rel) {
+					dirs = append(dirs, rel)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find packages under %s: %w", root, err)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs, nil
+}
+
+type testPackage struct {
+	Suite   TestSuiteConfig
+	Path    string
+	Command string
+	Hash    string
+}
+
+func (p testPackage) Name() string {
+	if p.Path == "" {
+		return p.Suite.Name
+	}
+	return p.Suite.Name + ":" + p.Path
+}
+
+func hashTestInputs(h hash.Hash, dir string, patterns []string) error {
+	files, err := globFiles(dir, patterns)
+	if err != nil {
+		return err
+	}
+	for _, rel := range sortedKeys(files) {
+		fmt.Fprintf(h, "%s\x00", rel)
+		if _, err := hashFile(h, filepath.Join(dir, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func planTestPackages(s TestSuiteConfig, envPath string) ([]testPackage, error) {
+	dir := s.Dir(envPath)
+	paths := []string{""}
+	if len(s.Packages) > 0 {
+		found, err := globDirs(dir, cleanPatterns(s.Packages))
+		if err != nil {
+			return nil, err
+		}
+		paths = found
+	}
+
+	shared := sha256.New()
+	if err := hashTestInputs(shared, dir, cleanPatterns(s.SharedInputs)); err != nil {
+		return nil, fmt.Errorf("failed to hash shared inputs of test suite %s: %w", s.Name, err)
+	}
+	sharedSum := shared.Sum(nil)
+
+	var packages []testPackage
+	for _, p := range paths {
+		command := s.Command
+		pkgDir := dir
+		if p != "" {
+			command = strings.ReplaceAll(command, testPackageVar, shellQuote("./"+p))
+			pkgDir = filepath.Join(dir, p)
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", command, s.WorkingDir, p)
+		h.Write(sharedSum)
+		if err := hashTestInputs(h, pkgDir, s.inputs()); err != nil {
+			return nil, fmt.Errorf("failed to hash inputs of %s: %w", p, err)
+		}
+		packages = append(packages, testPackage{Suite: s, Path: p, Command: command, Hash: hex.EncodeToString(h.Sum(nil))})
+	}
+	return packages, nil
+}
+
+type TestResult struct {
+	Package  string
+	Passed   bool
+	Cached   bool
+	Duration time.Duration
+	Output   string
+}
+
+type TestOptions struct {
+	NoCache  bool
+	Shard    TestShard
+	Parallel int
+}
+
+type testRunner struct {
+	ec       *EnvContext
+	cm       *CacheManager
+	db       *DB
+	logger   *FileLogger
+	rootPath string
+	env      []string
+	out      io.Writer
+	mu       sync.Mutex
+}
+
+func (r *testRunner) report(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, args...)
+}
+
+func (r *testRunner) run(p testPackage, noCache bool) (TestResult, error) {
+	result := TestResult{Package: p.Name()}
+	cacheName := testCachePrefix + p.Suite.Name
+	cachePath := r.cm.GetArtifactCachePath(r.rootPath, cacheName, p.Hash)
+	projectID := ComputeProjectID(r.rootPath)
+
+	if !noCache && dirExists(cachePath) {
+		if err := r.db.RecordCacheEvent("hit", projectID, cacheName, p.Hash); err != nil {
+			r.logger.Log("warning: failed to record cache hit: %v", err)
+		}
+		result.Passed, result.Cached = true, true
+		r.report("ok      %s (cached)\n", result.Package)
+		return result, nil
+	}
+	if err := r.db.RecordCacheEvent("miss", projectID, cacheName, p.Hash); err != nil {
+		r.logger.Log("warning: failed to record cache miss: %v", err)
+	}
+
+	args := []string{"sh", "-c", p.Command}
+	if r.ec.Config.Nix.Enabled {
+		args = r.ec.Config.Nix.Command(args...)
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = p.Suite.Dir(r.ec.Env.Path)
+	cmd.Env = r.env
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start).Round(time.Millisecond)
+	result.Output = output.String()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.Passed = true
+	case !errors.As(err, &exitErr):
+		return result, fmt.Errorf("failed to run tests of %s: %w", result.Package, err)
+	}
+
+	if !result.Passed {
+		r.report("FAIL    %s (%s)\n%s", result.Package, result.Duration, indentOutput(result.Output))
+		r.logger.Log("tests of %s failed (key: %s)", result.Package, p.Hash)
+		return result, nil
+	}
+	if err := storeTestResult(cachePath, result.Output, r.ec.Env.Path, cacheName, p.Hash); err != nil {
+		return result, err
+	}
+	r.report("ok      %s (%s)\n", result.Package, result.Duration)
+	r.logger.Log("tests of %s passed and were cached (key: %s)", result.Package, p.Hash)
+	return result, nil
+}
+
+func indentOutput(output string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	return b.String()
+}
+
+func storeTestResult(cachePath, output, envPath, cacheName, hash string) error {
+	tmp := cachePath + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", tmp, err)
+	}
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return fmt.Errorf("failed to create test cache entry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, testOutputFile), []byte(output), 0644); err != nil {
+		return fmt.Errorf("failed to write test output: %w", err)
+	}
+	if err := WriteCacheManifest(tmp, cacheName, hash, envPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, cachePath); err != nil {
+		if dirExists(cachePath) {
+			return os.RemoveAll(tmp)
+		}
+		return fmt.Errorf("failed to store test result: %w", err)
+	}
+	return nil
+}
+
+func RunTests(path string, suites []string, opts TestOptions, out io.Writer) ([]TestResult, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := ec.Config
+	if err := cfg.Test.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid mono.yml: %w", err)
+	}
+	if len(cfg.Test.Suites) == 0 {
+		return nil, fmt.Errorf("no test suites defined in mono.yml")
+	}
+
+	selected := cfg.Test.Suites
+	if len(suites) > 0 {
+		var defined []string
+		for _, s := range cfg.Test.Suites {
+			defined = append(defined, s.Name)
+		}
+		selected = nil
+		for _, name := range suites {
+			i := slices.IndexFunc(cfg.Test.Suites, func(s TestSuiteConfig) bool { return s.Name == name })
+			if i < 0 {
+				return nil, unknownSelection("test suite", name, defined)
+			}
+			selected = append(selected, cfg.Test.Suites[i])
+		}
+	}
+
+	var packages []testPackage
+	for _, s := range selected {
+		planned, err := planTestPackages(s, ec.Env.Path)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, planned...)
+	}
+	var owned []testPackage
+	for i, p := range packages {
+		if opts.Shard.owns(i) {
+			owned = append(owned, p)
+		}
+	}
+
+	envName := ec.Env.EnvName()
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono test %s: %d of %d package(s) in shard %d/%d", path, len(owned), len(packages), opts.Shard.Index, opts.Shard.Total)
+
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	cm, err := NewCacheManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	secrets, err := ResolveSecrets(cfg.Env)
+	if err != nil {
+		return nil, err
+	}
+	rootPath := ec.Env.RootPath.String
+	if rootPath == "" {
+		rootPath = ec.Env.Path
+	}
+
+	r := &testRunner{
+		ec:       ec,
+		cm:       cm,
+		db:       db,
+		logger:   logger,
+		rootPath: rootPath,
+		env:      withSecrets(ShellEnv(ec, os.Environ()), secrets),
+		out:      out,
+	}
+	parallel := opts.Parallel
+	if parallel == 0 {
+		parallel = cfg.Test.Parallel
+	}
+
+	results := make([]TestResult, len(owned))
+	var g errgroup.Group
+	g.SetLimit(max(parallel, 1))
+	for i, p := range owned {
+		g.Go(func() error {
+			result, err := r.run(p, opts.NoCache)
+			results[i] = result
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return results, err
+	}
+
+	var failed []string
+	for _, result := range results {
+		if !result.Passed {
+			failed = append(failed, result.Package)
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%d of %d package(s) failed: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return results, nil
+}
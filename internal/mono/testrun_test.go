@@ -0,0 +1,251 @@
+package mono
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTestShard(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    TestShard
+		wantErr bool
+	}{
+		{"", TestShard{Index: 1, Total: 1}, false},
+		{"1/1", TestShard{Index: 1, Total: 1}, false},
+		{"2/4", TestShard{Index: 2, Total: 4}, false},
+		{"0/4", TestShard{}, true},
+		{"5/4", TestShard{}, true},
+		{"2", TestShard{}, true},
+		{"a/b", TestShard{}, true},
+		{"1/0", TestShard{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTestShard(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTestShard(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTestShard(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTestShardOwnsEachPackageOnce(t *testing.T) {
+	const total, packages = 3, 10
+	owners := make([]int, packages)
+	for index := 1; index <= total; index++ {
+		shard := TestShard{Index: index, Total: total}
+		for i := range packages {
+			if shard.owns(i) {
+				owners[i]++
+			}
+		}
+	}
+	for i, n := range owners {
+		if n != 1 {
+			t.Errorf("package %d is owned by %d shards, want 1", i, n)
+		}
+	}
+}
+
+func TestTestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TestConfig
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			config: TestConfig{Suites: []TestSuiteConfig{{Name: "go", Command: "go test {package}", Packages: []string{"pkg/*"}}}},
+		},
+		{
+			name:    "duplicate suite",
+			config:  TestConfig{Suites: []TestSuiteConfig{{Name: "go", Command: "go test ./..."}, {Name: "go", Command: "go test ./..."}}},
+			wantErr: "defined more than once",
+		},
+		{
+			name:    "packages without placeholder",
+			config:  TestConfig{Suites: []TestSuiteConfig{{Name: "go", Command: "go test ./...", Packages: []string{"pkg/*"}}}},
+			wantErr: "has no {package}",
+		},
+		{
+			name:    "package outside environment",
+			config:  TestConfig{Suites: []TestSuiteConfig{{Name: "go", Command: "go test {package}", Packages: []string{"../pkg"}}}},
+			wantErr: "must be inside the environment",
+		},
+		{
+			name:    "negative parallel",
+			config:  TestConfig{Parallel: -1},
+			wantErr: "invalid test.parallel",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanTestPackagesHashesPerPackage(t *testing.T) {
+	envPath := t.TempDir()
+	writeBuildxFixture(t, envPath, map[string]string{
+		"go.mod":               "module example",
+		"pkg/users/users.go":   "package users",
+		"pkg/orders/orders.go": "package orders",
+		"docs/README.md":       "docs",
+	})
+	suite := TestSuiteConfig{
+		Name:         "go",
+		Command:      "go test {package}",
+		Packages:     []string{"pkg/*"},
+		SharedInputs: []string{"go.mod"},
+	}
+	plan := func() map[string]string {
+		t.Helper()
+		packages, err := planTestPackages(suite, envPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes := make(map[string]string)
+		for _, p := range packages {
+			hashes[p.Name()] = p.Hash
+		}
+		return hashes
+	}
+
+	before := plan()
+	if len(before) != 2 || before["go:pkg/users"] == "" || before["go:pkg/orders"] == "" {
+		t.Fatalf("planned packages = %v, want go:pkg/users and go:pkg/orders", before)
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"pkg/users/users.go": "package users // changed"})
+	afterPackage := plan()
+	if afterPackage["go:pkg/users"] == before["go:pkg/users"] {
+		t.Error("changing pkg/users did not change its hash")
+	}
+	if afterPackage["go:pkg/orders"] != before["go:pkg/orders"] {
+		t.Error("changing pkg/users changed the hash of pkg/orders")
+	}
+
+	writeBuildxFixture(t, envPath, map[string]string{"go.mod": "module example\n\ngo 1.24"})
+	afterShared := plan()
+	for name, hash := range afterShared {
+		if hash == afterPackage[name] {
+			t.Errorf("changing a shared input did not change the hash of %s", name)
+		}
+	}
+}
+
+func TestTestRunnerCachesPassingPackages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("test-runner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	envPath := t.TempDir()
+	runs := filepath.Join(t.TempDir(), "runs")
+	writeBuildxFixture(t, envPath, map[string]string{
+		"pkg/good/ok":   "0",
+		"pkg/bad/ok":    "1",
+		"pkg/bad/notes": "x",
+	})
+	suite := TestSuiteConfig{
+		Name:     "unit",
+		Command:  "echo run >> " + runs + " && cd {package} && exit $(cat ok)",
+		Packages: []string{"pkg/*"},
+	}
+
+	var out bytes.Buffer
+	r := &testRunner{
+		ec:       &EnvContext{Env: &Environment{Path: envPath}, Config: &Config{}},
+		cm:       cm,
+		db:       db,
+		logger:   logger,
+		rootPath: envPath,
+		env:      os.Environ(),
+		out:      &out,
+	}
+	runAll := func(noCache bool) map[string]TestResult {
+		t.Helper()
+		packages, err := planTestPackages(suite, envPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := make(map[string]TestResult)
+		for _, p := range packages {
+			result, err := r.run(p, noCache)
+			if err != nil {
+				t.Fatalf("run(%s) error = %v", p.Name(), err)
+			}
+			results[result.Package] = result
+		}
+		return results
+	}
+	countRuns := func() int {
+		t.Helper()
+		data, err := os.ReadFile(runs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "run")
+	}
+
+	first := runAll(false)
+	if !first["unit:pkg/good"].Passed || first["unit:pkg/good"].Cached {
+		t.Errorf("first run of pkg/good = %+v, want passed and not cached", first["unit:pkg/good"])
+	}
+	if first["unit:pkg/bad"].Passed {
+		t.Errorf("first run of pkg/bad passed, want failure")
+	}
+	if !strings.Contains(out.String(), "FAIL    unit:pkg/bad") {
+		t.Errorf("output = %q, want a FAIL line for pkg/bad", out.String())
+	}
+	if got := countRuns(); got != 2 {
+		t.Fatalf("commands ran %d times, want 2", got)
+	}
+
+	second := runAll(false)
+	if !second["unit:pkg/good"].Cached {
+		t.Error("second run of pkg/good was not served from the cache")
+	}
+	if second["unit:pkg/bad"].Cached || second["unit:pkg/bad"].Passed {
+		t.Errorf("second run of pkg/bad = %+v, want a fresh failure", second["unit:pkg/bad"])
+	}
+	if got := countRuns(); got != 3 {
+		t.Fatalf("commands ran %d times, want 3", got)
+	}
+
+	third := runAll(true)
+	if third["unit:pkg/good"].Cached {
+		t.Error("run with no cache reused a cached result")
+	}
+	if got := countRuns(); got != 5 {
+		t.Fatalf("commands ran %d times, want 5", got)
+	}
+}
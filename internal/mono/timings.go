@@ -0,0 +1,360 @@
+package mono
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const timingsSchema = `
+CREATE TABLE IF NOT EXISTS timing_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    env_name TEXT NOT NULL,
+    project TEXT NOT NULL,
+    operation TEXT NOT NULL,
+    started_at TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    succeeded INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_timing_runs_operation ON timing_runs(project, operation, started_at);
+CREATE TABLE IF NOT EXISTS timing_phases (
+    run_id INTEGER NOT NULL REFERENCES timing_runs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    phase TEXT NOT NULL,
+    duration_ms INTEGER NOT NULL,
+    PRIMARY KEY (run_id, position)
+);
+`
+
+const (
+	TimingInit        = "init"
+	TimingSync        = "sync"
+	TimingDBRestore   = "db restore"
+	timingTaskPrefix  = "task "
+	DefaultTimingRuns = 10
+	timingTimeLayout  = "2006-01-02 15:04:05.000"
+)
+
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"`
+}
+
+type TimingRun struct {
+	ID        int64         `json:"id"`
+	Env       string        `json:"env"`
+	Operation string        `json:"operation"`
+	Started   time.Time     `json:"started"`
+	Duration  time.Duration `json:"duration"`
+	Succeeded bool          `json:"succeeded"`
+	Phases    []PhaseTiming `json:"phases,omitempty"`
+}
+
+func (r TimingRun) Untracked() time.Duration {
+	untracked := r.Duration
+	for _, p := range r.Phases {
+		untracked -= p.Duration
+	}
+	return max(untracked, 0)
+}
+
+type TimingsConfig struct {
+	Summary *bool `yaml:"summary"`
+}
+
+func (tc TimingsConfig) SummaryEnabled() bool {
+	return tc.Summary == nil || *tc.Summary
+}
+
+type PhaseTimer struct {
+	env       string
+	project   string
+	operation string
+	start     time.Time
+	phases    []PhaseTiming
+	tracer    *Tracer
+}
+
+func TaskTimingOperation(name string) string {
+	return timingTaskPrefix + name
+}
+
+func NewPhaseTimer(operation, env, project string) *PhaseTimer {
+	EmitProgress(ProgressEvent{Env: env, Operation: operation, State: ProgressStart})
+	return &PhaseTimer{env: env, project: project, operation: operation, start: time.Now()}
+}
+
+func (t *PhaseTimer) Start(phase string) func() {
+	start := time.Now()
+	span := t.tracer.Start(phase)
+	EmitProgress(ProgressEvent{Env: t.env, Operation: t.operation, Phase: phase, State: ProgressStart})
+	return func() {
+		d := time.Since(start)
+		t.add(phase, d)
+		EmitProgress(ProgressEvent{Env: t.env, Operation: t.operation, Phase: phase, State: ProgressDone})
+		if span != nil {
+			span.End()
+			return
+		}
+		t.tracer.Record(phase, start, start.Add(d))
+	}
+}
+
+func (t *PhaseTimer) Finish(err error) {
+	state, msg := progressState(err)
+	EmitProgress(ProgressEvent{Env: t.env, Operation: t.operation, State: state, Error: msg})
+}
+
+func (t *PhaseTimer) Trace(tracer *Tracer) {
+	t.tracer = tracer
+}
+
+func (t *PhaseTimer) add(phase string, d time.Duration) {
+	i := slices.IndexFunc(t.phases, func(p PhaseTiming) bool { return p.Phase == phase })
+	if i >= 0 {
+		t.phases[i].Duration += d
+		return
+	}
+	t.phases = append(t.phases, PhaseTiming{Phase: phase, Duration: d})
+}
+
+func (t *PhaseTimer) SetProject(project string) {
+	t.project = project
+}
+
+func (t *PhaseTimer) Summary() string {
+	total := time.Since(t.start)
+	var phases []PhaseTiming
+	width := len("other")
+	tracked := time.Duration(0)
+	for _, p := range t.phases {
+		tracked += p.Duration
+		if p.Duration >= time.Millisecond {
+			phases = append(phases, p)
+			width = max(width, len(p.Phase))
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s took %s\n", t.operation, roundPhase(total))
+	for _, p := range phases {
+		fmt.Fprintf(&b, "  %-*s  %s\n", width, p.Phase, roundPhase(p.Duration))
+	}
+	if other := total - tracked; len(phases) > 0 && other >= 100*time.Millisecond {
+		fmt.Fprintf(&b, "  %-*s  %s\n", width, "other", roundPhase(other))
+	}
+	return b.String()
+}
+
+func (t *PhaseTimer) PrintSummary(cfg TimingsConfig) {
+	if cfg.SummaryEnabled() {
+		Printf("%s", t.Summary())
+	}
+}
+
+func roundPhase(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second)
+	case d >= time.Second:
+		return d.Round(100 * time.Millisecond)
+	default:
+		return d.Round(time.Millisecond)
+	}
+}
+
+func (t *PhaseTimer) Save(db *DB, succeeded bool) error {
+	var phases []PhaseTiming
+	for _, p := range t.phases {
+		if p.Duration >= time.Millisecond {
+			phases = append(phases, p)
+		}
+	}
+	return db.RecordTimingRun(TimingRun{
+		Env:       t.env,
+		Operation: t.operation,
+		Started:   t.start,
+		Duration:  time.Since(t.start),
+		Succeeded: succeeded,
+		Phases:    phases,
+	}, t.project)
+}
+
+func (db *DB) RecordTimingRun(run TimingRun, project string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	res, err := tx.Exec(
+		`INSERT INTO timing_runs (env_name, project, operation, started_at, duration_ms, succeeded) VALUES (?, ?, ?, ?, ?, ?)`,
+		run.Env, project, run.Operation, run.Started.UTC().Format(timingTimeLayout), run.Duration.Milliseconds(), run.Succeeded,
+	)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record %s timings: %w", run.Operation, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record %s timings: %w", run.Operation, err)
+	}
+	for i, p := range run.Phases {
+		if _, err := tx.Exec(`INSERT INTO timing_phases (run_id, position, phase, duration_ms) VALUES (?, ?, ?, ?)`, id, i, p.Phase, p.Duration.Milliseconds()); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record %s phase %s: %w", run.Operation, p.Phase, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s timings: %w", run.Operation, err)
+	}
+	return nil
+}
+
+func (db *DB) GetTimingRuns(project, env, operation string, limit int) ([]TimingRun, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, env_name, operation, started_at, duration_ms, succeeded FROM timing_runs
+		WHERE project = ? AND operation = ? AND (? = '' OR env_name = ?)
+		ORDER BY started_at DESC, id DESC LIMIT ?`,
+		project, operation, env, env, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s timings: %w", operation, err)
+	}
+	defer rows.Close()
+
+	var runs []TimingRun
+	for rows.Next() {
+		var r TimingRun
+		var started string
+		var durationMS int64
+		if err := rows.Scan(&r.ID, &r.Env, &r.Operation, &started, &durationMS, &r.Succeeded); err != nil {
+			return nil, fmt.Errorf("failed to scan timing run: %w", err)
+		}
+		r.Started, err = time.Parse(timingTimeLayout, started)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp %q: %w", started, err)
+		}
+		r.Duration = time.Duration(durationMS) * time.Millisecond
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read timing runs: %w", err)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close timing runs: %w", err)
+	}
+
+	for i := range runs {
+		phases, err := db.getTimingPhases(runs[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		runs[i].Phases = phases
+	}
+	return runs, nil
+}
+
+func (db *DB) getTimingPhases(runID int64) ([]PhaseTiming, error) {
+	rows, err := db.conn.Query(`SELECT phase, duration_ms FROM timing_phases WHERE run_id = ? ORDER BY position`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timing phases: %w", err)
+	}
+	defer rows.Close()
+
+	var phases []PhaseTiming
+	for rows.Next() {
+		var p PhaseTiming
+		var durationMS int64
+		if err := rows.Scan(&p.Phase, &durationMS); err != nil {
+			return nil, fmt.Errorf("failed to scan timing phase: %w", err)
+		}
+		p.Duration = time.Duration(durationMS) * time.Millisecond
+		phases = append(phases, p)
+	}
+	return phases, rows.Err()
+}
+
+type PhaseTrend struct {
+	Phase   string        `json:"phase"`
+	Last    time.Duration `json:"last"`
+	Average time.Duration `json:"average"`
+	Min     time.Duration `json:"min"`
+	Max     time.Duration `json:"max"`
+	Runs    int           `json:"runs"`
+}
+
+type TimingReport struct {
+	Env       string       `json:"env"`
+	Operation string       `json:"operation"`
+	Last      *TimingRun   `json:"last"`
+	History   []TimingRun  `json:"history"`
+	Trends    []PhaseTrend `json:"trends"`
+}
+
+func phaseTrends(last TimingRun, history []TimingRun) []PhaseTrend {
+	withTotal := func(r TimingRun) []PhaseTiming {
+		return append(slices.Clone(r.Phases), PhaseTiming{Phase: "total", Duration: r.Duration})
+	}
+
+	var trends []PhaseTrend
+	index := make(map[string]int)
+	for _, p := range withTotal(last) {
+		index[p.Phase] = len(trends)
+		trends = append(trends, PhaseTrend{Phase: p.Phase, Last: p.Duration})
+	}
+	for _, r := range history {
+		for _, p := range withTotal(r) {
+			i, ok := index[p.Phase]
+			if !ok {
+				continue
+			}
+			t := &trends[i]
+			if t.Runs == 0 || p.Duration < t.Min {
+				t.Min = p.Duration
+			}
+			t.Max = max(t.Max, p.Duration)
+			t.Average += p.Duration
+			t.Runs++
+		}
+	}
+	for i := range trends {
+		if trends[i].Runs > 0 {
+			trends[i].Average /= time.Duration(trends[i].Runs)
+		}
+	}
+	return trends
+}
+
+func Timings(path, operation string, limit int) (*TimingReport, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, err
+	}
+	project := env.RootPath.String
+	if project == "" {
+		project = env.Path
+	}
+
+	report := &TimingReport{Env: env.EnvName(), Operation: operation}
+	last, err := db.GetTimingRuns(project, report.Env, operation, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		return report, nil
+	}
+	report.Last = &last[0]
+	report.History, err = db.GetTimingRuns(project, "", operation, limit)
+	if err != nil {
+		return nil, err
+	}
+	report.Trends = phaseTrends(*report.Last, report.History)
+	return report, nil
+}
@@ -0,0 +1,146 @@
+package mono
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimerMergesRepeatedPhases(t *testing.T) {
+	timer := NewPhaseTimer(TimingInit, "feature", "/repo")
+	timer.add("compute keys", time.Second)
+	timer.add("restore target", 3*time.Second)
+	timer.add("compute keys", 2*time.Second)
+
+	want := []PhaseTiming{{Phase: "compute keys", Duration: 3 * time.Second}, {Phase: "restore target", Duration: 3 * time.Second}}
+	if len(timer.phases) != len(want) {
+		t.Fatalf("phases = %v, want %v", timer.phases, want)
+	}
+	for i := range want {
+		if timer.phases[i] != want[i] {
+			t.Errorf("phases[%d] = %v, want %v", i, timer.phases[i], want[i])
+		}
+	}
+}
+
+func TestPhaseTimerSummary(t *testing.T) {
+	timer := NewPhaseTimer(TimingInit, "feature", "/repo")
+	timer.start = time.Now().Add(-10 * time.Second)
+	timer.add("compute keys", 120*time.Millisecond)
+	timer.add("restore cargo", 4080*time.Millisecond)
+	timer.add("restore npm", 2*time.Second)
+	timer.add("containers", 3*time.Second)
+	timer.add("tmux session", 500*time.Microsecond)
+
+	lines := strings.Split(strings.TrimSuffix(timer.Summary(), "\n"), "\n")
+	want := []string{
+		"init took 10s",
+		"  compute keys   120ms",
+		"  restore cargo  4.1s",
+		"  restore npm    2s",
+		"  containers     3s",
+		"  other          8",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Summary() =\n%s\nwant %d lines", strings.Join(lines, "\n"), len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+
+	off := false
+	if (TimingsConfig{}).SummaryEnabled() != true || (TimingsConfig{Summary: &off}).SummaryEnabled() {
+		t.Error("summary should default to on and follow timings.summary")
+	}
+}
+
+func TestPhaseTrends(t *testing.T) {
+	run := func(total time.Duration, phases ...PhaseTiming) TimingRun {
+		return TimingRun{Duration: total, Phases: phases}
+	}
+	last := run(4*time.Minute, PhaseTiming{"containers", 3 * time.Minute}, PhaseTiming{"restore target", 30 * time.Second})
+	history := []TimingRun{
+		last,
+		run(2*time.Minute, PhaseTiming{"containers", time.Minute}, PhaseTiming{"seed from root", 10 * time.Second}),
+		run(3*time.Minute, PhaseTiming{"containers", 2 * time.Minute}, PhaseTiming{"restore target", 10 * time.Second}),
+	}
+
+	trends := phaseTrends(last, history)
+	want := []PhaseTrend{
+		{Phase: "containers", Last: 3 * time.Minute, Average: 2 * time.Minute, Min: time.Minute, Max: 3 * time.Minute, Runs: 3},
+		{Phase: "restore target", Last: 30 * time.Second, Average: 20 * time.Second, Min: 10 * time.Second, Max: 30 * time.Second, Runs: 2},
+		{Phase: "total", Last: 4 * time.Minute, Average: 3 * time.Minute, Min: 2 * time.Minute, Max: 4 * time.Minute, Runs: 3},
+	}
+	if len(trends) != len(want) {
+		t.Fatalf("trends = %+v, want %+v", trends, want)
+	}
+	for i := range want {
+		if trends[i] != want[i] {
+			t.Errorf("trends[%d] = %+v, want %+v", i, trends[i], want[i])
+		}
+	}
+}
+
+func TestTimingsReport(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	root := t.TempDir()
+	feature := filepath.Join(t.TempDir(), "feature")
+	if _, err := db.InsertEnvironment(feature, "", root, "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	runs := []struct {
+		env     string
+		started time.Time
+		total   time.Duration
+		ok      bool
+	}{
+		{"feature", base, 4 * time.Minute, true},
+		{"other", base.Add(time.Hour), 2 * time.Minute, false},
+	}
+	for _, r := range runs {
+		run := TimingRun{Env: r.env, Operation: TimingInit, Started: r.started, Duration: r.total, Succeeded: r.ok, Phases: []PhaseTiming{{"containers", r.total / 2}}}
+		if err := db.RecordTimingRun(run, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.RecordTimingRun(TimingRun{Env: "feature", Operation: TaskTimingOperation("codegen"), Started: base, Duration: time.Second, Succeeded: true}, root); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Timings(feature, TimingInit, DefaultTimingRuns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Last == nil || report.Last.Env != "feature" || report.Last.Duration != 4*time.Minute || !report.Last.Started.Equal(base) {
+		t.Fatalf("last run = %+v, want the 4m run of feature", report.Last)
+	}
+	if len(report.Last.Phases) != 1 || report.Last.Phases[0].Duration != 2*time.Minute {
+		t.Errorf("last run phases = %v, want containers 2m", report.Last.Phases)
+	}
+	if len(report.History) != 2 || report.History[0].Env != "other" || report.History[0].Succeeded {
+		t.Errorf("history = %+v, want the failed run of other first", report.History)
+	}
+
+	report, err = Timings(feature, TaskTimingOperation("lint"), DefaultTimingRuns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Last != nil {
+		t.Errorf("last run of an unknown task = %+v, want none", report.Last)
+	}
+}
@@ -0,0 +1,425 @@
+package mono
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+const (
+	DefaultTLSMount = "/etc/mono/tls"
+
+	caValidity       = 10 * 365 * 24 * time.Hour
+	certValidity     = 825 * 24 * time.Hour
+	certRenewBefore  = 30 * 24 * time.Hour
+	tlsCertFile      = "cert.pem"
+	tlsKeyFile       = "key.pem"
+	tlsCAFile        = "ca.pem"
+	caKeyFile        = "ca-key.pem"
+	trustedCAName    = "mono-local-ca"
+	linuxTrustAnchor = "/usr/local/share/ca-certificates/" + trustedCAName + ".crt"
+)
+
+type TLSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Mount   string `yaml:"mount"`
+}
+
+func (tc *TLSConfig) ApplyDefaults() {
+	if tc.Mount == "" {
+		tc.Mount = DefaultTLSMount
+	}
+}
+
+func (tc TLSConfig) Validate() error {
+	if tc.Enabled && tc.Mount != "" && !filepath.IsAbs(tc.Mount) {
+		return fmt.Errorf("invalid tls.mount %q: must be an absolute path inside the containers", tc.Mount)
+	}
+	return nil
+}
+
+type LocalCA struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+	Path    string
+}
+
+func CADir() (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, "ca"), nil
+}
+
+func EnsureCA() (*LocalCA, error) {
+	dir, err := CADir()
+	if err != nil {
+		return nil, err
+	}
+	certPath := filepath.Join(dir, tlsCAFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		return parseCA(certPEM, keyPEM, certPath)
+	}
+	if !os.IsNotExist(certErr) && certErr != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", certErr)
+	}
+	if !os.IsNotExist(keyErr) && keyErr != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", keyErr)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "mono local CA (" + hostname + ")", Organization: []string{"mono"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode CA key: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create CA directory: %w", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write CA key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+	return parseCA(certPEM, keyPEM, certPath)
+}
+
+func parseCA(certPEM, keyPEM []byte, path string) (*LocalCA, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("invalid CA certificate in %s", path)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA certificate in %s: %w", path, err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("invalid CA key next to %s", path)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA key next to %s: %w", path, err)
+	}
+	return &LocalCA{Cert: cert, Key: key, CertPEM: certPEM, Path: path}, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
+
+func (ca *LocalCA) Issue(commonName string, names []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"mono"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+			continue
+		}
+		template.DNSNames = append(template.DNSNames, name)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to issue certificate for %s: %w", commonName, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode key: %w", err)
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func EnvTLSNames(envName string, domains []string) []string {
+	env := hostnameLabel(envName)
+	names := []string{}
+	for _, domain := range domains {
+		if slices.Contains(names, env+"."+domain) {
+			continue
+		}
+		names = append(names, env+"."+domain, "*."+env+"."+domain)
+	}
+	return append(names, "localhost", "127.0.0.1")
+}
+
+func (c *Config) TLSDomains() []string {
+	return []string{c.Hosts.Domain, "localhost"}
+}
+
+func EnvTLSDir(envName string) (string, error) {
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get mono home: %w", err)
+	}
+	return filepath.Join(monoHome, "data", envName, "tls"), nil
+}
+
+func TLSEnvVars(envName string) ([]string, error) {
+	dir, err := EnvTLSDir(envName)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"MONO_TLS_CERT=" + filepath.Join(dir, tlsCertFile),
+		"MONO_TLS_KEY=" + filepath.Join(dir, tlsKeyFile),
+		"MONO_TLS_CA=" + filepath.Join(dir, tlsCAFile),
+	}, nil
+}
+
+func certificateCurrent(path string, ca *LocalCA, names []string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read certificate: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false, nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false, nil
+	}
+	if time.Until(cert.NotAfter) < certRenewBefore || cert.CheckSignatureFrom(ca.Cert) != nil {
+		return false, nil
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			if !slices.ContainsFunc(cert.IPAddresses, ip.Equal) {
+				return false, nil
+			}
+			continue
+		}
+		if !slices.Contains(cert.DNSNames, name) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func IssueEnvCertificate(envName string, domains []string, force bool) (string, bool, error) {
+	ca, err := EnsureCA()
+	if err != nil {
+		return "", false, err
+	}
+	dir, err := EnvTLSDir(envName)
+	if err != nil {
+		return "", false, err
+	}
+	names := EnvTLSNames(envName, domains)
+
+	if !force {
+		current, err := certificateCurrent(filepath.Join(dir, tlsCertFile), ca, names)
+		if err != nil {
+			return "", false, err
+		}
+		if current {
+			return dir, false, nil
+		}
+	}
+
+	certPEM, keyPEM, err := ca.Issue(names[0], names)
+	if err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+		perm os.FileMode
+	}{
+		{tlsKeyFile, keyPEM, 0600},
+		{tlsCertFile, append(certPEM, ca.CertPEM...), 0644},
+		{tlsCAFile, ca.CertPEM, 0644},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, f.data, f.perm); err != nil {
+			return "", false, fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		if err := os.Chmod(path, f.perm); err != nil {
+			return "", false, fmt.Errorf("failed to set permissions of %s: %w", f.name, err)
+		}
+	}
+	return dir, true, nil
+}
+
+func MountTLS(project *types.Project, dir, mount string) {
+	for name, svc := range project.Services {
+		svc.Volumes = append(svc.Volumes, types.ServiceVolumeConfig{
+			Type:     types.VolumeTypeBind,
+			Source:   dir,
+			Target:   mount,
+			ReadOnly: true,
+		})
+		if svc.Environment == nil {
+			svc.Environment = types.MappingWithEquals{}
+		}
+		for k, v := range map[string]string{
+			"MONO_TLS_CERT": filepath.Join(mount, tlsCertFile),
+			"MONO_TLS_KEY":  filepath.Join(mount, tlsKeyFile),
+			"MONO_TLS_CA":   filepath.Join(mount, tlsCAFile),
+		} {
+			if _, ok := svc.Environment[k]; !ok {
+				svc.Environment[k] = &v
+			}
+		}
+		project.Services[name] = svc
+	}
+}
+
+type proxyCertificates struct {
+	ca     *LocalCA
+	domain string
+	mu     sync.Mutex
+	certs  map[string]*tls.Certificate
+}
+
+func (p *proxyCertificates) get(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if hello.ServerName == "" {
+		return nil, errors.New("client did not send a server name")
+	}
+	_, envName, ok := ParseProxyHost(hello.ServerName, p.domain)
+	if !ok {
+		return nil, fmt.Errorf("expected host of the form <service>.<env>.%s, got %s", p.domain, hello.ServerName)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cert, ok := p.certs[envName]; ok {
+		return cert, nil
+	}
+	certPEM, keyPEM, err := p.ca.Issue(envName+"."+p.domain, EnvTLSNames(envName, []string{p.domain}))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate for %s: %w", envName, err)
+	}
+	p.certs[envName] = &cert
+	return &cert, nil
+}
+
+func TrustCACommand(ca *LocalCA) ([]string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"sudo", "security", "add-trusted-cert", "-d", "-r", "trustRoot", "-k", "/Library/Keychains/System.keychain", ca.Path}, nil
+	case "linux":
+		return []string{"sh", "-c", fmt.Sprintf("sudo cp %s %s && sudo update-ca-certificates", shellQuote(ca.Path), linuxTrustAnchor)}, nil
+	}
+	return nil, fmt.Errorf("trusting the CA is not supported on %s, import %s into your trust store manually", runtime.GOOS, ca.Path)
+}
+
+func TrustCA(ca *LocalCA) error {
+	command, err := TrustCACommand(ca)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := Command(command[0], command[1:]...).
+		Stdin(os.Stdin).
+		Stdout(&out).
+		Stderr(&out).
+		Timeout(5 * time.Minute).
+		Run(); err != nil {
+		return fmt.Errorf("failed to trust %s: %w: %s", ca.Path, err, bytes.TrimSpace(out.Bytes()))
+	}
+	return nil
+}
+
+func IssueEnvTLS(path string, force bool) (*Environment, string, bool, error) {
+	db, err := OpenDB()
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	env, err := db.FindEnvironment(path)
+	if err != nil {
+		return nil, "", false, err
+	}
+	cfg, err := LoadConfig(env.Path)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to load config: %w", err)
+	}
+	cfg.Hosts.ApplyDefaults()
+
+	dir, issued, err := IssueEnvCertificate(env.EnvName(), cfg.TLSDomains(), force)
+	return env, dir, issued, err
+}
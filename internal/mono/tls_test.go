@@ -0,0 +1,225 @@
+package mono
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+func TestEnsureCAIsStable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	first, err := EnsureCA()
+	if err != nil {
+		t.Fatalf("EnsureCA() error = %v", err)
+	}
+	second, err := EnsureCA()
+	if err != nil {
+		t.Fatalf("EnsureCA() second call error = %v", err)
+	}
+	if !first.Cert.Equal(second.Cert) {
+		t.Error("EnsureCA() created a new CA on the second call")
+	}
+	if !first.Cert.IsCA {
+		t.Error("CA certificate is not marked as a CA")
+	}
+
+	info, err := os.Stat(filepath.Join(filepath.Dir(first.Path), "ca-key.pem"))
+	if err != nil {
+		t.Fatalf("stat CA key: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("CA key permissions = %o, want 600", perm)
+	}
+}
+
+func TestIssueEnvCertificate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	dir, issued, err := IssueEnvCertificate("feature", []string{"test", "localhost"}, false)
+	if err != nil {
+		t.Fatalf("IssueEnvCertificate() error = %v", err)
+	}
+	if !issued {
+		t.Error("IssueEnvCertificate() issued = false on first call")
+	}
+
+	ca, err := EnsureCA()
+	if err != nil {
+		t.Fatalf("EnsureCA() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, tlsCertFile))
+	if err != nil {
+		t.Fatalf("read certificate: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("certificate is not PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, name := range []string{"feature.test", "api.feature.test", "web.feature.localhost", "localhost", "127.0.0.1"} {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("certificate does not verify for %s: %v", name, err)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{DNSName: "api.other.test", Roots: roots}); err == nil {
+		t.Error("certificate verifies for another environment's hostname")
+	}
+	if _, err := tls.LoadX509KeyPair(filepath.Join(dir, tlsCertFile), filepath.Join(dir, tlsKeyFile)); err != nil {
+		t.Errorf("certificate and key don't form a pair: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, tlsKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("key file mode = %o, want 600", perm)
+	}
+
+	_, issued, err = IssueEnvCertificate("feature", []string{"test", "localhost"}, false)
+	if err != nil {
+		t.Fatalf("IssueEnvCertificate() second call error = %v", err)
+	}
+	if issued {
+		t.Error("IssueEnvCertificate() reissued a current certificate")
+	}
+
+	_, issued, err = IssueEnvCertificate("feature", []string{"internal", "localhost"}, false)
+	if err != nil {
+		t.Fatalf("IssueEnvCertificate() new domain error = %v", err)
+	}
+	if !issued {
+		t.Error("IssueEnvCertificate() kept a certificate that doesn't cover the new domain")
+	}
+
+	_, issued, err = IssueEnvCertificate("feature", []string{"internal", "localhost"}, true)
+	if err != nil {
+		t.Fatalf("IssueEnvCertificate(force) error = %v", err)
+	}
+	if !issued {
+		t.Error("IssueEnvCertificate(force) did not reissue")
+	}
+}
+
+func TestMountTLS(t *testing.T) {
+	custom := "/run/certs/custom.pem"
+	project := &types.Project{Services: types.Services{
+		"api": {Name: "api"},
+		"web": {Name: "web", Environment: types.MappingWithEquals{"MONO_TLS_CERT": &custom}},
+	}}
+
+	MountTLS(project, "/home/dev/.mono/data/feature/tls", DefaultTLSMount)
+
+	for _, name := range []string{"api", "web"} {
+		svc := project.Services[name]
+		if !slices.ContainsFunc(svc.Volumes, func(v types.ServiceVolumeConfig) bool {
+			return v.Source == "/home/dev/.mono/data/feature/tls" && v.Target == DefaultTLSMount && v.ReadOnly
+		}) {
+			t.Errorf("%s volumes = %+v, want read-only tls mount", name, svc.Volumes)
+		}
+		if got := *svc.Environment["MONO_TLS_KEY"]; got != DefaultTLSMount+"/key.pem" {
+			t.Errorf("%s MONO_TLS_KEY = %q", name, got)
+		}
+	}
+	if got := *project.Services["api"].Environment["MONO_TLS_CERT"]; got != DefaultTLSMount+"/cert.pem" {
+		t.Errorf("api MONO_TLS_CERT = %q", got)
+	}
+	if got := *project.Services["web"].Environment["MONO_TLS_CERT"]; got != custom {
+		t.Errorf("web MONO_TLS_CERT = %q, want the service's own value kept", got)
+	}
+}
+
+func TestProxyCertificates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ca, err := EnsureCA()
+	if err != nil {
+		t.Fatalf("EnsureCA() error = %v", err)
+	}
+	certs := &proxyCertificates{ca: ca, domain: "localhost", certs: make(map[string]*tls.Certificate)}
+
+	cert, err := certs.get(&tls.ClientHelloInfo{ServerName: "web.feature.localhost"})
+	if err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	if err := leaf.VerifyHostname("web.feature.localhost"); err != nil {
+		t.Errorf("certificate does not cover web.feature.localhost: %v", err)
+	}
+
+	again, err := certs.get(&tls.ClientHelloInfo{ServerName: "api.feature.localhost"})
+	if err != nil {
+		t.Fatalf("get() second call error = %v", err)
+	}
+	if again != cert {
+		t.Error("get() issued a second certificate for the same environment")
+	}
+
+	if _, err := certs.get(&tls.ClientHelloInfo{ServerName: "example.com"}); err == nil {
+		t.Error("get() issued a certificate for a host outside the proxy domain")
+	}
+}
+
+func TestRenameTLSReissuesCertificate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MONO_HOME", "")
+
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	envPath := filepath.Join(home, "proj", "feature")
+	envID, err := db.InsertEnvironment(envPath, "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RenameEnvironment(envID, "renamed", ""); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := NewFileLogger("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	cfg := &Config{}
+	cfg.Hosts.Domain = "test"
+	if err := renameTLS(db, envPath, "renamed", SessionName("renamed"), cfg, logger); err != nil {
+		t.Fatalf("renameTLS() error = %v", err)
+	}
+
+	dir, err := EnvTLSDir("renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, tlsCertFile), filepath.Join(dir, tlsKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("api.renamed.test"); err != nil {
+		t.Errorf("certificate does not cover the new name: %v", err)
+	}
+	if err := leaf.VerifyHostname("api.feature.test"); err == nil {
+		t.Error("certificate still covers the old name")
+	}
+}
@@ -1,6 +1,7 @@
 package mono
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -20,11 +21,14 @@ func SessionExists(sessionName string) bool {
 	return err == nil
 }
 
-func CreateSession(sessionName, workDir string, envVars []string) error {
+func CreateSession(sessionName, workDir string, envVars []string, shell string) error {
 	args := []string{"new-session", "-d", "-s", sessionName, "-c", workDir}
 	for _, envVar := range envVars {
 		args = append(args, "-e", envVar)
 	}
+	if shell != "" {
+		args = append(args, shell)
+	}
 
 	output, err := Command("tmux", args...).
 		Timeout(tmuxTimeout).
@@ -36,6 +40,41 @@ func CreateSession(sessionName, workDir string, envVars []string) error {
 	return nil
 }
 
+func CreateWindow(sessionName, windowName, workDir, shell, command string) error {
+	target := sessionName + ":"
+	args := []string{"new-window", "-d", "-t", target, "-n", windowName, "-c", workDir}
+	if shell != "" {
+		args = append(args, shell)
+	}
+	output, err := Command("tmux", args...).
+		Timeout(tmuxTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create window %s: %s: %w", windowName, strings.TrimSpace(string(output)), err)
+	}
+	if command == "" {
+		return nil
+	}
+	return Command("tmux", "send-keys", "-t", target+windowName, command, "Enter").
+		Timeout(tmuxTimeout).
+		Run()
+}
+
+func WindowExists(sessionName, windowName string) (bool, error) {
+	output, err := Command("tmux", "list-windows", "-t", sessionName, "-F", "#{window_name}").
+		Timeout(tmuxTimeout).
+		Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list windows of %s: %w", sessionName, err)
+	}
+	for _, name := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if name == windowName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func SendKeys(sessionName, keys string) error {
 	Command("tmux", "send-keys", "-t", sessionName, "C-u").
 		Timeout(tmuxTimeout).
@@ -45,13 +84,40 @@ func SendKeys(sessionName, keys string) error {
 		Run()
 }
 
+func SetSessionEnv(sessionName string, envVars []string) error {
+	for _, envVar := range envVars {
+		key, value, ok := strings.Cut(envVar, "=")
+		if !ok {
+			continue
+		}
+		output, err := Command("tmux", "set-environment", "-t", sessionName, key, value).
+			Timeout(tmuxTimeout).
+			CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to set %s: %s: %w", key, strings.TrimSpace(string(output)), err)
+		}
+	}
+	return nil
+}
+
+func RenameSession(oldName, newName string) error {
+	output, err := Command("tmux", "rename-session", "-t", oldName, newName).
+		Timeout(tmuxTimeout).
+		CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to rename session: %s: %w", strings.TrimSpace(string(output)), err)
+	}
+	return nil
+}
+
 func KillSession(sessionName string) error {
 	if !SessionExists(sessionName) {
 		return nil
 	}
-	return Command("tmux", "kill-session", "-t", sessionName).
+	err := Command("tmux", "kill-session", "-t", sessionName).
 		Timeout(tmuxTimeout).
 		Run()
+	return errors.Join(err, audit(AuditKillSession, sessionName, strings.TrimPrefix(sessionName, "mono-"), 0, err))
 }
 
 func IsInsideTmux() bool {
@@ -90,7 +156,7 @@ func NewTmuxManager(sessionName, workDir string, config TmuxConfig) *TmuxManager
 }
 
 func (tm *TmuxManager) CreateSession(envVars []string) error {
-	return CreateSession(tm.sessionName, tm.workDir, envVars)
+	return CreateSession(tm.sessionName, tm.workDir, envVars, tm.config.shell())
 }
 
 func (tm *TmuxManager) SessionExists() bool {
@@ -102,27 +168,61 @@ func (tm *TmuxManager) KillSession() error {
 }
 
 func (tm *TmuxManager) Run(scriptPath string) error {
+	return tm.runIn(tm.sessionName, scriptPath)
+}
+
+func (tm *TmuxManager) RunInWindow(window, scriptPath string) error {
+	exists, err := WindowExists(tm.sessionName, window)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := CreateWindow(tm.sessionName, window, tm.workDir, tm.config.shell(), ""); err != nil {
+			return err
+		}
+	}
+	return tm.runIn(tm.sessionName+":"+window, scriptPath)
+}
+
+func (tm *TmuxManager) RestartWindow(window, command string) error {
+	exists, err := WindowExists(tm.sessionName, window)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return CreateWindow(tm.sessionName, window, tm.workDir, tm.config.shell(), command)
+	}
+	target := tm.sessionName + ":" + window
 	if tm.config.Run.OnConflict == "respawn" {
-		return tm.respawn(fmt.Sprintf("source %s", scriptPath))
+		return tm.respawn(target, command)
+	}
+	if err := tm.interrupt(target); err != nil {
+		return err
 	}
-	tm.interrupt()
-	tm.sendKeys(fmt.Sprintf("cd %q", tm.workDir))
-	return tm.sendKeys("source " + scriptPath)
+	return SendKeys(target, command)
 }
 
-func (tm *TmuxManager) interrupt() error {
-	return Command("tmux", "send-keys", "-t", tm.sessionName, "C-c").
+func (tm *TmuxManager) runIn(target, scriptPath string) error {
+	if tm.config.Run.OnConflict == "respawn" {
+		return tm.respawn(target, fmt.Sprintf("source %s", scriptPath))
+	}
+	tm.interrupt(target)
+	SendKeys(target, fmt.Sprintf("cd %q", tm.workDir))
+	return SendKeys(target, "source "+scriptPath)
+}
+
+func (tm *TmuxManager) interrupt(target string) error {
+	return Command("tmux", "send-keys", "-t", target, "C-c").
 		Timeout(tmuxTimeout).
 		Run()
 }
 
-func (tm *TmuxManager) respawn(cmd string) error {
+func (tm *TmuxManager) respawn(target, cmd string) error {
 	fullCmd := fmt.Sprintf("cd %q && %s", tm.workDir, cmd)
-	return Command("tmux", "respawn-pane", "-k", "-t", tm.sessionName, fullCmd).
+	if tm.config.nix.Enabled {
+		fullCmd = tm.config.nix.shellCommand(`"$SHELL" -c ` + shellQuote(fullCmd))
+	}
+	return Command("tmux", "respawn-pane", "-k", "-t", target, fullCmd).
 		Timeout(tmuxTimeout).
 		Run()
 }
-
-func (tm *TmuxManager) sendKeys(keys string) error {
-	return SendKeys(tm.sessionName, keys)
-}
@@ -0,0 +1,325 @@
+package mono
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	otlpTracesPath     = "/v1/traces"
+	traceExportTimeout = 5 * time.Second
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+	otlpSpanInternal   = 1
+)
+
+type TracingConfig struct {
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+func (tc TracingConfig) Validate() error {
+	if tc.Endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(tc.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid tracing.endpoint %q: expected an http or https URL", tc.Endpoint)
+	}
+	return nil
+}
+
+func (tc TracingConfig) tracesURL() string {
+	if tc.Endpoint != "" {
+		if strings.HasSuffix(tc.Endpoint, otlpTracesPath) {
+			return tc.Endpoint
+		}
+		return strings.TrimSuffix(tc.Endpoint, "/") + otlpTracesPath
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + otlpTracesPath
+	}
+	return ""
+}
+
+func (tc TracingConfig) headers() map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if v, err := url.QueryUnescape(strings.TrimSpace(value)); err == nil {
+			value = v
+		}
+		headers[strings.TrimSpace(key)] = value
+	}
+	for k, v := range tc.Headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+type Tracer struct {
+	url     string
+	headers map[string]string
+	traceID string
+	root    *Span
+	current *Span
+	mu      sync.Mutex
+	spans   []*Span
+}
+
+type Span struct {
+	tracer *Tracer
+	id     string
+	parent *Span
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]any
+	err    error
+}
+
+func NewTracer(cfg TracingConfig, name string, start time.Time) *Tracer {
+	target := cfg.tracesURL()
+	if target == "" {
+		return nil
+	}
+	t := &Tracer{url: target, headers: cfg.headers(), traceID: randomHex(16)}
+	t.root = t.newSpan(name, nil, start)
+	t.current = t.root
+	return t
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (t *Tracer) newSpan(name string, parent *Span, start time.Time) *Span {
+	return &Span{tracer: t, id: randomHex(8), parent: parent, name: name, start: start, attrs: make(map[string]any)}
+}
+
+func (t *Tracer) Root() *Span {
+	if t == nil {
+		return nil
+	}
+	return t.root
+}
+
+func (t *Tracer) Start(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSpan(name, t.current, time.Now())
+	t.current = s
+	return s
+}
+
+func (t *Tracer) Record(name string, start, end time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSpan(name, t.current, start)
+	s.end = end
+	t.spans = append(t.spans, s)
+}
+
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.attrs[key] = value
+}
+
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.err = err
+}
+
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	t := s.tracer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.end = time.Now()
+	t.spans = append(t.spans, s)
+	if t.current == s {
+		t.current = s.parent
+	}
+}
+
+func (t *Tracer) Finish(err error) error {
+	if t == nil {
+		return nil
+	}
+	t.root.Fail(err)
+	t.root.End()
+	return t.export()
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	var out []otlpAttribute
+	for _, key := range sortedKeys(attrs) {
+		var v otlpValue
+		switch value := attrs[key].(type) {
+		case string:
+			v.StringValue = &value
+		case bool:
+			v.BoolValue = &value
+		case int:
+			s := strconv.Itoa(value)
+			v.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case float64:
+			v.DoubleValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		out = append(out, otlpAttribute{Key: key, Value: v})
+	}
+	return out
+}
+
+func (t *Tracer) payload() otlpRequest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	resource := map[string]any{"service.name": "mono"}
+	if host, err := os.Hostname(); err == nil {
+		resource["host.name"] = host
+	}
+	var scope otlpScopeSpans
+	scope.Scope.Name = "github.com/gwuah/mono"
+	for _, s := range t.spans {
+		span := otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.id,
+			Name:              s.name,
+			Kind:              otlpSpanInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.attrs),
+			Status:            otlpStatus{Code: otlpStatusOK},
+		}
+		if s.parent != nil {
+			span.ParentSpanID = s.parent.id
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.err.Error()}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	var rs otlpResourceSpans
+	rs.Resource.Attributes = otlpAttributes(resource)
+	rs.ScopeSpans = []otlpScopeSpans{scope}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{rs}}
+}
+
+func (t *Tracer) export() error {
+	data, err := json.Marshal(t.payload())
+	if err != nil {
+		return fmt.Errorf("failed to encode traces: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create trace export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: traceExportTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export traces to %s: %w", t.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if err != nil {
+			return fmt.Errorf("trace collector at %s returned %s", t.url, resp.Status)
+		}
+		return fmt.Errorf("trace collector at %s returned %s: %s", t.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
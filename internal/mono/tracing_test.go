@@ -0,0 +1,100 @@
+package mono
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracerExportsPhasesAsNestedSpans(t *testing.T) {
+	var got otlpRequest
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != otlpTracesPath {
+			http.NotFound(w, r)
+			return
+		}
+		header = r.Header.Get("X-Team")
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	timer := NewPhaseTimer(TimingInit, "feature", "/repo")
+	doneConfig := timer.Start("load config")
+	tracer := NewTracer(TracingConfig{Endpoint: server.URL, Headers: map[string]string{"X-Team": "infra"}}, "mono init", timer.start)
+	timer.Trace(tracer)
+	doneConfig()
+
+	cm := &CacheManager{tracer: tracer}
+	doneRestore := timer.Start("restore cargo")
+	span := cm.tracer.Start("restore cache")
+	span.SetAttr("mono.artifact", "cargo")
+	span.End()
+	doneRestore()
+
+	if err := tracer.Finish(errors.New("containers failed")); err != nil {
+		t.Fatal(err)
+	}
+	if header != "infra" {
+		t.Errorf("X-Team header = %q, want infra", header)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("payload = %+v", got)
+	}
+
+	byName := make(map[string]otlpSpan)
+	for _, s := range got.ResourceSpans[0].ScopeSpans[0].Spans {
+		byName[s.Name] = s
+	}
+	root := byName["mono init"]
+	if root.ParentSpanID != "" || root.Status.Code != otlpStatusError || root.Status.Message != "containers failed" {
+		t.Errorf("root span = %+v, want a failed span without a parent", root)
+	}
+	if byName["load config"].ParentSpanID != root.SpanID {
+		t.Errorf("load config span = %+v, want it recorded under the root", byName["load config"])
+	}
+	if byName["restore cache"].ParentSpanID != byName["restore cargo"].SpanID {
+		t.Error("restore cache span is not nested under its phase")
+	}
+	if attrs := byName["restore cache"].Attributes; len(attrs) != 1 || *attrs[0].Value.StringValue != "cargo" {
+		t.Errorf("restore cache attributes = %+v", attrs)
+	}
+	for _, s := range byName {
+		if s.TraceID != root.TraceID {
+			t.Errorf("span %s has trace %s, want %s", s.Name, s.TraceID, root.TraceID)
+		}
+	}
+}
+
+func TestTracingConfigEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if tracer := NewTracer(TracingConfig{}, "mono init", time.Now()); tracer != nil {
+		t.Error("NewTracer() without an endpoint should disable tracing")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318/")
+	if got := (TracingConfig{}).tracesURL(); got != "http://collector:4318/v1/traces" {
+		t.Errorf("tracesURL() = %q", got)
+	}
+	if got := (TracingConfig{Endpoint: "https://otel.example.com/v1/traces"}).tracesURL(); got != "https://otel.example.com/v1/traces" {
+		t.Errorf("tracesURL() = %q", got)
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Bearer%20abc,x-team=infra")
+	if got := (TracingConfig{Headers: map[string]string{"x-team": "web"}}).headers(); got["authorization"] != "Bearer abc" || got["x-team"] != "web" {
+		t.Errorf("headers() = %v", got)
+	}
+	if err := (TracingConfig{Endpoint: "collector:4318"}).Validate(); err == nil {
+		t.Error("Validate() accepted an endpoint without a scheme")
+	}
+}
@@ -0,0 +1,207 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	UserConfigFile    = "config.yaml"
+	userConfigDisplay = "~/.mono/" + UserConfigFile
+)
+
+type CacheConfig struct {
+	Workers int    `yaml:"workers"`
+	MaxSize string `yaml:"max_size"`
+}
+
+func (cc CacheConfig) MaxBytes() (int64, error) {
+	if cc.MaxSize == "" {
+		return 0, nil
+	}
+	return ParseSize(cc.MaxSize)
+}
+
+type UserConfig struct {
+	Config `yaml:",inline"`
+	Cache  CacheConfig `yaml:"cache"`
+}
+
+func UserConfigPath() (string, error) {
+	home, err := GetMonoHome()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, UserConfigFile), nil
+}
+
+func readUserConfig() (*yaml.Node, *UserConfig, error) {
+	path, err := UserConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", userConfigDisplay, err)
+	}
+
+	var user UserConfig
+	node, err := parseConfigNode(userConfigDisplay, data, &user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, &user, nil
+	}
+	if err := node.Decode(&user); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", userConfigDisplay, err)
+	}
+	if err := user.Cache.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s: %w", userConfigDisplay, err)
+	}
+	return node, &user, nil
+}
+
+func (cc CacheConfig) Validate() error {
+	if cc.Workers < 0 {
+		return fmt.Errorf("cache.workers must not be negative")
+	}
+	if _, err := cc.MaxBytes(); err != nil {
+		return fmt.Errorf("cache.max_size: %w", err)
+	}
+	return nil
+}
+
+func LoadUserConfig() (*UserConfig, error) {
+	_, user, err := readUserConfig()
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = &UserConfig{}
+	}
+	if err := applyConfigOverrides(user, nil); err != nil {
+		return nil, err
+	}
+	if err := user.Cache.Validate(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func loadUserConfigNode(origins map[*yaml.Node]string) (*yaml.Node, error) {
+	node, _, err := readUserConfig()
+	if err != nil || node == nil {
+		return nil, err
+	}
+
+	own := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "cache", "version":
+		case "extends", "include":
+			return nil, fmt.Errorf("invalid %s:%d: %s is only supported in mono.yml", userConfigDisplay, key.Line, key.Value)
+		default:
+			own.Content = append(own.Content, key, value)
+		}
+	}
+	if origins != nil {
+		recordOrigin(own, userConfigDisplay, origins)
+	}
+	return applyPlatformNodes(userConfigDisplay, own)
+}
+
+func ParseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		size   int64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, u := range units {
+		if number, ok := strings.CutSuffix(value, u.suffix); ok {
+			value, multiplier = strings.TrimSpace(number), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q: expected e.g. 500MB or 20GB", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+func (cm *CacheManager) EnforceMaxSize(db *DB, keep []ArtifactCacheEntry, logger *FileLogger) error {
+	if cm.MaxSize <= 0 {
+		return nil
+	}
+
+	sizes, err := cm.GetCacheSizes()
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, s := range sizes {
+		total += s.Size
+	}
+	if total <= cm.MaxSize {
+		return nil
+	}
+
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return err
+	}
+	lastUsed := make(map[string]time.Time, len(stats))
+	for _, s := range stats {
+		lastUsed[filepath.Join(s.ProjectID, s.Artifact, s.CacheKey)] = s.LastUsed
+	}
+	protected := make(map[string]bool, len(keep))
+	for _, entry := range keep {
+		protected[entry.CachePath] = true
+	}
+
+	sort.SliceStable(sizes, func(i, j int) bool {
+		a := lastUsed[filepath.Join(sizes[i].ProjectID, sizes[i].Artifact, sizes[i].CacheKey)]
+		b := lastUsed[filepath.Join(sizes[j].ProjectID, sizes[j].Artifact, sizes[j].CacheKey)]
+		return a.Before(b)
+	})
+
+	for _, s := range sizes {
+		if total <= cm.MaxSize {
+			break
+		}
+		if protected[filepath.Join(cm.LocalCacheDir, s.ProjectID, s.Artifact, s.CacheKey)] {
+			continue
+		}
+		if err := cm.RemoveCacheEntry(s.ProjectID, s.Artifact, s.CacheKey); err != nil {
+			if errors.Is(err, ErrCacheEntryInUse) {
+				continue
+			}
+			return err
+		}
+		if err := db.DeleteCacheEvents(s.ProjectID, s.Artifact, s.CacheKey); err != nil {
+			return fmt.Errorf("failed to delete cache events: %w", err)
+		}
+		total -= s.Size
+		logger.Log("evicted %s/%s (key: %s) to stay under cache.max_size", s.ProjectID, s.Artifact, s.CacheKey)
+	}
+	if total > cm.MaxSize {
+		logger.Log("warning: cache is still over cache.max_size after evicting everything not in use")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package mono
+
+import (
+	"fmt"
+	"os"
+)
+
+type Verbosity int
+
+const (
+	VerbosityQuiet Verbosity = iota - 1
+	VerbosityNormal
+	VerbosityVerbose
+	VerbosityDebug
+)
+
+const verbosityEnvVar = "MONO_VERBOSITY"
+
+var verbosityNames = map[string]Verbosity{
+	"quiet":   VerbosityQuiet,
+	"normal":  VerbosityNormal,
+	"verbose": VerbosityVerbose,
+	"debug":   VerbosityDebug,
+}
+
+var verbosity = VerbosityNormal
+
+func (v Verbosity) String() string {
+	for name, level := range verbosityNames {
+		if level == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("Verbosity(%d)", int(v))
+}
+
+func SetVerbosity(verbose int, quiet bool) error {
+	if quiet && verbose > 0 {
+		return fmt.Errorf("--quiet cannot be combined with --verbose")
+	}
+	v := VerbosityNormal
+	if name, ok := os.LookupEnv(verbosityEnvVar); ok && name != "" {
+		level, ok := verbosityNames[name]
+		if !ok {
+			return fmt.Errorf("invalid %s: %w", verbosityEnvVar, unknownSelection("verbosity", name, sortedKeys(verbosityNames)))
+		}
+		v = level
+	}
+	switch {
+	case quiet:
+		v = VerbosityQuiet
+	case verbose > 0:
+		v = min(Verbosity(verbose), VerbosityDebug)
+	}
+	verbosity = v
+	return nil
+}
+
+func CurrentVerbosity() Verbosity {
+	return verbosity
+}
+
+func Printf(format string, args ...any) {
+	if verbosity > VerbosityQuiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+func Println(args ...any) {
+	if verbosity > VerbosityQuiet {
+		fmt.Println(args...)
+	}
+}
@@ -0,0 +1,131 @@
+package mono
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetVerbosity(t *testing.T) {
+	t.Cleanup(func() { verbosity = VerbosityNormal })
+
+	tests := []struct {
+		name    string
+		env     string
+		verbose int
+		quiet   bool
+		want    Verbosity
+		wantErr string
+	}{
+		{name: "default", want: VerbosityNormal},
+		{name: "verbose", verbose: 1, want: VerbosityVerbose},
+		{name: "debug", verbose: 2, want: VerbosityDebug},
+		{name: "capped", verbose: 5, want: VerbosityDebug},
+		{name: "quiet", quiet: true, want: VerbosityQuiet},
+		{name: "env", env: "quiet", want: VerbosityQuiet},
+		{name: "flag over env", env: "quiet", verbose: 1, want: VerbosityVerbose},
+		{name: "conflict", verbose: 1, quiet: true, wantErr: "cannot be combined"},
+		{name: "unknown env", env: "loud", wantErr: `unknown verbosity "loud" (available: debug, normal, quiet, verbose)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(verbosityEnvVar, tt.env)
+			err := SetVerbosity(tt.verbose, tt.quiet)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("SetVerbosity() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetVerbosity() error = %v", err)
+			}
+			if got := CurrentVerbosity(); got != tt.want {
+				t.Errorf("CurrentVerbosity() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func captureStream(t *testing.T, stream **os.File, fn func()) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := *stream
+	*stream = f
+	fn()
+	*stream = orig
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestVerbosityOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { verbosity = VerbosityNormal })
+
+	logger, err := NewFileLogger("feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	emit := func() (string, string) {
+		var stderr string
+		stdout := captureStream(t, &os.Stdout, func() {
+			stderr = captureStream(t, &os.Stderr, func() {
+				Printf("done %d\n", 1)
+				logger.Log("step %s", "restore")
+				logger.Debug("linked %s", "target/debug/app")
+			})
+		})
+		return stdout, stderr
+	}
+
+	tests := []struct {
+		level      Verbosity
+		wantStdout bool
+		wantLog    bool
+		wantDebug  bool
+	}{
+		{level: VerbosityQuiet},
+		{level: VerbosityNormal, wantStdout: true},
+		{level: VerbosityVerbose, wantStdout: true, wantLog: true},
+		{level: VerbosityDebug, wantStdout: true, wantLog: true, wantDebug: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			verbosity = tt.level
+			stdout, stderr := emit()
+			if got := stdout == "done 1\n"; got != tt.wantStdout {
+				t.Errorf("stdout = %q, want output %v", stdout, tt.wantStdout)
+			}
+			if got := strings.Contains(stderr, "[feature] step restore"); got != tt.wantLog {
+				t.Errorf("stderr = %q, want log %v", stderr, tt.wantLog)
+			}
+			if got := strings.Contains(stderr, "linked target/debug/app"); got != tt.wantDebug {
+				t.Errorf("stderr = %q, want debug %v", stderr, tt.wantDebug)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".mono", "mono.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(data), "step restore"); got != len(tests) {
+		t.Errorf("mono.log has %d log lines, want %d", got, len(tests))
+	}
+	if got := strings.Count(string(data), "linked target/debug/app"); got != 1 {
+		t.Errorf("mono.log has %d debug lines, want 1", got)
+	}
+}
@@ -0,0 +1,459 @@
+package mono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatchInterval = 500 * time.Millisecond
+	defaultWatchDebounce = 300 * time.Millisecond
+)
+
+type WatchConfig struct {
+	Interval string `yaml:"interval"`
+	Debounce string `yaml:"debounce"`
+}
+
+func (wc WatchConfig) Validate() error {
+	_, _, err := wc.durations()
+	return err
+}
+
+func (wc WatchConfig) durations() (time.Duration, time.Duration, error) {
+	interval, debounce := defaultWatchInterval, defaultWatchDebounce
+	if wc.Interval != "" {
+		d, err := time.ParseDuration(wc.Interval)
+		if err != nil || d <= 0 {
+			return 0, 0, fmt.Errorf("invalid watch.interval %q: expected e.g. 500ms or 2s", wc.Interval)
+		}
+		interval = d
+	}
+	if wc.Debounce != "" {
+		d, err := time.ParseDuration(wc.Debounce)
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("invalid watch.debounce %q: expected e.g. 300ms or 1s", wc.Debounce)
+		}
+		debounce = d
+	}
+	return interval, debounce, nil
+}
+
+func validatePatterns(owner, field string, patterns []string) error {
+	for _, p := range patterns {
+		clean := filepath.Clean(p)
+		if p == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s: invalid %s %q: must be inside the environment", owner, field, p)
+		}
+		if _, err := filepath.Match(filepath.ToSlash(clean), ""); err != nil {
+			return fmt.Errorf("%s: invalid %s %q: %w", owner, field, p, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) ValidateWatch() error {
+	if err := c.Watch.Validate(); err != nil {
+		return err
+	}
+	for _, s := range c.Services {
+		if err := validatePatterns("service "+s.Name, "watch pattern", s.Watch); err != nil {
+			return err
+		}
+	}
+	for _, w := range c.Tmux.Windows {
+		if len(w.Watch) > 0 && w.Command == "" {
+			return fmt.Errorf("tmux window %s watches files but has no command to re-run", w.Name)
+		}
+		if err := validatePatterns("tmux window "+w.Name, "watch pattern", w.Watch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchGlob(pattern, rel string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+func globRoot(pattern string) string {
+	var static []string
+	for _, part := range strings.Split(pattern, "/") {
+		if strings.ContainsAny(part, "*?[") {
+			break
+		}
+		static = append(static, part)
+	}
+	if len(static) == 0 {
+		return "."
+	}
+	return strings.Join(static, "/")
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+type watchTarget struct {
+	Name     string
+	Kind     string
+	Dir      string
+	Patterns []string
+	restart  func() error
+
+	files      map[string]fileStamp
+	pending    []string
+	lastChange time.Time
+}
+
+func cleanPatterns(patterns []string) []string {
+	var cleaned []string
+	for _, p := range patterns {
+		cleaned = append(cleaned, filepath.ToSlash(filepath.Clean(p)))
+	}
+	return cleaned
+}
+
+func matchesPatterns(patterns []string, rel string) bool {
+	for p := rel; p != "."; p = filepath.ToSlash(filepath.Dir(p)) {
+		for _, pattern := range patterns {
+			if matchGlob(pattern, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newWatchTarget(name, kind, dir string, patterns []string, restart func() error) *watchTarget {
+	return &watchTarget{Name: name, Kind: kind, Dir: dir, Patterns: cleanPatterns(patterns), restart: restart}
+}
+
+func (t *watchTarget) roots() []string {
+	var roots []string
+	for _, p := range t.Patterns {
+		if root := filepath.Join(t.Dir, filepath.FromSlash(globRoot(p))); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+func (t *watchTarget) scan() (map[string]fileStamp, error) {
+	files, err := globFiles(t.Dir, t.Patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan files of %s: %w", t.Name, err)
+	}
+	return files, nil
+}
+
+func globFiles(dir string, patterns []string) (map[string]fileStamp, error) {
+	var roots []string
+	for _, p := range patterns {
+		if root := globRoot(p); !slices.Contains(roots, root) {
+			roots = append(roots, root)
+		}
+	}
+	slices.Sort(roots)
+
+	files := make(map[string]fileStamp)
+	for _, root := range roots {
+		start := filepath.Join(dir, root)
+		err := filepath.WalkDir(start, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if path != start && skipDirs[d.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if _, seen := files[rel]; seen || !matchesPatterns(patterns, rel) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			files[rel] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+	return files, nil
+}
+
+func diffSnapshots(before, after map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range after {
+		if prev, ok := before[path]; !ok || !prev.modTime.Equal(stamp.modTime) || prev.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+func summarizeFiles(files []string) string {
+	if len(files) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(files[:3], ", "), len(files)-3)
+	}
+	return strings.Join(files, ", ")
+}
+
+type Watcher struct {
+	targets  []*watchTarget
+	interval time.Duration
+	debounce time.Duration
+	out      io.Writer
+	logger   *FileLogger
+	env      string
+	notify   NotifyConfig
+}
+
+func (w *Watcher) poll(now time.Time) error {
+	for _, t := range w.targets {
+		files, err := t.scan()
+		if err != nil {
+			return err
+		}
+		if changed := diffSnapshots(t.files, files); len(changed) > 0 {
+			for _, path := range changed {
+				if !slices.Contains(t.pending, path) {
+					t.pending = append(t.pending, path)
+				}
+			}
+			t.lastChange = now
+		}
+		t.files = files
+
+		if len(t.pending) == 0 || now.Sub(t.lastChange) < w.debounce {
+			continue
+		}
+		slices.Sort(t.pending)
+		summary := summarizeFiles(t.pending)
+		t.pending = nil
+		fmt.Fprintf(w.out, "[%s] restarting %s: %s changed\n", now.Format("15:04:05"), t.Name, summary)
+		w.logger.Log("restarting %s %s: %s changed", t.Kind, t.Name, summary)
+		start := time.Now()
+		err = t.restart()
+		if err != nil {
+			fmt.Fprintf(w.out, "[%s] failed to restart %s: %v\n", now.Format("15:04:05"), t.Name, err)
+			w.logger.Log("warning: failed to restart %s: %v", t.Name, err)
+		}
+		w.notify.Send(Notification{Operation: "restart of " + t.Name, Env: w.env, Duration: time.Since(start), Err: err}, w.logger)
+	}
+	return nil
+}
+
+func (w *Watcher) nextRestart(now time.Time) (time.Duration, bool) {
+	var wait time.Duration
+	found := false
+	for _, t := range w.targets {
+		if len(t.pending) == 0 {
+			continue
+		}
+		if d := t.lastChange.Add(w.debounce).Sub(now); !found || d < wait {
+			wait, found = d, true
+		}
+	}
+	return max(wait, 0), found
+}
+
+func (w *Watcher) subscribe(sub *FSSubscription) error {
+	for _, t := range w.targets {
+		for _, root := range t.roots() {
+			if err := sub.Watch(root, WatchRecursive); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) Run(ctx context.Context) (err error) {
+	for _, t := range w.targets {
+		files, err := t.scan()
+		if err != nil {
+			return err
+		}
+		t.files = files
+	}
+
+	fsw, err := NewFSWatcher(w.logger)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, fsw.Close())
+	}()
+	sub := fsw.Subscribe()
+	defer sub.Close()
+
+	var fallback <-chan time.Time
+	if err := w.subscribe(sub); err != nil {
+		fmt.Fprintf(w.out, "file events unavailable, checking every %s: %v\n", w.interval, err)
+		w.logger.Log("warning: file events unavailable, checking every %s: %v", w.interval, err)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		fallback = ticker.C
+	}
+
+	settle := time.NewTimer(w.debounce)
+	settle.Stop()
+	defer settle.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sub.Events():
+			now = time.Now()
+		case now = <-settle.C:
+		case now = <-fallback:
+		}
+		if err := w.poll(now); err != nil {
+			return err
+		}
+		if wait, ok := w.nextRestart(now); ok {
+			settle.Reset(wait)
+		}
+	}
+}
+
+func Watch(ctx context.Context, path string, names []string, out io.Writer) error {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return err
+	}
+	cfg := ec.Config
+	if err := cfg.ValidateWatch(); err != nil {
+		return fmt.Errorf("invalid mono.yml: %w", err)
+	}
+	interval, debounce, err := cfg.Watch.durations()
+	if err != nil {
+		return err
+	}
+
+	envName := ec.Env.EnvName()
+	sessionName := SessionName(envName)
+	tm := NewTmuxManager(sessionName, ec.Env.Path, cfg.Tmux)
+	if !tm.SessionExists() {
+		return fmt.Errorf("tmux session does not exist: %s", sessionName)
+	}
+
+	logger, err := NewFileLogger(envName)
+	if err != nil {
+		return fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono watch %s", path)
+
+	monoHome, err := GetMonoHome()
+	if err != nil {
+		return fmt.Errorf("failed to get mono home: %w", err)
+	}
+	scriptDir := filepath.Join(monoHome, "data", envName, "services")
+	if err := os.MkdirAll(scriptDir, 0755); err != nil {
+		return fmt.Errorf("failed to create service script directory: %w", err)
+	}
+
+	var targets []*watchTarget
+	var available []string
+	for _, s := range cfg.SelectedProcessServices() {
+		available = append(available, s.Name)
+		if len(s.Watch) == 0 {
+			continue
+		}
+		targets = append(targets, newWatchTarget(s.Name, "service", s.Dir(ec.Env.Path), s.Watch, func() error {
+			return startService(tm, ec, s, scriptDir)
+		}))
+	}
+	for _, win := range cfg.Tmux.Windows {
+		if win.Command == "" {
+			continue
+		}
+		available = append(available, win.Name)
+		if len(win.Watch) == 0 {
+			continue
+		}
+		targets = append(targets, newWatchTarget(win.Name, "window", ec.Env.Path, win.Watch, func() error {
+			return tm.RestartWindow(win.Name, win.Command)
+		}))
+	}
+
+	if len(names) > 0 {
+		var selected []*watchTarget
+		for _, name := range names {
+			i := slices.IndexFunc(targets, func(t *watchTarget) bool { return t.Name == name })
+			if i < 0 {
+				if slices.Contains(available, name) {
+					return fmt.Errorf("%s has no watch patterns in mono.yml", name)
+				}
+				return unknownSelection("watch target", name, available)
+			}
+			selected = append(selected, targets[i])
+		}
+		targets = selected
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("nothing to watch: add watch patterns to services or tmux.windows in mono.yml")
+	}
+
+	fmt.Fprintf(out, "Watching %d target(s) in %s (Ctrl-C to stop):\n", len(targets), envName)
+	for _, t := range targets {
+		fmt.Fprintf(out, "  %s (%s): %s\n", t.Name, t.Kind, strings.Join(t.Patterns, ", "))
+	}
+
+	w := &Watcher{targets: targets, interval: interval, debounce: debounce, out: out, logger: logger, env: envName, notify: cfg.Notify}
+	return w.Run(ctx)
+}
@@ -0,0 +1,221 @@
+package mono
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "internal/api/server.go", true},
+		{"src/**/*.ts", "src/app.ts", true},
+		{"src/**/*.ts", "src/routes/users/index.ts", true},
+		{"src/**/*.ts", "web/src/app.ts", false},
+		{"*.go", "internal/main.go", false},
+		{"go.mod", "go.mod", true},
+		{"config/*.yml", "config/dev.yml", true},
+		{"config/*.yml", "config/nested/dev.yml", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestWatchTargetScan(t *testing.T) {
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{
+		"main.go":                       "package main\n",
+		"internal/api/server.go":        "package api\n",
+		"README.md":                     "docs",
+		"migrations/001_init.sql":       "create table users ();",
+		"node_modules/dep/index.go":     "package dep\n",
+		"internal/node_modules/x/x.go":  "package x\n",
+		"migrations/node_modules/y.sql": "noise",
+	})
+
+	target := newWatchTarget("api", "service", dir, []string{"**/*.go", "./migrations"}, nil)
+	files, err := target.scan()
+	if err != nil {
+		t.Fatalf("scan() error = %v", err)
+	}
+	var got []string
+	for path := range files {
+		got = append(got, path)
+	}
+	slices.Sort(got)
+	want := []string{"internal/api/server.go", "main.go", "migrations/001_init.sql"}
+	if !slices.Equal(got, want) {
+		t.Errorf("scan() = %v, want %v", got, want)
+	}
+
+	missing := newWatchTarget("web", "window", dir, []string{"web/src/**/*.ts"}, nil)
+	if files, err := missing.scan(); err != nil || len(files) != 0 {
+		t.Errorf("scan() of a missing directory = %v, %v, want nothing", files, err)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	before := map[string]fileStamp{
+		"a.go": {modTime: now, size: 10},
+		"b.go": {modTime: now, size: 10},
+		"c.go": {modTime: now, size: 10},
+	}
+	after := map[string]fileStamp{
+		"a.go": {modTime: now, size: 10},
+		"b.go": {modTime: now.Add(time.Second), size: 10},
+		"d.go": {modTime: now, size: 3},
+	}
+	if got, want := diffSnapshots(before, after), []string{"b.go", "c.go", "d.go"}; !slices.Equal(got, want) {
+		t.Errorf("diffSnapshots() = %v, want %v", got, want)
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"main.go": "package main\n"})
+	logger, err := NewFileLogger("watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	restarts := 0
+	target := newWatchTarget("api", "service", dir, []string{"**/*.go"}, func() error {
+		restarts++
+		return nil
+	})
+	if target.files, err = target.scan(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w := &Watcher{targets: []*watchTarget{target}, debounce: time.Second, out: &out, logger: logger}
+
+	start := time.Now()
+	poll := func(offset time.Duration) {
+		t.Helper()
+		if err := w.poll(start.Add(offset)); err != nil {
+			t.Fatalf("poll() error = %v", err)
+		}
+	}
+
+	writeBuildxFixture(t, dir, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	poll(0)
+	writeBuildxFixture(t, dir, map[string]string{"util.go": "package main\n"})
+	poll(500 * time.Millisecond)
+	if restarts != 0 {
+		t.Fatalf("restarted %d times while files were still changing", restarts)
+	}
+	poll(1200 * time.Millisecond)
+	if restarts != 0 {
+		t.Fatalf("restarted before the debounce period since the last change passed")
+	}
+	poll(1600 * time.Millisecond)
+	if restarts != 1 {
+		t.Fatalf("restarts = %d after changes settled, want 1", restarts)
+	}
+	if !strings.Contains(out.String(), "restarting api: main.go, util.go changed") {
+		t.Errorf("output = %q, want the changed files listed", out.String())
+	}
+	poll(3 * time.Second)
+	if restarts != 1 {
+		t.Errorf("restarts = %d without further changes, want 1", restarts)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "util.go")); err != nil {
+		t.Fatal(err)
+	}
+	poll(4 * time.Second)
+	poll(5 * time.Second)
+	if restarts != 2 {
+		t.Errorf("restarts = %d after deleting a file, want 2", restarts)
+	}
+}
+
+func TestWatcherRunUsesFileEvents(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	dir := t.TempDir()
+	writeBuildxFixture(t, dir, map[string]string{"main.go": "package main\n"})
+	logger, err := NewFileLogger("watch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	restarted := make(chan struct{}, 1)
+	target := newWatchTarget("api", "service", dir, []string{"internal/**/*.go"}, func() error {
+		restarted <- struct{}{}
+		return nil
+	})
+	var out bytes.Buffer
+	w := &Watcher{targets: []*watchTarget{target}, interval: time.Hour, debounce: 50 * time.Millisecond, out: &out, logger: logger}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+
+	deadline := time.After(5 * time.Second)
+	for restarts := 0; restarts == 0; {
+		writeBuildxFixture(t, dir, map[string]string{"internal/api/server.go": "package api\n"})
+		select {
+		case <-restarted:
+			restarts++
+		case <-time.After(200 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no restart after creating a matching file")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "restarting api: internal/api/server.go changed") {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestValidateWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"valid", Config{
+			Services: []ServiceConfig{{Name: "api", Command: "go run .", Watch: []string{"**/*.go"}}},
+			Tmux:     TmuxConfig{Windows: []TmuxWindow{{Name: "css", Command: "npm run css", Watch: []string{"styles"}}}},
+		}, ""},
+		{"outside env", Config{Services: []ServiceConfig{{Name: "api", Watch: []string{"../shared/**"}}}}, "must be inside the environment"},
+		{"bad glob", Config{Services: []ServiceConfig{{Name: "api", Watch: []string{"src/[a.go"}}}}, "invalid watch pattern"},
+		{"window without command", Config{Tmux: TmuxConfig{Windows: []TmuxWindow{{Name: "logs", Watch: []string{"*.log"}}}}}, "no command"},
+		{"bad debounce", Config{Watch: WatchConfig{Debounce: "soon"}}, "invalid watch.debounce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.ValidateWatch()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateWatch() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateWatch() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
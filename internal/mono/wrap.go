@@ -0,0 +1,199 @@
+package mono
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+func directoryArtifacts(artifacts []ArtifactConfig) []ArtifactConfig {
+	var kept []ArtifactConfig
+	for _, a := range artifacts {
+		if a.ArtifactType() == ArtifactDirectory {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+func restoreForCommand(cm *CacheManager, db *DB, artifacts []ArtifactConfig, rootPath, envPath string, out io.Writer, logger *FileLogger) (bool, error) {
+	entries, err := cm.PrepareArtifactCache(artifacts, rootPath, envPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare artifact cache: %w", err)
+	}
+
+	projectID := ComputeProjectID(rootPath)
+	allHit := true
+	for _, entry := range entries {
+		present := artifactPresent(entry)
+		replaced := !present
+		switch {
+		case entry.Hit && present:
+			fmt.Fprintf(out, "mono: %s is already in place (key: %.12s)\n", entry.Name, entry.Key)
+		case entry.Hit:
+			if err := cm.RestoreFromCache(entry, logger); err != nil {
+				return false, err
+			}
+			fmt.Fprintf(out, "mono: restored %s from cache (key: %.12s)\n", entry.Name, entry.Key)
+			logger.Log("cache hit for %s (key: %s)", entry.Name, entry.Key)
+		case present:
+			allHit = false
+			fmt.Fprintf(out, "mono: %s changed (key: %.12s), building on the existing copy\n", entry.Name, entry.Key)
+		default:
+			allHit = false
+			artifact := artifactByName(artifacts, entry.Name)
+			approx, manifest, err := cm.FindApproximateEntry(*artifact, rootPath, envPath)
+			if err != nil {
+				return false, fmt.Errorf("failed to find approximate cache for %s: %w", entry.Name, err)
+			}
+			if approx == nil {
+				replaced = false
+				fmt.Fprintf(out, "mono: no cache for %s (key: %.12s), building from scratch\n", entry.Name, entry.Key)
+				break
+			}
+			if err := cm.RestoreFromCache(*approx, logger); err != nil {
+				return false, err
+			}
+			fmt.Fprintf(out, "mono: restored approximate %s cache from %s@%.8s\n", entry.Name, manifest.Branch, manifest.Commit)
+			logger.Log("restored approximate %s cache from %s@%.8s (key: %s)", entry.Name, manifest.Branch, manifest.Commit, approx.Key)
+		}
+
+		summary, err := cm.restoreArtifactCrates(artifacts, entry.Name, rootPath, envPath, replaced, logger)
+		if err != nil {
+			return false, err
+		}
+		if summary != "" {
+			fmt.Fprintf(out, "mono: crates of %s: %s\n", entry.Name, summary)
+		}
+
+		event := "miss"
+		if entry.Hit {
+			event = "hit"
+		}
+		if err := db.RecordCacheEvent(event, projectID, entry.Name, entry.Key); err != nil {
+			logger.Log("warning: failed to record cache %s: %v", event, err)
+		}
+	}
+	return allHit, nil
+}
+
+func runWrappedCommand(args, env []string, dir string) (int, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %s: %w", args[0], err)
+	}
+	return 0, nil
+}
+
+func RunWrapped(path string, args []string, out io.Writer) (int, error) {
+	ec, err := LoadEnvContext(path)
+	if err != nil {
+		return 0, err
+	}
+	env, cfg := ec.Env, ec.Config
+
+	logger, err := NewFileLogger(env.EnvName())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create logger: %w", err)
+	}
+	defer logger.Close()
+	logger.Log("mono run %s -- %s", path, strings.Join(args, " "))
+
+	db, err := OpenDB()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	if err := db.TouchEnvironment(env.ID); err != nil {
+		return 0, err
+	}
+
+	cm, err := NewCacheManager()
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize cache: %w", err)
+	}
+	artifacts := directoryArtifacts(cfg.Build.Artifacts)
+	rootPath := env.RootPath.String
+	if rootPath == "" && len(artifacts) > 0 {
+		fmt.Fprintf(out, "mono: %s has no root path, running without the cache\n", env.EnvName())
+		artifacts = nil
+	}
+
+	allHit := true
+	if len(artifacts) > 0 {
+		lock, err := AcquireEnvLock(env.Path, "run", 0)
+		if err != nil {
+			return 0, err
+		}
+		allHit, err = restoreForCommand(cm, db, artifacts, rootPath, env.Path, out, logger)
+		lock.Release()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	secrets, err := ResolveSecrets(cfg.Env)
+	if err != nil {
+		return 0, err
+	}
+	vars := append(ShellEnv(ec, os.Environ()), fmt.Sprintf("MONO_CACHE_HIT=%t", allHit))
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	dir := env.Path
+	if _, inside := relativeTo(env.Path, cwd); inside {
+		dir = cwd
+	}
+	command := args
+	if cfg.Nix.Enabled {
+		command = cfg.Nix.Command(args...)
+	}
+
+	slot, err := AcquireBuildSlot(cfg.BuildQueue, env.EnvName(), strings.Join(args, " "), out)
+	if err != nil {
+		return 0, err
+	}
+	code, err := runWrappedCommand(command, append(withSecrets(vars, secrets), slot.Env()...), dir)
+	slot.Release()
+	if err != nil {
+		return 0, err
+	}
+	logger.Log("%s exited with %d", filepath.Base(args[0]), code)
+	if code != 0 || len(artifacts) == 0 {
+		return code, nil
+	}
+
+	lock, err := AcquireEnvLock(env.Path, "run", 0)
+	if err != nil {
+		return code, err
+	}
+	defer lock.Release()
+	if err := cm.Sync(artifacts, rootPath, env.Path, SyncOptions{HardlinkBack: true}); err != nil {
+		return code, err
+	}
+	fmt.Fprintln(out, "mono: synced artifacts to the cache")
+	logger.Log("synced artifacts after %s", strings.Join(args, " "))
+	return code, nil
+}
@@ -0,0 +1,86 @@
+package mono
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreForCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MONO_HOME", "")
+	cm, err := NewCacheManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	logger, err := NewFileLogger("wrap-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+
+	rootDir := t.TempDir()
+	envDir := t.TempDir()
+	writeBuildxFixture(t, envDir, map[string]string{
+		"build.lock":   "v1",
+		"out/artifact": "compiled",
+	})
+	artifacts := []ArtifactConfig{{Name: "out", KeyFiles: []string{"build.lock"}, Paths: []string{"out"}, Strategy: RestoreCopy}}
+	if err := cm.Sync(artifacts, rootDir, envDir, SyncOptions{HardlinkBack: true}); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	restore := func() (bool, string) {
+		t.Helper()
+		var out bytes.Buffer
+		hit, err := restoreForCommand(cm, db, artifacts, rootDir, envDir, &out, logger)
+		if err != nil {
+			t.Fatalf("restoreForCommand() error = %v", err)
+		}
+		return hit, out.String()
+	}
+
+	if hit, out := restore(); !hit || !strings.Contains(out, "out is already in place") {
+		t.Errorf("restore with the artifact present = %v, %q", hit, out)
+	}
+
+	if err := os.RemoveAll(filepath.Join(envDir, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if hit, out := restore(); !hit || !strings.Contains(out, "restored out from cache") {
+		t.Errorf("restore with the artifact missing = %v, %q", hit, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(envDir, "out", "artifact")); err != nil || string(data) != "compiled" {
+		t.Errorf("restored artifact = %q, %v", data, err)
+	}
+
+	writeBuildxFixture(t, envDir, map[string]string{"build.lock": "v2"})
+	if hit, out := restore(); hit || !strings.Contains(out, "out changed") {
+		t.Errorf("restore after the key changed = %v, %q", hit, out)
+	}
+}
+
+func TestRunWrappedCommand(t *testing.T) {
+	dir := t.TempDir()
+	code, err := runWrappedCommand([]string{"sh", "-c", "echo $MONO_TEST > value && exit 3"}, []string{"MONO_TEST=wrapped"}, dir)
+	if err != nil {
+		t.Fatalf("runWrappedCommand() error = %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "value")); err != nil || strings.TrimSpace(string(data)) != "wrapped" {
+		t.Errorf("command saw MONO_TEST = %q, %v", data, err)
+	}
+
+	if _, err := runWrappedCommand([]string{"mono-test-missing-binary"}, nil, dir); err == nil {
+		t.Error("runWrappedCommand() with a missing binary should fail")
+	}
+}